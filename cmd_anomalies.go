@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["anomalies"] = maintenanceCommand{
+		usage: "export [flags] <audit.jsonl>...  Export recorded anomalies to CSV or Parquet by time range and source",
+		run:   runAnomaliesCommand,
+	}
+}
+
+func runAnomaliesCommand(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return errors.New("usage: anomalies export [flags] <audit.jsonl>...")
+	}
+
+	var opts processor.AnomalyExportOptions
+	flags := flag.NewFlagSet("anomalies export", flag.ContinueOnError)
+	from := flags.String("from", "", "Earliest window end exported, as RFC 3339, a date or a duration ago such as 168h")
+	to := flags.String("to", "", "Window end exported up to, excluded, in the same forms as --from")
+	var sources stringList
+	flags.Var(&sources, "source", "Glob pattern of the log sources exported, may be repeated (defaults to all)")
+	flags.BoolVar(&opts.IncludeNormal, "all", false, "Export windows that were not anomalies too")
+	flags.StringVar(&opts.Format, "format", "csv", "Export format: csv or parquet")
+	var feedback stringList
+	flags.Var(&feedback, "feedback", "Feedback file whose labels fill the label column, may be repeated")
+	output := flags.String("output", "-", "File the export is written to, - for stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: anomalies export [flags] <audit.jsonl>...")
+	}
+
+	now := time.Now()
+	var err error
+	if opts.From, err = parseTimeFlag(*from, now); err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	if opts.To, err = parseTimeFlag(*to, now); err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	opts.Sources = sources
+
+	var audits []io.Reader
+	for _, path := range flags.Args() {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		audits = append(audits, f)
+	}
+	for _, path := range feedback {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		opts.Feedback = append(opts.Feedback, f)
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	stats, err := processor.ExportAnomalies(audits, out, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "exported %d of %d audit records", stats.Exported, stats.Records)
+	if stats.Unreadable > 0 {
+		fmt.Fprintf(os.Stderr, ", %d unreadable", stats.Unreadable)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
+
+// parseTimeFlag parses a time given as RFC 3339, a date, or a duration
+// before now. An empty value is the zero time.
+func parseTimeFlag(v string, now time.Time) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither RFC 3339, a date nor a duration", v)
+}
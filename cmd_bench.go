@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["bench"] = maintenanceCommand{
+		usage: "[flags] [config.yaml]  Measure detector throughput, allocations and scoring latency over generated traffic",
+		run:   runBenchCommand,
+	}
+}
+
+func runBenchCommand(args []string) error {
+	opts := processor.DefaultBenchOptions
+
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	flags.IntVar(&opts.Logs, "logs", opts.Logs, "Number of logs generated")
+	flags.IntVar(&opts.Sources, "sources", opts.Sources, "Number of sources generated for configs with a default_source")
+	flags.IntVar(&opts.IPs, "ips", opts.IPs, "Number of distinct source IPs per source")
+	flags.IntVar(&opts.EventsPerSecond, "eps", opts.EventsPerSecond, "Rate of the generated logs in event time")
+	flags.Float64Var(&opts.AnomalyRatio, "anomaly-ratio", opts.AnomalyRatio, "Fraction of logs carrying a spike")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errors.New("usage: bench [flags] [config.yaml]")
+	}
+
+	var confYAML []byte
+	if flags.NArg() == 1 {
+		var err error
+		if confYAML, err = os.ReadFile(flags.Arg(0)); err != nil {
+			return err
+		}
+	}
+
+	stats, err := processor.Bench(context.Background(), service.GlobalEnvironment(), confYAML, os.LookupEnv, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("logs:            %d\n", stats.Logs)
+	fmt.Printf("windows:         %d\n", stats.Windows)
+	fmt.Printf("elapsed:         %v\n", stats.Elapsed)
+	fmt.Printf("logs/sec:        %.0f\n", stats.LogsPerSecond)
+	fmt.Printf("windows/sec:     %.0f\n", stats.WindowsPerSecond)
+	fmt.Printf("allocs/log:      %.1f\n", stats.AllocsPerLog)
+	fmt.Printf("bytes/log:       %.0f\n", stats.BytesPerLog)
+	fmt.Printf("scoring p50:     %v\n", stats.ScoringP50)
+	fmt.Printf("scoring p99:     %v\n", stats.ScoringP99)
+	return nil
+}
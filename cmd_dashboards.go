@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["dashboards"] = maintenanceCommand{
+		usage: "export [flags]  Write a Grafana dashboard for the detector metrics",
+		run:   runDashboardsCommand,
+	}
+}
+
+func runDashboardsCommand(args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return errors.New("usage: dashboards export [flags]")
+	}
+
+	flags := flag.NewFlagSet("dashboards export", flag.ContinueOnError)
+	title := flags.String("title", "Firewall Anomaly Detector", "Dashboard title")
+	datasource := flags.String("datasource", "Prometheus", "Name or UID of the Prometheus datasource")
+	tenants := flags.Bool("tenants", false, "Add a tenant variable, for detectors configured with a tenant_field")
+	output := flags.String("output", "", "File to write the dashboard to (defaults to stdout)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	dashboard, err := processor.GrafanaDashboard(processor.DashboardOptions{
+		Title:      *title,
+		Datasource: *datasource,
+		Tenants:    *tenants,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = fmt.Println(string(dashboard))
+		return err
+	}
+	return os.WriteFile(*output, append(dashboard, '\n'), 0o644)
+}
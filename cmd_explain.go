@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["explain"] = maintenanceCommand{
+		usage: "[flags] <config.yaml> <logs>  Print the features and scores of the windows of one log file",
+		run:   runExplainCommand,
+	}
+}
+
+func runExplainCommand(args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("usage: explain [flags] <config.yaml> <logs>")
+	}
+
+	confYAML, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var logs io.Reader = os.Stdin
+	if path := flags.Arg(1); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		logs = f
+	}
+
+	report, err := processor.Explain(context.Background(), service.GlobalEnvironment(), confYAML, os.LookupEnv, logs)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Printf("%d logs, %d windows, %d dropped\n\n", report.Logs, len(report.Windows), len(report.Dropped))
+	for _, w := range report.Windows {
+		name := w.Source
+		if w.Tenant != "" {
+			name = w.Tenant + "/" + name
+		}
+		verdict := "normal"
+		switch {
+		case w.Suppressed:
+			verdict = "anomaly, suppressed by " + w.SuppressedBy
+		case w.IsAnomaly:
+			verdict = "anomaly"
+		}
+		fmt.Printf("%s  %s - %s  %d samples\n", name, w.WindowStart.Format(time.RFC3339), w.WindowEnd.Format(time.RFC3339), w.Samples)
+		fmt.Printf("  score %.4f, threshold %.4f: %s\n", w.AnomalyScore, w.Threshold, verdict)
+
+		names := make([]string, 0, len(w.Features))
+		for name := range w.Features {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %-24s %g\n", name, w.Features[name])
+		}
+		fmt.Println()
+	}
+
+	if len(report.Dropped) > 0 {
+		fmt.Println("dropped logs:")
+		for _, d := range report.Dropped {
+			source := d.Source
+			if source == "" {
+				source = "-"
+			}
+			fmt.Printf("  line %-6d %-30s %s\n", d.Line, source, d.Reason)
+		}
+	}
+	return nil
+}
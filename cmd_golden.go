@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["golden"] = maintenanceCommand{
+		usage: "record|check [flags]  Record redacted logs and their detections as a golden fixture, or check fixtures",
+		run:   runGoldenCommand,
+	}
+}
+
+func runGoldenCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "record":
+			return runGoldenRecord(args[1:])
+		case "check":
+			return runGoldenCheck(args[1:])
+		}
+	}
+	return errors.New("usage: golden record|check [flags]")
+}
+
+func runGoldenRecord(args []string) error {
+	flags := flag.NewFlagSet("golden record", flag.ContinueOnError)
+	output := flags.String("output", "", "File the fixture is written to (required)")
+	description := flags.String("description", "", "What the fixture covers")
+	var redact stringList
+	flags.Var(&redact, "redact", "Dot separated path of a log field to redact besides IP addresses, may be repeated")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 || *output == "" {
+		return errors.New("usage: golden record --output <fixture.json> [flags] <config.yaml> <logs>")
+	}
+
+	confYAML, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var logs io.Reader = os.Stdin
+	if path := flags.Arg(1); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		logs = f
+	}
+
+	fixture, err := processor.RecordGolden(context.Background(), service.GlobalEnvironment(), confYAML, logs, redact)
+	if err != nil {
+		return err
+	}
+	fixture.Description = *description
+	if err := processor.WriteGoldenFixture(*output, fixture); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: %d logs, %d windows, %d dropped\n", *output, len(fixture.Logs), len(fixture.Expected.Windows), len(fixture.Expected.Dropped))
+	return nil
+}
+
+func runGoldenCheck(args []string) error {
+	flags := flag.NewFlagSet("golden check", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: golden check <fixture.json>...")
+	}
+
+	failed := 0
+	for _, path := range flags.Args() {
+		fixture, err := processor.ReadGoldenFixture(path)
+		if err != nil {
+			return err
+		}
+		diffs, err := fixture.Check(context.Background(), service.GlobalEnvironment())
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if len(diffs) == 0 {
+			fmt.Printf("%s: ok\n", path)
+			continue
+		}
+		failed++
+		fmt.Printf("%s: %d differences\n", path, len(diffs))
+		for _, diff := range diffs {
+			fmt.Printf("  %s\n", diff)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d fixtures changed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["init"] = maintenanceCommand{
+		usage: "[flags]  Generate a commented pipeline config, prompting for vendors and brokers",
+		run:   runInitCommand,
+	}
+}
+
+func runInitCommand(args []string) error {
+	opts := processor.DefaultScaffoldOptions
+
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	vendors := flags.String("vendors", "", "Comma separated firewall vendors, one of "+strings.Join(processor.ScaffoldVendors(), ", ")+" or any log_source")
+	brokers := flags.String("brokers", "", "Comma separated Kafka or Redpanda seed brokers")
+	flags.StringVar(&opts.RedisAddress, "redis", opts.RedisAddress, "Address of the Redis server logs are read from")
+	flags.StringVar(&opts.RedisKey, "redis-key", opts.RedisKey, "Redis list logs are read from")
+	flags.StringVar(&opts.AnomalyTopic, "anomaly-topic", opts.AnomalyTopic, "Topic anomalies are routed to")
+	flags.StringVar(&opts.NormalTopic, "normal-topic", opts.NormalTopic, "Topic normal windows are routed to")
+	flags.StringVar(&opts.Profile, "profile", opts.Profile, "Preset profile: none, datacenter_high_volume, branch_office or lab")
+	flags.BoolVar(&opts.DefaultSource, "default-source", opts.DefaultSource, "Score logs of unlisted sources rather than dropping them")
+	interactive := flags.Bool("prompt", isTerminal(os.Stdin), "Prompt for the vendors, brokers and Redis address not set by flags")
+	output := flags.String("output", "", "File to write the config to (defaults to stdout)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New("usage: init [flags]")
+	}
+
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	// Prompts go to stderr so that the config can be redirected
+	in := bufio.NewReader(os.Stdin)
+	ask := func(question, fallback string) (string, error) {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", question, fallback)
+		answer, err := in.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		if answer = strings.TrimSpace(answer); answer == "" {
+			return fallback, nil
+		}
+		return answer, nil
+	}
+
+	if *vendors == "" {
+		*vendors = strings.Join(opts.Vendors, ",")
+		if *interactive {
+			var err error
+			if *vendors, err = ask("Firewall vendors ("+strings.Join(processor.ScaffoldVendors(), ", ")+")", *vendors); err != nil {
+				return err
+			}
+		}
+	}
+	if *brokers == "" {
+		*brokers = strings.Join(opts.Brokers, ",")
+		if *interactive {
+			var err error
+			if *brokers, err = ask("Kafka or Redpanda brokers", *brokers); err != nil {
+				return err
+			}
+		}
+	}
+	if *interactive && !set["redis"] {
+		var err error
+		if opts.RedisAddress, err = ask("Redis address", opts.RedisAddress); err != nil {
+			return err
+		}
+	}
+	opts.Vendors = splitList(*vendors)
+	opts.Brokers = splitList(*brokers)
+
+	conf, err := processor.Scaffold(opts)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		_, err = fmt.Print(conf)
+		return err
+	}
+	return os.WriteFile(*output, []byte(conf), 0o644)
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
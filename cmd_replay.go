@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["replay"] = maintenanceCommand{
+		usage: "[flags] <config.yaml> [logs.jsonl]...  Backtest a detector config over historical logs",
+		run:   runReplayCommand,
+	}
+}
+
+func runReplayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	output := flags.String("output", "-", "File detections are written to as JSON lines, - for stdout")
+	anomaliesOnly := flags.Bool("anomalies-only", false, "Only write windows routed to the anomaly topic")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: replay [flags] <config.yaml> [logs.jsonl]...")
+	}
+
+	confYAML, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	// Logs are read from stdin without files, so that exports stored
+	// elsewhere can be streamed in, e.g. from `aws s3 cp s3://bucket/key -`
+	var inputs []io.Reader
+	for _, path := range flags.Args()[1:] {
+		if path == "-" {
+			inputs = append(inputs, os.Stdin, strings.NewReader("\n"))
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f, strings.NewReader("\n"))
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	w := bufio.NewWriter(out)
+
+	env := service.GlobalEnvironment()
+	stats, err := processor.Replay(context.Background(), env, confYAML, os.LookupEnv, io.MultiReader(inputs...), func(msg *service.Message, anomaly bool) error {
+		if *anomaliesOnly && !anomaly {
+			return nil
+		}
+		b, err := msg.AsBytes()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(append(b, '\n'))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "replayed %d logs: %d windows, %d anomalies\n", stats.Logs, stats.Results, stats.Anomalies)
+	return nil
+}
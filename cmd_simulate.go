@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["simulate"] = maintenanceCommand{
+		usage: "[flags] <config.yaml> [capture.jsonl]...  Replay a capture at a multiple of its rate, reporting lag and memory",
+		run:   runSimulateCommand,
+	}
+}
+
+func runSimulateCommand(args []string) error {
+	opts := processor.DefaultSimulationOptions
+
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	flags.Float64Var(&opts.Multiplier, "multiplier", opts.Multiplier, "Multiple of the capture's events per second it is replayed at")
+	flags.DurationVar(&opts.ReportInterval, "report-interval", opts.ReportInterval, "How often progress is reported")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: simulate [flags] <config.yaml> [capture.jsonl]...")
+	}
+
+	confYAML, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var inputs []io.Reader
+	for _, path := range flags.Args()[1:] {
+		if path == "-" {
+			inputs = append(inputs, os.Stdin, strings.NewReader("\n"))
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f, strings.NewReader("\n"))
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+
+	// Interrupting a long simulation still reports what was measured
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts.Progress = func(stats processor.SimulationStats) {
+		fmt.Fprintf(os.Stderr, "%8v  %d logs  %.0f/%.0f eps  lag %v  heap %s  %d windows\n",
+			stats.Elapsed.Round(100*time.Millisecond), stats.Logs, stats.AchievedEPS, stats.TargetEPS,
+			stats.Lag.Round(time.Microsecond), formatBytes(stats.HeapBytes), stats.OpenWindows)
+	}
+
+	stats, err := processor.Simulate(ctx, service.GlobalEnvironment(), confYAML, os.LookupEnv, io.MultiReader(inputs...), opts)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	fmt.Printf("logs:            %d\n", stats.Logs)
+	fmt.Printf("windows:         %d\n", stats.Windows)
+	fmt.Printf("elapsed:         %v\n", stats.Elapsed)
+	fmt.Printf("capture eps:     %.0f\n", stats.CaptureEPS)
+	fmt.Printf("target eps:      %.0f\n", stats.TargetEPS)
+	fmt.Printf("achieved eps:    %.0f\n", stats.AchievedEPS)
+	fmt.Printf("lag p50:         %v\n", stats.LagP50)
+	fmt.Printf("lag p99:         %v\n", stats.LagP99)
+	fmt.Printf("lag max:         %v\n", stats.MaxLag)
+	fmt.Printf("lag final:       %v\n", stats.Lag)
+	fmt.Printf("peak heap:       %s\n", formatBytes(stats.PeakHeapBytes))
+	fmt.Printf("peak windows:    %d\n", stats.PeakWindows)
+	return err
+}
+
+// formatBytes formats a byte count in binary units.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
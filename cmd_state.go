@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["state"] = maintenanceCommand{
+		usage: "migrate|inspect|reset [flags]  Upgrade, summarise or reset persisted window state",
+		run:   runStateCommand,
+	}
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// stateFlags are the flags locating persisted window state, shared by the
+// state subcommands.
+type stateFlags struct {
+	address  *string
+	password *string
+	db       *int
+	snapshot *string
+	hashes   stringList
+}
+
+func newStateFlags(name, verb string) (*flag.FlagSet, *stateFlags) {
+	flags := flag.NewFlagSet("state "+name, flag.ContinueOnError)
+	s := &stateFlags{
+		address:  flags.String("redis-address", "localhost:6379", "Redis server address"),
+		password: flags.String("redis-password", "", "Redis password"),
+		db:       flags.Int("redis-db", 0, "Redis database number"),
+		snapshot: flags.String("snapshot", "", "Window snapshot file to "+verb),
+	}
+	flags.Var(&s.hashes, "hash", "Redis hash of window state to "+verb+", may be repeated (defaults to the shutdown, spill and handoff hashes)")
+	return flags, s
+}
+
+func (s *stateFlags) client() *redis.Client {
+	if len(s.hashes) == 0 {
+		s.hashes = stringList{
+			"firewall_anomaly_detector:windows",
+			"firewall_anomaly_detector:spilled",
+			"firewall_anomaly_detector:handoff",
+		}
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     *s.address,
+		Password: *s.password,
+		DB:       *s.db,
+	})
+}
+
+func runStateCommand(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			return runStateMigrate(args[1:])
+		case "inspect":
+			return runStateInspect(args[1:])
+		case "reset":
+			return runStateReset(args[1:])
+		}
+	}
+	return errors.New("usage: state migrate|inspect|reset [flags]")
+}
+
+func runStateMigrate(args []string) error {
+	flags, state := newStateFlags("migrate", "migrate")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client := state.client()
+	defer client.Close()
+
+	ctx := context.Background()
+	for _, hash := range state.hashes {
+		res, err := processor.MigrateWindowStateHash(ctx, client, hash)
+		if err != nil {
+			return fmt.Errorf("migrating %s: %w", hash, err)
+		}
+		fmt.Printf("%s: %d migrated, %d current, %d unreadable\n", hash, res.Migrated, res.Current, res.Unreadable)
+	}
+
+	if *state.snapshot != "" {
+		migrated, err := processor.MigrateSnapshotFile(*state.snapshot)
+		if err != nil {
+			return fmt.Errorf("migrating %s: %w", *state.snapshot, err)
+		}
+		if migrated {
+			fmt.Printf("%s: migrated\n", *state.snapshot)
+		} else {
+			fmt.Printf("%s: current\n", *state.snapshot)
+		}
+	}
+	return nil
+}
+
+func runStateInspect(args []string) error {
+	flags, state := newStateFlags("inspect", "inspect")
+	source := flags.String("source", "", "Only summarise the windows of this log source")
+	skipRedis := flags.Bool("no-redis", false, "Only inspect the snapshot file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var summaries []processor.WindowSummary
+	if !*skipRedis {
+		client := state.client()
+		defer client.Close()
+
+		ctx := context.Background()
+		for _, hash := range state.hashes {
+			hashSummaries, err := processor.InspectWindowStateHash(ctx, client, hash)
+			if err != nil {
+				return fmt.Errorf("inspecting %s: %w", hash, err)
+			}
+			summaries = append(summaries, hashSummaries...)
+		}
+	}
+	if *state.snapshot != "" {
+		snapshotSummaries, err := processor.InspectSnapshotFile(*state.snapshot)
+		if err != nil {
+			return fmt.Errorf("inspecting %s: %w", *state.snapshot, err)
+		}
+		summaries = append(summaries, snapshotSummaries...)
+	}
+
+	fmt.Printf("%-34s  %-30s  %-10s  %7s  %5s  %12s  %12s  %12s  %12s  %6s  %7s  %s\n",
+		"location", "source", "tenant", "samples", "ips", "mean", "max", "baseline", "std_dev", "scores", "pending", "updated")
+	shown := 0
+	for _, s := range summaries {
+		if *source != "" && s.Source != *source {
+			continue
+		}
+		shown++
+		if s.Unreadable {
+			fmt.Printf("%-34s  %-30s  unreadable\n", s.Location, s.Key)
+			continue
+		}
+		tenant := s.Tenant
+		if tenant == "" {
+			tenant = "-"
+		}
+		baseline, stdDev := "-", "-"
+		if s.HasBaseline {
+			baseline, stdDev = fmt.Sprintf("%.2f", s.Baseline), fmt.Sprintf("%.2f", s.BaselineStdDev)
+		}
+		fmt.Printf("%-34s  %-30s  %-10s  %7d  %5d  %12.2f  %12.2f  %12s  %12s  %6d  %7d  %s\n",
+			s.Location, s.Source, tenant, s.Samples, s.UniqueIPs, s.Mean, s.Max, baseline, stdDev, s.Scores, s.Pending,
+			s.UpdatedAt.Format("2006-01-02T15:04:05Z07:00"))
+	}
+	fmt.Printf("%d windows\n", shown)
+	return nil
+}
+
+func runStateReset(args []string) error {
+	flags, state := newStateFlags("reset", "reset")
+	source := flags.String("source", "", "Log source whose windows and baseline are reset (required)")
+	skipRedis := flags.Bool("no-redis", false, "Only reset the snapshot file")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *source == "" {
+		return errors.New("usage: state reset --source <log_source> [flags]")
+	}
+
+	if !*skipRedis {
+		client := state.client()
+		defer client.Close()
+
+		ctx := context.Background()
+		for _, hash := range state.hashes {
+			deleted, err := processor.ResetWindowStateHash(ctx, client, hash, *source)
+			if err != nil {
+				return fmt.Errorf("resetting %s: %w", hash, err)
+			}
+			fmt.Printf("%s: %d windows of %s deleted\n", hash, deleted, *source)
+		}
+	}
+	if *state.snapshot != "" {
+		deleted, err := processor.ResetSnapshotFile(*state.snapshot, *source)
+		if err != nil {
+			return fmt.Errorf("resetting %s: %w", *state.snapshot, err)
+		}
+		fmt.Printf("%s: %d windows of %s deleted\n", *state.snapshot, deleted, *source)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+// defaultStatusPath is the default status_endpoint.path, requested when the
+// address given to the status command has no path.
+const defaultStatusPath = "/firewall_anomaly_detector/status"
+
+func init() {
+	maintenanceCommands["status"] = maintenanceCommand{
+		usage: "[flags] <address>  Print the model, sources and last emissions of a running instance",
+		run:   runStatusCommand,
+	}
+}
+
+func runStatusCommand(args []string) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "Print the status as JSON")
+	timeout := flags.Duration("timeout", 5*time.Second, "Timeout of the status request")
+	staleWindows := flags.Int("stale-windows", 2, "Flag sources that have not emitted for this many windows")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: status [flags] <address>")
+	}
+
+	target, err := statusURL(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: *timeout}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s, is status_endpoint enabled?", target, resp.Status)
+	}
+
+	var report processor.StatusReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	now := report.GeneratedAt
+	role := "active"
+	if !report.Active {
+		role = "standby"
+	}
+	if report.DryRun {
+		role += ", dry run"
+	}
+	fmt.Printf("instance   %s, up %s\n", role, since(now, &report.StartedAt))
+
+	model := "not loaded"
+	if report.Model.Loaded {
+		model = fmt.Sprintf("version %s, loaded %s ago", orDash(report.Model.Version), since(now, report.Model.LoadedAt))
+	}
+	if report.Model.Error != "" {
+		model += ", last load failed: " + report.Model.Error
+	}
+	fmt.Printf("model      %s (%s)\n", report.Model.Path, model)
+	fmt.Printf("threshold  %g\n", report.ScoreThreshold)
+	fmt.Printf("topics     %s, %s\n", report.AnomalyTopic, report.NormalTopic)
+	fmt.Printf("windows    %d active\n\n", report.ActiveWindows)
+
+	fmt.Printf("%-30s %-18s %9s %7s %6s %8s %14s %14s\n", "SOURCE", "METRIC", "THRESHOLD", "WINDOW", "ACTIVE", "EMITTED", "LAST EMISSION", "LAST ANOMALY")
+	for _, s := range report.Sources {
+		source := s.Source
+		if !s.Configured {
+			source += " (removed)"
+		}
+		window := "-"
+		if s.WindowSeconds > 0 {
+			window = (time.Duration(s.WindowSeconds) * time.Second).String()
+		}
+		lastEmission := "never"
+		if s.LastEmission != nil {
+			lastEmission = since(now, s.LastEmission) + " ago"
+		}
+		lastAnomaly := "never"
+		if s.LastAnomaly != nil {
+			lastAnomaly = since(now, s.LastAnomaly) + " ago"
+		}
+		fmt.Printf("%-30s %-18s %9g %7s %6d %8d %14s %14s%s\n",
+			source, orDash(s.Metric), s.ScoreThreshold, window, s.ActiveWindows, s.EmittedWindows,
+			lastEmission, lastAnomaly, staleMark(now, report.StartedAt, s, *staleWindows))
+	}
+	return nil
+}
+
+// statusURL resolves the address of an instance to the URL of its status,
+// defaulting the scheme to http and the path to the default endpoint path.
+func statusURL(address string) (string, error) {
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = defaultStatusPath
+	}
+	return u.String(), nil
+}
+
+// staleMark flags configured sources that have not emitted a window for
+// staleWindows windows, counting from the start of the instance when they
+// never emitted.
+func staleMark(now, startedAt time.Time, s processor.SourceStatus, staleWindows int) string {
+	if !s.Configured || s.WindowSeconds <= 0 || staleWindows <= 0 {
+		return ""
+	}
+	last := startedAt
+	if s.LastEmission != nil {
+		last = *s.LastEmission
+	}
+	if now.Sub(last) > time.Duration(staleWindows*s.WindowSeconds)*time.Second {
+		return "  STALE"
+	}
+	return ""
+}
+
+func since(now time.Time, t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return now.Sub(*t).Round(time.Second).String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
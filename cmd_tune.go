@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["tune"] = maintenanceCommand{
+		usage: "[flags] <config.yaml> [labeled.jsonl]...  Recommend score thresholds from labeled windows",
+		run:   runTuneCommand,
+	}
+}
+
+func runTuneCommand(args []string) error {
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	step := flags.Float64("step", 0.05, "Step between the candidate thresholds from 0 to 1")
+	output := flags.String("output", "", "File the recommended thresholds are written to as a config block")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: tune [flags] <config.yaml> [labeled.jsonl]...")
+	}
+	if *step <= 0 || *step > 1 {
+		return errors.New("step must be between 0 and 1")
+	}
+
+	confYAML, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var inputs []io.Reader
+	for _, path := range flags.Args()[1:] {
+		if path == "-" {
+			inputs = append(inputs, os.Stdin, strings.NewReader("\n"))
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		inputs = append(inputs, f, strings.NewReader("\n"))
+	}
+	if len(inputs) == 0 {
+		inputs = append(inputs, os.Stdin)
+	}
+
+	var thresholds []float64
+	for i := 0; float64(i)**step <= 1+1e-9; i++ {
+		thresholds = append(thresholds, math.Round(float64(i)**step*1e6)/1e6)
+	}
+
+	report, err := processor.Tune(context.Background(), service.GlobalEnvironment(), confYAML, os.LookupEnv, io.MultiReader(inputs...), thresholds)
+	if err != nil {
+		return err
+	}
+
+	printTuning("all sources", report.Overall)
+	for _, source := range report.Sources {
+		printTuning(source.Source, source)
+	}
+	if report.Skipped > 0 {
+		fmt.Printf("%d labeled windows skipped as none of their logs were windowed\n\n", report.Skipped)
+	}
+
+	block := report.ThresholdConfig()
+	if block == "" {
+		return errors.New("no source has windows of both labels to recommend a threshold from")
+	}
+	if *output != "" {
+		return os.WriteFile(*output, []byte(block), 0o644)
+	}
+	fmt.Print("# Recommended thresholds\n" + block)
+	return nil
+}
+
+func printTuning(name string, tuning processor.SourceTuning) {
+	fmt.Printf("%s: %d windows, %d anomalies", name, tuning.Windows, tuning.Anomalies)
+	if !tuning.Tunable {
+		fmt.Print(", no recommendation without windows of both labels\n\n")
+		return
+	}
+	fmt.Printf(", ROC AUC %.3f\n", tuning.AUC)
+	fmt.Printf("  %-9s  %-9s  %-6s  %-6s  %-6s  %s\n", "threshold", "precision", "recall", "fpr", "f1", "tp/fp/fn/tn")
+	for _, t := range tuning.Thresholds {
+		marker := ""
+		if t.Threshold == tuning.Recommended {
+			marker = "  <- recommended"
+		}
+		fmt.Printf("  %-9.2f  %-9.3f  %-6.3f  %-6.3f  %-6.3f  %d/%d/%d/%d%s\n",
+			t.Threshold, t.Precision, t.Recall, t.FalsePositiveRate, t.F1,
+			t.TruePositives, t.FalsePositives, t.FalseNegatives, t.TrueNegatives, marker)
+	}
+	fmt.Println()
+}
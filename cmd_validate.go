@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+
+	"github.com/jaykumar/redpanda-firewall-anomaly-detector/processor"
+)
+
+func init() {
+	maintenanceCommands["validate"] = maintenanceCommand{
+		usage: "[flags] <config.yaml>...  Check detector configs offline before deploying them",
+		run:   runValidateCommand,
+	}
+}
+
+func runValidateCommand(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	strict := flags.Bool("strict", false, "Fail on warnings as well as errors")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: validate [flags] <config.yaml>...")
+	}
+
+	var failed int
+	for _, path := range flags.Args() {
+		confYAML, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		issues, err := processor.ValidateConfig(service.GlobalEnvironment(), confYAML, os.LookupEnv)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, issue := range issues {
+			location := path
+			if issue.Line > 0 {
+				location = fmt.Sprintf("%s:%d", path, issue.Line)
+			}
+			fmt.Printf("%s: %s\n", location, issue)
+			if issue.Severity == processor.IssueError || *strict {
+				failed++
+			}
+		}
+		if len(issues) == 0 {
+			fmt.Printf("%s: ok\n", path)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d issues found", failed)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// maintenanceCommand is a subcommand handled by this binary rather than by
+// the Redpanda Connect CLI.
+type maintenanceCommand struct {
+	usage string
+	run   func(args []string) error
+}
+
+var maintenanceCommands = map[string]maintenanceCommand{}
+
+// runMaintenanceCommand runs the maintenance command named by the arguments,
+// returning false when they do not name one.
+func runMaintenanceCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	cmd, exists := maintenanceCommands[args[0]]
+	if !exists {
+		return false
+	}
+
+	if err := cmd.run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"os"
 
 	"github.com/redpanda-data/benthos/v4/public/service"
 
@@ -17,5 +18,10 @@ import (
 )
 
 func main() {
+	// Maintenance commands of the plugin are handled before the Redpanda
+	// Connect CLI, which owns every other command.
+	if runMaintenanceCommand(os.Args[1:]) {
+		return
+	}
 	service.RunCLI(context.Background())
 }
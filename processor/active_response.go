@@ -0,0 +1,710 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Firewall types the responder can block IPs on.
+const (
+	firewallFortiGate = "fortigate"
+	firewallPANOS     = "panos"
+)
+
+// Actions of the active responder, as metered.
+const (
+	responseBlock   = "block"
+	responseUnblock = "unblock"
+	responseDryRun  = "dry_run"
+)
+
+func activeResponseField() *service.ConfigField {
+	return service.NewObjectField("active_response",
+		service.NewBoolField("enabled").
+			Description("Block the offending IPs of detections matching the policy on the configured firewalls").
+			Default(false),
+		service.NewBoolField("dry_run").
+			Description("Log and meter the blocks that would be made without calling any firewall. Enabled by default so that the policy can be reviewed before it acts.").
+			Default(true),
+		service.NewFloatField("min_score").
+			Description("Lowest anomaly score that blocks its offending IPs").
+			Default(0.98),
+		service.NewStringListField("sources").
+			Description("Log sources whose detections block IPs. Applies to every source when empty.").
+			Default([]string{}),
+		service.NewStringListField("never_block").
+			Description("Addresses and CIDR prefixes never blocked, e.g. scanners, partners and the firewalls themselves").
+			Default([]string{}),
+		service.NewBoolField("external_only").
+			Description("Only block IPs routable on the internet, never private, loopback or link-local ones").
+			Default(true),
+		service.NewDurationField("ttl").
+			Description("How long an IP stays blocked after its last detection before it is removed again").
+			Default("1h"),
+		service.NewIntField("max_blocks").
+			Description("Most IPs blocked at once. Further IPs are not blocked until earlier blocks expire.").
+			Default(100),
+		service.NewStringField("state_key").
+			Description("Redis hash active blocks are kept in with their expiry, so that a restarted detector still removes them once due").
+			Default("firewall_anomaly_detector:active_blocks"),
+		service.NewObjectListField("firewalls",
+			service.NewStringField("name").
+				Description("Name of the firewall in logs and metrics"),
+			service.NewStringEnumField("type", firewallFortiGate, firewallPANOS).
+				Description("`fortigate` uses the FortiOS REST API, `panos` the PAN-OS XML API"),
+			service.NewStringField("url").
+				Description("Base URL of the management interface, e.g. `https://fw1.example.com`"),
+			service.NewStringField("api_key").
+				Description("API key, either a literal, a `${ENV_VAR}` reference or a `vault:<path>#<key>` reference").
+				Secret(),
+			service.NewStringField("address_group").
+				Description("Address group blocked IPs are added to, referenced by a deny policy. On PAN-OS this is the tag registered on the IPs, matched by a dynamic address group.").
+				Default("quarantine"),
+			service.NewStringField("vdom").
+				Description("FortiGate VDOM the address objects are created in").
+				Default("root"),
+			service.NewStringField("name_prefix").
+				Description("Prefix of the FortiGate address objects created for blocked IPs").
+				Default("fad-"),
+			service.NewBoolField("tls_skip_verify").
+				Description("Skip verification of the certificate of the management interface, e.g. when it is self-signed").
+				Default(false),
+			service.NewDurationField("timeout").
+				Description("Timeout of a single firewall request").
+				Default("10s"),
+		).
+			Description("Firewalls offending IPs are blocked on").
+			Default([]interface{}{}),
+	).
+		Description("Responder adding the offending IPs of detections above a very high score to a quarantine address group on FortiGate and PAN-OS firewalls, and removing them again after `ttl`").
+		Advanced()
+}
+
+// firewallClient adds and removes IPs of the quarantine of a firewall.
+type firewallClient interface {
+	Name() string
+	block(ctx context.Context, ip netip.Prefix, ttl time.Duration) error
+	unblock(ctx context.Context, ip netip.Prefix) error
+}
+
+// activeBlock is an IP blocked by the responder.
+type activeBlock struct {
+	Expires time.Time `json:"expires"`
+	// Firewalls holds the names of the firewalls the IP was blocked on
+	Firewalls []string `json:"firewalls"`
+}
+
+// responseRequest is a detection whose IPs the responder blocks.
+type responseRequest struct {
+	windowID string
+	source   string
+	ips      []string
+}
+
+// activeResponder blocks the offending IPs of severe detections on
+// firewalls. Firewalls are called from a background loop, so that slow
+// management interfaces never hold up scoring.
+type activeResponder struct {
+	logger       *service.Logger
+	dryRun       bool
+	minScore     float64
+	sources      map[string]struct{}
+	neverBlock   []netip.Prefix
+	externalOnly bool
+	ttl          time.Duration
+	maxBlocks    int
+	firewalls    []firewallClient
+	clock        func() time.Time
+	client       *redis.Client
+	stateKey     string
+
+	actions  *service.MetricCounter
+	failures *service.MetricCounter
+
+	mut    sync.Mutex
+	blocks map[netip.Prefix]*activeBlock
+
+	requests chan responseRequest
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newActiveResponderFromConfig(conf *service.ParsedConfig, client *redis.Client, mgr *service.Resources, secrets *secretResolver) (*activeResponder, error) {
+	enabled, err := conf.FieldBool("active_response", "enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	r := &activeResponder{
+		logger:   mgr.Logger(),
+		sources:  make(map[string]struct{}),
+		clock:    time.Now,
+		client:   client,
+		actions:  mgr.Metrics().NewCounter("active_response_actions", "firewall", "action"),
+		failures: mgr.Metrics().NewCounter("active_response_failures", "firewall"),
+		blocks:   make(map[netip.Prefix]*activeBlock),
+	}
+	if r.dryRun, err = conf.FieldBool("active_response", "dry_run"); err != nil {
+		return nil, err
+	}
+	if r.minScore, err = conf.FieldFloat("active_response", "min_score"); err != nil {
+		return nil, err
+	}
+	sources, err := conf.FieldStringList("active_response", "sources")
+	if err != nil {
+		return nil, err
+	}
+	for _, source := range sources {
+		r.sources[source] = struct{}{}
+	}
+	neverBlock, err := conf.FieldStringList("active_response", "never_block")
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range neverBlock {
+		prefix, err := parseBlockPrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("active_response.never_block: %w", err)
+		}
+		r.neverBlock = append(r.neverBlock, prefix)
+	}
+	if r.externalOnly, err = conf.FieldBool("active_response", "external_only"); err != nil {
+		return nil, err
+	}
+	if r.ttl, err = conf.FieldDuration("active_response", "ttl"); err != nil {
+		return nil, err
+	}
+	if r.ttl <= 0 {
+		return nil, fmt.Errorf("active_response.ttl must be positive, got %v", r.ttl)
+	}
+	if r.maxBlocks, err = conf.FieldInt("active_response", "max_blocks"); err != nil {
+		return nil, err
+	}
+	if r.maxBlocks <= 0 {
+		return nil, fmt.Errorf("active_response.max_blocks must be positive, got %d", r.maxBlocks)
+	}
+	if r.stateKey, err = conf.FieldString("active_response", "state_key"); err != nil {
+		return nil, err
+	}
+
+	firewallConfs, err := conf.FieldObjectList("active_response", "firewalls")
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]struct{}, len(firewallConfs))
+	for i, firewallConf := range firewallConfs {
+		firewall, err := newFirewallClientFromConfig(firewallConf, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("active_response.firewalls[%d]: %w", i, err)
+		}
+		if _, exists := names[firewall.Name()]; exists {
+			return nil, fmt.Errorf("active_response.firewalls[%d]: name %s is not unique", i, firewall.Name())
+		}
+		names[firewall.Name()] = struct{}{}
+		r.firewalls = append(r.firewalls, firewall)
+	}
+	if len(r.firewalls) == 0 && !r.dryRun {
+		return nil, errors.New("active_response.firewalls must not be empty unless active_response.dry_run is set")
+	}
+	return r, nil
+}
+
+func newFirewallClientFromConfig(conf *service.ParsedConfig, secrets *secretResolver) (firewallClient, error) {
+	name, err := conf.FieldString("name")
+	if err != nil {
+		return nil, err
+	}
+	kind, err := conf.FieldString("type")
+	if err != nil {
+		return nil, err
+	}
+	baseURL, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := secrets.resolveCredential(conf, "api_key")
+	if err != nil {
+		return nil, err
+	}
+	group, err := conf.FieldString("address_group")
+	if err != nil {
+		return nil, err
+	}
+	skipVerify, err := conf.FieldBool("tls_skip_verify")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || baseURL == "" || apiKey == "" || group == "" {
+		return nil, errors.New("name, url, api_key and address_group must not be empty")
+	}
+
+	client := &http.Client{Timeout: timeout}
+	if skipVerify {
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	if kind == firewallPANOS {
+		return &panosFirewall{name: name, client: client, url: baseURL, apiKey: apiKey, tag: group}, nil
+	}
+	f := &fortiGateFirewall{name: name, client: client, url: baseURL, apiKey: apiKey, group: group}
+	if f.vdom, err = conf.FieldString("vdom"); err != nil {
+		return nil, err
+	}
+	if f.namePrefix, err = conf.FieldString("name_prefix"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// parseBlockPrefix parses an address, or a CIDR prefix, to a prefix.
+func parseBlockPrefix(s string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// start restores the blocks of a previous run and runs the background loop
+// calling firewalls.
+func (r *activeResponder) start(interval time.Duration) {
+	if r == nil {
+		return
+	}
+	if err := r.restore(context.Background()); err != nil {
+		r.logger.Errorf("Failed to restore active blocks: %v", err)
+	}
+	r.requests = make(chan responseRequest, r.maxBlocks)
+	r.shutdown = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.loop(interval)
+}
+
+func (r *activeResponder) loop(interval time.Duration) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case req := <-r.requests:
+			r.respond(context.Background(), req)
+		case <-ticker.C:
+			r.expire(context.Background())
+		case <-r.shutdown:
+			return
+		}
+	}
+}
+
+// observe queues the offending IPs of an alerted detection for blocking
+// when it matches the policy. Detections are dropped while the queue is
+// full rather than holding up scoring.
+func (r *activeResponder) observe(windowID, source string, score float64, ips []IPCount) {
+	if r == nil || score < r.minScore {
+		return
+	}
+	if len(r.sources) > 0 {
+		if _, exists := r.sources[source]; !exists {
+			return
+		}
+	}
+	req := responseRequest{windowID: windowID, source: source}
+	for _, ip := range ips {
+		req.ips = append(req.ips, ip.IP)
+	}
+	select {
+	case r.requests <- req:
+	default:
+		r.logger.Warnf("Active response queue is full, not blocking the IPs of window %s", windowID)
+	}
+}
+
+// blockable reports whether the policy allows blocking a prefix.
+func (r *activeResponder) blockable(prefix netip.Prefix) bool {
+	if r.externalOnly && !externalIP(prefix.Addr().String()) {
+		return false
+	}
+	for _, never := range r.neverBlock {
+		if never.Overlaps(prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// respond blocks the IPs of a detection, or extends their blocks.
+func (r *activeResponder) respond(ctx context.Context, req responseRequest) {
+	for _, ip := range req.ips {
+		prefix, err := parseBlockPrefix(ip)
+		if err != nil || !r.blockable(prefix) {
+			continue
+		}
+
+		now := r.clock()
+		r.mut.Lock()
+		block, blocked := r.blocks[prefix]
+		var extended activeBlock
+		if blocked {
+			block.Expires = now.Add(r.ttl)
+			extended = *block
+		}
+		full := len(r.blocks) >= r.maxBlocks
+		r.mut.Unlock()
+		if blocked {
+			r.persist(ctx, prefix, extended)
+			continue
+		}
+		if full {
+			r.logger.Warnf("Not blocking %s of window %s, active_response.max_blocks of %d is reached", prefix, req.windowID, r.maxBlocks)
+			continue
+		}
+
+		if r.dryRun {
+			r.logger.Infof("Dry run: would block %s for %v after detection %s on %s", prefix, r.ttl, req.windowID, req.source)
+			r.actions.Incr(1, "", responseDryRun)
+			continue
+		}
+
+		block = &activeBlock{Expires: now.Add(r.ttl)}
+		for _, firewall := range r.firewalls {
+			if err := firewall.block(ctx, prefix, r.ttl); err != nil {
+				r.logger.Errorf("Failed to block %s on firewall %s: %v", prefix, firewall.Name(), err)
+				r.failures.Incr(1, firewall.Name())
+				continue
+			}
+			r.logger.Warnf("Blocked %s on firewall %s for %v after detection %s on %s", prefix, firewall.Name(), r.ttl, req.windowID, req.source)
+			r.actions.Incr(1, firewall.Name(), responseBlock)
+			block.Firewalls = append(block.Firewalls, firewall.Name())
+		}
+		if len(block.Firewalls) > 0 {
+			r.mut.Lock()
+			r.blocks[prefix] = block
+			r.mut.Unlock()
+			r.persist(ctx, prefix, *block)
+		}
+	}
+}
+
+// expire removes the blocks whose TTL has passed.
+func (r *activeResponder) expire(ctx context.Context) {
+	now := r.clock()
+	r.mut.Lock()
+	var due []netip.Prefix
+	for prefix, block := range r.blocks {
+		if !now.Before(block.Expires) {
+			due = append(due, prefix)
+		}
+	}
+	r.mut.Unlock()
+
+	for _, prefix := range due {
+		r.unblock(ctx, prefix)
+	}
+}
+
+// unblock removes a block from the firewalls it was made on. Firewalls that
+// fail keep the block, so that it is retried.
+func (r *activeResponder) unblock(ctx context.Context, prefix netip.Prefix) {
+	r.mut.Lock()
+	block, exists := r.blocks[prefix]
+	r.mut.Unlock()
+	if !exists {
+		return
+	}
+
+	var remaining []string
+	for _, firewall := range r.firewalls {
+		if !slices.Contains(block.Firewalls, firewall.Name()) {
+			continue
+		}
+		if err := firewall.unblock(ctx, prefix); err != nil {
+			r.logger.Errorf("Failed to unblock %s on firewall %s: %v", prefix, firewall.Name(), err)
+			r.failures.Incr(1, firewall.Name())
+			remaining = append(remaining, firewall.Name())
+			continue
+		}
+		r.logger.Infof("Unblocked %s on firewall %s", prefix, firewall.Name())
+		r.actions.Incr(1, firewall.Name(), responseUnblock)
+	}
+
+	r.mut.Lock()
+	if len(remaining) == 0 {
+		delete(r.blocks, prefix)
+	} else {
+		block.Firewalls = remaining
+	}
+	kept := *block
+	r.mut.Unlock()
+
+	if len(remaining) == 0 {
+		r.forget(ctx, prefix)
+	} else {
+		r.persist(ctx, prefix, kept)
+	}
+}
+
+// persist stores a block in Redis, so that the next detector to start
+// restores it and removes it once due.
+func (r *activeResponder) persist(ctx context.Context, prefix netip.Prefix, block activeBlock) {
+	if r.client == nil {
+		return
+	}
+	raw, err := json.Marshal(block)
+	if err == nil {
+		err = r.client.HSet(ctx, r.stateKey, prefix.String(), raw).Err()
+	}
+	if err != nil {
+		r.logger.Errorf("Failed to store the block of %s: %v", prefix, err)
+	}
+}
+
+// forget removes a lifted block from Redis.
+func (r *activeResponder) forget(ctx context.Context, prefix netip.Prefix) {
+	if r.client == nil {
+		return
+	}
+	if err := r.client.HDel(ctx, r.stateKey, prefix.String()).Err(); err != nil {
+		r.logger.Errorf("Failed to remove the block of %s: %v", prefix, err)
+	}
+}
+
+// restore loads the blocks stored by previous runs. Blocks that expired
+// meanwhile are removed on the next expiry check.
+func (r *activeResponder) restore(ctx context.Context) error {
+	if r.client == nil {
+		return nil
+	}
+	stored, err := r.client.HGetAll(ctx, r.stateKey).Result()
+	if err != nil {
+		return err
+	}
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	for key, raw := range stored {
+		prefix, err := netip.ParsePrefix(key)
+		if err != nil {
+			r.logger.Warnf("Ignoring stored block of unreadable prefix %q", key)
+			continue
+		}
+		var block activeBlock
+		if err := json.Unmarshal([]byte(raw), &block); err != nil {
+			r.logger.Warnf("Ignoring unreadable stored block of %s: %v", prefix, err)
+			continue
+		}
+		r.blocks[prefix] = &block
+	}
+	if len(stored) > 0 {
+		r.logger.Infof("Restored %d active blocks", len(r.blocks))
+	}
+	return nil
+}
+
+// Close stops the background loop. Active blocks stay in place until their
+// TTL passes, as they are stored for the next detector to remove.
+func (r *activeResponder) Close(ctx context.Context) {
+	if r == nil {
+		return
+	}
+	if r.shutdown != nil {
+		close(r.shutdown)
+		<-r.done
+	}
+}
+
+// fortiGateFirewall blocks IPs through the FortiOS REST API, creating an
+// address object per IP and adding it to an address group.
+type fortiGateFirewall struct {
+	name       string
+	client     *http.Client
+	url        string
+	apiKey     string
+	group      string
+	vdom       string
+	namePrefix string
+}
+
+func (f *fortiGateFirewall) Name() string {
+	return f.name
+}
+
+// paths returns the address and address group tables of a prefix.
+func (f *fortiGateFirewall) paths(prefix netip.Prefix) (address, group string) {
+	if prefix.Addr().Is6() {
+		return "firewall/address6", "firewall/addrgrp6"
+	}
+	return "firewall/address", "firewall/addrgrp"
+}
+
+// objectName returns the name of the address object of a prefix.
+func (f *fortiGateFirewall) objectName(prefix netip.Prefix) string {
+	return f.namePrefix + strings.NewReplacer("/", "_", ":", "-").Replace(prefix.String())
+}
+
+func (f *fortiGateFirewall) block(ctx context.Context, prefix netip.Prefix, ttl time.Duration) error {
+	addressPath, groupPath := f.paths(prefix)
+	name := f.objectName(prefix)
+
+	address := map[string]interface{}{
+		"name":    name,
+		"comment": "Blocked by firewall_anomaly_detector",
+	}
+	if prefix.Addr().Is6() {
+		address["ip6"] = prefix.String()
+	} else {
+		address["subnet"] = prefix.String()
+	}
+	// The address may be left over from an earlier block, in which case
+	// creating it fails but adding it to the group succeeds
+	createErr := f.do(ctx, http.MethodPost, addressPath, address)
+	if err := f.do(ctx, http.MethodPost, groupPath+"/"+url.PathEscape(f.group)+"/member", map[string]string{"name": name}); err != nil {
+		return errors.Join(createErr, err)
+	}
+	return nil
+}
+
+func (f *fortiGateFirewall) unblock(ctx context.Context, prefix netip.Prefix) error {
+	addressPath, groupPath := f.paths(prefix)
+	name := url.PathEscape(f.objectName(prefix))
+	if err := f.do(ctx, http.MethodDelete, groupPath+"/"+url.PathEscape(f.group)+"/member/"+name, nil); err != nil {
+		return err
+	}
+	return f.do(ctx, http.MethodDelete, addressPath+"/"+name, nil)
+}
+
+func (f *fortiGateFirewall) do(ctx context.Context, method, path string, payload interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, f.url+"/api/v2/cmdb/"+path+"?vdom="+url.QueryEscape(f.vdom), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+f.apiKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s returned status %d: %s", method, path, resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// panosFirewall blocks IPs through the PAN-OS XML API by registering a tag
+// on them, matched by a dynamic address group. Registrations carry the TTL
+// as their timeout, so that PAN-OS removes them even if the detector never
+// does.
+type panosFirewall struct {
+	name   string
+	client *http.Client
+	url    string
+	apiKey string
+	tag    string
+}
+
+func (p *panosFirewall) Name() string {
+	return p.name
+}
+
+func (p *panosFirewall) block(ctx context.Context, prefix netip.Prefix, ttl time.Duration) error {
+	return p.userID(ctx, fmt.Sprintf(
+		`<register><entry ip="%s" persistent="1"><tag><member timeout="%d">%s</member></tag></entry></register>`,
+		xmlEscape(panosAddress(prefix)), int(ttl.Seconds()), xmlEscape(p.tag)))
+}
+
+func (p *panosFirewall) unblock(ctx context.Context, prefix netip.Prefix) error {
+	return p.userID(ctx, fmt.Sprintf(
+		`<unregister><entry ip="%s"><tag><member>%s</member></tag></entry></unregister>`,
+		xmlEscape(panosAddress(prefix)), xmlEscape(p.tag)))
+}
+
+// panosAddress returns the address of a single IP, or the CIDR prefix of
+// an aggregated one, as registered.
+func panosAddress(prefix netip.Prefix) string {
+	if prefix.IsSingleIP() {
+		return prefix.Addr().String()
+	}
+	return prefix.String()
+}
+
+// userID sends a User-ID message with a payload of tag registrations.
+func (p *panosFirewall) userID(ctx context.Context, payload string) error {
+	form := url.Values{
+		"type": {"user-id"},
+		"cmd":  {"<uid-message><version>2.0</version><type>update</type><payload>" + payload + "</payload></uid-message>"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url+"/api/", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-PAN-KEY", p.apiKey)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("PAN-OS API returned status %d: %s", resp.StatusCode, respBody)
+	}
+	var result struct {
+		Status string `xml:"status,attr"`
+		Msg    string `xml:",innerxml"`
+	}
+	if err := xml.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("invalid PAN-OS API response: %w", err)
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("PAN-OS API returned status %s: %s", result.Status, strings.TrimSpace(result.Msg))
+	}
+	return nil
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,267 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseActiveResponder(t *testing.T, yaml string) (*activeResponder, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(activeResponseField()).Field(secretsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	secrets, err := newSecretResolverFromConfig(conf.Namespace("secrets"))
+	require.NoError(t, err)
+	return newActiveResponderFromConfig(conf, nil, service.MockResources(), secrets)
+}
+
+func TestActiveResponseConfig(t *testing.T) {
+	r, err := parseActiveResponder(t, `active_response: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, r)
+
+	// Dry runs need no firewalls
+	r, err = parseActiveResponder(t, `active_response: { enabled: true }`)
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.True(t, r.dryRun)
+	assert.Equal(t, 0.98, r.minScore)
+
+	for _, yaml := range []string{
+		`active_response: { enabled: true, dry_run: false }`,
+		`active_response: { enabled: true, never_block: [ "not an ip" ] }`,
+		`active_response: { enabled: true, ttl: 0s }`,
+		`active_response: { enabled: true, max_blocks: 0 }`,
+		`active_response: { enabled: true, firewalls: [ { name: fw1, type: fortigate, url: "https://fw1", api_key: "" } ] }`,
+		`active_response: { enabled: true, firewalls: [ { name: fw1, type: fortigate, url: "https://fw1", api_key: abc }, { name: fw1, type: panos, url: "https://fw2", api_key: abc } ] }`,
+	} {
+		_, err = parseActiveResponder(t, yaml)
+		assert.Error(t, err, yaml)
+	}
+}
+
+// recordingFirewall records the blocks made on it.
+type recordingFirewall struct {
+	name string
+	fail bool
+
+	mut     sync.Mutex
+	blocked map[string]time.Duration
+	calls   int
+}
+
+func (f *recordingFirewall) Name() string {
+	return f.name
+}
+
+func (f *recordingFirewall) block(ctx context.Context, ip netip.Prefix, ttl time.Duration) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.calls++
+	if f.fail {
+		return assert.AnError
+	}
+	f.blocked[ip.String()] = ttl
+	return nil
+}
+
+func (f *recordingFirewall) unblock(ctx context.Context, ip netip.Prefix) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.calls++
+	if f.fail {
+		return assert.AnError
+	}
+	delete(f.blocked, ip.String())
+	return nil
+}
+
+func testActiveResponder(dryRun bool, firewalls ...firewallClient) *activeResponder {
+	mgr := service.MockResources()
+	return &activeResponder{
+		logger:       mgr.Logger(),
+		dryRun:       dryRun,
+		minScore:     0.95,
+		sources:      map[string]struct{}{"fortinet.firewall": {}},
+		neverBlock:   []netip.Prefix{netip.MustParsePrefix("198.51.100.0/24")},
+		externalOnly: true,
+		ttl:          time.Hour,
+		maxBlocks:    2,
+		firewalls:    firewalls,
+		clock:        time.Now,
+		actions:      mgr.Metrics().NewCounter("active_response_actions", "firewall", "action"),
+		failures:     mgr.Metrics().NewCounter("active_response_failures", "firewall"),
+		blocks:       make(map[netip.Prefix]*activeBlock),
+		requests:     make(chan responseRequest, 10),
+	}
+}
+
+func TestActiveResponsePolicy(t *testing.T) {
+	r := testActiveResponder(false)
+	ips := []IPCount{{IP: "203.0.113.5", Count: 10}}
+
+	r.observe("w1", "fortinet.firewall", 0.9, ips)
+	r.observe("w2", "paloalto.firewall", 0.99, ips)
+	assert.Empty(t, r.requests, "detections below min_score or of other sources are ignored")
+
+	r.observe("w3", "fortinet.firewall", 0.99, ips)
+	require.Len(t, r.requests, 1)
+	assert.Equal(t, responseRequest{windowID: "w3", source: "fortinet.firewall", ips: []string{"203.0.113.5"}}, <-r.requests)
+
+	assert.True(t, r.blockable(netip.MustParsePrefix("203.0.113.5/32")))
+	assert.True(t, r.blockable(netip.MustParsePrefix("2001:db8::/64")))
+	assert.False(t, r.blockable(netip.MustParsePrefix("10.0.0.1/32")), "private IPs are never blocked")
+	assert.False(t, r.blockable(netip.MustParsePrefix("198.51.100.7/32")), "never_block is honoured")
+	assert.False(t, r.blockable(netip.MustParsePrefix("198.51.0.0/16")), "prefixes overlapping never_block are not blocked")
+}
+
+func TestActiveResponseBlocks(t *testing.T) {
+	fw1 := &recordingFirewall{name: "fw1", blocked: map[string]time.Duration{}}
+	fw2 := &recordingFirewall{name: "fw2", blocked: map[string]time.Duration{}, fail: true}
+	r := testActiveResponder(false, fw1, fw2)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	r.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	r.respond(ctx, responseRequest{windowID: "w1", ips: []string{"203.0.113.5", "10.0.0.1", "::ffff:203.0.113.6"}})
+	assert.Equal(t, map[string]time.Duration{"203.0.113.5/32": time.Hour, "203.0.113.6/32": time.Hour}, fw1.blocked)
+	assert.Equal(t, []string{"fw1"}, r.blocks[netip.MustParsePrefix("203.0.113.5/32")].Firewalls, "failed firewalls are not tracked")
+
+	// max_blocks caps the active blocks, while blocked IPs are extended
+	now = now.Add(30 * time.Minute)
+	r.respond(ctx, responseRequest{windowID: "w2", ips: []string{"203.0.113.7", "203.0.113.5"}})
+	assert.Len(t, fw1.blocked, 2)
+	assert.Equal(t, now.Add(time.Hour), r.blocks[netip.MustParsePrefix("203.0.113.5/32")].Expires)
+
+	// Blocks are removed once their TTL passes
+	now = now.Add(45 * time.Minute)
+	r.expire(ctx)
+	assert.Equal(t, map[string]time.Duration{"203.0.113.5/32": time.Hour}, fw1.blocked)
+	assert.Len(t, r.blocks, 1)
+
+	// Closing leaves the remaining blocks in place until their TTL passes
+	r.Close(ctx)
+	assert.Len(t, fw1.blocked, 1)
+}
+
+func TestActiveResponseRestoresBlocks(t *testing.T) {
+	server, client := newHashServer(t)
+	fw := &recordingFirewall{name: "fw1", blocked: map[string]time.Duration{}}
+	r := testActiveResponder(false, fw)
+	r.client, r.stateKey = client, "blocks"
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	r.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	r.respond(ctx, responseRequest{windowID: "w1", ips: []string{"203.0.113.5", "203.0.113.6"}})
+	r.Close(ctx)
+	require.Len(t, fw.blocked, 2)
+
+	// A restarted detector takes over the blocks, and removes them once due
+	restarted := testActiveResponder(false, fw)
+	restarted.client, restarted.stateKey = client, "blocks"
+	restarted.clock = r.clock
+	require.NoError(t, restarted.restore(ctx))
+	require.Len(t, restarted.blocks, 2)
+	assert.Equal(t, activeBlock{Expires: now.Add(time.Hour), Firewalls: []string{"fw1"}}, *restarted.blocks[netip.MustParsePrefix("203.0.113.5/32")])
+
+	now = now.Add(time.Hour)
+	restarted.expire(ctx)
+	assert.Empty(t, fw.blocked)
+	assert.Empty(t, restarted.blocks)
+
+	server.mut.Lock()
+	defer server.mut.Unlock()
+	assert.Empty(t, server.hash)
+}
+
+func TestActiveResponseDryRun(t *testing.T) {
+	fw := &recordingFirewall{name: "fw1", blocked: map[string]time.Duration{}}
+	r := testActiveResponder(true, fw)
+
+	r.respond(context.Background(), responseRequest{windowID: "w1", ips: []string{"203.0.113.5"}})
+	assert.Zero(t, fw.calls)
+	assert.Empty(t, r.blocks)
+}
+
+func TestFortiGateFirewall(t *testing.T) {
+	var mut sync.Mutex
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		assert.Equal(t, "edge", r.URL.Query().Get("vdom"))
+		body, _ := io.ReadAll(r.Body)
+		mut.Lock()
+		calls = append(calls, r.Method+" "+r.URL.EscapedPath()+" "+string(body))
+		mut.Unlock()
+		// The address exists from an earlier block
+		if r.Method == http.MethodPost && r.URL.Path == "/api/v2/cmdb/firewall/address" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	r, err := parseActiveResponder(t, `
+active_response:
+  enabled: true
+  dry_run: false
+  firewalls:
+    - name: fw1
+      type: fortigate
+      url: `+server.URL+`
+      api_key: abc
+      vdom: edge
+`)
+	require.NoError(t, err)
+	fw := r.firewalls[0]
+	ctx := context.Background()
+
+	require.NoError(t, fw.block(ctx, netip.MustParsePrefix("203.0.113.5/32"), time.Hour))
+	require.NoError(t, fw.unblock(ctx, netip.MustParsePrefix("203.0.113.5/32")))
+	require.NoError(t, fw.block(ctx, netip.MustParsePrefix("2001:db8::/64"), time.Hour))
+	assert.Equal(t, []string{
+		`POST /api/v2/cmdb/firewall/address {"comment":"Blocked by firewall_anomaly_detector","name":"fad-203.0.113.5_32","subnet":"203.0.113.5/32"}`,
+		`POST /api/v2/cmdb/firewall/addrgrp/quarantine/member {"name":"fad-203.0.113.5_32"}`,
+		`DELETE /api/v2/cmdb/firewall/addrgrp/quarantine/member/fad-203.0.113.5_32 `,
+		`DELETE /api/v2/cmdb/firewall/address/fad-203.0.113.5_32 `,
+		`POST /api/v2/cmdb/firewall/address6 {"comment":"Blocked by firewall_anomaly_detector","ip6":"2001:db8::/64","name":"fad-2001-db8--_64"}`,
+		`POST /api/v2/cmdb/firewall/addrgrp6/quarantine/member {"name":"fad-2001-db8--_64"}`,
+	}, calls)
+}
+
+func TestPANOSFirewall(t *testing.T) {
+	var cmds []string
+	status := "success"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/", r.URL.Path)
+		assert.Equal(t, "abc", r.Header.Get("X-PAN-KEY"))
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "user-id", r.PostForm.Get("type"))
+		cmds = append(cmds, r.PostForm.Get("cmd"))
+		_, _ = w.Write([]byte(`<response status="` + status + `"><msg>done</msg></response>`))
+	}))
+	defer server.Close()
+
+	fw := &panosFirewall{name: "fw2", client: server.Client(), url: server.URL, apiKey: "abc", tag: "quarantine"}
+	ctx := context.Background()
+
+	require.NoError(t, fw.block(ctx, netip.MustParsePrefix("203.0.113.5/32"), time.Hour))
+	require.NoError(t, fw.unblock(ctx, netip.MustParsePrefix("203.0.113.5/32")))
+	require.Len(t, cmds, 2)
+	assert.Contains(t, cmds[0], `<register><entry ip="203.0.113.5" persistent="1"><tag><member timeout="3600">quarantine</member></tag></entry></register>`)
+	assert.Contains(t, cmds[1], `<unregister><entry ip="203.0.113.5"><tag><member>quarantine</member></tag></entry></unregister>`)
+
+	status = "error"
+	assert.ErrorContains(t, fw.block(ctx, netip.MustParsePrefix("203.0.113.5/32"), time.Hour), "status error")
+}
@@ -0,0 +1,188 @@
+package processor
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"gonum.org/v1/gonum/stat"
+)
+
+func adaptiveThresholdField() *service.ConfigField {
+	return service.NewObjectField("adaptive_threshold",
+		service.NewFloatField("percentile").
+			Description("Percentile of the recent scores of a source that a window has to exceed to be flagged, e.g. `99`. Zero disables adaptive thresholding.").
+			Default(0.0),
+		service.NewIntField("history").
+			Description("Number of recent window scores kept per source, bounding the precision of the percentile. Defaults to 1000, or the value of `profile`.").
+			Optional(),
+		service.NewIntField("min_samples").
+			Description("Scores a source needs before its adaptive threshold applies; until then the fixed threshold is used").
+			Default(100),
+		service.NewFloatField("min_threshold").
+			Description("Floor of the adaptive threshold, keeping sources that always score near zero from flagging noise").
+			Default(0.0),
+	).
+		Description("Flags windows whose score exceeds a rolling percentile of the recent scores of their source instead of a fixed threshold, keeping the alert rate roughly constant as baselines shift").
+		Advanced()
+}
+
+// adaptiveThresholds derives per-source thresholds from a rolling percentile
+// of recent scores.
+type adaptiveThresholds struct {
+	quantile     float64
+	history      int
+	minSamples   int
+	minThreshold float64
+
+	gauge *service.MetricGauge
+
+	mut    sync.Mutex
+	scores map[string]*scoreHistory
+}
+
+// scoreHistory is a ring buffer of the recent scores of a source.
+type scoreHistory struct {
+	scores  []float64
+	next    int
+	updated time.Time
+}
+
+func newAdaptiveThresholdsFromConfig(conf *service.ParsedConfig, metrics *service.Metrics, labelKeys []string, p profile) (*adaptiveThresholds, error) {
+	percentile, err := conf.FieldFloat("percentile")
+	if err != nil {
+		return nil, err
+	}
+	if percentile == 0 {
+		return nil, nil
+	}
+	if percentile < 0 || percentile >= 100 {
+		return nil, errors.New("adaptive_threshold percentile must be between 0 and 100")
+	}
+
+	history, err := fieldIntOr(conf, p.scoreHistory, "history")
+	if err != nil {
+		return nil, err
+	}
+	minSamples, err := conf.FieldInt("min_samples")
+	if err != nil {
+		return nil, err
+	}
+	if minSamples <= 0 || minSamples > history {
+		return nil, errors.New("adaptive_threshold min_samples must be positive and no more than history")
+	}
+
+	minThreshold, err := conf.FieldFloat("min_threshold")
+	if err != nil {
+		return nil, err
+	}
+
+	return &adaptiveThresholds{
+		quantile:     percentile / 100,
+		history:      history,
+		minSamples:   minSamples,
+		minThreshold: minThreshold,
+		gauge:        metrics.NewGauge("adaptive_threshold_milli", labelKeys...),
+		scores:       make(map[string]*scoreHistory),
+	}, nil
+}
+
+// threshold returns the adaptive threshold of a window key, or false while
+// the key has too few scores for one.
+func (a *adaptiveThresholds) threshold(key string, labels []string) (float64, bool) {
+	if a == nil {
+		return 0, false
+	}
+
+	a.mut.Lock()
+	h, exists := a.scores[key]
+	if !exists || len(h.scores) < a.minSamples {
+		a.mut.Unlock()
+		return 0, false
+	}
+	sorted := append([]float64(nil), h.scores...)
+	a.mut.Unlock()
+
+	sort.Float64s(sorted)
+	threshold := math.Max(stat.Quantile(a.quantile, stat.Empirical, sorted, nil), a.minThreshold)
+	a.gauge.Set(int64(math.Round(threshold*1000)), labels...)
+	return threshold, true
+}
+
+// observe records the score of a window.
+func (a *adaptiveThresholds) observe(key string, score float64) {
+	if a == nil {
+		return
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	h, exists := a.scores[key]
+	if !exists {
+		h = &scoreHistory{}
+		a.scores[key] = h
+	}
+	h.updated = time.Now()
+	if len(h.scores) < a.history {
+		h.scores = append(h.scores, score)
+		return
+	}
+	h.scores[h.next] = score
+	h.next = (h.next + 1) % a.history
+}
+
+// snapshot returns the score histories for a state snapshot, oldest score
+// first.
+func (a *adaptiveThresholds) snapshot() map[string][]float64 {
+	if a == nil {
+		return nil
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	histories := make(map[string][]float64, len(a.scores))
+	for key, h := range a.scores {
+		scores := append([]float64(nil), h.scores[h.next:]...)
+		histories[key] = append(scores, h.scores[:h.next]...)
+	}
+	return histories
+}
+
+// restore loads the score histories of a state snapshot, keeping the most
+// recent scores should the history have shrunk.
+func (a *adaptiveThresholds) restore(histories map[string][]float64) {
+	if a == nil {
+		return
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	now := time.Now()
+	for key, scores := range histories {
+		if len(scores) > a.history {
+			scores = scores[len(scores)-a.history:]
+		}
+		a.scores[key] = &scoreHistory{scores: append([]float64(nil), scores...), updated: now}
+	}
+}
+
+// expire removes the score histories of window keys not scored since cutoff.
+func (a *adaptiveThresholds) expire(cutoff time.Time) int {
+	if a == nil {
+		return 0
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	n := 0
+	for key, h := range a.scores {
+		if h.updated.Before(cutoff) {
+			delete(a.scores, key)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAdaptiveThresholds(t *testing.T, yaml string) *adaptiveThresholds {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(adaptiveThresholdField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	a, err := newAdaptiveThresholdsFromConfig(conf.Namespace("adaptive_threshold"), nil, []string{"log_source"}, profiles[profileNone])
+	require.NoError(t, err)
+	return a
+}
+
+func TestAdaptiveThresholdsPercentile(t *testing.T) {
+	a := newTestAdaptiveThresholds(t, `adaptive_threshold: { percentile: 90, history: 10, min_samples: 5 }`)
+	require.NotNil(t, a)
+
+	labels := []string{"fortinet.firewall"}
+	for i := 0; i < 4; i++ {
+		a.observe("fortinet.firewall", 0.1)
+	}
+	_, ok := a.threshold("fortinet.firewall", labels)
+	assert.False(t, ok, "fixed threshold applies until min_samples")
+
+	for i := 1; i <= 6; i++ {
+		a.observe("fortinet.firewall", float64(i)/10)
+	}
+	threshold, ok := a.threshold("fortinet.firewall", labels)
+	require.True(t, ok)
+	assert.Equal(t, 0.5, threshold)
+
+	// Old scores roll out of the history as baselines shift
+	for i := 0; i < 10; i++ {
+		a.observe("fortinet.firewall", 0.9)
+	}
+	threshold, _ = a.threshold("fortinet.firewall", labels)
+	assert.Equal(t, 0.9, threshold)
+
+	_, ok = a.threshold("paloalto.firewall", labels)
+	assert.False(t, ok)
+}
+
+func TestAdaptiveThresholdsFloor(t *testing.T) {
+	a := newTestAdaptiveThresholds(t, `adaptive_threshold: { percentile: 99, history: 10, min_samples: 1, min_threshold: 0.3 }`)
+
+	a.observe("fortinet.firewall", 0)
+	threshold, ok := a.threshold("fortinet.firewall", nil)
+	require.True(t, ok)
+	assert.Equal(t, 0.3, threshold)
+}
+
+func TestAdaptiveThresholdsConfig(t *testing.T) {
+	assert.Nil(t, newTestAdaptiveThresholds(t, `adaptive_threshold: {}`))
+
+	spec := service.NewConfigSpec().Field(adaptiveThresholdField())
+	for _, yaml := range []string{
+		`adaptive_threshold: { percentile: 100 }`,
+		`adaptive_threshold: { percentile: 99, history: 10, min_samples: 20 }`,
+	} {
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		_, err = newAdaptiveThresholdsFromConfig(conf.Namespace("adaptive_threshold"), nil, nil, profiles[profileNone])
+		assert.Error(t, err, yaml)
+	}
+}
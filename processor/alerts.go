@@ -0,0 +1,390 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func alertsField() *service.ConfigField {
+	return service.NewObjectField("alerts",
+		service.NewDurationField("cooldown").
+			Description("After an alert fires for a log source and detection type, suppress repeats for this long. The next alert after the cooldown carries a `suppressed_count`, or if none follows, the latest suppressed alert is sent with it and `cooldown_ended` once the cooldown ends or the detector shuts down. Zero disables deduplication.").
+			Default("0s"),
+		service.NewIntField("queue_size").
+			Description("Number of alerts held for delivery by a background worker, so that slow channels do not delay scoring. Alerts arriving while the queue is full are dropped and counted by `alerts_dropped`. Zero sends alerts inline.").
+			Default(1000),
+		webhookAlertField(),
+		slackAlertField(),
+		emailAlertField(),
+		opsgenieAlertField(),
+		teamsAlertField(),
+		snmpAlertField(),
+		syslogAlertField(),
+		pagerDutyAlertField(),
+		theHiveAlertField(),
+		escalationPoliciesField(),
+	).
+		Description("Alert channels notified for every detected anomaly, independently of the Kafka routing").
+		Advanced()
+}
+
+// alertTopFeatures is the number of features summarised in human readable
+// alert messages.
+const alertTopFeatures = 5
+
+// alertSink is a single notification channel that anomaly results are
+// delivered to.
+type alertSink interface {
+	Name() string
+	Send(ctx context.Context, msg *service.Message) error
+}
+
+// alertCloser is implemented by sinks that hold background resources, such as
+// buffered digests, which must be released on shutdown.
+type alertCloser interface {
+	Close(ctx context.Context) error
+}
+
+// alertFilter is implemented by sinks that are only notified of some
+// anomalies, such as those severe enough to open a case.
+type alertFilter interface {
+	accepts(result map[string]interface{}) bool
+}
+
+// alertDispatcher fans anomaly results out to every configured sink. A failing
+// sink never blocks delivery to the others.
+type alertDispatcher struct {
+	logger    *service.Logger
+	sinks     []alertSink
+	cooldown  *alertCooldown
+	policies  []*escalationPolicy
+	escalator *alertEscalator
+	queue     *alertQueue
+
+	alertsSent       *service.MetricCounter
+	alertsFailed     *service.MetricCounter
+	alertsSuppressed *service.MetricCounter
+	alertsEscalated  *service.MetricCounter
+	alertsDropped    *service.MetricCounter
+}
+
+func newAlertDispatcherFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*alertDispatcher, error) {
+	var sinks []alertSink
+
+	cooldown, err := conf.FieldDuration("cooldown")
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := newWebhookAlertFromConfig(conf.Namespace("webhook"))
+	if err != nil {
+		return nil, err
+	}
+	if webhook != nil {
+		sinks = append(sinks, webhook)
+	}
+
+	slack, err := newSlackAlertFromConfig(conf.Namespace("slack"))
+	if err != nil {
+		return nil, err
+	}
+	if slack != nil {
+		sinks = append(sinks, slack)
+	}
+
+	email, err := newEmailAlertFromConfig(conf.Namespace("email"), mgr.Logger())
+	if err != nil {
+		return nil, err
+	}
+	if email != nil {
+		sinks = append(sinks, email)
+	}
+
+	opsgenie, err := newOpsgenieAlertFromConfig(conf.Namespace("opsgenie"))
+	if err != nil {
+		return nil, err
+	}
+	if opsgenie != nil {
+		sinks = append(sinks, opsgenie)
+	}
+
+	teams, err := newTeamsAlertFromConfig(conf.Namespace("teams"))
+	if err != nil {
+		return nil, err
+	}
+	if teams != nil {
+		sinks = append(sinks, teams)
+	}
+
+	snmp, err := newSNMPAlertFromConfig(conf.Namespace("snmp"))
+	if err != nil {
+		return nil, err
+	}
+	if snmp != nil {
+		sinks = append(sinks, snmp)
+	}
+
+	syslog, err := newSyslogAlertFromConfig(conf.Namespace("syslog"))
+	if err != nil {
+		return nil, err
+	}
+	if syslog != nil {
+		sinks = append(sinks, syslog)
+	}
+
+	pagerDuty, err := newPagerDutyAlertFromConfig(conf.Namespace("pagerduty"))
+	if err != nil {
+		return nil, err
+	}
+	if pagerDuty != nil {
+		sinks = append(sinks, pagerDuty)
+	}
+
+	theHive, err := newTheHiveAlertFromConfig(conf.Namespace("thehive"), mgr.Logger())
+	if err != nil {
+		return nil, err
+	}
+	if theHive != nil {
+		sinks = append(sinks, theHive)
+	}
+
+	queue, err := newAlertQueueFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	policyConfs, err := conf.FieldObjectList("escalation")
+	if err != nil {
+		return nil, err
+	}
+	policies, err := newEscalationPoliciesFromConfig(policyConfs, sinks)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &alertDispatcher{
+		logger:           mgr.Logger(),
+		sinks:            sinks,
+		cooldown:         newAlertCooldown(cooldown),
+		policies:         policies,
+		queue:            queue,
+		alertsSent:       mgr.Metrics().NewCounter("alerts_sent", "channel"),
+		alertsFailed:     mgr.Metrics().NewCounter("alerts_failed", "channel"),
+		alertsSuppressed: mgr.Metrics().NewCounter("alerts_suppressed"),
+		alertsEscalated:  mgr.Metrics().NewCounter("alerts_escalated", "policy"),
+		alertsDropped:    mgr.Metrics().NewCounter("alerts_dropped"),
+	}
+
+	if a.queue != nil {
+		go a.deliveryLoop()
+	}
+	if a.cooldown != nil {
+		a.cooldown.shutdown = make(chan struct{})
+		a.cooldown.done = make(chan struct{})
+		go a.cooldownLoop(time.Second)
+	}
+	for _, policy := range policies {
+		if policy.escalateAfter > 0 {
+			a.escalator = newAlertEscalator()
+			a.escalator.shutdown = make(chan struct{})
+			a.escalator.done = make(chan struct{})
+			go a.escalationLoop(time.Second)
+			break
+		}
+	}
+	return a, nil
+}
+
+func (a *alertDispatcher) Dispatch(ctx context.Context, msg *service.Message) {
+	if a == nil || len(a.sinks) == 0 {
+		return
+	}
+
+	result, err := alertResult(msg)
+	if err != nil {
+		a.logger.Errorf("Failed to read anomaly result for alerting: %v", err)
+		return
+	}
+
+	ok, suppressed := a.cooldown.admit(result)
+	if !ok {
+		a.alertsSuppressed.Incr(1)
+		return
+	}
+	if suppressed > 0 {
+		annotated := make(map[string]interface{}, len(result)+1)
+		for k, v := range result {
+			annotated[k] = v
+		}
+		annotated["suppressed_count"] = suppressed
+
+		msg = msg.Copy()
+		msg.SetStructured(annotated)
+	}
+
+	sinks, policy := a.route(result)
+	a.deliver(ctx, msg, sinks)
+
+	if policy != nil && policy.escalateAfter > 0 && a.escalator != nil {
+		a.escalator.track(alertID(result), msg, policy)
+	}
+}
+
+func (a *alertDispatcher) sendTo(ctx context.Context, msg *service.Message, sinks []alertSink) {
+	for _, sink := range sinks {
+		if filter, ok := sink.(alertFilter); ok {
+			if result, err := alertResult(msg); err == nil && !filter.accepts(result) {
+				continue
+			}
+		}
+		if err := sink.Send(ctx, msg); err != nil {
+			a.logger.Errorf("Failed to send %s alert: %v", sink.Name(), err)
+			a.alertsFailed.Incr(1, sink.Name())
+			continue
+		}
+		a.alertsSent.Incr(1, sink.Name())
+	}
+}
+
+func (a *alertDispatcher) Close(ctx context.Context) error {
+	if a == nil {
+		return nil
+	}
+	if a.escalator != nil && a.escalator.shutdown != nil {
+		close(a.escalator.shutdown)
+		<-a.escalator.done
+	}
+	if a.cooldown != nil && a.cooldown.shutdown != nil {
+		close(a.cooldown.shutdown)
+		<-a.cooldown.done
+		// Alerts suppressed by cooldowns cut short are still reported
+		a.reportCooldowns(ctx, true)
+	}
+	if a.queue != nil {
+		close(a.queue.shutdown)
+		<-a.queue.done
+	}
+	for _, sink := range a.sinks {
+		closer, ok := sink.(alertCloser)
+		if !ok {
+			continue
+		}
+		if err := closer.Close(ctx); err != nil {
+			a.logger.Errorf("Failed to close %s alert channel: %v", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+// alertResult returns the structured anomaly result carried by msg.
+func alertResult(msg *service.Message) (map[string]interface{}, error) {
+	structured, err := msg.AsStructured()
+	if err != nil {
+		return nil, err
+	}
+	result, ok := structured.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected anomaly result object, got %T", structured)
+	}
+	return result, nil
+}
+
+// topFeatures returns up to n feature names of the result ordered by their
+// absolute value, largest first.
+func topFeatures(result map[string]interface{}, n int) []string {
+	features := resultFeatures(result)
+
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		vi, vj := math.Abs(features[names[i]]), math.Abs(features[names[j]])
+		if vi != vj {
+			return vi > vj
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+// resultFeatures returns the feature map of a result, whether it is still in
+// its native form or has been round-tripped through JSON.
+func resultFeatures(result map[string]interface{}) map[string]float64 {
+	switch features := result["features"].(type) {
+	case map[string]float64:
+		return features
+	case map[string]interface{}:
+		converted := make(map[string]float64, len(features))
+		for name, v := range features {
+			if f, ok := v.(float64); ok {
+				converted[name] = f
+			}
+		}
+		return converted
+	}
+	return nil
+}
+
+// resultTopIPs returns the top offending IPs of a result, whether it is still
+// in its native form or has been round-tripped through JSON.
+func resultTopIPs(result map[string]interface{}) []IPCount {
+	switch ips := result["top_ips"].(type) {
+	case []IPCount:
+		return ips
+	case []interface{}:
+		converted := make([]IPCount, 0, len(ips))
+		for _, v := range ips {
+			entry, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ip, _ := entry["ip"].(string)
+			count, _ := entry["count"].(float64)
+			converted = append(converted, IPCount{IP: ip, Count: int(count)})
+		}
+		return converted
+	}
+	return nil
+}
+
+// postAlertJSON POSTs payload as JSON to url and fails on non-2xx responses.
+func postAlertJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned status %d: %s", url, resp.StatusCode, respBody)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// alertCooldown suppresses repeated alerts for the same log source and
+// detection type until a cooldown period has elapsed since the last alert
+// that was let through.
+type alertCooldown struct {
+	period time.Duration
+	now    func() time.Time
+
+	mut    sync.Mutex
+	states map[alertCooldownKey]*alertCooldownState
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+type alertCooldownKey struct {
+	tenant        string
+	logSource     string
+	detectionType string
+}
+
+type alertCooldownState struct {
+	lastSent   time.Time
+	suppressed int
+
+	// last is the latest suppressed result, reported with the number of
+	// suppressed alerts if no alert follows the cooldown
+	last map[string]interface{}
+}
+
+func newAlertCooldown(period time.Duration) *alertCooldown {
+	if period <= 0 {
+		return nil
+	}
+	return &alertCooldown{
+		period: period,
+		now:    time.Now,
+		states: make(map[alertCooldownKey]*alertCooldownState),
+	}
+}
+
+// admit reports whether an alert for the result may be sent. When it may, the
+// number of alerts suppressed during the previous cooldown is also returned.
+func (c *alertCooldown) admit(result map[string]interface{}) (ok bool, suppressed int) {
+	if c == nil {
+		return true, 0
+	}
+
+	tenant, _ := result["tenant"].(string)
+	source, _ := result["log_source"].(string)
+	reason, _ := result["reason"].(string)
+	key := alertCooldownKey{tenant: tenant, logSource: source, detectionType: reason}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := c.now()
+	state, exists := c.states[key]
+	if !exists {
+		c.states[key] = &alertCooldownState{lastSent: now}
+		return true, 0
+	}
+
+	if now.Sub(state.lastSent) < c.period {
+		state.suppressed++
+		state.last = result
+		return false, 0
+	}
+
+	suppressed = state.suppressed
+	state.lastSent = now
+	state.suppressed = 0
+	state.last = nil
+	return true, suppressed
+}
+
+// ended returns a summary of every cooldown that elapsed with alerts
+// suppressed and no alert since: its latest suppressed result carrying the
+// suppressed_count. With all, cooldowns still running are ended too, e.g.
+// on shutdown.
+func (c *alertCooldown) ended(all bool) []map[string]interface{} {
+	if c == nil {
+		return nil
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := c.now()
+	var summaries []map[string]interface{}
+	for _, state := range c.states {
+		if state.suppressed == 0 || (!all && now.Sub(state.lastSent) < c.period) {
+			continue
+		}
+		summary := make(map[string]interface{}, len(state.last)+2)
+		for k, v := range state.last {
+			summary[k] = v
+		}
+		summary["suppressed_count"] = state.suppressed
+		summary["cooldown_ended"] = true
+		summaries = append(summaries, summary)
+
+		state.suppressed = 0
+		state.last = nil
+	}
+	return summaries
+}
+
+// expire removes the cooldowns that elapsed and whose last alert was sent
+// before cutoff. Cooldowns with suppressed alerts are kept until their
+// summary is sent.
+func (c *alertCooldown) expire(cutoff time.Time) int {
+	if c == nil {
+		return 0
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := c.now()
+	n := 0
+	for key, state := range c.states {
+		if state.suppressed == 0 && state.lastSent.Before(cutoff) && now.Sub(state.lastSent) >= c.period {
+			delete(c.states, key)
+			n++
+		}
+	}
+	return n
+}
+
+// expireCooldowns removes the alert cooldowns that elapsed before cutoff.
+func (a *alertDispatcher) expireCooldowns(cutoff time.Time) int {
+	if a == nil {
+		return 0
+	}
+	return a.cooldown.expire(cutoff)
+}
+
+func (a *alertDispatcher) cooldownLoop(interval time.Duration) {
+	defer close(a.cooldown.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.reportCooldowns(context.Background(), false)
+		case <-a.cooldown.shutdown:
+			return
+		}
+	}
+}
+
+// reportCooldowns sends the summary of every cooldown that ended with
+// alerts suppressed, so that the count is reported even when the storm stops.
+// With all, running cooldowns are reported as well.
+func (a *alertDispatcher) reportCooldowns(ctx context.Context, all bool) {
+	for _, summary := range a.cooldown.ended(all) {
+		a.logger.Infof("Alert cooldown of %v ended with %v alerts suppressed", summary["log_source"], summary["suppressed_count"])
+
+		msg := service.NewMessage(nil)
+		msg.SetStructured(summary)
+		sinks, _ := a.route(summary)
+		a.sendTo(ctx, msg, sinks)
+	}
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mut     sync.Mutex
+	results []map[string]interface{}
+}
+
+func (r *recordingSink) Name() string {
+	return "recording"
+}
+
+func (r *recordingSink) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+	r.mut.Lock()
+	r.results = append(r.results, result)
+	r.mut.Unlock()
+	return nil
+}
+
+func newTestDispatcher(sinks ...alertSink) *alertDispatcher {
+	mgr := service.MockResources()
+	return &alertDispatcher{
+		logger:           mgr.Logger(),
+		sinks:            sinks,
+		alertsSent:       mgr.Metrics().NewCounter("alerts_sent", "channel"),
+		alertsFailed:     mgr.Metrics().NewCounter("alerts_failed", "channel"),
+		alertsSuppressed: mgr.Metrics().NewCounter("alerts_suppressed"),
+	}
+}
+
+func TestAlertCooldownSuppressesRepeats(t *testing.T) {
+	sink := &recordingSink{}
+	dispatcher := newTestDispatcher(sink)
+	dispatcher.cooldown = newAlertCooldown(10 * time.Minute)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dispatcher.cooldown.now = func() time.Time { return now }
+
+	send := func(source, reason string) {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": source, "reason": reason})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+
+	send("fortinet.firewall", "hike_rate_detected")
+	send("fortinet.firewall", "hike_rate_detected")
+	send("fortinet.firewall", "hike_rate_detected")
+	send("paloalto.firewall", "hike_rate_detected")
+	send("fortinet.firewall", "source_silent")
+	require.Len(t, sink.results, 3)
+
+	now = now.Add(11 * time.Minute)
+	send("fortinet.firewall", "hike_rate_detected")
+	require.Len(t, sink.results, 4)
+	assert.Equal(t, 2, sink.results[3]["suppressed_count"])
+
+	send("fortinet.firewall", "hike_rate_detected")
+	require.Len(t, sink.results, 4)
+}
+
+func TestAlertCooldownReportsEndedStorms(t *testing.T) {
+	sink := &recordingSink{}
+	dispatcher := newTestDispatcher(sink)
+	dispatcher.cooldown = newAlertCooldown(10 * time.Minute)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dispatcher.cooldown.now = func() time.Time { return now }
+
+	for _, score := range []float64{0.8, 0.81, 0.82, 0.83, 0.84} {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall", "reason": "hike_rate_detected", "anomaly_score": score})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+	require.Len(t, sink.results, 1)
+
+	// Nothing is reported while the cooldown runs
+	dispatcher.reportCooldowns(context.Background(), false)
+	require.Len(t, sink.results, 1)
+
+	// Once the storm stopped and the cooldown ended, the suppressed alerts
+	// are reported, even if the state is due for collection
+	now = now.Add(11 * time.Minute)
+	assert.Equal(t, 0, dispatcher.expireCooldowns(now))
+	dispatcher.reportCooldowns(context.Background(), false)
+	require.Len(t, sink.results, 2)
+	assert.Equal(t, 4, sink.results[1]["suppressed_count"])
+	assert.Equal(t, true, sink.results[1]["cooldown_ended"])
+	assert.InDelta(t, 0.84, sink.results[1]["anomaly_score"], 1e-9)
+
+	dispatcher.reportCooldowns(context.Background(), false)
+	assert.Len(t, sink.results, 2)
+	assert.Equal(t, 1, dispatcher.expireCooldowns(now))
+}
+
+func TestAlertCooldownReportedOnClose(t *testing.T) {
+	sink := &recordingSink{}
+	dispatcher := newTestDispatcher(sink)
+	dispatcher.cooldown = newAlertCooldown(10 * time.Minute)
+	dispatcher.cooldown.shutdown = make(chan struct{})
+	dispatcher.cooldown.done = make(chan struct{})
+	go dispatcher.cooldownLoop(time.Hour)
+
+	for _, score := range []float64{0.8, 0.81, 0.82} {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall", "reason": "hike_rate_detected", "anomaly_score": score})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+
+	// Shutting down mid-cooldown still reports the suppressed alerts
+	require.NoError(t, dispatcher.Close(context.Background()))
+	require.Len(t, sink.results, 2)
+	assert.Equal(t, 2, sink.results[1]["suppressed_count"])
+	assert.Equal(t, true, sink.results[1]["cooldown_ended"])
+}
+
+func TestAlertCooldownDisabled(t *testing.T) {
+	assert.Nil(t, newAlertCooldown(0))
+
+	ok, suppressed := (*alertCooldown)(nil).admit(map[string]interface{}{})
+	assert.True(t, ok)
+	assert.Equal(t, 0, suppressed)
+}
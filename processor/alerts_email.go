@@ -0,0 +1,295 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	emailModeImmediate = "immediate"
+	emailModeDigest    = "digest"
+)
+
+func emailAlertField() *service.ConfigField {
+	return service.NewObjectField("email",
+		service.NewStringField("host").
+			Description("SMTP server host. Leave empty to disable email alerts.").
+			Default(""),
+		service.NewIntField("port").
+			Description("SMTP server port").
+			Default(587),
+		service.NewStringField("username").
+			Description("SMTP username. Authentication is skipped when empty.").
+			Default(""),
+		service.NewStringField("password").
+			Description("SMTP password").
+			Secret().
+			Default(""),
+		service.NewStringField("from").
+			Description("Sender address").
+			Default(""),
+		service.NewStringListField("to").
+			Description("Recipient addresses").
+			Default([]string{}),
+		service.NewStringField("subject_prefix").
+			Description("Prefix prepended to every email subject").
+			Default("[firewall-anomaly]"),
+		service.NewStringField("template").
+			Description(alertTemplateDescription+" Renders the body of immediate emails and each entry of a digest.").
+			Default(""),
+		service.NewStringEnumField("mode", emailModeImmediate, emailModeDigest).
+			Description("Send one email per anomaly, or batch anomalies into a periodic digest").
+			Default(emailModeImmediate),
+		service.NewDurationField("digest_interval").
+			Description("How often a digest email is sent when `mode` is `digest`").
+			Default("15m"),
+	).Description("SMTP email alert channel")
+}
+
+type sendMailFunc func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+type emailAlert struct {
+	logger   *service.Logger
+	addr     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	prefix   string
+	mode     string
+	template *alertTemplate
+	sendMail sendMailFunc
+
+	pendingMut sync.Mutex
+	pending    []map[string]interface{}
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newEmailAlertFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*emailAlert, error) {
+	host, err := conf.FieldString("host")
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		return nil, nil
+	}
+
+	port, err := conf.FieldInt("port")
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := conf.FieldString("username")
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := conf.FieldString("password")
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := conf.FieldString("from")
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := conf.FieldStringList("to")
+	if err != nil {
+		return nil, err
+	}
+	if from == "" || len(to) == 0 {
+		return nil, fmt.Errorf("email alerts require both a from address and at least one recipient")
+	}
+
+	prefix, err := conf.FieldString("subject_prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	templateStr, err := conf.FieldString("template")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newAlertTemplate("email", templateStr)
+	if err != nil {
+		return nil, err
+	}
+
+	mode, err := conf.FieldString("mode")
+	if err != nil {
+		return nil, err
+	}
+
+	digestInterval, err := conf.FieldDuration("digest_interval")
+	if err != nil {
+		return nil, err
+	}
+	if digestInterval <= 0 {
+		return nil, fmt.Errorf("alerts.email.digest_interval must be positive, got %v", digestInterval)
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	e := &emailAlert{
+		logger:   logger,
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		auth:     auth,
+		from:     from,
+		to:       to,
+		prefix:   prefix,
+		mode:     mode,
+		template: tmpl,
+		sendMail: smtp.SendMail,
+	}
+
+	if mode == emailModeDigest {
+		e.shutdown = make(chan struct{})
+		e.done = make(chan struct{})
+		go e.digestLoop(digestInterval)
+	}
+	return e, nil
+}
+
+func (e *emailAlert) Name() string {
+	return "email"
+}
+
+func (e *emailAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	if e.mode == emailModeDigest {
+		e.pendingMut.Lock()
+		e.pending = append(e.pending, result)
+		e.pendingMut.Unlock()
+		return nil
+	}
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+	subject := fmt.Sprintf("%s Anomaly on %s (score %.2f)", e.prefix, source, score)
+	body, err := e.summary(result)
+	if err != nil {
+		return err
+	}
+	return e.send(subject, body)
+}
+
+func (e *emailAlert) summary(result map[string]interface{}) (string, error) {
+	if e.template == nil {
+		return emailResultSummary(result), nil
+	}
+	body, err := e.template.Render(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to render email template: %w", err)
+	}
+	return body, nil
+}
+
+func (e *emailAlert) digestLoop(interval time.Duration) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.flushDigest(); err != nil {
+				e.logger.Errorf("Failed to send anomaly digest email: %v", err)
+			}
+		case <-e.shutdown:
+			return
+		}
+	}
+}
+
+// flushDigest sends every pending anomaly as a single summary email.
+func (e *emailAlert) flushDigest() error {
+	e.pendingMut.Lock()
+	pending := e.pending
+	e.pending = nil
+	e.pendingMut.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "%d anomalies detected since the last digest.\n", len(pending))
+	for _, result := range pending {
+		summary, err := e.summary(result)
+		if err != nil {
+			return err
+		}
+		body.WriteString("\n")
+		body.WriteString(summary)
+	}
+
+	subject := fmt.Sprintf("%s Digest: %d anomalies", e.prefix, len(pending))
+	return e.send(subject, body.String())
+}
+
+func (e *emailAlert) send(subject, body string) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", e.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(e.to, ", "))
+	// The subject carries the log source, which must not end the header
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	return e.sendMail(e.addr, e.auth, e.from, e.to, msg.Bytes())
+}
+
+// Close flushes any pending digest before stopping the digest loop.
+func (e *emailAlert) Close(ctx context.Context) error {
+	if e.shutdown == nil {
+		return nil
+	}
+	close(e.shutdown)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return e.flushDigest()
+}
+
+func emailResultSummary(result map[string]interface{}) string {
+	var b strings.Builder
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+	fmt.Fprintf(&b, "Source: %s\n", source)
+	fmt.Fprintf(&b, "Score: %.2f\n", score)
+	fmt.Fprintf(&b, "Reason: %v\n", result["reason"])
+	fmt.Fprintf(&b, "Window: %v - %v\n", result["window_start"], result["window_end"])
+
+	features := resultFeatures(result)
+	for _, name := range topFeatures(result, alertTopFeatures) {
+		fmt.Fprintf(&b, "  %s: %.2f\n", name, features[name])
+	}
+	for _, ip := range resultTopIPs(result) {
+		fmt.Fprintf(&b, "  ip %s: %d logs\n", ip.IP, ip.Count)
+	}
+	return b.String()
+}
@@ -0,0 +1,133 @@
+package processor
+
+import (
+	"context"
+	"mime"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMail struct {
+	addr string
+	to   []string
+	msg  string
+}
+
+func parseEmailAlert(t *testing.T, yaml string, sent *[]sentMail) *emailAlert {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(emailAlertField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	email, err := newEmailAlertFromConfig(conf.Namespace("email"), service.MockResources().Logger())
+	require.NoError(t, err)
+	require.NotNil(t, email)
+
+	email.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		*sent = append(*sent, sentMail{addr: addr, to: to, msg: string(msg)})
+		return nil
+	}
+	return email
+}
+
+func TestEmailAlertImmediate(t *testing.T) {
+	var sent []sentMail
+	email := parseEmailAlert(t, `
+email:
+  host: smtp.example.com
+  port: 25
+  from: detector@example.com
+  to: [ soc@example.com ]
+`, &sent)
+
+	require.NoError(t, email.Send(context.Background(), testAnomalyMessage()))
+	require.Len(t, sent, 1)
+	assert.Equal(t, "smtp.example.com:25", sent[0].addr)
+	assert.Equal(t, []string{"soc@example.com"}, sent[0].to)
+	assert.Contains(t, sent[0].msg, "Subject: [firewall-anomaly] Anomaly on fortinet.firewall (score 0.90)\r\n")
+	assert.Contains(t, sent[0].msg, "ip 192.168.1.1: 12 logs")
+}
+
+func TestEmailAlertDigest(t *testing.T) {
+	var sent []sentMail
+	email := parseEmailAlert(t, `
+email:
+  host: smtp.example.com
+  from: detector@example.com
+  to: [ soc@example.com ]
+  mode: digest
+  digest_interval: 1h
+`, &sent)
+
+	require.NoError(t, email.Send(context.Background(), testAnomalyMessage()))
+	require.NoError(t, email.Send(context.Background(), testAnomalyMessage()))
+	assert.Empty(t, sent)
+
+	require.NoError(t, email.Close(context.Background()))
+	require.Len(t, sent, 1)
+	assert.Contains(t, sent[0].msg, "Subject: [firewall-anomaly] Digest: 2 anomalies\r\n")
+	assert.Equal(t, 2, strings.Count(sent[0].msg, "Source: fortinet.firewall"))
+}
+
+func TestEmailAlertRequiresRecipients(t *testing.T) {
+	spec := service.NewConfigSpec().Field(emailAlertField())
+	conf, err := spec.ParseYAML(`
+email:
+  host: smtp.example.com
+  from: detector@example.com
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newEmailAlertFromConfig(conf.Namespace("email"), service.MockResources().Logger())
+	assert.Error(t, err)
+}
+
+func TestEmailAlertRejectsNonPositiveDigestInterval(t *testing.T) {
+	conf, err := service.NewConfigSpec().Field(emailAlertField()).ParseYAML(`
+email:
+  host: smtp.example.com
+  from: detector@example.com
+  to: [ soc@example.com ]
+  mode: digest
+  digest_interval: 0s
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newEmailAlertFromConfig(conf.Namespace("email"), service.MockResources().Logger())
+	assert.ErrorContains(t, err, "alerts.email.digest_interval must be positive")
+}
+
+func TestEmailAlertEncodesSubject(t *testing.T) {
+	var sent []sentMail
+	email := parseEmailAlert(t, `
+email:
+  host: smtp.example.com
+  from: detector@example.com
+  to: [ soc@example.com ]
+`, &sent)
+
+	msg := service.NewMessage(nil)
+	msg.SetStructured(map[string]interface{}{
+		"log_source":    "x\r\nBcc: evil@example.com",
+		"anomaly_score": 0.9,
+	})
+	require.NoError(t, email.Send(context.Background(), msg))
+	require.Len(t, sent, 1)
+
+	headers, _, found := strings.Cut(sent[0].msg, "\r\n\r\n")
+	require.True(t, found)
+	assert.NotContains(t, headers, "\r\nBcc:")
+	for _, line := range strings.Split(headers, "\r\n") {
+		if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+			decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+			require.NoError(t, err)
+			assert.Equal(t, "[firewall-anomaly] Anomaly on x\r\nBcc: evil@example.com (score 0.90)", decoded)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func escalationPoliciesField() *service.ConfigField {
+	return service.NewObjectListField("escalation",
+		service.NewStringField("name").
+			Description("Name of the severity band"),
+		service.NewFloatField("min_score").
+			Description("Inclusive lower bound of anomaly scores in this band").
+			Default(0.0),
+		service.NewFloatField("max_score").
+			Description("Exclusive upper bound of anomaly scores in this band").
+			Default(1.01),
+		service.NewStringListField("channels").
+			Description("Alert channels notified for anomalies in this band, e.g. `slack` or `pagerduty`"),
+		service.NewDurationField("escalate_after").
+			Description("When non-zero, alerts that are not acknowledged through the feedback API within this period are re-sent to `escalate_to`").
+			Default("0s"),
+		service.NewStringListField("escalate_to").
+			Description("Alert channels notified when an alert in this band is escalated").
+			Default([]string{}),
+	).
+		Description("Severity bands mapping anomaly scores to alert channels. The first matching band wins. When empty every anomaly is sent to every channel.").
+		Default([]interface{}{})
+}
+
+type escalationPolicy struct {
+	name          string
+	minScore      float64
+	maxScore      float64
+	channels      []alertSink
+	escalateAfter time.Duration
+	escalateTo    []alertSink
+}
+
+func newEscalationPoliciesFromConfig(confs []*service.ParsedConfig, sinks []alertSink) ([]*escalationPolicy, error) {
+	byName := make(map[string]alertSink, len(sinks))
+	for _, sink := range sinks {
+		byName[sink.Name()] = sink
+	}
+	lookup := func(policy string, names []string) ([]alertSink, error) {
+		resolved := make([]alertSink, 0, len(names))
+		for _, name := range names {
+			sink, exists := byName[name]
+			if !exists {
+				return nil, fmt.Errorf("escalation policy %s references channel %s which is not configured", policy, name)
+			}
+			resolved = append(resolved, sink)
+		}
+		return resolved, nil
+	}
+
+	policies := make([]*escalationPolicy, 0, len(confs))
+	for _, conf := range confs {
+		p := &escalationPolicy{}
+
+		var err error
+		if p.name, err = conf.FieldString("name"); err != nil {
+			return nil, err
+		}
+		if p.minScore, err = conf.FieldFloat("min_score"); err != nil {
+			return nil, err
+		}
+		if p.maxScore, err = conf.FieldFloat("max_score"); err != nil {
+			return nil, err
+		}
+		if p.maxScore <= p.minScore {
+			return nil, fmt.Errorf("escalation policy %s: max_score must be greater than min_score", p.name)
+		}
+		if p.escalateAfter, err = conf.FieldDuration("escalate_after"); err != nil {
+			return nil, err
+		}
+
+		channels, err := conf.FieldStringList("channels")
+		if err != nil {
+			return nil, err
+		}
+		if p.channels, err = lookup(p.name, channels); err != nil {
+			return nil, err
+		}
+
+		escalateTo, err := conf.FieldStringList("escalate_to")
+		if err != nil {
+			return nil, err
+		}
+		if p.escalateTo, err = lookup(p.name, escalateTo); err != nil {
+			return nil, err
+		}
+		if p.escalateAfter > 0 && len(p.escalateTo) == 0 {
+			return nil, fmt.Errorf("escalation policy %s: escalate_after requires escalate_to channels", p.name)
+		}
+
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// pendingEscalation is an alert awaiting acknowledgement before it is
+// escalated to further channels.
+type pendingEscalation struct {
+	deadline time.Time
+	msg      *service.Message
+	policy   *escalationPolicy
+}
+
+// alertEscalator tracks unacknowledged alerts and re-sends them to the
+// escalation channels of their policy once their deadline has passed.
+type alertEscalator struct {
+	now func() time.Time
+
+	mut     sync.Mutex
+	pending map[string]*pendingEscalation
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newAlertEscalator() *alertEscalator {
+	return &alertEscalator{
+		now:     time.Now,
+		pending: make(map[string]*pendingEscalation),
+	}
+}
+
+// track holds a deep copy of msg for escalation, as the caller goes on to emit
+// and modify msg itself.
+func (e *alertEscalator) track(id string, msg *service.Message, policy *escalationPolicy) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	e.pending[id] = &pendingEscalation{
+		deadline: e.now().Add(policy.escalateAfter),
+		msg:      msg.DeepCopy(),
+		policy:   policy,
+	}
+}
+
+// acknowledge stops a pending alert from being escalated, returning whether
+// it was pending.
+func (e *alertEscalator) acknowledge(id string) bool {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	_, exists := e.pending[id]
+	delete(e.pending, id)
+	return exists
+}
+
+// due removes and returns every pending alert whose deadline has passed.
+func (e *alertEscalator) due() []*pendingEscalation {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	now := e.now()
+	var due []*pendingEscalation
+	for id, p := range e.pending {
+		if !now.Before(p.deadline) {
+			due = append(due, p)
+			delete(e.pending, id)
+		}
+	}
+	return due
+}
+
+// alertID identifies an alert for acknowledgement. Results carry a window_id
+// once deterministic IDs are assigned, otherwise the tenant, source and window
+// end are used.
+func alertID(result map[string]interface{}) string {
+	if id, ok := result["window_id"].(string); ok && id != "" {
+		return id
+	}
+	if tenant, ok := result["tenant"].(string); ok && tenant != "" {
+		return fmt.Sprintf("%s/%v/%v", tenant, result["log_source"], result["window_end"])
+	}
+	return fmt.Sprintf("%v/%v", result["log_source"], result["window_end"])
+}
+
+// route returns the channels an anomaly result is sent to, and the escalation
+// policy that matched it if any.
+func (a *alertDispatcher) route(result map[string]interface{}) ([]alertSink, *escalationPolicy) {
+	if len(a.policies) == 0 {
+		return a.sinks, nil
+	}
+
+	score, _ := result["anomaly_score"].(float64)
+	for _, policy := range a.policies {
+		if score >= policy.minScore && score < policy.maxScore {
+			return policy.channels, policy
+		}
+	}
+	return nil, nil
+}
+
+// Acknowledge marks an alert as handled so that it is not escalated.
+func (a *alertDispatcher) Acknowledge(id string) bool {
+	if a == nil || a.escalator == nil {
+		return false
+	}
+	return a.escalator.acknowledge(id)
+}
+
+func (a *alertDispatcher) escalationLoop(interval time.Duration) {
+	defer close(a.escalator.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			a.escalateDue(context.Background())
+		case <-a.escalator.shutdown:
+			return
+		}
+	}
+}
+
+func (a *alertDispatcher) escalateDue(ctx context.Context) {
+	for _, p := range a.escalator.due() {
+		a.logger.Warnf("Escalating unacknowledged %s alert", p.policy.name)
+		a.alertsEscalated.Incr(1, p.policy.name)
+
+		msg := p.msg.Copy()
+		if result, err := alertResult(msg); err == nil {
+			escalated := make(map[string]interface{}, len(result)+1)
+			for k, v := range result {
+				escalated[k] = v
+			}
+			escalated["escalated"] = true
+			msg.SetStructured(escalated)
+		}
+		a.sendTo(ctx, msg, p.policy.escalateTo)
+	}
+}
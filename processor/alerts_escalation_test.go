@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type namedRecordingSink struct {
+	recordingSink
+	name string
+}
+
+func (n *namedRecordingSink) Name() string {
+	return n.name
+}
+
+func parseEscalationPolicies(t *testing.T, yaml string, sinks []alertSink) ([]*escalationPolicy, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(escalationPoliciesField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	confs, err := conf.FieldObjectList("escalation")
+	require.NoError(t, err)
+	return newEscalationPoliciesFromConfig(confs, sinks)
+}
+
+func TestEscalationPoliciesRouteBySeverity(t *testing.T) {
+	slack := &namedRecordingSink{name: "slack"}
+	pagerDuty := &namedRecordingSink{name: "pagerduty"}
+	sinks := []alertSink{slack, pagerDuty}
+
+	policies, err := parseEscalationPolicies(t, `
+escalation:
+  - name: low
+    min_score: 0.7
+    max_score: 0.9
+    channels: [ slack ]
+    escalate_after: 15m
+    escalate_to: [ pagerduty ]
+  - name: high
+    min_score: 0.9
+    channels: [ slack, pagerduty ]
+`, sinks)
+	require.NoError(t, err)
+
+	dispatcher := newTestDispatcher(sinks...)
+	dispatcher.policies = policies
+	dispatcher.escalator = newAlertEscalator()
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dispatcher.escalator.now = func() time.Time { return now }
+
+	send := func(source string, score float64) {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": source, "anomaly_score": score, "window_end": now})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+
+	send("fortinet.firewall", 0.75)
+	send("paloalto.firewall", 0.8)
+	send("cisco.asa", 0.95)
+	assert.Len(t, slack.results, 3)
+	assert.Len(t, pagerDuty.results, 1)
+
+	// Acknowledged alerts are never escalated.
+	assert.True(t, dispatcher.Acknowledge(alertID(slack.results[1])))
+
+	now = now.Add(10 * time.Minute)
+	dispatcher.escalateDue(context.Background())
+	assert.Len(t, pagerDuty.results, 1)
+
+	now = now.Add(10 * time.Minute)
+	dispatcher.escalateDue(context.Background())
+	require.Len(t, pagerDuty.results, 2)
+	assert.Equal(t, "fortinet.firewall", pagerDuty.results[1]["log_source"])
+	assert.Equal(t, true, pagerDuty.results[1]["escalated"])
+
+	dispatcher.escalateDue(context.Background())
+	assert.Len(t, pagerDuty.results, 2)
+}
+
+func TestEscalationPoliciesUnknownChannel(t *testing.T) {
+	_, err := parseEscalationPolicies(t, `
+escalation:
+  - name: high
+    channels: [ pagerduty ]
+`, []alertSink{&namedRecordingSink{name: "slack"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func opsgenieAlertField() *service.ConfigField {
+	return service.NewObjectField("opsgenie",
+		service.NewStringField("api_key").
+			Description("Opsgenie API integration key. Leave empty to disable Opsgenie alerts.").
+			Secret().
+			Default(""),
+		service.NewStringField("api_url").
+			Description("Base URL of the Opsgenie API, use `https://api.eu.opsgenie.com` for EU accounts").
+			Default("https://api.opsgenie.com"),
+		service.NewStringEnumField("priority", "P1", "P2", "P3", "P4", "P5").
+			Description("Priority assigned to created alerts").
+			Default("P3"),
+		service.NewStringListField("tags").
+			Description("Tags attached to created alerts").
+			Default([]string{"firewall", "anomaly"}),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single Opsgenie request").
+			Default("5s"),
+	).Description("Opsgenie alerts API channel")
+}
+
+type opsgenieAlert struct {
+	client   *http.Client
+	apiKey   string
+	apiURL   string
+	priority string
+	tags     []string
+}
+
+func newOpsgenieAlertFromConfig(conf *service.ParsedConfig) (*opsgenieAlert, error) {
+	apiKey, err := conf.FieldString("api_key")
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == "" {
+		return nil, nil
+	}
+
+	apiURL, err := conf.FieldString("api_url")
+	if err != nil {
+		return nil, err
+	}
+
+	priority, err := conf.FieldString("priority")
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := conf.FieldStringList("tags")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &opsgenieAlert{
+		client:   &http.Client{Timeout: timeout},
+		apiKey:   apiKey,
+		apiURL:   strings.TrimSuffix(apiURL, "/"),
+		priority: priority,
+		tags:     tags,
+	}, nil
+}
+
+func (o *opsgenieAlert) Name() string {
+	return "opsgenie"
+}
+
+func (o *opsgenieAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+
+	details := map[string]string{
+		"log_source":    source,
+		"anomaly_score": fmt.Sprintf("%.4f", score),
+		"reason":        fmt.Sprintf("%v", result["reason"]),
+		"window_start":  fmt.Sprintf("%v", result["window_start"]),
+		"window_end":    fmt.Sprintf("%v", result["window_end"]),
+	}
+	features := resultFeatures(result)
+	for _, name := range topFeatures(result, alertTopFeatures) {
+		details[name] = fmt.Sprintf("%.4f", features[name])
+	}
+
+	payload := map[string]interface{}{
+		"message":     fmt.Sprintf("Firewall anomaly on %s (score %.2f)", source, score),
+		"alias":       "firewall-anomaly-" + source,
+		"description": emailResultSummary(result),
+		"source":      "firewall_anomaly_detector",
+		"priority":    o.priority,
+		"tags":        o.tags,
+		"details":     details,
+	}
+
+	return postAlertJSON(ctx, o.client, o.apiURL+"/v2/alerts", map[string]string{
+		"Authorization": "GenieKey " + o.apiKey,
+	}, payload)
+}
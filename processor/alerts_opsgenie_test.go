@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsgenieAlert(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/alerts", r.URL.Path)
+		assert.Equal(t, "GenieKey abc", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	spec := service.NewConfigSpec().Field(opsgenieAlertField())
+	conf, err := spec.ParseYAML(`
+opsgenie:
+  api_key: abc
+  api_url: `+server.URL+`
+  priority: P2
+`, nil)
+	require.NoError(t, err)
+
+	opsgenie, err := newOpsgenieAlertFromConfig(conf.Namespace("opsgenie"))
+	require.NoError(t, err)
+
+	require.NoError(t, opsgenie.Send(context.Background(), testAnomalyMessage()))
+	assert.Equal(t, "Firewall anomaly on fortinet.firewall (score 0.90)", payload["message"])
+	assert.Equal(t, "firewall-anomaly-fortinet.firewall", payload["alias"])
+	assert.Equal(t, "P2", payload["priority"])
+	assert.Equal(t, "250.0000", payload["details"].(map[string]interface{})["percent_change"])
+}
+
+func TestTeamsAlert(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+	}))
+	defer server.Close()
+
+	spec := service.NewConfigSpec().Field(teamsAlertField())
+	conf, err := spec.ParseYAML(`
+teams:
+  webhook_url: `+server.URL+`
+`, nil)
+	require.NoError(t, err)
+
+	teams, err := newTeamsAlertFromConfig(conf.Namespace("teams"))
+	require.NoError(t, err)
+
+	require.NoError(t, teams.Send(context.Background(), testAnomalyMessage()))
+	assert.Equal(t, "MessageCard", payload["@type"])
+
+	section := payload["sections"].([]interface{})[0].(map[string]interface{})
+	facts := section["facts"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"name": "Top IPs", "value": "192.168.1.1 (12)"}, facts[len(facts)-1])
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func pagerDutyAlertField() *service.ConfigField {
+	return service.NewObjectField("pagerduty",
+		service.NewStringField("routing_key").
+			Description("Events API v2 integration routing key. Leave empty to disable PagerDuty alerts.").
+			Secret().
+			Default(""),
+		service.NewStringField("events_url").
+			Description("PagerDuty Events API v2 endpoint").
+			Default("https://events.pagerduty.com/v2/enqueue").
+			Advanced(),
+		service.NewStringEnumField("severity", "critical", "error", "warning", "info").
+			Description("Severity of triggered incidents").
+			Default("error"),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single PagerDuty request").
+			Default("5s"),
+	).Description("PagerDuty Events API v2 channel")
+}
+
+type pagerDutyAlert struct {
+	client     *http.Client
+	routingKey string
+	eventsURL  string
+	severity   string
+}
+
+func newPagerDutyAlertFromConfig(conf *service.ParsedConfig) (*pagerDutyAlert, error) {
+	routingKey, err := conf.FieldString("routing_key")
+	if err != nil {
+		return nil, err
+	}
+	if routingKey == "" {
+		return nil, nil
+	}
+
+	eventsURL, err := conf.FieldString("events_url")
+	if err != nil {
+		return nil, err
+	}
+
+	severity, err := conf.FieldString("severity")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pagerDutyAlert{
+		client:     &http.Client{Timeout: timeout},
+		routingKey: routingKey,
+		eventsURL:  eventsURL,
+		severity:   severity,
+	}, nil
+}
+
+func (p *pagerDutyAlert) Name() string {
+	return "pagerduty"
+}
+
+func (p *pagerDutyAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+
+	details := map[string]interface{}{
+		"anomaly_score": score,
+		"reason":        result["reason"],
+		"window_start":  result["window_start"],
+		"window_end":    result["window_end"],
+		"features":      result["features"],
+		"top_ips":       result["top_ips"],
+	}
+
+	return postAlertJSON(ctx, p.client, p.eventsURL, nil, map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alertID(result),
+		"payload": map[string]interface{}{
+			"summary":        fmt.Sprintf("Firewall anomaly on %s (score %.2f)", source, score),
+			"source":         source,
+			"severity":       p.severity,
+			"component":      "firewall_anomaly_detector",
+			"custom_details": details,
+		},
+	})
+}
@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// alertDelivery is an alert waiting to be sent to its routed sinks.
+type alertDelivery struct {
+	msg   *service.Message
+	sinks []alertSink
+}
+
+// alertQueue hands alerts to a background worker so that slow channels, such
+// as webhooks retrying with backoff, never hold up the scoring of windows.
+type alertQueue struct {
+	deliveries chan alertDelivery
+	shutdown   chan struct{}
+	done       chan struct{}
+}
+
+func newAlertQueueFromConfig(conf *service.ParsedConfig) (*alertQueue, error) {
+	size, err := conf.FieldInt("queue_size")
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("alerts.queue_size must not be negative, got %d", size)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	return &alertQueue{
+		deliveries: make(chan alertDelivery, size),
+		shutdown:   make(chan struct{}),
+		done:       make(chan struct{}),
+	}, nil
+}
+
+// deliver sends msg to sinks from the background worker, or inline when the
+// queue is disabled. Alerts arriving while the queue is full are dropped
+// rather than blocking the caller. The worker sends a deep copy, as the caller
+// keeps setting metadata on msg while it is emitted.
+func (a *alertDispatcher) deliver(ctx context.Context, msg *service.Message, sinks []alertSink) {
+	if a.queue == nil {
+		a.sendTo(ctx, msg, sinks)
+		return
+	}
+	select {
+	case a.queue.deliveries <- alertDelivery{msg: msg.DeepCopy(), sinks: sinks}:
+	default:
+		a.logger.Warnf("Alert queue full, dropping alert")
+		a.alertsDropped.Incr(1)
+	}
+}
+
+func (a *alertDispatcher) deliveryLoop() {
+	defer close(a.queue.done)
+
+	for {
+		select {
+		case d := <-a.queue.deliveries:
+			a.sendTo(context.Background(), d.msg, d.sinks)
+		case <-a.queue.shutdown:
+			// Send what was queued before shutting down
+			for {
+				select {
+				case d := <-a.queue.deliveries:
+					a.sendTo(context.Background(), d.msg, d.sinks)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSink holds every send until it is released, like a webhook stuck
+// retrying an unreachable endpoint.
+type blockingSink struct {
+	recordingSink
+	release chan struct{}
+}
+
+func (b *blockingSink) Send(ctx context.Context, msg *service.Message) error {
+	<-b.release
+	return b.recordingSink.Send(ctx, msg)
+}
+
+func testAlertQueue(t *testing.T, yaml string) (*alertQueue, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(alertsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newAlertQueueFromConfig(conf.Namespace("alerts"))
+}
+
+func TestAlertQueueConfig(t *testing.T) {
+	queue, err := testAlertQueue(t, `alerts: {}`)
+	require.NoError(t, err)
+	require.NotNil(t, queue)
+	assert.Equal(t, 1000, cap(queue.deliveries))
+
+	queue, err = testAlertQueue(t, `alerts: { queue_size: 0 }`)
+	require.NoError(t, err)
+	assert.Nil(t, queue, "zero sends alerts inline")
+
+	_, err = testAlertQueue(t, `alerts: { queue_size: -1 }`)
+	assert.ErrorContains(t, err, "alerts.queue_size must not be negative")
+}
+
+func TestAlertQueueDoesNotBlockDispatch(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	dispatcher := newTestDispatcher(sink)
+	queue, err := testAlertQueue(t, `alerts: { queue_size: 2 }`)
+	require.NoError(t, err)
+	dispatcher.queue = queue
+	go dispatcher.deliveryLoop()
+
+	dispatch := func(seq int) {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall", "seq": seq})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+
+	// The worker holds the first alert while the sink blocks, the queue the
+	// next two, and the fourth is dropped instead of waiting
+	dispatch(0)
+	require.Eventually(t, func() bool { return len(queue.deliveries) == 0 }, time.Second, time.Millisecond)
+	for i := 1; i < 4; i++ {
+		dispatch(i)
+	}
+
+	close(sink.release)
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	// Queued alerts are still delivered on shutdown
+	sink.mut.Lock()
+	defer sink.mut.Unlock()
+	assert.Len(t, sink.results, 3)
+}
+
+// metaSink records the topic metadata of the alerts it is sent.
+type metaSink struct {
+	recordingSink
+	topics []string
+}
+
+func (m *metaSink) Send(ctx context.Context, msg *service.Message) error {
+	topic, _ := msg.MetaGet("topic")
+	m.mut.Lock()
+	m.topics = append(m.topics, topic)
+	m.mut.Unlock()
+	return m.recordingSink.Send(ctx, msg)
+}
+
+func TestAlertQueueSendsCopies(t *testing.T) {
+	sink := &metaSink{}
+	dispatcher := newTestDispatcher(sink)
+	queue, err := testAlertQueue(t, `alerts: {}`)
+	require.NoError(t, err)
+	dispatcher.queue = queue
+	dispatcher.policies = []*escalationPolicy{{name: "default", channels: []alertSink{sink}, maxScore: 1, escalateAfter: time.Minute}}
+	dispatcher.escalator = newAlertEscalator()
+	go dispatcher.deliveryLoop()
+
+	msg := service.NewMessage(nil)
+	msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall"})
+	msg.MetaSet("topic", "firewall-anomalies")
+	dispatcher.Dispatch(context.Background(), msg)
+
+	// The result is emitted, and its metadata set, while the alert is sent
+	for i := 0; i < 100; i++ {
+		msg.MetaSet("topic", "firewall-output")
+	}
+	require.NoError(t, dispatcher.Close(context.Background()))
+
+	sink.mut.Lock()
+	defer sink.mut.Unlock()
+	assert.Equal(t, []string{"firewall-anomalies"}, sink.topics)
+
+	require.Len(t, dispatcher.escalator.pending, 1)
+	for _, p := range dispatcher.escalator.pending {
+		topic, _ := p.msg.MetaGet("topic")
+		assert.Equal(t, "firewall-anomalies", topic)
+	}
+}
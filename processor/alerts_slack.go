@@ -0,0 +1,218 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func slackAlertField() *service.ConfigField {
+	return service.NewObjectField("slack",
+		service.NewStringField("webhook_url").
+			Description("Slack incoming webhook URL. Takes precedence over `bot_token`.").
+			Default(""),
+		service.NewStringField("bot_token").
+			Description("Slack bot token used with `chat.postMessage` when no webhook URL is set").
+			Secret().
+			Default(""),
+		service.NewStringField("channel").
+			Description("Channel to post to when using a bot token").
+			Default(""),
+		service.NewStringField("template").
+			Description(alertTemplateDescription+" When set, the rendered text replaces the default Block Kit summary.").
+			Default(""),
+		service.NewStringField("api_url").
+			Description("Base URL of the Slack Web API").
+			Default("https://slack.com/api").
+			Advanced(),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single Slack request").
+			Default("5s"),
+	).Description("Native Slack alert channel posting Block Kit messages")
+}
+
+type slackAlert struct {
+	client     *http.Client
+	webhookURL string
+	botToken   string
+	channel    string
+	apiURL     string
+	template   *alertTemplate
+}
+
+func newSlackAlertFromConfig(conf *service.ParsedConfig) (*slackAlert, error) {
+	webhookURL, err := conf.FieldString("webhook_url")
+	if err != nil {
+		return nil, err
+	}
+
+	botToken, err := conf.FieldString("bot_token")
+	if err != nil {
+		return nil, err
+	}
+
+	if webhookURL == "" && botToken == "" {
+		return nil, nil
+	}
+
+	channel, err := conf.FieldString("channel")
+	if err != nil {
+		return nil, err
+	}
+	if webhookURL == "" && channel == "" {
+		return nil, fmt.Errorf("slack alerts require a channel when using a bot token")
+	}
+
+	templateStr, err := conf.FieldString("template")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newAlertTemplate("slack", templateStr)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL, err := conf.FieldString("api_url")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &slackAlert{
+		client:     &http.Client{Timeout: timeout},
+		webhookURL: webhookURL,
+		botToken:   botToken,
+		channel:    channel,
+		apiURL:     strings.TrimSuffix(apiURL, "/"),
+		template:   tmpl,
+	}, nil
+}
+
+func (s *slackAlert) Name() string {
+	return "slack"
+}
+
+func (s *slackAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	payload := slackMessage(result)
+	if s.template != nil {
+		text, err := s.template.Render(result)
+		if err != nil {
+			return fmt.Errorf("failed to render slack template: %w", err)
+		}
+		payload = map[string]interface{}{"text": text}
+	}
+
+	url := s.webhookURL
+	if url == "" {
+		url = s.apiURL + "/chat.postMessage"
+		payload["channel"] = s.channel
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if s.webhookURL == "" {
+		req.Header.Set("Authorization", "Bearer "+s.botToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("slack returned status %d: %s", resp.StatusCode, respBody)
+	}
+
+	// The Web API reports failures in the body of a 200 response.
+	if s.webhookURL == "" {
+		var apiResp struct {
+			OK    bool   `json:"ok"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(respBody, &apiResp); err != nil {
+			return fmt.Errorf("failed to decode slack response: %w", err)
+		}
+		if !apiResp.OK {
+			return fmt.Errorf("slack API error: %s", apiResp.Error)
+		}
+	}
+	return nil
+}
+
+// slackMessage builds a Block Kit message summarising an anomaly result.
+func slackMessage(result map[string]interface{}) map[string]interface{} {
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+	reason, _ := result["reason"].(string)
+
+	summary := fmt.Sprintf("Firewall anomaly on %s (score %.2f)", source, score)
+
+	features := resultFeatures(result)
+	var featureLines []string
+	for _, name := range topFeatures(result, alertTopFeatures) {
+		featureLines = append(featureLines, fmt.Sprintf("• `%s`: %.2f", name, features[name]))
+	}
+
+	var ipLines []string
+	for _, ip := range resultTopIPs(result) {
+		ipLines = append(ipLines, fmt.Sprintf("• `%s` (%d logs)", ip.IP, ip.Count))
+	}
+
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": ":rotating_light: " + summary},
+		},
+		{
+			"type": "section",
+			"fields": []map[string]interface{}{
+				{"type": "mrkdwn", "text": "*Source*\n" + source},
+				{"type": "mrkdwn", "text": fmt.Sprintf("*Score*\n%.2f", score)},
+				{"type": "mrkdwn", "text": "*Reason*\n" + reason},
+				{"type": "mrkdwn", "text": fmt.Sprintf("*Window*\n%v – %v", result["window_start"], result["window_end"])},
+			},
+		},
+	}
+	if len(featureLines) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*Top features*\n" + strings.Join(featureLines, "\n")},
+		})
+	}
+	if len(ipLines) > 0 {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*Top offending IPs*\n" + strings.Join(ipLines, "\n")},
+		})
+	}
+
+	return map[string]interface{}{
+		"text":   summary,
+		"blocks": blocks,
+	}
+}
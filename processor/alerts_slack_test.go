@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSlackAlert(t *testing.T, yaml string) (*slackAlert, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(slackAlertField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	return newSlackAlertFromConfig(conf.Namespace("slack"))
+}
+
+func testAnomalyMessage() *service.Message {
+	msg := service.NewMessage(nil)
+	msg.SetStructured(map[string]interface{}{
+		"log_source":    "fortinet.firewall",
+		"anomaly_score": 0.9,
+		"reason":        "hike_rate_detected",
+		"features": map[string]float64{
+			"mean_value":     10,
+			"percent_change": 250,
+			"unique_ips":     3,
+		},
+		"top_ips": []IPCount{{IP: "192.168.1.1", Count: 12}},
+	})
+	return msg
+}
+
+func TestSlackAlertBotToken(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.postMessage", r.URL.Path)
+		assert.Equal(t, "Bearer xoxb-test", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	slack, err := parseSlackAlert(t, `
+slack:
+  bot_token: xoxb-test
+  channel: "#soc"
+  api_url: `+server.URL+`
+`)
+	require.NoError(t, err)
+
+	require.NoError(t, slack.Send(context.Background(), testAnomalyMessage()))
+	assert.Equal(t, "#soc", payload["channel"])
+	assert.Equal(t, "Firewall anomaly on fortinet.firewall (score 0.90)", payload["text"])
+
+	blocks := payload["blocks"].([]interface{})
+	require.Len(t, blocks, 4)
+	features := blocks[2].(map[string]interface{})["text"].(map[string]interface{})["text"].(string)
+	assert.Equal(t, "*Top features*\n• `percent_change`: 250.00\n• `mean_value`: 10.00\n• `unique_ips`: 3.00", features)
+	ips := blocks[3].(map[string]interface{})["text"].(map[string]interface{})["text"].(string)
+	assert.Equal(t, "*Top offending IPs*\n• `192.168.1.1` (12 logs)", ips)
+}
+
+func TestSlackAlertAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	defer server.Close()
+
+	slack, err := parseSlackAlert(t, `
+slack:
+  bot_token: xoxb-test
+  channel: "#missing"
+  api_url: `+server.URL+`
+`)
+	require.NoError(t, err)
+
+	err = slack.Send(context.Background(), testAnomalyMessage())
+	assert.EqualError(t, err, "slack API error: channel_not_found")
+}
+
+func TestSlackAlertRequiresChannelForBotToken(t *testing.T) {
+	_, err := parseSlackAlert(t, `
+slack:
+  bot_token: xoxb-test
+`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,464 @@
+package processor
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	snmpVersion2c = "v2c"
+	snmpVersion3  = "v3"
+
+	snmpNoAuthNoPriv = "noAuthNoPriv"
+	snmpAuthNoPriv   = "authNoPriv"
+	snmpAuthPriv     = "authPriv"
+
+	snmpAuthMD5 = "MD5"
+	snmpAuthSHA = "SHA"
+
+	// defaultSNMPEnterpriseOID is the root of FIREWALL-ANOMALY-MIB, see
+	// docs/FIREWALL-ANOMALY-MIB.txt.
+	defaultSNMPEnterpriseOID = "1.3.6.1.4.1.99999.1"
+
+	snmpSysUpTimeOID  = "1.3.6.1.2.1.1.3.0"
+	snmpTrapOIDOID    = "1.3.6.1.6.3.1.1.4.1.0"
+	snmpMaxMessageLen = 65507
+)
+
+func snmpAlertField() *service.ConfigField {
+	return service.NewObjectField("snmp",
+		service.NewStringField("target").
+			Description("Trap receiver address as `host:port`. Leave empty to disable SNMP traps.").
+			Default(""),
+		service.NewStringEnumField("version", snmpVersion2c, snmpVersion3).
+			Description("SNMP version of emitted traps").
+			Default(snmpVersion2c),
+		service.NewStringField("community").
+			Description("Community string used for SNMPv2c traps").
+			Secret().
+			Default("public"),
+		service.NewStringField("enterprise_oid").
+			Description("Root OID of the bundled FIREWALL-ANOMALY-MIB").
+			Default(defaultSNMPEnterpriseOID).
+			Advanced(),
+		service.NewObjectField("v3",
+			service.NewStringField("user").
+				Description("USM user name").
+				Default(""),
+			service.NewStringEnumField("security_level", snmpNoAuthNoPriv, snmpAuthNoPriv, snmpAuthPriv).
+				Description("USM security level").
+				Default(snmpAuthPriv),
+			service.NewStringEnumField("auth_protocol", snmpAuthMD5, snmpAuthSHA).
+				Description("Authentication protocol").
+				Default(snmpAuthSHA),
+			service.NewStringField("auth_password").
+				Description("Authentication passphrase").
+				Secret().
+				Default(""),
+			service.NewStringField("priv_password").
+				Description("AES-128 privacy passphrase").
+				Secret().
+				Default(""),
+			service.NewStringField("engine_id").
+				Description("Hex encoded authoritative engine ID of this sender. A fixed text based ID is used when empty.").
+				Default(""),
+		).Description("SNMPv3 user based security settings"),
+	).Description("SNMP trap channel for NOC tooling")
+}
+
+type snmpAlert struct {
+	target     string
+	version    string
+	community  string
+	trapOID    []uint32
+	objectsOID []uint32
+	startTime  time.Time
+	requestID  int32
+
+	user          string
+	securityLevel string
+	authProtocol  string
+	authKey       []byte
+	privKey       []byte
+	engineID      []byte
+}
+
+func newSNMPAlertFromConfig(conf *service.ParsedConfig) (*snmpAlert, error) {
+	target, err := conf.FieldString("target")
+	if err != nil {
+		return nil, err
+	}
+	if target == "" {
+		return nil, nil
+	}
+
+	version, err := conf.FieldString("version")
+	if err != nil {
+		return nil, err
+	}
+
+	community, err := conf.FieldString("community")
+	if err != nil {
+		return nil, err
+	}
+
+	enterpriseStr, err := conf.FieldString("enterprise_oid")
+	if err != nil {
+		return nil, err
+	}
+	enterprise, err := parseOID(enterpriseStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enterprise_oid: %w", err)
+	}
+
+	s := &snmpAlert{
+		target:     target,
+		version:    version,
+		community:  community,
+		trapOID:    append(append([]uint32{}, enterprise...), 0, 1),
+		objectsOID: append(append([]uint32{}, enterprise...), 1),
+		startTime:  time.Now(),
+	}
+
+	if version != snmpVersion3 {
+		return s, nil
+	}
+
+	v3Conf := conf.Namespace("v3")
+	if s.user, err = v3Conf.FieldString("user"); err != nil {
+		return nil, err
+	}
+	if s.user == "" {
+		return nil, fmt.Errorf("snmp v3 traps require a user")
+	}
+	if s.securityLevel, err = v3Conf.FieldString("security_level"); err != nil {
+		return nil, err
+	}
+	if s.authProtocol, err = v3Conf.FieldString("auth_protocol"); err != nil {
+		return nil, err
+	}
+
+	engineIDStr, err := v3Conf.FieldString("engine_id")
+	if err != nil {
+		return nil, err
+	}
+	if engineIDStr == "" {
+		// RFC 3411 engine ID: enterprise bit, text format, then the text.
+		s.engineID = append([]byte{0x80, 0x00, 0x00, 0x00, 0x04}, "firewall-anomaly"...)
+	} else if s.engineID, err = hex.DecodeString(strings.TrimPrefix(engineIDStr, "0x")); err != nil {
+		return nil, fmt.Errorf("invalid engine_id: %w", err)
+	}
+
+	if s.securityLevel != snmpNoAuthNoPriv {
+		authPassword, err := v3Conf.FieldString("auth_password")
+		if err != nil {
+			return nil, err
+		}
+		if len(authPassword) < 8 {
+			return nil, fmt.Errorf("snmp v3 auth_password must be at least 8 characters")
+		}
+		s.authKey = snmpLocalizeKey(s.newHash, authPassword, s.engineID)
+	}
+
+	if s.securityLevel == snmpAuthPriv {
+		privPassword, err := v3Conf.FieldString("priv_password")
+		if err != nil {
+			return nil, err
+		}
+		if len(privPassword) < 8 {
+			return nil, fmt.Errorf("snmp v3 priv_password must be at least 8 characters")
+		}
+		s.privKey = snmpLocalizeKey(s.newHash, privPassword, s.engineID)[:16]
+	}
+	return s, nil
+}
+
+func (s *snmpAlert) Name() string {
+	return "snmp"
+}
+
+func (s *snmpAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	packet, err := s.encodeTrap(result)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", s.target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(packet)
+	return err
+}
+
+func (s *snmpAlert) newHash() hash.Hash {
+	if s.authProtocol == snmpAuthMD5 {
+		return md5.New()
+	}
+	return sha1.New()
+}
+
+func (s *snmpAlert) varbinds(result map[string]interface{}) []byte {
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+	reason := fmt.Sprintf("%v", result["reason"])
+	windowEnd := fmt.Sprintf("%v", result["window_end"])
+	if t, ok := result["window_end"].(time.Time); ok {
+		windowEnd = t.UTC().Format(time.RFC3339)
+	}
+
+	object := func(id uint32) []uint32 {
+		return append(append([]uint32{}, s.objectsOID...), id, 0)
+	}
+	uptime := uint32(time.Since(s.startTime) / (10 * time.Millisecond))
+
+	var vbs []byte
+	vbs = append(vbs, berVarbind(mustParseOID(snmpSysUpTimeOID), berTLV(0x43, berUint(uptime)))...)
+	vbs = append(vbs, berVarbind(mustParseOID(snmpTrapOIDOID), berOID(s.trapOID))...)
+	vbs = append(vbs, berVarbind(object(1), berOctetString([]byte(source)))...)
+	vbs = append(vbs, berVarbind(object(2), berInteger(int64(math.Round(score*1000))))...)
+	vbs = append(vbs, berVarbind(object(3), berOctetString([]byte(reason)))...)
+	vbs = append(vbs, berVarbind(object(4), berOctetString([]byte(windowEnd)))...)
+	return berTLV(0x30, vbs)
+}
+
+func (s *snmpAlert) encodeTrap(result map[string]interface{}) ([]byte, error) {
+	requestID := atomic.AddInt32(&s.requestID, 1)
+
+	pdu := berTLV(0xa7, concatBytes(
+		berInteger(int64(requestID)),
+		berInteger(0),
+		berInteger(0),
+		s.varbinds(result),
+	))
+
+	if s.version == snmpVersion2c {
+		return berTLV(0x30, concatBytes(
+			berInteger(1),
+			berOctetString([]byte(s.community)),
+			pdu,
+		)), nil
+	}
+	return s.encodeV3(requestID, pdu)
+}
+
+// encodeV3 wraps the PDU in an SNMPv3 message secured with the user based
+// security model of RFC 3414, using AES-128 privacy from RFC 3826.
+func (s *snmpAlert) encodeV3(msgID int32, pdu []byte) ([]byte, error) {
+	var flags byte
+	if s.securityLevel != snmpNoAuthNoPriv {
+		flags |= 0x01
+	}
+	if s.securityLevel == snmpAuthPriv {
+		flags |= 0x02
+	}
+
+	engineBoots := uint32(1)
+	engineTime := uint32(time.Since(s.startTime) / time.Second)
+
+	scopedPDU := berTLV(0x30, concatBytes(
+		berOctetString(s.engineID),
+		berOctetString(nil),
+		pdu,
+	))
+
+	msgData := scopedPDU
+	var privParams []byte
+	if s.securityLevel == snmpAuthPriv {
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 16)
+		binary.BigEndian.PutUint32(iv[0:4], engineBoots)
+		binary.BigEndian.PutUint32(iv[4:8], engineTime)
+		copy(iv[8:], salt)
+
+		block, err := aes.NewCipher(s.privKey)
+		if err != nil {
+			return nil, err
+		}
+		encrypted := make([]byte, len(scopedPDU))
+		cipher.NewCFBEncrypter(block, iv).XORKeyStream(encrypted, scopedPDU)
+
+		msgData = berOctetString(encrypted)
+		privParams = salt
+	}
+
+	var authParams []byte
+	if s.securityLevel != snmpNoAuthNoPriv {
+		authParams = make([]byte, 12)
+	}
+
+	securityParams := berTLV(0x30, concatBytes(
+		berOctetString(s.engineID),
+		berInteger(int64(engineBoots)),
+		berInteger(int64(engineTime)),
+		berOctetString([]byte(s.user)),
+		berOctetString(authParams),
+		berOctetString(privParams),
+	))
+
+	packet := berTLV(0x30, concatBytes(
+		berInteger(3),
+		berTLV(0x30, concatBytes(
+			berInteger(int64(msgID)),
+			berInteger(snmpMaxMessageLen),
+			berOctetString([]byte{flags}),
+			berInteger(3),
+		)),
+		berOctetString(securityParams),
+		msgData,
+	))
+
+	if authParams != nil {
+		// The digest is computed over the whole message with zeroed auth
+		// parameters, which are then replaced in place.
+		offset := len(packet) - len(msgData) - len(privParams) - 2 - 12
+		mac := hmac.New(s.newHash, s.authKey)
+		mac.Write(packet)
+		copy(packet[offset:offset+12], mac.Sum(nil)[:12])
+	}
+	return packet, nil
+}
+
+// snmpLocalizeKey implements the password to key algorithm of RFC 3414
+// appendix A.2 followed by key localization to engineID.
+func snmpLocalizeKey(newHash func() hash.Hash, password string, engineID []byte) []byte {
+	h := newHash()
+	buf := make([]byte, 64)
+	pwLen := len(password)
+	index := 0
+	for count := 0; count < 1048576; count += 64 {
+		for i := range buf {
+			buf[i] = password[index%pwLen]
+			index++
+		}
+		h.Write(buf)
+	}
+	ku := h.Sum(nil)
+
+	h = newHash()
+	h.Write(ku)
+	h.Write(engineID)
+	h.Write(ku)
+	return h.Sum(nil)
+}
+
+//------------------------------------------------------------------------------
+
+func parseOID(s string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimPrefix(s, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("oid %q must have at least two components", s)
+	}
+	oid := make([]uint32, len(parts))
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("oid %q: %w", s, err)
+		}
+		oid[i] = uint32(v)
+	}
+	return oid, nil
+}
+
+func mustParseOID(s string) []uint32 {
+	oid, err := parseOID(s)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var out []byte
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for n > 0 {
+		b = append([]byte{byte(n)}, b...)
+		n >>= 8
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func berTLV(tag byte, value []byte) []byte {
+	return concatBytes([]byte{tag}, berLength(len(value)), value)
+}
+
+func berInteger(v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 127 || v < -128 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return berTLV(0x02, b)
+}
+
+// berUint encodes the content octets of an unsigned application type such
+// as TimeTicks.
+func berUint(v uint32) []byte {
+	b := []byte{byte(v)}
+	for v > 0xff {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return b
+}
+
+func berOctetString(b []byte) []byte {
+	return berTLV(0x04, b)
+}
+
+func berOID(oid []uint32) []byte {
+	b := []byte{byte(oid[0]*40 + oid[1])}
+	for _, sub := range oid[2:] {
+		enc := []byte{byte(sub & 0x7f)}
+		for sub >>= 7; sub > 0; sub >>= 7 {
+			enc = append([]byte{byte(sub&0x7f) | 0x80}, enc...)
+		}
+		b = append(b, enc...)
+	}
+	return berTLV(0x06, b)
+}
+
+func berVarbind(oid []uint32, value []byte) []byte {
+	return berTLV(0x30, concatBytes(berOID(oid), value))
+}
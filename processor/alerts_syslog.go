@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	syslogNetworkUDP = "udp"
+	syslogNetworkTCP = "tcp"
+	syslogNetworkTLS = "tls"
+
+	// syslogSDID is the structured data ID carrying detection details. The
+	// private enterprise number matches FIREWALL-ANOMALY-MIB.
+	syslogSDID = "anomaly@99999"
+)
+
+func syslogAlertField() *service.ConfigField {
+	return service.NewObjectField("syslog",
+		service.NewStringField("address").
+			Description("Syslog receiver as `host:port`. Leave empty to disable syslog forwarding.").
+			Default(""),
+		service.NewStringEnumField("network", syslogNetworkUDP, syslogNetworkTCP, syslogNetworkTLS).
+			Description("Transport used to reach the receiver").
+			Default(syslogNetworkUDP),
+		service.NewTLSField("tls").
+			Description("TLS settings used when `network` is `tls`"),
+		service.NewIntField("facility").
+			Description("Syslog facility code, defaults to local4").
+			Default(20),
+		service.NewStringField("app_name").
+			Description("APP-NAME header field").
+			Default("firewall-anomaly-detector"),
+		service.NewDurationField("timeout").
+			Description("Timeout for connecting and writing a message").
+			Default("5s"),
+	).Description("RFC 5424 syslog forwarder for SIEMs that only ingest syslog")
+}
+
+type syslogAlert struct {
+	address  string
+	network  string
+	tlsConf  *tls.Config
+	facility int
+	appName  string
+	hostname string
+	timeout  time.Duration
+
+	connMut sync.Mutex
+	conn    net.Conn
+}
+
+func newSyslogAlertFromConfig(conf *service.ParsedConfig) (*syslogAlert, error) {
+	address, err := conf.FieldString("address")
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		return nil, nil
+	}
+
+	network, err := conf.FieldString("network")
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConf, err := conf.FieldTLS("tls")
+	if err != nil {
+		return nil, err
+	}
+
+	facility, err := conf.FieldInt("facility")
+	if err != nil {
+		return nil, err
+	}
+	if facility < 0 || facility > 23 {
+		return nil, fmt.Errorf("syslog facility must be between 0 and 23, got %d", facility)
+	}
+
+	appName, err := conf.FieldString("app_name")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &syslogAlert{
+		address:  address,
+		network:  network,
+		tlsConf:  tlsConf,
+		facility: facility,
+		appName:  appName,
+		hostname: hostname,
+		timeout:  timeout,
+	}, nil
+}
+
+func (s *syslogAlert) Name() string {
+	return "syslog"
+}
+
+func (s *syslogAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	line := s.format(result, time.Now())
+
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	if s.conn == nil {
+		if s.conn, err = s.dial(ctx); err != nil {
+			return err
+		}
+	}
+
+	payload := []byte(line)
+	if s.network != syslogNetworkUDP {
+		// RFC 6587 octet counting framing for stream transports.
+		payload = []byte(fmt.Sprintf("%d %s", len(line), line))
+	}
+
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write(payload); err != nil {
+		// Drop the connection so the next alert reconnects.
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *syslogAlert) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	switch s.network {
+	case syslogNetworkTLS:
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: s.tlsConf}
+		return tlsDialer.DialContext(ctx, "tcp", s.address)
+	case syslogNetworkTCP:
+		return dialer.DialContext(ctx, "tcp", s.address)
+	default:
+		return dialer.DialContext(ctx, "udp", s.address)
+	}
+}
+
+func (s *syslogAlert) Close(ctx context.Context) error {
+	s.connMut.Lock()
+	defer s.connMut.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// format renders an anomaly result as an RFC 5424 message with the detection
+// details carried as structured data.
+func (s *syslogAlert) format(result map[string]interface{}, now time.Time) string {
+	const severityWarning = 4
+	pri := s.facility*8 + severityWarning
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+
+	params := map[string]string{
+		"log_source":    source,
+		"anomaly_score": fmt.Sprintf("%.4f", score),
+		"reason":        fmt.Sprintf("%v", result["reason"]),
+	}
+	for _, field := range []string{"window_start", "window_end"} {
+		if t, ok := result[field].(time.Time); ok {
+			params[field] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	features := resultFeatures(result)
+	for _, name := range topFeatures(result, alertTopFeatures) {
+		params[name] = fmt.Sprintf("%.4f", features[name])
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, name := range names {
+		fmt.Fprintf(&sd, ` %s="%s"`, name, syslogEscapeParam(params[name]))
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s - anomaly %s Firewall anomaly on %s (score %.2f)",
+		pri, now.UTC().Format(time.RFC3339Nano), s.hostname, s.appName, sd.String(), source, score)
+}
+
+func syslogEscapeParam(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSyslogAlert(t *testing.T, yaml string) *syslogAlert {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(syslogAlertField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	syslog, err := newSyslogAlertFromConfig(conf.Namespace("syslog"))
+	require.NoError(t, err)
+	require.NotNil(t, syslog)
+	return syslog
+}
+
+func TestSyslogFormat(t *testing.T) {
+	syslog := parseSyslogAlert(t, `
+syslog:
+  address: 127.0.0.1:514
+`)
+	syslog.hostname = "detector-1"
+
+	now := time.Date(2024, 1, 15, 10, 31, 0, 0, time.UTC)
+	line := syslog.format(map[string]interface{}{
+		"log_source":    "fortinet.firewall",
+		"anomaly_score": 0.9,
+		"reason":        `odd "quoted" reason]`,
+		"window_end":    now,
+	}, now)
+
+	assert.Equal(t, `<164>1 2024-01-15T10:31:00Z detector-1 firewall-anomaly-detector - anomaly `+
+		`[anomaly@99999 anomaly_score="0.9000" log_source="fortinet.firewall" reason="odd \"quoted\" reason\]" window_end="2024-01-15T10:31:00Z"] `+
+		`Firewall anomaly on fortinet.firewall (score 0.90)`, line)
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString(')')
+		received <- line
+	}()
+
+	syslog := parseSyslogAlert(t, `
+syslog:
+  address: `+listener.Addr().String()+`
+  network: tcp
+`)
+	defer syslog.Close(context.Background())
+
+	require.NoError(t, syslog.Send(context.Background(), testAnomalyMessage()))
+
+	select {
+	case line := <-received:
+		length, rest, found := strings.Cut(line, " ")
+		require.True(t, found)
+		assert.Equal(t, length, strconv.Itoa(len(rest)))
+		assert.True(t, strings.HasPrefix(rest, "<164>1 "))
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog message")
+	}
+}
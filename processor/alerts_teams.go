@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func teamsAlertField() *service.ConfigField {
+	return service.NewObjectField("teams",
+		service.NewStringField("webhook_url").
+			Description("Microsoft Teams incoming webhook URL. Leave empty to disable Teams alerts.").
+			Default(""),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single Teams request").
+			Default("5s"),
+	).Description("Microsoft Teams incoming webhook channel posting message cards")
+}
+
+type teamsAlert struct {
+	client     *http.Client
+	webhookURL string
+}
+
+func newTeamsAlertFromConfig(conf *service.ParsedConfig) (*teamsAlert, error) {
+	webhookURL, err := conf.FieldString("webhook_url")
+	if err != nil {
+		return nil, err
+	}
+	if webhookURL == "" {
+		return nil, nil
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &teamsAlert{
+		client:     &http.Client{Timeout: timeout},
+		webhookURL: webhookURL,
+	}, nil
+}
+
+func (t *teamsAlert) Name() string {
+	return "teams"
+}
+
+func (t *teamsAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+	return postAlertJSON(ctx, t.client, t.webhookURL, nil, teamsCard(result))
+}
+
+// teamsCard builds a legacy MessageCard, which every Teams incoming webhook
+// accepts without additional app registration.
+func teamsCard(result map[string]interface{}) map[string]interface{} {
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+	summary := fmt.Sprintf("Firewall anomaly on %s (score %.2f)", source, score)
+
+	facts := []map[string]string{
+		{"name": "Source", "value": source},
+		{"name": "Score", "value": fmt.Sprintf("%.2f", score)},
+		{"name": "Reason", "value": fmt.Sprintf("%v", result["reason"])},
+		{"name": "Window", "value": fmt.Sprintf("%v - %v", result["window_start"], result["window_end"])},
+	}
+
+	features := resultFeatures(result)
+	for _, name := range topFeatures(result, alertTopFeatures) {
+		facts = append(facts, map[string]string{"name": name, "value": fmt.Sprintf("%.2f", features[name])})
+	}
+
+	var ips []string
+	for _, ip := range resultTopIPs(result) {
+		ips = append(ips, fmt.Sprintf("%s (%d)", ip.IP, ip.Count))
+	}
+	if len(ips) > 0 {
+		facts = append(facts, map[string]string{"name": "Top IPs", "value": strings.Join(ips, ", ")})
+	}
+
+	return map[string]interface{}{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"themeColor": "D70000",
+		"summary":    summary,
+		"sections": []map[string]interface{}{
+			{
+				"activityTitle": summary,
+				"facts":         facts,
+			},
+		},
+	}
+}
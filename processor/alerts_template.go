@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// alertTemplate renders alert bodies from Go templates. Templates are
+// executed against the anomaly result, so every result field is available,
+// e.g. `{{ .log_source }}` or `{{ index .features "std_dev" }}`.
+type alertTemplate struct {
+	tmpl *template.Template
+}
+
+var alertTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"formatTime": func(layout string, v interface{}) string {
+		if t, ok := v.(time.Time); ok {
+			return t.Format(layout)
+		}
+		return fmt.Sprintf("%v", v)
+	},
+	"topFeatures": func(n int, result map[string]interface{}) []string {
+		return topFeatures(result, n)
+	},
+	"topIPs": func(result map[string]interface{}) []IPCount {
+		return resultTopIPs(result)
+	},
+	"feature": func(name string, result map[string]interface{}) float64 {
+		return resultFeatures(result)[name]
+	},
+}
+
+func newAlertTemplate(name, src string) (*alertTemplate, error) {
+	if src == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(alertTemplateFuncs).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w", name, err)
+	}
+	return &alertTemplate{tmpl: tmpl}, nil
+}
+
+func (a *alertTemplate) Render(result map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := a.tmpl.Execute(&buf, result); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+const alertTemplateDescription = "Go `text/template` rendered against the anomaly result. " +
+	"Fields are accessed as `{{ .log_source }}`, and the functions `json`, `upper`, `lower`, " +
+	"`formatTime`, `topFeatures`, `topIPs` and `feature` are available."
@@ -0,0 +1,37 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertTemplateRender(t *testing.T) {
+	tmpl, err := newAlertTemplate("test", `{{ upper .log_source }} {{ printf "%.1f" .anomaly_score }} `+
+		`{{ formatTime "15:04" .window_end }} {{ feature "std_dev" . }}`+
+		`{{ range topFeatures 1 . }} top={{ . }}{{ end }}`+
+		`{{ range topIPs . }} ip={{ .IP }}/{{ .Count }}{{ end }} {{ json .reason }} [{{ .missing }}]`)
+	require.NoError(t, err)
+
+	rendered, err := tmpl.Render(map[string]interface{}{
+		"log_source":    "fortinet.firewall",
+		"anomaly_score": 0.91,
+		"reason":        "hike_rate_detected",
+		"window_end":    time.Date(2024, 1, 15, 10, 31, 0, 0, time.UTC),
+		"features":      map[string]float64{"std_dev": 4.5, "percent_change": 80},
+		"top_ips":       []IPCount{{IP: "10.0.0.1", Count: 4}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `FORTINET.FIREWALL 0.9 10:31 4.5 top=percent_change ip=10.0.0.1/4 "hike_rate_detected" [<no value>]`, rendered)
+}
+
+func TestAlertTemplateEmptyAndInvalid(t *testing.T) {
+	tmpl, err := newAlertTemplate("empty", "")
+	require.NoError(t, err)
+	assert.Nil(t, tmpl)
+
+	_, err = newAlertTemplate("invalid", "{{ .unterminated ")
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// theHiveSeverities maps severity names to the severity levels of TheHive.
+var theHiveSeverities = map[string]int{
+	"low":      1,
+	"medium":   2,
+	"high":     3,
+	"critical": 4,
+}
+
+func theHiveAlertField() *service.ConfigField {
+	return service.NewObjectField("thehive",
+		service.NewStringField("url").
+			Description("Base URL of TheHive, e.g. `https://thehive.example.com`. Leave empty to disable TheHive alerts.").
+			Default(""),
+		service.NewStringField("api_key").
+			Description("API key of the TheHive user alerts are created as").
+			Secret().
+			Default(""),
+		service.NewStringField("organisation").
+			Description("Organisation alerts are created in, for users belonging to several").
+			Default(""),
+		service.NewFloatField("min_score").
+			Description("Lowest anomaly score an alert is created for, so that only high severity detections open alerts").
+			Default(0.8),
+		service.NewStringField("min_severity").
+			Description("Lowest `max_severity` of the logs of a window an alert is created for, one of `info`, `low`, `medium`, `high` or `critical`. Empty creates alerts whatever the severity of the logs.").
+			Default(""),
+		service.NewStringEnumField("severity", "low", "medium", "high", "critical").
+			Description("Severity of created alerts").
+			Default("high"),
+		service.NewIntField("tlp").
+			Description("Traffic Light Protocol level of created alerts and their observables, from 0 (white) to 4 (red)").
+			Default(2),
+		service.NewIntField("pap").
+			Description("Permissible Actions Protocol level of created alerts, from 0 (white) to 3 (red)").
+			Default(2),
+		service.NewStringField("alert_type").
+			Description("Type of created alerts, which with their source and window ID makes them unique").
+			Default("firewall_anomaly"),
+		service.NewStringListField("tags").
+			Description("Tags attached to created alerts").
+			Default([]string{"firewall", "anomaly"}),
+		service.NewObjectField("cortex",
+			service.NewStringField("url").
+				Description("Base URL of Cortex, e.g. `https://cortex.example.com`. Leave empty to run no analyzers.").
+				Default(""),
+			service.NewStringField("api_key").
+				Description("API key of the Cortex user analyzers are run as").
+				Secret().
+				Default(""),
+			service.NewStringListField("analyzers").
+				Description("IDs of the analyzers run on every IP observable of a created alert, e.g. `AbuseIPDB_1_0`").
+				Default([]string{}),
+		).Description("Cortex analyzers run on the IP observables of created alerts"),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single TheHive or Cortex request").
+			Default("5s"),
+	).Description("TheHive alert channel, attaching the top offending IPs as observables")
+}
+
+type theHiveAlert struct {
+	logger       *service.Logger
+	client       *http.Client
+	url          string
+	apiKey       string
+	organisation string
+	minScore     float64
+	minSeverity  string
+	severity     int
+	tlp          int
+	pap          int
+	alertType    string
+	tags         []string
+
+	cortexURL       string
+	cortexAPIKey    string
+	cortexAnalyzers []string
+}
+
+func newTheHiveAlertFromConfig(conf *service.ParsedConfig, logger *service.Logger) (*theHiveAlert, error) {
+	url, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, nil
+	}
+
+	t := &theHiveAlert{logger: logger, url: strings.TrimSuffix(url, "/")}
+	if t.apiKey, err = conf.FieldString("api_key"); err != nil {
+		return nil, err
+	}
+	if t.apiKey == "" {
+		return nil, errors.New("thehive.api_key is required")
+	}
+	if t.organisation, err = conf.FieldString("organisation"); err != nil {
+		return nil, err
+	}
+	if t.minScore, err = conf.FieldFloat("min_score"); err != nil {
+		return nil, err
+	}
+	if t.minSeverity, err = conf.FieldString("min_severity"); err != nil {
+		return nil, err
+	}
+	if _, ok := severityRanks[t.minSeverity]; t.minSeverity != "" && (!ok || t.minSeverity == severityUnknown) {
+		return nil, fmt.Errorf("thehive.min_severity must be a canonical severity, got %q", t.minSeverity)
+	}
+	severity, err := conf.FieldString("severity")
+	if err != nil {
+		return nil, err
+	}
+	t.severity = theHiveSeverities[severity]
+	if t.tlp, err = conf.FieldInt("tlp"); err != nil {
+		return nil, err
+	}
+	if t.tlp < 0 || t.tlp > 4 {
+		return nil, fmt.Errorf("thehive.tlp must be between 0 and 4, got %d", t.tlp)
+	}
+	if t.pap, err = conf.FieldInt("pap"); err != nil {
+		return nil, err
+	}
+	if t.pap < 0 || t.pap > 3 {
+		return nil, fmt.Errorf("thehive.pap must be between 0 and 3, got %d", t.pap)
+	}
+	if t.alertType, err = conf.FieldString("alert_type"); err != nil {
+		return nil, err
+	}
+	if t.tags, err = conf.FieldStringList("tags"); err != nil {
+		return nil, err
+	}
+
+	cortexURL, err := conf.FieldString("cortex", "url")
+	if err != nil {
+		return nil, err
+	}
+	if cortexURL != "" {
+		t.cortexURL = strings.TrimSuffix(cortexURL, "/")
+		if t.cortexAPIKey, err = conf.FieldString("cortex", "api_key"); err != nil {
+			return nil, err
+		}
+		if t.cortexAnalyzers, err = conf.FieldStringList("cortex", "analyzers"); err != nil {
+			return nil, err
+		}
+		if len(t.cortexAnalyzers) == 0 {
+			return nil, errors.New("thehive.cortex.analyzers must not be empty when thehive.cortex.url is set")
+		}
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+	t.client = &http.Client{Timeout: timeout}
+	return t, nil
+}
+
+func (t *theHiveAlert) Name() string {
+	return "thehive"
+}
+
+// accepts reports whether a result is severe enough to open an alert.
+func (t *theHiveAlert) accepts(result map[string]interface{}) bool {
+	if score, _ := result["anomaly_score"].(float64); score < t.minScore {
+		return false
+	}
+	if t.minSeverity != "" {
+		severity, _ := result["max_severity"].(string)
+		if severityRanks[severity] < severityRanks[t.minSeverity] {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *theHiveAlert) Send(ctx context.Context, msg *service.Message) error {
+	result, err := alertResult(msg)
+	if err != nil {
+		return err
+	}
+
+	source, _ := result["log_source"].(string)
+	score, _ := result["anomaly_score"].(float64)
+
+	ips := resultTopIPs(result)
+	observables := make([]map[string]interface{}, 0, len(ips))
+	for _, ip := range ips {
+		observables = append(observables, map[string]interface{}{
+			"dataType": "ip",
+			"data":     ip.IP,
+			"message":  fmt.Sprintf("%d logs in the anomalous window", ip.Count),
+			"tlp":      t.tlp,
+			"tags":     []string{"log_source:" + source},
+		})
+	}
+
+	tags := append([]string{"log_source:" + source}, t.tags...)
+	if reason, ok := result["reason"].(string); ok && reason != "" {
+		tags = append(tags, "reason:"+reason)
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + t.apiKey}
+	if t.organisation != "" {
+		headers["X-Organisation"] = t.organisation
+	}
+	if err := postAlertJSON(ctx, t.client, t.url+"/api/v1/alert", headers, map[string]interface{}{
+		"type":        t.alertType,
+		"source":      "firewall_anomaly_detector",
+		"sourceRef":   alertID(result),
+		"title":       fmt.Sprintf("Firewall anomaly on %s (score %.2f)", source, score),
+		"description": emailResultSummary(result),
+		"severity":    t.severity,
+		"tlp":         t.tlp,
+		"pap":         t.pap,
+		"tags":        tags,
+		"observables": observables,
+	}); err != nil {
+		return err
+	}
+
+	// The alert exists, so failed analyzer runs are only logged rather than
+	// failing the delivery
+	for _, ip := range ips {
+		for _, analyzer := range t.cortexAnalyzers {
+			if err := t.runAnalyzer(ctx, analyzer, ip.IP); err != nil {
+				t.logger.Warnf("Failed to run Cortex analyzer %s on %s: %v", analyzer, ip.IP, err)
+			}
+		}
+	}
+	return nil
+}
+
+// runAnalyzer starts a Cortex job analysing an IP.
+func (t *theHiveAlert) runAnalyzer(ctx context.Context, analyzer, ip string) error {
+	return postAlertJSON(ctx, t.client, t.cortexURL+"/api/analyzer/"+analyzer+"/run", map[string]string{
+		"Authorization": "Bearer " + t.cortexAPIKey,
+	}, map[string]interface{}{
+		"data":     ip,
+		"dataType": "ip",
+		"tlp":      t.tlp,
+		"message":  "Offending IP of a firewall anomaly",
+	})
+}
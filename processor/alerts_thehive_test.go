@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTheHiveAlert(t *testing.T, yaml string) (*theHiveAlert, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(theHiveAlertField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newTheHiveAlertFromConfig(conf.Namespace("thehive"), service.MockResources().Logger())
+}
+
+func TestTheHiveAlertConfig(t *testing.T) {
+	theHive, err := parseTheHiveAlert(t, `thehive: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, theHive)
+
+	for _, yaml := range []string{
+		`thehive: { url: "http://thehive" }`,
+		`thehive: { url: "http://thehive", api_key: abc, min_severity: severe }`,
+		`thehive: { url: "http://thehive", api_key: abc, tlp: 5 }`,
+		`thehive: { url: "http://thehive", api_key: abc, cortex: { url: "http://cortex" } }`,
+	} {
+		_, err = parseTheHiveAlert(t, yaml)
+		assert.Error(t, err, yaml)
+	}
+}
+
+func TestTheHiveAlert(t *testing.T) {
+	var payload map[string]interface{}
+	theHiveServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/alert", r.URL.Path)
+		assert.Equal(t, "Bearer abc", r.Header.Get("Authorization"))
+		assert.Equal(t, "soc", r.Header.Get("X-Organisation"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer theHiveServer.Close()
+
+	var mut sync.Mutex
+	var jobs []string
+	cortexServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer def", r.Header.Get("Authorization"))
+		var job map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&job))
+		mut.Lock()
+		jobs = append(jobs, r.URL.Path+" "+job["data"].(string))
+		mut.Unlock()
+		if r.URL.Path == "/api/analyzer/Broken_1_0/run" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer cortexServer.Close()
+
+	theHive, err := parseTheHiveAlert(t, `
+thehive:
+  url: `+theHiveServer.URL+`
+  api_key: abc
+  organisation: soc
+  severity: critical
+  cortex:
+    url: `+cortexServer.URL+`
+    api_key: def
+    analyzers: [ AbuseIPDB_1_0, Broken_1_0 ]
+`)
+	require.NoError(t, err)
+
+	// A failing analyzer does not fail the alert
+	require.NoError(t, theHive.Send(context.Background(), testAnomalyMessage()))
+	assert.Equal(t, "Firewall anomaly on fortinet.firewall (score 0.90)", payload["title"])
+	assert.Equal(t, "firewall_anomaly", payload["type"])
+	assert.Equal(t, 4.0, payload["severity"])
+	assert.Equal(t, []interface{}{"log_source:fortinet.firewall", "firewall", "anomaly", "reason:hike_rate_detected"}, payload["tags"])
+	observables := payload["observables"].([]interface{})
+	require.Len(t, observables, 1)
+	assert.Equal(t, "ip", observables[0].(map[string]interface{})["dataType"])
+	assert.Equal(t, "192.168.1.1", observables[0].(map[string]interface{})["data"])
+	assert.Equal(t, []string{"/api/analyzer/AbuseIPDB_1_0/run 192.168.1.1", "/api/analyzer/Broken_1_0/run 192.168.1.1"}, jobs)
+}
+
+func TestTheHiveAlertHighSeverityOnly(t *testing.T) {
+	var alerts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alerts++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	theHive, err := parseTheHiveAlert(t, `
+thehive:
+  url: `+server.URL+`
+  api_key: abc
+  min_score: 0.9
+  min_severity: high
+`)
+	require.NoError(t, err)
+
+	assert.True(t, theHive.accepts(map[string]interface{}{"anomaly_score": 0.95, "max_severity": "critical"}))
+	assert.False(t, theHive.accepts(map[string]interface{}{"anomaly_score": 0.85, "max_severity": "critical"}))
+	assert.False(t, theHive.accepts(map[string]interface{}{"anomaly_score": 0.95, "max_severity": "medium"}))
+	assert.False(t, theHive.accepts(map[string]interface{}{"anomaly_score": 0.95}))
+
+	// The dispatcher skips anomalies the channel does not accept
+	dispatcher := newTestDispatcher(theHive)
+	for _, score := range []float64{0.5, 0.95} {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall", "anomaly_score": score, "max_severity": "high"})
+		dispatcher.Dispatch(context.Background(), msg)
+	}
+	assert.Equal(t, 1, alerts)
+}
@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func webhookAlertField() *service.ConfigField {
+	return service.NewObjectField("webhook",
+		service.NewStringField("url").
+			Description("URL that anomaly events are POSTed to. Leave empty to disable the webhook.").
+			Default(""),
+		service.NewInterpolatedStringField("body").
+			Description("Templated request body. Defaults to the anomaly result as JSON.").
+			Default("${! content() }"),
+		service.NewStringField("template").
+			Description(alertTemplateDescription+" Takes precedence over `body` when set.").
+			Default(""),
+		service.NewStringMapField("headers").
+			Description("Additional HTTP headers sent with each alert").
+			Default(map[string]interface{}{}),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single delivery attempt").
+			Default("5s"),
+		service.NewIntField("max_retries").
+			Description("Maximum number of retries after a failed delivery").
+			Default(3),
+		service.NewBackOffField("backoff", false, &backoff.ExponentialBackOff{
+			InitialInterval: 500 * time.Millisecond,
+			MaxInterval:     5 * time.Second,
+			MaxElapsedTime:  30 * time.Second,
+		}),
+	).Description("Generic HTTP webhook alert channel")
+}
+
+type webhookAlert struct {
+	client     *http.Client
+	url        string
+	body       *service.InterpolatedString
+	template   *alertTemplate
+	headers    map[string]string
+	maxRetries int
+	backoff    *backoff.ExponentialBackOff
+}
+
+func newWebhookAlertFromConfig(conf *service.ParsedConfig) (*webhookAlert, error) {
+	url, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, nil
+	}
+
+	body, err := conf.FieldInterpolatedString("body")
+	if err != nil {
+		return nil, err
+	}
+
+	templateStr, err := conf.FieldString("template")
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := newAlertTemplate("webhook", templateStr)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := conf.FieldStringMap("headers")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries, err := conf.FieldInt("max_retries")
+	if err != nil {
+		return nil, err
+	}
+
+	boff, err := conf.FieldBackOff("backoff")
+	if err != nil {
+		return nil, err
+	}
+
+	return &webhookAlert{
+		client:     &http.Client{Timeout: timeout},
+		url:        url,
+		body:       body,
+		template:   tmpl,
+		headers:    headers,
+		maxRetries: maxRetries,
+		backoff:    boff,
+	}, nil
+}
+
+func (w *webhookAlert) Name() string {
+	return "webhook"
+}
+
+func (w *webhookAlert) Send(ctx context.Context, msg *service.Message) error {
+	body, err := w.render(msg)
+	if err != nil {
+		return fmt.Errorf("failed to render webhook body: %w", err)
+	}
+
+	boff := *w.backoff
+	boff.Reset()
+
+	return backoff.Retry(func() error {
+		return w.post(ctx, body)
+	}, backoff.WithContext(backoff.WithMaxRetries(&boff, uint64(w.maxRetries)), ctx))
+}
+
+func (w *webhookAlert) render(msg *service.Message) ([]byte, error) {
+	if w.template == nil {
+		return w.body.TryBytes(msg)
+	}
+
+	result, err := alertResult(msg)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := w.template.Render(result)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rendered), nil
+}
+
+func (w *webhookAlert) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return backoff.Permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		// Client errors other than rate limiting will not succeed on retry.
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseWebhookAlert(t *testing.T, yaml string) *webhookAlert {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(webhookAlertField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	webhook, err := newWebhookAlertFromConfig(conf.Namespace("webhook"))
+	require.NoError(t, err)
+	return webhook
+}
+
+func TestWebhookAlertRetriesAndTemplatesBody(t *testing.T) {
+	var attempts int32
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "token", r.Header.Get("Authorization"))
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	webhook := parseWebhookAlert(t, `
+webhook:
+  url: `+server.URL+`
+  body: '{"text":"anomaly on ${! json("log_source") }"}'
+  headers:
+    Authorization: token
+  backoff:
+    initial_interval: 1ms
+    max_interval: 1ms
+`)
+	require.NotNil(t, webhook)
+
+	msg := service.NewMessage(nil)
+	msg.SetStructured(map[string]interface{}{"log_source": "fortinet.firewall"})
+
+	require.NoError(t, webhook.Send(context.Background(), msg))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+	assert.Equal(t, `{"text":"anomaly on fortinet.firewall"}`, body)
+}
+
+func TestWebhookAlertClientErrorIsNotRetried(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	webhook := parseWebhookAlert(t, `
+webhook:
+  url: `+server.URL+`
+  backoff:
+    initial_interval: 1ms
+`)
+
+	err := webhook.Send(context.Background(), service.NewMessage([]byte(`{}`)))
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestWebhookAlertDisabledByDefault(t *testing.T) {
+	assert.Nil(t, parseWebhookAlert(t, `webhook: {}`))
+}
+
+func TestWebhookAlertGoTemplate(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer server.Close()
+
+	webhook := parseWebhookAlert(t, `
+webhook:
+  url: `+server.URL+`
+  template: '{"source":{{ json .log_source }},"score":{{ .anomaly_score }}}'
+`)
+
+	require.NoError(t, webhook.Send(context.Background(), testAnomalyMessage()))
+	assert.Equal(t, `{"source":"fortinet.firewall","score":0.9}`, body)
+}
@@ -0,0 +1,290 @@
+package processor
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Formats anomalies are exported in.
+const (
+	anomalyExportCSV     = "csv"
+	anomalyExportParquet = "parquet"
+)
+
+// anomalyExportColumns are the columns preceding the features of an exported
+// anomaly.
+var anomalyExportColumns = []string{
+	"log_source",
+	"tenant",
+	"window_key",
+	"window_start",
+	"window_end",
+	"samples",
+	"anomaly_score",
+	"threshold",
+	"is_anomaly",
+	"alerted",
+	"suppressed_by",
+	"topic",
+	"idempotency_key",
+	"label",
+}
+
+// AnomalyExportOptions filters and formats an anomaly export.
+type AnomalyExportOptions struct {
+	// From and To bound the window end times exported, To exclusive. Zero
+	// values leave the range open.
+	From time.Time
+	To   time.Time
+	// Sources are glob patterns of the log sources exported, all of them
+	// when empty.
+	Sources []string
+	// IncludeNormal exports windows that were not anomalies too.
+	IncludeNormal bool
+	// Format is csv or parquet.
+	Format string
+	// Feedback are the files of analyst labels, joined to the exported
+	// windows by ID.
+	Feedback []io.Reader
+}
+
+// AnomalyExportStats summarises an anomaly export.
+type AnomalyExportStats struct {
+	Records    int
+	Exported   int
+	Unreadable int
+}
+
+// ExportAnomalies writes the anomalies recorded in audit trail files within a
+// time range and set of sources to CSV or Parquet, ordered by window end.
+// Audit files may be gzip compressed, as left by log rotation. Every feature
+// seen in the exported records gets a column, empty or null for records of
+// sources without it. Windows labelled in the feedback files carry their
+// label, unlabelled ones an empty one.
+func ExportAnomalies(audits []io.Reader, w io.Writer, opts AnomalyExportOptions) (AnomalyExportStats, error) {
+	var stats AnomalyExportStats
+	if opts.Format != anomalyExportCSV && opts.Format != anomalyExportParquet {
+		return stats, fmt.Errorf("unknown export format %s", opts.Format)
+	}
+	for _, pattern := range opts.Sources {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return stats, fmt.Errorf("source pattern %s: %w", pattern, err)
+		}
+	}
+
+	labels := map[string]feedbackRecord{}
+	for _, feedback := range opts.Feedback {
+		if err := readFeedbackLabels(feedback, labels); err != nil {
+			return stats, err
+		}
+	}
+
+	var records []auditRecord
+	for _, audit := range audits {
+		err := readAuditRecords(audit, func(rec auditRecord, ok bool) {
+			stats.Records++
+			if !ok {
+				stats.Unreadable++
+				return
+			}
+			if opts.matches(rec) {
+				records = append(records, rec)
+			}
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].WindowEnd.Before(records[j].WindowEnd) })
+
+	features := map[string]bool{}
+	for _, rec := range records {
+		for name := range rec.Features {
+			features[name] = true
+		}
+	}
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var err error
+	if opts.Format == anomalyExportParquet {
+		err = writeAnomaliesParquet(w, records, names, labels)
+	} else {
+		err = writeAnomaliesCSV(w, records, names, labels)
+	}
+	if err != nil {
+		return stats, err
+	}
+	stats.Exported = len(records)
+	return stats, nil
+}
+
+func (o AnomalyExportOptions) matches(rec auditRecord) bool {
+	if !rec.IsAnomaly && !o.IncludeNormal {
+		return false
+	}
+	if !o.From.IsZero() && rec.WindowEnd.Before(o.From) {
+		return false
+	}
+	if !o.To.IsZero() && !rec.WindowEnd.Before(o.To) {
+		return false
+	}
+	if len(o.Sources) == 0 {
+		return true
+	}
+	for _, pattern := range o.Sources {
+		if matched, _ := path.Match(pattern, rec.Source); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// readAuditRecords reads the JSON lines of an audit file. Lines that are not
+// audit records are passed with ok false.
+func readAuditRecords(r io.Reader, fn func(rec auditRecord, ok bool)) error {
+	return readJSONLines(r, func(line []byte) {
+		var rec auditRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.WindowKey == "" {
+			fn(rec, false)
+			return
+		}
+		fn(rec, true)
+	})
+}
+
+// readJSONLines calls fn with every non-empty line of a file, decompressing
+// it when gzipped, as left by log rotation.
+func readJSONLines(r io.Reader, fn func(line []byte)) error {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			fn(line)
+		}
+	}
+	return scanner.Err()
+}
+
+func writeAnomaliesCSV(w io.Writer, records []auditRecord, features []string, labels map[string]feedbackRecord) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(append(append([]string{}, anomalyExportColumns...), features...)); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		row := []string{
+			rec.Source,
+			rec.Tenant,
+			rec.WindowKey,
+			rec.WindowStart.UTC().Format(time.RFC3339Nano),
+			rec.WindowEnd.UTC().Format(time.RFC3339Nano),
+			strconv.Itoa(rec.Samples),
+			strconv.FormatFloat(rec.AnomalyScore, 'g', -1, 64),
+			strconv.FormatFloat(rec.Threshold, 'g', -1, 64),
+			strconv.FormatBool(rec.IsAnomaly),
+			strconv.FormatBool(rec.Alerted),
+			rec.SuppressedBy,
+			rec.Topic,
+			rec.IdempotencyKey,
+			labels[rec.IdempotencyKey].Label,
+		}
+		for _, name := range features {
+			v, exists := rec.Features[name]
+			if !exists {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		if err := out.Write(row); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func writeAnomaliesParquet(w io.Writer, records []auditRecord, features []string, labels map[string]feedbackRecord) error {
+	group := parquet.Group{
+		"log_source":      parquet.String(),
+		"tenant":          parquet.String(),
+		"window_key":      parquet.String(),
+		"window_start":    parquet.Timestamp(parquet.Millisecond),
+		"window_end":      parquet.Timestamp(parquet.Millisecond),
+		"samples":         parquet.Int(64),
+		"anomaly_score":   parquet.Leaf(parquet.DoubleType),
+		"threshold":       parquet.Leaf(parquet.DoubleType),
+		"is_anomaly":      parquet.Leaf(parquet.BooleanType),
+		"alerted":         parquet.Leaf(parquet.BooleanType),
+		"suppressed_by":   parquet.String(),
+		"topic":           parquet.String(),
+		"idempotency_key": parquet.String(),
+		"label":           parquet.String(),
+	}
+	// Features are optional, as sources extract different ones
+	for _, name := range features {
+		group[name] = parquet.Optional(parquet.Leaf(parquet.DoubleType))
+	}
+	schema := parquet.NewSchema("anomalies", group)
+	writer := parquet.NewWriter(w, schema)
+
+	for _, rec := range records {
+		values := map[string]parquet.Value{
+			"log_source":      parquet.ByteArrayValue([]byte(rec.Source)),
+			"tenant":          parquet.ByteArrayValue([]byte(rec.Tenant)),
+			"window_key":      parquet.ByteArrayValue([]byte(rec.WindowKey)),
+			"window_start":    parquet.Int64Value(rec.WindowStart.UnixMilli()),
+			"window_end":      parquet.Int64Value(rec.WindowEnd.UnixMilli()),
+			"samples":         parquet.Int64Value(int64(rec.Samples)),
+			"anomaly_score":   parquet.DoubleValue(rec.AnomalyScore),
+			"threshold":       parquet.DoubleValue(rec.Threshold),
+			"is_anomaly":      parquet.BooleanValue(rec.IsAnomaly),
+			"alerted":         parquet.BooleanValue(rec.Alerted),
+			"suppressed_by":   parquet.ByteArrayValue([]byte(rec.SuppressedBy)),
+			"topic":           parquet.ByteArrayValue([]byte(rec.Topic)),
+			"idempotency_key": parquet.ByteArrayValue([]byte(rec.IdempotencyKey)),
+			"label":           parquet.ByteArrayValue([]byte(labels[rec.IdempotencyKey].Label)),
+		}
+
+		row := make(parquet.Row, len(values)+len(features))
+		for name, v := range values {
+			leaf, _ := schema.Lookup(name)
+			row[leaf.ColumnIndex] = v.Level(0, 0, leaf.ColumnIndex)
+		}
+		for _, name := range features {
+			leaf, _ := schema.Lookup(name)
+			if v, exists := rec.Features[name]; exists {
+				row[leaf.ColumnIndex] = parquet.DoubleValue(v).Level(0, 1, leaf.ColumnIndex)
+			} else {
+				row[leaf.ColumnIndex] = parquet.NullValue().Level(0, 0, leaf.ColumnIndex)
+			}
+		}
+		if _, err := writer.WriteRows([]parquet.Row{row}); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAuditTrail(t *testing.T) string {
+	t.Helper()
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var lines []string
+	add := func(source string, minute int, anomaly bool, features map[string]float64) {
+		b, err := json.Marshal(auditRecord{
+			WindowKey:      source,
+			Source:         source,
+			WindowStart:    start.Add(time.Duration(minute-1) * time.Minute),
+			WindowEnd:      start.Add(time.Duration(minute) * time.Minute),
+			Samples:        10,
+			Features:       features,
+			AnomalyScore:   0.9,
+			Threshold:      0.7,
+			IsAnomaly:      anomaly,
+			Alerted:        anomaly,
+			Topic:          "firewall-anomalies",
+			IdempotencyKey: fmt.Sprintf("%s-%d", source, minute),
+		})
+		require.NoError(t, err)
+		lines = append(lines, string(b))
+	}
+	add("paloalto.firewall", 3, true, map[string]float64{"mean_value": 3})
+	add("fortinet.firewall", 1, true, map[string]float64{"mean_value": 1, "unique_ips": 4})
+	add("fortinet.firewall", 2, false, map[string]float64{"mean_value": 2})
+	add("fortinet.firewall", 90, true, map[string]float64{"mean_value": 90})
+	lines = append(lines, "not an audit record")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestExportAnomaliesCSV(t *testing.T) {
+	var out bytes.Buffer
+	stats, err := ExportAnomalies([]io.Reader{strings.NewReader(testAuditTrail(t))}, &out, AnomalyExportOptions{
+		From:   time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC),
+		Format: anomalyExportCSV,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, AnomalyExportStats{Records: 5, Exported: 2, Unreadable: 1}, stats)
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, append(append([]string{}, anomalyExportColumns...), "mean_value", "unique_ips"), rows[0])
+
+	// Ordered by window end, with the features a source lacks left empty
+	assert.Equal(t, "fortinet.firewall", rows[1][0])
+	assert.Equal(t, "2024-01-15T10:01:00Z", rows[1][4])
+	assert.Equal(t, []string{"1", "4"}, rows[1][len(anomalyExportColumns):])
+	assert.Equal(t, "paloalto.firewall", rows[2][0])
+	assert.Equal(t, []string{"3", ""}, rows[2][len(anomalyExportColumns):])
+}
+
+func TestExportAnomaliesFilters(t *testing.T) {
+	export := func(opts AnomalyExportOptions) int {
+		opts.Format = anomalyExportCSV
+		stats, err := ExportAnomalies([]io.Reader{strings.NewReader(testAuditTrail(t))}, io.Discard, opts)
+		require.NoError(t, err)
+		return stats.Exported
+	}
+	assert.Equal(t, 3, export(AnomalyExportOptions{}))
+	assert.Equal(t, 4, export(AnomalyExportOptions{IncludeNormal: true}))
+	assert.Equal(t, 2, export(AnomalyExportOptions{Sources: []string{"fortinet.*"}}))
+	assert.Equal(t, 1, export(AnomalyExportOptions{Sources: []string{"paloalto.firewall"}}))
+	assert.Equal(t, 1, export(AnomalyExportOptions{From: time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)}))
+
+	_, err := ExportAnomalies(nil, io.Discard, AnomalyExportOptions{Format: "xlsx"})
+	assert.Error(t, err)
+	_, err = ExportAnomalies(nil, io.Discard, AnomalyExportOptions{Format: anomalyExportCSV, Sources: []string{"["}})
+	assert.Error(t, err)
+}
+
+func TestExportAnomaliesFeedback(t *testing.T) {
+	feedback := `{"window_id":"fortinet.firewall-1","label":"false_positive","labelled_at":"2024-01-15T12:00:00Z"}
+{"window_id":"paloalto.firewall-3","label":"true_positive","labelled_at":"2024-01-15T12:00:00Z"}
+`
+	var out bytes.Buffer
+	_, err := ExportAnomalies([]io.Reader{strings.NewReader(testAuditTrail(t))}, &out, AnomalyExportOptions{
+		Format:   anomalyExportCSV,
+		Feedback: []io.Reader{strings.NewReader(feedback)},
+	})
+	require.NoError(t, err)
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	label := len(anomalyExportColumns) - 1
+	assert.Equal(t, "label", rows[0][label])
+	assert.Equal(t, feedbackFalsePositive, rows[1][label])
+	assert.Equal(t, feedbackTruePositive, rows[2][label])
+	assert.Equal(t, "", rows[3][label])
+}
+
+func TestExportAnomaliesParquet(t *testing.T) {
+	// Rotated audit files are gzipped
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(testAuditTrail(t)))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	var out bytes.Buffer
+	stats, err := ExportAnomalies([]io.Reader{&compressed}, &out, AnomalyExportOptions{Format: anomalyExportParquet})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Exported)
+
+	type row struct {
+		LogSource string    `parquet:"log_source"`
+		WindowEnd time.Time `parquet:"window_end,timestamp(millisecond)"`
+		Alerted   bool      `parquet:"alerted"`
+		MeanValue float64   `parquet:"mean_value"`
+		UniqueIPs *float64  `parquet:"unique_ips,optional"`
+	}
+	rows, err := parquet.Read[row](bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, "fortinet.firewall", rows[0].LogSource)
+	assert.True(t, rows[0].Alerted)
+	assert.Equal(t, 1.0, rows[0].MeanValue)
+	require.NotNil(t, rows[0].UniqueIPs)
+	assert.Equal(t, 4.0, *rows[0].UniqueIPs)
+	assert.Equal(t, "paloalto.firewall", rows[1].LogSource)
+	assert.Nil(t, rows[1].UniqueIPs)
+	assert.Equal(t, 90.0, rows[2].MeanValue)
+}
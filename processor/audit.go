@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func auditField() *service.ConfigField {
+	return service.NewObjectField("audit",
+		service.NewStringField("path").
+			Description("File every detection decision is appended to as a JSON line").
+			Default(""),
+		service.NewStringField("output").
+			Description("Name of an output resource every detection decision is written to, e.g. a dedicated audit topic").
+			Default(""),
+	).
+		Description("Audit trail recording the features, score, threshold and routing of every scored window. Disabled unless `path` or `output` is set.").
+		Advanced()
+}
+
+// auditRecord is the audit trail entry of one scored window.
+type auditRecord struct {
+	AuditedAt      time.Time          `json:"audited_at"`
+	WindowKey      string             `json:"window_key"`
+	Source         string             `json:"log_source"`
+	Tenant         string             `json:"tenant,omitempty"`
+	WindowStart    time.Time          `json:"window_start"`
+	WindowEnd      time.Time          `json:"window_end"`
+	Samples        int                `json:"samples"`
+	Features       map[string]float64 `json:"features"`
+	AnomalyScore   float64            `json:"anomaly_score"`
+	Threshold      float64            `json:"threshold"`
+	IsAnomaly      bool               `json:"is_anomaly"`
+	Suppressed     bool               `json:"suppressed"`
+	SuppressedBy   string             `json:"suppressed_by,omitempty"`
+	Alerted        bool               `json:"alerted"`
+	Topic          string             `json:"topic"`
+	Final          bool               `json:"final,omitempty"`
+	IdempotencyKey string             `json:"idempotency_key"`
+}
+
+// auditTrail writes detection decisions to a JSON lines file and/or an
+// output resource.
+type auditTrail struct {
+	resources *service.Resources
+	output    string
+
+	mut  sync.Mutex
+	file *os.File
+}
+
+func newAuditTrailFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*auditTrail, error) {
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return nil, err
+	}
+	output, err := conf.FieldString("output")
+	if err != nil {
+		return nil, err
+	}
+	if path == "" && output == "" {
+		return nil, nil
+	}
+
+	a := &auditTrail{resources: mgr, output: output}
+	if path != "" {
+		if a.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// record appends a decision to the audit trail.
+func (a *auditTrail) record(ctx context.Context, rec auditRecord) error {
+	if a == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if a.file != nil {
+		a.mut.Lock()
+		_, err = a.file.Write(append(line, '\n'))
+		a.mut.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.output != "" {
+		msg := service.NewMessage(line)
+		msg.MetaSet("window_key", rec.WindowKey)
+		msg.MetaSet("idempotency_key", rec.IdempotencyKey)
+
+		var writeErr error
+		if err := a.resources.AccessOutput(ctx, a.output, func(o *service.ResourceOutput) {
+			writeErr = o.Write(ctx, msg)
+		}); err != nil {
+			return err
+		}
+		return writeErr
+	}
+	return nil
+}
+
+// Close closes the audit file.
+func (a *auditTrail) Close() error {
+	if a == nil || a.file == nil {
+		return nil
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	return a.file.Close()
+}
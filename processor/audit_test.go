@@ -0,0 +1,78 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditTrailFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	spec := service.NewConfigSpec().Field(auditField())
+	conf, err := spec.ParseYAML(`audit: { path: `+path+` }`, nil)
+	require.NoError(t, err)
+	audit, err := newAuditTrailFromConfig(conf.Namespace("audit"), service.MockResources())
+	require.NoError(t, err)
+
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		windows:        make(map[string]*WindowData),
+		audit:          audit,
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 20, "192.168.1.2", now.Add(time.Second))
+
+	_, err = detector.scoreWindow(context.Background(), "fortinet.firewall", detector.getWindow("fortinet.firewall"), "connection_count", 20, false)
+	require.NoError(t, err)
+	require.NoError(t, audit.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []auditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec auditRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		records = append(records, rec)
+	}
+	require.Len(t, records, 1)
+
+	rec := records[0]
+	assert.Equal(t, "fortinet.firewall", rec.WindowKey)
+	assert.Equal(t, "fortinet.firewall", rec.Source)
+	assert.Equal(t, 2, rec.Samples)
+	assert.Equal(t, 0.7, rec.Threshold)
+	assert.False(t, rec.IsAnomaly)
+	assert.False(t, rec.Alerted)
+	assert.Equal(t, "firewall-normal", rec.Topic)
+	assert.Equal(t, 15.0, rec.Features["mean_value"])
+	assert.NotEmpty(t, rec.IdempotencyKey)
+}
+
+func TestAuditTrailDisabled(t *testing.T) {
+	spec := service.NewConfigSpec().Field(auditField())
+	conf, err := spec.ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	audit, err := newAuditTrailFromConfig(conf.Namespace("audit"), service.MockResources())
+	require.NoError(t, err)
+	assert.Nil(t, audit)
+	assert.NoError(t, audit.record(context.Background(), auditRecord{}))
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func backpressureField() *service.ConfigField {
+	return service.NewObjectField("backpressure",
+		service.NewIntField("max_in_flight").
+			Description("Maximum number of logs in flight, buffered in open windows or awaiting the write of their results by `async_emission`. Consumption from Redis pauses while the budget is exhausted. Requires `consumption.mode: ack`, as `peek` reads rewind to the head of the log list. Zero disables the budget. Defaults to zero, or the value of `profile` in `ack` mode.").
+			Optional(),
+		service.NewFloatField("resume_ratio").
+			Description("Consumption resumes once logs in flight drop below this fraction of `max_in_flight`").
+			Default(0.8),
+	).
+		Description("Bounds the number of logs held in memory when downstream outputs fall behind").
+		Advanced()
+}
+
+// consumptionBudget throttles reads from Redis based on how many logs are in
+// flight, buffered in open windows or awaiting the output. It pauses at the budget and only resumes below
+// a lower watermark so that consumption does not flap.
+type consumptionBudget struct {
+	maxInFlight int
+	resumeAt    int
+
+	mut    sync.Mutex
+	paused bool
+}
+
+func newConsumptionBudgetFromConfig(conf *service.ParsedConfig, consumptionMode string, p profile) (*consumptionBudget, error) {
+	maxInFlight, err := fieldIntOr(conf, p.maxInFlight, "max_in_flight")
+	if err != nil {
+		return nil, err
+	}
+	if maxInFlight <= 0 {
+		return nil, nil
+	}
+
+	// Bounded peek reads return the same head of the log list every cycle,
+	// so the budget would stall consumption rather than pace it. The budget
+	// of a profile is left out in peek mode.
+	if consumptionMode != consumptionModeAck {
+		if conf.Contains("max_in_flight") {
+			return nil, errors.New("backpressure.max_in_flight requires consumption.mode ack")
+		}
+		return nil, nil
+	}
+
+	resumeRatio, err := conf.FieldFloat("resume_ratio")
+	if err != nil {
+		return nil, err
+	}
+	if resumeRatio <= 0 || resumeRatio > 1 {
+		return nil, errors.New("backpressure resume_ratio must be in (0, 1]")
+	}
+
+	return &consumptionBudget{
+		maxInFlight: maxInFlight,
+		resumeAt:    int(float64(maxInFlight) * resumeRatio),
+	}, nil
+}
+
+// allowance returns how many more logs may be read given the number already
+// in flight, and whether consumption changed between paused and resumed. A
+// negative allowance means reads are unbounded.
+func (b *consumptionBudget) allowance(inFlight int) (n int, changed bool) {
+	if b == nil {
+		return -1, false
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	wasPaused := b.paused
+	if b.paused {
+		b.paused = inFlight >= b.resumeAt
+	} else {
+		b.paused = inFlight >= b.maxInFlight
+	}
+	if b.paused {
+		return 0, !wasPaused
+	}
+	return b.maxInFlight - inFlight, wasPaused
+}
+
+// bufferedLogs returns the number of logs held in open windows.
+func (f *FirewallAnomalyDetector) bufferedLogs() int {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	n := 0
+	for _, window := range f.windows {
+		n += len(window.Values)
+	}
+	return n
+}
+
+// readAllowance returns how many logs the next read may consume, or a
+// negative number when reads are unbounded.
+func (f *FirewallAnomalyDetector) readAllowance() int {
+	if f.budget == nil {
+		return -1
+	}
+
+	inFlight := f.bufferedLogs() + f.emitter.heldLogs()
+	n, changed := f.budget.allowance(inFlight)
+	if changed {
+		if n == 0 {
+			f.logger.Warnf("Pausing log consumption with %d logs in flight", inFlight)
+			f.consumptionPaused.Set(1)
+		} else {
+			f.logger.Infof("Resuming log consumption with %d logs in flight", inFlight)
+			f.consumptionPaused.Set(0)
+		}
+	}
+	return n
+}
@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsumptionBudgetPausesAndResumes(t *testing.T) {
+	spec := service.NewConfigSpec().Field(backpressureField())
+	conf, err := spec.ParseYAML(`
+backpressure:
+  max_in_flight: 100
+  resume_ratio: 0.5
+`, nil)
+	require.NoError(t, err)
+
+	budget, err := newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumptionModeAck, profiles[profileNone])
+	require.NoError(t, err)
+
+	n, changed := budget.allowance(40)
+	assert.Equal(t, 60, n)
+	assert.False(t, changed)
+
+	n, changed = budget.allowance(100)
+	assert.Equal(t, 0, n)
+	assert.True(t, changed)
+
+	// Stays paused until below the resume watermark.
+	n, changed = budget.allowance(70)
+	assert.Equal(t, 0, n)
+	assert.False(t, changed)
+
+	n, changed = budget.allowance(49)
+	assert.Equal(t, 51, n)
+	assert.True(t, changed)
+}
+
+func TestConsumptionBudgetDisabledByDefault(t *testing.T) {
+	spec := service.NewConfigSpec().Field(backpressureField())
+	conf, err := spec.ParseYAML(`backpressure: {}`, nil)
+	require.NoError(t, err)
+
+	budget, err := newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumptionModeAck, profiles[profileNone])
+	require.NoError(t, err)
+	assert.Nil(t, budget)
+
+	n, _ := budget.allowance(1 << 20)
+	assert.Equal(t, -1, n)
+}
+
+func TestConsumptionBudgetRequiresAckMode(t *testing.T) {
+	spec := service.NewConfigSpec().Field(backpressureField())
+	conf, err := spec.ParseYAML(`backpressure: { max_in_flight: 100 }`, nil)
+	require.NoError(t, err)
+
+	_, err = newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumptionModePeek, profiles[profileNone])
+	assert.ErrorContains(t, err, "backpressure.max_in_flight requires consumption.mode ack")
+
+	// The budget of a profile only applies in ack mode
+	conf, err = spec.ParseYAML(`backpressure: {}`, nil)
+	require.NoError(t, err)
+	budget, err := newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumptionModePeek, profiles[profileBranchOffice])
+	require.NoError(t, err)
+	assert.Nil(t, budget)
+}
+
+func TestReadAllowanceCountsHeldResults(t *testing.T) {
+	budget := &consumptionBudget{maxInFlight: 10, resumeAt: 8}
+	detector := &FirewallAnomalyDetector{
+		logger:  service.MockResources().Logger(),
+		windows: map[string]*WindowData{"fortinet.firewall": {Values: []float64{1, 2, 3}}},
+		budget:  budget,
+		emitter: &resultEmitter{},
+	}
+	assert.Equal(t, 7, detector.readAllowance())
+
+	// Logs whose results await the output count until they are written
+	msg := service.NewMessage(nil)
+	detector.emitter.hold(msg, []string{"a", "b", "c", "d", "e", "f", "g"}, nil)
+	assert.Equal(t, 0, detector.readAllowance())
+
+	detector.emitter.settle([]*service.Message{msg}, true)
+	assert.Equal(t, 7, detector.readAllowance())
+}
@@ -0,0 +1,256 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"gonum.org/v1/gonum/stat"
+)
+
+func baselineCacheField() *service.ConfigField {
+	return service.NewObjectField("baseline_cache",
+		service.NewStringField("name").
+			Description("Name the baselines of this detector are published under, read by `firewall_anomaly_baselines` cache resources whose `detector` is set to it. Empty publishes nothing.").
+			Default(""),
+		service.NewFloatField("smoothing").
+			Description("Weight of each scored window in the moving mean and standard deviation of its baseline").
+			Default(0.1),
+	).
+		Description("Per-source baselines of scored windows, exposed to other components of the pipeline through the `firewall_anomaly_baselines` cache").
+		Advanced()
+}
+
+func baselineCacheConfigSpec() *service.ConfigSpec {
+	return service.NewConfigSpec().
+		Beta().
+		Summary("Reads the per-source baselines of a firewall_anomaly_detector processor").
+		Description(`
+A read-only cache serving the baselines published by a firewall_anomaly_detector processor with
+` + "`baseline_cache.name`" + ` set, so that other processors of the pipeline, such as a ` + "`cache`" + ` processor
+followed by a Bloblang mapping, can compare logs against them. Keys are window keys: the log
+source, prefixed by ` + "`<tenant>/`" + ` when ` + "`tenant_field`" + ` is set. Values are JSON objects.
+
+Keys of sources that were never scored, or of a detector that is not running, do not exist.
+Writes and deletes fail.`).
+		Field(service.NewStringField("detector").
+			Description("The `baseline_cache.name` of the detector whose baselines are read"))
+}
+
+// baselineStores holds the baselines published by running detectors, by
+// name, as caches are built separately from the detectors they read.
+var baselineStores sync.Map // name -> *baselineStore
+
+// sourceBaseline is the baseline of a window key, as served by the cache.
+type sourceBaseline struct {
+	LogSource string `json:"log_source"`
+	Tenant    string `json:"tenant,omitempty"`
+	// Windows is the number of windows scored
+	Windows int `json:"windows"`
+	// Mean and StdDev are moving statistics of the metric mean of the
+	// windows, weighted by smoothing
+	Mean         float64            `json:"mean"`
+	StdDev       float64            `json:"std_dev"`
+	LastFeatures map[string]float64 `json:"last_features"`
+	LastScore    float64            `json:"last_score"`
+	Threshold    float64            `json:"threshold"`
+	WindowEnd    time.Time          `json:"window_end"`
+
+	variance float64
+	updated  time.Time
+}
+
+// baselineStore keeps the baselines of the window keys of a detector.
+type baselineStore struct {
+	name      string
+	smoothing float64
+
+	mut       sync.Mutex
+	baselines map[string]*sourceBaseline
+}
+
+func newBaselineStoreFromConfig(conf *service.ParsedConfig) (*baselineStore, error) {
+	name, err := conf.FieldString("name")
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return nil, nil
+	}
+	smoothing, err := conf.FieldFloat("smoothing")
+	if err != nil {
+		return nil, err
+	}
+	if smoothing <= 0 || smoothing > 1 {
+		return nil, fmt.Errorf("baseline_cache.smoothing must be greater than 0 and at most 1, got %v", smoothing)
+	}
+	return &baselineStore{name: name, smoothing: smoothing, baselines: make(map[string]*sourceBaseline)}, nil
+}
+
+// publish makes the store readable by caches. Names are unique among the
+// running detectors.
+func (b *baselineStore) publish() error {
+	if b == nil {
+		return nil
+	}
+	if _, loaded := baselineStores.LoadOrStore(b.name, b); loaded {
+		return fmt.Errorf("baseline_cache.name %s is already published by another detector", b.name)
+	}
+	return nil
+}
+
+func (b *baselineStore) unpublish() {
+	if b != nil {
+		baselineStores.CompareAndDelete(b.name, b)
+	}
+}
+
+// observe folds a scored window into the baseline of its key.
+func (b *baselineStore) observe(key, source, tenant string, window *WindowData, features map[string]float64, score, threshold float64) {
+	if b == nil {
+		return
+	}
+	mean := 0.0
+	if len(window.Values) > 0 {
+		mean = stat.Mean(window.Values, nil)
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	baseline, exists := b.baselines[key]
+	if !exists {
+		baseline = &sourceBaseline{LogSource: source, Tenant: tenant, Mean: mean}
+		b.baselines[key] = baseline
+	}
+	// Exponentially weighted mean and variance
+	diff := mean - baseline.Mean
+	incr := b.smoothing * diff
+	baseline.Mean += incr
+	baseline.variance = (1 - b.smoothing) * (baseline.variance + diff*incr)
+	baseline.StdDev = math.Sqrt(baseline.variance)
+
+	baseline.Windows++
+	baseline.LastFeatures = features
+	baseline.LastScore = score
+	baseline.Threshold = threshold
+	baseline.WindowEnd = window.EndTime
+	baseline.updated = time.Now()
+}
+
+// snapshot returns a copy of the baselines for a state snapshot.
+func (b *baselineStore) snapshot() map[string]*sourceBaseline {
+	if b == nil {
+		return nil
+	}
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	baselines := make(map[string]*sourceBaseline, len(b.baselines))
+	for key, baseline := range b.baselines {
+		c := *baseline
+		baselines[key] = &c
+	}
+	return baselines
+}
+
+// restore loads the baselines of a state snapshot.
+func (b *baselineStore) restore(baselines map[string]*sourceBaseline) {
+	if b == nil {
+		return
+	}
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := time.Now()
+	for key, baseline := range baselines {
+		baseline.variance = baseline.StdDev * baseline.StdDev
+		baseline.updated = now
+		b.baselines[key] = baseline
+	}
+}
+
+// get returns the JSON baseline of a window key.
+func (b *baselineStore) get(key string) ([]byte, bool) {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	baseline, exists := b.baselines[key]
+	if !exists {
+		return nil, false
+	}
+	data, err := json.Marshal(baseline)
+	return data, err == nil
+}
+
+// expire removes the baselines of window keys not scored since cutoff.
+func (b *baselineStore) expire(cutoff time.Time) int {
+	if b == nil {
+		return 0
+	}
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	n := 0
+	for key, baseline := range b.baselines {
+		if baseline.updated.Before(cutoff) {
+			delete(b.baselines, key)
+			n++
+		}
+	}
+	return n
+}
+
+// errBaselineCacheReadOnly is returned for writes to a baseline cache.
+var errBaselineCacheReadOnly = errors.New("firewall_anomaly_baselines caches are read only")
+
+// baselineCache is a cache resource reading the baselines a detector
+// publishes. The detector is looked up on every read, as it is built after
+// the cache resources and may be restarted.
+type baselineCache struct {
+	detector string
+}
+
+func newBaselineCacheFromConfig(conf *service.ParsedConfig) (*baselineCache, error) {
+	detector, err := conf.FieldString("detector")
+	if err != nil {
+		return nil, err
+	}
+	if detector == "" {
+		return nil, errors.New("detector must not be empty")
+	}
+	return &baselineCache{detector: detector}, nil
+}
+
+func (c *baselineCache) Get(ctx context.Context, key string) ([]byte, error) {
+	store, published := baselineStores.Load(c.detector)
+	if !published {
+		return nil, service.ErrKeyNotFound
+	}
+	data, exists := store.(*baselineStore).get(key)
+	if !exists {
+		return nil, service.ErrKeyNotFound
+	}
+	return data, nil
+}
+
+func (c *baselineCache) Set(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	return errBaselineCacheReadOnly
+}
+
+func (c *baselineCache) Add(ctx context.Context, key string, value []byte, ttl *time.Duration) error {
+	return errBaselineCacheReadOnly
+}
+
+func (c *baselineCache) Delete(ctx context.Context, key string) error {
+	return errBaselineCacheReadOnly
+}
+
+func (c *baselineCache) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselineCacheConfig(t *testing.T) {
+	parse := func(yaml string) (*baselineStore, error) {
+		spec := service.NewConfigSpec().Field(baselineCacheField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newBaselineStoreFromConfig(conf.Namespace("baseline_cache"))
+	}
+
+	b, err := parse(`baseline_cache: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = parse(`baseline_cache: { name: edge, smoothing: 0.5 }`)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	assert.Equal(t, "edge", b.name)
+	assert.Equal(t, 0.5, b.smoothing)
+
+	_, err = parse(`baseline_cache: { name: edge, smoothing: 0 }`)
+	assert.Error(t, err)
+
+	conf, err := baselineCacheConfigSpec().ParseYAML(`detector: ""`, nil)
+	require.NoError(t, err)
+	_, err = newBaselineCacheFromConfig(conf)
+	assert.Error(t, err)
+}
+
+func TestBaselineObserve(t *testing.T) {
+	b := &baselineStore{name: "edge", smoothing: 0.5, baselines: make(map[string]*sourceBaseline)}
+	end := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+
+	b.observe("acme/fortinet.firewall", "fortinet.firewall", "acme", &WindowData{Values: []float64{10, 30}, EndTime: end}, map[string]float64{"mean_value": 20}, 0.2, 0.7)
+	b.observe("acme/fortinet.firewall", "fortinet.firewall", "acme", &WindowData{Values: []float64{40}, EndTime: end.Add(time.Minute)}, map[string]float64{"mean_value": 40}, 0.4, 0.7)
+
+	// The first window sets the mean, the second moves it halfway
+	baseline := b.baselines["acme/fortinet.firewall"]
+	assert.Equal(t, 2, baseline.Windows)
+	assert.InDelta(t, 30, baseline.Mean, 1e-9)
+	assert.InDelta(t, 10, baseline.StdDev, 1e-9)
+	assert.Equal(t, map[string]float64{"mean_value": 40}, baseline.LastFeatures)
+	assert.Equal(t, 0.4, baseline.LastScore)
+	assert.Equal(t, end.Add(time.Minute), baseline.WindowEnd)
+}
+
+func TestBaselineCache(t *testing.T) {
+	ctx := context.Background()
+	cache := &baselineCache{detector: "edge"}
+
+	// Nothing is served until the detector publishes its baselines
+	_, err := cache.Get(ctx, "fortinet.firewall")
+	assert.ErrorIs(t, err, service.ErrKeyNotFound)
+
+	b := &baselineStore{name: "edge", smoothing: 0.1, baselines: make(map[string]*sourceBaseline)}
+	require.NoError(t, b.publish())
+	defer b.unpublish()
+	other := &baselineStore{name: "edge", smoothing: 0.1, baselines: make(map[string]*sourceBaseline)}
+	assert.ErrorContains(t, other.publish(), "already published")
+
+	_, err = cache.Get(ctx, "fortinet.firewall")
+	assert.ErrorIs(t, err, service.ErrKeyNotFound)
+
+	b.observe("fortinet.firewall", "fortinet.firewall", "", &WindowData{Values: []float64{5}}, map[string]float64{"mean_value": 5}, 0.1, 0.7)
+	data, err := cache.Get(ctx, "fortinet.firewall")
+	require.NoError(t, err)
+	var baseline map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &baseline))
+	assert.Equal(t, "fortinet.firewall", baseline["log_source"])
+	assert.Equal(t, 5.0, baseline["mean"])
+	assert.Equal(t, 1.0, baseline["windows"])
+	assert.NotContains(t, baseline, "tenant")
+
+	assert.ErrorIs(t, cache.Set(ctx, "fortinet.firewall", data, nil), errBaselineCacheReadOnly)
+	assert.ErrorIs(t, cache.Delete(ctx, "fortinet.firewall"), errBaselineCacheReadOnly)
+
+	// Other detectors are not affected by unpublishing a conflicting one
+	other.unpublish()
+	_, err = cache.Get(ctx, "fortinet.firewall")
+	assert.NoError(t, err)
+}
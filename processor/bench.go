@@ -0,0 +1,193 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"gonum.org/v1/gonum/stat"
+)
+
+// benchConfig is benchmarked when no config is given: every generated source
+// falls back to the default source.
+const benchConfig = `
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        default_source:
+          metric: connection_count
+`
+
+// BenchOptions describes the traffic generated for a benchmark.
+type BenchOptions struct {
+	// Logs is the number of logs generated.
+	Logs int
+	// Sources is the number of sources generated for configs with a default
+	// source. Configs without one get traffic for their exact sources only.
+	Sources int
+	// IPs is the number of distinct source IPs per source.
+	IPs int
+	// EventsPerSecond is the rate of the logs in event time, which with the
+	// window length determines how many windows they fill.
+	EventsPerSecond int
+	// AnomalyRatio is the fraction of logs carrying a spike.
+	AnomalyRatio float64
+}
+
+// DefaultBenchOptions are the options of the bench command.
+var DefaultBenchOptions = BenchOptions{
+	Logs:            200000,
+	Sources:         100,
+	IPs:             50,
+	EventsPerSecond: 1000,
+	AnomalyRatio:    0.001,
+}
+
+// BenchStats are the measurements of a benchmark.
+type BenchStats struct {
+	Logs    int
+	Windows int
+	Elapsed time.Duration
+
+	LogsPerSecond    float64
+	WindowsPerSecond float64
+	AllocsPerLog     float64
+	BytesPerLog      float64
+
+	ScoringP50 time.Duration
+	ScoringP99 time.Duration
+}
+
+// Bench measures the throughput, allocations and scoring latency of the
+// first firewall_anomaly_detector processor of a config, or of a default
+// config when confYAML is empty, over generated traffic. Logs are parsed,
+// windowed and scored like in a replay, on an event time clock with external
+// systems disabled, so that only the detector itself is measured. Windows
+// still open once every log is processed are scored as part of the run.
+func Bench(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), opts BenchOptions) (BenchStats, error) {
+	var stats BenchStats
+	if opts.Logs <= 0 || opts.EventsPerSecond <= 0 || opts.IPs <= 0 {
+		return stats, errors.New("logs, events per second and IPs must be positive")
+	}
+	if len(confYAML) == 0 {
+		confYAML = []byte(benchConfig)
+	}
+
+	detector, err := newOfflineDetector(env, confYAML, lookupEnv)
+	if err != nil {
+		return stats, err
+	}
+	defer detector.Close(ctx)
+
+	sources, err := detector.benchSources(opts.Sources)
+	if err != nil {
+		return stats, err
+	}
+
+	// Logs are generated upfront so that generating them is not measured
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	logs := generateBenchLogs(opts, sources, start)
+
+	var watermark time.Time
+	detector.clock = func() time.Time { return watermark }
+	latencies := make([]float64, 0, 1024)
+	detector.onScored = func(latency time.Duration) {
+		latencies = append(latencies, float64(latency))
+	}
+	countWindow := func(*service.Message) error {
+		stats.Windows++
+		return nil
+	}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	began := time.Now()
+
+	for _, item := range logs {
+		log, ok := detector.parseLog(ctx, item)
+		if !ok {
+			continue
+		}
+		if log.Timestamp.After(watermark) {
+			watermark = log.Timestamp
+		}
+		msg, err := detector.processLog(ctx, log)
+		if err != nil {
+			return stats, err
+		}
+		if msg != nil {
+			stats.Windows++
+		}
+	}
+	if err := detector.flushRemaining(ctx, countWindow); err != nil {
+		return stats, err
+	}
+
+	stats.Elapsed = time.Since(began)
+	runtime.ReadMemStats(&after)
+
+	stats.Logs = len(logs)
+	stats.LogsPerSecond = float64(stats.Logs) / stats.Elapsed.Seconds()
+	stats.WindowsPerSecond = float64(stats.Windows) / stats.Elapsed.Seconds()
+	stats.AllocsPerLog = float64(after.Mallocs-before.Mallocs) / float64(stats.Logs)
+	stats.BytesPerLog = float64(after.TotalAlloc-before.TotalAlloc) / float64(stats.Logs)
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		stats.ScoringP50 = time.Duration(stat.Quantile(0.5, stat.Empirical, latencies, nil))
+		stats.ScoringP99 = time.Duration(stat.Quantile(0.99, stat.Empirical, latencies, nil))
+	}
+	return stats, nil
+}
+
+// benchSources returns the sources traffic is generated for: the exact
+// sources of the config, topped up to n generated ones when it has a
+// default source.
+func (f *FirewallAnomalyDetector) benchSources(n int) ([]string, error) {
+	f.settingsMut.RLock()
+	var names []string
+	for key := range f.sources {
+		if key != defaultSourceKey && !isSourcePattern(key) {
+			names = append(names, key)
+		}
+	}
+	_, hasDefault := f.sources[defaultSourceKey]
+	f.settingsMut.RUnlock()
+
+	sort.Strings(names)
+	if hasDefault {
+		for i := len(names); i < n; i++ {
+			names = append(names, fmt.Sprintf("bench.source.%d", i))
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("config has no exact sources or default_source to generate traffic for")
+	}
+	return names, nil
+}
+
+// generateBenchLogs generates JSON logs spread evenly over the sources in
+// event time, setting every metric field so that any source configuration
+// extracts a value. The traffic is the same for every run.
+func generateBenchLogs(opts BenchOptions, sources []string, start time.Time) []string {
+	rng := rand.New(rand.NewSource(1))
+	interval := time.Second / time.Duration(opts.EventsPerSecond)
+
+	logs := make([]string, opts.Logs)
+	for i := range logs {
+		count := 10 + rng.Intn(10)
+		if rng.Float64() < opts.AnomalyRatio {
+			count *= 50
+		}
+		ip := rng.Intn(opts.IPs)
+		logs[i] = fmt.Sprintf(`{"timestamp":%q,"log_source":%q,"source_ip":"10.%d.%d.%d","dest_ip":"192.168.0.1","connection_count":%d,"bytes_sent":%d,"bytes_recv":%d,"action":"allow","severity":"info"}`,
+			start.Add(time.Duration(i)*interval).Format(time.RFC3339Nano), sources[i%len(sources)],
+			ip>>16&0xff, ip>>8&0xff, ip&0xff, count, count*1500, count*900)
+	}
+	return logs
+}
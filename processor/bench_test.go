@@ -0,0 +1,177 @@
+package processor
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestBench(t *testing.T) {
+	stats, err := Bench(context.Background(), service.NewEnvironment(), nil, os.LookupEnv, BenchOptions{
+		Logs:            2000,
+		Sources:         5,
+		IPs:             10,
+		EventsPerSecond: 10,
+		AnomalyRatio:    0.01,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2000, stats.Logs)
+	assert.GreaterOrEqual(t, stats.Windows, 5)
+	assert.Positive(t, stats.LogsPerSecond)
+	assert.Positive(t, stats.AllocsPerLog)
+	assert.Positive(t, stats.ScoringP99)
+	assert.LessOrEqual(t, stats.ScoringP50, stats.ScoringP99)
+}
+
+func TestBenchSources(t *testing.T) {
+	detector := &FirewallAnomalyDetector{sources: map[string]string{
+		"fortinet.firewall": "connection_count",
+		"paloalto.*":        "bytes_sent",
+	}}
+	sources, err := detector.benchSources(3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fortinet.firewall"}, sources)
+
+	detector.sources[defaultSourceKey] = "connection_count"
+	sources, err = detector.benchSources(3)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fortinet.firewall", "bench.source.1", "bench.source.2"}, sources)
+
+	_, err = (&FirewallAnomalyDetector{}).benchSources(3)
+	assert.Error(t, err)
+}
+
+func newBenchDetector(b *testing.B) *FirewallAnomalyDetector {
+	b.Helper()
+
+	detector, err := newOfflineDetector(service.NewEnvironment(), []byte(benchConfig), os.LookupEnv)
+	require.NoError(b, err)
+	b.Cleanup(func() { _ = detector.Close(context.Background()) })
+	return detector
+}
+
+func BenchmarkParseLog(b *testing.B) {
+	detector := newBenchDetector(b)
+	logs := generateBenchLogs(DefaultBenchOptions, []string{"bench.source.0"}, time.Now())
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		detector.parseLog(context.Background(), logs[i%len(logs)])
+	}
+}
+
+func BenchmarkProcessLog(b *testing.B) {
+	detector := newBenchDetector(b)
+	opts := DefaultBenchOptions
+	opts.Logs = b.N
+	logs := generateBenchLogs(opts, []string{"bench.source.0", "bench.source.1"}, time.Now())
+
+	parsed := make([]FirewallLog, len(logs))
+	for i, item := range logs {
+		parsed[i], _ = detector.parseLog(context.Background(), item)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range parsed {
+		if _, err := detector.processLog(context.Background(), parsed[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkExtractFeatures(b *testing.B) {
+	detector := newBenchDetector(b)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		detector.updateWindow("bench.source.0", float64(i%20), "10.0.0."+string(rune('0'+i%10)), now)
+	}
+	window := detector.getWindow("bench.source.0")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		detector.scoreFeatures(detector.extractFeatures(window))
+	}
+}
+
+// multiPassFeatures computes the mean, standard deviation, max and min of
+// values with a pass over them each, as a reference for metricFeatures.
+func multiPassFeatures(values []float64) metricStats {
+	var stats metricStats
+	stats[featureMeanValue] = stat.Mean(values, nil)
+	stats[featureStdDev] = stat.StdDev(values, nil)
+	stats[featureMaxValue] = floats.Max(values)
+	stats[featureMinValue] = floats.Min(values)
+	return stats
+}
+
+func benchValues(n int, base, spread float64) []float64 {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = base + rng.Float64()*spread
+	}
+	return values
+}
+
+func TestMetricFeaturesMatchMultiPass(t *testing.T) {
+	for _, values := range [][]float64{
+		{10, 20, 30, 40, 50},
+		benchValues(1000, 0, 20),
+		// Byte counters, large and close to each other
+		benchValues(1000, 1e12, 1e3),
+		benchValues(10, -5, 10),
+	} {
+		got := metricFeatures(values, 0, 0)
+		want := multiPassFeatures(values)
+		assert.InEpsilon(t, want[featureMeanValue], got[featureMeanValue], 1e-12)
+		assert.InEpsilon(t, want[featureStdDev], got[featureStdDev], 1e-9)
+		assert.Equal(t, want[featureMaxValue], got[featureMaxValue])
+		assert.Equal(t, want[featureMinValue], got[featureMinValue])
+	}
+}
+
+func BenchmarkMetricFeatures(b *testing.B) {
+	values := benchValues(1000, 0, 20)
+
+	b.Run("single_pass", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			metricFeatures(values, 10, 10)
+		}
+	})
+	b.Run("multi_pass", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			multiPassFeatures(values)
+		}
+	})
+}
+
+func BenchmarkScoreWindow(b *testing.B) {
+	detector := newBenchDetector(b)
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		detector.updateWindow("bench.source.0", float64(i%20), "10.0.0."+string(rune('0'+i%10)), now)
+	}
+	window := detector.getWindow("bench.source.0")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := detector.scoreWindow(context.Background(), "bench.source.0", window, "connection_count", 10, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func clockSkewField() *service.ConfigField {
+	return service.NewObjectField("clock_skew",
+		service.NewDurationField("threshold").
+			Description("Skew between the event time of the logs of a log source and the time they are read beyond which a warning is logged and, with `correct`, their event times are corrected. Zero disables skew tracking.").
+			Default("0s"),
+		service.NewBoolField("correct").
+			Description("Shift the event times of log sources skewed beyond `threshold` by their skew, so that their logs land in the windows of the time they were read").
+			Default(false),
+		service.NewFloatField("smoothing").
+			Description("Weight of each log in the moving average of the skew of its source, between 0 and 1. Lower values ride out transport delays and bursts of old logs.").
+			Default(0.05),
+	).
+		Description("Per-source tracking of devices logging ahead of or behind the clock of the processor").
+		Advanced()
+}
+
+// clockSkews tracks the skew of each log source as an exponentially weighted
+// moving average of the time its logs were read ahead of or behind their
+// event time. A positive skew is a device logging ahead of the processor.
+type clockSkews struct {
+	threshold time.Duration
+	correct   bool
+	smoothing float64
+
+	mut     sync.Mutex
+	sources map[string]*sourceSkew // log source -> skew
+}
+
+type sourceSkew struct {
+	skew    float64 // nanoseconds
+	warned  bool
+	updated time.Time
+}
+
+func newClockSkewsFromConfig(conf *service.ParsedConfig) (*clockSkews, error) {
+	threshold, err := conf.FieldDuration("threshold")
+	if err != nil {
+		return nil, err
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("clock_skew.threshold must not be negative, got %v", threshold)
+	}
+	if threshold == 0 {
+		return nil, nil
+	}
+
+	c := &clockSkews{threshold: threshold, sources: make(map[string]*sourceSkew)}
+	if c.correct, err = conf.FieldBool("correct"); err != nil {
+		return nil, err
+	}
+	if c.smoothing, err = conf.FieldFloat("smoothing"); err != nil {
+		return nil, err
+	}
+	if c.smoothing <= 0 || c.smoothing > 1 {
+		return nil, fmt.Errorf("clock_skew.smoothing must be in (0, 1], got %v", c.smoothing)
+	}
+	return c, nil
+}
+
+// observe updates the skew of a log source with a log of the given event
+// time read at now. It returns the skew, whether it exceeds the threshold and
+// whether it just crossed the threshold in either direction.
+func (c *clockSkews) observe(source string, eventTime, now time.Time) (skew time.Duration, exceeded, crossed bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	sample := float64(eventTime.Sub(now))
+	s, exists := c.sources[source]
+	if !exists {
+		s = &sourceSkew{skew: sample}
+		c.sources[source] = s
+	} else {
+		s.skew += c.smoothing * (sample - s.skew)
+	}
+	s.updated = now
+
+	skew = time.Duration(s.skew)
+	exceeded = skew > c.threshold || skew < -c.threshold
+	crossed = exceeded != s.warned
+	s.warned = exceeded
+	return skew, exceeded, crossed
+}
+
+// expire removes the skews of log sources not seen since cutoff.
+func (c *clockSkews) expire(cutoff time.Time) int {
+	if c == nil {
+		return 0
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	n := 0
+	for source, s := range c.sources {
+		if s.updated.Before(cutoff) {
+			delete(c.sources, source)
+			n++
+		}
+	}
+	return n
+}
+
+// correctClockSkew tracks the skew of the source of a log read at now and
+// corrects its event time when the source is skewed beyond the threshold.
+func (f *FirewallAnomalyDetector) correctClockSkew(log *FirewallLog, now time.Time) {
+	if f.clockSkews == nil {
+		return
+	}
+
+	labels := f.metricLabels(log.tenant, log.LogSource)
+	skew, exceeded, crossed := f.clockSkews.observe(log.LogSource, log.Timestamp, now)
+	f.clockSkew.Set(skew.Milliseconds(), labels...)
+	switch {
+	case crossed && exceeded:
+		f.logger.Warnf("Log source %s is skewed by %v, beyond %v", log.LogSource, skew, f.clockSkews.threshold)
+	case crossed:
+		f.logger.Infof("Log source %s is back within %v of the clock", log.LogSource, f.clockSkews.threshold)
+	}
+	if exceeded && f.clockSkews.correct {
+		log.Timestamp = log.Timestamp.Add(-skew)
+		f.clockSkewCorrections.Incr(1, labels...)
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockSkewConfig(t *testing.T) {
+	parse := func(yaml string) (*clockSkews, error) {
+		spec := service.NewConfigSpec().Field(clockSkewField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newClockSkewsFromConfig(conf.Namespace("clock_skew"))
+	}
+
+	c, err := parse(`clock_skew: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = parse(`clock_skew: { threshold: 2m, correct: true }`)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, 2*time.Minute, c.threshold)
+	assert.True(t, c.correct)
+
+	_, err = parse(`clock_skew: { threshold: 2m, smoothing: 0 }`)
+	assert.Error(t, err)
+}
+
+func TestClockSkewCorrection(t *testing.T) {
+	mgr := service.MockResources()
+	detector := &FirewallAnomalyDetector{
+		logger:               mgr.Logger(),
+		sources:              map[string]string{"fortinet.firewall": "connection_count"},
+		sourceMatches:        &sync.Map{},
+		clockSkews:           &clockSkews{threshold: time.Minute, correct: true, smoothing: 0.5, sources: make(map[string]*sourceSkew)},
+		clockSkew:            mgr.Metrics().NewGauge("clock_skew_ms", "log_source"),
+		clockSkewCorrections: mgr.Metrics().NewCounter("clock_skew_corrections", "log_source"),
+	}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// Within the threshold event times are left alone
+	log := FirewallLog{LogSource: "fortinet.firewall", Timestamp: now.Add(-10 * time.Second)}
+	detector.correctClockSkew(&log, now)
+	assert.Equal(t, now.Add(-10*time.Second), log.Timestamp)
+
+	// A device logging five minutes ahead moves the average past the
+	// threshold, after which its logs are shifted back by the skew
+	log = FirewallLog{LogSource: "fortinet.firewall", Timestamp: now.Add(5 * time.Minute)}
+	detector.correctClockSkew(&log, now)
+	skew := time.Duration(detector.clockSkews.sources["fortinet.firewall"].skew)
+	assert.Equal(t, 5*time.Minute/2-5*time.Second, skew)
+	assert.Equal(t, now.Add(5*time.Minute-skew), log.Timestamp)
+
+	// Other sources are tracked separately
+	log = FirewallLog{LogSource: "paloalto.firewall", Timestamp: now}
+	detector.correctClockSkew(&log, now)
+	assert.Equal(t, now, log.Timestamp)
+
+	assert.Equal(t, 2, detector.clockSkews.expire(now.Add(time.Nanosecond)))
+}
+
+func TestClockSkewCrossings(t *testing.T) {
+	c := &clockSkews{threshold: time.Minute, smoothing: 1, sources: make(map[string]*sourceSkew)}
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	_, exceeded, crossed := c.observe("fw", now.Add(-2*time.Minute), now)
+	assert.True(t, exceeded)
+	assert.True(t, crossed)
+
+	_, exceeded, crossed = c.observe("fw", now.Add(-3*time.Minute), now)
+	assert.True(t, exceeded)
+	assert.False(t, crossed)
+
+	_, exceeded, crossed = c.observe("fw", now, now)
+	assert.False(t, exceeded)
+	assert.True(t, crossed)
+}
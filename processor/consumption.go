@@ -0,0 +1,314 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	consumptionModePeek = "peek"
+	consumptionModeAck  = "ack"
+)
+
+func consumptionField() *service.ConfigField {
+	return service.NewObjectField("consumption",
+		service.NewStringEnumField("mode", consumptionModePeek, consumptionModeAck).
+			Description("`peek` reads the whole log list without removing anything. `ack` moves logs to a processing list and only removes them once the window they belong to has been emitted, so that logs read before a crash are re-delivered on restart.").
+			Default(consumptionModePeek),
+		service.NewStringField("processing_key").
+			Description("Redis list holding logs that have been read but whose window has not been emitted yet. Defaults to the log list key suffixed with `:processing`. Every replica needs its own processing list.").
+			Default(""),
+		service.NewIntField("batch_size").
+			Description("Maximum number of logs moved to the processing list per read in `ack` mode. Defaults to 1000, or the value of `profile`.").
+			Optional(),
+	).
+		Description("How logs are consumed from the Redis list")
+}
+
+// moveLogsScript atomically moves up to ARGV[1] logs from the head of the
+// log list to the tail of the processing list.
+var moveLogsScript = redis.NewScript(`
+local moved = {}
+for i = 1, tonumber(ARGV[1]) do
+	local item = redis.call("LMOVE", KEYS[1], KEYS[2], "LEFT", "RIGHT")
+	if not item then
+		break
+	end
+	moved[#moved + 1] = item
+end
+return moved
+`)
+
+// logConsumer reads logs from Redis. In ack mode consumed logs are parked in
+// a processing list until they are acknowledged.
+type logConsumer struct {
+	client        *redis.Client
+	mode          string
+	key           string
+	processingKey string
+	batchSize     int
+}
+
+func newLogConsumerFromConfig(conf *service.ParsedConfig, client *redis.Client, key string, p profile) (*logConsumer, error) {
+	c := &logConsumer{client: client, key: key}
+
+	var err error
+	if c.mode, err = conf.FieldString("mode"); err != nil {
+		return nil, err
+	}
+	if c.processingKey, err = conf.FieldString("processing_key"); err != nil {
+		return nil, err
+	}
+	if c.processingKey == "" {
+		c.processingKey = key + ":processing"
+	}
+	if c.batchSize, err = fieldIntOr(conf, p.batchSize, "batch_size"); err != nil {
+		return nil, err
+	}
+	if c.batchSize < 1 {
+		return nil, errors.New("consumption batch_size must be positive")
+	}
+	return c, nil
+}
+
+func (c *logConsumer) acking() bool {
+	return c != nil && c.mode == consumptionModeAck
+}
+
+// read returns up to limit raw logs, or as many as available when limit is
+// negative. Batches read once on behalf of several reads, moving as many
+// logs in ack mode.
+func (c *logConsumer) read(ctx context.Context, limit, reads int) ([]string, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+	if !c.acking() {
+		stop := int64(-1)
+		if limit > 0 {
+			stop = int64(limit) - 1
+		}
+		return c.client.LRange(ctx, c.key, 0, stop).Result()
+	}
+	return moveLogsScript.Run(ctx, c.client, []string{c.key, c.processingKey}, c.moveSize(limit, reads)).StringSlice()
+}
+
+// moveSize returns the number of logs moved in ack mode on behalf of reads.
+func (c *logConsumer) moveSize(limit, reads int) int {
+	if reads < 1 {
+		reads = 1
+	}
+	n := c.batchSize * reads
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	return n
+}
+
+// ack removes logs from the processing list once they no longer need to be
+// re-delivered. Within a processing cycle the removals are deferred to the
+// pipeline of the cycle.
+func (c *logConsumer) ack(ctx context.Context, raws ...string) error {
+	if !c.acking() || len(raws) == 0 {
+		return nil
+	}
+	if b := redisBatchFrom(ctx); b != nil {
+		b.add(raws, nil)
+		return nil
+	}
+
+	pipe := c.client.Pipeline()
+	for _, raw := range raws {
+		pipe.LRem(ctx, c.processingKey, 1, raw)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// requeue hands a log back to the log list, e.g. when it belongs to a window
+// owned by another replica.
+func (c *logConsumer) requeue(ctx context.Context, raw string) error {
+	if !c.acking() {
+		return nil
+	}
+	if b := redisBatchFrom(ctx); b != nil {
+		b.add(nil, []string{raw})
+		return nil
+	}
+
+	pipe := c.client.TxPipeline()
+	pipe.RPush(ctx, c.key, raw)
+	pipe.LRem(ctx, c.processingKey, 1, raw)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// redisBatch collects the acknowledgements and requeues of a processing
+// cycle, so that they are written in a single round trip however many logs
+// the cycle handles.
+type redisBatch struct {
+	mut      sync.Mutex
+	acks     []string
+	requeues []string
+}
+
+type redisBatchKey struct{}
+
+// withRedisBatch returns a context deferring the writes of consumer calls
+// made with it to the returned batch.
+func withRedisBatch(ctx context.Context) (context.Context, *redisBatch) {
+	b := &redisBatch{}
+	return context.WithValue(ctx, redisBatchKey{}, b), b
+}
+
+func redisBatchFrom(ctx context.Context) *redisBatch {
+	b, _ := ctx.Value(redisBatchKey{}).(*redisBatch)
+	return b
+}
+
+func (b *redisBatch) add(acks, requeues []string) {
+	b.mut.Lock()
+	b.acks = append(b.acks, acks...)
+	b.requeues = append(b.requeues, requeues...)
+	b.mut.Unlock()
+}
+
+// flush writes the collected requeues and acknowledgements in one
+// transaction and returns the number of commands sent.
+func (c *logConsumer) flush(ctx context.Context, b *redisBatch) (int, error) {
+	b.mut.Lock()
+	acks, requeues := b.acks, b.requeues
+	b.acks, b.requeues = nil, nil
+	b.mut.Unlock()
+	if !c.acking() || len(acks)+len(requeues) == 0 {
+		return 0, nil
+	}
+
+	pipe := c.client.TxPipeline()
+	for _, raw := range requeues {
+		pipe.RPush(ctx, c.key, raw)
+		pipe.LRem(ctx, c.processingKey, 1, raw)
+	}
+	for _, raw := range acks {
+		pipe.LRem(ctx, c.processingKey, 1, raw)
+	}
+	n := pipe.Len()
+	_, err := pipe.Exec(ctx)
+	return n, err
+}
+
+// recover moves logs left in the processing list by a previous run back to
+// the head of the log list so that they are re-delivered. Logs still pending
+// in restored windows are left in place.
+func (c *logConsumer) recover(ctx context.Context, inFlight []string) (int, error) {
+	if !c.acking() {
+		return 0, nil
+	}
+
+	parked, err := c.client.LRange(ctx, c.processingKey, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	redeliver := unclaimedLogs(parked, inFlight)
+	if len(redeliver) == 0 {
+		return 0, nil
+	}
+
+	pipe := c.client.TxPipeline()
+	for i := len(redeliver) - 1; i >= 0; i-- {
+		pipe.LRem(ctx, c.processingKey, 1, redeliver[i])
+		pipe.LPush(ctx, c.key, redeliver[i])
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return len(redeliver), nil
+}
+
+// unclaimedLogs returns the parked logs, in order, that are not accounted for
+// by inFlight. Duplicated logs are matched one for one.
+func unclaimedLogs(parked, inFlight []string) []string {
+	claimed := make(map[string]int, len(inFlight))
+	for _, raw := range inFlight {
+		claimed[raw]++
+	}
+
+	var unclaimed []string
+	for _, raw := range parked {
+		if claimed[raw] > 0 {
+			claimed[raw]--
+			continue
+		}
+		unclaimed = append(unclaimed, raw)
+	}
+	return unclaimed
+}
+
+// pendingLogs returns every log held by a window that has not been emitted.
+func (f *FirewallAnomalyDetector) pendingLogs() []string {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	var pending []string
+	for _, window := range f.windows {
+		pending = append(pending, window.Pending...)
+	}
+	return pending
+}
+
+// recoverConsumption re-delivers logs read by a previous run whose windows
+// were never emitted.
+func (f *FirewallAnomalyDetector) recoverConsumption(ctx context.Context) error {
+	spilled, err := f.loadSpilled(ctx)
+	if err != nil {
+		return err
+	}
+
+	n, err := f.consumer.recover(ctx, append(f.pendingLogs(), spilled...))
+	if n > 0 {
+		f.logger.Infof("Re-delivering %d logs read before the last shutdown", n)
+	}
+	return err
+}
+
+func (f *FirewallAnomalyDetector) ackLogs(ctx context.Context, raws ...string) {
+	if err := f.consumer.ack(ctx, raws...); err != nil {
+		f.logger.Errorf("Failed to acknowledge %d logs: %v", len(raws), err)
+	}
+}
+
+// flushRedisBatch writes the acknowledgements and requeues deferred during a
+// processing cycle.
+func (f *FirewallAnomalyDetector) flushRedisBatch(ctx context.Context, b *redisBatch) {
+	n, err := f.consumer.flush(ctx, b)
+	if n > 0 {
+		f.redisPipelineSize.Set(int64(n))
+		f.redisPipelineCommands.Incr(int64(n))
+	}
+	if err != nil {
+		f.logger.Errorf("Failed to write %d pipelined Redis commands: %v", n, err)
+	}
+}
+
+func (f *FirewallAnomalyDetector) requeueLog(ctx context.Context, raw string) {
+	if err := f.consumer.requeue(ctx, raw); err != nil {
+		f.logger.Errorf("Failed to requeue log: %v", err)
+	}
+}
+
+// windowID identifies the result of a window so that downstream consumers
+// can drop results re-emitted after a crash or re-delivery. Windows are
+// aligned, so their key and start identify them, and the config epoch
+// separates the results of a window re-processed under a new config.
+func windowID(windowKey string, start time.Time, configEpoch int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", windowKey, start.UnixNano(), configEpoch)))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnclaimedLogs(t *testing.T) {
+	parked := []string{`{"a":1}`, `{"b":2}`, `{"a":1}`, `{"c":3}`}
+	inFlight := []string{`{"a":1}`, `{"c":3}`}
+
+	assert.Equal(t, []string{`{"b":2}`, `{"a":1}`}, unclaimedLogs(parked, inFlight))
+	assert.Equal(t, parked, unclaimedLogs(parked, nil))
+	assert.Empty(t, unclaimedLogs(nil, inFlight))
+}
+
+func TestTrackPendingLogs(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+	}
+
+	now := time.Now()
+	detector.updateWindow("fortinet.firewall", 100, "192.168.1.1", now)
+	detector.trackPending("fortinet.firewall", `{"log_source":"fortinet.firewall"}`)
+	detector.trackPending("fortinet.firewall", "")
+	detector.trackPending("paloalto.firewall", `{"log_source":"paloalto.firewall"}`)
+
+	assert.Equal(t, []string{`{"log_source":"fortinet.firewall"}`}, detector.pendingLogs())
+}
+
+func TestWindowIDIsDeterministic(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	id := windowID("fortinet.firewall", start, 0)
+	assert.Len(t, id, 32)
+	assert.Equal(t, id, windowID("fortinet.firewall", start.In(time.Local), 0))
+	assert.NotEqual(t, id, windowID("paloalto.firewall", start, 0))
+	assert.NotEqual(t, id, windowID("fortinet.firewall", start.Add(time.Minute), 0))
+	assert.NotEqual(t, id, windowID("fortinet.firewall", start, 1))
+}
+
+func TestConsumerMoveSize(t *testing.T) {
+	c := &logConsumer{mode: consumptionModeAck, batchSize: 100}
+
+	assert.Equal(t, 100, c.moveSize(-1, 1))
+	assert.Equal(t, 100, c.moveSize(-1, 0))
+	assert.Equal(t, 500, c.moveSize(-1, 5))
+	assert.Equal(t, 250, c.moveSize(250, 5))
+}
+
+func TestConsumerBatchesWrites(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	c := &logConsumer{client: client, mode: consumptionModeAck, key: "logs", processingKey: "logs:processing"}
+
+	// Writes made within a cycle are only collected
+	ctx, batch := withRedisBatch(context.Background())
+	require.NoError(t, c.ack(ctx, `{"a":1}`, `{"b":2}`))
+	require.NoError(t, c.requeue(ctx, `{"c":3}`))
+	require.NoError(t, c.ack(ctx))
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, batch.acks)
+	assert.Equal(t, []string{`{"c":3}`}, batch.requeues)
+
+	// A requeue takes a push and a removal
+	n, err := c.flush(ctx, batch)
+	assert.Error(t, err)
+	assert.Equal(t, 4, n)
+	assert.Empty(t, batch.acks)
+	assert.Empty(t, batch.requeues)
+
+	n, err = c.flush(ctx, batch)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	// Outside a cycle writes go out immediately
+	assert.Error(t, c.ack(context.Background(), `{"a":1}`))
+}
+
+func TestProcessBatchOnStandby(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windows:     make(map[string]*WindowData),
+		replication: &replicator{},
+	}
+
+	batches, err := detector.ProcessBatch(context.Background(), service.MessageBatch{
+		service.NewMessage(nil),
+		service.NewMessage(nil),
+	})
+	require.NoError(t, err)
+	assert.Nil(t, batches)
+}
@@ -0,0 +1,297 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func controlField() *service.ConfigField {
+	return service.NewObjectField("control",
+		service.NewStringField("key").
+			Description("Redis key holding a JSON document of runtime overrides of thresholds, sources and topics. Empty disables the control channel.").
+			Default(""),
+		service.NewDurationField("poll_interval").
+			Description("How often the control key is checked for changes").
+			Default("10s"),
+	).
+		Description("Control channel for adjusting the detector at runtime without restarting the pipeline and losing window state").
+		Advanced()
+}
+
+// controlDocument is the JSON document of runtime overrides stored in the
+// control key. Overrides apply on top of the static configuration, so
+// removing one reverts to the configured value.
+type controlDocument struct {
+	ScoreThreshold *float64                 `json:"score_threshold"`
+	AnomalyTopic   string                   `json:"anomaly_topic"`
+	NormalTopic    string                   `json:"normal_topic"`
+	Sources        map[string]controlSource `json:"sources"`
+}
+
+type controlSource struct {
+	Metric         string   `json:"metric"`
+	ScoreThreshold *float64 `json:"score_threshold"`
+	WindowSeconds  int      `json:"window_seconds"`
+}
+
+// detectorSettings are the settings of the detector that can change at
+// runtime.
+type detectorSettings struct {
+	scoreThreshold   float64
+	anomalyTopic     string
+	normalTopic      string
+	sources          map[string]string
+	sourceThresholds map[string]float64
+	sourceWindows    map[string]time.Duration
+	sourcePriorities map[string]int
+	sourcePatterns   []sourcePattern
+}
+
+// withOverrides returns the settings with the overrides of a control document
+// applied. Multi-metric sources can not change their metric.
+func (s detectorSettings) withOverrides(doc controlDocument, multiMetric map[string][]sourceMetric) (detectorSettings, error) {
+	out := detectorSettings{
+		scoreThreshold:   s.scoreThreshold,
+		anomalyTopic:     s.anomalyTopic,
+		normalTopic:      s.normalTopic,
+		sources:          make(map[string]string, len(s.sources)+len(doc.Sources)),
+		sourceThresholds: make(map[string]float64, len(s.sourceThresholds)),
+		sourceWindows:    make(map[string]time.Duration, len(s.sourceWindows)),
+		sourcePriorities: s.sourcePriorities,
+	}
+	for k, v := range s.sources {
+		out.sources[k] = v
+	}
+	for k, v := range s.sourceThresholds {
+		out.sourceThresholds[k] = v
+	}
+	for k, v := range s.sourceWindows {
+		out.sourceWindows[k] = v
+	}
+
+	if doc.ScoreThreshold != nil {
+		if err := checkThreshold(*doc.ScoreThreshold); err != nil {
+			return s, err
+		}
+		out.scoreThreshold = *doc.ScoreThreshold
+	}
+	if doc.AnomalyTopic != "" {
+		out.anomalyTopic = doc.AnomalyTopic
+	}
+	if doc.NormalTopic != "" {
+		out.normalTopic = doc.NormalTopic
+	}
+
+	for source, override := range doc.Sources {
+		if override.Metric != "" {
+			if _, ok := metricValueOf(FirewallLog{}, override.Metric); !ok {
+				return s, fmt.Errorf("source %s: unknown metric field %s", source, override.Metric)
+			}
+			if _, multi := multiMetric[source]; multi {
+				return s, fmt.Errorf("source %s: the metric of a multi-metric source can not be changed", source)
+			}
+			out.sources[source] = override.Metric
+		}
+		if _, exists := out.sources[source]; !exists {
+			return s, fmt.Errorf("source %s: new sources need a metric", source)
+		}
+		if override.ScoreThreshold != nil {
+			if err := checkThreshold(*override.ScoreThreshold); err != nil {
+				return s, fmt.Errorf("source %s: %w", source, err)
+			}
+			out.sourceThresholds[source] = *override.ScoreThreshold
+		}
+		if override.WindowSeconds < 0 {
+			return s, fmt.Errorf("source %s: window_seconds must be positive", source)
+		}
+		if override.WindowSeconds > 0 {
+			out.sourceWindows[source] = time.Duration(override.WindowSeconds) * time.Second
+		}
+	}
+
+	patterns, err := compileSourcePatterns(out.sources, out.sourcePriorities)
+	if err != nil {
+		return s, err
+	}
+	out.sourcePatterns = patterns
+	return out, nil
+}
+
+func checkThreshold(threshold float64) error {
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("score_threshold %v is outside of [0, 1]", threshold)
+	}
+	return nil
+}
+
+// settings returns the current runtime settings.
+func (f *FirewallAnomalyDetector) settings() detectorSettings {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	return detectorSettings{
+		scoreThreshold:   f.scoreThreshold,
+		anomalyTopic:     f.anomalyTopic,
+		normalTopic:      f.normalTopic,
+		sources:          f.sources,
+		sourceThresholds: f.sourceThresholds,
+		sourceWindows:    f.sourceWindows,
+		sourcePriorities: f.sourcePriorities,
+		sourcePatterns:   f.sourcePatterns,
+	}
+}
+
+// applySettings replaces the runtime settings. Maps are replaced rather
+// than modified so that readers never observe a partial update.
+func (f *FirewallAnomalyDetector) applySettings(s detectorSettings) {
+	f.settingsMut.Lock()
+	defer f.settingsMut.Unlock()
+
+	f.scoreThreshold = s.scoreThreshold
+	f.anomalyTopic = s.anomalyTopic
+	f.normalTopic = s.normalTopic
+	f.sources = s.sources
+	f.sourceThresholds = s.sourceThresholds
+	f.sourceWindows = s.sourceWindows
+	f.sourcePatterns = s.sourcePatterns
+	f.sourceMatches = &sync.Map{}
+}
+
+// metricFieldFor returns the metric field of a configured log source.
+func (f *FirewallAnomalyDetector) metricFieldFor(source string) (string, bool) {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	field, exists := f.sources[f.sourceKeyLocked(source)]
+	return field, exists
+}
+
+// topics returns the topics anomalous and normal results are routed to.
+func (f *FirewallAnomalyDetector) topics() (anomaly, normal string) {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	return f.anomalyTopic, f.normalTopic
+}
+
+// controlChannel polls the control key for runtime overrides.
+type controlChannel struct {
+	client   *redis.Client
+	key      string
+	interval time.Duration
+
+	// base holds the settings of the static configuration that overrides are
+	// applied on top of.
+	base detectorSettings
+	last string
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newControlChannelFromConfig(conf *service.ParsedConfig, client *redis.Client) (*controlChannel, error) {
+	key, err := conf.FieldString("key")
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	interval, err := conf.FieldDuration("poll_interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("control.poll_interval must be positive, got %v", interval)
+	}
+	return &controlChannel{
+		client:   client,
+		key:      key,
+		interval: interval,
+	}, nil
+}
+
+// startControl applies the current overrides and keeps polling for changes.
+func (f *FirewallAnomalyDetector) startControl() {
+	c := f.control
+	if c == nil {
+		return
+	}
+
+	c.base = f.settings()
+	c.shutdown = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := f.pollControl(context.Background()); err != nil {
+				f.logger.Errorf("Failed to apply runtime overrides from %s: %v", c.key, err)
+			}
+			select {
+			case <-ticker.C:
+			case <-c.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// pollControl applies the control document when it changed since the last
+// poll. Invalid documents are rejected as a whole.
+func (f *FirewallAnomalyDetector) pollControl(ctx context.Context) error {
+	c := f.control
+
+	raw, err := c.client.Get(ctx, c.key).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if raw == c.last {
+		return nil
+	}
+	if err := f.applyControl(raw); err != nil {
+		return err
+	}
+	c.last = raw
+	return nil
+}
+
+// applyControl applies a raw control document, an empty document reverting
+// to the static configuration.
+func (f *FirewallAnomalyDetector) applyControl(raw string) error {
+	var doc controlDocument
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+			return err
+		}
+	}
+
+	settings, err := f.control.base.withOverrides(doc, f.sourceMetrics)
+	if err != nil {
+		return err
+	}
+	f.applySettings(settings)
+	f.logger.Infof("Applied runtime overrides: score_threshold %v, %d sources, topics %s/%s",
+		settings.scoreThreshold, len(settings.sources), settings.anomalyTopic, settings.normalTopic)
+	return nil
+}
+
+func (f *FirewallAnomalyDetector) stopControl() {
+	c := f.control
+	if c == nil || c.shutdown == nil {
+		return
+	}
+	close(c.shutdown)
+	<-c.done
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newControlTestDetector() *FirewallAnomalyDetector {
+	f := &FirewallAnomalyDetector{
+		windowSeconds:  60,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		sources: map[string]string{
+			"fortinet.firewall": "connection_count",
+			"paloalto.firewall": "bytes_sent",
+		},
+		sourceThresholds: map[string]float64{"paloalto.firewall": 0.9},
+		sourceWindows: map[string]time.Duration{
+			"fortinet.firewall": time.Minute,
+			"paloalto.firewall": time.Minute,
+		},
+		sourceMetrics: map[string][]sourceMetric{
+			"paloalto.firewall": {{field: "bytes_sent", prefix: "bytes_sent"}, {field: "bytes_recv", prefix: "bytes_recv"}},
+		},
+		windows: make(map[string]*WindowData),
+		control: &controlChannel{key: "control"},
+	}
+	f.control.base = f.settings()
+	return f
+}
+
+func TestControlAppliesOverrides(t *testing.T) {
+	f := newControlTestDetector()
+
+	require.NoError(t, f.applyControl(`{
+		"score_threshold": 0.5,
+		"anomaly_topic": "anomalies-v2",
+		"sources": {
+			"fortinet.firewall": {"score_threshold": 0.8, "window_seconds": 300},
+			"cisco.asa": {"metric": "bytes_recv"}
+		}
+	}`))
+
+	anomaly, normal := f.topics()
+	assert.Equal(t, "anomalies-v2", anomaly)
+	assert.Equal(t, "firewall-normal", normal)
+
+	assert.Equal(t, 0.8, f.thresholdFor("", "fortinet.firewall"))
+	assert.Equal(t, 0.9, f.thresholdFor("", "paloalto.firewall"))
+	assert.Equal(t, 0.5, f.thresholdFor("", "cisco.asa"))
+
+	assert.Equal(t, 5*time.Minute, f.windowLength("fortinet.firewall"))
+	assert.Equal(t, time.Minute, f.windowLength("cisco.asa"))
+
+	field, exists := f.metricFieldFor("cisco.asa")
+	assert.True(t, exists)
+	assert.Equal(t, "bytes_recv", field)
+	assert.Equal(t, []string{"cisco.asa"}, f.metricLabels("", "cisco.asa"))
+}
+
+func TestControlRevertsRemovedOverrides(t *testing.T) {
+	f := newControlTestDetector()
+
+	require.NoError(t, f.applyControl(`{"score_threshold": 0.5, "sources": {"cisco.asa": {"metric": "bytes_recv"}}}`))
+	require.NoError(t, f.applyControl(""))
+
+	assert.Equal(t, 0.7, f.thresholdFor("", "fortinet.firewall"))
+	_, exists := f.metricFieldFor("cisco.asa")
+	assert.False(t, exists)
+}
+
+func TestControlRejectsInvalidDocuments(t *testing.T) {
+	tests := map[string]string{
+		"malformed":              `{"score_threshold":`,
+		"threshold out of range": `{"score_threshold": 1.5}`,
+		"unknown metric":         `{"sources": {"cisco.asa": {"metric": "packets"}}}`,
+		"new source no metric":   `{"sources": {"cisco.asa": {"score_threshold": 0.5}}}`,
+		"negative window":        `{"sources": {"fortinet.firewall": {"window_seconds": -1}}}`,
+		"multi-metric source":    `{"sources": {"paloalto.firewall": {"metric": "connection_count"}}}`,
+	}
+
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := newControlTestDetector()
+
+			assert.Error(t, f.applyControl(doc))
+			assert.Equal(t, 0.7, f.thresholdFor("", "fortinet.firewall"))
+			field, _ := f.metricFieldFor("paloalto.firewall")
+			assert.Equal(t, "bytes_sent", field)
+		})
+	}
+}
+
+func TestControlRejectsNonPositivePollInterval(t *testing.T) {
+	spec := service.NewConfigSpec().Field(controlField())
+	conf, err := spec.ParseYAML(`control: { key: control, poll_interval: 0s }`, nil)
+	require.NoError(t, err)
+
+	_, err = newControlChannelFromConfig(conf.Namespace("control"), nil)
+	assert.ErrorContains(t, err, "control.poll_interval must be positive")
+}
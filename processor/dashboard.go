@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DashboardOptions configures the generated Grafana dashboard.
+type DashboardOptions struct {
+	// Title of the dashboard.
+	Title string
+	// Datasource is the name or UID of the Prometheus datasource.
+	Datasource string
+	// Tenants adds a tenant variable and filter, for detectors configured
+	// with a `tenant_field`.
+	Tenants bool
+}
+
+type dashboardTarget struct {
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+	RefID        string `json:"refId"`
+}
+
+type dashboardPanel struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	Title       string                 `json:"title"`
+	Datasource  map[string]string      `json:"datasource"`
+	GridPos     map[string]int         `json:"gridPos"`
+	FieldConfig map[string]interface{} `json:"fieldConfig"`
+	Targets     []dashboardTarget      `json:"targets"`
+}
+
+// GrafanaDashboard returns the JSON model of a Grafana dashboard wired to the
+// metric names and labels exported by the detector through the Prometheus
+// metrics exporter.
+func GrafanaDashboard(opts DashboardOptions) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "Firewall Anomaly Detector"
+	}
+	if opts.Datasource == "" {
+		opts.Datasource = "Prometheus"
+	}
+	datasource := map[string]string{"type": "prometheus", "uid": opts.Datasource}
+
+	// Every per-source series is filtered by the dashboard variables
+	filter := `log_source=~"$log_source"`
+	by := "log_source"
+	if opts.Tenants {
+		filter += `,tenant=~"$tenant"`
+		by = "log_source, tenant"
+	}
+	legend := "{{log_source}}"
+	if opts.Tenants {
+		legend = "{{tenant}}/{{log_source}}"
+	}
+	sel := func(expr string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(expr, "$filter", filter), "$by", by)
+	}
+
+	var panels []dashboardPanel
+	add := func(title, unit string, targets ...dashboardTarget) {
+		n := len(panels)
+		for i := range targets {
+			targets[i].Expr = sel(targets[i].Expr)
+			targets[i].RefID = string(rune('A' + i))
+		}
+		panels = append(panels, dashboardPanel{
+			ID:         n + 1,
+			Type:       "timeseries",
+			Title:      title,
+			Datasource: datasource,
+			GridPos:    map[string]int{"h": 8, "w": 12, "x": (n % 2) * 12, "y": (n / 2) * 8},
+			FieldConfig: map[string]interface{}{
+				"defaults":  map[string]interface{}{"unit": unit},
+				"overrides": []interface{}{},
+			},
+			Targets: targets,
+		})
+	}
+
+	add("Processed logs", "ops",
+		dashboardTarget{Expr: `sum by ($by) (rate(processed_logs{$filter}[$__rate_interval]))`, LegendFormat: legend})
+	add("Anomalies", "ops",
+		dashboardTarget{Expr: `sum by ($by) (rate(anomalies_detected{$filter}[$__rate_interval]))`, LegendFormat: legend},
+		dashboardTarget{Expr: `sum by ($by) (rate(anomalies_suppressed{$filter}[$__rate_interval]))`, LegendFormat: legend + " suppressed"})
+	add("Dropped logs", "ops",
+		dashboardTarget{Expr: `sum by (reason) (rate(logs_dropped{$filter}[$__rate_interval]))`, LegendFormat: "{{reason}}"},
+		dashboardTarget{Expr: `sum by ($by) (rate(logs_rate_limited{$filter}[$__rate_interval]))`, LegendFormat: legend + " rate limited"})
+	add("Anomaly score", "none",
+		dashboardTarget{Expr: `histogram_quantile(0.5, sum by (le, $by) (rate(anomaly_score_bucket{$filter}[$__rate_interval])))`, LegendFormat: legend + " p50"},
+		dashboardTarget{Expr: `histogram_quantile(0.95, sum by (le, $by) (rate(anomaly_score_bucket{$filter}[$__rate_interval])))`, LegendFormat: legend + " p95"})
+	add("Emission lag", "ns",
+		dashboardTarget{Expr: `max by ($by) (emission_lag_ns{$filter,quantile="0.99"})`, LegendFormat: legend + " p99"})
+	add("Processing latency", "ns",
+		dashboardTarget{Expr: `max(redis_read_latency_ns{quantile="0.99"})`, LegendFormat: "redis read p99"},
+		dashboardTarget{Expr: `max(parse_latency_ns{quantile="0.99"})`, LegendFormat: "parse p99"},
+		dashboardTarget{Expr: `max by ($by) (scoring_latency_ns{$filter,quantile="0.99"})`, LegendFormat: legend + " scoring p99"})
+	add("Window state", "short",
+		dashboardTarget{Expr: `sum(active_windows)`, LegendFormat: "windows"},
+		dashboardTarget{Expr: `sum(buffered_window_values)`, LegendFormat: "buffered values"},
+		dashboardTarget{Expr: `sum(consumption_paused)`, LegendFormat: "paused instances"})
+	add("Window memory", "bytes",
+		dashboardTarget{Expr: `sum(window_state_bytes)`, LegendFormat: "estimated"})
+	add("Model drift (PSI)", "none",
+		dashboardTarget{Expr: `max by (distribution) (model_drift_psi_milli) / 1000`, LegendFormat: "{{distribution}}"})
+	add("Alerts", "ops",
+		dashboardTarget{Expr: `sum by (channel) (rate(alerts_sent[$__rate_interval]))`, LegendFormat: "{{channel}} sent"},
+		dashboardTarget{Expr: `sum by (channel) (rate(alerts_failed[$__rate_interval]))`, LegendFormat: "{{channel}} failed"})
+
+	variables := []map[string]interface{}{
+		dashboardVariable("log_source", "label_values(processed_logs, log_source)", datasource),
+	}
+	if opts.Tenants {
+		variables = append(variables, dashboardVariable("tenant", "label_values(processed_logs, tenant)", datasource))
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"title":         opts.Title,
+		"uid":           "firewall-anomaly-detector",
+		"tags":          []string{"firewall", "anomaly-detection", "redpanda-connect"},
+		"schemaVersion": 39,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-6h", "to": "now"},
+		"templating":    map[string]interface{}{"list": variables},
+		"panels":        panels,
+	}, "", "  ")
+}
+
+func dashboardVariable(name, query string, datasource map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       name,
+		"type":       "query",
+		"datasource": datasource,
+		"query":      query,
+		"refresh":    2,
+		"multi":      true,
+		"includeAll": true,
+		"allValue":   ".*",
+		"current":    map[string]interface{}{"text": "All", "value": "$__all"},
+	}
+}
@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrafanaDashboard(t *testing.T) {
+	raw, err := GrafanaDashboard(DashboardOptions{Datasource: "prom"})
+	require.NoError(t, err)
+
+	var dashboard struct {
+		Title      string
+		Panels     []dashboardPanel
+		Templating struct {
+			List []map[string]interface{}
+		}
+	}
+	require.NoError(t, json.Unmarshal(raw, &dashboard))
+	assert.Equal(t, "Firewall Anomaly Detector", dashboard.Title)
+	require.Len(t, dashboard.Templating.List, 1)
+	assert.Equal(t, "log_source", dashboard.Templating.List[0]["name"])
+
+	var exprs []string
+	for _, panel := range dashboard.Panels {
+		assert.Equal(t, "prom", panel.Datasource["uid"])
+		for _, target := range panel.Targets {
+			assert.NotContains(t, target.Expr, "$filter")
+			assert.NotContains(t, target.Expr, "$by")
+			exprs = append(exprs, target.Expr)
+		}
+	}
+	all := strings.Join(exprs, "\n")
+	for _, metric := range []string{"processed_logs", "anomalies_detected", "logs_dropped", "anomaly_score_bucket", "emission_lag_ns", "window_state_bytes", "model_drift_psi_milli"} {
+		assert.Contains(t, all, metric)
+	}
+	assert.NotContains(t, all, "tenant")
+
+	raw, err = GrafanaDashboard(DashboardOptions{Tenants: true})
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `tenant=~\"$tenant\"`)
+}
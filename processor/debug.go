@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func debugEndpointField() *service.ConfigField {
+	return service.NewObjectField("debug_endpoint",
+		service.NewBoolField("enabled").
+			Description("Serve the live windows of this instance on the Redpanda Connect HTTP server").
+			Default(false),
+		service.NewStringField("path").
+			Description("Path the windows are served at. The `key` query parameter restricts the response to one window.").
+			Default("/firewall_anomaly_detector/windows"),
+	).
+		Description("Debug endpoint for verifying that baselines are forming correctly").
+		Advanced()
+}
+
+// endpointRegistrar is implemented by the Redpanda Connect manager, which
+// serves registered endpoints on its HTTP server.
+type endpointRegistrar interface {
+	RegisterEndpoint(path, desc string, h http.HandlerFunc)
+}
+
+// registerEndpoint adds a handler to the HTTP server of Redpanda Connect. The
+// public service API does not expose the server mux, so it is reached
+// through the management layer that backs the resources.
+func registerEndpoint(mgr *service.Resources, path, desc string, h http.HandlerFunc) error {
+	unwrap := reflect.ValueOf(mgr.XUnwrapper()).MethodByName("Unwrap")
+	if !unwrap.IsValid() {
+		return errors.New("resources do not expose an HTTP server")
+	}
+	registrar, ok := unwrap.Call(nil)[0].Interface().(endpointRegistrar)
+	if !ok {
+		return errors.New("resources do not expose an HTTP server")
+	}
+	registrar.RegisterEndpoint(path, desc, h)
+	return nil
+}
+
+// registerDebugEndpoint serves the live windows when the debug endpoint is
+// enabled.
+func (f *FirewallAnomalyDetector) registerDebugEndpoint(conf *service.ParsedConfig) error {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return err
+	}
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return err
+	}
+	return registerEndpoint(f.resources, path, "Lists the live windows of the firewall anomaly detector.", f.handleWindows)
+}
+
+// windowView is the debug representation of a live window.
+type windowView struct {
+	Key       string             `json:"key"`
+	Source    string             `json:"log_source"`
+	Tenant    string             `json:"tenant,omitempty"`
+	Samples   int                `json:"samples"`
+	UniqueIPs int                `json:"unique_ips"`
+	StartTime time.Time          `json:"start_time"`
+	EndTime   time.Time          `json:"end_time"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	LastMean  float64            `json:"last_mean"`
+	Stats     map[string]float64 `json:"stats"`
+	Pending   int                `json:"pending_logs"`
+}
+
+// windowViews describes the windows held in memory, ordered by key.
+func (f *FirewallAnomalyDetector) windowViews(only string) []windowView {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	views := make([]windowView, 0, len(f.windows))
+	for key, window := range f.windows {
+		if only != "" && key != only {
+			continue
+		}
+		source, tenant := windowScope(key, window)
+		views = append(views, windowView{
+			Key:       key,
+			Source:    source,
+			Tenant:    tenant,
+			Samples:   len(window.Values),
+			UniqueIPs: window.uniqueIPs(),
+			StartTime: window.StartTime,
+			EndTime:   window.EndTime,
+			UpdatedAt: window.UpdatedAt,
+			LastMean:  window.LastMean,
+			Stats:     f.extractFeatures(window).asMap(),
+			Pending:   len(window.Pending),
+		})
+	}
+	sort.Slice(views, func(i, j int) bool { return views[i].Key < views[j].Key })
+	return views
+}
+
+func (f *FirewallAnomalyDetector) handleWindows(w http.ResponseWriter, r *http.Request) {
+	resp := map[string]interface{}{
+		"windows": f.windowViews(r.URL.Query().Get("key")),
+	}
+	if f.spill != nil {
+		f.spill.mut.Lock()
+		resp["spilled_windows"] = len(f.spill.spilled)
+		f.spill.mut.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		f.logger.Warnf("Failed to write windows debug response: %v", err)
+	}
+}
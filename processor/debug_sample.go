@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func debugSampleField() *service.ConfigField {
+	return service.NewObjectField("debug_sample",
+		service.NewFloatField("rate").
+			Description("Fraction of scored windows, normal or anomalous, copied to the debug topic, e.g. `0.01` for 1%. Zero disables sampling.").
+			Default(0.0),
+		service.NewStringField("topic").
+			Description("Topic sampled windows are routed to").
+			Default("firewall-detector-debug"),
+	).
+		Description("Copies a sample of all scored windows with their full features to a debug topic, for retroactive analysis of false negatives").
+		Advanced()
+}
+
+// debugSampler selects scored windows to copy to the debug topic.
+type debugSampler struct {
+	rate  float64
+	topic string
+
+	mut     sync.Mutex
+	pending []*service.Message
+}
+
+func newDebugSamplerFromConfig(conf *service.ParsedConfig) (*debugSampler, error) {
+	rate, err := conf.FieldFloat("rate")
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, nil
+	}
+	if rate > 1 {
+		return nil, errors.New("debug_sample rate must be between 0 and 1")
+	}
+
+	topic, err := conf.FieldString("topic")
+	if err != nil {
+		return nil, err
+	}
+	return &debugSampler{rate: rate, topic: topic}, nil
+}
+
+// sampled reports whether the window with the given idempotency key is
+// sampled. The decision is derived from the key so that re-deliveries and
+// other replicas sample the same windows.
+func (s *debugSampler) sampled(key string) bool {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8]))/math.MaxUint64 < s.rate
+}
+
+// offer queues a copy of a result for the debug topic when its window is
+// sampled.
+func (s *debugSampler) offer(result *service.Message, key string, threshold float64, samples int) {
+	if s == nil || !s.sampled(key) {
+		return
+	}
+
+	sample := result.Copy()
+	structured, err := sample.AsStructuredMut()
+	if err != nil {
+		return
+	}
+	if obj, ok := structured.(map[string]interface{}); ok {
+		obj["threshold"] = threshold
+		obj["window_samples"] = samples
+		sample.SetStructuredMut(obj)
+	}
+	sample.MetaSet("topic", s.topic)
+	sample.MetaSet("debug_sample", "true")
+
+	s.mut.Lock()
+	s.pending = append(s.pending, sample)
+	s.mut.Unlock()
+}
+
+// drain returns the queued debug copies.
+func (s *debugSampler) drain() []*service.Message {
+	if s == nil {
+		return nil
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	return pending
+}
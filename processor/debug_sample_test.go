@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugSampler(t *testing.T) {
+	spec := service.NewConfigSpec().Field(debugSampleField())
+	conf, err := spec.ParseYAML(`debug_sample: { rate: 0.1 }`, nil)
+	require.NoError(t, err)
+	sampler, err := newDebugSamplerFromConfig(conf.Namespace("debug_sample"))
+	require.NoError(t, err)
+
+	sampled := 0
+	for i := 0; i < 10000; i++ {
+		key := fmt.Sprintf("window-%d", i)
+		if sampler.sampled(key) {
+			sampled++
+		}
+		assert.Equal(t, sampler.sampled(key), sampler.sampled(key))
+	}
+	assert.InDelta(t, 1000, sampled, 150)
+
+	var key string
+	for i := 0; key == ""; i++ {
+		if k := fmt.Sprintf("window-%d", i); sampler.sampled(k) {
+			key = k
+		}
+	}
+
+	result := service.NewMessage(nil)
+	result.SetStructured(map[string]interface{}{"anomaly_score": 0.2, "is_anomaly": false})
+	result.MetaSet("topic", "firewall-normal")
+	sampler.offer(result, key, 0.7, 12)
+
+	samples := sampler.drain()
+	require.Len(t, samples, 1)
+	assert.Empty(t, sampler.drain())
+
+	topic, _ := samples[0].MetaGet("topic")
+	assert.Equal(t, "firewall-detector-debug", topic)
+	sample, err := alertResult(samples[0])
+	require.NoError(t, err)
+	assert.Equal(t, 0.7, sample["threshold"])
+	assert.Equal(t, 12, sample["window_samples"])
+
+	// The routed result is left untouched
+	topic, _ = result.MetaGet("topic")
+	assert.Equal(t, "firewall-normal", topic)
+	original, err := alertResult(result)
+	require.NoError(t, err)
+	assert.NotContains(t, original, "threshold")
+}
+
+func TestDebugSamplerDisabled(t *testing.T) {
+	spec := service.NewConfigSpec().Field(debugSampleField())
+	conf, err := spec.ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	sampler, err := newDebugSamplerFromConfig(conf.Namespace("debug_sample"))
+	require.NoError(t, err)
+	assert.Nil(t, sampler)
+	sampler.offer(service.NewMessage(nil), "window", 0.7, 1)
+	assert.Empty(t, sampler.drain())
+}
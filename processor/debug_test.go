@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugWindows(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		logger:        service.MockResources().Logger(),
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 30, "192.168.1.2", now.Add(time.Second))
+	detector.updateWindow("paloalto.firewall", 5, "192.168.1.1", now)
+
+	rec := httptest.NewRecorder()
+	detector.handleWindows(rec, httptest.NewRequest("GET", "/firewall_anomaly_detector/windows?key=fortinet.firewall", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp struct {
+		Windows []windowView `json:"windows"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Len(t, resp.Windows, 1)
+
+	view := resp.Windows[0]
+	assert.Equal(t, "fortinet.firewall", view.Key)
+	assert.Equal(t, 2, view.Samples)
+	assert.Equal(t, 2, view.UniqueIPs)
+	assert.Equal(t, 20.0, view.Stats["mean_value"])
+	assert.Equal(t, now.Add(60*time.Second), view.EndTime)
+
+	assert.Len(t, detector.windowViews(""), 2)
+}
+
+func TestRegisterEndpoint(t *testing.T) {
+	mgr := service.MockResources()
+	require.NoError(t, registerEndpoint(mgr, "/windows", "Lists windows.", func(http.ResponseWriter, *http.Request) {}))
+}
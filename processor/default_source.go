@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// defaultSourceKey is the `sources` key the default source configuration is
+// held under, and the log_source label of logs it applies to.
+const defaultSourceKey = "default_source"
+
+func defaultSourceField() *service.ConfigField {
+	return service.NewObjectField("default_source",
+		service.NewStringField("metric").
+			Description("Metric field to extract from logs of unmatched sources, one of `connection_count`, `bytes_sent` or `bytes_recv`"),
+		service.NewFloatField("score_threshold").
+			Description("Anomaly threshold of unmatched sources, overriding `score_threshold`").
+			Optional(),
+		service.NewIntField("window_seconds").
+			Description("Duration of the windows of unmatched sources in seconds, overriding `window_seconds`").
+			Optional(),
+	).
+		Description("Configuration applied to logs whose log_source matches no `sources` key, which are dropped when omitted. Every unmatched source keeps its own windows.").
+		Optional().
+		Advanced()
+}
+
+// defaultSource is the configuration of unmatched log sources.
+type defaultSource struct {
+	metric    string
+	threshold *float64
+	window    time.Duration
+}
+
+// parseDefaultSource returns the default source configuration, or nil when
+// unmatched sources are dropped.
+func parseDefaultSource(conf *service.ParsedConfig, windowSeconds int) (*defaultSource, error) {
+	if !conf.Contains("default_source") {
+		return nil, nil
+	}
+	conf = conf.Namespace("default_source")
+
+	d := &defaultSource{window: time.Duration(windowSeconds) * time.Second}
+
+	var err error
+	if d.metric, err = conf.FieldString("metric"); err != nil {
+		return nil, err
+	}
+	if _, ok := metricValueOf(FirewallLog{}, d.metric); !ok {
+		return nil, fmt.Errorf("default_source: unknown metric field %s", d.metric)
+	}
+	if conf.Contains("score_threshold") {
+		threshold, err := conf.FieldFloat("score_threshold")
+		if err != nil {
+			return nil, err
+		}
+		d.threshold = &threshold
+	}
+	if conf.Contains("window_seconds") {
+		seconds, err := conf.FieldInt("window_seconds")
+		if err != nil {
+			return nil, err
+		}
+		if seconds <= 0 {
+			return nil, fmt.Errorf("window_seconds of default_source must be positive")
+		}
+		d.window = time.Duration(seconds) * time.Second
+	}
+	return d, nil
+}
+
+// addTo registers the default source under defaultSourceKey alongside the
+// configured sources.
+func (d *defaultSource) addTo(sources map[string]string, thresholds map[string]float64, windows map[string]time.Duration) {
+	if d == nil {
+		return
+	}
+	sources[defaultSourceKey] = d.metric
+	if d.threshold != nil {
+		thresholds[defaultSourceKey] = *d.threshold
+	}
+	windows[defaultSourceKey] = d.window
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestDefaultSource(t *testing.T, yaml string) (*defaultSource, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(defaultSourceField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return parseDefaultSource(conf, 60)
+}
+
+func TestParseDefaultSource(t *testing.T) {
+	d, err := parseTestDefaultSource(t, `{}`)
+	require.NoError(t, err)
+	assert.Nil(t, d)
+
+	d, err = parseTestDefaultSource(t, `default_source: { metric: bytes_sent, score_threshold: 0.9, window_seconds: 300 }`)
+	require.NoError(t, err)
+	require.NotNil(t, d)
+	assert.Equal(t, "bytes_sent", d.metric)
+	assert.Equal(t, 0.9, *d.threshold)
+	assert.Equal(t, 5*time.Minute, d.window)
+
+	_, err = parseTestDefaultSource(t, `default_source: { metric: packets }`)
+	assert.Error(t, err)
+}
+
+func TestDefaultSourceFallback(t *testing.T) {
+	d, err := parseTestDefaultSource(t, `default_source: { metric: bytes_recv, score_threshold: 0.9 }`)
+	require.NoError(t, err)
+
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:    60,
+		scoreThreshold:   0.7,
+		sources:          map[string]string{"fortinet.firewall": "connection_count"},
+		sourceThresholds: map[string]float64{},
+		sourceWindows:    map[string]time.Duration{"fortinet.firewall": time.Minute},
+		sourceMatches:    &sync.Map{},
+	}
+	d.addTo(detector.sources, detector.sourceThresholds, detector.sourceWindows)
+
+	field, exists := detector.metricFieldFor("cisco.asa")
+	assert.True(t, exists)
+	assert.Equal(t, "bytes_recv", field)
+	assert.Equal(t, 0.9, detector.thresholdFor("", "cisco.asa"))
+	assert.Equal(t, 0.7, detector.thresholdFor("", "fortinet.firewall"))
+	assert.Equal(t, []string{defaultSourceKey}, detector.metricLabels("", "cisco.asa"))
+
+	// The default source is never reported silent
+	m, err := newSelfMonitorFromConfig(mustParseSelfMonitoring(t, `self_monitoring: { silent_windows: 1 }`), detector.sourceWindows)
+	require.NoError(t, err)
+	assert.NotContains(t, m.lastSeen, defaultSourceKey)
+}
+
+func mustParseSelfMonitoring(t *testing.T, yaml string) *service.ParsedConfig {
+	t.Helper()
+
+	conf, err := service.NewConfigSpec().Field(selfMonitoringField()).ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return conf.Namespace("self_monitoring")
+}
@@ -0,0 +1,297 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const reasonModelDrift = "model_drift"
+
+// psiEpsilon stands in for empty buckets, which would otherwise make the PSI
+// infinite.
+const psiEpsilon = 1e-4
+
+func driftField() *service.ConfigField {
+	return service.NewObjectField("drift",
+		service.NewBoolField("enabled").
+			Description("Periodically compare the score and feature distributions against a reference").
+			Default(false),
+		service.NewStringField("reference_path").
+			Description("JSON file holding the reference distributions. When missing, the first evaluated period is captured as the reference and written to it. When empty, the reference is only kept in memory.").
+			Default(""),
+		service.NewDurationField("interval").
+			Description("Length of the periods whose distributions are compared against the reference").
+			Default("1h"),
+		service.NewIntField("min_samples").
+			Description("Minimum number of scored windows a period needs to be evaluated").
+			Default(100),
+		service.NewFloatField("alert_threshold").
+			Description("Emit a `model_drift` event when the Population Stability Index of any distribution reaches this value. Zero disables drift events.").
+			Default(0.25),
+	).
+		Description("Model drift detection through the Population Stability Index (PSI) of score and feature distributions. Buckets are those of `histograms`.").
+		Advanced()
+}
+
+// distribution counts observations in buckets with the given upper bounds,
+// plus an implicit `+Inf` bucket.
+type distribution struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []float64 `json:"counts"`
+}
+
+func newDistribution(bounds []float64) *distribution {
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	return &distribution{
+		Bounds: sorted,
+		Counts: make([]float64, len(sorted)+1),
+	}
+}
+
+func (d *distribution) observe(v float64) {
+	d.Counts[sort.SearchFloat64s(d.Bounds, v)]++
+}
+
+func (d *distribution) total() float64 {
+	var n float64
+	for _, c := range d.Counts {
+		n += c
+	}
+	return n
+}
+
+// psi returns the Population Stability Index of d against a reference.
+func (d *distribution) psi(ref *distribution) (float64, error) {
+	if len(d.Counts) != len(ref.Counts) {
+		return 0, errors.New("distribution buckets differ from the reference")
+	}
+	actualTotal, expectedTotal := d.total(), ref.total()
+	if actualTotal == 0 || expectedTotal == 0 {
+		return 0, errors.New("empty distribution")
+	}
+
+	var index float64
+	for i := range d.Counts {
+		actual := math.Max(d.Counts[i]/actualTotal, psiEpsilon)
+		expected := math.Max(ref.Counts[i]/expectedTotal, psiEpsilon)
+		index += (actual - expected) * math.Log(actual/expected)
+	}
+	return index, nil
+}
+
+// driftDistributions are the distributions compared for drift.
+type driftDistributions struct {
+	CapturedAt time.Time                `json:"captured_at"`
+	Score      *distribution            `json:"score"`
+	Features   map[string]*distribution `json:"features"`
+}
+
+func (d *driftDistributions) samples() int {
+	return int(d.Score.total())
+}
+
+// driftMonitor accumulates the distributions of a period and compares them
+// with the reference once the period is over.
+type driftMonitor struct {
+	path           string
+	interval       time.Duration
+	minSamples     int
+	alertThreshold float64
+	scoreBounds    []float64
+	featureBounds  []float64
+
+	gauge  *service.MetricGauge
+	logger *service.Logger
+	now    func() time.Time
+
+	mut         sync.Mutex
+	periodStart time.Time
+	current     *driftDistributions
+	reference   *driftDistributions
+}
+
+func newDriftMonitorFromConfig(conf, histConf *service.ParsedConfig, mgr *service.Resources) (*driftMonitor, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	d := &driftMonitor{
+		gauge:  mgr.Metrics().NewGauge("model_drift_psi_milli", "distribution"),
+		logger: mgr.Logger(),
+		now:    time.Now,
+	}
+	if d.path, err = conf.FieldString("reference_path"); err != nil {
+		return nil, err
+	}
+	if d.interval, err = conf.FieldDuration("interval"); err != nil {
+		return nil, err
+	}
+	if d.minSamples, err = conf.FieldInt("min_samples"); err != nil {
+		return nil, err
+	}
+	if d.alertThreshold, err = conf.FieldFloat("alert_threshold"); err != nil {
+		return nil, err
+	}
+	if d.scoreBounds, err = histConf.FieldFloatList("score_buckets"); err != nil {
+		return nil, err
+	}
+	if d.featureBounds, err = histConf.FieldFloatList("feature_buckets"); err != nil {
+		return nil, err
+	}
+
+	if d.path != "" {
+		raw, err := os.ReadFile(d.path)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(raw, &d.reference); err != nil {
+				return nil, err
+			}
+			if d.reference.Score == nil {
+				return nil, errors.New("drift reference has no score distribution")
+			}
+		case !errors.Is(err, os.ErrNotExist):
+			return nil, err
+		}
+	}
+
+	d.resetPeriod()
+	return d, nil
+}
+
+func (d *driftMonitor) resetPeriod() {
+	d.periodStart = d.now()
+	d.current = &driftDistributions{
+		Score:    newDistribution(d.scoreBounds),
+		Features: make(map[string]*distribution),
+	}
+}
+
+// observe records a scored window in the current period.
+func (d *driftMonitor) observe(features map[string]float64, score float64) {
+	if d == nil {
+		return
+	}
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	d.current.Score.observe(score)
+	for name, value := range features {
+		dist, exists := d.current.Features[name]
+		if !exists {
+			dist = newDistribution(d.featureBounds)
+			d.current.Features[name] = dist
+		}
+		dist.observe(value)
+	}
+}
+
+// evaluate closes the current period once it is over, returning the PSI of
+// every distribution against the reference. No indices are returned while
+// the reference is being captured or the period had too few samples.
+func (d *driftMonitor) evaluate() map[string]float64 {
+	if d == nil {
+		return nil
+	}
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	now := d.now()
+	if now.Sub(d.periodStart) < d.interval {
+		return nil
+	}
+	period := d.current
+	d.resetPeriod()
+	if period.samples() < d.minSamples {
+		return nil
+	}
+	period.CapturedAt = now
+
+	if d.reference == nil {
+		d.reference = period
+		if err := d.saveReference(); err != nil {
+			d.logger.Errorf("Failed to write drift reference: %v", err)
+		} else {
+			d.logger.Infof("Captured drift reference from %d scored windows", period.samples())
+		}
+		return nil
+	}
+
+	indices := make(map[string]float64, len(period.Features)+1)
+	if psi, err := period.Score.psi(d.reference.Score); err == nil {
+		indices["score"] = psi
+	}
+	for name, dist := range period.Features {
+		ref, exists := d.reference.Features[name]
+		if !exists {
+			continue
+		}
+		if psi, err := dist.psi(ref); err == nil {
+			indices[name] = psi
+		}
+	}
+	for name, psi := range indices {
+		d.gauge.Set(int64(math.Round(psi*1000)), name)
+	}
+	return indices
+}
+
+func (d *driftMonitor) saveReference() error {
+	if d.path == "" {
+		return nil
+	}
+	raw, err := json.MarshalIndent(d.reference, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := d.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, d.path)
+}
+
+// driftEvents evaluates drift, returning a `model_drift` event when any
+// distribution drifted past the alert threshold.
+func (f *FirewallAnomalyDetector) driftEvents(ctx context.Context) []*service.Message {
+	indices := f.drift.evaluate()
+	if len(indices) == 0 || f.drift.alertThreshold <= 0 {
+		return nil
+	}
+
+	var drifted []string
+	maxPSI := 0.0
+	for name, psi := range indices {
+		if psi >= f.drift.alertThreshold {
+			drifted = append(drifted, name)
+		}
+		maxPSI = math.Max(maxPSI, psi)
+	}
+	if len(drifted) == 0 {
+		return nil
+	}
+	sort.Strings(drifted)
+
+	f.logger.Warnf("Model drift detected in %v (max PSI %.3f)", drifted, maxPSI)
+	now := time.Now()
+	return []*service.Message{f.detectorEvent(ctx, map[string]interface{}{
+		"timestamp":  now,
+		"log_source": "detector",
+		"window_end": now,
+		"reason":     reasonModelDrift,
+		"severity":   "warning",
+		"is_anomaly": false,
+		"psi":        indices,
+		"max_psi":    maxPSI,
+		"drifted":    drifted,
+	}, true)}
+}
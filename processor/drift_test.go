@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributionPSI(t *testing.T) {
+	ref := newDistribution([]float64{0.5, 0.2})
+	assert.Equal(t, []float64{0.2, 0.5}, ref.Bounds)
+	for _, v := range []float64{0.1, 0.3, 0.6, 0.1} {
+		ref.observe(v)
+	}
+	assert.Equal(t, []float64{2, 1, 1}, ref.Counts)
+
+	same := newDistribution(ref.Bounds)
+	for _, v := range []float64{0.15, 0.4, 0.9, 0.05} {
+		same.observe(v)
+	}
+	psi, err := same.psi(ref)
+	require.NoError(t, err)
+	assert.InDelta(t, 0, psi, 1e-9)
+
+	shifted := newDistribution(ref.Bounds)
+	for _, v := range []float64{0.9, 0.9, 0.9, 0.1} {
+		shifted.observe(v)
+	}
+	psi, err = shifted.psi(ref)
+	require.NoError(t, err)
+	assert.Greater(t, psi, 0.25)
+
+	_, err = newDistribution([]float64{1}).psi(ref)
+	assert.Error(t, err)
+}
+
+func newTestDriftMonitor(t *testing.T, yaml string, now *time.Time) *driftMonitor {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(driftField()).Field(histogramsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	d, err := newDriftMonitorFromConfig(conf.Namespace("drift"), conf.Namespace("histograms"), service.MockResources())
+	require.NoError(t, err)
+	require.NotNil(t, d)
+
+	d.now = func() time.Time { return *now }
+	d.resetPeriod()
+	return d
+}
+
+func TestDriftMonitor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reference.json")
+	yaml := `
+drift:
+  enabled: true
+  reference_path: ` + path + `
+  interval: 1h
+  min_samples: 10
+`
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	d := newTestDriftMonitor(t, yaml, &now)
+
+	observe := func(score float64) {
+		for i := 0; i < 20; i++ {
+			d.observe(map[string]float64{"mean_value": 50}, score)
+		}
+	}
+
+	// The first full period is captured as the reference
+	observe(0.15)
+	assert.Nil(t, d.evaluate())
+	now = now.Add(time.Hour)
+	assert.Nil(t, d.evaluate())
+	_, err := os.Stat(path)
+	require.NoError(t, err)
+
+	// Periods with too few samples are skipped
+	d.observe(nil, 0.95)
+	now = now.Add(time.Hour)
+	assert.Nil(t, d.evaluate())
+
+	// A restarted monitor compares against the stored reference
+	d = newTestDriftMonitor(t, yaml, &now)
+	observe(0.95)
+	now = now.Add(time.Hour)
+	indices := d.evaluate()
+	assert.Greater(t, indices["score"], 0.25)
+	assert.InDelta(t, 0, indices["mean_value"], 1e-9)
+}
+
+func TestDriftDisabled(t *testing.T) {
+	spec := service.NewConfigSpec().Field(driftField()).Field(histogramsField())
+	conf, err := spec.ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	d, err := newDriftMonitorFromConfig(conf.Namespace("drift"), conf.Namespace("histograms"), service.MockResources())
+	require.NoError(t, err)
+	assert.Nil(t, d)
+	assert.Nil(t, d.evaluate())
+	d.observe(nil, 0)
+}
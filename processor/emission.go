@@ -0,0 +1,255 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func asyncEmissionField() *service.ConfigField {
+	return service.NewObjectField("async_emission",
+		service.NewStringField("output").
+			Description("Name of the output resource window results are written to by a background flusher, instead of being returned down the pipeline. Results keep their `topic` metadata for routing.").
+			Default(""),
+		service.NewIntField("queue_size").
+			Description("Results queued for the flusher. Once the queue is full, processing waits for the flusher to catch up.").
+			Default(10000),
+		service.NewIntField("batch_size").
+			Description("Maximum results written to the output per batch").
+			Default(100),
+		service.NewDurationField("flush_interval").
+			Description("Longest a result waits for its batch to fill, and the delay before a failed batch is retried").
+			Default("100ms"),
+	).
+		Description("Asynchronous emission of window results through a bounded queue, so that a slow downstream does not hold up window updates and let the Redis backlog grow. Disabled unless `output` is set.").
+		Advanced()
+}
+
+// resultEmitter queues window results and writes them to an output resource
+// from a background flusher. The logs of a queued result are only
+// acknowledged once it is written, and requeued if it never is.
+type resultEmitter struct {
+	queue         chan *service.Message
+	batchSize     int
+	flushInterval time.Duration
+	write         func(ctx context.Context, batch service.MessageBatch) error
+	ack           func(ctx context.Context, raws []string)
+	requeue       func(ctx context.Context, raws []string)
+	logger        *service.Logger
+	depth         *service.MetricGauge
+
+	mut  sync.Mutex
+	held map[*service.Message]heldResult
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newResultEmitterFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*resultEmitter, error) {
+	output, err := conf.FieldString("output")
+	if err != nil {
+		return nil, err
+	}
+	if output == "" {
+		return nil, nil
+	}
+
+	queueSize, err := conf.FieldInt("queue_size")
+	if err != nil {
+		return nil, err
+	}
+	if queueSize < 1 {
+		return nil, fmt.Errorf("async_emission.queue_size must be positive, got %d", queueSize)
+	}
+	e := &resultEmitter{
+		queue:  make(chan *service.Message, queueSize),
+		logger: mgr.Logger(),
+		depth:  mgr.Metrics().NewGauge("result_queue_depth"),
+	}
+	if e.batchSize, err = conf.FieldInt("batch_size"); err != nil {
+		return nil, err
+	}
+	if e.batchSize < 1 {
+		return nil, fmt.Errorf("async_emission.batch_size must be positive, got %d", e.batchSize)
+	}
+	if e.flushInterval, err = conf.FieldDuration("flush_interval"); err != nil {
+		return nil, err
+	}
+	if e.flushInterval <= 0 {
+		return nil, fmt.Errorf("async_emission.flush_interval must be positive, got %v", e.flushInterval)
+	}
+
+	e.write = func(ctx context.Context, batch service.MessageBatch) error {
+		var writeErr error
+		if err := mgr.AccessOutput(ctx, output, func(o *service.ResourceOutput) {
+			writeErr = o.WriteBatch(ctx, batch)
+		}); err != nil {
+			return err
+		}
+		return writeErr
+	}
+	return e, nil
+}
+
+// enqueue queues results for the flusher, waiting while the queue is full.
+// The logs of results left out are requeued.
+func (e *resultEmitter) enqueue(ctx context.Context, results []*service.Message) error {
+	for i, msg := range results {
+		select {
+		case e.queue <- msg:
+		case <-ctx.Done():
+			e.settle(results[i:], false)
+			return ctx.Err()
+		}
+		e.depth.Set(int64(len(e.queue)))
+	}
+	return nil
+}
+
+// heldResult is what a queued result holds until it is written: the logs it
+// was computed from and the release of its window claim.
+type heldResult struct {
+	raws    []string
+	release func(ctx context.Context)
+}
+
+// hold keeps the logs of a result unacknowledged until it is written.
+// release is called instead if the result is never written.
+func (e *resultEmitter) hold(msg *service.Message, raws []string, release func(ctx context.Context)) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	if e.held == nil {
+		e.held = make(map[*service.Message]heldResult)
+	}
+	e.held[msg] = heldResult{raws: raws, release: release}
+}
+
+// heldLogs returns the number of logs whose results are queued but not
+// written yet.
+func (e *resultEmitter) heldLogs() int {
+	if e == nil {
+		return 0
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+	n := 0
+	for _, held := range e.held {
+		n += len(held.raws)
+	}
+	return n
+}
+
+// settle acknowledges the logs held for a batch of results once it is
+// written, or releases their window claims and requeues them otherwise.
+func (e *resultEmitter) settle(batch []*service.Message, written bool) {
+	e.mut.Lock()
+	var raws []string
+	var releases []func(ctx context.Context)
+	for _, msg := range batch {
+		held := e.held[msg]
+		raws = append(raws, held.raws...)
+		if held.release != nil {
+			releases = append(releases, held.release)
+		}
+		delete(e.held, msg)
+	}
+	e.mut.Unlock()
+
+	ctx := context.Background()
+	if written {
+		if len(raws) > 0 && e.ack != nil {
+			e.ack(ctx, raws)
+		}
+		return
+	}
+	for _, release := range releases {
+		release(ctx)
+	}
+	if len(raws) > 0 && e.requeue != nil {
+		e.requeue(ctx, raws)
+	}
+}
+
+func (e *resultEmitter) start() {
+	if e == nil {
+		return
+	}
+
+	e.shutdown = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go func() {
+		defer close(e.done)
+
+		ticker := time.NewTicker(e.flushInterval)
+		defer ticker.Stop()
+
+		var batch service.MessageBatch
+		retrying := false
+		for {
+			// A failed batch is only retried on the next tick, leaving further
+			// results queued meanwhile
+			queue := e.queue
+			if retrying {
+				queue = nil
+			}
+
+			select {
+			case msg := <-queue:
+				e.depth.Set(int64(len(e.queue)))
+				if batch = append(batch, msg); len(batch) < e.batchSize {
+					continue
+				}
+			case <-ticker.C:
+				if len(batch) == 0 {
+					continue
+				}
+			case <-e.shutdown:
+				e.drain(batch)
+				return
+			}
+
+			if err := e.write(context.Background(), batch); err != nil {
+				e.logger.Errorf("Failed to emit %d results, retrying: %v", len(batch), err)
+				retrying = true
+				continue
+			}
+			e.settle(batch, true)
+			batch, retrying = nil, false
+		}
+	}()
+}
+
+// drain writes the results still queued on shutdown, along with the batch
+// in flight. The logs of results that cannot be written are requeued for
+// re-delivery.
+func (e *resultEmitter) drain(batch service.MessageBatch) {
+	for len(e.queue) > 0 {
+		batch = append(batch, <-e.queue)
+	}
+	e.depth.Set(0)
+
+	for len(batch) > 0 {
+		n := min(len(batch), e.batchSize)
+		if err := e.write(context.Background(), batch[:n]); err != nil {
+			e.logger.Errorf("Failed to emit %d results on shutdown, requeueing their logs: %v", len(batch), err)
+			e.settle(batch, false)
+			return
+		}
+		e.settle(batch[:n], true)
+		batch = batch[n:]
+	}
+}
+
+// stop flushes the queued results and stops the flusher.
+func (e *resultEmitter) stop() {
+	if e == nil || e.shutdown == nil {
+		return
+	}
+	close(e.shutdown)
+	<-e.done
+}
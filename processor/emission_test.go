@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultEmitterConfig(t *testing.T) {
+	parse := func(yaml string) (*resultEmitter, error) {
+		spec := service.NewConfigSpec().Field(asyncEmissionField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newResultEmitterFromConfig(conf.Namespace("async_emission"), service.MockResources())
+	}
+
+	e, err := parse(`async_emission: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	e, err = parse(`async_emission: { output: results, queue_size: 5, batch_size: 2, flush_interval: 1s }`)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, 5, cap(e.queue))
+	assert.Equal(t, 2, e.batchSize)
+	assert.Equal(t, time.Second, e.flushInterval)
+
+	_, err = parse(`async_emission: { output: results, queue_size: 0 }`)
+	assert.Error(t, err)
+	_, err = parse(`async_emission: { output: results, batch_size: 0 }`)
+	assert.Error(t, err)
+}
+
+// recordingEmitter returns an emitter writing to batches, failing while fail
+// is set.
+func recordingEmitter(queueSize, batchSize int) (*resultEmitter, func() [][]string, func(bool)) {
+	mgr := service.MockResources()
+	var mut sync.Mutex
+	var batches [][]string
+	failing := false
+
+	e := &resultEmitter{
+		queue:         make(chan *service.Message, queueSize),
+		batchSize:     batchSize,
+		flushInterval: 10 * time.Millisecond,
+		logger:        mgr.Logger(),
+		depth:         mgr.Metrics().NewGauge("result_queue_depth"),
+	}
+	e.write = func(_ context.Context, batch service.MessageBatch) error {
+		mut.Lock()
+		defer mut.Unlock()
+		if failing {
+			return errors.New("output unavailable")
+		}
+		var bodies []string
+		for _, msg := range batch {
+			b, _ := msg.AsBytes()
+			bodies = append(bodies, string(b))
+		}
+		batches = append(batches, bodies)
+		return nil
+	}
+	written := func() [][]string {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([][]string(nil), batches...)
+	}
+	fail := func(f bool) {
+		mut.Lock()
+		failing = f
+		mut.Unlock()
+	}
+	return e, written, fail
+}
+
+func messages(bodies ...string) []*service.Message {
+	msgs := make([]*service.Message, 0, len(bodies))
+	for _, body := range bodies {
+		msgs = append(msgs, service.NewMessage([]byte(body)))
+	}
+	return msgs
+}
+
+func TestResultEmitterBatches(t *testing.T) {
+	e, written, fail := recordingEmitter(10, 2)
+	e.start()
+
+	require.NoError(t, e.enqueue(context.Background(), messages("a", "b", "c")))
+	require.Eventually(t, func() bool { return len(written()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, written())
+
+	// Failed batches are retried until the output recovers
+	fail(true)
+	require.NoError(t, e.enqueue(context.Background(), messages("d")))
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, written(), 2)
+	fail(false)
+	require.Eventually(t, func() bool { return len(written()) == 3 }, time.Second, time.Millisecond)
+	assert.Equal(t, []string{"d"}, written()[2])
+
+	e.stop()
+}
+
+func TestResultEmitterDrainsOnStop(t *testing.T) {
+	e, written, fail := recordingEmitter(10, 2)
+	fail(true)
+	e.start()
+	require.NoError(t, e.enqueue(context.Background(), messages("a", "b", "c", "d", "e")))
+
+	fail(false)
+	e.stop()
+	var bodies []string
+	for _, batch := range written() {
+		assert.LessOrEqual(t, len(batch), 2)
+		bodies = append(bodies, batch...)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, bodies)
+}
+
+func TestResultEmitterBoundsQueue(t *testing.T) {
+	// Without a flusher the queue fills up and enqueueing waits
+	e, _, _ := recordingEmitter(2, 2)
+	require.NoError(t, e.enqueue(context.Background(), messages("a", "b")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, e.enqueue(ctx, messages("c")), context.DeadlineExceeded)
+	assert.Len(t, e.queue, 2)
+}
+
+func TestResultEmitterAcksWrittenResults(t *testing.T) {
+	e, written, fail := recordingEmitter(10, 2)
+	var mut sync.Mutex
+	var acked, requeued []string
+	e.ack = func(_ context.Context, raws []string) {
+		mut.Lock()
+		acked = append(acked, raws...)
+		mut.Unlock()
+	}
+	e.requeue = func(_ context.Context, raws []string) {
+		mut.Lock()
+		requeued = append(requeued, raws...)
+		mut.Unlock()
+	}
+	settled := func() ([]string, []string) {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([]string(nil), acked...), append([]string(nil), requeued...)
+	}
+
+	// Logs are only acknowledged once their result is written
+	fail(true)
+	e.start()
+	results := messages("a", "b", "c")
+	released := 0
+	e.hold(results[0], []string{"log-a1", "log-a2"}, func(context.Context) { released++ })
+	e.hold(results[2], []string{"log-c"}, func(context.Context) { released++ })
+	require.NoError(t, e.enqueue(context.Background(), results[:1]))
+	time.Sleep(50 * time.Millisecond)
+	acks, _ := settled()
+	assert.Empty(t, acks)
+
+	fail(false)
+	require.Eventually(t, func() bool { return len(written()) == 1 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { acks, _ := settled(); return len(acks) == 2 }, time.Second, time.Millisecond)
+
+	// Results that cannot be written on shutdown have their window claims
+	// released and their logs requeued
+	fail(true)
+	require.NoError(t, e.enqueue(context.Background(), results[1:]))
+	e.stop()
+	acks, requeues := settled()
+	assert.Equal(t, []string{"log-a1", "log-a2"}, acks)
+	assert.Equal(t, []string{"log-c"}, requeues)
+	assert.Equal(t, 1, released)
+	assert.Empty(t, e.held)
+}
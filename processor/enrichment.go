@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	enrichPayloadLog = "log"
+	enrichPayloadIPs = "ips"
+
+	enrichMergeRaw    = "raw"
+	enrichMergeOutput = "output"
+)
+
+func httpEnrichmentField() *service.ConfigField {
+	fields := []*service.ConfigField{
+		service.NewStringField("url").
+			Description("Endpoint that receives a POST for every parsed log. Leave empty to disable enrichment.").
+			Default(""),
+		service.NewStringMapField("headers").
+			Description("Additional HTTP headers sent with each enrichment request").
+			Default(map[string]interface{}{}),
+		service.NewDurationField("timeout").
+			Description("Maximum time to wait for the enrichment endpoint, including the wait for a free lookup slot").
+			Default("5s"),
+		service.NewStringEnumField("payload", enrichPayloadLog, enrichPayloadIPs).
+			Description("Whether to POST the full parsed log or only its source and destination IPs").
+			Default(enrichPayloadLog),
+		service.NewStringListField("fields").
+			Description("Top-level fields of the JSON response to keep. When empty all response fields are kept.").
+			Default([]string{}),
+		service.NewStringEnumField("merge_into", enrichMergeRaw, enrichMergeOutput).
+			Description("Ignored. Selected fields are always merged into the `enrichment` object of the window result, as nothing reads the `raw` object of a log after enrichment.").
+			Default(enrichMergeOutput).
+			Deprecated(),
+	}
+	return service.NewObjectField("http_enrichment", append(fields, lookupFields()...)...).
+		Description("Generic HTTP enrichment applied to every log before windowing").
+		Advanced()
+}
+
+// httpEnricher POSTs logs to a user supplied endpoint and returns selected
+// fields of the JSON response.
+type httpEnricher struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	payload string
+	fields  []string
+	lookups *lookupExecutor
+}
+
+func newHTTPEnricherFromConfig(conf *service.ParsedConfig) (*httpEnricher, error) {
+	url, err := conf.FieldString("url")
+	if err != nil {
+		return nil, err
+	}
+	if url == "" {
+		return nil, nil
+	}
+
+	headers, err := conf.FieldStringMap("headers")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := conf.FieldString("payload")
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := conf.FieldStringList("fields")
+	if err != nil {
+		return nil, err
+	}
+
+	lookups, err := newLookupExecutorFromConfig(conf, "http_enrichment", timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &httpEnricher{
+		client:  &http.Client{Timeout: timeout},
+		url:     url,
+		headers: headers,
+		payload: payload,
+		fields:  fields,
+		lookups: lookups,
+	}, nil
+}
+
+func (e *httpEnricher) Enrich(ctx context.Context, log FirewallLog) (map[string]interface{}, error) {
+	var body interface{} = log
+	if e.payload == enrichPayloadIPs {
+		body = map[string]string{
+			"source_ip": log.SourceIP,
+			"dest_ip":   log.DestIP,
+		}
+	}
+
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("enrichment endpoint returned status %d", resp.StatusCode)
+	}
+
+	var respData map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return nil, fmt.Errorf("failed to decode enrichment response: %w", err)
+	}
+
+	if len(e.fields) == 0 {
+		return respData, nil
+	}
+
+	selected := make(map[string]interface{}, len(e.fields))
+	for _, field := range e.fields {
+		if v, exists := respData[field]; exists {
+			selected[field] = v
+		}
+	}
+	return selected, nil
+}
+
+// startEnrichment starts the lookups of the logs of a read in the
+// background, so that they run concurrently up to the limit of the enricher
+// rather than one after the other as the logs are processed. It returns a
+// function waiting for the lookups of logs that were never windowed.
+func (f *FirewallAnomalyDetector) startEnrichment(ctx context.Context, logs []FirewallLog) func() {
+	if f.enricher == nil || len(logs) < 2 {
+		return func() {}
+	}
+
+	for i := range logs {
+		f.decodeRawForEnrichment(&logs[i])
+		log := logs[i]
+		logs[i].lookup = f.enricher.lookups.start(ctx, func(ctx context.Context) (map[string]interface{}, error) {
+			return f.enricher.Enrich(ctx, log)
+		})
+	}
+	return func() {
+		for _, log := range logs {
+			_, _ = log.lookup.wait()
+		}
+	}
+}
+
+// decodeRawForEnrichment decodes the raw object of a log, which is only
+// decoded for the enricher.
+func (f *FirewallAnomalyDetector) decodeRawForEnrichment(log *FirewallLog) {
+	if err := log.decodeRaw(); err != nil {
+		f.logger.Warnf("Failed to decode raw object of log source %s: %v", log.LogSource, err)
+	}
+}
+
+// applyEnrichment runs the configured enricher against the log, or waits for
+// the lookup started for it, and returns the fields to merge into the
+// enrichment of its window. Errors are logged and never prevent the log from
+// being windowed.
+func (f *FirewallAnomalyDetector) applyEnrichment(ctx context.Context, log *FirewallLog) map[string]interface{} {
+	if f.enricher == nil {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	var err error
+	if log.lookup != nil {
+		fields, err = log.lookup.wait()
+	} else {
+		f.decodeRawForEnrichment(log)
+		fields, err = f.enricher.lookups.run(ctx, func(ctx context.Context) (map[string]interface{}, error) {
+			return f.enricher.Enrich(ctx, *log)
+		})
+	}
+	if errors.Is(err, errCircuitOpen) {
+		f.enrichmentSkipped.Incr(1)
+		return nil
+	}
+	if err != nil {
+		f.logger.Warnf("HTTP enrichment failed for log source %s: %v", log.LogSource, err)
+		f.enrichmentErrors.Incr(1)
+		return nil
+	}
+
+	return fields
+}
+
+func (f *FirewallAnomalyDetector) mergeWindowEnrichment(windowKey string, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		return
+	}
+
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	if window, exists := f.windows[windowKey]; exists {
+		mergeEnrichment(window, fields)
+	}
+}
+
+// mergeEnrichment merges enrichment fields into a window.
+func mergeEnrichment(window *WindowData, fields map[string]interface{}) {
+	if len(fields) == 0 {
+		return
+	}
+	if window.Enrichment == nil {
+		window.Enrichment = make(map[string]interface{}, len(fields))
+	}
+	for k, v := range fields {
+		window.Enrichment[k] = v
+	}
+}
@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseEnricher(t *testing.T, yaml string) *httpEnricher {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(httpEnrichmentField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	enricher, err := newHTTPEnricherFromConfig(conf.Namespace("http_enrichment"))
+	require.NoError(t, err)
+	return enricher
+}
+
+func TestHTTPEnrichmentDisabledByDefault(t *testing.T) {
+	enricher := parseEnricher(t, `http_enrichment: {}`)
+	assert.Nil(t, enricher)
+}
+
+func TestHTTPEnrichmentSelectsFields(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		_, _ = w.Write([]byte(`{"country":"NL","asn":1234,"ignored":true}`))
+	}))
+	defer server.Close()
+
+	enricher := parseEnricher(t, `
+http_enrichment:
+  url: `+server.URL+`
+  headers:
+    X-Api-Key: secret
+  payload: ips
+  fields: [ country, asn ]
+`)
+	require.NotNil(t, enricher)
+
+	detector := &FirewallAnomalyDetector{enricher: enricher}
+	log := FirewallLog{LogSource: "fortinet.firewall", SourceIP: "192.168.1.1", DestIP: "10.0.0.1"}
+
+	pending := detector.applyEnrichment(context.Background(), &log)
+	assert.Equal(t, map[string]interface{}{"source_ip": "192.168.1.1", "dest_ip": "10.0.0.1"}, received)
+	assert.Equal(t, map[string]interface{}{"country": "NL", "asn": 1234.0}, pending)
+}
+
+func TestHTTPEnrichmentMergesIntoOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"reputation":"bad"}`))
+	}))
+	defer server.Close()
+
+	// The deprecated merge_into: raw merges into the result as well
+	enricher := parseEnricher(t, `
+http_enrichment:
+  url: `+server.URL+`
+  merge_into: raw
+`)
+
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		enricher:      enricher,
+	}
+	log := FirewallLog{LogSource: "fortinet.firewall", SourceIP: "192.168.1.1"}
+
+	pending := detector.applyEnrichment(context.Background(), &log)
+	assert.Equal(t, map[string]interface{}{"reputation": "bad"}, pending)
+	assert.Nil(t, log.Raw)
+
+	detector.windowsCreated = service.MockResources().Metrics().NewCounter("windows_created")
+	detector.updateWindow(log.LogSource, 1, log.SourceIP, log.Timestamp)
+	detector.mergeWindowEnrichment(log.LogSource, pending)
+	assert.Equal(t, "bad", detector.getWindow(log.LogSource).Enrichment["reputation"])
+}
+
+func TestHTTPEnrichmentErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	enricher := parseEnricher(t, `
+http_enrichment:
+  url: `+server.URL+`
+`)
+
+	_, err := enricher.Enrich(context.Background(), FirewallLog{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,211 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	eventTimeRFC3339 = "rfc3339"
+	eventTimeUnix    = "unix"
+	eventTimeUnixMs  = "unix_ms"
+	eventTimeUnixUs  = "unix_us"
+	eventTimeUnixNs  = "unix_ns"
+
+	eventTimeFallbackIngest = "ingest_time"
+	eventTimeFallbackDrop   = "drop"
+)
+
+func eventTimeField() *service.ConfigField {
+	return service.NewObjectField("event_time",
+		service.NewStringField("field").
+			Description("Dot separated path of the field holding the event time of each log, e.g. `raw.eventtime`").
+			Default("timestamp"),
+		service.NewStringField("format").
+			Description("Format of the event time: `rfc3339`, `unix`, `unix_ms`, `unix_us` and `unix_ns` for epoch numbers or numeric strings, or a Go time layout such as `2006-01-02 15:04:05`").
+			Default(eventTimeRFC3339),
+		service.NewStringEnumField("fallback", eventTimeFallbackIngest, eventTimeFallbackDrop).
+			Description("What to do with logs whose event time is missing or unparsable: window them at the time they are read, or drop them").
+			Default(eventTimeFallbackIngest),
+		service.NewStringField("timezone").
+			Description("IANA timezone such as `Europe/Berlin` of event times logged without a UTC offset, unless their source sets its own").
+			Default("UTC"),
+	).
+		Description("Field logs are windowed by and how it is parsed").
+		Advanced()
+}
+
+func sourceTimezoneField() *service.ConfigField {
+	return service.NewStringField("timezone").
+		Description("IANA timezone such as `America/New_York` of event times this source logs without a UTC offset, overriding `event_time.timezone`").
+		Optional()
+}
+
+// eventTimeParser resolves the event time of logs, normalised to UTC.
+type eventTimeParser struct {
+	path     []string
+	format   string
+	fallback string
+
+	// location applies to event times without an offset of sources missing
+	// from locations, which is keyed by `sources` key.
+	location  *time.Location
+	locations map[string]*time.Location
+}
+
+func newEventTimeParserFromConfig(conf *service.ParsedConfig) (*eventTimeParser, error) {
+	field, err := conf.FieldString("field")
+	if err != nil {
+		return nil, err
+	}
+	if field == "" {
+		return nil, fmt.Errorf("event_time field must not be empty")
+	}
+
+	e := &eventTimeParser{path: strings.Split(field, ".")}
+	if e.format, err = conf.FieldString("format"); err != nil {
+		return nil, err
+	}
+	if e.format == "" {
+		return nil, fmt.Errorf("event_time format must not be empty")
+	}
+	if e.fallback, err = conf.FieldString("fallback"); err != nil {
+		return nil, err
+	}
+
+	timezone, err := conf.FieldString("timezone")
+	if err != nil {
+		return nil, err
+	}
+	if e.location, err = time.LoadLocation(timezone); err != nil {
+		return nil, fmt.Errorf("event_time timezone: %w", err)
+	}
+	e.locations = make(map[string]*time.Location)
+	return e, nil
+}
+
+// parseSourceTimezone returns the timezone of a source, or nil when it uses
+// the timezone of event_time.
+func parseSourceTimezone(source string, conf *service.ParsedConfig) (*time.Location, error) {
+	if !conf.Contains("timezone") {
+		return nil, nil
+	}
+	timezone, err := conf.FieldString("timezone")
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", source, err)
+	}
+	return location, nil
+}
+
+// locationOf returns the timezone of event times of a `sources` key.
+func (e *eventTimeParser) locationOf(sourceKey string) *time.Location {
+	if location, exists := e.locations[sourceKey]; exists {
+		return location
+	}
+	return e.location
+}
+
+// stamp sets the timestamp of a log from the event time of its document,
+// read in the timezone of its `sources` key when it has no offset. When the event time is missing or unparsable the log is stamped with now
+// and fellBack is true, or an error is returned when such logs are dropped.
+func (e *eventTimeParser) stamp(log *FirewallLog, doc map[string]interface{}, sourceKey string, now time.Time) (fellBack bool, err error) {
+	timestamp, err := e.parse(doc, e.locationOf(sourceKey))
+	if err == nil {
+		log.Timestamp = timestamp
+		return false, nil
+	}
+	if e.fallback == eventTimeFallbackDrop {
+		return false, err
+	}
+	log.Timestamp = now
+	return true, nil
+}
+
+// parse returns the event time of a decoded log document.
+func (e *eventTimeParser) parse(doc map[string]interface{}, location *time.Location) (time.Time, error) {
+	field := strings.Join(e.path, ".")
+
+	var v interface{} = doc
+	for _, segment := range e.path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return time.Time{}, fmt.Errorf("event time field %s is missing", field)
+		}
+		if v, ok = obj[segment]; !ok || v == nil {
+			return time.Time{}, fmt.Errorf("event time field %s is missing", field)
+		}
+	}
+
+	var raw string
+	switch value := v.(type) {
+	case string:
+		raw = value
+	case json.Number:
+		raw = value.String()
+	default:
+		return time.Time{}, fmt.Errorf("event time field %s is not a string or number", field)
+	}
+
+	t, err := parseEventTime(raw, e.format, location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("event time field %s: %w", field, err)
+	}
+	return t, nil
+}
+
+// rfc3339Local is RFC 3339 without the offset that firewalls logging local
+// time omit.
+const rfc3339Local = "2006-01-02T15:04:05.999999999"
+
+// parseEventTime parses an event time in one of the epoch formats or as a
+// time layout, in location when it has no offset, and returns it in UTC.
+// Epoch times are always UTC.
+func parseEventTime(raw, format string, location *time.Location) (time.Time, error) {
+	var unit float64
+	switch format {
+	case eventTimeRFC3339:
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			if local, localErr := time.ParseInLocation(rfc3339Local, raw, location); localErr == nil {
+				return local.UTC(), nil
+			}
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	case eventTimeUnix:
+		unit = float64(time.Second)
+	case eventTimeUnixMs:
+		unit = float64(time.Millisecond)
+	case eventTimeUnixUs:
+		unit = float64(time.Microsecond)
+	case eventTimeUnixNs:
+		unit = float64(time.Nanosecond)
+	default:
+		t, err := time.ParseInLocation(format, raw, location)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	}
+
+	// Integers are converted exactly, fractional epochs as precisely as a
+	// float allows.
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(0, n*int64(unit)).UTC(), nil
+	}
+	n, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return time.Time{}, fmt.Errorf("%q is not an epoch time", raw)
+	}
+	return time.Unix(0, int64(n*unit)).UTC(), nil
+}
@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestEventTime(t *testing.T, yaml string) *eventTimeParser {
+	t.Helper()
+
+	conf, err := service.NewConfigSpec().Field(eventTimeField()).ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	e, err := newEventTimeParserFromConfig(conf.Namespace("event_time"))
+	require.NoError(t, err)
+	return e
+}
+
+func TestParseEventTime(t *testing.T) {
+	want := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		raw    string
+		format string
+	}{
+		{"2024-01-15T10:30:00Z", eventTimeRFC3339},
+		{"1705314600", eventTimeUnix},
+		{"1705314600.0", eventTimeUnix},
+		{"1705314600000", eventTimeUnixMs},
+		{"1705314600000000", eventTimeUnixUs},
+		{"1705314600000000000", eventTimeUnixNs},
+		{"2024-01-15 10:30:00", "2006-01-02 15:04:05"},
+	}
+	for _, test := range tests {
+		got, err := parseEventTime(test.raw, test.format, time.UTC)
+		require.NoError(t, err, test.format)
+		assert.True(t, want.Equal(got), "%s: %v", test.format, got)
+	}
+
+	_, err := parseEventTime("yesterday", eventTimeUnix, time.UTC)
+	assert.Error(t, err)
+	_, err = parseEventTime("1705314600", eventTimeRFC3339, time.UTC)
+	assert.Error(t, err)
+}
+
+func TestEventTimeFromNestedField(t *testing.T) {
+	e := parseTestEventTime(t, `event_time: { field: raw.eventtime, format: unix_us }`)
+
+	log, doc, err := decodeLog(`{"timestamp":"not a time","log_source":"fortinet.firewall","connection_count":3,"raw":{"eventtime":1705314600123456}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "fortinet.firewall", log.LogSource)
+	assert.Equal(t, 3, log.ConnectionCount)
+
+	fellBack, err := e.stamp(&log, doc, "fortinet.firewall", time.Now())
+	require.NoError(t, err)
+	assert.False(t, fellBack)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 30, 0, 123456000, time.UTC), log.Timestamp)
+}
+
+func TestEventTimeFallback(t *testing.T) {
+	now := time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)
+
+	e := parseTestEventTime(t, `{}`)
+	for _, item := range []string{
+		`{"log_source":"fortinet.firewall"}`,
+		`{"timestamp":"15/01/2024","log_source":"fortinet.firewall"}`,
+		`{"timestamp":null,"log_source":"fortinet.firewall"}`,
+	} {
+		log, doc, err := decodeLog(item)
+		require.NoError(t, err)
+		fellBack, err := e.stamp(&log, doc, "fortinet.firewall", now)
+		require.NoError(t, err)
+		assert.True(t, fellBack, item)
+		assert.Equal(t, now, log.Timestamp)
+	}
+
+	e = parseTestEventTime(t, `event_time: { fallback: drop }`)
+	log, doc, err := decodeLog(`{"timestamp":"15/01/2024","log_source":"fortinet.firewall"}`)
+	require.NoError(t, err)
+	_, err = e.stamp(&log, doc, "fortinet.firewall", now)
+	assert.Error(t, err)
+
+	_, _, err = decodeLog(`{"log_source":`)
+	assert.Error(t, err)
+}
+
+func TestEventTimeZones(t *testing.T) {
+	e := parseTestEventTime(t, `event_time: { format: "2006-01-02 15:04:05", timezone: Europe/Berlin }`)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	e.locations["paloalto.firewall"] = newYork
+
+	stamp := func(item, sourceKey string) time.Time {
+		log, doc, err := decodeLog(item)
+		require.NoError(t, err)
+		_, err = e.stamp(&log, doc, sourceKey, time.Now())
+		require.NoError(t, err)
+		return log.Timestamp
+	}
+
+	// Local times are normalised to UTC so that windows of sources in
+	// different regions line up
+	berlin := stamp(`{"timestamp":"2024-01-15 10:30:00"}`, "fortinet.firewall")
+	assert.Equal(t, time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC), berlin)
+	assert.Equal(t, time.UTC, berlin.Location())
+	assert.Equal(t, time.Date(2024, 1, 15, 15, 30, 0, 0, time.UTC), stamp(`{"timestamp":"2024-01-15 10:30:00"}`, "paloalto.firewall"))
+
+	// Offsets in the event time take precedence over the timezone
+	local, err := parseEventTime("2024-01-15T10:30:00", eventTimeRFC3339, newYork)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 15, 15, 30, 0, 0, time.UTC), local)
+	offset, err := parseEventTime("2024-01-15T10:30:00+01:00", eventTimeRFC3339, newYork)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC), offset)
+	epoch, err := parseEventTime("1705314600", eventTimeUnix, newYork)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC), epoch)
+
+	conf, err := service.NewConfigSpec().Field(sourceTimezoneField()).ParseYAML(`timezone: Mars/Olympus_Mons`, nil)
+	require.NoError(t, err)
+	_, err = parseSourceTimezone("fortinet.firewall", conf)
+	assert.Error(t, err)
+}
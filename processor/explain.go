@@ -0,0 +1,194 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// ExplainedWindow is the scoring decision of a window of an explained file.
+type ExplainedWindow struct {
+	Source       string             `json:"log_source"`
+	Tenant       string             `json:"tenant,omitempty"`
+	WindowStart  time.Time          `json:"window_start"`
+	WindowEnd    time.Time          `json:"window_end"`
+	Samples      int                `json:"samples"`
+	Features     map[string]float64 `json:"features"`
+	AnomalyScore float64            `json:"anomaly_score"`
+	Threshold    float64            `json:"threshold"`
+	IsAnomaly    bool               `json:"is_anomaly"`
+	Suppressed   bool               `json:"suppressed,omitempty"`
+	SuppressedBy string             `json:"suppressed_by,omitempty"`
+	Final        bool               `json:"final,omitempty"`
+}
+
+// MarshalJSON writes features that are not finite, such as the standard
+// deviation of a single sample, as null.
+func (w ExplainedWindow) MarshalJSON() ([]byte, error) {
+	type window ExplainedWindow
+	return json.Marshal(struct {
+		window
+		Features map[string]interface{} `json:"features"`
+	}{window(w), finiteFeatures(w.Features)})
+}
+
+// UnmarshalJSON reads features written as null as NaN.
+func (w *ExplainedWindow) UnmarshalJSON(b []byte) error {
+	type window ExplainedWindow
+	var decoded struct {
+		window
+		Features map[string]*float64 `json:"features"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return err
+	}
+	*w = ExplainedWindow(decoded.window)
+	w.Features = make(map[string]float64, len(decoded.Features))
+	for name, v := range decoded.Features {
+		if v == nil {
+			w.Features[name] = math.NaN()
+			continue
+		}
+		w.Features[name] = *v
+	}
+	return nil
+}
+
+// DroppedLog is a log of an explained file that never reached a window.
+type DroppedLog struct {
+	// Line is the line of the log in a JSON lines file, or its position in
+	// a JSON array, counted from 1.
+	Line   int    `json:"line"`
+	Source string `json:"log_source,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ExplainReport is the result of explaining a log file.
+type ExplainReport struct {
+	Logs    int               `json:"logs"`
+	Windows []ExplainedWindow `json:"windows"`
+	Dropped []DroppedLog      `json:"dropped"`
+}
+
+// Explain runs the logs of a single file through the parsing, windowing,
+// feature extraction and scoring of the first firewall_anomaly_detector
+// processor of a config, reporting the features, score and threshold of
+// every window and why logs were dropped. The file holds JSON lines or a
+// JSON array of logs, optionally gzip compressed. Like a replay the clock
+// follows event time, so the same file and config always produce the same
+// report.
+func Explain(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), logs io.Reader) (ExplainReport, error) {
+	items, err := readLogFile(logs)
+	if err != nil {
+		return ExplainReport{}, err
+	}
+	return explainLogs(ctx, env, confYAML, lookupEnv, items)
+}
+
+// explainLogs explains logs numbered by their position, where empty logs
+// only take up a number.
+func explainLogs(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), items []string) (ExplainReport, error) {
+	report := ExplainReport{Windows: []ExplainedWindow{}, Dropped: []DroppedLog{}}
+
+	detector, err := newOfflineDetector(env, confYAML, lookupEnv)
+	if err != nil {
+		return report, err
+	}
+	defer detector.Close(ctx)
+
+	// Nothing of an explanation leaves the process
+	detector.features = nil
+
+	var watermark time.Time
+	detector.clock = func() time.Time { return watermark }
+
+	line := 0
+	detector.onDropped = func(source, reason string) {
+		report.Dropped = append(report.Dropped, DroppedLog{Line: line, Source: source, Reason: reason})
+	}
+	detector.onDecided = func(decision auditRecord) {
+		report.Windows = append(report.Windows, ExplainedWindow{
+			Source:       decision.Source,
+			Tenant:       decision.Tenant,
+			WindowStart:  decision.WindowStart,
+			WindowEnd:    decision.WindowEnd,
+			Samples:      decision.Samples,
+			Features:     decision.Features,
+			AnomalyScore: decision.AnomalyScore,
+			Threshold:    decision.Threshold,
+			IsAnomaly:    decision.IsAnomaly,
+			Suppressed:   decision.Suppressed,
+			SuppressedBy: decision.SuppressedBy,
+			Final:        decision.Final,
+		})
+	}
+
+	for i, item := range items {
+		line = i + 1
+		if item == "" {
+			continue
+		}
+		report.Logs++
+
+		log, ok := detector.parseLog(ctx, item)
+		if !ok {
+			continue
+		}
+		if log.Timestamp.After(watermark) {
+			watermark = log.Timestamp
+		}
+		if _, err := detector.processLog(ctx, log); err != nil {
+			return report, err
+		}
+	}
+	line = 0
+	err = detector.flushRemaining(ctx, func(*service.Message) error { return nil })
+	return report, err
+}
+
+// readLogFile reads the logs of a JSON lines file or of a JSON array,
+// decompressing gzip files. Blank lines are kept as empty logs so that logs
+// are numbered by their line.
+func readLogFile(r io.Reader) ([]string, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var logs []json.RawMessage
+		if err := json.Unmarshal(trimmed, &logs); err != nil {
+			return nil, fmt.Errorf("reading JSON array of logs: %w", err)
+		}
+		items := make([]string, len(logs))
+		for i, log := range logs {
+			items[i] = string(log)
+		}
+		return items, nil
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines, nil
+}
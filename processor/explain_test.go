@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func explainTestLogs() []string {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var logs []string
+	for i := 0; i < 10; i++ {
+		logs = append(logs, fmt.Sprintf(`{"timestamp":%q,"log_source":"fortinet.firewall","source_ip":"10.0.0.%d","connection_count":%d}`,
+			start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i%3, 10*(i+1)))
+	}
+	logs = append(logs, `not json`, `{"timestamp":"2024-01-15T10:00:05Z","log_source":"unknown.firewall","connection_count":1}`)
+	return logs
+}
+
+func TestExplain(t *testing.T) {
+	report, err := Explain(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv,
+		strings.NewReader(strings.Join(explainTestLogs(), "\n")+"\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, 12, report.Logs)
+	assert.Equal(t, []DroppedLog{
+		{Line: 11, Reason: dropReasonParseFailure},
+		{Line: 12, Source: "unknown.firewall", Reason: dropReasonUnknownSource},
+	}, report.Dropped)
+
+	require.Len(t, report.Windows, 1)
+	window := report.Windows[0]
+	assert.Equal(t, "fortinet.firewall", window.Source)
+	assert.Equal(t, 10, window.Samples)
+	assert.Equal(t, 55.0, window.Features["mean_value"])
+	assert.Equal(t, 100.0, window.Features["max_value"])
+	assert.Equal(t, 0.7, window.Threshold)
+	assert.True(t, window.Final)
+
+	// The same file explains the same way every time
+	again, err := Explain(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv,
+		strings.NewReader(strings.Join(explainTestLogs(), "\n")))
+	require.NoError(t, err)
+	assert.Equal(t, report, again)
+}
+
+func TestReadLogFile(t *testing.T) {
+	items, err := readLogFile(strings.NewReader("{\"a\":1}\n\n {\"a\":2} \n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, "", `{"a":2}`, ""}, items)
+
+	items, err = readLogFile(strings.NewReader(` [{"a":1}, {"a":2}]`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`, `{"a":2}`}, items)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write([]byte(`[{"a":1}]`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	items, err = readLogFile(&compressed)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}`}, items)
+
+	_, err = readLogFile(strings.NewReader(`[{"a":1}`))
+	assert.Error(t, err)
+}
+
+func TestExplainedWindowJSON(t *testing.T) {
+	b, err := json.Marshal(ExplainedWindow{Source: "fortinet.firewall", Features: map[string]float64{"mean_value": 10, "std_dev": math.NaN()}})
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"features":{"mean_value":10,"std_dev":null}`)
+	assert.Equal(t, 1, strings.Count(string(b), `"features"`))
+}
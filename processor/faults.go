@@ -0,0 +1,153 @@
+package processor
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Faults counted by the fault label of faults_injected.
+const (
+	faultMalformed    = "malformed"
+	faultDuplicate    = "duplicate"
+	faultOutOfOrder   = "out_of_order"
+	faultRedisLatency = "redis_latency"
+)
+
+func faultInjectionField() *service.ConfigField {
+	return service.NewObjectField("fault_injection",
+		service.NewFloatField("malformed_rate").
+			Description("Fraction of logs read that are corrupted into invalid JSON before parsing").
+			Default(0.0),
+		service.NewFloatField("duplicate_rate").
+			Description("Fraction of logs read that are delivered twice in the same read").
+			Default(0.0),
+		service.NewFloatField("out_of_order_rate").
+			Description("Fraction of logs read that are held back and delivered in reverse order at the start of the next read, as a burst of late logs").
+			Default(0.0),
+		service.NewDurationField("redis_latency").
+			Description("Maximum latency added to every read from Redis, each read being delayed by a random duration up to it").
+			Default("0s"),
+		service.NewIntField("seed").
+			Description("Seed of the random faults, so that a test sees the same faults on every run. Zero seeds from the clock.").
+			Default(0),
+	).
+		Description("Injects faults into the logs read from Redis to verify that the detector degrades gracefully. Meant for test configs only, never enable it in production.").
+		Advanced()
+}
+
+// faultInjector corrupts, duplicates, reorders and delays the logs read from
+// Redis.
+type faultInjector struct {
+	malformedRate  float64
+	duplicateRate  float64
+	outOfOrderRate float64
+	redisLatency   time.Duration
+
+	injected *service.MetricCounter
+	sleep    func(ctx context.Context, d time.Duration)
+
+	mut  sync.Mutex
+	rand *rand.Rand
+	held []string
+}
+
+func newFaultInjectorFromConfig(conf *service.ParsedConfig, metrics *service.Metrics) (*faultInjector, error) {
+	malformedRate, err := conf.FieldFloat("malformed_rate")
+	if err != nil {
+		return nil, err
+	}
+	duplicateRate, err := conf.FieldFloat("duplicate_rate")
+	if err != nil {
+		return nil, err
+	}
+	outOfOrderRate, err := conf.FieldFloat("out_of_order_rate")
+	if err != nil {
+		return nil, err
+	}
+	redisLatency, err := conf.FieldDuration("redis_latency")
+	if err != nil {
+		return nil, err
+	}
+	if malformedRate <= 0 && duplicateRate <= 0 && outOfOrderRate <= 0 && redisLatency <= 0 {
+		return nil, nil
+	}
+
+	seed, err := conf.FieldInt("seed")
+	if err != nil {
+		return nil, err
+	}
+	if seed == 0 {
+		seed = int(time.Now().UnixNano())
+	}
+
+	return &faultInjector{
+		malformedRate:  malformedRate,
+		duplicateRate:  duplicateRate,
+		outOfOrderRate: outOfOrderRate,
+		redisLatency:   redisLatency,
+		injected:       metrics.NewCounter("faults_injected", "fault"),
+		sleep:          sleepContext,
+		rand:           rand.New(rand.NewSource(int64(seed))),
+	}, nil
+}
+
+// sleepContext sleeps for d or until the context is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// delayRead adds the injected latency of a read from Redis.
+func (i *faultInjector) delayRead(ctx context.Context) {
+	if i == nil || i.redisLatency <= 0 {
+		return
+	}
+	i.mut.Lock()
+	delay := time.Duration(i.rand.Int63n(int64(i.redisLatency) + 1))
+	i.mut.Unlock()
+	i.injected.Incr(1, faultRedisLatency)
+	i.sleep(ctx, delay)
+}
+
+// inject applies the log faults to a read. corrupted receives the logs that
+// were replaced with malformed copies, which are never processed under their
+// original form.
+func (i *faultInjector) inject(items []string, corrupted func(original string)) []string {
+	if i == nil {
+		return items
+	}
+	i.mut.Lock()
+	defer i.mut.Unlock()
+
+	// Logs held back by the previous read arrive first, newest first
+	out := make([]string, 0, len(items)+len(i.held))
+	for j := len(i.held) - 1; j >= 0; j-- {
+		out = append(out, i.held[j])
+	}
+	i.held = i.held[:0]
+
+	for _, item := range items {
+		if i.rand.Float64() < i.outOfOrderRate {
+			i.held = append(i.held, item)
+			i.injected.Incr(1, faultOutOfOrder)
+			continue
+		}
+		if i.rand.Float64() < i.malformedRate {
+			corrupted(item)
+			item = item[:len(item)/2] + "\x00}"
+			i.injected.Incr(1, faultMalformed)
+		}
+		out = append(out, item)
+		if i.rand.Float64() < i.duplicateRate {
+			out = append(out, item)
+			i.injected.Incr(1, faultDuplicate)
+		}
+	}
+	return out
+}
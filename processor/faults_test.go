@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFaultInjector(t *testing.T, yaml string) *faultInjector {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(faultInjectionField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	faults, err := newFaultInjectorFromConfig(conf.Namespace("fault_injection"), service.MockResources().Metrics())
+	require.NoError(t, err)
+	return faults
+}
+
+func TestFaultInjectionDisabledByDefault(t *testing.T) {
+	faults := parseFaultInjector(t, `fault_injection: {}`)
+	assert.Nil(t, faults)
+
+	// A disabled injector passes reads through
+	assert.Equal(t, []string{"a", "b"}, faults.inject([]string{"a", "b"}, nil))
+	faults.delayRead(context.Background())
+}
+
+func TestFaultInjection(t *testing.T) {
+	items := []string{`{"n":1}`, `{"n":2}`, `{"n":3}`}
+
+	faults := parseFaultInjector(t, `
+fault_injection:
+  malformed_rate: 1
+  seed: 1
+`)
+	var corrupted []string
+	out := faults.inject(items, func(original string) { corrupted = append(corrupted, original) })
+	assert.Equal(t, items, corrupted)
+	require.Len(t, out, 3)
+	for _, item := range out {
+		assert.False(t, json.Valid([]byte(item)), item)
+	}
+
+	faults = parseFaultInjector(t, `
+fault_injection:
+  duplicate_rate: 1
+  seed: 1
+`)
+	assert.Equal(t, []string{`{"n":1}`, `{"n":1}`, `{"n":2}`, `{"n":2}`, `{"n":3}`, `{"n":3}`}, faults.inject(items, nil))
+
+	faults = parseFaultInjector(t, `
+fault_injection:
+  out_of_order_rate: 1
+  seed: 1
+`)
+	assert.Empty(t, faults.inject(items, nil))
+	faults.outOfOrderRate = 0
+	assert.Equal(t, []string{`{"n":3}`, `{"n":2}`, `{"n":1}`, `{"n":4}`}, faults.inject([]string{`{"n":4}`}, nil))
+
+	faults = parseFaultInjector(t, `
+fault_injection:
+  redis_latency: 50ms
+  seed: 1
+`)
+	var slept time.Duration
+	faults.sleep = func(_ context.Context, d time.Duration) { slept = d }
+	faults.delayRead(context.Background())
+	assert.LessOrEqual(t, slept, 50*time.Millisecond)
+}
+
+func TestFaultInjectionDegradesGracefully(t *testing.T) {
+	faults := parseFaultInjector(t, `
+fault_injection:
+  malformed_rate: 0.2
+  duplicate_rate: 0.2
+  out_of_order_rate: 0.3
+  seed: 7
+`)
+
+	detector, err := newOfflineDetector(service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv)
+	require.NoError(t, err)
+	defer detector.Close(context.Background())
+
+	dropped := map[string]int{}
+	detector.onDropped = func(_, reason string) { dropped[reason]++ }
+	samples := 0
+	detector.onDecided = func(decision auditRecord) { samples += decision.Samples }
+
+	// Reads of ten logs each, the last one empty to release held back logs
+	logs := explainTestLogs()[:10]
+	ctx := context.Background()
+	delivered := 0
+	for _, read := range [][]string{logs[:5], logs[5:], nil} {
+		for _, item := range faults.inject(read, func(string) {}) {
+			delivered++
+			log, ok := detector.parseLog(ctx, item)
+			if !ok {
+				continue
+			}
+			_, err := detector.processLog(ctx, log)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, detector.flushRemaining(ctx, func(*service.Message) error { return nil }))
+
+	// Every delivered log is either windowed or dropped as malformed
+	assert.NotZero(t, dropped[dropReasonParseFailure])
+	assert.Equal(t, delivered, samples+dropped[dropReasonParseFailure])
+}
+
+func TestValidateFaultInjection(t *testing.T) {
+	issues, err := ValidateConfig(service.NewEnvironment(), []byte(`
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        model_path: ""
+        fault_injection:
+          duplicate_rate: 1.5
+          redis_latency: 10ms
+`), os.LookupEnv)
+	require.NoError(t, err)
+
+	var paths []string
+	for _, issue := range issues {
+		paths = append(paths, issue.Severity+" "+issue.Path)
+	}
+	assert.Contains(t, paths, IssueError+" pipeline.processors.0.firewall_anomaly_detector.fault_injection.duplicate_rate")
+	assert.Contains(t, paths, IssueWarning+" pipeline.processors.0.firewall_anomaly_detector.fault_injection")
+}
@@ -0,0 +1,228 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func feastField() *service.ConfigField {
+	return service.NewObjectField("feast",
+		service.NewStringField("url").
+			Description("Base URL of the Feast feature server, e.g. `http://feast:6566`. Leave empty to push nothing.").
+			Default(""),
+		service.NewStringField("push_source").
+			Description("Name of the Feast push source the features are pushed to").
+			Default("firewall_window_features"),
+		service.NewStringEnumField("to", "online", "online_and_offline").
+			Description("Stores the features are pushed to").
+			Default("online"),
+		service.NewStringField("entity_column").
+			Description("Column holding the entity key of a row: the log source, prefixed by `<tenant>/` when `tenant_field` is set").
+			Default("log_source"),
+		service.NewDurationField("interval").
+			Description("How often the latest features of every source are pushed").
+			Default("10s"),
+		service.NewStringMapField("headers").
+			Description("Additional HTTP headers sent with each push, e.g. for an authenticating proxy").
+			Default(map[string]interface{}{}),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single push").
+			Default("5s"),
+	).
+		Description("Pushes the features of the latest scored window of every source into a Feast online store through the feature server, for other ML services to consume in real time").
+		Advanced()
+}
+
+// feastRow holds the features of the latest scored window of a window key.
+type feastRow struct {
+	windowEnd    time.Time
+	features     map[string]float64
+	anomalyScore float64
+}
+
+// feastPusher pushes the latest features of every window key to a Feast
+// push source from a background loop.
+type feastPusher struct {
+	logger       *service.Logger
+	client       *http.Client
+	url          string
+	pushSource   string
+	to           string
+	entityColumn string
+	headers      map[string]string
+	interval     time.Duration
+
+	pushed *service.MetricCounter
+	failed *service.MetricCounter
+
+	mut    sync.Mutex
+	latest map[string]feastRow // window key -> row
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newFeastPusherFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*feastPusher, error) {
+	url, err := conf.FieldString("url")
+	if err != nil || url == "" {
+		return nil, err
+	}
+
+	p := &feastPusher{
+		logger: mgr.Logger(),
+		url:    strings.TrimSuffix(url, "/"),
+		pushed: mgr.Metrics().NewCounter("feast_rows_pushed"),
+		failed: mgr.Metrics().NewCounter("feast_pushes_failed"),
+		latest: make(map[string]feastRow),
+	}
+	if p.pushSource, err = conf.FieldString("push_source"); err != nil {
+		return nil, err
+	}
+	if p.to, err = conf.FieldString("to"); err != nil {
+		return nil, err
+	}
+	if p.entityColumn, err = conf.FieldString("entity_column"); err != nil {
+		return nil, err
+	}
+	if p.pushSource == "" || p.entityColumn == "" {
+		return nil, errors.New("feast.push_source and feast.entity_column must not be empty")
+	}
+	if p.interval, err = conf.FieldDuration("interval"); err != nil {
+		return nil, err
+	}
+	if p.interval <= 0 {
+		return nil, fmt.Errorf("feast.interval must be positive, got %v", p.interval)
+	}
+	if p.headers, err = conf.FieldStringMap("headers"); err != nil {
+		return nil, err
+	}
+	timeout, err := conf.FieldDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+	p.client = &http.Client{Timeout: timeout}
+	return p, nil
+}
+
+// observe replaces the latest features of a window key, unless they are of
+// an older window.
+func (p *feastPusher) observe(windowKey string, windowEnd time.Time, features map[string]float64, anomalyScore float64) {
+	if p == nil {
+		return
+	}
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if latest, exists := p.latest[windowKey]; exists && latest.windowEnd.After(windowEnd) {
+		return
+	}
+	p.latest[windowKey] = feastRow{windowEnd: windowEnd, features: features, anomalyScore: anomalyScore}
+}
+
+func (p *feastPusher) start() {
+	if p == nil {
+		return
+	}
+	p.shutdown = make(chan struct{})
+	p.done = make(chan struct{})
+	go p.loop()
+}
+
+func (p *feastPusher) loop() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.push(context.Background())
+		case <-p.shutdown:
+			return
+		}
+	}
+}
+
+// push sends the rows observed since the last push. Failed rows are kept
+// for the next push unless newer windows replace them.
+func (p *feastPusher) push(ctx context.Context) {
+	p.mut.Lock()
+	rows := p.latest
+	p.latest = make(map[string]feastRow, len(rows))
+	p.mut.Unlock()
+	if len(rows) == 0 {
+		return
+	}
+
+	if err := postAlertJSON(ctx, p.client, p.url+"/push", p.headers, map[string]interface{}{
+		"push_source_name": p.pushSource,
+		"df":               p.frame(rows),
+		"to":               p.to,
+	}); err != nil {
+		p.logger.Errorf("Failed to push the features of %d sources to Feast: %v", len(rows), err)
+		p.failed.Incr(1)
+		for key, row := range rows {
+			p.observe(key, row.windowEnd, row.features, row.anomalyScore)
+		}
+		return
+	}
+	p.pushed.Incr(int64(len(rows)))
+}
+
+// frame returns the rows as the columnar data frame Feast expects. Features
+// a source lacks are null.
+func (p *feastPusher) frame(rows map[string]feastRow) map[string][]interface{} {
+	keys := make([]string, 0, len(rows))
+	names := make(map[string]struct{})
+	for key, row := range rows {
+		keys = append(keys, key)
+		for name := range row.features {
+			names[name] = struct{}{}
+		}
+	}
+	sort.Strings(keys)
+
+	df := map[string][]interface{}{
+		p.entityColumn:    make([]interface{}, 0, len(keys)),
+		"event_timestamp": make([]interface{}, 0, len(keys)),
+		"anomaly_score":   make([]interface{}, 0, len(keys)),
+	}
+	for name := range names {
+		df[name] = make([]interface{}, 0, len(keys))
+	}
+	for _, key := range keys {
+		row := rows[key]
+		df[p.entityColumn] = append(df[p.entityColumn], key)
+		df["event_timestamp"] = append(df["event_timestamp"], row.windowEnd.UTC().Format(time.RFC3339Nano))
+		df["anomaly_score"] = append(df["anomaly_score"], row.anomalyScore)
+		for name := range names {
+			if value, exists := row.features[name]; exists {
+				df[name] = append(df[name], value)
+			} else {
+				df[name] = append(df[name], nil)
+			}
+		}
+	}
+	return df
+}
+
+// Close stops the background loop and pushes the remaining rows.
+func (p *feastPusher) Close(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	if p.shutdown != nil {
+		close(p.shutdown)
+		<-p.done
+	}
+	p.push(ctx)
+}
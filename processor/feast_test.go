@@ -0,0 +1,91 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseFeastPusher(t *testing.T, yaml string) (*feastPusher, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(feastField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newFeastPusherFromConfig(conf.Namespace("feast"), service.MockResources())
+}
+
+func TestFeastConfig(t *testing.T) {
+	p, err := parseFeastPusher(t, `feast: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = parseFeastPusher(t, `feast: { url: "http://feast:6566", push_source: "" }`)
+	assert.Error(t, err)
+	_, err = parseFeastPusher(t, `feast: { url: "http://feast:6566", interval: 0s }`)
+	assert.Error(t, err)
+}
+
+func TestFeastPush(t *testing.T) {
+	var payload map[string]interface{}
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/push", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Api-Key"))
+		payload = nil
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p, err := parseFeastPusher(t, `
+feast:
+  url: `+server.URL+`/
+  to: online_and_offline
+  headers:
+    X-Api-Key: secret
+`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	end := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+	p.observe("fortinet.firewall", end, map[string]float64{"mean_value": 10, "unique_ips": 3}, 0.2)
+	p.observe("fortinet.firewall", end.Add(time.Minute), map[string]float64{"mean_value": 40, "unique_ips": 5}, 0.9)
+	// Late windows do not replace newer ones
+	p.observe("fortinet.firewall", end.Add(-time.Minute), map[string]float64{"mean_value": 1, "unique_ips": 1}, 0.1)
+	p.observe("acme/paloalto.firewall", end, map[string]float64{"mean_value": 7}, 0.1)
+
+	p.push(ctx)
+	assert.Equal(t, "firewall_window_features", payload["push_source_name"])
+	assert.Equal(t, "online_and_offline", payload["to"])
+	assert.Equal(t, map[string]interface{}{
+		"log_source":      []interface{}{"acme/paloalto.firewall", "fortinet.firewall"},
+		"event_timestamp": []interface{}{"2024-01-15T10:01:00Z", "2024-01-15T10:02:00Z"},
+		"anomaly_score":   []interface{}{0.1, 0.9},
+		"mean_value":      []interface{}{7.0, 40.0},
+		"unique_ips":      []interface{}{nil, 5.0},
+	}, payload["df"])
+
+	// Nothing is pushed without new windows
+	payload = nil
+	p.push(ctx)
+	assert.Nil(t, payload)
+
+	// Failed rows are retried with the next push
+	status = http.StatusInternalServerError
+	p.observe("fortinet.firewall", end.Add(2*time.Minute), map[string]float64{"mean_value": 50}, 0.3)
+	p.push(ctx)
+	assert.Len(t, p.latest, 1)
+
+	status = http.StatusOK
+	p.Close(ctx)
+	assert.Empty(t, p.latest)
+	assert.Equal(t, []interface{}{50.0}, payload["df"].(map[string]interface{})["mean_value"])
+}
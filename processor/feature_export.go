@@ -0,0 +1,351 @@
+package processor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	featureExportCSV     = "csv"
+	featureExportParquet = "parquet"
+)
+
+func featureExportField() *service.ConfigField {
+	return service.NewObjectField("feature_export",
+		service.NewStringField("directory").
+			Description("Directory feature vectors are written to, in a file per source that is rotated every `rotate_interval`").
+			Default(""),
+		service.NewStringEnumField("format", featureExportCSV, featureExportParquet).
+			Description("Format of the exported files. Parquet files are only readable once rotated or closed on shutdown.").
+			Default(featureExportCSV),
+		service.NewDurationField("rotate_interval").
+			Description("Interval after which a new file is started for a source").
+			Default("1h"),
+		service.NewStringField("topic").
+			Description("Topic feature vectors are routed to, as JSON documents").
+			Default(""),
+	).
+		Description("Exports the feature vector of every scored window, normal or anomalous, for offline training of the model on exactly the features the detector computes. Disabled unless `directory` or `topic` is set.").
+		Advanced()
+}
+
+// featureRow is the exported feature vector of one scored window.
+type featureRow struct {
+	windowID     string
+	source       string
+	tenant       string
+	windowStart  time.Time
+	windowEnd    time.Time
+	samples      int
+	features     map[string]float64
+	anomalyScore float64
+	threshold    float64
+	isAnomaly    bool
+}
+
+// featureRowColumns are the columns preceding the features of a row.
+var featureRowColumns = []string{
+	"log_source",
+	"tenant",
+	"window_start",
+	"window_end",
+	"samples",
+	"anomaly_score",
+	"threshold",
+	"is_anomaly",
+}
+
+// featureExporter writes the feature vectors of scored windows to rotated
+// files and/or queues them for the export topic.
+type featureExporter struct {
+	directory      string
+	format         string
+	rotateInterval time.Duration
+	topic          string
+
+	mut     sync.Mutex
+	files   map[string]*featureFile
+	pending []*service.Message
+}
+
+func newFeatureExporterFromConfig(conf *service.ParsedConfig) (*featureExporter, error) {
+	directory, err := conf.FieldString("directory")
+	if err != nil {
+		return nil, err
+	}
+	topic, err := conf.FieldString("topic")
+	if err != nil {
+		return nil, err
+	}
+	if directory == "" && topic == "" {
+		return nil, nil
+	}
+
+	format, err := conf.FieldString("format")
+	if err != nil {
+		return nil, err
+	}
+	rotateInterval, err := conf.FieldDuration("rotate_interval")
+	if err != nil {
+		return nil, err
+	}
+	if rotateInterval <= 0 {
+		return nil, fmt.Errorf("feature_export rotate_interval must be positive, got %v", rotateInterval)
+	}
+
+	if directory != "" {
+		if err := os.MkdirAll(directory, 0o750); err != nil {
+			return nil, err
+		}
+	}
+	return &featureExporter{
+		directory:      directory,
+		format:         format,
+		rotateInterval: rotateInterval,
+		topic:          topic,
+		files:          make(map[string]*featureFile),
+	}, nil
+}
+
+// export writes the feature vector of a window. Files are kept per source
+// key, since every source matching a key shares the same features.
+func (e *featureExporter) export(sourceKey string, row featureRow, now time.Time) error {
+	if e == nil {
+		return nil
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if e.topic != "" {
+		msg := service.NewMessage(nil)
+		msg.SetStructured(row.document())
+		msg.MetaSet("topic", e.topic)
+		msg.MetaSet("feature_export", "true")
+		e.pending = append(e.pending, msg)
+	}
+	if e.directory == "" {
+		return nil
+	}
+
+	names := sortedFeatureNames(row.features)
+	file := e.files[sourceKey]
+	if file != nil && (now.Sub(file.opened) >= e.rotateInterval || !slices.Equal(file.features, names)) {
+		delete(e.files, sourceKey)
+		if err := file.close(); err != nil {
+			return err
+		}
+		file = nil
+	}
+	if file == nil {
+		var err error
+		if file, err = e.open(sourceKey, names, now); err != nil {
+			return err
+		}
+		e.files[sourceKey] = file
+	}
+	return file.write(row)
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// open starts a new file for a source key, named after the time it was
+// opened.
+func (e *featureExporter) open(sourceKey string, names []string, now time.Time) (*featureFile, error) {
+	dir := filepath.Join(e.directory, unsafePathChars.ReplaceAllString(sourceKey, "_"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, "features-"+now.UTC().Format("20060102T150405.000000000Z")+"."+e.format)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &featureFile{file: f, features: names, opened: now}
+	if e.format == featureExportParquet {
+		file.schema = featureParquetSchema(names)
+		file.parquet = parquet.NewWriter(f, file.schema)
+		return file, nil
+	}
+
+	file.csv = csv.NewWriter(f)
+	if err := file.csv.Write(append(append([]string{}, featureRowColumns...), names...)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// drain returns the feature vectors queued for the export topic.
+func (e *featureExporter) drain() []*service.Message {
+	if e == nil {
+		return nil
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	pending := e.pending
+	e.pending = nil
+	return pending
+}
+
+// Close closes every open file, completing Parquet files.
+func (e *featureExporter) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	var firstErr error
+	for key, file := range e.files {
+		if err := file.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(e.files, key)
+	}
+	return firstErr
+}
+
+// document returns the JSON document of a row for the export topic.
+func (r featureRow) document() map[string]interface{} {
+	doc := map[string]interface{}{
+		"log_source":    r.source,
+		"window_start":  r.windowStart,
+		"window_end":    r.windowEnd,
+		"samples":       r.samples,
+		"anomaly_score": r.anomalyScore,
+		"threshold":     r.threshold,
+		"is_anomaly":    r.isAnomaly,
+		"features":      r.features,
+	}
+	if r.tenant != "" {
+		doc["tenant"] = r.tenant
+	}
+	return doc
+}
+
+// featureFile is an open export file with a fixed set of feature columns.
+type featureFile struct {
+	file     *os.File
+	features []string
+	opened   time.Time
+
+	csv     *csv.Writer
+	parquet *parquet.Writer
+	schema  *parquet.Schema
+}
+
+func (f *featureFile) write(row featureRow) error {
+	if f.parquet != nil {
+		_, err := f.parquet.WriteRows([]parquet.Row{f.parquetRow(row)})
+		return err
+	}
+
+	record := []string{
+		row.source,
+		row.tenant,
+		row.windowStart.UTC().Format(time.RFC3339Nano),
+		row.windowEnd.UTC().Format(time.RFC3339Nano),
+		strconv.Itoa(row.samples),
+		strconv.FormatFloat(row.anomalyScore, 'g', -1, 64),
+		strconv.FormatFloat(row.threshold, 'g', -1, 64),
+		strconv.FormatBool(row.isAnomaly),
+	}
+	for _, name := range f.features {
+		record = append(record, strconv.FormatFloat(row.features[name], 'g', -1, 64))
+	}
+	if err := f.csv.Write(record); err != nil {
+		return err
+	}
+	// Rows are flushed as they are written so that open files can be read
+	// while training
+	f.csv.Flush()
+	return f.csv.Error()
+}
+
+// featureParquetSchema returns the Parquet schema of rows with the given
+// features.
+func featureParquetSchema(names []string) *parquet.Schema {
+	return parquet.NewSchema("features", featureParquetGroup(names))
+}
+
+// featureParquetGroup returns the Parquet columns of rows with the given
+// features.
+func featureParquetGroup(names []string) parquet.Group {
+	group := parquet.Group{
+		"log_source":    parquet.String(),
+		"tenant":        parquet.String(),
+		"window_start":  parquet.Timestamp(parquet.Millisecond),
+		"window_end":    parquet.Timestamp(parquet.Millisecond),
+		"samples":       parquet.Int(64),
+		"anomaly_score": parquet.Leaf(parquet.DoubleType),
+		"threshold":     parquet.Leaf(parquet.DoubleType),
+		"is_anomaly":    parquet.Leaf(parquet.BooleanType),
+	}
+	for _, name := range names {
+		group[name] = parquet.Leaf(parquet.DoubleType)
+	}
+	return group
+}
+
+func (f *featureFile) parquetRow(row featureRow) parquet.Row {
+	values := map[string]parquet.Value{
+		"log_source":    parquet.ByteArrayValue([]byte(row.source)),
+		"tenant":        parquet.ByteArrayValue([]byte(row.tenant)),
+		"window_start":  parquet.Int64Value(row.windowStart.UnixMilli()),
+		"window_end":    parquet.Int64Value(row.windowEnd.UnixMilli()),
+		"samples":       parquet.Int64Value(int64(row.samples)),
+		"anomaly_score": parquet.DoubleValue(row.anomalyScore),
+		"threshold":     parquet.DoubleValue(row.threshold),
+		"is_anomaly":    parquet.BooleanValue(row.isAnomaly),
+	}
+	for _, name := range f.features {
+		values[name] = parquet.DoubleValue(row.features[name])
+	}
+
+	// Columns are ordered by the schema rather than by the row
+	out := make(parquet.Row, len(values))
+	for name, v := range values {
+		leaf, _ := f.schema.Lookup(name)
+		out[leaf.ColumnIndex] = v.Level(0, 0, leaf.ColumnIndex)
+	}
+	return out
+}
+
+func (f *featureFile) close() error {
+	if f.parquet != nil {
+		if err := f.parquet.Close(); err != nil {
+			_ = f.file.Close()
+			return err
+		}
+	} else {
+		f.csv.Flush()
+		if err := f.csv.Error(); err != nil {
+			_ = f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}
+
+func sortedFeatureNames(features map[string]float64) []string {
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,174 @@
+package processor
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFeatureExporter(t *testing.T, yaml string) *featureExporter {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(featureExportField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	exporter, err := newFeatureExporterFromConfig(conf.Namespace("feature_export"))
+	require.NoError(t, err)
+	return exporter
+}
+
+func testFeatureRow(end time.Time, mean float64) featureRow {
+	return featureRow{
+		source:       "fortinet.firewall",
+		windowStart:  end.Add(-time.Minute),
+		windowEnd:    end,
+		samples:      2,
+		features:     map[string]float64{"mean_value": mean, "unique_ips": 2},
+		anomalyScore: 0.1,
+		threshold:    0.7,
+	}
+}
+
+func TestFeatureExportCSV(t *testing.T) {
+	dir := t.TempDir()
+	exporter := newTestFeatureExporter(t, `feature_export: { directory: `+dir+`, rotate_interval: 1h }`)
+
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		windows:        make(map[string]*WindowData),
+		features:       exporter,
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 20, "192.168.1.2", now.Add(time.Second))
+
+	_, err := detector.scoreWindow(context.Background(), "fortinet.firewall", detector.getWindow("fortinet.firewall"), "connection_count", 20, false)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Close())
+
+	paths, err := filepath.Glob(filepath.Join(dir, "fortinet.firewall", "features-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	f, err := os.Open(paths[0])
+	require.NoError(t, err)
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	row := make(map[string]string, len(records[0]))
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	assert.Equal(t, "fortinet.firewall", row["log_source"])
+	assert.Equal(t, "2", row["samples"])
+	assert.Equal(t, "0.7", row["threshold"])
+	assert.Equal(t, "false", row["is_anomaly"])
+	assert.Equal(t, "15", row["mean_value"])
+	assert.Equal(t, "2", row["unique_ips"])
+}
+
+func TestFeatureExportRotation(t *testing.T) {
+	dir := t.TempDir()
+	exporter := newTestFeatureExporter(t, `feature_export: { directory: `+dir+`, rotate_interval: 1h }`)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now, 10), now))
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now, 11), now.Add(30*time.Minute)))
+
+	// Rotated once the interval elapsed
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now, 12), now.Add(time.Hour)))
+
+	// and when the features of the source change
+	row := testFeatureRow(now, 13)
+	row.features["std_dev"] = 1
+	require.NoError(t, exporter.export("fortinet.firewall", row, now.Add(time.Hour+time.Second)))
+	require.NoError(t, exporter.Close())
+
+	paths, err := filepath.Glob(filepath.Join(dir, "fortinet.firewall", "features-*.csv"))
+	require.NoError(t, err)
+	require.Len(t, paths, 3)
+
+	var rows int
+	for _, path := range paths {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		require.NoError(t, err)
+		rows += len(records) - 1
+	}
+	assert.Equal(t, 4, rows)
+}
+
+func TestFeatureExportParquet(t *testing.T) {
+	dir := t.TempDir()
+	exporter := newTestFeatureExporter(t, `feature_export: { directory: `+dir+`, format: parquet }`)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now, 10), now))
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now.Add(time.Minute), 20), now))
+	require.NoError(t, exporter.Close())
+
+	paths, err := filepath.Glob(filepath.Join(dir, "fortinet.firewall", "features-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	type row struct {
+		LogSource string    `parquet:"log_source"`
+		WindowEnd time.Time `parquet:"window_end,timestamp(millisecond)"`
+		Samples   int64     `parquet:"samples"`
+		MeanValue float64   `parquet:"mean_value"`
+		UniqueIPs float64   `parquet:"unique_ips"`
+	}
+	rows, err := parquet.ReadFile[row](paths[0])
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, "fortinet.firewall", rows[0].LogSource)
+	assert.True(t, now.Equal(rows[0].WindowEnd))
+	assert.Equal(t, int64(2), rows[0].Samples)
+	assert.Equal(t, 10.0, rows[0].MeanValue)
+	assert.Equal(t, 20.0, rows[1].MeanValue)
+	assert.Equal(t, 2.0, rows[1].UniqueIPs)
+}
+
+func TestFeatureExportTopic(t *testing.T) {
+	exporter := newTestFeatureExporter(t, `feature_export: { topic: firewall-features }`)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, exporter.export("fortinet.firewall", testFeatureRow(now, 10), now))
+
+	msgs := exporter.drain()
+	require.Len(t, msgs, 1)
+	assert.Empty(t, exporter.drain())
+
+	topic, _ := msgs[0].MetaGet("topic")
+	assert.Equal(t, "firewall-features", topic)
+	doc, err := msgs[0].AsStructured()
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, doc.(map[string]interface{})["features"].(map[string]float64)["mean_value"])
+	require.NoError(t, exporter.Close())
+}
+
+func TestFeatureExportDisabled(t *testing.T) {
+	exporter := newTestFeatureExporter(t, `{}`)
+	assert.Nil(t, exporter)
+	assert.NoError(t, exporter.export("fortinet.firewall", featureRow{}, time.Now()))
+	assert.Empty(t, exporter.drain())
+	assert.NoError(t, exporter.Close())
+}
@@ -0,0 +1,344 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Datasets of the feature store.
+const (
+	featureStoreFeatures = "features"
+	featureStoreLabels   = "labels"
+)
+
+func featureStoreField() *service.ConfigField {
+	return service.NewObjectField("feature_store",
+		service.NewStringField("directory").
+			Description("Directory the datasets are written to, e.g. a mounted bucket").
+			Default(""),
+		service.NewStringField("output").
+			Description("Name of an output resource every Parquet file is written to, e.g. an `aws_s3` output with `path: ${! meta(\"feature_store_path\") }`").
+			Default(""),
+		service.NewStringField("prefix").
+			Description("Path prefix of the datasets").
+			Default(""),
+		service.NewDurationField("flush_interval").
+			Description("How often buffered rows are written as Parquet files").
+			Default("15m"),
+		service.NewIntField("max_rows").
+			Description("Rows of a partition buffered before they are written early").
+			Default(100000),
+	).
+		Description("Feature store appending the feature vector of every scored window to a Parquet dataset partitioned by source key, date and hour, with analyst labels when available, for notebooks and training jobs. Disabled unless `directory` or `output` is set.").
+		Advanced()
+}
+
+// featureStoreRow is a buffered feature vector with the label it was given
+// before it was written, if any.
+type featureStoreRow struct {
+	featureRow
+	label string
+}
+
+// featureStorePartition buffers the rows of one partition of the features
+// dataset. Its rows share the same features.
+type featureStorePartition struct {
+	sourceKey string
+	hour      time.Time
+	features  []string
+	rows      []*featureStoreRow
+}
+
+// featureStore buffers feature vectors and labels, and writes them as
+// partitioned Parquet datasets to a directory and/or an output resource.
+type featureStore struct {
+	resources     *service.Resources
+	directory     string
+	output        string
+	prefix        string
+	flushInterval time.Duration
+	maxRows       int
+
+	files   *service.MetricCounter
+	dropped *service.MetricCounter
+
+	mut         sync.Mutex
+	partitions  map[string]*featureStorePartition
+	byWindowID  map[string]*featureStoreRow
+	labels      []feedbackRecord
+	nextFlushed time.Time
+}
+
+func newFeatureStoreFromConfig(conf *service.ParsedConfig, mgr *service.Resources) (*featureStore, error) {
+	s := &featureStore{
+		resources:  mgr,
+		partitions: make(map[string]*featureStorePartition),
+		byWindowID: make(map[string]*featureStoreRow),
+	}
+	var err error
+	if s.directory, err = conf.FieldString("directory"); err != nil {
+		return nil, err
+	}
+	if s.output, err = conf.FieldString("output"); err != nil {
+		return nil, err
+	}
+	if s.directory == "" && s.output == "" {
+		return nil, nil
+	}
+	if s.prefix, err = conf.FieldString("prefix"); err != nil {
+		return nil, err
+	}
+	if s.flushInterval, err = conf.FieldDuration("flush_interval"); err != nil {
+		return nil, err
+	}
+	if s.flushInterval <= 0 {
+		return nil, fmt.Errorf("feature_store.flush_interval must be positive, got %v", s.flushInterval)
+	}
+	if s.maxRows, err = conf.FieldInt("max_rows"); err != nil {
+		return nil, err
+	}
+	if s.maxRows <= 0 {
+		return nil, fmt.Errorf("feature_store.max_rows must be positive, got %d", s.maxRows)
+	}
+	if s.directory != "" {
+		if err := os.MkdirAll(s.directory, 0o750); err != nil {
+			return nil, err
+		}
+	}
+	s.files = mgr.Metrics().NewCounter("feature_store_files", "dataset")
+	s.dropped = mgr.Metrics().NewCounter("feature_store_rows_dropped", "dataset")
+	return s, nil
+}
+
+// add buffers the feature vector of a scored window in the partition of its
+// source key and hour.
+func (s *featureStore) add(sourceKey string, row featureRow) {
+	if s == nil {
+		return
+	}
+	names := sortedFeatureNames(row.features)
+	hour := row.windowStart.UTC().Truncate(time.Hour)
+	key := sourceKey + "/" + hour.Format(time.RFC3339)
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	p := s.partitions[key]
+	if p != nil && !slices.Equal(p.features, names) {
+		// A partition file has a single schema, so a change of the
+		// features of a source starts a new one
+		s.writePartition(context.Background(), p)
+		p = nil
+	}
+	if p == nil {
+		p = &featureStorePartition{sourceKey: sourceKey, hour: hour, features: names}
+		s.partitions[key] = p
+	}
+	stored := &featureStoreRow{featureRow: row}
+	p.rows = append(p.rows, stored)
+	s.byWindowID[row.windowID] = stored
+
+	if len(p.rows) >= s.maxRows {
+		s.writePartition(context.Background(), p)
+		delete(s.partitions, key)
+	}
+}
+
+// label records the label of a detection, setting it on its row if that is
+// still buffered. Every label is also written to the labels dataset, to be
+// joined by window ID with rows written before it.
+func (s *featureStore) label(rec feedbackRecord) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if row, exists := s.byWindowID[rec.WindowID]; exists {
+		row.label = rec.Label
+	}
+	s.labels = append(s.labels, rec)
+}
+
+// flush writes every buffered partition once per flush interval, or
+// immediately when force is set.
+func (s *featureStore) flush(ctx context.Context, now time.Time, force bool) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if !force && now.Before(s.nextFlushed) {
+		return
+	}
+	s.nextFlushed = now.Add(s.flushInterval)
+
+	keys := make([]string, 0, len(s.partitions))
+	for key := range s.partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s.writePartition(ctx, s.partitions[key])
+		delete(s.partitions, key)
+	}
+
+	if len(s.labels) > 0 {
+		if err := s.writeLabels(ctx, now); err != nil {
+			s.resources.Logger().Errorf("Failed to write %d labels to the feature store: %v", len(s.labels), err)
+			s.dropped.Incr(int64(len(s.labels)), featureStoreLabels)
+		}
+		s.labels = nil
+	}
+}
+
+// writePartition writes the rows of a partition as a Parquet file. Rows
+// that fail to be written are dropped rather than buffered without bound.
+func (s *featureStore) writePartition(ctx context.Context, p *featureStorePartition) {
+	for _, row := range p.rows {
+		delete(s.byWindowID, row.windowID)
+	}
+	if len(p.rows) == 0 {
+		return
+	}
+
+	schema := parquet.NewSchema("features", featureStoreGroup(p.features))
+	var buf bytes.Buffer
+	w := parquet.NewWriter(&buf, schema)
+	rows := make([]parquet.Row, 0, len(p.rows))
+	for _, row := range p.rows {
+		rows = append(rows, featureStoreParquetRow(schema, p.features, row))
+	}
+	_, err := w.WriteRows(rows)
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		dir := path.Join(featureStoreFeatures,
+			"source_key="+unsafePathChars.ReplaceAllString(p.sourceKey, "_"),
+			"date="+p.hour.Format("2006-01-02"),
+			"hour="+p.hour.Format("15"))
+		err = s.write(ctx, featureStoreFeatures, dir, buf.Bytes())
+	}
+	if err != nil {
+		s.resources.Logger().Errorf("Failed to write %d feature vectors of %s to the feature store: %v", len(p.rows), p.sourceKey, err)
+		s.dropped.Incr(int64(len(p.rows)), featureStoreFeatures)
+	}
+}
+
+// writeLabels writes the buffered labels as a Parquet file of the labels
+// dataset, partitioned by the date they were flushed.
+func (s *featureStore) writeLabels(ctx context.Context, now time.Time) error {
+	var buf bytes.Buffer
+	w := parquet.NewGenericWriter[featureStoreLabel](&buf)
+	labels := make([]featureStoreLabel, 0, len(s.labels))
+	for _, rec := range s.labels {
+		labels = append(labels, featureStoreLabel{
+			WindowID:   rec.WindowID,
+			Label:      rec.Label,
+			Analyst:    rec.Analyst,
+			LabelledAt: rec.LabelledAt,
+		})
+	}
+	if _, err := w.Write(labels); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return s.write(ctx, featureStoreLabels, path.Join(featureStoreLabels, "date="+now.UTC().Format("2006-01-02")), buf.Bytes())
+}
+
+// featureStoreLabel is a row of the labels dataset.
+type featureStoreLabel struct {
+	WindowID   string    `parquet:"window_id"`
+	Label      string    `parquet:"label"`
+	Analyst    string    `parquet:"analyst,optional"`
+	LabelledAt time.Time `parquet:"labelled_at,timestamp(millisecond)"`
+}
+
+// write stores a Parquet file of a dataset under a partition directory,
+// with a unique name so that replicas never overwrite each other's files.
+func (s *featureStore) write(ctx context.Context, dataset, dir string, data []byte) error {
+	name := path.Join(s.prefix, dir, "part-"+uuid.NewString()+".parquet")
+
+	var errs []error
+	if s.directory != "" {
+		file := filepath.Join(s.directory, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(file), 0o750)
+		if err == nil {
+			err = os.WriteFile(file, data, 0o640)
+		}
+		errs = append(errs, err)
+	}
+	if s.output != "" {
+		msg := service.NewMessage(data)
+		msg.MetaSet("feature_store_path", name)
+		msg.MetaSet("feature_store_dataset", dataset)
+
+		var writeErr error
+		err := s.resources.AccessOutput(ctx, s.output, func(o *service.ResourceOutput) {
+			writeErr = o.Write(ctx, msg)
+		})
+		errs = append(errs, err, writeErr)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	s.files.Incr(1, dataset)
+	return nil
+}
+
+// featureStoreGroup returns the Parquet columns of the features dataset:
+// those of feature export files, the window ID and the optional label.
+func featureStoreGroup(names []string) parquet.Group {
+	group := featureParquetGroup(names)
+	group["window_id"] = parquet.String()
+	group["label"] = parquet.Optional(parquet.String())
+	return group
+}
+
+func featureStoreParquetRow(schema *parquet.Schema, names []string, row *featureStoreRow) parquet.Row {
+	values := map[string]parquet.Value{
+		"window_id":     parquet.ByteArrayValue([]byte(row.windowID)),
+		"log_source":    parquet.ByteArrayValue([]byte(row.source)),
+		"tenant":        parquet.ByteArrayValue([]byte(row.tenant)),
+		"window_start":  parquet.Int64Value(row.windowStart.UnixMilli()),
+		"window_end":    parquet.Int64Value(row.windowEnd.UnixMilli()),
+		"samples":       parquet.Int64Value(int64(row.samples)),
+		"anomaly_score": parquet.DoubleValue(row.anomalyScore),
+		"threshold":     parquet.DoubleValue(row.threshold),
+		"is_anomaly":    parquet.BooleanValue(row.isAnomaly),
+	}
+	for _, name := range names {
+		values[name] = parquet.DoubleValue(row.features[name])
+	}
+
+	// Columns are ordered by the schema rather than by the row
+	out := make(parquet.Row, len(values)+1)
+	for name, v := range values {
+		leaf, _ := schema.Lookup(name)
+		out[leaf.ColumnIndex] = v.Level(0, 0, leaf.ColumnIndex)
+	}
+	leaf, _ := schema.Lookup("label")
+	if row.label == "" {
+		out[leaf.ColumnIndex] = parquet.NullValue().Level(0, 0, leaf.ColumnIndex)
+	} else {
+		out[leaf.ColumnIndex] = parquet.ByteArrayValue([]byte(row.label)).Level(0, 1, leaf.ColumnIndex)
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestFeatureStore(t *testing.T, yaml string) (*featureStore, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(featureStoreField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newFeatureStoreFromConfig(conf.Namespace("feature_store"), service.MockResources())
+}
+
+func TestFeatureStoreConfig(t *testing.T) {
+	s, err := newTestFeatureStore(t, `feature_store: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = newTestFeatureStore(t, `feature_store: { output: features_bucket }`)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	assert.Equal(t, 15*time.Minute, s.flushInterval)
+
+	_, err = newTestFeatureStore(t, `feature_store: { output: features_bucket, flush_interval: 0s }`)
+	assert.Error(t, err)
+	_, err = newTestFeatureStore(t, `feature_store: { output: features_bucket, max_rows: 0 }`)
+	assert.Error(t, err)
+}
+
+func TestFeatureStoreWritesPartitions(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newTestFeatureStore(t, `feature_store: { directory: `+dir+`, prefix: firewall }`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	first, second := testFeatureRow(now, 10), testFeatureRow(now.Add(time.Minute), 20)
+	first.windowID, second.windowID = "w1", "w2"
+	s.add("fortinet.firewall", first)
+	s.add("fortinet.firewall", second)
+	late := testFeatureRow(now.Add(time.Hour), 30)
+	late.windowID = "w3"
+	s.add("fortinet.firewall", late)
+
+	// Labels of buffered rows are written with them
+	s.label(feedbackRecord{WindowID: "w2", Label: feedbackTruePositive, LabelledAt: now})
+	s.flush(ctx, now, false)
+
+	// Nothing is written again before the flush interval has passed
+	s.add("fortinet.firewall", first)
+	s.flush(ctx, now.Add(time.Minute), false)
+	assert.Len(t, s.partitions, 1)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "firewall", "features", "source_key=fortinet.firewall", "date=2024-01-15", "hour=10", "part-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+
+	type row struct {
+		WindowID  string  `parquet:"window_id"`
+		LogSource string  `parquet:"log_source"`
+		MeanValue float64 `parquet:"mean_value"`
+		Label     *string `parquet:"label,optional"`
+	}
+	rows, err := parquet.ReadFile[row](paths[0])
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "w1", rows[0].WindowID)
+	assert.Nil(t, rows[0].Label)
+	require.NotNil(t, rows[1].Label)
+	assert.Equal(t, feedbackTruePositive, *rows[1].Label)
+	assert.Equal(t, 20.0, rows[1].MeanValue)
+
+	paths, err = filepath.Glob(filepath.Join(dir, "firewall", "features", "source_key=fortinet.firewall", "date=2024-01-15", "hour=11", "part-*.parquet"))
+	require.NoError(t, err)
+	assert.Len(t, paths, 1)
+
+	// Every label lands in the labels dataset, to join rows written earlier
+	paths, err = filepath.Glob(filepath.Join(dir, "firewall", "labels", "date=2024-01-15", "part-*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, paths, 1)
+	labels, err := parquet.ReadFile[featureStoreLabel](paths[0])
+	require.NoError(t, err)
+	require.Len(t, labels, 1)
+	assert.Equal(t, "w2", labels[0].WindowID)
+	assert.True(t, now.Equal(labels[0].LabelledAt))
+}
+
+func TestFeatureStoreWritesEarly(t *testing.T) {
+	dir := t.TempDir()
+	s, err := newTestFeatureStore(t, `feature_store: { directory: `+dir+`, max_rows: 2 }`)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	s.add("fortinet.firewall", testFeatureRow(now, 10))
+
+	// A change of the features of a source starts a new file
+	changed := testFeatureRow(now, 10)
+	changed.features = map[string]float64{"mean_value": 10}
+	s.add("fortinet.firewall", changed)
+	s.add("fortinet.firewall", changed)
+
+	paths, err := filepath.Glob(filepath.Join(dir, "features", "source_key=fortinet.firewall", "*", "*", "part-*.parquet"))
+	require.NoError(t, err)
+	assert.Len(t, paths, 2)
+	assert.Empty(t, s.partitions)
+	assert.Empty(t, s.byWindowID)
+}
@@ -0,0 +1,156 @@
+package processor
+
+import "math"
+
+// featureIndex is the position of a feature among the features of a metric.
+type featureIndex int
+
+const (
+	featureMeanValue featureIndex = iota
+	featureStdDev
+	featureMaxValue
+	featureMinValue
+	featurePercentChange
+	featureUniqueIPs
+	featurePeakToMeanRatio
+	featureHighSeverityRatio
+	numFeatures
+)
+
+// featureNames are the features computed for every metric of a window,
+// indexed by featureIndex.
+var featureNames = []string{
+	featureMeanValue:         "mean_value",
+	featureStdDev:            "std_dev",
+	featureMaxValue:          "max_value",
+	featureMinValue:          "min_value",
+	featurePercentChange:     "percent_change",
+	featureUniqueIPs:         "unique_ips",
+	featurePeakToMeanRatio:   "peak_to_mean_ratio",
+	featureHighSeverityRatio: "high_severity_ratio",
+}
+
+// windowFeatures are computed once per window rather than per metric.
+const windowFeatures featureSet = 1<<featureUniqueIPs | 1<<featureHighSeverityRatio
+
+// featureIndexOf returns the index of a named feature.
+func featureIndexOf(name string) (featureIndex, bool) {
+	for i, n := range featureNames {
+		if n == name {
+			return featureIndex(i), true
+		}
+	}
+	return 0, false
+}
+
+// featureSet is a set of features, one bit per featureIndex.
+type featureSet uint8
+
+const allFeatures featureSet = 1<<numFeatures - 1
+
+// defaultFeatures are the features of sources that select none, which leave
+// out the features added after models were first trained on the others.
+const defaultFeatures = allFeatures &^ (1 << featureHighSeverityRatio)
+
+func (s featureSet) has(i featureIndex) bool {
+	return s&(1<<i) != 0
+}
+
+// metricStats are the features of one metric, indexed by featureIndex.
+type metricStats [numFeatures]float64
+
+// only zeroes the features outside a set.
+func (m metricStats) only(selected featureSet) metricStats {
+	for i := range m {
+		if !selected.has(featureIndex(i)) {
+			m[i] = 0
+		}
+	}
+	return m
+}
+
+// featureVector is the feature vector of a window: the features of each of
+// its metrics in configuration order, limited to the features selected for
+// its source. Features that are not selected are zero.
+type featureVector struct {
+	metrics  []metricStats
+	sources  []sourceMetric // metrics of multi-metric sources, nil otherwise
+	selected featureSet
+
+	// sanitised is the number of non-finite features replaced by sanitise
+	sanitised int
+}
+
+// sanitise replaces the non-finite features of the vector, such as the
+// standard deviation of a window of a single sample or ratios overflowing
+// for tiny means, which JSON can not encode and scoring can not compare.
+// NaN is replaced with zero and infinities are clamped to the largest finite
+// value of their sign.
+func (v *featureVector) sanitise() {
+	for m := range v.metrics {
+		for i, value := range v.metrics[m] {
+			switch {
+			case math.IsNaN(value):
+				v.metrics[m][i] = 0
+			case math.IsInf(value, 1):
+				v.metrics[m][i] = math.MaxFloat64
+			case math.IsInf(value, -1):
+				v.metrics[m][i] = -math.MaxFloat64
+			default:
+				continue
+			}
+			v.sanitised++
+		}
+	}
+}
+
+// each calls fn with the output name and value of every selected feature,
+// in a stable order: the features of every metric in index order, prefixed
+// for multi-metric sources, whose shared window features come last.
+func (v featureVector) each(fn func(name string, value float64)) {
+	if v.sources == nil {
+		for i, value := range v.metrics[0] {
+			if v.selected.has(featureIndex(i)) {
+				fn(featureNames[i], value)
+			}
+		}
+		return
+	}
+
+	for m, stats := range v.metrics {
+		for i, value := range stats {
+			if !windowFeatures.has(featureIndex(i)) && v.selected.has(featureIndex(i)) {
+				fn(v.sources[m].prefix+"_"+featureNames[i], value)
+			}
+		}
+	}
+	for i, value := range v.metrics[0] {
+		if windowFeatures.has(featureIndex(i)) && v.selected.has(featureIndex(i)) {
+			fn(featureNames[i], value)
+		}
+	}
+}
+
+// names returns the output names of the features of the vector, in the
+// order of values.
+func (v featureVector) names() []string {
+	names := make([]string, 0, len(v.metrics)*int(numFeatures))
+	v.each(func(name string, _ float64) { names = append(names, name) })
+	return names
+}
+
+// values returns the features of the vector in the order the model reads
+// them.
+func (v featureVector) values() []float64 {
+	values := make([]float64, 0, len(v.metrics)*int(numFeatures))
+	v.each(func(_ string, value float64) { values = append(values, value) })
+	return values
+}
+
+// asMap returns the features of the vector keyed by output name, as they are
+// emitted, audited and exported.
+func (v featureVector) asMap() map[string]float64 {
+	features := make(map[string]float64, len(v.metrics)*int(numFeatures))
+	v.each(func(name string, value float64) { features[name] = value })
+	return features
+}
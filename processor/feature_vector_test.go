@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureNamesMatchIndexes(t *testing.T) {
+	assert.Len(t, featureNames, int(numFeatures))
+	for i, name := range featureNames {
+		index, ok := featureIndexOf(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, featureIndex(i), index, name)
+	}
+	_, ok := featureIndexOf("packets")
+	assert.False(t, ok)
+}
+
+func TestFeatureVectorOrder(t *testing.T) {
+	stats := metricFeatures([]float64{10, 20, 30}, 10, 2)
+	vector := featureVector{metrics: []metricStats{stats}, selected: allFeatures}
+	assert.Equal(t, featureNames, vector.names())
+	assert.Equal(t, []float64{20, 10, 30, 10, 100, 2, 1.5, 0}, vector.values())
+
+	// Multi-metric vectors list every metric in configuration order and the
+	// shared unique IP count last
+	selected := featureSet(1<<featureMeanValue | 1<<featureUniqueIPs)
+	vector = featureVector{
+		metrics: []metricStats{
+			metricFeatures([]float64{100, 300}, 0, 2).only(selected),
+			metricFeatures([]float64{1, 3}, 0, 2).only(selected),
+		},
+		sources: []sourceMetric{
+			{field: "bytes_sent", prefix: "bytes_sent"},
+			{field: "connection_count", prefix: "conn_count"},
+		},
+		selected: selected,
+	}
+	assert.Equal(t, []string{"bytes_sent_mean_value", "conn_count_mean_value", "unique_ips"}, vector.names())
+	assert.Equal(t, []float64{200, 2, 2}, vector.values())
+	assert.Equal(t, map[string]float64{"bytes_sent_mean_value": 200, "conn_count_mean_value": 2, "unique_ips": 2}, vector.asMap())
+	assert.Equal(t, 0.0, vector.metrics[1][featureMaxValue])
+}
@@ -0,0 +1,231 @@
+package processor
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Labels analysts give detections.
+const (
+	feedbackTruePositive  = "true_positive"
+	feedbackFalsePositive = "false_positive"
+)
+
+// maxFeedbackBody bounds the size of a feedback request.
+const maxFeedbackBody = 64 * 1024
+
+func feedbackField() *service.ConfigField {
+	return service.NewObjectField("feedback",
+		service.NewBoolField("enabled").
+			Description("Serve the feedback endpoint on the Redpanda Connect HTTP server").
+			Default(false),
+		service.NewStringField("path").
+			Description("Path labels are posted to").
+			Default("/firewall_anomaly_detector/feedback"),
+		service.NewStringField("token").
+			Description("Bearer token required to post labels, either a literal, a `${ENV_VAR}` reference or a `vault:<path>#<key>` reference. Anyone reaching the HTTP server can post labels when omitted.").
+			Secret().
+			Optional(),
+		service.NewStringField("file").
+			Description("File every label is appended to as a JSON line, joined to exported anomalies by `anomalies export --feedback`").
+			Default(""),
+		service.NewStringField("output").
+			Description("Name of an output resource every label is written to, e.g. a topic feeding a training set").
+			Default(""),
+	).
+		Description("Endpoint analysts or SOAR playbooks label detections through as true or false positives by their `window_id`, closing the loop for retraining. Labels are persisted to `file` and/or `output`, one of which is required.").
+		Advanced()
+}
+
+// feedbackRecord is the label of a detection.
+type feedbackRecord struct {
+	WindowID   string    `json:"window_id"`
+	Label      string    `json:"label"`
+	Analyst    string    `json:"analyst,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	LabelledAt time.Time `json:"labelled_at"`
+}
+
+// feedbackStore persists the labels posted to the feedback endpoint.
+type feedbackStore struct {
+	path      string
+	token     string
+	resources *service.Resources
+	output    string
+	labels    *service.MetricCounter
+
+	mut  sync.Mutex
+	file *os.File
+}
+
+func newFeedbackStoreFromConfig(conf *service.ParsedConfig, mgr *service.Resources, secrets *secretResolver) (*feedbackStore, error) {
+	enabled, err := conf.FieldBool("feedback", "enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	s := &feedbackStore{resources: mgr, labels: mgr.Metrics().NewCounter("feedback_labels", "label")}
+	if s.path, err = conf.FieldString("feedback", "path"); err != nil {
+		return nil, err
+	}
+	if s.token, err = secrets.resolveCredential(conf, "feedback", "token"); err != nil {
+		return nil, err
+	}
+	if s.output, err = conf.FieldString("feedback", "output"); err != nil {
+		return nil, err
+	}
+	file, err := conf.FieldString("feedback", "file")
+	if err != nil {
+		return nil, err
+	}
+	if file == "" && s.output == "" {
+		return nil, errors.New("feedback.file or feedback.output must be set to persist labels")
+	}
+	if file != "" {
+		if s.file, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// registerFeedbackEndpoint serves the feedback endpoint when it is enabled.
+func (f *FirewallAnomalyDetector) registerFeedbackEndpoint() error {
+	if f.feedback == nil {
+		return nil
+	}
+	return registerEndpoint(f.resources, f.feedback.path, "Labels detections of the firewall anomaly detector as true or false positives.", f.handleFeedback)
+}
+
+// handleFeedback records the label posted for a detection, responding with
+// the stored record.
+func (f *FirewallAnomalyDetector) handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "labels must be posted", http.StatusMethodNotAllowed)
+		return
+	}
+	if !f.feedback.authorised(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or invalid bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	var rec feedbackRecord
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxFeedbackBody)).Decode(&rec); err != nil {
+		http.Error(w, fmt.Sprintf("invalid label: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := rec.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec.LabelledAt = f.now()
+
+	if err := f.feedback.record(r.Context(), rec); err != nil {
+		f.logger.Errorf("Failed to persist label of window %s: %v", rec.WindowID, err)
+		http.Error(w, "failed to persist label", http.StatusInternalServerError)
+		return
+	}
+	// A labelled detection has been handled, so its alert is not escalated
+	f.alerts.Acknowledge(rec.WindowID)
+	f.thresholdControl.observeLabel(rec)
+	f.featureStore.label(rec)
+	if _, err := f.stix.confirm(r.Context(), rec); err != nil {
+		f.logger.Errorf("Failed to export indicators of window %s: %v", rec.WindowID, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(rec); err != nil {
+		f.logger.Warnf("Failed to write feedback response: %v", err)
+	}
+}
+
+// authorised reports whether a request carries the bearer token, if one is
+// required.
+func (s *feedbackStore) authorised(r *http.Request) bool {
+	if s.token == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) == 1
+}
+
+func (rec feedbackRecord) validate() error {
+	if rec.WindowID == "" {
+		return errors.New("window_id is required")
+	}
+	if rec.Label != feedbackTruePositive && rec.Label != feedbackFalsePositive {
+		return fmt.Errorf("label must be %s or %s, got %q", feedbackTruePositive, feedbackFalsePositive, rec.Label)
+	}
+	return nil
+}
+
+// record persists a label.
+func (s *feedbackStore) record(ctx context.Context, rec feedbackRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if s.file != nil {
+		s.mut.Lock()
+		_, err = s.file.Write(append(line, '\n'))
+		s.mut.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.output != "" {
+		msg := service.NewMessage(line)
+		msg.MetaSet("window_id", rec.WindowID)
+		msg.MetaSet("label", rec.Label)
+
+		var writeErr error
+		if err := s.resources.AccessOutput(ctx, s.output, func(o *service.ResourceOutput) {
+			writeErr = o.Write(ctx, msg)
+		}); err != nil {
+			return err
+		}
+		if writeErr != nil {
+			return writeErr
+		}
+	}
+	s.labels.Incr(1, rec.Label)
+	return nil
+}
+
+// Close closes the feedback file.
+func (s *feedbackStore) Close() error {
+	if s == nil || s.file == nil {
+		return nil
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.file.Close()
+}
+
+// readFeedbackLabels reads the labels of a feedback file by window ID. The
+// latest label of a window wins, so that analysts can correct themselves.
+func readFeedbackLabels(r io.Reader, labels map[string]feedbackRecord) error {
+	return readJSONLines(r, func(line []byte) {
+		var rec feedbackRecord
+		if err := json.Unmarshal(line, &rec); err != nil || rec.validate() != nil {
+			return
+		}
+		if prev, exists := labels[rec.WindowID]; !exists || !rec.LabelledAt.Before(prev.LabelledAt) {
+			labels[rec.WindowID] = rec
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeedbackConfig(t *testing.T) {
+	parse := func(yaml string) (*feedbackStore, error) {
+		spec := service.NewConfigSpec().Field(feedbackField()).Field(secretsField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		secrets, err := newSecretResolverFromConfig(conf.Namespace("secrets"))
+		require.NoError(t, err)
+		return newFeedbackStoreFromConfig(conf, service.MockResources(), secrets)
+	}
+
+	s, err := parse(`feedback: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = parse(`feedback: { enabled: true }`)
+	assert.ErrorContains(t, err, "feedback.file or feedback.output must be set")
+
+	t.Setenv("FEEDBACK_TOKEN", "s3cret")
+	file := filepath.Join(t.TempDir(), "feedback.jsonl")
+	s, err = parse(`feedback: { enabled: true, file: ` + file + `, token: "${FEEDBACK_TOKEN}" }`)
+	require.NoError(t, err)
+	require.NotNil(t, s)
+	defer s.Close()
+	assert.Equal(t, "/firewall_anomaly_detector/feedback", s.path)
+	assert.Equal(t, "s3cret", s.token)
+}
+
+func TestHandleFeedback(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "feedback.jsonl")
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	require.NoError(t, err)
+
+	mgr := service.MockResources()
+	now := time.Date(2024, 1, 15, 10, 5, 0, 0, time.UTC)
+	detector := &FirewallAnomalyDetector{
+		logger: mgr.Logger(),
+		clock:  func() time.Time { return now },
+		feedback: &feedbackStore{
+			token:  "s3cret",
+			file:   out,
+			labels: mgr.Metrics().NewCounter("feedback_labels", "label"),
+		},
+	}
+	post := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/firewall_anomaly_detector/feedback", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		detector.handleFeedback(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusMethodNotAllowed, post(http.MethodGet, "s3cret", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, post(http.MethodPost, "", `{"window_id":"abc","label":"true_positive"}`).Code)
+	assert.Equal(t, http.StatusUnauthorized, post(http.MethodPost, "wrong", `{"window_id":"abc","label":"true_positive"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(http.MethodPost, "s3cret", `not json`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(http.MethodPost, "s3cret", `{"label":"true_positive"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(http.MethodPost, "s3cret", `{"window_id":"abc","label":"maybe"}`).Code)
+
+	rec := post(http.MethodPost, "s3cret", `{"window_id":"abc","label":"false_positive","analyst":"soc-1","comment":"backup job"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	var stored feedbackRecord
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stored))
+	assert.Equal(t, feedbackRecord{WindowID: "abc", Label: feedbackFalsePositive, Analyst: "soc-1", Comment: "backup job", LabelledAt: now}, stored)
+
+	// Only accepted labels are persisted
+	require.NoError(t, detector.feedback.Close())
+	in, err := os.Open(file)
+	require.NoError(t, err)
+	defer in.Close()
+	labels := map[string]feedbackRecord{}
+	require.NoError(t, readFeedbackLabels(in, labels))
+	assert.Equal(t, map[string]feedbackRecord{"abc": stored}, labels)
+}
+
+func TestReadFeedbackLabels(t *testing.T) {
+	lines := strings.Join([]string{
+		`{"window_id":"abc","label":"true_positive","labelled_at":"2024-01-15T10:00:00Z"}`,
+		`{"window_id":"abc","label":"false_positive","labelled_at":"2024-01-15T11:00:00Z"}`,
+		`{"window_id":"def","label":"false_positive","labelled_at":"2024-01-15T12:00:00Z"}`,
+		`{"window_id":"def","label":"true_positive","labelled_at":"2024-01-15T09:00:00Z"}`,
+		`{"window_id":"ghi","label":"unsure"}`,
+		`not a label`,
+	}, "\n")
+
+	labels := map[string]feedbackRecord{}
+	require.NoError(t, readFeedbackLabels(strings.NewReader(lines), labels))
+	require.Len(t, labels, 2)
+	assert.Equal(t, feedbackFalsePositive, labels["abc"].Label)
+	assert.Equal(t, feedbackFalsePositive, labels["def"].Label)
+}
+
+func TestFeedbackAcknowledgesAlert(t *testing.T) {
+	pagerDuty := &namedRecordingSink{name: "pagerduty"}
+	dispatcher := newTestDispatcher(pagerDuty)
+	dispatcher.escalator = newAlertEscalator()
+	dispatcher.policies = []*escalationPolicy{{name: "low", maxScore: 1.01, escalateAfter: 15 * time.Minute, escalateTo: []alertSink{pagerDuty}}}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	dispatcher.escalator.now = func() time.Time { return now }
+
+	mgr := service.MockResources()
+	detector := &FirewallAnomalyDetector{
+		logger: mgr.Logger(),
+		clock:  func() time.Time { return now },
+		alerts: dispatcher,
+		feedback: &feedbackStore{
+			labels: mgr.Metrics().NewCounter("feedback_labels", "label"),
+		},
+	}
+
+	msg := service.NewMessage(nil)
+	msg.SetStructured(map[string]interface{}{"window_id": "abc", "log_source": "fortinet.firewall", "anomaly_score": 0.8})
+	dispatcher.Dispatch(context.Background(), msg)
+	require.Len(t, dispatcher.escalator.pending, 1)
+
+	req := httptest.NewRequest(http.MethodPost, "/firewall_anomaly_detector/feedback", strings.NewReader(`{"window_id":"abc","label":"true_positive"}`))
+	rec := httptest.NewRecorder()
+	detector.handleFeedback(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// The labelled alert is not escalated
+	now = now.Add(time.Hour)
+	dispatcher.escalateDue(context.Background())
+	assert.Empty(t, pagerDuty.results)
+}
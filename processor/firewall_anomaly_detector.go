@@ -3,17 +3,39 @@ package processor
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/redpanda-data/benthos/v4/public/service"
-	"gonum.org/v1/gonum/stat"
 )
 
 func init() {
-	configSpec := service.NewConfigSpec().
+	constructor := func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchProcessor, error) {
+		return newFirewallAnomalyDetector(conf, mgr)
+	}
+
+	err := service.RegisterBatchProcessor("firewall_anomaly_detector", detectorConfigSpec(), constructor)
+	if err != nil {
+		panic(err)
+	}
+
+	cacheConstructor := func(conf *service.ParsedConfig, mgr *service.Resources) (service.Cache, error) {
+		return newBaselineCacheFromConfig(conf)
+	}
+	if err := service.RegisterCache("firewall_anomaly_baselines", baselineCacheConfigSpec(), cacheConstructor); err != nil {
+		panic(err)
+	}
+}
+
+// detectorConfigSpec returns the config spec of the processor, which is
+// shared with offline config validation.
+func detectorConfigSpec() *service.ConfigSpec {
+	return service.NewConfigSpec().
 		Beta().
 		Categories("Integration").
 		Summary("Detects anomalies in firewall logs using ML models and sliding windows").
@@ -22,28 +44,99 @@ This processor reads firewall logs from Redis, maintains sliding time windows fo
 aggregates features, applies ML anomaly detection, and routes results to different Kafka topics.
 
 Features:
-- Sliding time window aggregation per log source
+- Sliding time window aggregation per log source, optionally scoped per tenant
+- Epoch aligned window boundaries, each window emitted once when it closes
+- Deterministic window IDs for deduplicating results re-emitted after a crash
+- Optional zero-valued heartbeat results for windows without logs
+- Optional validation of incoming logs, rejecting invalid ones with a list of violations
+- Preallocated window sample buffers, optionally bounded as ring buffers
+- Periodic collection of the idle state of sources that went quiet
+- Bloom filter tracking of the source IPs of windows with millions of distinct IPs
+- Preset profiles for datacenter, branch office and lab deployments
+- Event time replay of historical logs for backtesting configuration changes
+- Configurable event time field, format and per-source timezone with an ingest time fallback
+- Configurable fallbacks for logs missing their source IP or metric fields
+- Per-source clock skew detection with optional event time correction
+- Reordering buffer sorting interleaved, out of order logs by event time before windowing
+- Exact, glob and regular expression source matching with a catch-all default source
 - Configurable ML model loading (Isolation Forest)
-- Feature extraction: mean, std dev, max, min, percent change, unique IPs, peak-to-mean ratio
-- Anomaly scoring and threshold-based routing
-- Redis integration for log consumption
+- Feature extraction: mean, std dev, max, min, percent change, unique IPs, peak-to-mean ratio, selectable per source
+- Anomaly scoring and threshold-based routing, with fixed or adaptive percentile thresholds
+- Dry-run mode computing and metering detections without routing or alerting them
+- Redis integration for log consumption, optionally acknowledged once windows are emitted
+- Active/standby replication of window state through Redis
+- Per-source or per-tenant rate limiting of admitted logs
+- Worker pool processing independent windows in parallel
+- Fault injection of malformed, duplicate and out of order logs and Redis latency for resilience tests
+- Debug HTTP endpoint listing live windows
+- Web UI reviewing live anomalies, recent scores per source and current windows
+- Liveness and readiness probes covering Redis, the model and emission progress
+- Status endpoint reporting the loaded model, configured sources, active windows and last emission per source
+- Audit trail of every detection decision
+- Analyst feedback endpoint labelling detections as true or false positives for retraining
+- Export of the external IPs of confirmed anomalies as STIX 2.1 indicators to a TAXII collection
+- Active response blocking the offending IPs of severe detections on FortiGate and PAN-OS firewalls for a TTL, with a dry run
+- Threshold controller holding a target precision of analyst labels and a daily alert budget per source
+- Asynchronous emission of results through a bounded queue and a background flusher
+- Silence and score flatline detection of log sources
+- Model drift detection through the Population Stability Index of scores and features
+- Sampled copies of scored windows to a debug topic
+- Per-source baselines readable by other processors through the firewall_anomaly_baselines cache
+- Export of the feature vectors of every window to CSV or Parquet files or a topic for model training
+- Push of the latest window features of every source into a Feast online store
+- Feature store appending feature vectors and analyst labels to partitioned Parquet datasets in a directory or object storage
+- Runtime overrides of thresholds, sources and topics through a Redis control key
+- Credentials resolved from the environment or Vault
+- Memory budget that spills least recently updated windows to Redis
+- Bounded in-flight log budget that pauses consumption when downstream falls behind
 - Kafka/Redpanda output routing
+- Elastic Common Schema output for Elastic SIEM
+- Versioned result schema, with a compatibility mode emitting the previous major version
+- Compact or verbose result payloads
+- Byte unit normalisation and cumulative counters with reset and wraparound detection
+- Normalisation of Fortinet, Palo Alto Networks and Cisco severities to a canonical scale
+- Canonical IPv4 and IPv6 source IPs, with optional aggregation of IPv6 sources to a prefix such as /64
+- Optional HTTP enrichment of logs via a user supplied endpoint
+- Strict mode routing logs of unknown sources and metrics to a reject topic or the error output
+- Partitioned window ownership for running multiple replicas
+- Redis locks around window flushes and model reloads
+- Flushing or persisting in-flight windows on shutdown
+- Periodic on-disk snapshots of window state
+- Maintenance window schedules that suppress alerting
+- Alert channels (webhook, Slack, email, Opsgenie, MS Teams, SNMP traps, syslog, PagerDuty, TheHive with Cortex analyzers) notified for every anomaly
 `).
+		Field(profileField()).
 		Field(service.NewIntField("window_seconds").
-			Description("Duration of the sliding time window in seconds").
-			Default(60)).
+			Description("Duration of the sliding time window in seconds. Defaults to 60, or the value of `profile`.").
+			Optional()).
 		Field(service.NewStringField("model_path").
 			Description("Path to the pre-trained ML model file (.pkl)").
 			Default("/etc/plugin/model.pkl")).
 		Field(service.NewFloatField("score_threshold").
 			Description("Threshold for anomaly detection (0.0 to 1.0)").
 			Default(0.7)).
+		Field(service.NewBoolField("dry_run").
+			Description("Compute, log and meter detections without routing anything to the anomaly topic or alert channels, for baking new thresholds and models on production traffic. Anomalies are routed to the normal topic marked `dry_run`.").
+			Default(false)).
+		Field(service.NewDurationField("max_lateness").
+			Description("Logs with a timestamp older than this are dropped as late. Zero accepts logs of any age that still fall in an open window.").
+			Default("0s").
+			Advanced()).
+		Field(service.NewIntField("config_epoch").
+			Description("Epoch of the config hashed into the `window_id` of results along with the window key and start. Results of a window re-processed after a crash keep their ID, and bumping the epoch after a config change that alters results gives them new IDs so that downstream deduplication keeps them.").
+			Default(0).
+			Advanced()).
+		Field(service.NewDurationField("window_grace").
+			Description("How long after its end a window waits for late logs before the clock closes it. Windows also close as soon as a log past their end arrives, and logs of closed windows are dropped as late.").
+			Default("10s").
+			Advanced()).
 		Field(service.NewObjectField("redis_config",
 			service.NewStringField("address").
 				Description("Redis server address").
 				Default("localhost:6379"),
 			service.NewStringField("password").
-				Description("Redis password").
+				Description("Redis password, either a literal, a `${ENV_VAR}` reference or a `vault:<path>#<key>` reference").
+				Secret().
 				Optional(),
 			service.NewIntField("db").
 				Description("Redis database number").
@@ -67,8 +160,25 @@ Features:
 			service.NewStringField("metric").
 				Description("Metric field to extract from logs for this source").
 				Default("connection_count"),
+			service.NewFloatField("score_threshold").
+				Description("Anomaly threshold for this source, overriding `score_threshold`").
+				Optional(),
+			service.NewIntField("window_seconds").
+				Description("Duration of the windows of this source in seconds, overriding `window_seconds`").
+				Optional(),
+			sourceMetricsField(),
+			sourceFeaturesField(),
+			sourceTimezoneField(),
+			sourceUnitField(),
+			sourceCounterField(),
+			sourceCounterBitsField(),
+			sourceSeverityMapField(),
+			sourceIPv6PrefixField(),
+			service.NewIntField("priority").
+				Description("Priority of a glob or regular expression key when several match a log source, highest first. Exact keys always take precedence.").
+				Default(0),
 		).
-			Description("Configuration for different log sources").
+			Description("Configuration for different log sources, keyed by exact log_source, a glob such as `fortinet.*` or a regular expression enclosed in slashes such as `/fw-[0-9]+\\.example\\.com/`").
 			Default(map[string]interface{}{
 				"fortinet.firewall": map[string]interface{}{
 					"metric": "connection_count",
@@ -76,48 +186,156 @@ Features:
 				"paloalto.firewall": map[string]interface{}{
 					"metric": "bytes_sent",
 				},
-			}))
-
-	constructor := func(conf *service.ParsedConfig, mgr *service.Resources) (service.Processor, error) {
-		return newFirewallAnomalyDetector(conf, mgr)
-	}
-
-	err := service.RegisterProcessor("firewall_anomaly_detector", configSpec, constructor)
-	if err != nil {
-		panic(err)
-	}
+			})).
+		Field(defaultSourceField()).
+		Field(eventTimeField()).
+		Field(clockSkewField()).
+		Field(reorderField()).
+		Field(outputSchemaField()).
+		Field(outputSchemaVersionField()).
+		Fields(outputVerbosityFields()...).
+		Field(httpEnrichmentField()).
+		Field(alertsField()).
+		Field(suppressionSchedulesField()).
+		Field(partitioningField()).
+		Field(distributedLocksField()).
+		Field(shutdownField()).
+		Field(snapshotField()).
+		Field(consumptionField()).
+		Field(backpressureField()).
+		Field(workerPoolField()).
+		Field(memoryBudgetField()).
+		Field(stateGCField()).
+		Field(windowBufferField()).
+		Field(ipSetField()).
+		Field(replicationField()).
+		Field(rateLimitField()).
+		Field(faultInjectionField()).
+		Field(histogramsField()).
+		Field(debugEndpointField()).
+		Field(webUIField()).
+		Field(healthField()).
+		Field(statusEndpointField()).
+		Field(auditField()).
+		Field(asyncEmissionField()).
+		Field(selfMonitoringField()).
+		Field(emptyWindowsField()).
+		Field(missingFieldsField()).
+		Field(severityField()).
+		Field(baselineCacheField()).
+		Field(feedbackField()).
+		Field(thresholdControlField()).
+		Field(stixExportField()).
+		Field(activeResponseField()).
+		Field(driftField()).
+		Field(debugSampleField()).
+		Field(featureExportField()).
+		Field(featureStoreField()).
+		Field(feastField()).
+		Field(controlField()).
+		Field(secretsField()).
+		Field(adaptiveThresholdField()).
+		Fields(tenantFields()...).
+		Fields(strictFields()...).
+		Field(validationField())
 }
 
 //------------------------------------------------------------------------------
 
 type FirewallLog struct {
 	Timestamp       time.Time              `json:"timestamp"`
-	LogSource       string                 `json:"log_source"`
-	SourceIP        string                 `json:"source_ip"`
-	DestIP          string                 `json:"dest_ip"`
-	ConnectionCount int                    `json:"connection_count,omitempty"`
-	BytesSent       int64                  `json:"bytes_sent,omitempty"`
-	BytesRecv       int64                  `json:"bytes_recv,omitempty"`
+	LogSource       string                 `json:"log_source" validate:"required"`
+	SourceIP        string                 `json:"source_ip" validate:"required,ip"`
+	DestIP          string                 `json:"dest_ip" validate:"required,ip"`
+	ConnectionCount int                    `json:"connection_count,omitempty" validate:"min=0"`
+	BytesSent       int64                  `json:"bytes_sent,omitempty" validate:"min=0"`
+	BytesRecv       int64                  `json:"bytes_recv,omitempty" validate:"min=0"`
 	Action          string                 `json:"action"`
 	Severity        string                 `json:"severity"`
 	Raw             map[string]interface{} `json:"raw"`
+
+	// consumed is the log as read from Redis, used to acknowledge it
+	consumed string
+
+	// tenant is resolved from the configured tenant field
+	tenant string
+
+	// original is the log as read from Redis, kept in strict mode and when
+	// validating logs to route rejected logs
+	original string
+
+	// rawJSON is the raw object until it is decoded into Raw by decodeRaw
+	rawJSON json.RawMessage
+
+	// lookup is the enrichment lookup started for the log ahead of its
+	// processing
+	lookup *pendingLookup
+
+	// absent are the metric fields missing from the log as read
+	absent absentFields
 }
 
 type WindowData struct {
+	Source    string
+	Tenant    string
 	Values    []float64
 	IPs       map[string]bool
+	IPCounts  map[string]int
 	LastMean  float64
 	StartTime time.Time
 	EndTime   time.Time
+	UpdatedAt time.Time
+
+	// IPFilter replaces IPs once the window has seen more distinct IPs than
+	// ip_set.exact_limit.
+	IPFilter *IPBloomFilter `json:",omitempty"`
+
+	// ValuesHead is the position of the oldest of the Values once they have
+	// wrapped around under window_buffer.max_samples. Values are otherwise
+	// held in arrival order.
+	ValuesHead int `json:",omitempty"`
+
+	// Enrichment holds fields merged from the HTTP enricher when it is
+	// configured to merge into the window result.
+	Enrichment map[string]interface{}
+
+	// Metrics holds the values of the metrics of a multi-metric source other
+	// than its first, keyed by feature prefix.
+	Metrics map[string][]float64
+	// MetricHeads holds the ValuesHead of each of the Metrics that wrapped
+	// around.
+	MetricHeads map[string]int `json:",omitempty"`
+
+	// Severities counts the logs of the window by canonical severity.
+	Severities map[string]int `json:",omitempty"`
+
+	// Pending holds the raw logs of the window that are acknowledged once
+	// the window has been emitted.
+	Pending []string
 }
 
 type FirewallAnomalyDetector struct {
-	logger  *service.Logger
-	metrics *service.Metrics
-
-	windowSeconds  int
-	modelPath      string
-	scoreThreshold float64
+	logger    *service.Logger
+	metrics   *service.Metrics
+	resources *service.Resources
+
+	windowSeconds   int
+	modelPath       string
+	scoreThreshold  float64
+	dryRun          bool
+	maxLateness     time.Duration
+	clock           func() time.Time
+	onScored        func(latency time.Duration)
+	onDropped       func(source, reason string)
+	onDecided       func(decision auditRecord)
+	eventTime       *eventTimeParser
+	clockSkews      *clockSkews
+	reorder         *reorderBuffer
+	decoder         logDecoder
+	outputSchema    string
+	schemaMajor     int
+	outputVerbosity string
+	outputTopIPs    int
 
 	redisClient *redis.Client
 	redisKey    string
@@ -126,19 +344,132 @@ type FirewallAnomalyDetector struct {
 	anomalyTopic string
 	normalTopic  string
 
-	sources map[string]string // log_source -> metric_field
+	sources          map[string]string  // log_source -> metric_field
+	sourceThresholds map[string]float64 // log_source -> score_threshold override
+	sourceWindows    map[string]time.Duration
+	sourceMetrics    map[string][]sourceMetric
+	sourceFeatures   map[string]featureSet
+	sourcePriorities map[string]int
+	sourcePatterns   []sourcePattern
+	sourceMatches    *sync.Map // log_source -> matching pattern key
+	units            *unitNormaliser
+	ipv6Prefixes     map[string]int // `sources` key -> IPv6 aggregation prefix
+
+	// settingsMut guards the settings that the control channel changes at
+	// runtime: scoreThreshold, the topics and the per-source maps.
+	settingsMut sync.RWMutex
 
 	windows      map[string]*WindowData
 	windowsMutex sync.RWMutex
 
+	// closed holds the window last closed per window key, guarded by
+	// windowsMutex. Logs before its end are late.
+	closed      map[string]closedWindow
+	windowGrace time.Duration
+	// configEpoch is hashed into window IDs
+	configEpoch int
+	// emitEmptyWindows emits a result for every window without logs of the
+	// keys in closed
+	emitEmptyWindows bool
+	// holdWindows keeps windows open until they are drained, however long
+	// their logs span
+	holdWindows bool
+
+	// missingFields are the fallbacks of fields missing from logs
+	missingFields missingFields
+	// severities normalises the severities of logs
+	severities *severityNormaliser
+
+	enricher     *httpEnricher
+	alerts       *alertDispatcher
+	suppressions []*suppressionSchedule
+	partitioner  *partitioner
+	locker       *redisLocker
+	shutdown     *shutdownPolicy
+	snapshots    *snapshotter
+	consumer     *logConsumer
+	budget       *consumptionBudget
+	workers      *workerPool
+	tenants      *tenantConfig
+	spill        *windowSpill
+	stateGC      *stateCollector
+	buffers      windowBuffers
+	ipSets       ipSets
+	replication  *replicator
+	rateLimiter  *logRateLimiter
+	faults       *faultInjector
+	histograms   *detectorHistograms
+	health       *healthChecks
+	status       *statusTracker
+	audit        *auditTrail
+	feedback     *feedbackStore
+	stix         *stixExporter
+	responder    *activeResponder
+	emitter      *resultEmitter
+	selfMonitor  *selfMonitor
+	drift        *driftMonitor
+	debugSampler *debugSampler
+	features     *featureExporter
+	featureStore *featureStore
+	feast        *feastPusher
+	adaptive     *adaptiveThresholds
+	baselines    *baselineStore
+	strict       *strictMode
+	validator    *logValidator
+	control      *controlChannel
+	ui           *webUI
+
+	// thresholdControl adjusts thresholds to hold a precision and alert
+	// budget
+	thresholdControl *thresholdController
+
 	// Metrics
 	processedLogs     *service.MetricCounter
 	anomaliesDetected *service.MetricCounter
 	windowsCreated    *service.MetricCounter
+	enrichmentErrors  *service.MetricCounter
+	enrichmentSkipped *service.MetricCounter
+
+	anomaliesSuppressed *service.MetricCounter
+	consumptionPaused   *service.MetricGauge
+	windowsSpilled      *service.MetricCounter
+	samplesOverwritten  *service.MetricCounter
+	ipSetsApproximated  *service.MetricCounter
+	logsRateLimited     *service.MetricCounter
+	logsSampled         *service.MetricCounter
+	logsDropped         *service.MetricCounter
+	logsDefaultSource   *service.MetricCounter
+	eventTimeFallbacks  *service.MetricCounter
+	logsMissingField    *service.MetricCounter
+	counterResets       *service.MetricCounter
+	counterWraps        *service.MetricCounter
+	reorderBuffered     *service.MetricGauge
+
+	clockSkew            *service.MetricGauge
+	clockSkewCorrections *service.MetricCounter
+	featuresSanitised    *service.MetricCounter
+	emptyWindowsEmitted  *service.MetricCounter
+
+	redisPipelineSize     *service.MetricGauge
+	redisPipelineCommands *service.MetricCounter
+
+	redisReadLatency *service.MetricTimer
+	parseLatency     *service.MetricTimer
+	scoringLatency   *service.MetricTimer
+	emissionLag      *service.MetricTimer
+
+	activeWindows    *service.MetricGauge
+	bufferedValues   *service.MetricGauge
+	windowStateBytes *service.MetricGauge
 }
 
 func newFirewallAnomalyDetector(conf *service.ParsedConfig, mgr *service.Resources) (*FirewallAnomalyDetector, error) {
-	windowSeconds, err := conf.FieldInt("window_seconds")
+	preset, err := profileFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	windowSeconds, err := fieldIntOr(conf, preset.windowSeconds, "window_seconds")
 	if err != nil {
 		return nil, err
 	}
@@ -153,13 +484,86 @@ func newFirewallAnomalyDetector(conf *service.ParsedConfig, mgr *service.Resourc
 		return nil, err
 	}
 
+	dryRun, err := conf.FieldBool("dry_run")
+	if err != nil {
+		return nil, err
+	}
+
+	maxLateness, err := conf.FieldDuration("max_lateness")
+	if err != nil {
+		return nil, err
+	}
+
+	configEpoch, err := conf.FieldInt("config_epoch")
+	if err != nil {
+		return nil, err
+	}
+	if configEpoch < 0 {
+		return nil, fmt.Errorf("config_epoch must not be negative, got %d", configEpoch)
+	}
+
+	windowGrace, err := conf.FieldDuration("window_grace")
+	if err != nil {
+		return nil, err
+	}
+	if windowGrace < 0 {
+		return nil, fmt.Errorf("window_grace must not be negative, got %v", windowGrace)
+	}
+
+	emitEmptyWindows, err := conf.FieldBool("empty_windows", "emit")
+	if err != nil {
+		return nil, err
+	}
+
+	eventTime, err := newEventTimeParserFromConfig(conf.Namespace("event_time"))
+	if err != nil {
+		return nil, err
+	}
+
+	clockSkews, err := newClockSkewsFromConfig(conf.Namespace("clock_skew"))
+	if err != nil {
+		return nil, err
+	}
+
+	reorder, err := newReorderBufferFromConfig(conf.Namespace("reorder"))
+	if err != nil {
+		return nil, err
+	}
+
+	outputSchema, err := conf.FieldString("output_schema")
+	if err != nil {
+		return nil, err
+	}
+	schemaMajor, err := schemaMajorFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+	outputVerbosity, err := conf.FieldString("output_verbosity")
+	if err != nil {
+		return nil, err
+	}
+	outputTopIPs, err := conf.FieldInt("output_top_ips")
+	if err != nil {
+		return nil, err
+	}
+	if outputTopIPs <= 0 {
+		return nil, fmt.Errorf("output_top_ips must be positive, got %d", outputTopIPs)
+	}
+
 	// Parse Redis config
 	redisAddr, err := conf.FieldString("redis_config", "address")
 	if err != nil {
 		return nil, err
 	}
 
-	redisPassword, _ := conf.FieldString("redis_config", "password")
+	secrets, err := newSecretResolverFromConfig(conf.Namespace("secrets"))
+	if err != nil {
+		return nil, err
+	}
+	redisPassword, err := secrets.resolveCredential(conf, "redis_config", "password")
+	if err != nil {
+		return nil, err
+	}
 	redisDB, err := conf.FieldInt("redis_config", "db")
 	if err != nil {
 		return nil, err
@@ -186,6 +590,11 @@ func newFirewallAnomalyDetector(conf *service.ParsedConfig, mgr *service.Resourc
 		return nil, err
 	}
 
+	severities, err := newSeverityNormaliserFromConfig(conf.Namespace("severity"))
+	if err != nil {
+		return nil, err
+	}
+
 	// Parse sources config
 	sourcesMap, err := conf.FieldObjectMap("sources")
 	if err != nil {
@@ -193,12 +602,116 @@ func newFirewallAnomalyDetector(conf *service.ParsedConfig, mgr *service.Resourc
 	}
 
 	sources := make(map[string]string)
+	sourceThresholds := make(map[string]float64)
+	sourceWindows := make(map[string]time.Duration)
+	sourceMetrics := make(map[string][]sourceMetric)
+	sourceFeatures := make(map[string]featureSet)
+	sourcePriorities := make(map[string]int)
+	sourceUnitsMap := make(map[string]*sourceUnits)
+	ipv6Prefixes := make(map[string]int)
 	for source, sourceConf := range sourcesMap {
 		metric, err := sourceConf.FieldString("metric")
 		if err != nil {
 			return nil, err
 		}
 		sources[source] = metric
+
+		metrics, err := parseSourceMetrics(source, sourceConf)
+		if err != nil {
+			return nil, err
+		}
+		if len(metrics) > 0 {
+			sources[source] = metrics[0].field
+			sourceMetrics[source] = metrics
+		}
+
+		features, err := parseSourceFeatures(source, sourceConf)
+		if err != nil {
+			return nil, err
+		}
+		if features != defaultFeatures {
+			sourceFeatures[source] = features
+		}
+
+		units, err := parseSourceUnits(sourceConf)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", source, err)
+		}
+		if units != nil {
+			sourceUnitsMap[source] = units
+		}
+
+		if err := severities.parseSourceSeverities(source, sourceConf); err != nil {
+			return nil, err
+		}
+
+		prefix, err := parseSourceIPv6Prefix(source, sourceConf)
+		if err != nil {
+			return nil, err
+		}
+		if prefix > 0 {
+			ipv6Prefixes[source] = prefix
+		}
+
+		location, err := parseSourceTimezone(source, sourceConf)
+		if err != nil {
+			return nil, err
+		}
+		if location != nil {
+			eventTime.locations[source] = location
+		}
+
+		if sourcePriorities[source], err = fieldIntOr(sourceConf, 0, "priority"); err != nil {
+			return nil, err
+		}
+
+		if sourceConf.Contains("score_threshold") {
+			if sourceThresholds[source], err = sourceConf.FieldFloat("score_threshold"); err != nil {
+				return nil, err
+			}
+		}
+
+		sourceWindows[source] = time.Duration(windowSeconds) * time.Second
+		if sourceConf.Contains("window_seconds") {
+			seconds, err := sourceConf.FieldInt("window_seconds")
+			if err != nil {
+				return nil, err
+			}
+			if seconds <= 0 {
+				return nil, fmt.Errorf("window_seconds of source %s must be positive", source)
+			}
+			sourceWindows[source] = time.Duration(seconds) * time.Second
+		}
+	}
+
+	defaultSource, err := parseDefaultSource(conf, windowSeconds)
+	if err != nil {
+		return nil, err
+	}
+	defaultSource.addTo(sources, sourceThresholds, sourceWindows)
+
+	sourcePatterns, err := compileSourcePatterns(sources, sourcePriorities)
+	if err != nil {
+		return nil, err
+	}
+
+	enricher, err := newHTTPEnricherFromConfig(conf.Namespace("http_enrichment"))
+	if err != nil {
+		return nil, err
+	}
+
+	alerts, err := newAlertDispatcherFromConfig(conf.Namespace("alerts"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduleConfs, err := conf.FieldObjectList("suppression_schedules")
+	if err != nil {
+		return nil, err
+	}
+	suppressions, err := newSuppressionSchedulesFromConfig(scheduleConfs)
+	if err != nil {
+		return nil, err
 	}
 
 	// Initialize Redis client
@@ -208,175 +721,1072 @@ func newFirewallAnomalyDetector(conf *service.ParsedConfig, mgr *service.Resourc
 		DB:       redisDB,
 	})
 
+	partitioner, err := newPartitionerFromConfig(conf.Namespace("partitioning"), redisClient, mgr.Logger())
+	if err != nil {
+		return nil, err
+	}
+
+	locker, err := newRedisLockerFromConfig(conf.Namespace("distributed_locks"), redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdown, err := newShutdownPolicyFromConfig(conf.Namespace("shutdown"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := newSnapshotterFromConfig(conf.Namespace("snapshot"))
+	if err != nil {
+		return nil, err
+	}
+
+	consumer, err := newLogConsumerFromConfig(conf.Namespace("consumption"), redisClient, redisKey, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	budget, err := newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumer.mode, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	workers, err := newWorkerPoolFromConfig(conf.Namespace("worker_pool"))
+	if err != nil {
+		return nil, err
+	}
+
+	tenants, err := newTenantConfigFromParsed(conf)
+	if err != nil {
+		return nil, err
+	}
+	labelKeys := metricLabelKeys(tenants)
+
+	spill, err := newWindowSpillFromConfig(conf.Namespace("memory_budget"), redisClient, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	stateGC, err := newStateCollectorFromConfig(conf.Namespace("state_gc"), mgr.Metrics())
+	if err != nil {
+		return nil, err
+	}
+
+	buffers, err := newWindowBuffersFromConfig(conf.Namespace("window_buffer"))
+	if err != nil {
+		return nil, err
+	}
+
+	ipSets, err := newIPSetsFromConfig(conf.Namespace("ip_set"))
+	if err != nil {
+		return nil, err
+	}
+
+	replication, err := newReplicatorFromConfig(conf.Namespace("replication"), redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimiter, err := newLogRateLimiterFromConfig(conf.Namespace("rate_limit"), redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	faults, err := newFaultInjectorFromConfig(conf.Namespace("fault_injection"), mgr.Metrics())
+	if err != nil {
+		return nil, err
+	}
+	if faults != nil {
+		mgr.Logger().Warnf("Fault injection is enabled, logs read from Redis are corrupted, duplicated, reordered and delayed on purpose")
+	}
+
+	histograms, err := newDetectorHistogramsFromConfig(conf.Namespace("histograms"), mgr.Metrics(), labelKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	health, err := newHealthChecksFromConfig(conf.Namespace("health"), redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := newStatusTrackerFromConfig(conf.Namespace("status_endpoint"))
+	if err != nil {
+		return nil, err
+	}
+
+	audit, err := newAuditTrailFromConfig(conf.Namespace("audit"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	feedback, err := newFeedbackStoreFromConfig(conf, mgr, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	thresholdControl, err := newThresholdControllerFromConfig(conf.Namespace("threshold_control"), mgr.Metrics())
+	if err != nil {
+		return nil, err
+	}
+	if thresholdControl != nil && thresholdControl.targetPrecision > 0 && feedback == nil {
+		return nil, errors.New("threshold_control.target_precision requires feedback.enabled")
+	}
+
+	stix, err := newSTIXExporterFromConfig(conf, mgr.Metrics(), secrets)
+	if err != nil {
+		return nil, err
+	}
+	if stix != nil && feedback == nil {
+		return nil, errors.New("stix_export requires feedback.enabled to confirm anomalies")
+	}
+
+	responder, err := newActiveResponderFromConfig(conf, redisClient, mgr, secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	emitter, err := newResultEmitterFromConfig(conf.Namespace("async_emission"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	selfMonitor, err := newSelfMonitorFromConfig(conf.Namespace("self_monitoring"), sourceWindows)
+	if err != nil {
+		return nil, err
+	}
+
+	drift, err := newDriftMonitorFromConfig(conf.Namespace("drift"), conf.Namespace("histograms"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	debugSampler, err := newDebugSamplerFromConfig(conf.Namespace("debug_sample"))
+	if err != nil {
+		return nil, err
+	}
+
+	featureExport, err := newFeatureExporterFromConfig(conf.Namespace("feature_export"))
+	if err != nil {
+		return nil, err
+	}
+
+	featureStore, err := newFeatureStoreFromConfig(conf.Namespace("feature_store"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	feast, err := newFeastPusherFromConfig(conf.Namespace("feast"), mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	adaptive, err := newAdaptiveThresholdsFromConfig(conf.Namespace("adaptive_threshold"), mgr.Metrics(), labelKeys, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	baselines, err := newBaselineStoreFromConfig(conf.Namespace("baseline_cache"))
+	if err != nil {
+		return nil, err
+	}
+
+	strict, err := newStrictModeFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	validator, err := newLogValidatorFromConfig(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	missingFields, err := newMissingFieldsFromConfig(conf.Namespace("missing_fields"))
+	if err != nil {
+		return nil, err
+	}
+
+	control, err := newControlChannelFromConfig(conf.Namespace("control"), redisClient)
+	if err != nil {
+		return nil, err
+	}
+
 	detector := &FirewallAnomalyDetector{
 		logger:            mgr.Logger(),
 		metrics:           mgr.Metrics(),
+		resources:         mgr,
 		windowSeconds:     windowSeconds,
 		modelPath:         modelPath,
 		scoreThreshold:    scoreThreshold,
+		dryRun:            dryRun,
+		maxLateness:       maxLateness,
+		eventTime:         eventTime,
+		clockSkews:        clockSkews,
+		reorder:           reorder,
+		decoder:           newLogDecoder(eventTime, tenants),
+		outputSchema:      outputSchema,
+		schemaMajor:       schemaMajor,
+		outputVerbosity:   outputVerbosity,
+		outputTopIPs:      outputTopIPs,
 		redisClient:       redisClient,
 		redisKey:          redisKey,
 		kafkaBrokers:      kafkaBrokers,
 		anomalyTopic:      anomalyTopic,
 		normalTopic:       normalTopic,
 		sources:           sources,
+		sourceThresholds:  sourceThresholds,
+		sourceWindows:     sourceWindows,
+		sourceMetrics:     sourceMetrics,
+		sourceFeatures:    sourceFeatures,
+		sourcePriorities:  sourcePriorities,
+		sourcePatterns:    sourcePatterns,
+		sourceMatches:     &sync.Map{},
+		units:             newUnitNormaliser(sourceUnitsMap),
+		ipv6Prefixes:      ipv6Prefixes,
 		windows:           make(map[string]*WindowData),
-		processedLogs:     mgr.Metrics().NewCounter("processed_logs"),
-		anomaliesDetected: mgr.Metrics().NewCounter("anomalies_detected"),
-		windowsCreated:    mgr.Metrics().NewCounter("windows_created"),
+		closed:            make(map[string]closedWindow),
+		windowGrace:       windowGrace,
+		configEpoch:       configEpoch,
+		emitEmptyWindows:  emitEmptyWindows,
+		enricher:          enricher,
+		alerts:            alerts,
+		suppressions:      suppressions,
+		partitioner:       partitioner,
+		locker:            locker,
+		shutdown:          shutdown,
+		snapshots:         snapshots,
+		consumer:          consumer,
+		budget:            budget,
+		workers:           workers,
+		tenants:           tenants,
+		spill:             spill,
+		stateGC:           stateGC,
+		buffers:           buffers,
+		ipSets:            ipSets,
+		replication:       replication,
+		rateLimiter:       rateLimiter,
+		faults:            faults,
+		histograms:        histograms,
+		health:            health,
+		status:            status,
+		audit:             audit,
+		feedback:          feedback,
+		stix:              stix,
+		responder:         responder,
+		thresholdControl:  thresholdControl,
+		emitter:           emitter,
+		selfMonitor:       selfMonitor,
+		drift:             drift,
+		debugSampler:      debugSampler,
+		features:          featureExport,
+		featureStore:      featureStore,
+		feast:             feast,
+		adaptive:          adaptive,
+		baselines:         baselines,
+		strict:            strict,
+		validator:         validator,
+		missingFields:     missingFields,
+		severities:        severities,
+		control:           control,
+		processedLogs:     mgr.Metrics().NewCounter("processed_logs", labelKeys...),
+		anomaliesDetected: mgr.Metrics().NewCounter("anomalies_detected", labelKeys...),
+		windowsCreated:    mgr.Metrics().NewCounter("windows_created", labelKeys...),
+		enrichmentErrors:  mgr.Metrics().NewCounter("enrichment_errors"),
+		enrichmentSkipped: mgr.Metrics().NewCounter("enrichment_lookups_skipped"),
+
+		anomaliesSuppressed: mgr.Metrics().NewCounter("anomalies_suppressed", labelKeys...),
+		consumptionPaused:   mgr.Metrics().NewGauge("consumption_paused"),
+		windowsSpilled:      mgr.Metrics().NewCounter("windows_spilled"),
+		samplesOverwritten:  mgr.Metrics().NewCounter("window_samples_overwritten", labelKeys...),
+		ipSetsApproximated:  mgr.Metrics().NewCounter("window_ip_sets_approximated", labelKeys...),
+		logsRateLimited:     mgr.Metrics().NewCounter("logs_rate_limited", labelKeys...),
+		logsSampled:         mgr.Metrics().NewCounter("logs_rate_limit_sampled", labelKeys...),
+		logsDropped:         mgr.Metrics().NewCounter("logs_dropped", append(append([]string(nil), labelKeys...), "reason")...),
+		logsDefaultSource:   mgr.Metrics().NewCounter("logs_default_source"),
+		eventTimeFallbacks:  mgr.Metrics().NewCounter("logs_event_time_fallback", labelKeys...),
+		logsMissingField:    mgr.Metrics().NewCounter("logs_missing_field", append(append([]string(nil), labelKeys...), "field")...),
+		counterResets:       mgr.Metrics().NewCounter("counter_resets", labelKeys...),
+		counterWraps:        mgr.Metrics().NewCounter("counter_wraps", labelKeys...),
+		reorderBuffered:     mgr.Metrics().NewGauge("reorder_buffer_logs"),
+
+		clockSkew:            mgr.Metrics().NewGauge("clock_skew_ms", labelKeys...),
+		clockSkewCorrections: mgr.Metrics().NewCounter("clock_skew_corrections", labelKeys...),
+		featuresSanitised:    mgr.Metrics().NewCounter("features_sanitised", labelKeys...),
+		emptyWindowsEmitted:  mgr.Metrics().NewCounter("empty_windows_emitted", labelKeys...),
+
+		redisPipelineSize:     mgr.Metrics().NewGauge("redis_pipeline_size"),
+		redisPipelineCommands: mgr.Metrics().NewCounter("redis_pipeline_commands"),
+
+		redisReadLatency: mgr.Metrics().NewTimer("redis_read_latency_ns"),
+		parseLatency:     mgr.Metrics().NewTimer("parse_latency_ns"),
+		scoringLatency:   mgr.Metrics().NewTimer("scoring_latency_ns", labelKeys...),
+		emissionLag:      mgr.Metrics().NewTimer("emission_lag_ns", labelKeys...),
+
+		activeWindows:    mgr.Metrics().NewGauge("active_windows"),
+		bufferedValues:   mgr.Metrics().NewGauge("buffered_window_values"),
+		windowStateBytes: mgr.Metrics().NewGauge("window_state_bytes"),
+	}
+
+	if err := detector.loadModel(context.Background()); err != nil {
+		return nil, err
+	}
+	if err := detector.registerDebugEndpoint(conf.Namespace("debug_endpoint")); err != nil {
+		return nil, err
+	}
+	if err := detector.registerWebUI(conf.Namespace("web_ui")); err != nil {
+		return nil, err
+	}
+	if err := detector.registerHealthEndpoints(conf.Namespace("health")); err != nil {
+		return nil, err
+	}
+	if err := detector.registerStatusEndpoint(conf.Namespace("status_endpoint")); err != nil {
+		return nil, err
+	}
+	if err := detector.registerFeedbackEndpoint(); err != nil {
+		return nil, err
+	}
+	if err := baselines.publish(); err != nil {
+		return nil, err
 	}
 
-	// Load ML model (placeholder - would integrate with actual ML library)
-	detector.logger.Infof("Loading ML model from: %s", modelPath)
+	partitioner.onHeartbeat = detector.rebalance
+	if selfMonitor != nil {
+		selfMonitor.windowLength = detector.windowLength
+	}
+	partitioner.start()
+
+	if err := detector.restoreSnapshot(); err != nil {
+		detector.logger.Warnf("Failed to restore window snapshot: %v", err)
+	}
+	if err := detector.restoreWindows(context.Background()); err != nil {
+		detector.logger.Warnf("Failed to restore persisted windows: %v", err)
+	}
+	if err := detector.recoverConsumption(context.Background()); err != nil {
+		detector.logger.Warnf("Failed to re-deliver unacknowledged logs: %v", err)
+	}
+	detector.startSnapshots()
+	detector.startReplication()
+	detector.startControl()
+	if detector.emitter != nil {
+		detector.emitter.ack = func(ctx context.Context, raws []string) {
+			detector.ackLogs(ctx, raws...)
+		}
+		detector.emitter.requeue = func(ctx context.Context, raws []string) {
+			for _, raw := range raws {
+				detector.requeueLog(ctx, raw)
+			}
+		}
+	}
+	detector.emitter.start()
+	detector.responder.start(time.Second)
+	detector.feast.start()
+	detector.startStateGC()
 
 	return detector, nil
 }
 
-func (f *FirewallAnomalyDetector) Process(ctx context.Context, m *service.Message) (service.MessageBatch, error) {
+// ProcessBatch handles a batch of messages with a single read from Redis on
+// behalf of all of them, so that Redis round trips, the window bookkeeping
+// done per read and the periodic events are amortised across the batch.
+func (f *FirewallAnomalyDetector) ProcessBatch(ctx context.Context, batch service.MessageBatch) ([]service.MessageBatch, error) {
+	results, err := f.process(ctx, len(batch))
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return []service.MessageBatch{results}, nil
+}
+
+// process reads logs from Redis on behalf of the given number of input
+// messages and processes them.
+func (f *FirewallAnomalyDetector) process(ctx context.Context, reads int) (service.MessageBatch, error) {
+	defer f.updateWindowGauges()
+
+	// Standbys only replay the active instance's state
+	if !f.replication.isActive() {
+		return nil, nil
+	}
+
+	// Acknowledgements and requeues of the cycle go out in one pipeline
+	ctx, batch := withRedisBatch(ctx)
+	defer f.flushRedisBatch(ctx, batch)
+
 	// Read logs from Redis
-	logs, err := f.readLogsFromRedis(ctx)
+	logs, err := f.readLogsFromRedis(ctx, reads)
 	if err != nil {
 		f.logger.Errorf("Failed to read logs from Redis: %v", err)
 		return nil, err
 	}
 
-	var results []*service.Message
-
-	for _, log := range logs {
-		// Process each log through sliding windows
-		result, err := f.processLog(ctx, log)
-		if err != nil {
-			f.logger.Errorf("Failed to process log: %v", err)
-			continue
-		}
+	// Out of order logs are sorted by event time before they reach windows
+	logs = f.reorder.release(logs, f.now())
+	if f.reorder != nil {
+		f.reorderBuffered.Set(int64(f.reorder.size()))
+	}
 
-		if result != nil {
-			results = append(results, result)
+	// Process each log through sliding windows, then close the windows the
+	// clock passed. Asynchronously emitted results are queued for the
+	// flusher rather than returned.
+	results := f.processLogs(ctx, logs)
+	closed, err := f.closeDueWindows(ctx)
+	results = append(results, closed...)
+	if err != nil {
+		f.logger.Errorf("Failed to emit closed windows: %v", err)
+	}
+	f.controlThresholds(f.now())
+	f.featureStore.flush(ctx, f.now(), false)
+	if f.emitter != nil {
+		if err := f.emitter.enqueue(ctx, results); err != nil {
+			return nil, err
 		}
+		results = nil
 	}
+	results = append(results, f.selfMonitoringEvents(ctx)...)
+	results = append(results, f.driftEvents(ctx)...)
+	results = append(results, f.debugSampler.drain()...)
+	results = append(results, f.features.drain()...)
 
 	return results, nil
 }
 
-func (f *FirewallAnomalyDetector) readLogsFromRedis(ctx context.Context) ([]FirewallLog, error) {
+func (f *FirewallAnomalyDetector) readLogsFromRedis(ctx context.Context, reads int) ([]FirewallLog, error) {
 	// Read from Redis list
-	result, err := f.redisClient.LRange(ctx, f.redisKey, 0, -1).Result()
+	readStart := time.Now()
+	f.faults.delayRead(ctx)
+	result, err := f.consumer.read(ctx, f.readAllowance(), reads)
 	if err != nil {
 		return nil, err
 	}
+	f.redisReadLatency.Timing(time.Since(readStart).Nanoseconds())
+	result = f.faults.inject(result, func(original string) {
+		// The corrupted copy is dropped, so the original is never
+		// acknowledged otherwise
+		f.ackLogs(ctx, original)
+	})
+	if len(result) > 0 {
+		f.health.recordRead()
+	}
 
 	var logs []FirewallLog
 	for _, item := range result {
-		var log FirewallLog
-		if err := json.Unmarshal([]byte(item), &log); err != nil {
-			f.logger.Warnf("Failed to parse log entry: %v", err)
+		parseStart := time.Now()
+		log, ok := f.parseLog(ctx, item)
+		if !ok {
 			continue
 		}
+		f.parseLatency.Timing(time.Since(parseStart).Nanoseconds())
 		logs = append(logs, log)
 	}
 
 	return logs, nil
 }
 
+// parseLog decodes a raw log and resolves its tenant and event time. Logs
+// that can not be windowed are dropped and ok is false.
+func (f *FirewallAnomalyDetector) parseLog(ctx context.Context, item string) (log FirewallLog, ok bool) {
+	log, doc, err := f.decoder.decode(item)
+	if err != nil {
+		f.logger.Warnf("Failed to parse log entry: %v", err)
+		f.dropLog(ctx, item, "", "", dropReasonParseFailure)
+		return log, false
+	}
+	if f.consumer.acking() {
+		log.consumed = item
+	}
+	if f.strict != nil || f.validator != nil {
+		log.original = item
+	}
+	log.tenant = f.tenants.tenantOf(doc)
+	fellBack, err := f.eventTime.stamp(&log, doc, f.sourceKey(log.LogSource), f.now())
+	if err != nil {
+		f.logger.Debugf("Dropping log of %s: %v", log.LogSource, err)
+		f.dropLog(ctx, item, log.tenant, log.LogSource, dropReasonInvalidEventTime)
+		return log, false
+	}
+	if fellBack {
+		f.eventTimeFallbacks.Incr(1, f.metricLabels(log.tenant, log.LogSource)...)
+	} else {
+		f.correctClockSkew(&log, f.now())
+	}
+	return log, true
+}
+
+// now returns the time windows complete and logs age against, which is the
+// wall clock unless the detector replays historical logs in event time.
+func (f *FirewallAnomalyDetector) now() time.Time {
+	if f.clock != nil {
+		return f.clock()
+	}
+	return time.Now()
+}
+
+// Reasons logs are dropped for, used as the reason label of logs_dropped.
+const (
+	dropReasonParseFailure  = "parse_failure"
+	dropReasonUnknownSource = "unknown_source"
+	dropReasonUnknownMetric = "unknown_metric"
+	dropReasonLate          = "late"
+
+	dropReasonInvalidEventTime = "invalid_event_time"
+	dropReasonCounterBaseline  = "counter_baseline"
+	dropReasonInvalidSchema    = "invalid_schema"
+	dropReasonMissingSourceIP  = "missing_source_ip"
+	dropReasonMissingMetric    = "missing_metric"
+)
+
+// dropLog acknowledges a log that will never contribute to a window and
+// counts it by reason.
+func (f *FirewallAnomalyDetector) dropLog(ctx context.Context, raw, tenant, source, reason string) {
+	f.logsDropped.Incr(1, append(f.metricLabels(tenant, source), reason)...)
+	if f.onDropped != nil {
+		f.onDropped(source, reason)
+	}
+	f.ackLogs(ctx, raw)
+}
+
+// isLate reports whether a log is too old to be windowed.
+func (f *FirewallAnomalyDetector) isLate(timestamp, now time.Time) bool {
+	return f.maxLateness > 0 && now.Sub(timestamp) > f.maxLateness
+}
+
 func (f *FirewallAnomalyDetector) processLog(ctx context.Context, log FirewallLog) (*service.Message, error) {
-	f.processedLogs.Incr(1)
+	f.processedLogs.Incr(1, f.metricLabels(log.tenant, log.LogSource)...)
+
+	if violations := f.validator.validate(log); len(violations) > 0 {
+		f.logger.Debugf("Rejecting invalid log of %s: %v", log.LogSource, violations)
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonInvalidSchema)
+		return f.validator.reject(log, violations), nil
+	}
 
 	// Get metric field for this log source
-	metricField, exists := f.sources[log.LogSource]
+	metricField, exists := f.metricFieldFor(log.LogSource)
 	if !exists {
 		f.logger.Warnf("No configuration found for log source: %s", log.LogSource)
-		return nil, nil
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonUnknownSource)
+		return f.strict.reject(log, dropReasonUnknownSource, fmt.Errorf("no configuration found for log source %s", log.LogSource)), nil
 	}
+	sourceKey := f.sourceKey(log.LogSource)
+	if sourceKey == defaultSourceKey {
+		f.logsDefaultSource.Incr(1)
+	}
+	f.selfMonitor.observeLog(sourceKey)
 
 	// Extract metric value
-	var metricValue float64
-	switch metricField {
-	case "connection_count":
-		metricValue = float64(log.ConnectionCount)
-	case "bytes_sent":
-		metricValue = float64(log.BytesSent)
-	case "bytes_recv":
-		metricValue = float64(log.BytesRecv)
-	default:
+	metricValue, ok := metricValueOf(log, metricField)
+	if !ok {
 		f.logger.Warnf("Unknown metric field: %s", metricField)
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonUnknownMetric)
+		return f.strict.reject(log, dropReasonUnknownMetric, fmt.Errorf("unknown metric field %s of log source %s", metricField, log.LogSource)), nil
+	}
+	secondaryValues, err := f.secondaryMetricValues(log)
+	if err != nil {
+		f.logger.Warnf("%v", err)
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonUnknownMetric)
+		return f.strict.reject(log, dropReasonUnknownMetric, err), nil
+	}
+	if reason := f.tolerateMissingFields(&log, metricField); reason != "" {
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, reason)
 		return nil, nil
 	}
+	f.normaliseIPs(&log)
 
-	// Update sliding window
-	windowKey := log.LogSource
-	f.updateWindow(windowKey, metricValue, log.SourceIP, log.Timestamp)
+	// Windows owned by another replica are scored there
+	windowKey := windowKeyFor(log.tenant, log.LogSource)
+	if !f.partitioner.owns(windowKey) {
+		f.requeueLog(ctx, log.consumed)
+		return nil, nil
+	}
+
+	if f.isLate(log.Timestamp, f.now()) {
+		f.logger.Debugf("Dropping late log of %s from %v", log.LogSource, log.Timestamp)
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonLate)
+		return nil, nil
+	}
 
-	// Check if window is complete and ready for analysis
-	window := f.getWindow(windowKey)
-	if window == nil || time.Since(window.EndTime) < time.Duration(f.windowSeconds)*time.Second {
+	// Units are normalised and cumulative counters converted to deltas before
+	// rate limiting so that every log moves its counters on
+	metricValue, secondaryValues, counted := f.normaliseMetrics(windowKey, log.tenant, log.LogSource, metricField, metricValue, secondaryValues)
+	if !counted {
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonCounterBaseline)
 		return nil, nil
 	}
 
+	// Noisy sources are capped before they reach windowing
+	admitted, sampled, err := f.rateLimiter.admit(ctx, log.tenant, log.LogSource)
+	if err != nil {
+		f.logger.Warnf("Rate limiter unavailable, admitting log: %v", err)
+		admitted = true
+	}
+	if !admitted {
+		f.logsRateLimited.Incr(1, f.metricLabels(log.tenant, log.LogSource)...)
+		f.ackLogs(ctx, log.consumed)
+		return nil, nil
+	}
+	if sampled {
+		f.logsSampled.Incr(1, f.metricLabels(log.tenant, log.LogSource)...)
+	}
+
+	// Enrich the log before it contributes to the window
+	enrichment := f.applyEnrichment(ctx, &log)
+
+	// Add the log to its window, which closes the previous window of the key
+	// once the log is past its end
+	f.rehydrateWindow(ctx, windowKey)
+	closed, late := f.observeWindow(windowKey, windowUpdate{
+		tenant:     log.tenant,
+		source:     log.LogSource,
+		value:      metricValue,
+		secondary:  secondaryValues,
+		sourceIP:   log.SourceIP,
+		timestamp:  log.Timestamp,
+		enrichment: enrichment,
+		severity:   f.severities.normalise(sourceKey, log.Severity),
+		pending:    log.consumed,
+	})
+	if late {
+		f.logger.Debugf("Dropping log of %s from %v, its window is closed", log.LogSource, log.Timestamp)
+		f.dropLog(ctx, log.consumed, log.tenant, log.LogSource, dropReasonLate)
+		return nil, nil
+	}
+	f.replication.markDirty(windowKey)
+	if closed == nil {
+		f.enforceMemoryBudget(ctx)
+		return nil, nil
+	}
+	return f.emitClosedWindow(ctx, windowKey, closed)
+}
+
+// emitClosedWindow scores a window that was closed and acknowledges its
+// logs, which no longer need re-delivery. Under async_emission they are
+// acknowledged once the flusher has written the result.
+func (f *FirewallAnomalyDetector) emitClosedWindow(ctx context.Context, windowKey string, window *WindowData) (*service.Message, error) {
+	source, _ := windowScope(windowKey, window)
+	metricField, _ := f.metricFieldFor(source)
+	resultMsg, err := f.scoreWindow(ctx, windowKey, window, metricField, window.lastValue(), false)
+	if err != nil {
+		return nil, err
+	}
+	if resultMsg != nil && f.emitter != nil {
+		f.emitter.hold(resultMsg, window.Pending, func(ctx context.Context) {
+			f.releaseWindowClaim(ctx, windowKey, window.EndTime)
+		})
+		return resultMsg, nil
+	}
+	f.ackLogs(ctx, window.Pending...)
+	return resultMsg, nil
+}
+
+// closeDueWindows closes and scores the windows whose end the clock passed
+// by window_grace, so that the windows of sources that went quiet are
+// emitted without waiting for their next log.
+func (f *FirewallAnomalyDetector) closeDueWindows(ctx context.Context) ([]*service.Message, error) {
+	if f.holdWindows {
+		return nil, nil
+	}
+
+	now := f.now()
+	f.rehydrateDue(ctx, now)
+	due := make(map[string]*WindowData)
+	f.windowsMutex.Lock()
+	for key, window := range f.windows {
+		if !now.Before(window.EndTime.Add(f.windowGrace)) {
+			due[key] = window
+			f.closeWindowLocked(key, window)
+		}
+	}
+	empty := f.emptyWindowsLocked(now)
+	f.windowsMutex.Unlock()
+
+	keys := make([]string, 0, len(due))
+	for key := range due {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var results []*service.Message
+	for _, key := range keys {
+		f.replication.markDirty(key)
+		msg, err := f.emitClosedWindow(ctx, key, due[key])
+		if err != nil {
+			return results, err
+		}
+		if msg != nil {
+			results = append(results, msg)
+		}
+	}
+	for _, e := range empty {
+		msg, err := f.emptyWindowResult(ctx, e)
+		if err != nil {
+			return results, err
+		}
+		if msg != nil {
+			results = append(results, msg)
+		}
+	}
+	return results, nil
+}
+
+// scoreWindow extracts features from a window, scores them and builds the
+// routed result message. It returns nil when another instance has already
+// emitted the window. Final results are produced when windows are flushed on
+// shutdown rather than on completion.
+func (f *FirewallAnomalyDetector) scoreWindow(ctx context.Context, windowKey string, window *WindowData, metricField string, metricValue float64, final bool) (*service.Message, error) {
+	// Only one replica emits the result of a given window
+	claimed, err := f.locker.claimWindow(ctx, windowKey, window.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		f.logger.Debugf("Window %s ending %v was flushed by another instance", windowKey, window.EndTime)
+		return nil, nil
+	}
+
+	source, tenant := windowScope(windowKey, window)
+	labels := f.metricLabels(tenant, source)
+
 	// Extract features
-	features := f.extractFeatures(window)
+	scoringStart := time.Now()
+	vector := f.extractFeatures(window)
+	if vector.sanitised > 0 {
+		f.featuresSanitised.Incr(int64(vector.sanitised), labels...)
+	}
 
 	// Score with ML model
-	anomalyScore := f.scoreAnomaly(features)
+	anomalyScore := f.scoreFeatures(vector)
+	scoringTime := time.Since(scoringStart)
+	f.scoringLatency.Timing(scoringTime.Nanoseconds(), labels...)
+	if f.onScored != nil {
+		f.onScored(scoringTime)
+	}
 
 	// Determine if anomaly
-	isAnomaly := anomalyScore >= f.scoreThreshold
+	features := vector.asMap()
+	f.histograms.observeWindow(window, features, anomalyScore, labels)
+	f.selfMonitor.observeScore(source, anomalyScore)
+	f.drift.observe(features, anomalyScore)
+	// A fixed threshold is reached, while an adaptive one has to be exceeded
+	// so that sources with steady scores are not flagged on every window
+	threshold := f.thresholdFor(tenant, source)
+	isAnomaly := anomalyScore >= threshold
+	if adaptiveThreshold, ok := f.adaptive.threshold(windowKey, labels); ok {
+		threshold = adaptiveThreshold
+		isAnomaly = anomalyScore > adaptiveThreshold
+	}
+	f.adaptive.observe(windowKey, anomalyScore)
+	f.baselines.observe(windowKey, source, tenant, window, features, anomalyScore, threshold)
 
 	// Create result message
+	resultKey := windowID(windowKey, window.StartTime, f.configEpoch)
 	result := map[string]interface{}{
-		"timestamp":     window.EndTime,
-		"log_source":    log.LogSource,
-		"window_start":  window.StartTime,
-		"window_end":    window.EndTime,
-		"anomaly_score": anomalyScore,
-		"is_anomaly":    isAnomaly,
-		"reason":        "hike_rate_detected",
-		"features":      features,
-		"metric_field":  metricField,
-		"metric_value":  metricValue,
-	}
-
-	// Set topic based on anomaly status
-	topic := f.normalTopic
+		"schema_version": resultSchemaVersion,
+		"window_id":      resultKey,
+		"timestamp":      window.EndTime,
+		"log_source":     source,
+		"window_start":   window.StartTime,
+		"window_end":     window.EndTime,
+		"anomaly_score":  anomalyScore,
+		"is_anomaly":     isAnomaly,
+		"reason":         "hike_rate_detected",
+		"features":       features,
+		"metric_field":   metricField,
+		"metric_value":   metricValue,
+		"top_ips":        topIPs(window.IPCounts, topIPsLimit),
+	}
+	if tenant != "" {
+		result["tenant"] = tenant
+	}
+	if final {
+		result["final"] = true
+	}
+	if len(window.Enrichment) > 0 {
+		result["enrichment"] = window.Enrichment
+	}
+	if len(window.Severities) > 0 {
+		result["max_severity"] = maxSeverity(window)
+		result["severity_counts"] = window.Severities
+	}
+
+	// Anomalies inside a maintenance window are kept but not escalated
+	suppressed, suppressedBy := false, ""
 	if isAnomaly {
-		topic = f.anomalyTopic
-		f.anomaliesDetected.Incr(1)
+		if name := f.activeSuppression(source, window.EndTime); name != "" {
+			suppressed, suppressedBy = true, name
+			result["suppressed"] = true
+			result["suppressed_by"] = name
+			f.anomaliesSuppressed.Incr(1, labels...)
+		}
+	}
+
+	// Set topic based on anomaly status. Dry runs meter and log anomalies
+	// but keep them on the normal topic.
+	anomalyTopic, topic := f.topics()
+	alerted := isAnomaly && !suppressed && !f.dryRun
+	if isAnomaly && !suppressed {
+		f.anomaliesDetected.Incr(1, labels...)
+	}
+	if alerted {
+		// Only alerts that were sent count against the alert budget
+		f.thresholdControl.observeAlert(f.sourceKey(source), resultKey, f.now())
+		topic = anomalyTopic
+	} else if isAnomaly && !suppressed {
+		result["dry_run"] = true
+		f.logger.Infof("Dry run: anomaly in window %s scored %.3f, threshold %.3f", windowKey, anomalyScore, threshold)
 	}
 
 	// Create message
 	resultMsg := service.NewMessage(nil)
 	resultMsg.SetStructured(result)
 	resultMsg.MetaSet("topic", topic)
+	resultMsg.MetaSet("idempotency_key", resultKey)
+	if tenant != "" {
+		resultMsg.MetaSet("tenant", tenant)
+	}
 
-	// Clear the window after processing
-	f.clearWindow(windowKey)
+	if alerted {
+		f.alerts.Dispatch(ctx, resultMsg)
+		f.stix.observeAlert(resultKey, source, tenant, anomalyScore, window, f.now())
+		f.responder.observe(resultKey, source, anomalyScore, resultTopIPs(result))
+	}
 
-	return resultMsg, nil
+	f.debugSampler.offer(resultMsg, resultKey, threshold, len(window.Values))
+
+	decision := auditRecord{
+		AuditedAt:      time.Now(),
+		WindowKey:      windowKey,
+		Source:         source,
+		Tenant:         tenant,
+		WindowStart:    window.StartTime,
+		WindowEnd:      window.EndTime,
+		Samples:        len(window.Values),
+		Features:       features,
+		AnomalyScore:   anomalyScore,
+		Threshold:      threshold,
+		IsAnomaly:      isAnomaly,
+		Suppressed:     suppressed,
+		SuppressedBy:   suppressedBy,
+		Alerted:        alerted,
+		Topic:          topic,
+		Final:          final,
+		IdempotencyKey: resultKey,
+	}
+	if f.onDecided != nil {
+		f.onDecided(decision)
+	}
+	f.ui.observe(decision)
+	if err := f.audit.record(ctx, decision); err != nil {
+		f.logger.Errorf("Failed to audit window %s: %v", windowKey, err)
+	}
+	row := featureRow{
+		windowID:     resultKey,
+		source:       source,
+		tenant:       tenant,
+		windowStart:  window.StartTime,
+		windowEnd:    window.EndTime,
+		samples:      len(window.Values),
+		features:     features,
+		anomalyScore: anomalyScore,
+		threshold:    threshold,
+		isAnomaly:    isAnomaly,
+	}
+	sourceKey := f.sourceKey(source)
+	if err := f.features.export(sourceKey, row, f.now()); err != nil {
+		f.logger.Errorf("Failed to export features of window %s: %v", windowKey, err)
+	}
+	f.featureStore.add(sourceKey, row)
+	f.feast.observe(windowKey, window.EndTime, features, anomalyScore)
+
+	// Lag between the window closing in event time and its emission
+	f.emissionLag.Timing(emissionLag(window, time.Now()).Nanoseconds(), labels...)
+	f.health.recordEmission()
+	f.status.recordEmission(f.sourceKey(source), isAnomaly, f.now())
+
+	return f.outputMessage(resultMsg, result, resultKey, window, threshold), nil
+}
+
+// emissionLag returns how far behind event time a window is emitted, i.e.
+// the time elapsed since the end of the window.
+func emissionLag(window *WindowData, now time.Time) time.Duration {
+	if lag := now.Sub(window.EndTime); lag > 0 {
+		return lag
+	}
+	return 0
+}
+
+// loadModel (re)loads the ML model, one instance at a time when distributed
+// locks are enabled.
+func (f *FirewallAnomalyDetector) loadModel(ctx context.Context) error {
+	err := f.locker.withModelLock(ctx, f.modelPath, func() error {
+		// Load ML model (placeholder - would integrate with actual ML library)
+		f.logger.Infof("Loading ML model from: %s", f.modelPath)
+		return nil
+	})
+	version := modelVersion(f.modelPath)
+	f.health.recordModel(version, err)
+	f.status.recordModel(version, f.now(), err)
+	return err
 }
 
 func (f *FirewallAnomalyDetector) updateWindow(windowKey string, value float64, sourceIP string, timestamp time.Time) {
+	f.updateScopedWindow(windowKey, "", windowKey, value, sourceIP, timestamp)
+}
+
+// windowUpdate is the contribution of one log to its window.
+type windowUpdate struct {
+	tenant     string
+	source     string
+	value      float64
+	secondary  map[string]float64
+	sourceIP   string
+	timestamp  time.Time
+	enrichment map[string]interface{}
+	severity   string
+	pending    string
+}
+
+// observeWindow adds a log to the window its event time falls in, holding
+// the windows lock once for the whole update. A log past the end of the open
+// window of its key closes that window, which is returned to be scored, and
+// opens the next one. Logs of windows that are already closed are late and
+// not added. Windows are written by every log, so a plain mutex suits them
+// better than a sync.Map, which favours keys that are written once and read
+// many times.
+func (f *FirewallAnomalyDetector) observeWindow(windowKey string, u windowUpdate) (closed *WindowData, late bool) {
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	if !f.holdWindows {
+		if u.timestamp.Before(f.closed[windowKey].end) {
+			return nil, true
+		}
+		if window, exists := f.windows[windowKey]; exists {
+			if u.timestamp.Before(window.StartTime) {
+				return nil, true
+			}
+			if !u.timestamp.Before(window.EndTime) {
+				closed = window
+				f.closeWindowLocked(windowKey, window)
+			}
+		}
+	}
+
+	window := f.updateScopedWindowLocked(windowKey, u.tenant, u.source, u.value, u.sourceIP, u.timestamp)
+	f.addMetricValuesLocked(window, u.secondary)
+	mergeEnrichment(window, u.enrichment)
+	if u.severity != "" {
+		if window.Severities == nil {
+			window.Severities = make(map[string]int)
+		}
+		window.Severities[u.severity]++
+	}
+	if u.pending != "" {
+		window.Pending = append(window.Pending, u.pending)
+	}
+	return closed, false
+}
+
+// closedWindow is the window last closed for a window key.
+type closedWindow struct {
+	source string
+	tenant string
+	// end is the end of the window, or of the last empty window emitted
+	// after it
+	end time.Time
+	// lastLog is the end of the last window that received logs
+	lastLog time.Time
+}
+
+// closeWindowLocked removes a window that is about to be scored and records
+// its end, before which logs of its key are late from then on.
+func (f *FirewallAnomalyDetector) closeWindowLocked(windowKey string, window *WindowData) {
+	delete(f.windows, windowKey)
+	if f.closed == nil {
+		f.closed = make(map[string]closedWindow)
+	}
+	source, tenant := windowScope(windowKey, window)
+	f.closed[windowKey] = closedWindow{source: source, tenant: tenant, end: window.EndTime, lastLog: window.EndTime}
+}
+
+// windowBounds returns the window of the given length a timestamp falls in.
+// Windows are aligned to multiples of their length since the Unix epoch, so
+// that their boundaries never depend on the first log they receive.
+func windowBounds(timestamp time.Time, length time.Duration) (start, end time.Time) {
+	offset := time.Duration(timestamp.UnixNano() % int64(length))
+	if offset < 0 {
+		offset += length
+	}
+	start = timestamp.Add(-offset)
+	return start, start.Add(length)
+}
+
+// updateScopedWindow adds a value to the window of a tenant's log source.
+func (f *FirewallAnomalyDetector) updateScopedWindow(windowKey, tenant, source string, value float64, sourceIP string, timestamp time.Time) {
 	f.windowsMutex.Lock()
 	defer f.windowsMutex.Unlock()
+	f.updateScopedWindowLocked(windowKey, tenant, source, value, sourceIP, timestamp)
+}
 
+func (f *FirewallAnomalyDetector) updateScopedWindowLocked(windowKey, tenant, source string, value float64, sourceIP string, timestamp time.Time) *WindowData {
 	window, exists := f.windows[windowKey]
 	if !exists {
+		length := f.windowLength(source)
+		start, end := windowBounds(timestamp, length)
 		window = &WindowData{
-			Values:    []float64{},
+			Source:    source,
+			Tenant:    tenant,
+			Values:    f.buffers.newBuffer(length),
 			IPs:       make(map[string]bool),
-			StartTime: timestamp,
-			EndTime:   timestamp.Add(time.Duration(f.windowSeconds) * time.Second),
+			IPCounts:  make(map[string]int),
+			StartTime: start,
+			EndTime:   end,
 		}
 		f.windows[windowKey] = window
-		f.windowsCreated.Incr(1)
+		f.windowsCreated.Incr(1, f.metricLabels(tenant, source)...)
 	}
 
 	// Add value to window
-	window.Values = append(window.Values, value)
-	window.IPs[sourceIP] = true
+	var overwritten bool
+	window.Values, window.ValuesHead, overwritten = f.buffers.push(window.Values, window.ValuesHead, value)
+	if overwritten {
+		f.samplesOverwritten.Incr(1, f.metricLabels(tenant, source)...)
+	}
+	if f.ipSets.record(window, sourceIP) {
+		f.ipSetsApproximated.Incr(1, f.metricLabels(tenant, source)...)
+	}
+	window.UpdatedAt = time.Now()
+	return window
+}
+
+// windowLength returns the duration of the windows of a log source.
+func (f *FirewallAnomalyDetector) windowLength(source string) time.Duration {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	if length, exists := f.sourceWindows[f.sourceKeyLocked(source)]; exists {
+		return length
+	}
+	return time.Duration(f.windowSeconds) * time.Second
+}
+
+// trackPending records a consumed log against its window until the window is
+// emitted.
+func (f *FirewallAnomalyDetector) trackPending(windowKey, raw string) {
+	if raw == "" {
+		return
+	}
+
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+	if window, exists := f.windows[windowKey]; exists {
+		window.Pending = append(window.Pending, raw)
+	}
+}
 
-	// Update end time
-	if timestamp.After(window.EndTime) {
-		window.EndTime = timestamp.Add(time.Duration(f.windowSeconds) * time.Second)
+// windowScope returns the log source and tenant of a window. Windows restored
+// from state written before they were recorded are keyed by source.
+func windowScope(windowKey string, window *WindowData) (source, tenant string) {
+	if window.Source == "" {
+		return windowKey, ""
 	}
+	return window.Source, window.Tenant
 }
 
 func (f *FirewallAnomalyDetector) getWindow(windowKey string) *WindowData {
@@ -391,27 +1801,27 @@ func (f *FirewallAnomalyDetector) clearWindow(windowKey string) {
 	delete(f.windows, windowKey)
 }
 
-func (f *FirewallAnomalyDetector) extractFeatures(window *WindowData) map[string]float64 {
-	if len(window.Values) == 0 {
-		return map[string]float64{
-			"mean_value":         0.0,
-			"std_dev":            0.0,
-			"max_value":          0.0,
-			"min_value":          0.0,
-			"percent_change":     0.0,
-			"unique_ips":         0.0,
-			"peak_to_mean_ratio": 0.0,
-		}
+// metricFeatures computes the statistical features of the values of one
+// metric.
+func metricFeatures(values []float64, lastMean float64, ips int) metricStats {
+	var stats metricStats
+	if len(values) == 0 {
+		return stats
 	}
 
-	// Calculate basic statistics
-	mean := stat.Mean(window.Values, nil)
-	stdDev := stat.StdDev(window.Values, nil)
-
-	// Find max and min
-	max := window.Values[0]
-	min := window.Values[0]
-	for _, v := range window.Values {
+	// Calculate basic statistics and find max and min in a single pass. The
+	// variance is accumulated over values shifted by the first one, which
+	// keeps it accurate for large values close to each other, such as byte
+	// counts.
+	shift := values[0]
+	max := values[0]
+	min := values[0]
+	var sum, shiftedSum, shiftedSquares float64
+	for _, v := range values {
+		sum += v
+		d := v - shift
+		shiftedSum += d
+		shiftedSquares += d * d
 		if v > max {
 			max = v
 		}
@@ -419,15 +1829,18 @@ func (f *FirewallAnomalyDetector) extractFeatures(window *WindowData) map[string
 			min = v
 		}
 	}
+	n := float64(len(values))
+	mean := sum / n
+	stdDev := math.Sqrt((shiftedSquares - shiftedSum*shiftedSum/n) / (n - 1))
 
 	// Calculate percent change from previous window
 	percentChange := 0.0
-	if window.LastMean > 0 {
-		percentChange = ((mean - window.LastMean) / window.LastMean) * 100
+	if lastMean > 0 {
+		percentChange = ((mean - lastMean) / lastMean) * 100
 	}
 
 	// Count unique IPs
-	uniqueIPs := float64(len(window.IPs))
+	uniqueIPs := float64(ips)
 
 	// Calculate peak to mean ratio
 	peakToMeanRatio := 0.0
@@ -435,18 +1848,44 @@ func (f *FirewallAnomalyDetector) extractFeatures(window *WindowData) map[string
 		peakToMeanRatio = max / mean
 	}
 
-	return map[string]float64{
-		"mean_value":         mean,
-		"std_dev":            stdDev,
-		"max_value":          max,
-		"min_value":          min,
-		"percent_change":     percentChange,
-		"unique_ips":         uniqueIPs,
-		"peak_to_mean_ratio": peakToMeanRatio,
+	stats[featureMeanValue] = mean
+	stats[featureStdDev] = stdDev
+	stats[featureMaxValue] = max
+	stats[featureMinValue] = min
+	stats[featurePercentChange] = percentChange
+	stats[featureUniqueIPs] = uniqueIPs
+	stats[featurePeakToMeanRatio] = peakToMeanRatio
+	return stats
+}
+
+// IPCount is the number of logs seen from a single source IP within a window.
+type IPCount struct {
+	IP    string `json:"ip"`
+	Count int    `json:"count"`
+}
+
+const topIPsLimit = 5
+
+// topIPs returns the n most frequent source IPs of a window, most frequent
+// first with ties broken by address.
+func topIPs(counts map[string]int, n int) []IPCount {
+	ips := make([]IPCount, 0, len(counts))
+	for ip, count := range counts {
+		ips = append(ips, IPCount{IP: ip, Count: count})
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		if ips[i].Count != ips[j].Count {
+			return ips[i].Count > ips[j].Count
+		}
+		return ips[i].IP < ips[j].IP
+	})
+	if len(ips) > n {
+		ips = ips[:n]
 	}
+	return ips
 }
 
-func (f *FirewallAnomalyDetector) scoreAnomaly(features map[string]float64) float64 {
+func (f *FirewallAnomalyDetector) scoreAnomaly(features metricStats) float64 {
 	// This is a placeholder implementation
 	// In a real implementation, you would load and use the actual ML model
 
@@ -454,22 +1893,22 @@ func (f *FirewallAnomalyDetector) scoreAnomaly(features map[string]float64) floa
 	score := 0.0
 
 	// Higher score for high percent change
-	if math.Abs(features["percent_change"]) > 50 {
+	if math.Abs(features[featurePercentChange]) > 50 {
 		score += 0.3
 	}
 
 	// Higher score for high peak-to-mean ratio
-	if features["peak_to_mean_ratio"] > 3 {
+	if features[featurePeakToMeanRatio] > 3 {
 		score += 0.2
 	}
 
 	// Higher score for high standard deviation
-	if features["std_dev"] > features["mean_value"] {
+	if features[featureStdDev] > features[featureMeanValue] {
 		score += 0.2
 	}
 
 	// Higher score for many unique IPs
-	if features["unique_ips"] > 100 {
+	if features[featureUniqueIPs] > 100 {
 		score += 0.3
 	}
 
@@ -477,6 +1916,41 @@ func (f *FirewallAnomalyDetector) scoreAnomaly(features map[string]float64) floa
 }
 
 func (f *FirewallAnomalyDetector) Close(ctx context.Context) error {
+	f.emitter.stop()
+	f.stopStateGC()
+	if err := f.shutdownWindows(ctx); err != nil {
+		f.logger.Errorf("Failed to %s windows on shutdown: %v", f.shutdown.mode, err)
+	}
+	f.stopSnapshots()
+	f.stopReplication(ctx)
+	f.stopControl()
+	f.baselines.unpublish()
+
+	_ = f.alerts.Close(ctx)
+	f.responder.Close(ctx)
+	if err := f.audit.Close(); err != nil {
+		f.logger.Errorf("Failed to close audit trail: %v", err)
+	}
+	if err := f.feedback.Close(); err != nil {
+		f.logger.Errorf("Failed to close feedback file: %v", err)
+	}
+	if err := f.thresholdControl.Close(); err != nil {
+		f.logger.Errorf("Failed to close threshold adjustment audit file: %v", err)
+	}
+	if err := f.features.Close(); err != nil {
+		f.logger.Errorf("Failed to close feature export files: %v", err)
+	}
+	f.featureStore.flush(ctx, f.now(), true)
+	f.feast.Close(ctx)
+
+	// Leaving replicas hand their windows over to the remaining ones
+	if f.replication.isActive() {
+		if err := f.handoffWindows(ctx, true); err != nil {
+			f.logger.Errorf("Failed to hand over windows on shutdown: %v", err)
+		}
+	}
+	f.partitioner.close(ctx)
+
 	if f.redisClient != nil {
 		return f.redisClient.Close()
 	}
@@ -1,7 +1,9 @@
 package processor
 
 import (
+	"context"
 	"encoding/json"
+	"math"
 	"testing"
 	"time"
 
@@ -42,6 +44,20 @@ func TestFirewallAnomalyDetectorConfig(t *testing.T) {
 	assert.NotNil(t, config)
 }
 
+func TestDefaultSourcesConfig(t *testing.T) {
+	// The default sources lack the defaults of their fields
+	conf, err := detectorConfigSpec().ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	detector, err := newFirewallAnomalyDetector(conf, service.MockResources())
+	require.NoError(t, err)
+	defer detector.Close(context.Background())
+
+	metric, exists := detector.metricFieldFor("paloalto.firewall")
+	assert.True(t, exists)
+	assert.Equal(t, "bytes_sent", metric)
+}
+
 func TestFirewallLogParsing(t *testing.T) {
 	logJSON := `{
 		"timestamp": "2024-01-15T10:30:00Z",
@@ -83,7 +99,7 @@ func TestFeatureExtraction(t *testing.T) {
 	}
 
 	detector := &FirewallAnomalyDetector{}
-	features := detector.extractFeatures(window)
+	features := detector.extractFeatures(window).asMap()
 
 	assert.Equal(t, 30.0, features["mean_value"])
 	assert.Equal(t, 15.811388300841896, features["std_dev"])
@@ -103,7 +119,7 @@ func TestFeatureExtractionEmptyWindow(t *testing.T) {
 	}
 
 	detector := &FirewallAnomalyDetector{}
-	features := detector.extractFeatures(window)
+	features := detector.extractFeatures(window).asMap()
 
 	assert.Equal(t, 0.0, features["mean_value"])
 	assert.Equal(t, 0.0, features["std_dev"])
@@ -114,29 +130,46 @@ func TestFeatureExtractionEmptyWindow(t *testing.T) {
 	assert.Equal(t, 0.0, features["peak_to_mean_ratio"])
 }
 
+func TestFeatureExtractionSanitisesNonFiniteFeatures(t *testing.T) {
+	detector := &FirewallAnomalyDetector{}
+
+	// The standard deviation of a single sample is undefined
+	vector := detector.extractFeatures(&WindowData{Values: []float64{10}, IPs: map[string]bool{}})
+	assert.Equal(t, 1, vector.sanitised)
+	assert.Equal(t, 0.0, vector.asMap()["std_dev"])
+
+	// A percent change from a vanishing previous mean overflows
+	vector = detector.extractFeatures(&WindowData{Values: []float64{1e300, 1e300}, LastMean: 1e-300, IPs: map[string]bool{}})
+	assert.Equal(t, 1, vector.sanitised)
+	assert.Equal(t, math.MaxFloat64, vector.asMap()["percent_change"])
+
+	vector = detector.extractFeatures(&WindowData{Values: []float64{1, 2}, IPs: map[string]bool{}})
+	assert.Zero(t, vector.sanitised)
+}
+
 func TestAnomalyScoring(t *testing.T) {
 	detector := &FirewallAnomalyDetector{
 		scoreThreshold: 0.7,
 	}
 
 	// Test normal features
-	normalFeatures := map[string]float64{
-		"percent_change":     10.0,
-		"peak_to_mean_ratio": 1.5,
-		"std_dev":            5.0,
-		"mean_value":         10.0,
-		"unique_ips":         50.0,
+	normalFeatures := metricStats{
+		featurePercentChange:   10.0,
+		featurePeakToMeanRatio: 1.5,
+		featureStdDev:          5.0,
+		featureMeanValue:       10.0,
+		featureUniqueIPs:       50.0,
 	}
 	score := detector.scoreAnomaly(normalFeatures)
 	assert.True(t, score < 0.7, "Normal features should score below threshold")
 
 	// Test anomalous features
-	anomalousFeatures := map[string]float64{
-		"percent_change":     75.0, // > 50
-		"peak_to_mean_ratio": 4.0,  // > 3
-		"std_dev":            15.0, // > mean_value
-		"mean_value":         10.0,
-		"unique_ips":         150.0, // > 100
+	anomalousFeatures := metricStats{
+		featurePercentChange:   75.0, // > 50
+		featurePeakToMeanRatio: 4.0,  // > 3
+		featureStdDev:          15.0, // > mean_value
+		featureMeanValue:       10.0,
+		featureUniqueIPs:       150.0, // > 100
 	}
 	score = detector.scoreAnomaly(anomalousFeatures)
 	assert.True(t, score >= 0.7, "Anomalous features should score above threshold")
@@ -176,6 +209,136 @@ func TestWindowManagement(t *testing.T) {
 	assert.Nil(t, window)
 }
 
+func TestObserveWindow(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		sources:       map[string]string{"fortinet.firewall": "bytes_sent"},
+		sourceMetrics: map[string][]sourceMetric{
+			"fortinet.firewall": {
+				{field: "bytes_sent", prefix: "bytes_sent"},
+				{field: "connection_count", prefix: "conn_count"},
+			},
+		},
+		windows: make(map[string]*WindowData),
+		clock:   func() time.Time { return now },
+	}
+
+	closed, late := detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:     "fortinet.firewall",
+		value:      100,
+		secondary:  map[string]float64{"conn_count": 2},
+		sourceIP:   "192.168.1.1",
+		timestamp:  start.Add(10 * time.Second),
+		enrichment: map[string]interface{}{"site": "hq"},
+		pending:    `{"log_source":"fortinet.firewall"}`,
+	})
+	assert.Nil(t, closed)
+	assert.False(t, late)
+	window := detector.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Equal(t, start, window.StartTime)
+	assert.Equal(t, start.Add(time.Minute), window.EndTime)
+	assert.Equal(t, []float64{100}, window.Values)
+	assert.Equal(t, []float64{2}, window.Metrics["conn_count"])
+	assert.Equal(t, map[string]interface{}{"site": "hq"}, window.Enrichment)
+	assert.Equal(t, []string{`{"log_source":"fortinet.firewall"}`}, window.Pending)
+
+	// Logs within the window never move its boundaries, however late the
+	// clock is
+	now = start.Add(2 * time.Minute)
+	closed, late = detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:    "fortinet.firewall",
+		value:     200,
+		sourceIP:  "192.168.1.2",
+		timestamp: start.Add(59 * time.Second),
+	})
+	assert.Nil(t, closed)
+	assert.False(t, late)
+	assert.Equal(t, start.Add(time.Minute), window.EndTime)
+
+	// A log past the end closes the window and opens the next one
+	closed, late = detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:    "fortinet.firewall",
+		value:     300,
+		sourceIP:  "192.168.1.3",
+		timestamp: start.Add(time.Minute),
+	})
+	assert.False(t, late)
+	require.Same(t, window, closed)
+	assert.Equal(t, []float64{100, 200}, closed.Values)
+	next := detector.getWindow("fortinet.firewall")
+	require.NotNil(t, next)
+	assert.Equal(t, start.Add(time.Minute), next.StartTime)
+	assert.Equal(t, []float64{300}, next.Values)
+
+	// Logs of the closed window are late and not added anywhere
+	closed, late = detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:    "fortinet.firewall",
+		value:     400,
+		sourceIP:  "192.168.1.4",
+		timestamp: start.Add(30 * time.Second),
+	})
+	assert.Nil(t, closed)
+	assert.True(t, late)
+	assert.Equal(t, []float64{300}, next.Values)
+}
+
+func TestWindowBounds(t *testing.T) {
+	ts := time.Date(2024, 1, 15, 10, 3, 25, 0, time.UTC)
+	start, end := windowBounds(ts, time.Minute)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 3, 0, 0, time.UTC), start)
+	assert.Equal(t, time.Date(2024, 1, 15, 10, 4, 0, 0, time.UTC), end)
+
+	// Timestamps on a boundary open the window starting there
+	start, _ = windowBounds(end, time.Minute)
+	assert.Equal(t, end, start)
+
+	// Before the epoch windows are aligned the same way
+	start, end = windowBounds(time.Unix(-90, 0), time.Minute)
+	assert.Equal(t, time.Unix(-120, 0), start)
+	assert.Equal(t, time.Unix(-60, 0), end)
+}
+
+func TestCloseDueWindows(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		windowGrace:    10 * time.Second,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		sources:        map[string]string{"fortinet.firewall": "connection_count"},
+		windows:        make(map[string]*WindowData),
+		clock:          func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	for i, value := range []float64{100, 120, 110} {
+		detector.updateWindow("fortinet.firewall", value, "192.168.1.1", start.Add(time.Duration(i)*time.Second))
+	}
+
+	// The window waits out the grace period after its end
+	now = start.Add(65 * time.Second)
+	results, err := detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	now = start.Add(70 * time.Second)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Nil(t, detector.getWindow("fortinet.firewall"))
+
+	// The window is emitted once
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
 func TestMetricExtraction(t *testing.T) {
 	log := FirewallLog{
 		LogSource:       "fortinet.firewall",
@@ -218,3 +381,141 @@ func extractMetricValue(log FirewallLog, metricField string) float64 {
 		return 0.0
 	}
 }
+
+func TestTopIPs(t *testing.T) {
+	counts := map[string]int{
+		"192.168.1.1": 3,
+		"192.168.1.2": 7,
+		"192.168.1.3": 3,
+		"192.168.1.4": 1,
+	}
+
+	assert.Equal(t, []IPCount{
+		{IP: "192.168.1.2", Count: 7},
+		{IP: "192.168.1.1", Count: 3},
+		{IP: "192.168.1.3", Count: 3},
+	}, topIPs(counts, 3))
+}
+
+func TestEmissionLag(t *testing.T) {
+	end := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+	window := &WindowData{EndTime: end}
+
+	assert.Equal(t, 90*time.Second, emissionLag(window, end.Add(90*time.Second)))
+	assert.Equal(t, time.Duration(0), emissionLag(window, end.Add(-time.Second)))
+}
+
+func TestIsLate(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	detector := &FirewallAnomalyDetector{}
+	assert.False(t, detector.isLate(now.Add(-24*time.Hour), now))
+
+	detector.maxLateness = 5 * time.Minute
+	assert.False(t, detector.isLate(now.Add(-time.Minute), now))
+	assert.False(t, detector.isLate(now.Add(time.Minute), now))
+	assert.True(t, detector.isLate(now.Add(-6*time.Minute), now))
+}
+
+func TestSourceThresholds(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		scoreThreshold:   0.7,
+		sourceThresholds: map[string]float64{"paloalto.firewall": 0.4},
+	}
+	assert.Equal(t, 0.4, detector.thresholdFor("", "paloalto.firewall"))
+	assert.Equal(t, 0.7, detector.thresholdFor("", "fortinet.firewall"))
+
+	// Tenant overrides are more specific than source overrides
+	detector.tenants = &tenantConfig{thresholds: map[string]float64{"acme": 0.9}}
+	assert.Equal(t, 0.9, detector.thresholdFor("acme", "paloalto.firewall"))
+	assert.Equal(t, 0.4, detector.thresholdFor("globex", "paloalto.firewall"))
+}
+
+func TestSourceWindowLengths(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 300,
+		windows:       make(map[string]*WindowData),
+		sourceWindows: map[string]time.Duration{"dns.firewall": 10 * time.Second},
+	}
+	assert.Equal(t, 10*time.Second, detector.windowLength("dns.firewall"))
+	assert.Equal(t, 5*time.Minute, detector.windowLength("fortinet.firewall"))
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("dns.firewall", 1, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 1, "192.168.1.1", now)
+	assert.Equal(t, now.Add(10*time.Second), detector.getWindow("dns.firewall").EndTime)
+	assert.Equal(t, now.Add(5*time.Minute), detector.getWindow("fortinet.firewall").EndTime)
+}
+
+func TestDryRun(t *testing.T) {
+	sink := &recordingSink{}
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		scoreThreshold: 0,
+		dryRun:         true,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		windows:        make(map[string]*WindowData),
+		alerts:         newTestDispatcher(sink),
+	}
+	detector.thresholdControl = testThresholdController(0, 10)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+
+	msg, err := detector.scoreWindow(context.Background(), "fortinet.firewall", detector.getWindow("fortinet.firewall"), "connection_count", 10, false)
+	require.NoError(t, err)
+
+	// Detected, but neither routed to the anomaly topic nor alerted
+	result, err := alertResult(msg)
+	require.NoError(t, err)
+	assert.Equal(t, true, result["is_anomaly"])
+	assert.Equal(t, true, result["dry_run"])
+	topic, _ := msg.MetaGet("topic")
+	assert.Equal(t, "firewall-normal", topic)
+	assert.Empty(t, sink.results)
+	assert.Empty(t, detector.thresholdControl.sources, "dry runs do not use up the alert budget")
+
+	event, err := alertResult(detector.detectorEvent(context.Background(), map[string]interface{}{"reason": reasonModelDrift}, true))
+	require.NoError(t, err)
+	assert.Equal(t, true, event["dry_run"])
+	assert.Empty(t, sink.results)
+
+	detector.dryRun = false
+	detector.updateWindow("paloalto.firewall", 10, "192.168.1.1", now)
+	msg, err = detector.scoreWindow(context.Background(), "paloalto.firewall", detector.getWindow("paloalto.firewall"), "connection_count", 10, false)
+	require.NoError(t, err)
+	topic, _ = msg.MetaGet("topic")
+	assert.Equal(t, "firewall-anomalies", topic)
+	assert.Len(t, sink.results, 1)
+	assert.Len(t, detector.thresholdControl.sources["paloalto.firewall"].alerts, 1)
+}
+
+func TestAdaptiveThresholdFlatline(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		scoreThreshold: 2,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		windows:        make(map[string]*WindowData),
+		adaptive:       newTestAdaptiveThresholds(t, `adaptive_threshold: { percentile: 99, history: 10, min_samples: 1 }`),
+	}
+
+	// A source whose score never changes sits at its adaptive threshold,
+	// which it has to exceed to be flagged
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	var scores []interface{}
+	for i := 0; i < 5; i++ {
+		detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+		msg, err := detector.scoreWindow(context.Background(), "fortinet.firewall", detector.getWindow("fortinet.firewall"), "connection_count", 10, false)
+		require.NoError(t, err)
+		result, err := alertResult(msg)
+		require.NoError(t, err)
+		assert.Equal(t, false, result["is_anomaly"], "window %d", i)
+		scores = append(scores, result["anomaly_score"])
+		detector.windows = make(map[string]*WindowData)
+	}
+	assert.Equal(t, scores[0], scores[len(scores)-1])
+}
@@ -0,0 +1,261 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// goldenTolerance is the absolute difference tolerated between expected and
+// actual floats of a golden fixture, so that fixtures survive harmless
+// changes in floating point evaluation order.
+const goldenTolerance = 1e-9
+
+// GoldenFixture is a recorded batch of logs, the detector config it was run
+// with and the detections it produced, replayed by regression tests to catch
+// changes in parsing, windowing, feature extraction or scoring.
+type GoldenFixture struct {
+	Description string `json:"description,omitempty"`
+	// Config is a pipeline config with a firewall_anomaly_detector
+	// processor. It must not depend on environment variables without
+	// defaults, so that the fixture runs anywhere.
+	Config string `json:"config"`
+	// Logs are the redacted raw logs, in the order they were read.
+	Logs     []string      `json:"logs"`
+	Expected ExplainReport `json:"expected"`
+}
+
+// noEnv resolves no environment variables, keeping fixtures self-contained.
+func noEnv(string) (string, bool) { return "", false }
+
+// RecordGolden reads a log file like Explain, redacts its logs and records
+// the detections of the config over them as a golden fixture. Every IP
+// address is replaced with a stable pseudonym, and the values of the
+// redactFields, dot separated paths such as `raw.user`, with stable tokens,
+// so that fixtures built from production logs can be committed. Logs that
+// are not JSON are kept as they are.
+func RecordGolden(ctx context.Context, env *service.Environment, confYAML []byte, logs io.Reader, redactFields []string) (GoldenFixture, error) {
+	fixture := GoldenFixture{Config: string(confYAML)}
+
+	items, err := readLogFile(logs)
+	if err != nil {
+		return fixture, err
+	}
+	for _, item := range items {
+		if item == "" {
+			continue
+		}
+		fixture.Logs = append(fixture.Logs, redactLog(item, redactFields))
+	}
+
+	fixture.Expected, err = explainLogs(ctx, env, confYAML, noEnv, fixture.Logs)
+	return fixture, err
+}
+
+// Rerecord replaces the expected detections of a fixture with those of the
+// current build, for accepting an intended behaviour change.
+func (g *GoldenFixture) Rerecord(ctx context.Context, env *service.Environment) error {
+	report, err := explainLogs(ctx, env, []byte(g.Config), noEnv, g.Logs)
+	if err != nil {
+		return err
+	}
+	g.Expected = report
+	return nil
+}
+
+// Check replays the logs of a fixture and describes every difference from
+// its expected detections, returning none when behaviour is unchanged.
+func (g GoldenFixture) Check(ctx context.Context, env *service.Environment) ([]string, error) {
+	actual, err := explainLogs(ctx, env, []byte(g.Config), noEnv, g.Logs)
+	if err != nil {
+		return nil, err
+	}
+	return diffExplainReports(g.Expected, actual), nil
+}
+
+// ReadGoldenFixture reads a fixture written by WriteGoldenFixture.
+func ReadGoldenFixture(path string) (GoldenFixture, error) {
+	var fixture GoldenFixture
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, err
+	}
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		return fixture, fmt.Errorf("%s: %w", path, err)
+	}
+	return fixture, nil
+}
+
+// WriteGoldenFixture writes a fixture as indented JSON, so that changes to
+// it read well in a diff.
+func WriteGoldenFixture(path string, fixture GoldenFixture) error {
+	b, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
+// redactLog pseudonymises the IP addresses and the redactFields of a JSON
+// log.
+func redactLog(item string, redactFields []string) string {
+	var doc interface{}
+	dec := json.NewDecoder(strings.NewReader(item))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return item
+	}
+
+	for _, field := range redactFields {
+		redactPath(doc, strings.Split(field, "."))
+	}
+	doc = redactIPs(doc)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return item
+	}
+	return string(b)
+}
+
+func redactPath(doc interface{}, path []string) {
+	obj, ok := doc.(map[string]interface{})
+	if !ok {
+		return
+	}
+	v, exists := obj[path[0]]
+	if !exists {
+		return
+	}
+	if len(path) > 1 {
+		redactPath(v, path[1:])
+		return
+	}
+	raw, _ := json.Marshal(v)
+	sum := sha256.Sum256(raw)
+	obj[path[0]] = "redacted-" + hex.EncodeToString(sum[:4])
+}
+
+// redactIPs replaces every string that is an IP address with a pseudonym in
+// 10.0.0.0/8, or in fd00::/8 for IPv6, derived from the address so that the
+// same address always gets the same pseudonym.
+func redactIPs(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = redactIPs(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = redactIPs(child)
+		}
+	case string:
+		addr, err := netip.ParseAddr(v)
+		if err != nil || addr.Zone() != "" {
+			return v
+		}
+		ip := addr.As16()
+		sum := sha256.Sum256(ip[:])
+		if addr.Unmap().Is4() {
+			return netip.AddrFrom4([4]byte{10, sum[0], sum[1], sum[2]}).String()
+		}
+		var pseudo [16]byte
+		pseudo[0] = 0xfd
+		copy(pseudo[1:], sum[:len(pseudo)-1])
+		return netip.AddrFrom16(pseudo).String()
+	}
+	return v
+}
+
+// diffExplainReports describes the differences between two explanations of
+// the same logs.
+func diffExplainReports(want, got ExplainReport) []string {
+	var diffs []string
+	if want.Logs != got.Logs {
+		diffs = append(diffs, fmt.Sprintf("logs: want %d, got %d", want.Logs, got.Logs))
+	}
+
+	if len(want.Dropped) != len(got.Dropped) {
+		diffs = append(diffs, fmt.Sprintf("dropped logs: want %d, got %d", len(want.Dropped), len(got.Dropped)))
+	}
+	for i := 0; i < len(want.Dropped) && i < len(got.Dropped); i++ {
+		if want.Dropped[i] != got.Dropped[i] {
+			diffs = append(diffs, fmt.Sprintf("dropped log %d: want %+v, got %+v", i, want.Dropped[i], got.Dropped[i]))
+		}
+	}
+
+	if len(want.Windows) != len(got.Windows) {
+		diffs = append(diffs, fmt.Sprintf("windows: want %d, got %d", len(want.Windows), len(got.Windows)))
+	}
+	for i := 0; i < len(want.Windows) && i < len(got.Windows); i++ {
+		w, g := want.Windows[i], got.Windows[i]
+		name := fmt.Sprintf("window %d (%s %s)", i, w.Source, w.WindowStart.Format("2006-01-02T15:04:05Z07:00"))
+		check := func(field string, want, got interface{}) {
+			if want != got {
+				diffs = append(diffs, fmt.Sprintf("%s %s: want %v, got %v", name, field, want, got))
+			}
+		}
+		check("log_source", w.Source, g.Source)
+		check("tenant", w.Tenant, g.Tenant)
+		check("window_start", w.WindowStart.UTC(), g.WindowStart.UTC())
+		check("window_end", w.WindowEnd.UTC(), g.WindowEnd.UTC())
+		check("samples", w.Samples, g.Samples)
+		check("is_anomaly", w.IsAnomaly, g.IsAnomaly)
+		check("suppressed_by", w.SuppressedBy, g.SuppressedBy)
+		check("final", w.Final, g.Final)
+		if !floatsMatch(w.AnomalyScore, g.AnomalyScore) {
+			diffs = append(diffs, fmt.Sprintf("%s anomaly_score: want %v, got %v", name, w.AnomalyScore, g.AnomalyScore))
+		}
+		if !floatsMatch(w.Threshold, g.Threshold) {
+			diffs = append(diffs, fmt.Sprintf("%s threshold: want %v, got %v", name, w.Threshold, g.Threshold))
+		}
+
+		features := make(map[string]bool, len(w.Features)+len(g.Features))
+		for feature := range w.Features {
+			features[feature] = true
+		}
+		for feature := range g.Features {
+			features[feature] = true
+		}
+		names := make([]string, 0, len(features))
+		for feature := range features {
+			names = append(names, feature)
+		}
+		sort.Strings(names)
+		for _, feature := range names {
+			wv, wok := w.Features[feature]
+			gv, gok := g.Features[feature]
+			switch {
+			case !wok:
+				diffs = append(diffs, fmt.Sprintf("%s feature %s: unexpected, got %v", name, feature, gv))
+			case !gok:
+				diffs = append(diffs, fmt.Sprintf("%s feature %s: want %v, missing", name, feature, wv))
+			case !floatsMatch(wv, gv):
+				diffs = append(diffs, fmt.Sprintf("%s feature %s: want %v, got %v", name, feature, wv, gv))
+			}
+		}
+	}
+	return diffs
+}
+
+// floatsMatch compares floats within goldenTolerance, treating NaNs as
+// equal.
+func floatsMatch(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	return math.Abs(a-b) <= goldenTolerance
+}
@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"context"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var updateGolden = flag.Bool("update-golden", false, "Rerecord the expected detections of the golden fixtures")
+
+// TestGoldenFixtures replays every fixture in testdata/golden and fails when
+// the detections differ from those recorded. After an intended behaviour
+// change, rerecord them with `go test ./processor -run TestGoldenFixtures
+// -update-golden` and review the diff.
+func TestGoldenFixtures(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".json"), func(t *testing.T) {
+			fixture, err := ReadGoldenFixture(path)
+			require.NoError(t, err)
+
+			if *updateGolden {
+				require.NoError(t, fixture.Rerecord(context.Background(), service.NewEnvironment()))
+				require.NoError(t, WriteGoldenFixture(path, fixture))
+				return
+			}
+
+			diffs, err := fixture.Check(context.Background(), service.NewEnvironment())
+			require.NoError(t, err)
+			for _, diff := range diffs {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestRecordGolden(t *testing.T) {
+	logs := explainTestLogs()
+	logs[0] = `{"timestamp":"2024-01-15T10:00:00Z","log_source":"fortinet.firewall","source_ip":"203.0.113.7","dest_ip":"2001:db8::1","connection_count":10,"raw":{"user":"alice"}}`
+
+	fixture, err := RecordGolden(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), strings.NewReader(strings.Join(logs, "\n")), []string{"raw.user"})
+	require.NoError(t, err)
+
+	require.Len(t, fixture.Logs, len(logs))
+	assert.NotContains(t, fixture.Logs[0], "203.0.113.7")
+	assert.NotContains(t, fixture.Logs[0], "2001:db8::1")
+	assert.NotContains(t, fixture.Logs[0], "alice")
+	assert.Contains(t, fixture.Logs[0], `"source_ip":"10.`)
+	assert.Contains(t, fixture.Logs[0], `"dest_ip":"fd`)
+	assert.Contains(t, fixture.Logs[0], `"user":"redacted-`)
+	assert.Equal(t, "not json", fixture.Logs[10])
+	require.Len(t, fixture.Expected.Windows, 1)
+
+	// The same address always gets the same pseudonym
+	assert.Equal(t, redactLog(`{"ip":"203.0.113.7"}`, nil), redactLog(`{"ip":"203.0.113.7"}`, nil))
+	assert.NotEqual(t, redactLog(`{"ip":"203.0.113.7"}`, nil), redactLog(`{"ip":"203.0.113.8"}`, nil))
+
+	// A round trip through a file keeps the fixture passing
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	require.NoError(t, WriteGoldenFixture(path, fixture))
+	read, err := ReadGoldenFixture(path)
+	require.NoError(t, err)
+	diffs, err := read.Check(context.Background(), service.NewEnvironment())
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	// A change in scoring is caught
+	read.Expected.Windows[0].AnomalyScore += 0.1
+	read.Expected.Windows[0].Features["mean_value"]++
+	read.Expected.Dropped = read.Expected.Dropped[:1]
+	diffs, err = read.Check(context.Background(), service.NewEnvironment())
+	require.NoError(t, err)
+	assert.Len(t, diffs, 3)
+}
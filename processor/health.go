@@ -0,0 +1,233 @@
+package processor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func healthField() *service.ConfigField {
+	return service.NewObjectField("health",
+		service.NewBoolField("enabled").
+			Description("Serve liveness and readiness probes on the Redpanda Connect HTTP server").
+			Default(false),
+		service.NewStringField("liveness_path").
+			Description("Path of the liveness probe, failing while the model is not loaded or the detector is wedged").
+			Default("/firewall_anomaly_detector/live"),
+		service.NewStringField("readiness_path").
+			Description("Path of the readiness probe, additionally failing while Redis is unreachable").
+			Default("/firewall_anomaly_detector/ready"),
+		service.NewDurationField("max_emission_age").
+			Description("The detector is considered wedged when logs keep being consumed but no window has been emitted for this long. Should comfortably exceed `window_seconds`. Zero disables the check.").
+			Default("0s"),
+		service.NewDurationField("redis_timeout").
+			Description("Timeout of the Redis ping of the readiness probe").
+			Default("1s"),
+	).
+		Description("Health probes covering Redis connectivity, model state and emission progress, e.g. for Kubernetes").
+		Advanced()
+}
+
+// healthChecks tracks the state reported by the health probes.
+type healthChecks struct {
+	client       *redis.Client
+	maxAge       time.Duration
+	redisTimeout time.Duration
+
+	now func() time.Time
+
+	mut           sync.Mutex
+	startedAt     time.Time
+	lastRead      time.Time
+	lastEmission  time.Time
+	modelLoaded   bool
+	modelVersion  string
+	modelLoadedAt time.Time
+	modelErr      error
+}
+
+func newHealthChecksFromConfig(conf *service.ParsedConfig, client *redis.Client) (*healthChecks, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	h := &healthChecks{
+		client:    client,
+		now:       time.Now,
+		startedAt: time.Now(),
+	}
+	if h.maxAge, err = conf.FieldDuration("max_emission_age"); err != nil {
+		return nil, err
+	}
+	if h.redisTimeout, err = conf.FieldDuration("redis_timeout"); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// registerHealthEndpoints serves the liveness and readiness probes.
+func (f *FirewallAnomalyDetector) registerHealthEndpoints(conf *service.ParsedConfig) error {
+	if f.health == nil {
+		return nil
+	}
+
+	livenessPath, err := conf.FieldString("liveness_path")
+	if err != nil {
+		return err
+	}
+	readinessPath, err := conf.FieldString("readiness_path")
+	if err != nil {
+		return err
+	}
+
+	if err := registerEndpoint(f.resources, livenessPath, "Liveness of the firewall anomaly detector.", f.health.handle(false)); err != nil {
+		return err
+	}
+	return registerEndpoint(f.resources, readinessPath, "Readiness of the firewall anomaly detector.", f.health.handle(true))
+}
+
+func (h *healthChecks) recordRead() {
+	if h == nil {
+		return
+	}
+	h.mut.Lock()
+	h.lastRead = h.now()
+	h.mut.Unlock()
+}
+
+func (h *healthChecks) recordEmission() {
+	if h == nil {
+		return
+	}
+	h.mut.Lock()
+	h.lastEmission = h.now()
+	h.mut.Unlock()
+}
+
+func (h *healthChecks) recordModel(version string, err error) {
+	if h == nil {
+		return
+	}
+	h.mut.Lock()
+	defer h.mut.Unlock()
+
+	h.modelErr = err
+	if err == nil {
+		h.modelLoaded = true
+		h.modelVersion = version
+		h.modelLoadedAt = h.now()
+	}
+}
+
+// healthReport is the body served by both probes.
+type healthReport struct {
+	Status       string      `json:"status"`
+	Redis        redisHealth `json:"redis"`
+	Model        modelHealth `json:"model"`
+	LastRead     *time.Time  `json:"last_read,omitempty"`
+	LastEmission *time.Time  `json:"last_emission,omitempty"`
+	Wedged       bool        `json:"wedged"`
+}
+
+type redisHealth struct {
+	Checked   bool   `json:"checked"`
+	Connected bool   `json:"connected"`
+	Error     string `json:"error,omitempty"`
+}
+
+type modelHealth struct {
+	Loaded   bool       `json:"loaded"`
+	Version  string     `json:"version,omitempty"`
+	LoadedAt *time.Time `json:"loaded_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// wedged reports whether logs have been consumed without any window being
+// emitted for longer than the maximum emission age.
+func (h *healthChecks) wedged() bool {
+	if h.maxAge <= 0 || !h.lastRead.After(h.lastEmission) {
+		return false
+	}
+	since := h.lastEmission
+	if since.IsZero() {
+		since = h.startedAt
+	}
+	return h.now().Sub(since) > h.maxAge
+}
+
+// report evaluates the probes, pinging Redis for readiness.
+func (h *healthChecks) report(ctx context.Context, readiness bool) (healthReport, bool) {
+	h.mut.Lock()
+	r := healthReport{
+		Model: modelHealth{
+			Loaded:   h.modelLoaded,
+			Version:  h.modelVersion,
+			LoadedAt: optionalTime(h.modelLoadedAt),
+		},
+		LastRead:     optionalTime(h.lastRead),
+		LastEmission: optionalTime(h.lastEmission),
+		Wedged:       h.wedged(),
+	}
+	if h.modelErr != nil {
+		r.Model.Error = h.modelErr.Error()
+	}
+	h.mut.Unlock()
+
+	healthy := r.Model.Loaded && !r.Wedged
+	if readiness {
+		r.Redis.Checked = true
+		pingCtx, done := context.WithTimeout(ctx, h.redisTimeout)
+		err := h.client.Ping(pingCtx).Err()
+		done()
+		if err != nil {
+			r.Redis.Error = err.Error()
+		}
+		r.Redis.Connected = err == nil
+		healthy = healthy && r.Redis.Connected
+	}
+
+	r.Status = "ok"
+	if !healthy {
+		r.Status = "failing"
+	}
+	return r, healthy
+}
+
+func (h *healthChecks) handle(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, healthy := h.report(r.Context(), readiness)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// modelVersion identifies a model file by a digest of its contents, returning
+// an empty version when the file can not be read.
+func modelVersion(path string) string {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:6])
+}
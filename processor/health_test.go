@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthWedged(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	h := &healthChecks{
+		maxAge:    5 * time.Minute,
+		now:       func() time.Time { return now },
+		startedAt: start,
+	}
+	h.recordModel("abc", nil)
+
+	// Idle detectors are not wedged
+	now = start.Add(time.Hour)
+	_, healthy := h.report(context.Background(), false)
+	assert.True(t, healthy)
+
+	h.recordRead()
+	report, healthy := h.report(context.Background(), false)
+	assert.False(t, healthy)
+	assert.True(t, report.Wedged)
+	assert.Equal(t, "failing", report.Status)
+
+	h.recordEmission()
+	now = now.Add(time.Minute)
+	h.recordRead()
+	report, healthy = h.report(context.Background(), false)
+	assert.True(t, healthy)
+	assert.Equal(t, "abc", report.Model.Version)
+	assert.False(t, report.Redis.Checked)
+}
+
+func TestHealthModelAndRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	h := &healthChecks{
+		client:       client,
+		redisTimeout: time.Second,
+		now:          time.Now,
+		startedAt:    time.Now(),
+	}
+	h.recordModel("", errors.New("corrupt model"))
+
+	rec := httptest.NewRecorder()
+	h.handle(false)(rec, httptest.NewRequest("GET", "/live", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "corrupt model")
+
+	h.recordModel("", nil)
+	rec = httptest.NewRecorder()
+	h.handle(false)(rec, httptest.NewRequest("GET", "/live", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	report, healthy := h.report(context.Background(), true)
+	assert.False(t, healthy)
+	assert.True(t, report.Redis.Checked)
+	assert.False(t, report.Redis.Connected)
+	assert.NotEmpty(t, report.Redis.Error)
+}
+
+func TestModelVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "model.pkl")
+	assert.Equal(t, "", modelVersion(path))
+
+	require.NoError(t, os.WriteFile(path, []byte("model"), 0o644))
+	assert.Len(t, modelVersion(path), 12)
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const reasonEmptyWindow = "empty_window"
+
+func emptyWindowsField() *service.ConfigField {
+	return service.NewObjectField("empty_windows",
+		service.NewBoolField("emit").
+			Description("Emit a zero-valued result for every window in which a log source received no logs, once the clock is `window_grace` past its end").
+			Default(false),
+	).
+		Description("Heartbeat results for windows without logs, so that downstream dashboards tell a quiet firewall from a broken pipeline. Only sources that produced logs since they were last reclaimed by `state_gc` are covered, and nothing is emitted while Redis cannot be read.").
+		Advanced()
+}
+
+// emptyWindow is a window of a window key that received no logs.
+type emptyWindow struct {
+	key    string
+	source string
+	tenant string
+	start  time.Time
+	end    time.Time
+}
+
+// emptyWindowsLocked returns the windows that passed without logs by now,
+// sorted by window key and end, and records them as closed. Windows before
+// the open window of a key are closed right away, as their logs are late
+// already. The caller holds windowsMutex.
+func (f *FirewallAnomalyDetector) emptyWindowsLocked(now time.Time) []emptyWindow {
+	if !f.emitEmptyWindows {
+		return nil
+	}
+
+	keys := make([]string, 0, len(f.closed))
+	for key := range f.closed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var empty []emptyWindow
+	for _, key := range keys {
+		closed := f.closed[key]
+		length := f.windowLength(closed.source)
+		due := now.Add(-f.windowGrace)
+		if window, open := f.windows[key]; open {
+			due = window.StartTime
+		}
+		for !due.Before(closed.end.Add(length)) {
+			empty = append(empty, emptyWindow{
+				key:    key,
+				source: closed.source,
+				tenant: closed.tenant,
+				start:  closed.end,
+				end:    closed.end.Add(length),
+			})
+			closed.end = closed.end.Add(length)
+		}
+		f.closed[key] = closed
+	}
+	return empty
+}
+
+// emptyWindowResult builds the zero-valued result of an empty window, routed
+// to the normal topic. Empty windows are neither scored nor alerted, and
+// only one replica emits each of them.
+func (f *FirewallAnomalyDetector) emptyWindowResult(ctx context.Context, e emptyWindow) (*service.Message, error) {
+	claimed, err := f.locker.claimWindow(ctx, e.key, e.end)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, nil
+	}
+
+	window := &WindowData{
+		Source:    e.source,
+		Tenant:    e.tenant,
+		IPs:       map[string]bool{},
+		IPCounts:  map[string]int{},
+		StartTime: e.start,
+		EndTime:   e.end,
+	}
+	metricField, _ := f.metricFieldFor(e.source)
+	key := windowID(e.key, e.start, f.configEpoch)
+	result := map[string]interface{}{
+		"schema_version": resultSchemaVersion,
+		"window_id":      key,
+		"timestamp":      e.end,
+		"log_source":     e.source,
+		"window_start":   e.start,
+		"window_end":     e.end,
+		"anomaly_score":  0.0,
+		"is_anomaly":     false,
+		"reason":         reasonEmptyWindow,
+		"empty":          true,
+		"features":       f.extractFeatures(window).asMap(),
+		"metric_field":   metricField,
+		"metric_value":   0.0,
+		"top_ips":        topIPs(window.IPCounts, topIPsLimit),
+	}
+	if e.tenant != "" {
+		result["tenant"] = e.tenant
+	}
+
+	_, normalTopic := f.topics()
+	msg := service.NewMessage(nil)
+	msg.SetStructured(result)
+	msg.MetaSet("topic", normalTopic)
+	msg.MetaSet("reason", reasonEmptyWindow)
+	msg.MetaSet("idempotency_key", key)
+	if e.tenant != "" {
+		msg.MetaSet("tenant", e.tenant)
+	}
+
+	f.emptyWindowsEmitted.Incr(1, f.metricLabels(e.tenant, e.source)...)
+	return f.outputMessage(msg, result, key, window, f.thresholdFor(e.tenant, e.source)), nil
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmptyWindowResults(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	mgr := service.MockResources()
+	detector := &FirewallAnomalyDetector{
+		logger:              mgr.Logger(),
+		windowSeconds:       60,
+		windowGrace:         10 * time.Second,
+		emitEmptyWindows:    true,
+		scoreThreshold:      0.7,
+		anomalyTopic:        "firewall-anomalies",
+		normalTopic:         "firewall-normal",
+		sources:             map[string]string{"fortinet.firewall": "connection_count"},
+		windows:             make(map[string]*WindowData),
+		clock:               func() time.Time { return now },
+		emptyWindowsEmitted: mgr.Metrics().NewCounter("empty_windows_emitted"),
+	}
+	ctx := context.Background()
+
+	// Sources that never logged are not covered
+	now = start.Add(5 * time.Minute)
+	results, err := detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	detector.updateWindow("fortinet.firewall", 100, "192.168.1.1", start.Add(5*time.Minute))
+	now = start.Add(6*time.Minute + 10*time.Second)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.NotEqual(t, true, resultField(t, results[0], "empty"))
+
+	// Two windows pass without logs, the second one within its grace period
+	now = start.Add(8*time.Minute + 5*time.Second)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	result, err := results[0].AsStructured()
+	require.NoError(t, err)
+	heartbeat := result.(map[string]interface{})
+	assert.Equal(t, true, heartbeat["empty"])
+	assert.Equal(t, reasonEmptyWindow, heartbeat["reason"])
+	assert.Equal(t, false, heartbeat["is_anomaly"])
+	assert.Equal(t, 0.0, heartbeat["metric_value"])
+	assert.Equal(t, start.Add(6*time.Minute), heartbeat["window_start"])
+	assert.Equal(t, start.Add(7*time.Minute), heartbeat["window_end"])
+	assert.Equal(t, 0.0, heartbeat["features"].(map[string]float64)["mean_value"])
+	topic, _ := results[0].MetaGet("topic")
+	assert.Equal(t, "firewall-normal", topic)
+
+	// Logs of an emitted empty window are late
+	_, late := detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:    "fortinet.firewall",
+		value:     1,
+		timestamp: start.Add(6*time.Minute + 30*time.Second),
+	})
+	assert.True(t, late)
+
+	// A log of the next window closes the empty window before it without
+	// waiting for its grace period
+	_, late = detector.observeWindow("fortinet.firewall", windowUpdate{
+		source:    "fortinet.firewall",
+		value:     1,
+		timestamp: start.Add(8*time.Minute + time.Second),
+	})
+	assert.False(t, late)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, start.Add(8*time.Minute), resultField(t, results[0], "window_end"))
+
+	now = start.Add(8*time.Minute + 30*time.Second)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestEmptyWindowsDisabled(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		closed: map[string]closedWindow{"fortinet.firewall": {source: "fortinet.firewall"}},
+	}
+	assert.Empty(t, detector.emptyWindowsLocked(time.Now()))
+}
+
+func resultField(t *testing.T, msg *service.Message, name string) interface{} {
+	t.Helper()
+	result, err := msg.AsStructured()
+	require.NoError(t, err)
+	return result.(map[string]interface{})[name]
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func histogramsField() *service.ConfigField {
+	return service.NewObjectField("histograms",
+		service.NewFloatListField("score_buckets").
+			Description("Upper bounds of the `anomaly_score` histogram buckets").
+			Default([]interface{}{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1.0}),
+		service.NewFloatListField("sample_buckets").
+			Description("Upper bounds of the `window_samples` histogram buckets").
+			Default([]interface{}{1.0, 5.0, 10.0, 50.0, 100.0, 500.0, 1000.0, 5000.0, 10000.0}),
+		service.NewFloatListField("feature_buckets").
+			Description("Upper bounds of the `feature_value` histogram buckets, shared by all features").
+			Default([]interface{}{0.1, 1.0, 10.0, 100.0, 1000.0, 10000.0, 100000.0, 1000000.0}),
+	).
+		Description("Bucket boundaries of the score, window size and feature value histograms").
+		Advanced()
+}
+
+// bucketHistogram exposes a Prometheus style histogram through Benthos
+// counters: a cumulative `<name>_bucket` counter labelled by upper bound
+// `le`, and a `<name>_count` counter, so that `histogram_quantile` works on
+// the exported series.
+type bucketHistogram struct {
+	bounds  []float64
+	labels  []string
+	buckets *service.MetricCounter
+	count   *service.MetricCounter
+}
+
+func newBucketHistogram(metrics *service.Metrics, name string, bounds []float64, labelKeys ...string) (*bucketHistogram, error) {
+	if len(bounds) == 0 {
+		return nil, errors.New("histogram " + name + " needs at least one bucket")
+	}
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+
+	labels := make([]string, len(sorted))
+	for i, b := range sorted {
+		labels[i] = strconv.FormatFloat(b, 'g', -1, 64)
+	}
+
+	return &bucketHistogram{
+		bounds:  sorted,
+		labels:  labels,
+		buckets: metrics.NewCounter(name+"_bucket", append(append([]string(nil), labelKeys...), "le")...),
+		count:   metrics.NewCounter(name+"_count", labelKeys...),
+	}, nil
+}
+
+// bucketsFor returns the `le` labels of every bucket an observation falls
+// in, including the implicit `+Inf` bucket.
+func (h *bucketHistogram) bucketsFor(v float64) []string {
+	i := sort.SearchFloat64s(h.bounds, v)
+	return append(append([]string(nil), h.labels[i:]...), "+Inf")
+}
+
+// Observe records a value with the given label values.
+func (h *bucketHistogram) Observe(v float64, labelValues ...string) {
+	if h == nil {
+		return
+	}
+	for _, le := range h.bucketsFor(v) {
+		h.buckets.Incr(1, append(append([]string(nil), labelValues...), le)...)
+	}
+	h.count.Incr(1, labelValues...)
+}
+
+// detectorHistograms are the distributions recorded for every scored window.
+type detectorHistograms struct {
+	score   *bucketHistogram
+	samples *bucketHistogram
+	feature *bucketHistogram
+}
+
+func newDetectorHistogramsFromConfig(conf *service.ParsedConfig, metrics *service.Metrics, labelKeys []string) (*detectorHistograms, error) {
+	scoreBuckets, err := conf.FieldFloatList("score_buckets")
+	if err != nil {
+		return nil, err
+	}
+	sampleBuckets, err := conf.FieldFloatList("sample_buckets")
+	if err != nil {
+		return nil, err
+	}
+	featureBuckets, err := conf.FieldFloatList("feature_buckets")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &detectorHistograms{}
+	if h.score, err = newBucketHistogram(metrics, "anomaly_score", scoreBuckets, labelKeys...); err != nil {
+		return nil, err
+	}
+	if h.samples, err = newBucketHistogram(metrics, "window_samples", sampleBuckets, labelKeys...); err != nil {
+		return nil, err
+	}
+	featureLabels := append(append([]string(nil), labelKeys...), "feature")
+	if h.feature, err = newBucketHistogram(metrics, "feature_value", featureBuckets, featureLabels...); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// observeWindow records the distributions of a scored window.
+func (h *detectorHistograms) observeWindow(window *WindowData, features map[string]float64, score float64, labelValues []string) {
+	if h == nil {
+		return
+	}
+	h.score.Observe(score, labelValues...)
+	h.samples.Observe(float64(len(window.Values)), labelValues...)
+	for name, value := range features {
+		h.feature.Observe(value, append(append([]string(nil), labelValues...), name)...)
+	}
+}
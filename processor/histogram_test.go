@@ -0,0 +1,36 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketHistogramBuckets(t *testing.T) {
+	h, err := newBucketHistogram(service.MockResources().Metrics(), "anomaly_score", []float64{0.5, 0.1, 1})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"0.1", "0.5", "1", "+Inf"}, h.bucketsFor(0.05))
+	assert.Equal(t, []string{"0.5", "1", "+Inf"}, h.bucketsFor(0.5))
+	assert.Equal(t, []string{"1", "+Inf"}, h.bucketsFor(0.7))
+	assert.Equal(t, []string{"+Inf"}, h.bucketsFor(3))
+
+	// Observing never panics, with or without labels.
+	h.Observe(0.7)
+	var disabled *bucketHistogram
+	disabled.Observe(0.7)
+}
+
+func TestHistogramsRequireBuckets(t *testing.T) {
+	spec := service.NewConfigSpec().Field(histogramsField())
+	conf, err := spec.ParseYAML(`
+histograms:
+  score_buckets: []
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newDetectorHistogramsFromConfig(conf.Namespace("histograms"), service.MockResources().Metrics(), nil)
+	assert.Error(t, err)
+}
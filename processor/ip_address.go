@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func sourceIPv6PrefixField() *service.ConfigField {
+	return service.NewIntField("ipv6_prefix").
+		Description("Prefix length IPv6 source IPs of this source are aggregated to, e.g. `64` to count every /64 as a single IP, keeping the cardinality of hosts with privacy addresses in check. IPv4 source IPs are never aggregated.").
+		Optional()
+}
+
+// parseSourceIPv6Prefix returns the IPv6 aggregation prefix of a source,
+// zero when its IPs are not aggregated.
+func parseSourceIPv6Prefix(source string, conf *service.ParsedConfig) (int, error) {
+	if !conf.Contains("ipv6_prefix") {
+		return 0, nil
+	}
+	prefix, err := conf.FieldInt("ipv6_prefix")
+	if err != nil {
+		return 0, err
+	}
+	if prefix < 1 || prefix > 128 {
+		return 0, fmt.Errorf("ipv6_prefix of source %s must be between 1 and 128, got %d", source, prefix)
+	}
+	if prefix == 128 {
+		return 0, nil
+	}
+	return prefix, nil
+}
+
+// normaliseIP returns the canonical form of an IP address, so that textual
+// variants of an address count as one IP: IPv6 addresses are compressed and
+// lower cased, IPv4-mapped IPv6 addresses are unmapped and zones dropped.
+// IPv6 addresses are replaced by their prefix in CIDR notation when
+// ipv6Prefix is set. Values that are not IP addresses are returned as they
+// are.
+func normaliseIP(ip string, ipv6Prefix int) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+	if addr.Is6() && ipv6Prefix > 0 {
+		prefix, err := addr.Prefix(ipv6Prefix)
+		if err == nil {
+			return prefix.String()
+		}
+	}
+	return addr.String()
+}
+
+// normaliseIPs puts the IPs of a log in their canonical form before they are
+// windowed or looked up, aggregating the source IPs of sources with an
+// ipv6_prefix.
+func (f *FirewallAnomalyDetector) normaliseIPs(log *FirewallLog) {
+	log.SourceIP = normaliseIP(log.SourceIP, f.ipv6Prefixes[f.sourceKey(log.LogSource)])
+	log.DestIP = normaliseIP(log.DestIP, 0)
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormaliseIP(t *testing.T) {
+	for _, tc := range []struct {
+		ip     string
+		prefix int
+		want   string
+	}{
+		{"10.0.0.1", 0, "10.0.0.1"},
+		{"10.0.0.1", 64, "10.0.0.1"},
+		{"::ffff:10.0.0.1", 0, "10.0.0.1"},
+		{"2001:DB8:0:0::1", 0, "2001:db8::1"},
+		{"2001:0db8:0000:0000:0000:0000:0000:0001", 0, "2001:db8::1"},
+		{"fe80::1%eth0", 0, "fe80::1"},
+		{" 2001:db8::1 ", 0, "2001:db8::1"},
+		{"2001:db8:1:2:aaaa:bbbb:cccc:dddd", 64, "2001:db8:1:2::/64"},
+		{"2001:db8:1:2:aaaa:bbbb:cccc:dddd", 48, "2001:db8:1::/48"},
+		{unknownSourceIP, 64, unknownSourceIP},
+		{"", 0, ""},
+	} {
+		assert.Equal(t, tc.want, normaliseIP(tc.ip, tc.prefix), tc.ip)
+	}
+}
+
+func TestParseSourceIPv6Prefix(t *testing.T) {
+	spec := service.NewConfigSpec().Field(sourceIPv6PrefixField())
+	parse := func(yaml string) (int, error) {
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return parseSourceIPv6Prefix("fortinet.firewall", conf)
+	}
+
+	prefix, err := parse(`{}`)
+	require.NoError(t, err)
+	assert.Zero(t, prefix)
+
+	prefix, err = parse(`ipv6_prefix: 64`)
+	require.NoError(t, err)
+	assert.Equal(t, 64, prefix)
+
+	prefix, err = parse(`ipv6_prefix: 128`)
+	require.NoError(t, err)
+	assert.Zero(t, prefix)
+
+	_, err = parse(`ipv6_prefix: 129`)
+	assert.ErrorContains(t, err, "ipv6_prefix of source fortinet.firewall must be between 1 and 128")
+}
+
+func TestUniqueIPsOfIPv6Variants(t *testing.T) {
+	mgr := service.MockResources()
+	f := &FirewallAnomalyDetector{
+		logger:        mgr.Logger(),
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		sources: map[string]string{
+			"fortinet.firewall": "connection_count",
+			"paloalto.firewall": "connection_count",
+		},
+		sourceMatches: &sync.Map{},
+		ipv6Prefixes:  map[string]int{"paloalto.firewall": 64},
+	}
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	process := func(source string, ips ...string) *WindowData {
+		for _, ip := range ips {
+			_, err := f.processLog(ctx, FirewallLog{LogSource: source, SourceIP: ip, ConnectionCount: 1, Timestamp: now})
+			require.NoError(t, err)
+		}
+		window := f.getWindow(source)
+		require.NotNil(t, window)
+		return window
+	}
+
+	// Textual variants of an address are one IP
+	window := process("fortinet.firewall", "2001:db8::1", "2001:DB8:0::1", "2001:0db8:0000:0000:0000:0000:0000:0001", "::ffff:10.0.0.1", "10.0.0.1")
+	assert.Equal(t, 2, window.uniqueIPs())
+	assert.Equal(t, map[string]int{"2001:db8::1": 3, "10.0.0.1": 2}, window.IPCounts)
+
+	// Sources aggregating IPv6 count every /64 once
+	window = process("paloalto.firewall", "2001:db8:1:2::1", "2001:db8:1:2::2", "2001:db8:1:3::1", "10.0.0.1")
+	assert.Equal(t, 3, window.uniqueIPs())
+	assert.Equal(t, 2, window.IPCounts["2001:db8:1:2::/64"])
+}
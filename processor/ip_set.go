@@ -0,0 +1,209 @@
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func ipSetField() *service.ConfigField {
+	return service.NewObjectField("ip_set",
+		service.NewIntField("exact_limit").
+			Description("Distinct source IPs a window tracks exactly. A window seeing more switches to a Bloom filter, counting its unique IPs approximately in fixed memory. Zero always tracks them exactly.").
+			Default(0),
+		service.NewFloatField("false_positive_rate").
+			Description("False positive rate of the Bloom filter at `expected_ips` distinct IPs, i.e. the share of new IPs mistaken for ones already seen and so missing from the unique IP count.").
+			Default(0.01),
+		service.NewIntField("expected_ips").
+			Description("Distinct source IPs the Bloom filter of a window is sized for. Windows seeing more have a higher false positive rate.").
+			Default(1000000),
+	).
+		Description("Approximate tracking of the source IPs of windows with millions of distinct IPs, whose exact sets would be too big").
+		Advanced()
+}
+
+// ipSets decides how the source IPs of windows are tracked. The zero value
+// tracks them exactly.
+type ipSets struct {
+	exactLimit        int
+	falsePositiveRate float64
+	expectedIPs       int
+}
+
+func newIPSetsFromConfig(conf *service.ParsedConfig) (ipSets, error) {
+	var s ipSets
+	var err error
+	if s.exactLimit, err = conf.FieldInt("exact_limit"); err != nil {
+		return s, err
+	}
+	if s.exactLimit < 0 {
+		return s, fmt.Errorf("ip_set.exact_limit must not be negative, got %d", s.exactLimit)
+	}
+	if s.falsePositiveRate, err = conf.FieldFloat("false_positive_rate"); err != nil {
+		return s, err
+	}
+	if s.falsePositiveRate <= 0 || s.falsePositiveRate >= 1 {
+		return s, fmt.Errorf("ip_set.false_positive_rate must be between 0 and 1, got %v", s.falsePositiveRate)
+	}
+	if s.expectedIPs, err = conf.FieldInt("expected_ips"); err != nil {
+		return s, err
+	}
+	if s.expectedIPs <= 0 {
+		return s, fmt.Errorf("ip_set.expected_ips must be positive, got %d", s.expectedIPs)
+	}
+	if s.exactLimit == 0 {
+		return ipSets{}, nil
+	}
+	return s, nil
+}
+
+// record adds a source IP to a window. It reports whether the window
+// switched from its exact set to a Bloom filter.
+func (s ipSets) record(window *WindowData, ip string) bool {
+	if window.IPFilter != nil {
+		window.IPFilter.add(ip)
+		// Only the IPs seen before the switch keep being counted, bounding
+		// the counts to the exact limit
+		if _, exists := window.IPCounts[ip]; exists {
+			window.IPCounts[ip]++
+		}
+		return false
+	}
+
+	if window.IPs == nil {
+		window.IPs = make(map[string]bool)
+	}
+	window.IPs[ip] = true
+	if window.IPCounts == nil {
+		window.IPCounts = make(map[string]int)
+	}
+	window.IPCounts[ip]++
+	if s.exactLimit == 0 || len(window.IPs) <= s.exactLimit {
+		return false
+	}
+
+	window.IPFilter = newIPBloomFilter(s.expectedIPs, s.falsePositiveRate)
+	for ip := range window.IPs {
+		window.IPFilter.add(ip)
+	}
+	window.IPs = nil
+	return true
+}
+
+// uniqueIPs returns the number of distinct source IPs of a window, estimated
+// once it tracks them with a Bloom filter.
+func (w *WindowData) uniqueIPs() int {
+	if w.IPFilter != nil {
+		return w.IPFilter.Count
+	}
+	return len(w.IPs)
+}
+
+// IPBloomFilter is a Bloom filter of source IPs counting the IPs it did not
+// hold yet. Count falls short of the distinct IPs added by the false
+// positive rate.
+type IPBloomFilter struct {
+	Bits   []byte
+	Hashes int
+	Count  int
+}
+
+// newIPBloomFilter returns a filter sized for n items at false positive rate
+// p.
+func newIPBloomFilter(n int, p float64) *IPBloomFilter {
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &IPBloomFilter{Bits: make([]byte, (int(m)+7)/8), Hashes: k}
+}
+
+// positions calls fn with the bit positions of an item, derived by double
+// hashing from the two halves of its FNV-1a hash.
+func (b *IPBloomFilter) positions(ip string, fn func(i int, mask byte)) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(ip))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	m := uint64(len(b.Bits)) * 8
+	for i := 0; i < b.Hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % m
+		fn(int(bit/8), 1<<(bit%8))
+	}
+}
+
+// add adds an IP to the filter and reports whether it was seen for the
+// first time.
+func (b *IPBloomFilter) add(ip string) bool {
+	added := false
+	b.positions(ip, func(i int, mask byte) {
+		if b.Bits[i]&mask == 0 {
+			b.Bits[i] |= mask
+			added = true
+		}
+	})
+	if added {
+		b.Count++
+	}
+	return added
+}
+
+// union adds the IPs of another filter. Filters of the same size are merged
+// bit by bit and their count estimated from the bits set; otherwise their
+// counts are summed, overestimating the IPs they share.
+func (b *IPBloomFilter) union(other *IPBloomFilter) {
+	if len(b.Bits) != len(other.Bits) || b.Hashes != other.Hashes {
+		b.Count += other.Count
+		return
+	}
+
+	set := 0
+	for i, octet := range other.Bits {
+		b.Bits[i] |= octet
+		set += bits.OnesCount8(b.Bits[i])
+	}
+	m := float64(len(b.Bits) * 8)
+	if float64(set) >= m {
+		// A saturated filter has no estimate
+		b.Count += other.Count
+		return
+	}
+	estimate := int(math.Round(-m / float64(b.Hashes) * math.Log(1-float64(set)/m)))
+	b.Count = max(estimate, b.Count, other.Count)
+}
+
+func (b *IPBloomFilter) clone() *IPBloomFilter {
+	c := *b
+	c.Bits = append([]byte(nil), b.Bits...)
+	return &c
+}
+
+// mergeIPs folds the source IPs of src into dst, switching dst to a Bloom
+// filter when src has one.
+func mergeIPs(dst, src *WindowData) {
+	switch {
+	case dst.IPFilter == nil && src.IPFilter == nil:
+		if dst.IPs == nil {
+			dst.IPs = make(map[string]bool, len(src.IPs))
+		}
+		for ip := range src.IPs {
+			dst.IPs[ip] = true
+		}
+	case dst.IPFilter == nil:
+		dst.IPFilter = src.IPFilter.clone()
+		for ip := range dst.IPs {
+			dst.IPFilter.add(ip)
+		}
+		dst.IPs = nil
+	case src.IPFilter == nil:
+		for ip := range src.IPs {
+			dst.IPFilter.add(ip)
+		}
+	default:
+		dst.IPFilter.union(src.IPFilter)
+	}
+}
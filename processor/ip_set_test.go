@@ -0,0 +1,143 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseIPSets(t *testing.T, yaml string) (ipSets, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(ipSetField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newIPSetsFromConfig(conf.Namespace("ip_set"))
+}
+
+func TestIPSetsConfig(t *testing.T) {
+	s, err := parseIPSets(t, `ip_set: {}`)
+	require.NoError(t, err)
+	assert.Equal(t, ipSets{}, s)
+
+	s, err = parseIPSets(t, `ip_set: {exact_limit: 1000, false_positive_rate: 0.001, expected_ips: 5000}`)
+	require.NoError(t, err)
+	assert.Equal(t, ipSets{exactLimit: 1000, falsePositiveRate: 0.001, expectedIPs: 5000}, s)
+
+	_, err = parseIPSets(t, `ip_set: {exact_limit: -1}`)
+	assert.Error(t, err)
+	_, err = parseIPSets(t, `ip_set: {false_positive_rate: 1}`)
+	assert.Error(t, err)
+	_, err = parseIPSets(t, `ip_set: {expected_ips: 0}`)
+	assert.Error(t, err)
+}
+
+func TestIPBloomFilterCount(t *testing.T) {
+	filter := newIPBloomFilter(100000, 0.01)
+	assert.Equal(t, 7, filter.Hashes)
+	assert.InDelta(t, 119814, len(filter.Bits), 1)
+
+	assert.True(t, filter.add("10.0.0.1"))
+	assert.False(t, filter.add("10.0.0.1"))
+
+	for i := 0; i < 100000; i++ {
+		filter.add(fmt.Sprintf("10.%d.%d.%d", i>>16, (i>>8)&0xff, i&0xff))
+	}
+	// New IPs are only missed at about the false positive rate
+	assert.InDelta(t, 100000, filter.Count, 1000)
+}
+
+func TestIPSetsSwitchToFilter(t *testing.T) {
+	s := ipSets{exactLimit: 3, falsePositiveRate: 0.01, expectedIPs: 1000}
+	window := &WindowData{}
+	for i := 1; i <= 3; i++ {
+		assert.False(t, s.record(window, fmt.Sprintf("10.0.0.%d", i)))
+	}
+	assert.Len(t, window.IPs, 3)
+	assert.Nil(t, window.IPFilter)
+
+	assert.True(t, s.record(window, "10.0.0.4"))
+	assert.Nil(t, window.IPs)
+	require.NotNil(t, window.IPFilter)
+	assert.Equal(t, 4, window.uniqueIPs())
+
+	// Counts keep up for the IPs seen before the switch only
+	s.record(window, "10.0.0.1")
+	s.record(window, "10.0.0.5")
+	assert.Equal(t, 5, window.uniqueIPs())
+	assert.Equal(t, 2, window.IPCounts["10.0.0.1"])
+	assert.Len(t, window.IPCounts, 4)
+
+	// Filters survive persisted window state
+	raw, err := encodeWindowState(window)
+	require.NoError(t, err)
+	restored, _, err := decodeWindowState("fortinet.firewall", raw)
+	require.NoError(t, err)
+	assert.Equal(t, window.IPFilter, restored.IPFilter)
+	assert.Equal(t, 5, restored.uniqueIPs())
+}
+
+func TestIPSetsBoundWindowFeatures(t *testing.T) {
+	mgr := service.MockResources()
+	detector := &FirewallAnomalyDetector{
+		logger:             mgr.Logger(),
+		windowSeconds:      60,
+		sources:            map[string]string{"fortinet.firewall": "connection_count"},
+		windows:            make(map[string]*WindowData),
+		ipSets:             ipSets{exactLimit: 10, falsePositiveRate: 0.01, expectedIPs: 1000},
+		windowsCreated:     mgr.Metrics().NewCounter("windows_created"),
+		ipSetsApproximated: mgr.Metrics().NewCounter("window_ip_sets_approximated", "log_source"),
+	}
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		detector.updateWindow("fortinet.firewall", 1, fmt.Sprintf("10.0.%d.%d", i/100, i%100), start.Add(time.Duration(i)*time.Millisecond))
+	}
+	window := detector.getWindow("fortinet.firewall")
+	require.NotNil(t, window.IPFilter)
+	assert.Len(t, window.IPCounts, 11)
+	assert.InDelta(t, 200, detector.extractFeatures(window).asMap()["unique_ips"], 2)
+}
+
+func TestMergeIPs(t *testing.T) {
+	s := ipSets{exactLimit: 2, falsePositiveRate: 0.01, expectedIPs: 1000}
+	filtered := func(ips ...string) *WindowData {
+		window := &WindowData{}
+		for _, ip := range ips {
+			s.record(window, ip)
+		}
+		return window
+	}
+
+	// Exact sets stay exact
+	dst := &WindowData{IPs: map[string]bool{"10.0.0.1": true}}
+	mergeIPs(dst, &WindowData{IPs: map[string]bool{"10.0.0.1": true, "10.0.0.2": true}})
+	assert.Equal(t, 2, dst.uniqueIPs())
+
+	// An exact set joins the filter of the other window
+	dst = &WindowData{IPs: map[string]bool{"10.0.0.1": true, "10.0.0.9": true}}
+	src := filtered("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	mergeIPs(dst, src)
+	assert.Nil(t, dst.IPs)
+	assert.Equal(t, 4, dst.uniqueIPs())
+	assert.NotSame(t, src.IPFilter, dst.IPFilter)
+
+	dst = filtered("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	mergeIPs(dst, &WindowData{IPs: map[string]bool{"10.0.0.3": true, "10.0.0.4": true}})
+	assert.Equal(t, 4, dst.uniqueIPs())
+
+	// Filters of the same size are unioned, others add up their counts
+	dst = filtered("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	mergeIPs(dst, filtered("10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"))
+	assert.Equal(t, 5, dst.uniqueIPs())
+
+	dst = filtered("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	other := &WindowData{IPFilter: newIPBloomFilter(50, 0.01)}
+	other.IPFilter.add("10.0.0.3")
+	mergeIPs(dst, other)
+	assert.Equal(t, 4, dst.uniqueIPs())
+}
@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func distributedLocksField() *service.ConfigField {
+	return service.NewObjectField("distributed_locks",
+		service.NewBoolField("enabled").
+			Description("Whether to take Redis locks around window flushes and model reloads").
+			Default(false),
+		service.NewStringField("key_prefix").
+			Description("Prefix of the Redis keys used as locks").
+			Default("firewall_anomaly_detector:lock:"),
+		service.NewDurationField("window_ttl").
+			Description("How long a flushed window stays claimed. Other instances will not emit a result for the same window during this period, unless the result fails to be written and the claim is released.").
+			Default("10m"),
+		service.NewDurationField("model_ttl").
+			Description("Upper bound on how long a model reload holds its lock").
+			Default("1m"),
+	).
+		Description("Redis based locks so that multi-instance deployments emit a single result per window and reload models one at a time").
+		Advanced()
+}
+
+const lockRetryInterval = 100 * time.Millisecond
+
+// releaseLockScript deletes a lock only if it is still held by the caller's
+// token, so a lock that expired and was re-acquired elsewhere is left alone.
+var releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// redisLocker provides single-holder locks backed by SET NX with a random
+// token per acquisition.
+type redisLocker struct {
+	client    *redis.Client
+	prefix    string
+	windowTTL time.Duration
+	modelTTL  time.Duration
+
+	// token identifies the window claims of this instance, so that it can
+	// release them when their result fails to be emitted
+	token string
+}
+
+func newRedisLockerFromConfig(conf *service.ParsedConfig, client *redis.Client) (*redisLocker, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	l := &redisLocker{client: client}
+	if l.prefix, err = conf.FieldString("key_prefix"); err != nil {
+		return nil, err
+	}
+	if l.windowTTL, err = conf.FieldDuration("window_ttl"); err != nil {
+		return nil, err
+	}
+	if l.modelTTL, err = conf.FieldDuration("model_ttl"); err != nil {
+		return nil, err
+	}
+	if l.token, err = lockToken(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// acquire attempts to take the named lock for ttl. It returns a release
+// function when the lock was obtained and nil when it is held elsewhere.
+func (l *redisLocker) acquire(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, error) {
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	key := l.prefix + name
+	ok, err := l.client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return releaseLockScript.Run(ctx, l.client, []string{key}, token).Err()
+	}, nil
+}
+
+// claimWindow reports whether this instance may emit the result of a window.
+// The claim expires with the window TTL, so replicas that flush the same
+// window later skip it. It is only released early by releaseWindow, when the
+// result fails to be emitted. Without locking every window is claimed.
+func (l *redisLocker) claimWindow(ctx context.Context, windowKey string, windowEnd time.Time) (bool, error) {
+	if l == nil {
+		return true, nil
+	}
+	return l.client.SetNX(ctx, l.prefix+windowLockName(windowKey, windowEnd), l.token, l.windowTTL).Result()
+}
+
+// releaseWindow releases the claim of this instance on a window whose result
+// was not emitted, so that the window is emitted once its logs are
+// re-delivered.
+func (l *redisLocker) releaseWindow(ctx context.Context, windowKey string, windowEnd time.Time) error {
+	if l == nil {
+		return nil
+	}
+	return releaseLockScript.Run(ctx, l.client, []string{l.prefix + windowLockName(windowKey, windowEnd)}, l.token).Err()
+}
+
+// withModelLock runs fn while holding the model reload lock, waiting for
+// other instances to finish their reload first.
+func (l *redisLocker) withModelLock(ctx context.Context, modelPath string, fn func() error) error {
+	if l == nil {
+		return fn()
+	}
+
+	for {
+		release, err := l.acquire(ctx, "model:"+modelPath, l.modelTTL)
+		if err != nil {
+			return err
+		}
+		if release != nil {
+			defer func() {
+				_ = release(context.Background())
+			}()
+			return fn()
+		}
+
+		select {
+		case <-time.After(lockRetryInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func windowLockName(windowKey string, windowEnd time.Time) string {
+	return fmt.Sprintf("window:%s:%d", windowKey, windowEnd.UnixNano())
+}
+
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// releaseWindowClaim releases the claim on a window whose result was not
+// emitted.
+func (f *FirewallAnomalyDetector) releaseWindowClaim(ctx context.Context, windowKey string, windowEnd time.Time) {
+	if err := f.locker.releaseWindow(ctx, windowKey, windowEnd); err != nil {
+		f.logger.Errorf("Failed to release the claim on window %s: %v", windowKey, err)
+	}
+}
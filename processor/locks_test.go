@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDistributedLocksDisabledByDefault(t *testing.T) {
+	spec := service.NewConfigSpec().Field(distributedLocksField())
+	conf, err := spec.ParseYAML(`distributed_locks: {}`, nil)
+	require.NoError(t, err)
+
+	locker, err := newRedisLockerFromConfig(conf.Namespace("distributed_locks"), nil)
+	require.NoError(t, err)
+	assert.Nil(t, locker)
+
+	// Without locking every window is claimed and reloads run directly.
+	claimed, err := locker.claimWindow(context.Background(), "fortinet.firewall", time.Now())
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.NoError(t, locker.releaseWindow(context.Background(), "fortinet.firewall", time.Now()))
+
+	reloaded := false
+	require.NoError(t, locker.withModelLock(context.Background(), "/etc/plugin/model.pkl", func() error {
+		reloaded = true
+		return nil
+	}))
+	assert.True(t, reloaded)
+}
+
+func TestWindowLockNameIsStablePerWindow(t *testing.T) {
+	end := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+	assert.Equal(t, windowLockName("fortinet.firewall", end), windowLockName("fortinet.firewall", end.In(time.Local)))
+	assert.NotEqual(t, windowLockName("fortinet.firewall", end), windowLockName("fortinet.firewall", end.Add(time.Minute)))
+}
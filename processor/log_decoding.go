@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// decodedLog is the shape logs are decoded into. The timestamp is decoded
+// separately so that a timestamp in another format does not fail the whole
+// log, the raw object is kept undecoded until something reads it, and the
+// metrics are decoded through pointers to tell missing ones from zeros.
+type decodedLog struct {
+	logFields
+	Timestamp       json.RawMessage `json:"timestamp"`
+	Raw             json.RawMessage `json:"raw"`
+	ConnectionCount *int            `json:"connection_count"`
+	BytesSent       *int64          `json:"bytes_sent"`
+	BytesRecv       *int64          `json:"bytes_recv"`
+}
+
+type logFields FirewallLog
+
+// logDecoder decodes the logs read from Redis. The generic document of a log
+// is only decoded as a whole when the event time or the tenant are read from
+// fields other than the top-level timestamp, which is otherwise taken from
+// the fields decoded into the log.
+type logDecoder struct {
+	fullDocument bool
+}
+
+func newLogDecoder(eventTime *eventTimeParser, tenants *tenantConfig) logDecoder {
+	topLevelTimestamp := len(eventTime.path) == 1 && eventTime.path[0] == "timestamp"
+	return logDecoder{fullDocument: !topLevelTimestamp || tenants != nil}
+}
+
+// decode decodes a log along with the document its event time and tenant
+// are taken from.
+func (d logDecoder) decode(item string) (FirewallLog, map[string]interface{}, error) {
+	if d.fullDocument {
+		return decodeLog(item)
+	}
+
+	var decoded decodedLog
+	if err := unmarshalLog([]byte(item), &decoded); err != nil {
+		return FirewallLog{}, nil, err
+	}
+	doc := map[string]interface{}{}
+	if len(decoded.Timestamp) > 0 {
+		timestamp, err := decodeDocument(decoded.Timestamp)
+		if err != nil {
+			return FirewallLog{}, nil, err
+		}
+		doc["timestamp"] = timestamp
+	}
+	return decoded.log(), doc, nil
+}
+
+// decodeLog decodes a log read from Redis along with its whole generic
+// document.
+func decodeLog(item string) (FirewallLog, map[string]interface{}, error) {
+	var decoded decodedLog
+	if err := unmarshalLog([]byte(item), &decoded); err != nil {
+		return FirewallLog{}, nil, err
+	}
+
+	doc, err := decodeDocument([]byte(item))
+	if err != nil {
+		return FirewallLog{}, nil, err
+	}
+	obj, _ := doc.(map[string]interface{})
+	return decoded.log(), obj, nil
+}
+
+func (d decodedLog) log() FirewallLog {
+	log := FirewallLog(d.logFields)
+	if len(d.Raw) > 0 && string(d.Raw) != "null" {
+		log.rawJSON = d.Raw
+	}
+	if d.ConnectionCount != nil {
+		log.ConnectionCount = *d.ConnectionCount
+	} else {
+		log.absent |= absentConnectionCount
+	}
+	if d.BytesSent != nil {
+		log.BytesSent = *d.BytesSent
+	} else {
+		log.absent |= absentBytesSent
+	}
+	if d.BytesRecv != nil {
+		log.BytesRecv = *d.BytesRecv
+	} else {
+		log.absent |= absentBytesRecv
+	}
+	return log
+}
+
+// decodeDocument decodes generic JSON, keeping numbers as json.Number so
+// that large integers such as nanosecond timestamps survive.
+func decodeDocument(b []byte) (interface{}, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// decodeRaw decodes the raw object of a log on first use.
+func (l *FirewallLog) decodeRaw() error {
+	if l.Raw != nil || len(l.rawJSON) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(l.rawJSON, &l.Raw); err != nil {
+		return err
+	}
+	l.rawJSON = nil
+	return nil
+}
@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogDecoderSelection(t *testing.T) {
+	assert.False(t, newLogDecoder(parseTestEventTime(t, `{}`), nil).fullDocument)
+	assert.True(t, newLogDecoder(parseTestEventTime(t, `event_time: { field: raw.eventtime }`), nil).fullDocument)
+	assert.True(t, newLogDecoder(parseTestEventTime(t, `{}`), &tenantConfig{path: []string{"tenant"}}).fullDocument)
+}
+
+func TestLogDecoderFastPath(t *testing.T) {
+	items := []string{
+		`{"timestamp":"2024-01-15T10:30:00Z","log_source":"fortinet.firewall","source_ip":"192.168.1.1","connection_count":3,"raw":{"user":"alice"}}`,
+		`{"timestamp":1705314600,"log_source":"paloalto.firewall","bytes_sent":1500,"raw":null}`,
+		`{"log_source":"fortinet.firewall","timestamp":null}`,
+		`{"log_source":"fortinet.firewall"}`,
+	}
+	for _, item := range items {
+		fast, fastDoc, err := logDecoder{}.decode(item)
+		require.NoError(t, err, item)
+		full, fullDoc, err := logDecoder{fullDocument: true}.decode(item)
+		require.NoError(t, err, item)
+
+		assert.Equal(t, full, fast, item)
+		if timestamp, exists := fullDoc["timestamp"]; exists {
+			assert.Equal(t, timestamp, fastDoc["timestamp"], item)
+		} else {
+			assert.NotContains(t, fastDoc, "timestamp", item)
+		}
+	}
+
+	_, _, err := logDecoder{}.decode(`{"log_source":`)
+	assert.Error(t, err)
+}
+
+func TestLogRawDecodedLazily(t *testing.T) {
+	log, _, err := logDecoder{}.decode(`{"log_source":"fortinet.firewall","raw":{"user":"alice","port":443}}`)
+	require.NoError(t, err)
+	assert.Nil(t, log.Raw)
+
+	require.NoError(t, log.decodeRaw())
+	assert.Equal(t, map[string]interface{}{"user": "alice", "port": 443.0}, log.Raw)
+	require.NoError(t, log.decodeRaw())
+
+	// Malformed raw objects only fail when they are read
+	log, _, err = logDecoder{}.decode(`{"log_source":"fortinet.firewall","raw":[1,2]}`)
+	require.NoError(t, err)
+	assert.Error(t, log.decodeRaw())
+
+	log, _, err = logDecoder{}.decode(`{"log_source":"fortinet.firewall"}`)
+	require.NoError(t, err)
+	require.NoError(t, log.decodeRaw())
+	assert.Nil(t, log.Raw)
+}
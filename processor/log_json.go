@@ -0,0 +1,11 @@
+//go:build !gojson
+
+package processor
+
+import "encoding/json"
+
+// unmarshalLog decodes the known fields of a log. Building with the gojson
+// tag swaps encoding/json for github.com/goccy/go-json.
+func unmarshalLog(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,11 @@
+//go:build gojson
+
+package processor
+
+import gojson "github.com/goccy/go-json"
+
+// unmarshalLog decodes the known fields of a log with github.com/goccy/go-json,
+// which decodes them faster than encoding/json.
+func unmarshalLog(data []byte, v interface{}) error {
+	return gojson.Unmarshal(data, v)
+}
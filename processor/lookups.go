@@ -0,0 +1,165 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func lookupFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewIntField("max_in_flight").
+			Description("Maximum lookups in flight at once. The lookups of the logs of a read are started together, so that a slow endpoint holds up a read for about one lookup rather than one per log.").
+			Default(16),
+		service.NewObjectField("circuit_breaker",
+			service.NewIntField("failures").
+				Description("Consecutive failed lookups that open the circuit, skipping lookups until `open_for` has passed. Zero never opens it.").
+				Default(5),
+			service.NewDurationField("open_for").
+				Description("How long the circuit stays open before a single lookup probes whether the endpoint recovered").
+				Default("30s"),
+		).
+			Description("Stops looking up logs while the endpoint keeps failing, so that logs are windowed without enrichment instead of each waiting for a timeout"),
+	}
+}
+
+// errCircuitOpen is returned for lookups skipped while a circuit is open.
+var errCircuitOpen = errors.New("circuit open")
+
+// lookupExecutor runs the lookups of an enricher with bounded concurrency,
+// a deadline per lookup and a circuit breaker.
+type lookupExecutor struct {
+	slots   chan struct{}
+	timeout time.Duration
+	breaker *circuitBreaker
+}
+
+func newLookupExecutorFromConfig(conf *service.ParsedConfig, prefix string, timeout time.Duration) (*lookupExecutor, error) {
+	maxInFlight, err := conf.FieldInt("max_in_flight")
+	if err != nil {
+		return nil, err
+	}
+	if maxInFlight < 1 {
+		return nil, fmt.Errorf("%s.max_in_flight must be positive, got %d", prefix, maxInFlight)
+	}
+
+	b := &circuitBreaker{now: time.Now}
+	if b.threshold, err = conf.FieldInt("circuit_breaker", "failures"); err != nil {
+		return nil, err
+	}
+	if b.threshold < 0 {
+		return nil, fmt.Errorf("%s.circuit_breaker.failures must not be negative, got %d", prefix, b.threshold)
+	}
+	if b.openFor, err = conf.FieldDuration("circuit_breaker", "open_for"); err != nil {
+		return nil, err
+	}
+	if b.openFor <= 0 {
+		return nil, fmt.Errorf("%s.circuit_breaker.open_for must be positive, got %v", prefix, b.openFor)
+	}
+
+	return &lookupExecutor{
+		slots:   make(chan struct{}, maxInFlight),
+		timeout: timeout,
+		breaker: b,
+	}, nil
+}
+
+// run runs a lookup once a slot is free. The deadline of the lookup covers
+// the wait for its slot.
+func (x *lookupExecutor) run(ctx context.Context, lookup func(ctx context.Context) (map[string]interface{}, error)) (map[string]interface{}, error) {
+	if !x.breaker.allow() {
+		return nil, errCircuitOpen
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, x.timeout)
+	defer cancel()
+
+	select {
+	case x.slots <- struct{}{}:
+	case <-ctx.Done():
+		x.breaker.record(ctx.Err())
+		return nil, ctx.Err()
+	}
+	defer func() { <-x.slots }()
+
+	fields, err := lookup(ctx)
+	x.breaker.record(err)
+	return fields, err
+}
+
+// start runs a lookup in the background.
+func (x *lookupExecutor) start(ctx context.Context, lookup func(ctx context.Context) (map[string]interface{}, error)) *pendingLookup {
+	p := &pendingLookup{done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		p.fields, p.err = x.run(ctx, lookup)
+	}()
+	return p
+}
+
+// pendingLookup is a lookup started ahead of the log it belongs to being
+// processed.
+type pendingLookup struct {
+	done   chan struct{}
+	fields map[string]interface{}
+	err    error
+}
+
+func (p *pendingLookup) wait() (map[string]interface{}, error) {
+	<-p.done
+	return p.fields, p.err
+}
+
+// circuitBreaker opens after a number of consecutive failures and lets a
+// single probe through once it has been open for a while. The probe closes
+// it again on success.
+type circuitBreaker struct {
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mut       sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *circuitBreaker) allow() bool {
+	if b.threshold == 0 {
+		return true
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *circuitBreaker) record(err error) {
+	if b.threshold == 0 {
+		return
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.probing = false
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	if b.failures++; b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.openFor)
+	}
+}
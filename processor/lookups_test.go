@@ -0,0 +1,113 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupExecutorBoundsConcurrency(t *testing.T) {
+	x := &lookupExecutor{
+		slots:   make(chan struct{}, 2),
+		timeout: time.Second,
+		breaker: &circuitBreaker{now: time.Now},
+	}
+
+	var inFlight, peak int32
+	lookup := func(ctx context.Context) (map[string]interface{}, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return map[string]interface{}{"ok": true}, nil
+	}
+
+	var pending []*pendingLookup
+	for i := 0; i < 8; i++ {
+		pending = append(pending, x.start(context.Background(), lookup))
+	}
+	for _, p := range pending {
+		fields, err := p.wait()
+		require.NoError(t, err)
+		assert.Equal(t, true, fields["ok"])
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&peak))
+}
+
+func TestLookupExecutorTimesOutWaitingForSlot(t *testing.T) {
+	x := &lookupExecutor{
+		slots:   make(chan struct{}, 1),
+		timeout: 20 * time.Millisecond,
+		breaker: &circuitBreaker{now: time.Now},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	release := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		_, _ = x.run(context.Background(), func(ctx context.Context) (map[string]interface{}, error) {
+			<-release
+			return nil, nil
+		})
+	}()
+	for len(x.slots) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, err := x.run(context.Background(), func(ctx context.Context) (map[string]interface{}, error) {
+		t.Error("lookup ran without a slot")
+		return nil, nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	close(release)
+	wg.Wait()
+}
+
+func TestCircuitBreakerOpensAndProbes(t *testing.T) {
+	now := time.Unix(1000, 0)
+	b := &circuitBreaker{threshold: 2, openFor: 30 * time.Second, now: func() time.Time { return now }}
+	failure := errors.New("unavailable")
+
+	require.True(t, b.allow())
+	b.record(failure)
+	require.True(t, b.allow())
+	b.record(failure)
+	assert.False(t, b.allow())
+
+	// A single probe is let through once the circuit has been open long
+	// enough
+	now = now.Add(31 * time.Second)
+	assert.True(t, b.allow())
+	assert.False(t, b.allow())
+
+	// A failed probe opens the circuit again
+	b.record(failure)
+	assert.False(t, b.allow())
+
+	now = now.Add(31 * time.Second)
+	require.True(t, b.allow())
+	b.record(nil)
+	assert.True(t, b.allow())
+	assert.True(t, b.allow())
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	b := &circuitBreaker{now: time.Now}
+	for i := 0; i < 10; i++ {
+		b.record(errors.New("unavailable"))
+	}
+	assert.True(t, b.allow())
+}
@@ -0,0 +1,26 @@
+package processor
+
+// unknownSourceLabel is the log_source label of logs whose source is not
+// configured, which keeps label cardinality bounded by the configuration.
+const unknownSourceLabel = "unknown"
+
+// metricLabels returns the label values of per-source metrics. Metrics are
+// labelled by log_source, and by tenant when tenancy is enabled.
+func (f *FirewallAnomalyDetector) metricLabels(tenant, source string) []string {
+	source = f.sourceKey(source)
+	if _, exists := f.metricFieldFor(source); !exists {
+		source = unknownSourceLabel
+	}
+	if f.tenants == nil {
+		return []string{source}
+	}
+	return []string{source, tenant}
+}
+
+// metricLabelKeys mirrors metricLabels for metric construction.
+func metricLabelKeys(tenants *tenantConfig) []string {
+	if tenants == nil {
+		return []string{"log_source"}
+	}
+	return []string{"log_source", "tenant"}
+}
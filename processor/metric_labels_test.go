@@ -0,0 +1,20 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricLabels(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		sources: map[string]string{"fortinet.firewall": "connection_count"},
+	}
+	assert.Equal(t, []string{"log_source"}, metricLabelKeys(nil))
+	assert.Equal(t, []string{"fortinet.firewall"}, detector.metricLabels("", "fortinet.firewall"))
+	assert.Equal(t, []string{unknownSourceLabel}, detector.metricLabels("", "attacker.controlled"))
+
+	detector.tenants = &tenantConfig{}
+	assert.Equal(t, []string{"log_source", "tenant"}, metricLabelKeys(detector.tenants))
+	assert.Equal(t, []string{"fortinet.firewall", "acme"}, detector.metricLabels("acme", "fortinet.firewall"))
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	missingSourceIPUnknown = "unknown"
+	missingMetricZero      = "zero"
+	missingSkip            = "skip"
+
+	// unknownSourceIP is the IP logs without a source IP are counted under
+	unknownSourceIP = "unknown"
+)
+
+func missingFieldsField() *service.ConfigField {
+	return service.NewObjectField("missing_fields",
+		service.NewStringEnumField("source_ip", missingSourceIPUnknown, missingSkip).
+			Description("What to do with logs without a `source_ip`: count them under a single `unknown` IP, or skip them").
+			Default(missingSourceIPUnknown),
+		service.NewStringEnumField("metric", missingMetricZero, missingSkip).
+			Description("What to do with logs missing a metric field of their source: window them with a zero value, or skip them").
+			Default(missingMetricZero),
+	).
+		Description("Fallbacks for logs missing fields. Logs without an event time are handled by `event_time.fallback`. Skipped logs are counted by `logs_dropped`, and filled in fields by `logs_missing_field`.").
+		Advanced()
+}
+
+// missingFields is how logs missing fields are windowed.
+type missingFields struct {
+	skipSourceIP bool
+	skipMetric   bool
+}
+
+func newMissingFieldsFromConfig(conf *service.ParsedConfig) (missingFields, error) {
+	var m missingFields
+	sourceIP, err := conf.FieldString("source_ip")
+	if err != nil {
+		return m, err
+	}
+	metric, err := conf.FieldString("metric")
+	if err != nil {
+		return m, err
+	}
+	m.skipSourceIP = sourceIP == missingSkip
+	m.skipMetric = metric == missingSkip
+	return m, nil
+}
+
+// absentFields is a set of metric fields missing from a log.
+type absentFields uint8
+
+const (
+	absentConnectionCount absentFields = 1 << iota
+	absentBytesSent
+	absentBytesRecv
+)
+
+// hasMetric reports whether a metric field was present in a log. Logs that
+// were not decoded from JSON have every metric.
+func (l FirewallLog) hasMetric(field string) bool {
+	switch field {
+	case "connection_count":
+		return l.absent&absentConnectionCount == 0
+	case "bytes_sent":
+		return l.absent&absentBytesSent == 0
+	case "bytes_recv":
+		return l.absent&absentBytesRecv == 0
+	}
+	return true
+}
+
+// tolerateMissingFields applies the fallbacks of the fields missing from a
+// log, whose metrics come from metricField and the secondary metrics of its
+// source. It returns the reason to drop the log for when it is skipped.
+func (f *FirewallAnomalyDetector) tolerateMissingFields(log *FirewallLog, metricField string) string {
+	labels := f.metricLabels(log.tenant, log.LogSource)
+
+	fields := []string{metricField}
+	if metrics := f.sourceMetrics[f.sourceKey(log.LogSource)]; len(metrics) > 1 {
+		for _, m := range metrics[1:] {
+			fields = append(fields, m.field)
+		}
+	}
+	for _, field := range fields {
+		if log.hasMetric(field) {
+			continue
+		}
+		if f.missingFields.skipMetric {
+			return dropReasonMissingMetric
+		}
+		f.logsMissingField.Incr(1, append(labels, field)...)
+	}
+
+	if log.SourceIP == "" {
+		if f.missingFields.skipSourceIP {
+			return dropReasonMissingSourceIP
+		}
+		log.SourceIP = unknownSourceIP
+		f.logsMissingField.Incr(1, append(labels, "source_ip")...)
+	}
+	return ""
+}
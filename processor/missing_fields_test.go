@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissingFieldsConfig(t *testing.T) {
+	parse := func(yaml string) missingFields {
+		spec := service.NewConfigSpec().Field(missingFieldsField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		m, err := newMissingFieldsFromConfig(conf.Namespace("missing_fields"))
+		require.NoError(t, err)
+		return m
+	}
+
+	assert.Equal(t, missingFields{}, parse(`missing_fields: {}`))
+	assert.Equal(t, missingFields{skipSourceIP: true, skipMetric: true}, parse(`missing_fields: { source_ip: skip, metric: skip }`))
+}
+
+func TestDecodingTracksAbsentMetrics(t *testing.T) {
+	log, _, err := logDecoder{}.decode(`{"log_source":"fortinet.firewall","bytes_sent":0}`)
+	require.NoError(t, err)
+	assert.False(t, log.hasMetric("connection_count"))
+	assert.True(t, log.hasMetric("bytes_sent"))
+	assert.False(t, log.hasMetric("bytes_recv"))
+
+	log, _, err = decodeLog(`{"log_source":"fortinet.firewall","connection_count":7}`)
+	require.NoError(t, err)
+	assert.True(t, log.hasMetric("connection_count"))
+	assert.Equal(t, 7, log.ConnectionCount)
+	assert.False(t, log.hasMetric("bytes_sent"))
+}
+
+func TestMissingFieldFallbacks(t *testing.T) {
+	newDetector := func(m missingFields) *FirewallAnomalyDetector {
+		mgr := service.MockResources()
+		return &FirewallAnomalyDetector{
+			logger:        mgr.Logger(),
+			windowSeconds: 60,
+			windows:       make(map[string]*WindowData),
+			sources:       map[string]string{"fortinet.firewall": "connection_count"},
+			sourceMatches: &sync.Map{},
+			missingFields: m,
+		}
+	}
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	decode := func(item string) FirewallLog {
+		log, _, err := logDecoder{}.decode(item)
+		require.NoError(t, err)
+		log.Timestamp = now
+		return log
+	}
+	ctx := context.Background()
+
+	// By default missing metrics count as zero and missing IPs under a
+	// single unknown IP
+	f := newDetector(missingFields{})
+	_, err := f.processLog(ctx, decode(`{"log_source":"fortinet.firewall"}`))
+	require.NoError(t, err)
+	window := f.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Equal(t, []float64{0}, window.Values)
+	assert.True(t, window.IPs[unknownSourceIP])
+
+	var dropped []string
+	f = newDetector(missingFields{skipSourceIP: true, skipMetric: true})
+	f.onDropped = func(source, reason string) { dropped = append(dropped, reason) }
+	_, err = f.processLog(ctx, decode(`{"log_source":"fortinet.firewall","source_ip":"10.0.0.1"}`))
+	require.NoError(t, err)
+	_, err = f.processLog(ctx, decode(`{"log_source":"fortinet.firewall","connection_count":0}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{dropReasonMissingMetric, dropReasonMissingSourceIP}, dropped)
+	assert.Nil(t, f.getWindow("fortinet.firewall"))
+
+	// A zero that was logged is not missing
+	_, err = f.processLog(ctx, decode(`{"log_source":"fortinet.firewall","source_ip":"10.0.0.1","connection_count":0}`))
+	require.NoError(t, err)
+	require.NotNil(t, f.getWindow("fortinet.firewall"))
+	assert.Equal(t, []float64{0}, f.getWindow("fortinet.firewall").Values)
+}
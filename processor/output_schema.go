@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	outputSchemaNative = "native"
+	outputSchemaECS    = "ecs"
+
+	// ecsVersion is the Elastic Common Schema version results are mapped to.
+	ecsVersion = "8.11.0"
+
+	// ecsNamespace holds result fields that ECS has no field for.
+	ecsNamespace = "firewall_anomaly"
+)
+
+// The version of the native result schema. Additive changes bump the minor
+// version, while renaming, removing or retyping a field bumps the major one,
+// along with a mapping back to the previous major in previousSchemaResult.
+const (
+	resultSchemaMajor   = 2
+	resultSchemaVersion = "2.0"
+)
+
+func outputSchemaField() *service.ConfigField {
+	return service.NewStringEnumField("output_schema", outputSchemaNative, outputSchemaECS).
+		Description("Field names of results: `native`, or `ecs` for Elastic Common Schema names that Elastic SIEM ingests without a transform pipeline. Alerts and debug samples always use native names.").
+		Default(outputSchemaNative).
+		Advanced()
+}
+
+func outputSchemaVersionField() *service.ConfigField {
+	return service.NewIntField("output_schema_version").
+		Description(fmt.Sprintf("Major version of the native result schema emitted, either the current %d or %d for consumers that have not migrated yet. Every result carries its `schema_version`, also set as metadata.", resultSchemaMajor, resultSchemaMajor-1)).
+		Default(resultSchemaMajor).
+		Advanced()
+}
+
+// schemaMajorFromConfig returns the major result schema version configured.
+func schemaMajorFromConfig(conf *service.ParsedConfig) (int, error) {
+	major, err := conf.FieldInt("output_schema_version")
+	if err != nil {
+		return 0, err
+	}
+	if major != resultSchemaMajor && major != resultSchemaMajor-1 {
+		return 0, fmt.Errorf("output_schema_version must be %d or %d, got %d", resultSchemaMajor, resultSchemaMajor-1, major)
+	}
+	return major, nil
+}
+
+// ecsFields maps native result fields to their ECS field path.
+var ecsFields = map[string][]string{
+	"window_id":     {"event", "id"},
+	"timestamp":     {"@timestamp"},
+	"window_start":  {"event", "start"},
+	"window_end":    {"event", "end"},
+	"anomaly_score": {"event", "risk_score"},
+	"reason":        {"rule", "name"},
+	"log_source":    {"observer", "name"},
+	"tenant":        {"organization", "id"},
+}
+
+// outputMessage returns the message emitted for a result, shaped by the
+// output verbosity, schema version and schema. The result message itself
+// keeps the standard native result, which alerts and debug samples rely on.
+func (f *FirewallAnomalyDetector) outputMessage(msg *service.Message, result map[string]interface{}, key string, window *WindowData, threshold float64) *service.Message {
+	previous := f.schemaMajor == resultSchemaMajor-1
+	if !previous && f.outputSchema != outputSchemaECS && (f.outputVerbosity == "" || f.outputVerbosity == outputVerbosityStandard) {
+		msg.MetaSet("schema_version", resultSchemaVersion)
+		return msg
+	}
+
+	output := f.shapeResult(result, window, threshold)
+	version := resultSchemaVersion
+	if previous {
+		output, version = previousSchemaResult(output), previousSchemaVersion
+	}
+	if f.outputSchema == outputSchemaECS {
+		output = ecsResult(output, key)
+	}
+	out := msg.Copy()
+	out.SetStructured(output)
+	out.MetaSet("schema_version", version)
+	return out
+}
+
+// previousSchemaVersion is the version results of the previous major are
+// emitted as. Version 1 results predate schema versioning.
+const previousSchemaVersion = "1"
+
+// previousSchemaResult maps a result to the previous major version of the
+// schema. Version 1 results carry no schema_version.
+func previousSchemaResult(result map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(result))
+	for name, value := range result {
+		if name != "schema_version" {
+			out[name] = value
+		}
+	}
+	return out
+}
+
+// ecsResult maps a native result to ECS. Fields without an ECS equivalent
+// are kept under the firewall_anomaly namespace.
+func ecsResult(result map[string]interface{}, key string) map[string]interface{} {
+	out := map[string]interface{}{}
+	custom := map[string]interface{}{}
+	for name, value := range result {
+		if path, mapped := ecsFields[name]; mapped {
+			setPath(out, path, value)
+		} else {
+			custom[name] = value
+		}
+	}
+	out[ecsNamespace] = custom
+
+	kind := "event"
+	if isAnomaly, _ := result["is_anomaly"].(bool); isAnomaly {
+		kind = "alert"
+	}
+	setPath(out, []string{"ecs", "version"}, ecsVersion)
+	setPath(out, []string{"event", "kind"}, kind)
+	setPath(out, []string{"event", "category"}, []string{"network"})
+	setPath(out, []string{"event", "type"}, []string{"info"})
+	setPath(out, []string{"event", "module"}, "firewall_anomaly_detector")
+	setPath(out, []string{"event", "id"}, key)
+	setPath(out, []string{"observer", "type"}, "firewall")
+	if score, ok := result["anomaly_score"].(float64); ok {
+		setPath(out, []string{"event", "risk_score_norm"}, score*100)
+	}
+
+	// The top contributing IP is the source of the detection, every
+	// contributing IP is related to it
+	if ips, _ := result["top_ips"].([]IPCount); len(ips) > 0 {
+		related := make([]string, 0, len(ips))
+		for _, ip := range ips {
+			related = append(related, ip.IP)
+		}
+		setPath(out, []string{"source", "ip"}, related[0])
+		setPath(out, []string{"related", "ip"}, related)
+	}
+	return out
+}
+
+// setPath sets a value at a path of nested objects, creating them as needed.
+func setPath(obj map[string]interface{}, path []string, value interface{}) {
+	for _, segment := range path[:len(path)-1] {
+		child, ok := obj[segment].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			obj[segment] = child
+		}
+		obj = child
+	}
+	obj[path[len(path)-1]] = value
+}
@@ -0,0 +1,117 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECSOutputSchema(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:  60,
+		scoreThreshold: 0.1,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		outputSchema:   outputSchemaECS,
+		sources:        map[string]string{"fortinet.firewall": "connection_count"},
+		windows:        make(map[string]*WindowData),
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.2", now.Add(time.Second))
+	detector.updateWindow("fortinet.firewall", 900, "192.168.1.2", now.Add(2*time.Second))
+
+	window := detector.getWindow("fortinet.firewall")
+	msg, err := detector.scoreWindow(context.Background(), "fortinet.firewall", window, "connection_count", 900, false)
+	require.NoError(t, err)
+
+	result, err := alertResult(msg)
+	require.NoError(t, err)
+	assert.NotContains(t, result, "anomaly_score")
+	assert.Equal(t, window.EndTime, result["@timestamp"])
+
+	event := result["event"].(map[string]interface{})
+	assert.Equal(t, "alert", event["kind"])
+	assert.Equal(t, window.StartTime, event["start"])
+	score := event["risk_score"].(float64)
+	assert.Greater(t, score, 0.1)
+	assert.InDelta(t, score*100, event["risk_score_norm"], 1e-9)
+	id, _ := msg.MetaGet("idempotency_key")
+	assert.Equal(t, windowID("fortinet.firewall", window.StartTime, 0), id)
+	assert.Equal(t, id, event["id"])
+	assert.NotContains(t, result["firewall_anomaly"], "window_id")
+
+	assert.Equal(t, "hike_rate_detected", result["rule"].(map[string]interface{})["name"])
+	assert.Equal(t, "fortinet.firewall", result["observer"].(map[string]interface{})["name"])
+	assert.Equal(t, "192.168.1.2", result["source"].(map[string]interface{})["ip"])
+	assert.Equal(t, []string{"192.168.1.2", "192.168.1.1"}, result["related"].(map[string]interface{})["ip"])
+
+	custom := result[ecsNamespace].(map[string]interface{})
+	assert.Equal(t, true, custom["is_anomaly"])
+	assert.Equal(t, "connection_count", custom["metric_field"])
+	assert.Contains(t, custom, "features")
+
+	topic, _ := msg.MetaGet("topic")
+	assert.Equal(t, "firewall-anomalies", topic)
+}
+
+func TestNativeOutputSchemaUnchanged(t *testing.T) {
+	result := map[string]interface{}{"anomaly_score": 0.5, "log_source": "fortinet.firewall"}
+	msg := service.NewMessage(nil)
+	msg.SetStructured(result)
+	window := &WindowData{IPCounts: map[string]int{}}
+
+	detector := &FirewallAnomalyDetector{outputSchema: outputSchemaNative, outputVerbosity: outputVerbosityStandard}
+	assert.Same(t, msg, detector.outputMessage(msg, result, "key", window, 0.7))
+
+	detector.outputSchema = outputSchemaECS
+	ecs := detector.outputMessage(msg, result, "key", window, 0.7)
+	assert.NotSame(t, msg, ecs)
+	native, err := alertResult(msg)
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, native["anomaly_score"])
+}
+
+func TestOutputSchemaVersion(t *testing.T) {
+	parse := func(yaml string) (int, error) {
+		conf, err := service.NewConfigSpec().Field(outputSchemaVersionField()).ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return schemaMajorFromConfig(conf)
+	}
+
+	major, err := parse(`{}`)
+	require.NoError(t, err)
+	assert.Equal(t, resultSchemaMajor, major)
+	major, err = parse(`output_schema_version: 1`)
+	require.NoError(t, err)
+	assert.Equal(t, 1, major)
+	_, err = parse(`output_schema_version: 3`)
+	assert.ErrorContains(t, err, "output_schema_version must be 2 or 1")
+
+	result := map[string]interface{}{"schema_version": resultSchemaVersion, "anomaly_score": 0.5, "log_source": "fortinet.firewall"}
+	msg := service.NewMessage(nil)
+	msg.SetStructured(result)
+	window := &WindowData{IPCounts: map[string]int{}}
+
+	detector := &FirewallAnomalyDetector{outputSchema: outputSchemaNative, outputVerbosity: outputVerbosityStandard, schemaMajor: resultSchemaMajor}
+	current := detector.outputMessage(msg, result, "key", window, 0.7)
+	version, _ := current.MetaGet("schema_version")
+	assert.Equal(t, "2.0", version)
+
+	// Consumers pinned to the previous major get version 1 results, leaving
+	// the native result alerts rely on untouched
+	detector.schemaMajor = 1
+	previous := detector.outputMessage(msg, result, "key", window, 0.7)
+	assert.NotSame(t, msg, previous)
+	version, _ = previous.MetaGet("schema_version")
+	assert.Equal(t, "1", version)
+	output, err := alertResult(previous)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"anomaly_score": 0.5, "log_source": "fortinet.firewall"}, output)
+	assert.Equal(t, resultSchemaVersion, result["schema_version"])
+}
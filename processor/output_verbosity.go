@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	outputVerbosityCompact  = "compact"
+	outputVerbosityStandard = "standard"
+	outputVerbosityVerbose  = "verbose"
+)
+
+func outputVerbosityFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewStringEnumField("output_verbosity", outputVerbosityCompact, outputVerbosityStandard, outputVerbosityVerbose).
+			Description("Payload of results: `compact` emits only the score, verdict and window key fields for high-volume pipelines, `standard` adds features, metric and top IPs, and `verbose` adds the applied threshold, window size and `output_top_ips` contributing IPs. Alerts and debug samples always carry standard results.").
+			Default(outputVerbosityStandard).
+			Advanced(),
+		service.NewIntField("output_top_ips").
+			Description("Number of contributing IPs included in verbose results").
+			Default(25).
+			Advanced(),
+	}
+}
+
+// compactFields are the result fields kept in compact mode.
+var compactFields = []string{
+	"schema_version",
+	"window_id",
+	"timestamp",
+	"log_source",
+	"tenant",
+	"window_start",
+	"window_end",
+	"anomaly_score",
+	"is_anomaly",
+	"suppressed",
+	"final",
+	"empty",
+}
+
+// shapeResult returns the result emitted at a verbosity, leaving the
+// standard result untouched.
+func (f *FirewallAnomalyDetector) shapeResult(result map[string]interface{}, window *WindowData, threshold float64) map[string]interface{} {
+	switch f.outputVerbosity {
+	case outputVerbosityCompact:
+		shaped := make(map[string]interface{}, len(compactFields))
+		for _, name := range compactFields {
+			if value, exists := result[name]; exists {
+				shaped[name] = value
+			}
+		}
+		return shaped
+	case outputVerbosityVerbose:
+		shaped := make(map[string]interface{}, len(result)+2)
+		for name, value := range result {
+			shaped[name] = value
+		}
+		shaped["threshold"] = threshold
+		shaped["window_samples"] = len(window.Values)
+		shaped["top_ips"] = topIPs(window.IPCounts, f.outputTopIPs)
+		return shaped
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scoreTestVerbosity(t *testing.T, verbosity string) map[string]interface{} {
+	t.Helper()
+
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:   60,
+		scoreThreshold:  0.7,
+		anomalyTopic:    "firewall-anomalies",
+		normalTopic:     "firewall-normal",
+		outputVerbosity: verbosity,
+		outputTopIPs:    8,
+		sources:         map[string]string{"fortinet.firewall": "connection_count"},
+		windows:         make(map[string]*WindowData),
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		detector.updateWindow("fortinet.firewall", 100, fmt.Sprintf("192.168.1.%d", i), now.Add(time.Duration(i)*time.Second))
+	}
+
+	msg, err := detector.scoreWindow(context.Background(), "fortinet.firewall", detector.getWindow("fortinet.firewall"), "connection_count", 100, false)
+	require.NoError(t, err)
+	result, err := alertResult(msg)
+	require.NoError(t, err)
+	return result
+}
+
+func TestCompactOutput(t *testing.T) {
+	result := scoreTestVerbosity(t, outputVerbosityCompact)
+
+	assert.ElementsMatch(t, []string{"schema_version", "window_id", "timestamp", "log_source", "window_start", "window_end", "anomaly_score", "is_anomaly"}, keysOf(result))
+	assert.Equal(t, "fortinet.firewall", result["log_source"])
+}
+
+func TestVerboseOutput(t *testing.T) {
+	standard := scoreTestVerbosity(t, outputVerbosityStandard)
+	assert.Len(t, standard["top_ips"], topIPsLimit)
+	assert.NotContains(t, standard, "threshold")
+
+	verbose := scoreTestVerbosity(t, outputVerbosityVerbose)
+	assert.Len(t, verbose["top_ips"], 8)
+	assert.Equal(t, 0.7, verbose["threshold"])
+	assert.Equal(t, 10, verbose["window_samples"])
+	assert.Contains(t, verbose, "features")
+}
+
+func keysOf(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	partitionModeNone   = "none"
+	partitionModeStatic = "static"
+	partitionModeRedis  = "redis"
+)
+
+func partitioningField() *service.ConfigField {
+	return service.NewObjectField("partitioning",
+		service.NewStringEnumField("mode", partitionModeNone, partitionModeStatic, partitionModeRedis).
+			Description("How window keys are split between replicas. `static` uses `instance_id` and `instance_count`, `redis` discovers live replicas through heartbeats in Redis.").
+			Default(partitionModeNone),
+		service.NewIntField("instance_id").
+			Description("Zero based index of this replica in `static` mode").
+			Default(0),
+		service.NewIntField("instance_count").
+			Description("Total number of replicas in `static` mode").
+			Default(1),
+		service.NewStringField("instance_name").
+			Description("Unique name of this replica in `redis` mode. Defaults to the hostname and process ID.").
+			Default(""),
+		service.NewStringField("membership_key").
+			Description("Redis sorted set holding replica heartbeats in `redis` mode").
+			Default("firewall_anomaly_detector:members"),
+		service.NewDurationField("heartbeat_interval").
+			Description("How often replicas heartbeat and refresh membership in `redis` mode").
+			Default("5s"),
+		service.NewDurationField("member_ttl").
+			Description("Replicas that have not heartbeated for this long are considered gone. Must exceed `heartbeat_interval`, a few intervals allowing for missed heartbeats.").
+			Default("15s"),
+		service.NewStringField("handoff_key").
+			Description("Redis hash windows are handed over through when ownership moves between replicas in `redis` mode").
+			Default("firewall_anomaly_detector:handoff"),
+	).
+		Description("Partitions window ownership between replicas so that each window key is scored by exactly one instance").
+		Advanced()
+}
+
+// partitioner decides which window keys are owned by this instance. Keys are
+// assigned by hashing them modulo the number of live instances.
+type partitioner struct {
+	mode string
+	name string
+
+	mut   sync.RWMutex
+	index int
+	count int
+
+	redisClient       *redis.Client
+	membershipKey     string
+	heartbeatInterval time.Duration
+	memberTTL         time.Duration
+	handoffKey        string
+	logger            *service.Logger
+
+	// onHeartbeat is called after every successful membership refresh with
+	// whether ownership changed, so that windows can be rebalanced.
+	onHeartbeat func(ctx context.Context, changed bool)
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newPartitionerFromConfig(conf *service.ParsedConfig, redisClient *redis.Client, logger *service.Logger) (*partitioner, error) {
+	mode, err := conf.FieldString("mode")
+	if err != nil {
+		return nil, err
+	}
+
+	p := &partitioner{mode: mode, index: 0, count: 1, logger: logger}
+
+	switch mode {
+	case partitionModeStatic:
+		if p.index, err = conf.FieldInt("instance_id"); err != nil {
+			return nil, err
+		}
+		if p.count, err = conf.FieldInt("instance_count"); err != nil {
+			return nil, err
+		}
+		if p.count < 1 || p.index < 0 || p.index >= p.count {
+			return nil, fmt.Errorf("partitioning instance_id must be in [0, %d), got %d", p.count, p.index)
+		}
+	case partitionModeRedis:
+		if p.name, err = conf.FieldString("instance_name"); err != nil {
+			return nil, err
+		}
+		if p.name == "" {
+			hostname, _ := os.Hostname()
+			p.name = hostname + "-" + strconv.Itoa(os.Getpid())
+		}
+		if p.membershipKey, err = conf.FieldString("membership_key"); err != nil {
+			return nil, err
+		}
+		if p.heartbeatInterval, err = conf.FieldDuration("heartbeat_interval"); err != nil {
+			return nil, err
+		}
+		if p.heartbeatInterval <= 0 {
+			return nil, fmt.Errorf("partitioning.heartbeat_interval must be positive, got %v", p.heartbeatInterval)
+		}
+		if p.memberTTL, err = conf.FieldDuration("member_ttl"); err != nil {
+			return nil, err
+		}
+		if p.memberTTL <= p.heartbeatInterval {
+			return nil, fmt.Errorf("partitioning.member_ttl must exceed heartbeat_interval %v, got %v", p.heartbeatInterval, p.memberTTL)
+		}
+		if p.handoffKey, err = conf.FieldString("handoff_key"); err != nil {
+			return nil, err
+		}
+		p.redisClient = redisClient
+	}
+	return p, nil
+}
+
+// start begins heartbeating when membership is discovered through Redis.
+func (p *partitioner) start() {
+	if p == nil || p.mode != partitionModeRedis {
+		return
+	}
+
+	p.shutdown = make(chan struct{})
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := p.heartbeat(context.Background()); err != nil {
+				p.logger.Warnf("Failed to refresh partition membership: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-p.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// heartbeat registers this instance, expires dead members and recomputes
+// this instance's position among the live members.
+func (p *partitioner) heartbeat(ctx context.Context) error {
+	now := time.Now()
+
+	pipe := p.redisClient.TxPipeline()
+	pipe.ZAdd(ctx, p.membershipKey, &redis.Z{Score: float64(now.UnixMilli()), Member: p.name})
+	pipe.ZRemRangeByScore(ctx, p.membershipKey, "-inf", strconv.FormatInt(now.Add(-p.memberTTL).UnixMilli(), 10))
+	members := pipe.ZRange(ctx, p.membershipKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	changed := p.setMembers(members.Val())
+	if p.onHeartbeat != nil {
+		p.onHeartbeat(ctx, changed)
+	}
+	return nil
+}
+
+// setMembers updates this instance's position among the live members and
+// reports whether it changed.
+func (p *partitioner) setMembers(members []string) bool {
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+
+	index := sort.SearchStrings(sorted, p.name)
+	if index == len(sorted) || sorted[index] != p.name {
+		// Not yet visible to others, act as a single member until we are.
+		sorted, index = []string{p.name}, 0
+	}
+
+	p.mut.Lock()
+	changed := p.index != index || p.count != len(sorted)
+	p.index, p.count = index, len(sorted)
+	p.mut.Unlock()
+
+	if changed {
+		p.logger.Infof("Partition membership changed: instance %d of %d", index, len(sorted))
+	}
+	return changed
+}
+
+// owns reports whether this instance is responsible for windowKey.
+func (p *partitioner) owns(windowKey string) bool {
+	if p == nil || p.mode == partitionModeNone {
+		return true
+	}
+
+	p.mut.RLock()
+	index, count := p.index, p.count
+	p.mut.RUnlock()
+
+	return partitionFor(windowKey, count) == index
+}
+
+func (p *partitioner) close(ctx context.Context) {
+	if p == nil || p.shutdown == nil {
+		return
+	}
+	close(p.shutdown)
+	<-p.done
+
+	// Leave the membership promptly so the remaining replicas rebalance
+	// without waiting for the TTL to expire.
+	if err := p.redisClient.ZRem(ctx, p.membershipKey, p.name).Err(); err != nil {
+		p.logger.Warnf("Failed to leave partition membership: %v", err)
+	}
+}
+
+func partitionFor(windowKey string, count int) int {
+	if count <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(windowKey))
+	return int(h.Sum32() % uint32(count))
+}
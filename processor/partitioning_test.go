@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parsePartitioner(t *testing.T, yaml string) (*partitioner, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(partitioningField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	return newPartitionerFromConfig(conf.Namespace("partitioning"), nil, service.MockResources().Logger())
+}
+
+func TestStaticPartitioningOwnsEachKeyOnce(t *testing.T) {
+	instances := make([]*partitioner, 3)
+	for i := range instances {
+		p, err := parsePartitioner(t, fmt.Sprintf(`
+partitioning:
+  mode: static
+  instance_id: %d
+  instance_count: 3
+`, i))
+		require.NoError(t, err)
+		instances[i] = p
+	}
+
+	perInstance := make([]int, len(instances))
+	for k := 0; k < 300; k++ {
+		key := fmt.Sprintf("firewall-%d", k)
+
+		owners := 0
+		for i, p := range instances {
+			if p.owns(key) {
+				owners++
+				perInstance[i]++
+			}
+		}
+		assert.Equal(t, 1, owners, key)
+	}
+	for _, n := range perInstance {
+		assert.Greater(t, n, 50)
+	}
+}
+
+func TestStaticPartitioningInvalidInstance(t *testing.T) {
+	_, err := parsePartitioner(t, `
+partitioning:
+  mode: static
+  instance_id: 3
+  instance_count: 3
+`)
+	assert.Error(t, err)
+}
+
+func TestRedisPartitioningInvalidIntervals(t *testing.T) {
+	_, err := parsePartitioner(t, `
+partitioning:
+  mode: redis
+  heartbeat_interval: 0s
+`)
+	assert.ErrorContains(t, err, "partitioning.heartbeat_interval must be positive")
+
+	// Members would expire between heartbeats
+	_, err = parsePartitioner(t, `
+partitioning:
+  mode: redis
+  heartbeat_interval: 10s
+  member_ttl: 10s
+`)
+	assert.ErrorContains(t, err, "partitioning.member_ttl must exceed heartbeat_interval")
+}
+
+func TestPartitioningDisabledOwnsEverything(t *testing.T) {
+	p, err := parsePartitioner(t, `partitioning: {}`)
+	require.NoError(t, err)
+	assert.True(t, p.owns("fortinet.firewall"))
+}
+
+func TestRedisPartitioningMembership(t *testing.T) {
+	p, err := parsePartitioner(t, `
+partitioning:
+  mode: redis
+  instance_name: detector-b
+`)
+	require.NoError(t, err)
+
+	p.setMembers([]string{"detector-c", "detector-a", "detector-b"})
+	assert.Equal(t, 1, p.index)
+	assert.Equal(t, 3, p.count)
+
+	// Until our own heartbeat is visible we own every key.
+	p.setMembers([]string{"detector-a"})
+	assert.Equal(t, 0, p.index)
+	assert.Equal(t, 1, p.count)
+	assert.True(t, p.owns("fortinet.firewall"))
+}
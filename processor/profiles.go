@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	profileNone                 = "none"
+	profileDatacenterHighVolume = "datacenter_high_volume"
+	profileBranchOffice         = "branch_office"
+	profileLab                  = "lab"
+)
+
+func profileField() *service.ConfigField {
+	return service.NewStringEnumField("profile", profileNone, profileDatacenterHighVolume, profileBranchOffice, profileLab).
+		Description("Preset of window size, score history, memory and consumption limits suited to a deployment. Every field the profile sets can still be overridden individually.").
+		Default(profileNone)
+}
+
+// profile holds the values of the fields a preset sets, used for every such
+// field left unset.
+type profile struct {
+	windowSeconds int
+	scoreHistory  int
+	maxWindows    int
+	maxInFlight   int
+	batchSize     int
+}
+
+var profiles = map[string]profile{
+	// The defaults of the individual fields
+	profileNone: {
+		windowSeconds: 60,
+		scoreHistory:  1000,
+		maxWindows:    0,
+		maxInFlight:   0,
+		batchSize:     1000,
+	},
+	// Busy sources fill short windows, and a larger score history gives
+	// precise adaptive percentiles, while the window and in-flight limits
+	// keep traffic storms within memory.
+	profileDatacenterHighVolume: {
+		windowSeconds: 30,
+		scoreHistory:  5000,
+		maxWindows:    100000,
+		maxInFlight:   50000,
+		batchSize:     5000,
+	},
+	// Quiet sources need longer windows to gather enough samples, on
+	// smaller hosts.
+	profileBranchOffice: {
+		windowSeconds: 300,
+		scoreHistory:  500,
+		maxWindows:    2000,
+		maxInFlight:   2000,
+		batchSize:     200,
+	},
+	// Short windows give quick feedback while experimenting.
+	profileLab: {
+		windowSeconds: 10,
+		scoreHistory:  200,
+		maxWindows:    500,
+		maxInFlight:   500,
+		batchSize:     100,
+	},
+}
+
+func profileFromConfig(conf *service.ParsedConfig) (profile, error) {
+	name, err := conf.FieldString("profile")
+	if err != nil {
+		return profile{}, err
+	}
+	return profiles[name], nil
+}
+
+// fieldIntOr returns an int field, or fallback when it is not set.
+func fieldIntOr(conf *service.ParsedConfig, fallback int, path ...string) (int, error) {
+	if !conf.Contains(path...) {
+		return fallback, nil
+	}
+	return conf.FieldInt(path...)
+}
+
+// fieldStringOr returns a string field, or fallback when it is not set.
+func fieldStringOr(conf *service.ParsedConfig, fallback string, path ...string) (string, error) {
+	if !conf.Contains(path...) {
+		return fallback, nil
+	}
+	return conf.FieldString(path...)
+}
@@ -0,0 +1,63 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileDefaults(t *testing.T) {
+	conf, err := detectorConfigSpec().ParseYAML(`
+profile: branch_office
+consumption:
+  mode: ack
+memory_budget:
+  max_windows: 50
+`, nil)
+	require.NoError(t, err)
+
+	preset, err := profileFromConfig(conf)
+	require.NoError(t, err)
+	assert.Equal(t, profiles[profileBranchOffice], preset)
+
+	// Unset fields take the value of the profile
+	windowSeconds, err := fieldIntOr(conf, preset.windowSeconds, "window_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 300, windowSeconds)
+
+	budget, err := newConsumptionBudgetFromConfig(conf.Namespace("backpressure"), consumptionModeAck, preset)
+	require.NoError(t, err)
+	require.NotNil(t, budget)
+	assert.Equal(t, 2000, budget.maxInFlight)
+
+	// Fields set explicitly override the profile
+	spill, err := newWindowSpillFromConfig(conf.Namespace("memory_budget"), nil, preset)
+	require.NoError(t, err)
+	assert.Equal(t, 50, spill.maxWindows)
+}
+
+func TestNoProfileKeepsFieldDefaults(t *testing.T) {
+	conf, err := detectorConfigSpec().ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	preset, err := profileFromConfig(conf)
+	require.NoError(t, err)
+
+	windowSeconds, err := fieldIntOr(conf, preset.windowSeconds, "window_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 60, windowSeconds)
+
+	consumer, err := newLogConsumerFromConfig(conf.Namespace("consumption"), nil, "firewall_logs", preset)
+	require.NoError(t, err)
+	assert.Equal(t, 1000, consumer.batchSize)
+
+	spill, err := newWindowSpillFromConfig(conf.Namespace("memory_budget"), nil, preset)
+	require.NoError(t, err)
+	assert.Nil(t, spill)
+
+	for name := range profiles {
+		_, err := detectorConfigSpec().ParseYAML(`profile: `+name, nil)
+		assert.NoError(t, err, name)
+	}
+}
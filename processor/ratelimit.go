@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	rateLimitBySource       = "source"
+	rateLimitByTenant       = "tenant"
+	rateLimitByTenantSource = "tenant_source"
+)
+
+func rateLimitField() *service.ConfigField {
+	return service.NewObjectField("rate_limit",
+		service.NewFloatField("logs_per_second").
+			Description("Sustained number of logs per second admitted to windowing per key. Zero disables rate limiting.").
+			Default(0.0),
+		service.NewIntField("burst").
+			Description("Number of logs a key may exceed its rate by in a burst. Defaults to one second worth of logs.").
+			Default(0),
+		service.NewStringEnumField("key", rateLimitBySource, rateLimitByTenant, rateLimitByTenantSource).
+			Description("What a token bucket is kept for").
+			Default(rateLimitBySource),
+		service.NewBoolField("shared").
+			Description("Keep the buckets in Redis so that the limit applies across all replicas").
+			Default(false),
+		service.NewStringField("key_prefix").
+			Description("Prefix of the Redis keys of shared buckets").
+			Default("firewall_anomaly_detector:ratelimit:"),
+		service.NewFloatField("overflow_sample_rate").
+			Description("Fraction of logs over the limit that are still admitted, so that noisy sources remain represented").
+			Default(0.0),
+	).
+		Description("Token bucket rate limiting of logs so that one noisy firewall can not starve the rest").
+		Advanced()
+}
+
+// takeTokenScript refills a token bucket stored in a hash and takes a token
+// from it, returning 1 when a token was available.
+var takeTokenScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(bucket[1]) or burst
+local last = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + (now - last) / 1000 * rate)
+local admitted = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	admitted = 1
+end
+redis.call("HSET", KEYS[1], "tokens", tokens, "last", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return admitted
+`)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// logRateLimiter admits logs to windowing through a token bucket per key.
+type logRateLimiter struct {
+	rate       float64
+	burst      float64
+	key        string
+	sampleRate float64
+
+	client *redis.Client
+	prefix string
+
+	now  func() time.Time
+	rand func() float64
+
+	mut     sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func newLogRateLimiterFromConfig(conf *service.ParsedConfig, client *redis.Client) (*logRateLimiter, error) {
+	rate, err := conf.FieldFloat("logs_per_second")
+	if err != nil {
+		return nil, err
+	}
+	if rate <= 0 {
+		return nil, nil
+	}
+
+	l := &logRateLimiter{
+		rate:    rate,
+		now:     time.Now,
+		rand:    rand.Float64,
+		buckets: make(map[string]*tokenBucket),
+	}
+
+	burst, err := conf.FieldInt("burst")
+	if err != nil {
+		return nil, err
+	}
+	l.burst = float64(burst)
+	if burst <= 0 {
+		l.burst = math.Max(1, math.Ceil(rate))
+	}
+
+	if l.key, err = conf.FieldString("key"); err != nil {
+		return nil, err
+	}
+	if l.sampleRate, err = conf.FieldFloat("overflow_sample_rate"); err != nil {
+		return nil, err
+	}
+	if l.sampleRate < 0 || l.sampleRate > 1 {
+		return nil, errors.New("rate_limit overflow_sample_rate must be between 0 and 1")
+	}
+
+	shared, err := conf.FieldBool("shared")
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		l.client = client
+		if l.prefix, err = conf.FieldString("key_prefix"); err != nil {
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// bucketKey returns the bucket a log of a tenant and source is counted in.
+func (l *logRateLimiter) bucketKey(tenant, source string) string {
+	switch l.key {
+	case rateLimitByTenant:
+		return tenant
+	case rateLimitByTenantSource:
+		return windowKeyFor(tenant, source)
+	default:
+		return source
+	}
+}
+
+// admit reports whether a log may enter windowing, and whether it was
+// admitted as a sample of the overflow.
+func (l *logRateLimiter) admit(ctx context.Context, tenant, source string) (ok, sampled bool, err error) {
+	if l == nil {
+		return true, false, nil
+	}
+
+	key := l.bucketKey(tenant, source)
+	if l.client != nil {
+		ok, err = l.takeShared(ctx, key)
+	} else {
+		ok = l.takeLocal(key)
+	}
+	if err != nil || ok {
+		return ok, false, err
+	}
+
+	if l.sampleRate > 0 && l.rand() < l.sampleRate {
+		return true, true, nil
+	}
+	return false, false, nil
+}
+
+func (l *logRateLimiter) takeLocal(key string) bool {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	now := l.now()
+	bucket, exists := l.buckets[key]
+	if !exists {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.last).Seconds()
+	bucket.tokens = math.Min(l.burst, bucket.tokens+elapsed*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+func (l *logRateLimiter) takeShared(ctx context.Context, key string) (bool, error) {
+	admitted, err := takeTokenScript.Run(ctx, l.client, []string{l.prefix + key},
+		l.rate, l.burst, strconv.FormatInt(l.now().UnixMilli(), 10)).Int()
+	return admitted == 1, err
+}
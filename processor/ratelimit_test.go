@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseRateLimiter(t *testing.T, yaml string) *logRateLimiter {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(rateLimitField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	limiter, err := newLogRateLimiterFromConfig(conf.Namespace("rate_limit"), nil)
+	require.NoError(t, err)
+	return limiter
+}
+
+func TestRateLimiterTokenBucketPerSource(t *testing.T) {
+	limiter := parseRateLimiter(t, `
+rate_limit:
+  logs_per_second: 2
+  burst: 3
+`)
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	admit := func(source string) bool {
+		ok, _, err := limiter.admit(context.Background(), "", source)
+		require.NoError(t, err)
+		return ok
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, admit("fortinet.firewall"))
+	}
+	assert.False(t, admit("fortinet.firewall"))
+
+	// Other sources have their own bucket.
+	assert.True(t, admit("paloalto.firewall"))
+
+	// Half a second refills one token.
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, admit("fortinet.firewall"))
+	assert.False(t, admit("fortinet.firewall"))
+}
+
+func TestRateLimiterOverflowSampling(t *testing.T) {
+	limiter := parseRateLimiter(t, `
+rate_limit:
+  logs_per_second: 1
+  key: tenant
+  overflow_sample_rate: 0.5
+`)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	draws := []float64{0.9, 0.1}
+	limiter.rand = func() float64 {
+		d := draws[0]
+		draws = draws[1:]
+		return d
+	}
+
+	ok, sampled, err := limiter.admit(context.Background(), "acme", "fortinet.firewall")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, sampled)
+
+	// The tenant's bucket is shared by all of its sources.
+	ok, _, err = limiter.admit(context.Background(), "acme", "paloalto.firewall")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, sampled, err = limiter.admit(context.Background(), "acme", "paloalto.firewall")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, sampled)
+}
+
+func TestRateLimiterDisabledByDefault(t *testing.T) {
+	assert.Nil(t, parseRateLimiter(t, `rate_limit: {}`))
+}
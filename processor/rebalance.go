@@ -0,0 +1,205 @@
+package processor
+
+import (
+	"context"
+	"time"
+)
+
+// rebalance is run after every partition membership refresh. When ownership
+// changed, windows this instance no longer owns are handed over through
+// Redis, and windows handed over by other replicas are adopted.
+func (f *FirewallAnomalyDetector) rebalance(ctx context.Context, changed bool) {
+	if changed {
+		if err := f.handoffWindows(ctx, false); err != nil {
+			f.logger.Errorf("Failed to hand over windows: %v", err)
+		}
+	}
+	if err := f.adoptWindows(ctx); err != nil {
+		f.logger.Errorf("Failed to adopt handed over windows: %v", err)
+	}
+}
+
+// handoffWindows moves windows out of memory into the handoff hash, either
+// every window or only those now owned by another replica.
+func (f *FirewallAnomalyDetector) handoffWindows(ctx context.Context, all bool) error {
+	p := f.partitioner
+	if p == nil || p.mode != partitionModeRedis {
+		return nil
+	}
+
+	f.windowsMutex.Lock()
+	moved := make(map[string]*WindowData)
+	for key, window := range f.windows {
+		if all || !p.owns(key) {
+			moved[key] = window
+			delete(f.windows, key)
+		}
+	}
+	f.windowsMutex.Unlock()
+	if len(moved) == 0 {
+		return nil
+	}
+
+	values := make(map[string]interface{}, len(moved))
+	var pending []string
+	for key, window := range moved {
+		// The new owner acknowledges nothing on our behalf, so our parked
+		// logs are released with the handover.
+		pending = append(pending, window.Pending...)
+		handed := *window
+		handed.Pending = nil
+
+		raw, err := encodeWindowState(&handed)
+		if err != nil {
+			return err
+		}
+		values[key] = raw
+	}
+
+	if err := p.redisClient.HSet(ctx, p.handoffKey, values).Err(); err != nil {
+		dropped := make(map[string]*WindowData)
+		f.windowsMutex.Lock()
+		for key, window := range moved {
+			if older := f.adoptWindowLocked(key, window); older != nil {
+				dropped[key] = older
+			}
+		}
+		f.windowsMutex.Unlock()
+		for key, window := range dropped {
+			f.dropSupersededWindow(ctx, key, window)
+		}
+		return err
+	}
+
+	f.ackLogs(ctx, pending...)
+	for key := range moved {
+		f.replication.markDirty(key)
+	}
+	f.logger.Infof("Handed over %d windows to other replicas", len(moved))
+	return nil
+}
+
+// adoptWindows takes over handed over windows owned by this instance,
+// merging them with any window that has already been started locally.
+func (f *FirewallAnomalyDetector) adoptWindows(ctx context.Context) error {
+	p := f.partitioner
+	if p == nil || p.mode != partitionModeRedis {
+		return nil
+	}
+
+	stored, err := p.redisClient.HGetAll(ctx, p.handoffKey).Result()
+	if err != nil {
+		return err
+	}
+
+	var adopted []string
+	for key, raw := range stored {
+		if !p.owns(key) {
+			continue
+		}
+		adopted = append(adopted, key)
+
+		window, _, err := decodeWindowState(key, raw)
+		if err != nil {
+			f.logger.Warnf("Discarding unreadable handed over window %s: %v", key, err)
+			continue
+		}
+
+		f.windowsMutex.Lock()
+		older := f.adoptWindowLocked(key, window)
+		f.windowsMutex.Unlock()
+		if older != nil {
+			f.dropSupersededWindow(ctx, key, older)
+		}
+		f.replication.markDirty(key)
+	}
+	if len(adopted) == 0 {
+		return nil
+	}
+
+	f.logger.Infof("Adopted %d windows from other replicas", len(adopted))
+	return p.redisClient.HDel(ctx, p.handoffKey, adopted...).Err()
+}
+
+// adoptWindowLocked puts a handed over window into the windows map, merging
+// it with a window of the same interval started locally. Windows are aligned
+// to fixed intervals, so of two windows covering different intervals only the
+// newer is kept and the older is returned to be dropped.
+func (f *FirewallAnomalyDetector) adoptWindowLocked(key string, window *WindowData) *WindowData {
+	existing, exists := f.windows[key]
+	switch {
+	case !exists:
+		f.windows[key] = window
+		return nil
+	case existing.StartTime.Equal(window.StartTime):
+		mergeWindows(existing, window)
+		return nil
+	case window.StartTime.After(existing.StartTime):
+		f.windows[key] = window
+		return existing
+	default:
+		return window
+	}
+}
+
+// dropSupersededWindow drops a window whose interval a newer window of its
+// key replaced, counting its logs as late.
+func (f *FirewallAnomalyDetector) dropSupersededWindow(ctx context.Context, key string, window *WindowData) {
+	source, tenant := windowScope(key, window)
+	f.logger.Warnf("Dropping window %s starting %s, superseded by a newer window", key, window.StartTime.Format(time.RFC3339))
+	if samples := len(window.Values); samples > 0 {
+		f.logsDropped.Incr(int64(samples), append(f.metricLabels(tenant, source), dropReasonLate)...)
+	}
+	f.ackLogs(ctx, window.Pending...)
+}
+
+// mergeWindows folds the aggregates of src into dst, a window of the same
+// interval.
+func mergeWindows(dst, src *WindowData) {
+	// Merged values are kept in arrival order, unwrapping ring buffers
+	dst.Values = append(orderedSamples(dst.Values, dst.ValuesHead), orderedSamples(src.Values, src.ValuesHead)...)
+	dst.ValuesHead = 0
+	dst.Pending = append(dst.Pending, src.Pending...)
+
+	mergeIPs(dst, src)
+	if dst.IPCounts == nil {
+		dst.IPCounts = make(map[string]int, len(src.IPCounts))
+	}
+	for ip, count := range src.IPCounts {
+		dst.IPCounts[ip] += count
+	}
+	for prefix, values := range dst.Metrics {
+		dst.Metrics[prefix] = orderedSamples(values, dst.MetricHeads[prefix])
+	}
+	dst.MetricHeads = nil
+	for prefix, values := range src.Metrics {
+		if dst.Metrics == nil {
+			dst.Metrics = make(map[string][]float64, len(src.Metrics))
+		}
+		dst.Metrics[prefix] = append(dst.Metrics[prefix], orderedSamples(values, src.MetricHeads[prefix])...)
+	}
+	for k, v := range src.Enrichment {
+		if dst.Enrichment == nil {
+			dst.Enrichment = make(map[string]interface{}, len(src.Enrichment))
+		}
+		if _, exists := dst.Enrichment[k]; !exists {
+			dst.Enrichment[k] = v
+		}
+	}
+	for severity, count := range src.Severities {
+		if dst.Severities == nil {
+			dst.Severities = make(map[string]int, len(src.Severities))
+		}
+		dst.Severities[severity] += count
+	}
+
+	if dst.Source == "" {
+		dst.Source, dst.Tenant = src.Source, src.Tenant
+	}
+	if dst.LastMean == 0 {
+		dst.LastMean = src.LastMean
+	}
+	if src.UpdatedAt.After(dst.UpdatedAt) {
+		dst.UpdatedAt = src.UpdatedAt
+	}
+}
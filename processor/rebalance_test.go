@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeWindows(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	dst := &WindowData{
+		Values:    []float64{10},
+		IPs:       map[string]bool{"192.168.1.1": true},
+		IPCounts:  map[string]int{"192.168.1.1": 1},
+		StartTime: start,
+		EndTime:   start.Add(60 * time.Second),
+	}
+	src := &WindowData{
+		Source:     "fortinet.firewall",
+		Values:     []float64{20, 30},
+		IPs:        map[string]bool{"192.168.1.1": true, "192.168.1.2": true},
+		IPCounts:   map[string]int{"192.168.1.1": 1, "192.168.1.2": 1},
+		StartTime:  start,
+		EndTime:    start.Add(60 * time.Second),
+		Enrichment: map[string]interface{}{"asn": 64512.0},
+	}
+
+	mergeWindows(dst, src)
+
+	assert.Equal(t, []float64{10, 20, 30}, dst.Values)
+	assert.Len(t, dst.IPs, 2)
+	assert.Equal(t, 2, dst.IPCounts["192.168.1.1"])
+	assert.Equal(t, start, dst.StartTime)
+	assert.Equal(t, start.Add(60*time.Second), dst.EndTime)
+	assert.Equal(t, "fortinet.firewall", dst.Source)
+	assert.Equal(t, 64512.0, dst.Enrichment["asn"])
+}
+
+func TestAdoptWindowsOfDifferentIntervals(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector := &FirewallAnomalyDetector{
+		logger:  service.MockResources().Logger(),
+		windows: make(map[string]*WindowData),
+	}
+	window := func(start time.Time, values ...float64) *WindowData {
+		return &WindowData{Source: "fortinet.firewall", Values: values, StartTime: start, EndTime: start.Add(time.Minute)}
+	}
+
+	detector.windows["fortinet.firewall"] = window(start.Add(time.Minute), 10)
+
+	// An older handed over window is dropped rather than widening the local one
+	older := window(start, 20, 30)
+	assert.Same(t, older, detector.adoptWindowLocked("fortinet.firewall", older))
+	local := detector.windows["fortinet.firewall"]
+	assert.Equal(t, start.Add(time.Minute), local.StartTime)
+	assert.Equal(t, start.Add(2*time.Minute), local.EndTime)
+	assert.Equal(t, []float64{10}, local.Values)
+	detector.dropSupersededWindow(context.Background(), "fortinet.firewall", older)
+
+	// A newer one replaces the local window, which is dropped
+	newer := window(start.Add(2*time.Minute), 40)
+	assert.Same(t, local, detector.adoptWindowLocked("fortinet.firewall", newer))
+	assert.Same(t, newer, detector.windows["fortinet.firewall"])
+
+	// Windows of the same interval are merged
+	require.Nil(t, detector.adoptWindowLocked("fortinet.firewall", window(start.Add(2*time.Minute), 50)))
+	merged := detector.windows["fortinet.firewall"]
+	assert.Equal(t, []float64{40, 50}, merged.Values)
+	assert.Equal(t, start.Add(2*time.Minute), merged.StartTime)
+	assert.Equal(t, start.Add(3*time.Minute), merged.EndTime)
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func reorderField() *service.ConfigField {
+	return service.NewObjectField("reorder",
+		service.NewDurationField("max_delay").
+			Description("How long logs are held back to be sorted by event time before they are windowed. A log is released once a log at least this much newer in event time has been read, or once it has been held this long. Zero windows logs in the order they are read.").
+			Default("0s"),
+		service.NewIntField("max_logs").
+			Description("Maximum logs held at once. The oldest in event time are released early once the buffer is full.").
+			Default(10000),
+	).
+		Description("Buffer sorting logs that arrive interleaved and out of order from several firewalls by event time before they are assigned to windows. In `ack` consumption mode held logs are not acknowledged, so logs held on shutdown are re-delivered on startup; otherwise they are lost.").
+		Advanced()
+}
+
+// reorderBuffer holds logs back for up to maxDelay and releases them sorted
+// by event time.
+type reorderBuffer struct {
+	maxDelay time.Duration
+	maxLogs  int
+
+	mut  sync.Mutex
+	held []heldLog
+	// latest is the latest event time read so far
+	latest time.Time
+}
+
+// heldLog is a log waiting in the reorder buffer.
+type heldLog struct {
+	log    FirewallLog
+	heldAt time.Time
+}
+
+func newReorderBufferFromConfig(conf *service.ParsedConfig) (*reorderBuffer, error) {
+	maxDelay, err := conf.FieldDuration("max_delay")
+	if err != nil {
+		return nil, err
+	}
+	if maxDelay < 0 {
+		return nil, fmt.Errorf("reorder.max_delay must not be negative, got %v", maxDelay)
+	}
+	if maxDelay == 0 {
+		return nil, nil
+	}
+	maxLogs, err := conf.FieldInt("max_logs")
+	if err != nil {
+		return nil, err
+	}
+	if maxLogs < 1 {
+		return nil, fmt.Errorf("reorder.max_logs must be positive, got %d", maxLogs)
+	}
+	return &reorderBuffer{maxDelay: maxDelay, maxLogs: maxLogs}, nil
+}
+
+// release adds logs read at now to the buffer and returns the logs that are
+// due, sorted by event time. Logs with the same event time keep the order
+// they were read in.
+func (b *reorderBuffer) release(logs []FirewallLog, now time.Time) []FirewallLog {
+	if b == nil {
+		return logs
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	for _, log := range logs {
+		b.held = append(b.held, heldLog{log: log, heldAt: now})
+		if log.Timestamp.After(b.latest) {
+			b.latest = log.Timestamp
+		}
+	}
+	sort.SliceStable(b.held, func(i, j int) bool {
+		return b.held[i].log.Timestamp.Before(b.held[j].log.Timestamp)
+	})
+
+	// Every log up to the event time of the newest log that is due is
+	// released, so that logs held longest never overtake older ones
+	cutoff := b.latest.Add(-b.maxDelay)
+	for _, h := range b.held {
+		if now.Sub(h.heldAt) >= b.maxDelay && h.log.Timestamp.After(cutoff) {
+			cutoff = h.log.Timestamp
+		}
+	}
+	n := sort.Search(len(b.held), func(i int) bool {
+		return b.held[i].log.Timestamp.After(cutoff)
+	})
+	if overflow := len(b.held) - b.maxLogs; n < overflow {
+		n = overflow
+	}
+
+	released := make([]FirewallLog, n)
+	for i := range released {
+		released[i] = b.held[i].log
+	}
+	remaining := copy(b.held, b.held[n:])
+	clear(b.held[remaining:])
+	b.held = b.held[:remaining]
+	return released
+}
+
+// size returns the number of logs held.
+func (b *reorderBuffer) size() int {
+	if b == nil {
+		return 0
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+	return len(b.held)
+}
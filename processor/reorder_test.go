@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReorderBufferConfig(t *testing.T) {
+	parse := func(yaml string) (*reorderBuffer, error) {
+		spec := service.NewConfigSpec().Field(reorderField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newReorderBufferFromConfig(conf.Namespace("reorder"))
+	}
+
+	b, err := parse(`reorder: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, b)
+
+	b, err = parse(`reorder: { max_delay: 5s, max_logs: 100 }`)
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	assert.Equal(t, 5*time.Second, b.maxDelay)
+	assert.Equal(t, 100, b.maxLogs)
+
+	_, err = parse(`reorder: { max_delay: 5s, max_logs: 0 }`)
+	assert.Error(t, err)
+}
+
+func TestReorderBufferSortsByEventTime(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(seconds int, ip string) FirewallLog {
+		return FirewallLog{Timestamp: start.Add(time.Duration(seconds) * time.Second), SourceIP: ip}
+	}
+	ips := func(logs []FirewallLog) []string {
+		var ips []string
+		for _, log := range logs {
+			ips = append(ips, log.SourceIP)
+		}
+		return ips
+	}
+
+	b := &reorderBuffer{maxDelay: 5 * time.Second, maxLogs: 100}
+	now := start
+
+	// Nothing is released until a log 5s newer has been read
+	released := b.release([]FirewallLog{at(3, "c"), at(1, "a"), at(2, "b")}, now)
+	assert.Empty(t, released)
+	assert.Equal(t, 3, b.size())
+
+	released = b.release([]FirewallLog{at(7, "e"), at(1, "a2"), at(4, "d")}, now)
+	assert.Equal(t, []string{"a", "a2", "b"}, ips(released))
+	assert.Equal(t, 3, b.size())
+
+	// Logs held for max_delay are released along with older ones
+	released = b.release(nil, now.Add(5*time.Second))
+	assert.Equal(t, []string{"c", "d", "e"}, ips(released))
+	assert.Zero(t, b.size())
+}
+
+func TestReorderBufferOverflow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := &reorderBuffer{maxDelay: time.Minute, maxLogs: 2}
+
+	released := b.release([]FirewallLog{
+		{Timestamp: start.Add(2 * time.Second), SourceIP: "b"},
+		{Timestamp: start.Add(3 * time.Second), SourceIP: "c"},
+		{Timestamp: start.Add(time.Second), SourceIP: "a"},
+	}, start)
+	require.Len(t, released, 1)
+	assert.Equal(t, "a", released[0].SourceIP)
+	assert.Equal(t, 2, b.size())
+}
+
+func TestReorderBufferDisabled(t *testing.T) {
+	var b *reorderBuffer
+	logs := []FirewallLog{{SourceIP: "b"}, {SourceIP: "a"}}
+	assert.Equal(t, logs, b.release(logs, time.Now()))
+	assert.Zero(t, b.size())
+}
@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// replayDisabled are the detector fields removed for a replay: everything
+// that reaches Redis, alert channels or other external systems, or that
+// runs on the wall clock.
+var replayDisabled = []string{
+	"redis_config",
+	"secrets",
+	"http_enrichment",
+	"alerts",
+	"partitioning",
+	"distributed_locks",
+	"shutdown",
+	"async_emission",
+	"snapshot",
+	"consumption",
+	"backpressure",
+	"memory_budget",
+	"state_gc",
+	"replication",
+	"rate_limit",
+	"fault_injection",
+	"debug_endpoint",
+	"web_ui",
+	"health",
+	"status_endpoint",
+	"feedback",
+	"threshold_control",
+	"stix_export",
+	"active_response",
+	"feature_store",
+	"feast",
+	"audit",
+	"self_monitoring",
+	"drift",
+	"debug_sample",
+	"control",
+	"strict",
+	"validate_logs",
+	"clock_skew",
+	"baseline_cache",
+}
+
+// ReplayStats summarises a replay.
+type ReplayStats struct {
+	Logs      int
+	Results   int
+	Anomalies int
+}
+
+// Replay runs historical logs through the windowing and scoring of the first
+// firewall_anomaly_detector processor of a config. Logs are read as JSON
+// lines in event time order, and the clock follows the latest event time
+// rather than the wall clock, so windows complete as fast as logs are read
+// and results carry their original timestamps. Windows still open at the end
+// of the logs are emitted as final results. emit receives every result along
+// with whether it was routed to the anomaly topic. Alerts, Redis and every other
+// external system are disabled, while feature export files are still written
+// to build training sets from history.
+func Replay(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), logs io.Reader, emit func(msg *service.Message, anomaly bool) error) (ReplayStats, error) {
+	var stats ReplayStats
+
+	detector, err := newOfflineDetector(env, confYAML, lookupEnv)
+	if err != nil {
+		return stats, err
+	}
+	defer detector.Close(ctx)
+
+	// Feature vectors of replayed windows are only exported to files, as
+	// nothing drains the export topic
+	if detector.features != nil {
+		detector.features.topic = ""
+	}
+
+	var watermark time.Time
+	detector.clock = func() time.Time { return watermark }
+
+	anomalyTopic, _ := detector.topics()
+	emitResult := func(msg *service.Message) error {
+		topic, _ := msg.MetaGet("topic")
+		anomaly := topic == anomalyTopic
+		stats.Results++
+		if anomaly {
+			stats.Anomalies++
+		}
+		return emit(msg, anomaly)
+	}
+
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		item := strings.TrimSpace(scanner.Text())
+		if item == "" {
+			continue
+		}
+		stats.Logs++
+
+		log, ok := detector.parseLog(ctx, item)
+		if !ok {
+			continue
+		}
+		if log.Timestamp.After(watermark) {
+			watermark = log.Timestamp
+		}
+
+		msg, err := detector.processLog(ctx, log)
+		if err != nil {
+			return stats, err
+		}
+		if msg != nil {
+			if err := emitResult(msg); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	return stats, detector.flushRemaining(ctx, emitResult)
+}
+
+// newOfflineDetector builds the first firewall_anomaly_detector processor of
+// a config with the replayDisabled fields removed, for running it outside of
+// a pipeline.
+func newOfflineDetector(env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool)) (*FirewallAnomalyDetector, error) {
+	expanded, missing := expandEnv(string(confYAML), lookupEnv)
+	if len(missing) > 0 {
+		return nil, errors.New("environment variables not set: " + strings.Join(missing, ", "))
+	}
+
+	var fields map[string]interface{}
+	err := env.FullConfigSchema("", "").NewStreamConfigWalker().WalkComponentsYAML([]byte(expanded), func(w *service.WalkedComponent) error {
+		if fields != nil || w.ComponentType != "processor" || w.Name != "firewall_anomaly_detector" {
+			return nil
+		}
+		var err error
+		if fields, err = walkedDetectorFields(w); err == nil && fields == nil {
+			fields = map[string]interface{}{}
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		return nil, errors.New("config has no firewall_anomaly_detector processor")
+	}
+	for _, name := range replayDisabled {
+		delete(fields, name)
+	}
+	// Offline runs route nothing anywhere, and report the anomalies a dry
+	// run keeps on the normal topic
+	delete(fields, "dry_run")
+
+	conf, err := parseDetectorFields(env, fields)
+	if err != nil {
+		return nil, err
+	}
+	return newFirewallAnomalyDetector(conf, service.MockResources())
+}
+
+// flushRemaining scores the windows still open, in key order, as final
+// results.
+func (f *FirewallAnomalyDetector) flushRemaining(ctx context.Context, emit func(msg *service.Message) error) error {
+	remaining := f.drainWindows()
+	keys := make([]string, 0, len(remaining))
+	for key := range remaining {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		window := remaining[key]
+		source, _ := windowScope(key, window)
+		metricField, _ := f.metricFieldFor(source)
+		msg, err := f.scoreWindow(ctx, key, window, metricField, window.lastValue(), true)
+		if err != nil {
+			return err
+		}
+		if msg != nil {
+			if err := emit(msg); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
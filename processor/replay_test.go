@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const replayTestConfig = `
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        window_seconds: 60
+        max_lateness: 5m
+        redis_config:
+          password: ${REDIS_PASSWORD:unused}
+        alerts:
+          webhook:
+            url: http://localhost:1/alerts
+        sources:
+          fortinet.firewall:
+            metric: connection_count
+`
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var logs strings.Builder
+	for i := 0; i < 200; i++ {
+		count := 10
+		if i == 150 {
+			count = 5000
+		}
+		fmt.Fprintf(&logs, `{"timestamp":%q,"log_source":"fortinet.firewall","source_ip":"10.0.0.%d","connection_count":%d}`+"\n",
+			start.Add(time.Duration(i)*5*time.Second).Format(time.RFC3339), i%4, count)
+	}
+	logs.WriteString("not json\n\n")
+
+	var results []map[string]interface{}
+	stats, err := Replay(context.Background(), service.NewEnvironment(), []byte(replayTestConfig), os.LookupEnv, strings.NewReader(logs.String()), func(msg *service.Message, anomaly bool) error {
+		result, err := alertResult(msg)
+		require.NoError(t, err)
+		results = append(results, result)
+		return nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 201, stats.Logs)
+	assert.Equal(t, len(results), stats.Results)
+	require.NotEmpty(t, results)
+
+	// Logs years older than max_lateness are windowed in event time, and
+	// results keep their original timestamps. The 1000s of logs span 17
+	// minute windows, each emitted once.
+	require.Len(t, results, 17)
+	for i, result := range results {
+		windowStart := start.Add(time.Duration(i) * time.Minute)
+		assert.Equal(t, windowStart, result["window_start"])
+		assert.Equal(t, windowStart.Add(time.Minute), result["window_end"])
+		assert.Equal(t, "fortinet.firewall", result["log_source"])
+	}
+	assert.Equal(t, 5000.0, results[12]["features"].(map[string]float64)["max_value"])
+	assert.Equal(t, true, results[len(results)-1]["final"])
+}
+
+func TestReplayWithoutDetector(t *testing.T) {
+	_, err := Replay(context.Background(), service.NewEnvironment(), []byte(`
+pipeline:
+  processors:
+    - mapping: root = this
+`), os.LookupEnv, strings.NewReader(""), func(*service.Message, bool) error { return nil })
+	assert.Error(t, err)
+}
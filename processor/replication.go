@@ -0,0 +1,333 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func replicationField() *service.ConfigField {
+	return service.NewObjectField("replication",
+		service.NewBoolField("enabled").
+			Description("Whether to run in active/standby mode. The instance holding the lease is active and streams window state to Redis, the others replay it and take over when the lease expires.").
+			Default(false),
+		service.NewStringField("stream_key").
+			Description("Redis stream window state deltas are written to").
+			Default("firewall_anomaly_detector:replication"),
+		service.NewStringField("lease_key").
+			Description("Redis key holding the lease of the active instance").
+			Default("firewall_anomaly_detector:active"),
+		service.NewDurationField("lease_ttl").
+			Description("How long the active instance's lease lasts without renewal. Standbys take over after this period. Must be at least twice `interval`.").
+			Default("10s"),
+		service.NewDurationField("interval").
+			Description("How often deltas are published, the lease is renewed, and standbys replay").
+			Default("1s"),
+		service.NewIntField("max_len").
+			Description("Approximate maximum number of deltas retained in the stream").
+			Default(100000),
+	).
+		Description("Hot-standby replication of window state so that failover keeps mid-window aggregates").
+		Advanced()
+}
+
+const (
+	replicationOpUpsert = "upsert"
+	replicationOpDelete = "delete"
+)
+
+// replicationDelta is the state of one window after a change. Upserts carry
+// the whole window so that replaying any suffix of the stream converges.
+type replicationDelta struct {
+	Op     string      `json:"op"`
+	Key    string      `json:"key"`
+	Window *WindowData `json:"window,omitempty"`
+}
+
+// renewLeaseScript extends the lease only if it is still held by the caller.
+var renewLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type replicator struct {
+	client    *redis.Client
+	streamKey string
+	leaseKey  string
+	leaseTTL  time.Duration
+	interval  time.Duration
+	maxLen    int64
+	token     string
+
+	mut    sync.Mutex
+	active bool
+	dirty  map[string]struct{}
+	lastID string
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newReplicatorFromConfig(conf *service.ParsedConfig, client *redis.Client) (*replicator, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	r := &replicator{
+		client: client,
+		dirty:  make(map[string]struct{}),
+		lastID: "0",
+	}
+	if r.streamKey, err = conf.FieldString("stream_key"); err != nil {
+		return nil, err
+	}
+	if r.leaseKey, err = conf.FieldString("lease_key"); err != nil {
+		return nil, err
+	}
+	if r.leaseTTL, err = conf.FieldDuration("lease_ttl"); err != nil {
+		return nil, err
+	}
+	if r.interval, err = conf.FieldDuration("interval"); err != nil {
+		return nil, err
+	}
+	if r.interval <= 0 {
+		return nil, fmt.Errorf("replication.interval must be positive, got %v", r.interval)
+	}
+	// The lease has to outlive a missed renewal, or two instances end up
+	// active at once
+	if r.leaseTTL < 2*r.interval {
+		return nil, fmt.Errorf("replication.lease_ttl must be at least twice interval %v, got %v", r.interval, r.leaseTTL)
+	}
+	maxLen, err := conf.FieldInt("max_len")
+	if err != nil {
+		return nil, err
+	}
+	r.maxLen = int64(maxLen)
+	if r.token, err = lockToken(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// isActive reports whether this instance should consume and emit. Without
+// replication every instance is active.
+func (r *replicator) isActive() bool {
+	if r == nil {
+		return true
+	}
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.active
+}
+
+// markDirty records that a window changed and needs to be replicated.
+func (r *replicator) markDirty(windowKey string) {
+	if r == nil {
+		return
+	}
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.active {
+		r.dirty[windowKey] = struct{}{}
+	}
+}
+
+func (r *replicator) takeDirty() []string {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	keys := make([]string, 0, len(r.dirty))
+	for key := range r.dirty {
+		keys = append(keys, key)
+	}
+	r.dirty = make(map[string]struct{})
+	return keys
+}
+
+func (r *replicator) setActive(active bool) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.active = active
+}
+
+// holdLease acquires or renews the lease, returning whether it is held.
+func (r *replicator) holdLease(ctx context.Context) (bool, error) {
+	if r.isActive() {
+		renewed, err := renewLeaseScript.Run(ctx, r.client, []string{r.leaseKey}, r.token, r.leaseTTL.Milliseconds()).Int()
+		return renewed == 1, err
+	}
+	return r.client.SetNX(ctx, r.leaseKey, r.token, r.leaseTTL).Result()
+}
+
+func (f *FirewallAnomalyDetector) startReplication() {
+	r := f.replication
+	if r == nil {
+		return
+	}
+
+	r.shutdown = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			f.replicate(context.Background())
+			select {
+			case <-ticker.C:
+			case <-r.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// replicate runs one round of lease handling and either publishes deltas as
+// the active instance or replays them as a standby.
+func (f *FirewallAnomalyDetector) replicate(ctx context.Context) {
+	r := f.replication
+
+	wasActive := r.isActive()
+	held, err := r.holdLease(ctx)
+	if err != nil {
+		f.logger.Errorf("Failed to hold replication lease: %v", err)
+		return
+	}
+
+	switch {
+	case held && !wasActive:
+		// Catch up with everything the previous active instance published
+		// before taking over.
+		if err := f.replayDeltas(ctx); err != nil {
+			f.logger.Errorf("Failed to replay window state before promotion: %v", err)
+		}
+		r.setActive(true)
+		f.logger.Infof("Promoted to active instance")
+	case !held && wasActive:
+		r.setActive(false)
+		f.logger.Warnf("Lost replication lease, demoted to standby")
+	}
+
+	if held {
+		if err := f.publishDeltas(ctx); err != nil {
+			f.logger.Errorf("Failed to publish window state: %v", err)
+		}
+		return
+	}
+	if err := f.replayDeltas(ctx); err != nil {
+		f.logger.Errorf("Failed to replay window state: %v", err)
+	}
+}
+
+func (f *FirewallAnomalyDetector) publishDeltas(ctx context.Context) error {
+	r := f.replication
+	keys := r.takeDirty()
+	if len(keys) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, key := range keys {
+		delta := replicationDelta{Op: replicationOpDelete, Key: key}
+		if window := f.copyWindow(key); window != nil {
+			delta = replicationDelta{Op: replicationOpUpsert, Key: key, Window: window}
+		}
+		b, err := json.Marshal(delta)
+		if err != nil {
+			return err
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: r.streamKey,
+			MaxLen: r.maxLen,
+			Approx: true,
+			Values: map[string]interface{}{"delta": string(b)},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (f *FirewallAnomalyDetector) replayDeltas(ctx context.Context) error {
+	r := f.replication
+
+	for {
+		streams, err := r.client.XRead(ctx, &redis.XReadArgs{
+			Streams: []string{r.streamKey, r.lastID},
+			Count:   1000,
+			Block:   -1,
+		}).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		read := 0
+		for _, stream := range streams {
+			for _, entry := range stream.Messages {
+				read++
+				r.lastID = entry.ID
+
+				raw, _ := entry.Values["delta"].(string)
+				var delta replicationDelta
+				if err := json.Unmarshal([]byte(raw), &delta); err != nil {
+					f.logger.Warnf("Skipping unreadable replication delta %s: %v", entry.ID, err)
+					continue
+				}
+				f.applyDelta(delta)
+			}
+		}
+		if read == 0 {
+			return nil
+		}
+	}
+}
+
+// applyDelta replays a replicated window change.
+func (f *FirewallAnomalyDetector) applyDelta(delta replicationDelta) {
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	switch delta.Op {
+	case replicationOpUpsert:
+		if delta.Window != nil {
+			f.windows[delta.Key] = delta.Window
+		}
+	case replicationOpDelete:
+		delete(f.windows, delta.Key)
+	}
+}
+
+func (f *FirewallAnomalyDetector) stopReplication(ctx context.Context) {
+	r := f.replication
+	if r == nil || r.shutdown == nil {
+		return
+	}
+	close(r.shutdown)
+	<-r.done
+
+	if r.isActive() {
+		if err := f.publishDeltas(ctx); err != nil {
+			f.logger.Errorf("Failed to publish window state on shutdown: %v", err)
+		}
+		// Hand over promptly rather than waiting for the lease to expire.
+		if err := releaseLockScript.Run(ctx, r.client, []string{r.leaseKey}, r.token).Err(); err != nil {
+			f.logger.Warnf("Failed to release replication lease: %v", err)
+		}
+	}
+}
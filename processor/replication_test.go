@@ -0,0 +1,80 @@
+package processor
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicationDeltasReplay(t *testing.T) {
+	active := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+	}
+	standby := &FirewallAnomalyDetector{
+		windows: make(map[string]*WindowData),
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	active.updateWindow("fortinet.firewall", 100, "192.168.1.1", now)
+	active.updateWindow("fortinet.firewall", 150, "192.168.1.2", now.Add(time.Second))
+
+	// Deltas travel through the stream as JSON.
+	replay := func(delta replicationDelta) {
+		b, err := json.Marshal(delta)
+		require.NoError(t, err)
+
+		var decoded replicationDelta
+		require.NoError(t, json.Unmarshal(b, &decoded))
+		standby.applyDelta(decoded)
+	}
+
+	replay(replicationDelta{Op: replicationOpUpsert, Key: "fortinet.firewall", Window: active.copyWindow("fortinet.firewall")})
+	window := standby.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Equal(t, []float64{100, 150}, window.Values)
+	assert.Equal(t, 1, window.IPCounts["192.168.1.2"])
+
+	replay(replicationDelta{Op: replicationOpDelete, Key: "fortinet.firewall"})
+	assert.Nil(t, standby.getWindow("fortinet.firewall"))
+}
+
+func TestReplicationDisabledIsActive(t *testing.T) {
+	spec := service.NewConfigSpec().Field(replicationField())
+	conf, err := spec.ParseYAML(`replication: {}`, nil)
+	require.NoError(t, err)
+
+	replication, err := newReplicatorFromConfig(conf.Namespace("replication"), nil)
+	require.NoError(t, err)
+	assert.Nil(t, replication)
+	assert.True(t, replication.isActive())
+}
+
+func TestReplicationRejectsNonPositiveInterval(t *testing.T) {
+	spec := service.NewConfigSpec().Field(replicationField())
+	conf, err := spec.ParseYAML(`replication: { enabled: true, interval: 0s }`, nil)
+	require.NoError(t, err)
+
+	_, err = newReplicatorFromConfig(conf.Namespace("replication"), nil)
+	assert.ErrorContains(t, err, "replication.interval must be positive")
+}
+
+func TestReplicationRejectsShortLeaseTTL(t *testing.T) {
+	spec := service.NewConfigSpec().Field(replicationField())
+	for _, ttl := range []string{"0s", "1s", "1500ms"} {
+		conf, err := spec.ParseYAML(`replication: { enabled: true, interval: 1s, lease_ttl: `+ttl+` }`, nil)
+		require.NoError(t, err)
+
+		_, err = newReplicatorFromConfig(conf.Namespace("replication"), nil)
+		assert.ErrorContains(t, err, "replication.lease_ttl must be at least twice interval 1s", ttl)
+	}
+
+	conf, err := spec.ParseYAML(`replication: { enabled: true, interval: 1s, lease_ttl: 2s }`, nil)
+	require.NoError(t, err)
+	_, err = newReplicatorFromConfig(conf.Namespace("replication"), nil)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,164 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// vendorSource is the log source of a firewall vendor and the metric its
+// logs are scored on.
+type vendorSource struct {
+	source string
+	metric string
+}
+
+// scaffoldVendors are the vendors known to the config scaffolding.
+var scaffoldVendors = map[string]vendorSource{
+	"fortinet":   {"fortinet.firewall", "connection_count"},
+	"paloalto":   {"paloalto.firewall", "bytes_sent"},
+	"checkpoint": {"checkpoint.firewall", "bytes_recv"},
+	"cisco":      {"cisco.asa", "connection_count"},
+	"juniper":    {"juniper.srx", "bytes_sent"},
+	"sophos":     {"sophos.firewall", "connection_count"},
+}
+
+// ScaffoldVendors returns the names of the vendors known to Scaffold.
+func ScaffoldVendors() []string {
+	names := make([]string, 0, len(scaffoldVendors))
+	for name := range scaffoldVendors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ScaffoldOptions customises a generated pipeline config.
+type ScaffoldOptions struct {
+	// Vendors are the firewall vendors logs are collected from, by name or
+	// as log_source values for others.
+	Vendors []string
+	// Brokers are the Kafka or Redpanda seed brokers.
+	Brokers      []string
+	RedisAddress string
+	RedisKey     string
+	AnomalyTopic string
+	NormalTopic  string
+	Profile      string
+	// DefaultSource scores logs of unlisted sources rather than dropping
+	// them.
+	DefaultSource bool
+}
+
+// DefaultScaffoldOptions are the options of the init command.
+var DefaultScaffoldOptions = ScaffoldOptions{
+	Vendors:       []string{"fortinet", "paloalto"},
+	Brokers:       []string{"localhost:9092"},
+	RedisAddress:  "localhost:6379",
+	RedisKey:      "firewall_logs",
+	AnomalyTopic:  "firewall-anomalies",
+	NormalTopic:   "firewall-normal",
+	Profile:       profileNone,
+	DefaultSource: true,
+}
+
+// Scaffold returns a complete, commented pipeline config running the
+// detector: an input pacing it, the processor and a switch output routing
+// results to their Kafka topics.
+func Scaffold(opts ScaffoldOptions) (string, error) {
+	if len(opts.Vendors) == 0 && !opts.DefaultSource {
+		return "", fmt.Errorf("at least one vendor or the default source is required")
+	}
+	if len(opts.Brokers) == 0 {
+		return "", fmt.Errorf("at least one broker is required")
+	}
+	if _, exists := profiles[opts.Profile]; !exists {
+		return "", fmt.Errorf("unknown profile %s", opts.Profile)
+	}
+
+	brokers := make([]string, len(opts.Brokers))
+	for i, broker := range opts.Brokers {
+		brokers[i] = strconv.Quote(broker)
+	}
+	brokerList := "[ " + strings.Join(brokers, ", ") + " ]"
+
+	var b strings.Builder
+	w := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format+"\n", args...)
+	}
+
+	w("# Firewall anomaly detector pipeline.")
+	w("#")
+	w("# Check it with `redpanda-connect-plugin-example validate <file>` and run it")
+	w("# with `redpanda-connect-plugin-example run <file>`.")
+	w("")
+	w("# The detector reads firewall logs from the Redis list itself, up to")
+	w("# consumption.batch_size at a time, so this input only paces it by triggering")
+	w("# a read every interval.")
+	w("input:")
+	w("  generate:")
+	w("    interval: 1s")
+	w("    mapping: root = {}")
+	w("")
+	w("pipeline:")
+	w("  # Windows live in the processor, a single thread keeps them in one place")
+	w("  threads: 1")
+	w("  processors:")
+	w("    - firewall_anomaly_detector:")
+	w("        # Preset of window size and memory limits: none, datacenter_high_volume,")
+	w("        # branch_office or lab")
+	w("        profile: %s", opts.Profile)
+	w("        model_path: /etc/plugin/model.pkl")
+	w("        # Windows scoring at least this are anomalies; tune it with")
+	w("        # `redpanda-connect-plugin-example tune` once labeled windows exist")
+	w("        score_threshold: 0.7")
+	w("        redis_config:")
+	w("          address: %s", strconv.Quote(opts.RedisAddress))
+	w("          # Resolved from the environment, never commit credentials")
+	w("          password: ${REDIS_PASSWORD:}")
+	w("          key: %s", strconv.Quote(opts.RedisKey))
+	w("        kafka_config:")
+	w("          brokers: %s", brokerList)
+	w("          anomaly_topic: %s", strconv.Quote(opts.AnomalyTopic))
+	w("          normal_topic: %s", strconv.Quote(opts.NormalTopic))
+	if len(opts.Vendors) > 0 {
+		w("        # Sources by log_source, with the metric their windows are scored on:")
+		w("        # connection_count, bytes_sent or bytes_recv")
+		w("        sources:")
+		for _, vendor := range opts.Vendors {
+			vs, known := scaffoldVendors[vendor]
+			if !known {
+				vs = vendorSource{source: vendor, metric: "connection_count"}
+			}
+			w("          %s:", strconv.Quote(vs.source))
+			w("            metric: %s", vs.metric)
+		}
+	}
+	if opts.DefaultSource {
+		w("        # Logs of sources not listed above are scored on their connection")
+		w("        # count rather than dropped")
+		w("        default_source:")
+		w("          metric: connection_count")
+	}
+	w("")
+	w("# Results carry the topic they are routed to in their `topic` metadata.")
+	w("output:")
+	w("  switch:")
+	w("    cases:")
+	w("      # Anomalies, and detector events such as silent sources and model drift")
+	w("      - check: '@topic == %s'", strconv.Quote(opts.AnomalyTopic))
+	w("        output:")
+	w("          kafka_franz:")
+	w("            seed_brokers: %s", brokerList)
+	w("            topic: %s", strconv.Quote(opts.AnomalyTopic))
+	w("            key: ${! json(\"log_source\") }")
+	w("      # Normal windows, plus debug samples, feature vectors and rejected logs")
+	w("      # once enabled")
+	w("      - output:")
+	w("          kafka_franz:")
+	w("            seed_brokers: %s", brokerList)
+	w("            topic: ${! @topic }")
+	w("            key: ${! json(\"log_source\") }")
+	return b.String(), nil
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	// Components of the generated pipeline
+	_ "github.com/redpanda-data/benthos/v4/public/components/pure"
+	_ "github.com/redpanda-data/connect/v4/public/components/kafka"
+)
+
+func TestScaffold(t *testing.T) {
+	opts := DefaultScaffoldOptions
+	opts.Vendors = []string{"fortinet", "checkpoint", "acme.firewall"}
+	opts.Brokers = []string{"kafka1:9092", "kafka2:9092"}
+	opts.Profile = profileBranchOffice
+
+	conf, err := Scaffold(opts)
+	require.NoError(t, err)
+
+	issues, err := ValidateConfig(service.NewEnvironment(), []byte(conf), func(string) (string, bool) { return "", false })
+	require.NoError(t, err)
+	for _, issue := range issues {
+		// The model is mounted where the detector runs
+		if strings.HasSuffix(issue.Path, ".model_path") {
+			continue
+		}
+		assert.NotEqual(t, IssueError, issue.Severity, issue.String())
+	}
+
+	assert.Contains(t, conf, `"checkpoint.firewall":`+"\n            metric: bytes_recv")
+	assert.Contains(t, conf, `"acme.firewall":`+"\n            metric: connection_count")
+	assert.Contains(t, conf, `seed_brokers: [ "kafka1:9092", "kafka2:9092" ]`)
+	assert.Contains(t, conf, `check: '@topic == "firewall-anomalies"'`)
+}
+
+func TestScaffoldRuns(t *testing.T) {
+	conf, err := Scaffold(DefaultScaffoldOptions)
+	require.NoError(t, err)
+
+	// The generated pipeline builds with its components
+	builder := service.NewStreamBuilder()
+	require.NoError(t, builder.SetYAML(os.Expand(conf, func(string) string { return "" })))
+}
+
+func TestScaffoldRequiresSources(t *testing.T) {
+	opts := DefaultScaffoldOptions
+	opts.Vendors, opts.DefaultSource = nil, false
+	_, err := Scaffold(opts)
+	assert.Error(t, err)
+
+	opts = DefaultScaffoldOptions
+	opts.Profile = "huge"
+	_, err = Scaffold(opts)
+	assert.Error(t, err)
+}
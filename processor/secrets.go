@@ -0,0 +1,201 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const vaultSecretPrefix = "vault:"
+
+func secretsField() *service.ConfigField {
+	return service.NewObjectField("secrets",
+		service.NewObjectField("vault",
+			service.NewStringField("address").
+				Description("Address of the Vault server, e.g. `https://vault:8200`. Defaults to `VAULT_ADDR`.").
+				Default(""),
+			service.NewStringField("token").
+				Description("Vault token. Defaults to `VAULT_TOKEN`.").
+				Secret().
+				Default(""),
+			service.NewStringField("namespace").
+				Description("Vault Enterprise namespace").
+				Default(""),
+			service.NewDurationField("timeout").
+				Description("Maximum time to wait for a secret lookup").
+				Default("5s"),
+		).
+			Description("Vault server that `vault:<path>#<key>` credential references are read from"),
+	).
+		Description("Resolution of credential fields such as `redis_config.password`. A credential is either a literal, a `${ENV_VAR}` reference resolved from the environment of the detector, or a `vault:<path>#<key>` reference read from Vault when the processor starts.").
+		Advanced()
+}
+
+// envReference matches `${NAME}` and `${NAME:default}` references, following
+// the syntax of config file interpolation.
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_.]*)(?::([^}]*))?\}`)
+
+// secretResolver resolves references in credential fields so that secrets
+// don't have to live in plaintext config.
+type secretResolver struct {
+	client         *http.Client
+	vaultAddress   string
+	vaultToken     string
+	vaultNamespace string
+
+	lookupEnv func(name string) (string, bool)
+}
+
+func newSecretResolverFromConfig(conf *service.ParsedConfig) (*secretResolver, error) {
+	address, err := conf.FieldString("vault", "address")
+	if err != nil {
+		return nil, err
+	}
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+
+	token, err := conf.FieldString("vault", "token")
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+
+	namespace, err := conf.FieldString("vault", "namespace")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := conf.FieldDuration("vault", "timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretResolver{
+		client:         &http.Client{Timeout: timeout},
+		vaultAddress:   strings.TrimSuffix(address, "/"),
+		vaultToken:     token,
+		vaultNamespace: namespace,
+		lookupEnv:      os.LookupEnv,
+	}, nil
+}
+
+// resolve returns the value of a credential field. `${ENV_VAR}` references
+// are expanded from the environment of the detector and a
+// `vault:<path>#<key>` value is read from Vault. Anything else is returned as
+// is.
+func (r *secretResolver) resolve(ctx context.Context, field, value string) (string, error) {
+	if strings.HasPrefix(value, vaultSecretPrefix) {
+		secret, err := r.readVault(ctx, strings.TrimPrefix(value, vaultSecretPrefix))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", field, err)
+		}
+		return secret, nil
+	}
+
+	resolved, missing := expandEnv(value, r.lookupEnv)
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%s: environment variable %s is not set", field, strings.Join(missing, ", "))
+	}
+	return resolved, nil
+}
+
+// expandEnv expands the `${NAME}` and `${NAME:default}` references of a
+// value, returning the names of referenced variables that are not set and
+// have no default.
+func expandEnv(value string, lookupEnv func(name string) (string, bool)) (string, []string) {
+	var missing []string
+	expanded := envReference.ReplaceAllStringFunc(value, func(ref string) string {
+		match := envReference.FindStringSubmatch(ref)
+		v, ok := lookupEnv(match[1])
+		if v == "" && strings.Contains(ref, ":") {
+			return match[2]
+		}
+		if !ok {
+			missing = append(missing, match[1])
+		}
+		return v
+	})
+	return expanded, missing
+}
+
+// readVault reads a key of a Vault secret. Both KV version 1 and version 2
+// responses are understood; for version 2 the path includes `data/`, e.g.
+// `secret/data/redis#password`.
+func (r *secretResolver) readVault(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("vault reference %q is not of the form vault:<path>#<key>", ref)
+	}
+	if r.vaultAddress == "" {
+		return "", fmt.Errorf("vault reference %q needs secrets.vault.address or VAULT_ADDR", ref)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.vaultAddress+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", r.vaultToken)
+	if r.vaultNamespace != "" {
+		req.Header.Set("X-Vault-Namespace", r.vaultNamespace)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("vault returned status %d for %s: %s", resp.StatusCode, path, strings.TrimSpace(string(body)))
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&secret); err != nil {
+		return "", err
+	}
+
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, isMetadata := data["metadata"]; isMetadata {
+			data = nested
+		}
+	}
+	value, exists := data[key]
+	if !exists {
+		return "", fmt.Errorf("vault secret %s has no key %s", path, key)
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s key %s is not a string", path, key)
+	}
+	return s, nil
+}
+
+// resolveCredential reads an optional credential field and resolves it.
+func (r *secretResolver) resolveCredential(conf *service.ParsedConfig, path ...string) (string, error) {
+	if !conf.Contains(path...) {
+		return "", nil
+	}
+	value, err := conf.FieldString(path...)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.client.Timeout+time.Second)
+	defer cancel()
+	return r.resolve(ctx, strings.Join(path, "."), value)
+}
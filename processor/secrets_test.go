@@ -0,0 +1,94 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSecretResolver(t *testing.T, yaml string, env map[string]string) *secretResolver {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(secretsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	r, err := newSecretResolverFromConfig(conf.Namespace("secrets"))
+	require.NoError(t, err)
+	r.lookupEnv = func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	return r
+}
+
+func TestSecretResolverEnv(t *testing.T) {
+	r := newTestSecretResolver(t, `secrets: {}`, map[string]string{"REDIS_PASSWORD": "hunter2"})
+
+	v, err := r.resolve(context.Background(), "redis_config.password", "${REDIS_PASSWORD}")
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", v)
+
+	v, err = r.resolve(context.Background(), "redis_config.password", "plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", v)
+
+	v, err = r.resolve(context.Background(), "redis_config.password", "${MISSING:fallback}")
+	require.NoError(t, err)
+	assert.Equal(t, "fallback", v)
+
+	_, err = r.resolve(context.Background(), "redis_config.password", "${MISSING}")
+	assert.ErrorContains(t, err, "redis_config.password: environment variable MISSING is not set")
+}
+
+func TestSecretResolverVault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/redis":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"from-kv2"},"metadata":{"version":3}}}`))
+		case "/v1/kv/redis":
+			_, _ = w.Write([]byte(`{"data":{"password":"from-kv1"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	r := newTestSecretResolver(t, `
+secrets:
+  vault:
+    address: `+server.URL+`
+    token: s.token
+`, nil)
+
+	v, err := r.resolve(context.Background(), "redis_config.password", "vault:secret/data/redis#password")
+	require.NoError(t, err)
+	assert.Equal(t, "from-kv2", v)
+
+	v, err = r.resolve(context.Background(), "redis_config.password", "vault:kv/redis#password")
+	require.NoError(t, err)
+	assert.Equal(t, "from-kv1", v)
+
+	_, err = r.resolve(context.Background(), "redis_config.password", "vault:kv/redis#username")
+	assert.ErrorContains(t, err, "has no key username")
+
+	_, err = r.resolve(context.Background(), "redis_config.password", "vault:kv/missing#password")
+	assert.ErrorContains(t, err, "status 404")
+
+	_, err = r.resolve(context.Background(), "redis_config.password", "vault:kv/redis")
+	assert.ErrorContains(t, err, "vault:<path>#<key>")
+
+	r.vaultToken = "wrong"
+	_, err = r.resolve(context.Background(), "redis_config.password", "vault:kv/redis#password")
+	assert.ErrorContains(t, err, "permission denied")
+}
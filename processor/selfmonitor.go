@@ -0,0 +1,214 @@
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	reasonSourceSilent     = "source_silent"
+	reasonDetectorFlatline = "detector_flatline"
+)
+
+func selfMonitoringField() *service.ConfigField {
+	return service.NewObjectField("self_monitoring",
+		service.NewIntField("silent_windows").
+			Description("Emit a `source_silent` event when a configured log source produces no logs for this many windows. Zero disables the check.").
+			Default(0),
+		service.NewIntField("flatline_windows").
+			Description("Emit a `detector_flatline` warning when this many consecutive windows of a source score zero. Zero disables the check.").
+			Default(0),
+	).
+		Description("Meta-monitoring of the detector, as sensor outages are themselves security events").
+		Advanced()
+}
+
+// selfMonitor detects log sources that went silent and sources whose scores
+// flatlined at zero.
+type selfMonitor struct {
+	silentWindows   int
+	flatlineWindows int
+
+	// windowLength returns the window duration of a source. It defaults to
+	// the configured durations and is replaced by the detector so that
+	// sources added at runtime are covered.
+	windowLength func(source string) time.Duration
+
+	now func() time.Time
+
+	mut       sync.Mutex
+	lastSeen  map[string]time.Time
+	silent    map[string]bool
+	zeroRuns  map[string]int
+	flatlined map[string]bool
+	pending   []map[string]interface{}
+}
+
+func newSelfMonitorFromConfig(conf *service.ParsedConfig, windowLengths map[string]time.Duration) (*selfMonitor, error) {
+	silentWindows, err := conf.FieldInt("silent_windows")
+	if err != nil {
+		return nil, err
+	}
+	flatlineWindows, err := conf.FieldInt("flatline_windows")
+	if err != nil {
+		return nil, err
+	}
+	if silentWindows <= 0 && flatlineWindows <= 0 {
+		return nil, nil
+	}
+
+	m := &selfMonitor{
+		silentWindows: silentWindows,
+		windowLength: func(source string) time.Duration {
+			return windowLengths[source]
+		},
+		flatlineWindows: flatlineWindows,
+		now:             time.Now,
+		lastSeen:        make(map[string]time.Time, len(windowLengths)),
+		silent:          make(map[string]bool),
+		zeroRuns:        make(map[string]int),
+		flatlined:       make(map[string]bool),
+	}
+
+	// Sources that never produce a log are silent from the start, except for
+	// the default source which only sees logs when a source is unmatched
+	started := m.now()
+	for source := range windowLengths {
+		if source == defaultSourceKey {
+			continue
+		}
+		m.lastSeen[source] = started
+	}
+	return m, nil
+}
+
+// observeLog records that a source is producing logs.
+func (m *selfMonitor) observeLog(source string) {
+	if m == nil {
+		return
+	}
+	m.mut.Lock()
+	m.lastSeen[source] = m.now()
+	delete(m.silent, source)
+	m.mut.Unlock()
+}
+
+// observeScore records the score of a window, queueing a flatline warning
+// once a source has scored zero for too many consecutive windows.
+func (m *selfMonitor) observeScore(source string, score float64) {
+	if m == nil || m.flatlineWindows <= 0 {
+		return
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if score != 0 {
+		delete(m.zeroRuns, source)
+		delete(m.flatlined, source)
+		return
+	}
+	m.zeroRuns[source]++
+	if m.zeroRuns[source] < m.flatlineWindows || m.flatlined[source] {
+		return
+	}
+	m.flatlined[source] = true
+
+	now := m.now()
+	m.pending = append(m.pending, map[string]interface{}{
+		"timestamp":        now,
+		"log_source":       source,
+		"window_end":       now,
+		"reason":           reasonDetectorFlatline,
+		"severity":         "warning",
+		"is_anomaly":       false,
+		"flatline_windows": m.zeroRuns[source],
+	})
+}
+
+// events returns the queued flatline warnings and a `source_silent` event for
+// every owned source that newly went silent.
+func (m *selfMonitor) events(owns func(source string) bool) []map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	events := m.pending
+	m.pending = nil
+	if m.silentWindows <= 0 {
+		return events
+	}
+
+	now := m.now()
+	sources := make([]string, 0, len(m.lastSeen))
+	for source := range m.lastSeen {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		lastSeen := m.lastSeen[source]
+		silentAfter := time.Duration(m.silentWindows) * m.windowLength(source)
+		if m.silent[source] || now.Sub(lastSeen) < silentAfter || !owns(source) {
+			continue
+		}
+		m.silent[source] = true
+		events = append(events, map[string]interface{}{
+			"timestamp":      now,
+			"log_source":     source,
+			"window_start":   lastSeen,
+			"window_end":     now,
+			"reason":         reasonSourceSilent,
+			"severity":       "critical",
+			"is_anomaly":     true,
+			"anomaly_score":  1.0,
+			"last_seen":      lastSeen,
+			"silent_seconds": int(now.Sub(lastSeen).Seconds()),
+		})
+	}
+	return events
+}
+
+// selfMonitoringEvents builds the detector health events to emit alongside
+// window results. Silent sources are security events and alert.
+func (f *FirewallAnomalyDetector) selfMonitoringEvents(ctx context.Context) []*service.Message {
+	events := f.selfMonitor.events(f.partitioner.owns)
+
+	msgs := make([]*service.Message, 0, len(events))
+	for _, event := range events {
+		if event["reason"] == reasonSourceSilent {
+			f.logger.Warnf("Log source %v has been silent since %v", event["log_source"], event["last_seen"])
+			msgs = append(msgs, f.detectorEvent(ctx, event, true))
+		} else {
+			f.logger.Warnf("Scores of log source %v flatlined at zero for %v windows", event["log_source"], event["flatline_windows"])
+			msgs = append(msgs, f.detectorEvent(ctx, event, false))
+		}
+	}
+	return msgs
+}
+
+// detectorEvent builds a message routed to the anomaly topic for an event
+// about the detector itself, optionally sending it to the alert channels.
+// Dry runs route events to the normal topic without alerting.
+func (f *FirewallAnomalyDetector) detectorEvent(ctx context.Context, event map[string]interface{}, alert bool) *service.Message {
+	msg := service.NewMessage(nil)
+	anomalyTopic, normalTopic := f.topics()
+	if f.dryRun {
+		event["dry_run"] = true
+		anomalyTopic, alert = normalTopic, false
+	}
+	msg.SetStructured(event)
+	msg.MetaSet("topic", anomalyTopic)
+	if reason, ok := event["reason"].(string); ok {
+		msg.MetaSet("reason", reason)
+	}
+	if alert {
+		f.alerts.Dispatch(ctx, msg)
+	}
+	return msg
+}
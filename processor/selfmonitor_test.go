@@ -0,0 +1,97 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSelfMonitor(t *testing.T, yaml string, now *time.Time) *selfMonitor {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(selfMonitoringField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	m, err := newSelfMonitorFromConfig(conf.Namespace("self_monitoring"), map[string]time.Duration{
+		"fortinet.firewall": time.Minute,
+		"paloalto.firewall": time.Minute,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, m)
+
+	m.now = func() time.Time { return *now }
+	for source := range m.lastSeen {
+		m.lastSeen[source] = *now
+	}
+	return m
+}
+
+func ownsAll(string) bool { return true }
+
+func TestSelfMonitorSilentSources(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	m := newTestSelfMonitor(t, `self_monitoring: { silent_windows: 3 }`, &now)
+
+	now = now.Add(2 * time.Minute)
+	m.observeLog("fortinet.firewall")
+	assert.Empty(t, m.events(ownsAll))
+
+	now = now.Add(90 * time.Second)
+	events := m.events(ownsAll)
+	require.Len(t, events, 1)
+	assert.Equal(t, "paloalto.firewall", events[0]["log_source"])
+	assert.Equal(t, reasonSourceSilent, events[0]["reason"])
+	assert.Equal(t, 210, events[0]["silent_seconds"])
+
+	// Silence is reported once until the source recovers
+	now = now.Add(2 * time.Minute)
+	events = m.events(ownsAll)
+	require.Len(t, events, 1)
+	assert.Equal(t, "fortinet.firewall", events[0]["log_source"])
+	assert.Empty(t, m.events(ownsAll))
+
+	m.observeLog("paloalto.firewall")
+	now = now.Add(4 * time.Minute)
+	assert.Len(t, m.events(ownsAll), 1)
+
+	// Sources owned by other replicas are reported there
+	now = now.Add(time.Hour)
+	m.observeLog("paloalto.firewall")
+	now = now.Add(time.Hour)
+	assert.Empty(t, m.events(func(string) bool { return false }))
+}
+
+func TestSelfMonitorFlatline(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	m := newTestSelfMonitor(t, `self_monitoring: { flatline_windows: 3 }`, &now)
+
+	m.observeScore("fortinet.firewall", 0)
+	m.observeScore("fortinet.firewall", 0)
+	m.observeScore("fortinet.firewall", 0.2)
+	m.observeScore("fortinet.firewall", 0)
+	m.observeScore("fortinet.firewall", 0)
+	assert.Empty(t, m.events(ownsAll))
+
+	m.observeScore("fortinet.firewall", 0)
+	m.observeScore("fortinet.firewall", 0)
+	events := m.events(ownsAll)
+	require.Len(t, events, 1)
+	assert.Equal(t, reasonDetectorFlatline, events[0]["reason"])
+	assert.Equal(t, 3, events[0]["flatline_windows"])
+	assert.Empty(t, m.events(ownsAll))
+}
+
+func TestSelfMonitorDisabled(t *testing.T) {
+	spec := service.NewConfigSpec().Field(selfMonitoringField())
+	conf, err := spec.ParseYAML(`{}`, nil)
+	require.NoError(t, err)
+
+	m, err := newSelfMonitorFromConfig(conf.Namespace("self_monitoring"), nil)
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	assert.Empty(t, m.events(ownsAll))
+}
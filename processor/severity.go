@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Canonical severities vendor severities are normalised to, in increasing
+// order.
+const (
+	severityUnknown  = "unknown"
+	severityInfo     = "info"
+	severityLow      = "low"
+	severityMedium   = "medium"
+	severityHigh     = "high"
+	severityCritical = "critical"
+)
+
+// severityRanks orders the canonical severities.
+var severityRanks = map[string]int{
+	severityUnknown:  0,
+	severityInfo:     1,
+	severityLow:      2,
+	severityMedium:   3,
+	severityHigh:     4,
+	severityCritical: 5,
+}
+
+// vendorSeverities maps the severities logged by Fortinet (syslog level
+// names), Palo Alto Networks and Cisco ASA (syslog level numbers) to the
+// canonical scale. Keys are lower case.
+var vendorSeverities = map[string]string{
+	// Canonical names map to themselves
+	severityInfo:     severityInfo,
+	severityLow:      severityLow,
+	severityMedium:   severityMedium,
+	severityHigh:     severityHigh,
+	severityCritical: severityCritical,
+
+	// Fortinet
+	"emergency":   severityCritical,
+	"alert":       severityCritical,
+	"error":       severityHigh,
+	"warning":     severityMedium,
+	"notice":      severityLow,
+	"information": severityInfo,
+	"debug":       severityInfo,
+
+	// Palo Alto Networks
+	"informational": severityInfo,
+
+	// Cisco ASA
+	"0": severityCritical,
+	"1": severityCritical,
+	"2": severityCritical,
+	"3": severityHigh,
+	"4": severityMedium,
+	"5": severityLow,
+	"6": severityInfo,
+	"7": severityInfo,
+}
+
+func severityField() *service.ConfigField {
+	return service.NewObjectField("severity",
+		service.NewStringMapField("map").
+			Description("Vendor severities mapped to `info`, `low`, `medium`, `high` or `critical`, extending and overriding the built-in table of Fortinet, Palo Alto Networks and Cisco ASA severities. Keys are case insensitive.").
+			Default(map[string]interface{}{}),
+		service.NewStringEnumField("high", severityLow, severityMedium, severityHigh, severityCritical).
+			Description("Lowest canonical severity counted by the `high_severity_ratio` feature").
+			Default(severityHigh),
+	).
+		Description("Normalisation of the `severity` of logs to a canonical scale, reported in results and usable as the `high_severity_ratio` feature").
+		Advanced()
+}
+
+func sourceSeverityMapField() *service.ConfigField {
+	return service.NewStringMapField("severity_map").
+		Description("Severities of this source mapped to canonical severities, overriding `severity.map`").
+		Optional()
+}
+
+// severityNormaliser maps vendor severities to canonical ones.
+type severityNormaliser struct {
+	global  map[string]string
+	sources map[string]map[string]string // `sources` key -> overrides
+	high    int
+}
+
+func newSeverityNormaliserFromConfig(conf *service.ParsedConfig) (*severityNormaliser, error) {
+	n := &severityNormaliser{sources: make(map[string]map[string]string)}
+
+	mapping, err := conf.FieldStringMap("map")
+	if err != nil {
+		return nil, err
+	}
+	if n.global, err = parseSeverityMap(mapping); err != nil {
+		return nil, fmt.Errorf("severity.map: %w", err)
+	}
+	high, err := conf.FieldString("high")
+	if err != nil {
+		return nil, err
+	}
+	n.high = severityRanks[high]
+	return n, nil
+}
+
+// parseSeverityMap validates a mapping of vendor severities, lower casing its
+// keys.
+func parseSeverityMap(mapping map[string]string) (map[string]string, error) {
+	parsed := make(map[string]string, len(mapping))
+	for vendor, canonical := range mapping {
+		if _, ok := severityRanks[canonical]; !ok || canonical == severityUnknown {
+			return nil, fmt.Errorf("severity %s maps to %s, which is not one of info, low, medium, high or critical", vendor, canonical)
+		}
+		parsed[strings.ToLower(strings.TrimSpace(vendor))] = canonical
+	}
+	return parsed, nil
+}
+
+// parseSourceSeverities adds the severity overrides of a source.
+func (n *severityNormaliser) parseSourceSeverities(source string, conf *service.ParsedConfig) error {
+	if !conf.Contains("severity_map") {
+		return nil
+	}
+	mapping, err := conf.FieldStringMap("severity_map")
+	if err != nil {
+		return err
+	}
+	parsed, err := parseSeverityMap(mapping)
+	if err != nil {
+		return fmt.Errorf("source %s: %w", source, err)
+	}
+	n.sources[source] = parsed
+	return nil
+}
+
+// normalise returns the canonical severity of a vendor severity logged by a
+// source, unknown when it is missing or not mapped.
+func (n *severityNormaliser) normalise(sourceKey, severity string) string {
+	key := strings.ToLower(strings.TrimSpace(severity))
+	if key == "" {
+		return severityUnknown
+	}
+	if n != nil {
+		if canonical, ok := n.sources[sourceKey][key]; ok {
+			return canonical
+		}
+		if canonical, ok := n.global[key]; ok {
+			return canonical
+		}
+	}
+	if canonical, ok := vendorSeverities[key]; ok {
+		return canonical
+	}
+	return severityUnknown
+}
+
+// highSeverityRatio returns the share of the logs of a window at or above
+// the high severity.
+func (n *severityNormaliser) highSeverityRatio(window *WindowData) float64 {
+	high := severityRanks[severityHigh]
+	if n != nil {
+		high = n.high
+	}
+
+	var total, count int
+	for severity, logs := range window.Severities {
+		total += logs
+		if severityRanks[severity] >= high {
+			count += logs
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total)
+}
+
+// maxSeverity returns the highest canonical severity logged in a window, or
+// an empty string when it has no severities.
+func maxSeverity(window *WindowData) string {
+	max := ""
+	for severity := range window.Severities {
+		if max == "" || severityRanks[severity] > severityRanks[max] {
+			max = severity
+		}
+	}
+	return max
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverityConfig(t *testing.T) {
+	spec := service.NewConfigSpec().
+		Field(severityField()).
+		Field(service.NewObjectMapField("sources", sourceSeverityMapField()))
+	parse := func(yaml string) (*severityNormaliser, error) {
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		n, err := newSeverityNormaliserFromConfig(conf.Namespace("severity"))
+		if err != nil {
+			return nil, err
+		}
+		sources, err := conf.FieldObjectMap("sources")
+		require.NoError(t, err)
+		for source, sourceConf := range sources {
+			if err := n.parseSourceSeverities(source, sourceConf); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	}
+
+	n, err := parse(`{}`)
+	require.NoError(t, err)
+	assert.Equal(t, severityRanks[severityHigh], n.high)
+	assert.Empty(t, n.global)
+
+	n, err = parse(`
+severity: { map: { Threat: critical }, high: medium }
+sources:
+  cisco.asa: { severity_map: { "4": high } }
+`)
+	require.NoError(t, err)
+	assert.Equal(t, severityRanks[severityMedium], n.high)
+	assert.Equal(t, map[string]string{"threat": severityCritical}, n.global)
+	assert.Equal(t, map[string]string{"4": severityHigh}, n.sources["cisco.asa"])
+
+	_, err = parse(`severity: { map: { threat: severe } }`)
+	assert.ErrorContains(t, err, "severity threat maps to severe")
+
+	_, err = parse(`sources: { cisco.asa: { severity_map: { "4": unknown } } }`)
+	assert.ErrorContains(t, err, "source cisco.asa")
+}
+
+func TestNormaliseSeverity(t *testing.T) {
+	n := &severityNormaliser{
+		global:  map[string]string{"threat": severityCritical, "warning": severityHigh},
+		sources: map[string]map[string]string{"cisco.asa": {"4": severityHigh}},
+		high:    severityRanks[severityHigh],
+	}
+
+	// Built-in vendor severities, matched case insensitively
+	assert.Equal(t, severityMedium, n.normalise("fortinet.firewall", "Medium"))
+	assert.Equal(t, severityInfo, n.normalise("paloalto.firewall", "Informational"))
+	assert.Equal(t, severityLow, n.normalise("fortinet.firewall", "notice"))
+	assert.Equal(t, severityMedium, n.normalise("cisco.ngfw", "4"))
+
+	// Configured severities override the built-in table, and those of a
+	// source override the global ones
+	assert.Equal(t, severityHigh, n.normalise("fortinet.firewall", "WARNING"))
+	assert.Equal(t, severityCritical, n.normalise("fortinet.firewall", "threat"))
+	assert.Equal(t, severityHigh, n.normalise("cisco.asa", "4"))
+
+	assert.Equal(t, severityUnknown, n.normalise("fortinet.firewall", ""))
+	assert.Equal(t, severityUnknown, n.normalise("fortinet.firewall", "whatever"))
+
+	// Without a normaliser only the built-in table applies
+	var none *severityNormaliser
+	assert.Equal(t, severityMedium, none.normalise("fortinet.firewall", "warning"))
+}
+
+func TestHighSeverityRatio(t *testing.T) {
+	window := &WindowData{Severities: map[string]int{
+		severityInfo:     6,
+		severityHigh:     3,
+		severityCritical: 1,
+	}}
+	n := &severityNormaliser{high: severityRanks[severityHigh]}
+	assert.InDelta(t, 0.4, n.highSeverityRatio(window), 1e-9)
+	n.high = severityRanks[severityCritical]
+	assert.InDelta(t, 0.1, n.highSeverityRatio(window), 1e-9)
+	assert.Equal(t, severityCritical, maxSeverity(window))
+
+	assert.Zero(t, n.highSeverityRatio(&WindowData{}))
+	assert.Empty(t, maxSeverity(&WindowData{}))
+}
+
+func TestWindowSeverities(t *testing.T) {
+	mgr := service.MockResources()
+	f := &FirewallAnomalyDetector{
+		logger:         mgr.Logger(),
+		windowSeconds:  60,
+		windows:        make(map[string]*WindowData),
+		sources:        map[string]string{"fortinet.firewall": "connection_count"},
+		sourceFeatures: map[string]featureSet{"fortinet.firewall": 1<<featureMeanValue | 1<<featureHighSeverityRatio},
+		sourceMatches:  &sync.Map{},
+	}
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	for _, severity := range []string{"information", "notice", "error", "critical"} {
+		log := FirewallLog{LogSource: "fortinet.firewall", SourceIP: "10.0.0.1", ConnectionCount: 1, Severity: severity, Timestamp: now}
+		_, err := f.processLog(ctx, log)
+		require.NoError(t, err)
+	}
+
+	window := f.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Equal(t, map[string]int{severityInfo: 1, severityLow: 1, severityHigh: 1, severityCritical: 1}, window.Severities)
+	assert.Equal(t, map[string]float64{"mean_value": 1, "high_severity_ratio": 0.5}, f.extractFeatures(window).asMap())
+}
@@ -0,0 +1,191 @@
+package processor
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	shutdownModeDiscard = "discard"
+	shutdownModeFlush   = "flush"
+	shutdownModePersist = "persist"
+)
+
+func shutdownField() *service.ConfigField {
+	return service.NewObjectField("shutdown",
+		service.NewStringEnumField("mode", shutdownModeDiscard, shutdownModeFlush, shutdownModePersist).
+			Description("What happens to in-flight windows on shutdown. `flush` scores every non-empty window and writes the results, flagged `final: true`, to `output`. `persist` stores the windows in Redis and restores them on startup.").
+			Default(shutdownModeDiscard),
+		service.NewStringField("output").
+			Description("Name of the output resource final results are written to in `flush` mode").
+			Default(""),
+		service.NewStringField("state_key").
+			Description("Redis hash in-flight windows are persisted to in `persist` mode").
+			Default("firewall_anomaly_detector:windows"),
+	).
+		Description("Handling of in-flight windows when the processor is closed, e.g. on SIGTERM during a deploy")
+}
+
+type shutdownPolicy struct {
+	mode     string
+	output   string
+	stateKey string
+}
+
+func newShutdownPolicyFromConfig(conf *service.ParsedConfig) (*shutdownPolicy, error) {
+	p := &shutdownPolicy{}
+
+	var err error
+	if p.mode, err = conf.FieldString("mode"); err != nil {
+		return nil, err
+	}
+	if p.output, err = conf.FieldString("output"); err != nil {
+		return nil, err
+	}
+	if p.stateKey, err = conf.FieldString("state_key"); err != nil {
+		return nil, err
+	}
+	if p.mode == shutdownModeFlush && p.output == "" {
+		return nil, errors.New("shutdown mode flush requires an output resource")
+	}
+	return p, nil
+}
+
+// drainWindows removes and returns every non-empty window.
+func (f *FirewallAnomalyDetector) drainWindows() map[string]*WindowData {
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	drained := make(map[string]*WindowData, len(f.windows))
+	for key, window := range f.windows {
+		if len(window.Values) > 0 {
+			drained[key] = window
+		}
+	}
+	f.windows = make(map[string]*WindowData)
+	return drained
+}
+
+// shutdownWindows applies the shutdown policy to all in-flight windows.
+func (f *FirewallAnomalyDetector) shutdownWindows(ctx context.Context) error {
+	if f.shutdown == nil || f.shutdown.mode == shutdownModeDiscard || !f.replication.isActive() {
+		return nil
+	}
+
+	f.rehydrateAll(ctx)
+	windows := f.drainWindows()
+	if len(windows) == 0 {
+		return nil
+	}
+	for key := range windows {
+		f.replication.markDirty(key)
+	}
+
+	switch f.shutdown.mode {
+	case shutdownModeFlush:
+		return f.flushWindows(ctx, windows)
+	case shutdownModePersist:
+		return f.persistWindows(ctx, windows)
+	}
+	return nil
+}
+
+// flushWindows scores the given windows and writes their final results to
+// the configured output resource.
+func (f *FirewallAnomalyDetector) flushWindows(ctx context.Context, windows map[string]*WindowData) error {
+	var batch service.MessageBatch
+	for key, window := range windows {
+		metricValue := window.lastValue()
+		source, _ := windowScope(key, window)
+		metricField, _ := f.metricFieldFor(source)
+		msg, err := f.scoreWindow(ctx, key, window, metricField, metricValue, true)
+		if err != nil {
+			f.logger.Errorf("Failed to score window %s on shutdown: %v", key, err)
+			continue
+		}
+		if msg != nil {
+			batch = append(batch, msg)
+		}
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var writeErr error
+	if err := f.resources.AccessOutput(ctx, f.shutdown.output, func(o *service.ResourceOutput) {
+		writeErr = o.WriteBatch(ctx, batch)
+	}); err != nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		// The logs of the windows are re-delivered on startup, when they
+		// must not be skipped as emitted
+		for key, window := range windows {
+			f.releaseWindowClaim(ctx, key, window.EndTime)
+		}
+		return writeErr
+	}
+
+	f.logger.Infof("Flushed %d windows on shutdown", len(batch))
+	for _, window := range windows {
+		f.ackLogs(ctx, window.Pending...)
+	}
+	return nil
+}
+
+// persistWindows stores the given windows in Redis so that they can be
+// restored by the next instance to start.
+func (f *FirewallAnomalyDetector) persistWindows(ctx context.Context, windows map[string]*WindowData) error {
+	values := make(map[string]interface{}, len(windows))
+	for key, window := range windows {
+		raw, err := encodeWindowState(window)
+		if err != nil {
+			return err
+		}
+		values[key] = raw
+	}
+
+	if err := f.redisClient.HSet(ctx, f.shutdown.stateKey, values).Err(); err != nil {
+		return err
+	}
+	f.logger.Infof("Persisted %d windows on shutdown", len(windows))
+	return nil
+}
+
+// restoreWindows loads windows persisted by a previous shutdown. Only windows
+// owned by this instance are restored and removed from Redis.
+func (f *FirewallAnomalyDetector) restoreWindows(ctx context.Context) error {
+	if f.shutdown == nil || f.shutdown.mode != shutdownModePersist {
+		return nil
+	}
+
+	stored, err := f.redisClient.HGetAll(ctx, f.shutdown.stateKey).Result()
+	if err != nil {
+		return err
+	}
+
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	var restored []string
+	for key, raw := range stored {
+		if !f.partitioner.owns(key) {
+			continue
+		}
+		window, _, err := decodeWindowState(key, raw)
+		if err != nil {
+			f.logger.Warnf("Discarding unreadable persisted window %s: %v", key, err)
+		} else {
+			f.windows[key] = window
+		}
+		restored = append(restored, key)
+	}
+	if len(restored) == 0 {
+		return nil
+	}
+
+	f.logger.Infof("Restored %d windows persisted on shutdown", len(restored))
+	return f.redisClient.HDel(ctx, f.shutdown.stateKey, restored...).Err()
+}
@@ -0,0 +1,51 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownFlushRequiresOutput(t *testing.T) {
+	spec := service.NewConfigSpec().Field(shutdownField())
+	conf, err := spec.ParseYAML(`
+shutdown:
+  mode: flush
+`, nil)
+	require.NoError(t, err)
+
+	_, err = newShutdownPolicyFromConfig(conf.Namespace("shutdown"))
+	assert.Error(t, err)
+}
+
+func TestShutdownDrainAndFinalScore(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:  60,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		sources:        map[string]string{"fortinet.firewall": "connection_count"},
+		windows:        make(map[string]*WindowData),
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 100, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 120, "192.168.1.2", now.Add(time.Second))
+	detector.windows["paloalto.firewall"] = &WindowData{IPs: map[string]bool{}}
+
+	windows := detector.drainWindows()
+	require.Len(t, windows, 1)
+	assert.Empty(t, detector.windows)
+
+	msg, err := detector.scoreWindow(context.Background(), "fortinet.firewall", windows["fortinet.firewall"], "connection_count", 120, true)
+	require.NoError(t, err)
+
+	result, err := alertResult(msg)
+	require.NoError(t, err)
+	assert.Equal(t, true, result["final"])
+	assert.Equal(t, "fortinet.firewall", result["log_source"])
+}
@@ -0,0 +1,224 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"gonum.org/v1/gonum/stat"
+)
+
+// simulationLagSamples bounds the lag samples quantiles are computed from,
+// so that long simulations do not grow the heap they measure.
+const simulationLagSamples = 10000
+
+// simulationMemoryInterval is how often the heap is measured.
+const simulationMemoryInterval = 250 * time.Millisecond
+
+// SimulationOptions describes how a capture is replayed by Simulate.
+type SimulationOptions struct {
+	// Multiplier speeds up the capture relative to its own rate, e.g. 10
+	// replays a 5k EPS capture at 50k EPS.
+	Multiplier float64
+	// ReportInterval is how often Progress is called, if set.
+	ReportInterval time.Duration
+	Progress       func(stats SimulationStats)
+}
+
+// DefaultSimulationOptions are the options of the simulate command.
+var DefaultSimulationOptions = SimulationOptions{
+	Multiplier:     1,
+	ReportInterval: 5 * time.Second,
+}
+
+// SimulationStats are the measurements of a simulation, so far while it
+// runs.
+type SimulationStats struct {
+	Logs    int
+	Windows int
+	Elapsed time.Duration
+
+	// CaptureEPS is the rate of the capture in event time, and TargetEPS the
+	// rate it is replayed at.
+	CaptureEPS  float64
+	TargetEPS   float64
+	AchievedEPS float64
+
+	// Lag is how far behind its schedule the detector was when it finished
+	// processing the latest log. A lag that keeps growing means the detector
+	// does not sustain the target rate.
+	Lag    time.Duration
+	LagP50 time.Duration
+	LagP99 time.Duration
+	MaxLag time.Duration
+
+	HeapBytes     uint64
+	PeakHeapBytes uint64
+	OpenWindows   int
+	PeakWindows   int
+}
+
+// Simulate replays a capture of JSON logs, in event time order, through the
+// first firewall_anomaly_detector processor of a config at the rate of the
+// capture times a multiplier on the wall clock, measuring how far the
+// detector lags behind and how much memory it holds. Logs are parsed,
+// windowed and scored like in a replay, with external systems disabled and
+// the detector clock following event time. Windows still open at the end of
+// the capture are scored as part of the run.
+func Simulate(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), capture io.Reader, opts SimulationOptions) (SimulationStats, error) {
+	var stats SimulationStats
+	if opts.Multiplier <= 0 {
+		return stats, errors.New("multiplier must be positive")
+	}
+
+	detector, err := newOfflineDetector(env, confYAML, lookupEnv)
+	if err != nil {
+		return stats, err
+	}
+	defer detector.Close(ctx)
+
+	// Feature export files would measure the disk rather than the detector
+	detector.features = nil
+
+	var watermark time.Time
+	detector.clock = func() time.Time { return watermark }
+	countWindow := func(*service.Message) error {
+		stats.Windows++
+		return nil
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	lags := make([]float64, 0, simulationLagSamples)
+	observeLag := func(lag time.Duration) {
+		stats.Lag = lag
+		if lag > stats.MaxLag {
+			stats.MaxLag = lag
+		}
+		// Reservoir sampling keeps a uniform sample of every lag
+		if len(lags) < simulationLagSamples {
+			lags = append(lags, float64(lag))
+		} else if i := rng.Intn(stats.Logs); i < simulationLagSamples {
+			lags[i] = float64(lag)
+		}
+	}
+
+	var first, last time.Time
+	began := time.Now()
+	lastMeasured, lastReported := began, began
+	measure := func(now time.Time) {
+		var mem runtime.MemStats
+		runtime.ReadMemStats(&mem)
+		stats.HeapBytes = mem.HeapAlloc
+		if stats.HeapBytes > stats.PeakHeapBytes {
+			stats.PeakHeapBytes = stats.HeapBytes
+		}
+		stats.OpenWindows = detector.openWindows()
+		if stats.OpenWindows > stats.PeakWindows {
+			stats.PeakWindows = stats.OpenWindows
+		}
+
+		stats.Elapsed = now.Sub(began)
+		if stats.Elapsed > 0 {
+			stats.AchievedEPS = float64(stats.Logs) / stats.Elapsed.Seconds()
+		}
+		if span := last.Sub(first); span > 0 {
+			stats.CaptureEPS = float64(stats.Logs) / span.Seconds()
+			stats.TargetEPS = stats.CaptureEPS * opts.Multiplier
+		}
+		if len(lags) > 0 {
+			sorted := append([]float64(nil), lags...)
+			sort.Float64s(sorted)
+			stats.LagP50 = time.Duration(stat.Quantile(0.5, stat.Empirical, sorted, nil))
+			stats.LagP99 = time.Duration(stat.Quantile(0.99, stat.Empirical, sorted, nil))
+		}
+	}
+
+	runtime.GC()
+	measure(began)
+
+	scanner := bufio.NewScanner(capture)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		item := strings.TrimSpace(scanner.Text())
+		if item == "" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+
+		log, ok := detector.parseLog(ctx, item)
+		if !ok {
+			continue
+		}
+		stats.Logs++
+
+		// Logs are due at the offset of their event time from the first
+		// log, scaled by the multiplier. Out of order logs are due at once.
+		if first.IsZero() {
+			first, last = log.Timestamp, log.Timestamp
+		}
+		if log.Timestamp.After(last) {
+			last = log.Timestamp
+		}
+		due := began.Add(time.Duration(float64(last.Sub(first)) / opts.Multiplier))
+		if wait := time.Until(due); wait > time.Millisecond {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			}
+		}
+
+		if log.Timestamp.After(watermark) {
+			watermark = log.Timestamp
+		}
+		msg, err := detector.processLog(ctx, log)
+		if err != nil {
+			return stats, err
+		}
+		if msg != nil {
+			stats.Windows++
+		}
+
+		now := time.Now()
+		lag := now.Sub(due)
+		if lag < 0 {
+			lag = 0
+		}
+		observeLag(lag)
+
+		if now.Sub(lastMeasured) >= simulationMemoryInterval {
+			lastMeasured = now
+			measure(now)
+		}
+		if opts.Progress != nil && opts.ReportInterval > 0 && now.Sub(lastReported) >= opts.ReportInterval {
+			lastReported = now
+			measure(now)
+			opts.Progress(stats)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+
+	if err := detector.flushRemaining(ctx, countWindow); err != nil {
+		return stats, err
+	}
+	measure(time.Now())
+	return stats, nil
+}
+
+// openWindows returns the number of windows held in memory.
+func (f *FirewallAnomalyDetector) openWindows() int {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+	return len(f.windows)
+}
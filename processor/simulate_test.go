@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulate(t *testing.T) {
+	// 2000 logs over 20 seconds of event time, 100 EPS
+	opts := BenchOptions{Logs: 2000, IPs: 10, EventsPerSecond: 100}
+	logs := generateBenchLogs(opts, []string{"fortinet.firewall", "paloalto.firewall"}, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	var progress []SimulationStats
+	stats, err := Simulate(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv,
+		strings.NewReader(strings.Join(logs, "\n")), SimulationOptions{
+			Multiplier:     100,
+			ReportInterval: 50 * time.Millisecond,
+			Progress:       func(stats SimulationStats) { progress = append(progress, stats) },
+		})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2000, stats.Logs)
+	assert.Equal(t, 2, stats.Windows)
+	assert.InDelta(t, 100, stats.CaptureEPS, 1)
+	assert.InDelta(t, 10000, stats.TargetEPS, 100)
+	// Replayed at 100x, 20 seconds of traffic take about 200ms
+	assert.GreaterOrEqual(t, stats.Elapsed, 190*time.Millisecond)
+	assert.Less(t, stats.AchievedEPS, 11000.0)
+	assert.LessOrEqual(t, stats.LagP50, stats.LagP99)
+	assert.LessOrEqual(t, stats.LagP99, stats.MaxLag)
+	assert.NotZero(t, stats.PeakHeapBytes)
+	assert.Equal(t, 2, stats.PeakWindows)
+	assert.Zero(t, stats.OpenWindows)
+
+	require.NotEmpty(t, progress)
+	assert.Less(t, progress[0].Logs, 2000)
+}
+
+func TestSimulateRequiresMultiplier(t *testing.T) {
+	_, err := Simulate(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv, strings.NewReader(""), SimulationOptions{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,296 @@
+package processor
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func init() {
+	// Enrichment values are decoded from JSON and therefore hold these
+	// dynamic types.
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+func snapshotField() *service.ConfigField {
+	return service.NewObjectField("snapshot",
+		service.NewStringField("path").
+			Description("Local file window state, published baselines and adaptive threshold score histories are snapshotted to. Leave empty to disable snapshots.").
+			Default(""),
+		service.NewDurationField("interval").
+			Description("How often a snapshot is written").
+			Default("30s"),
+	).
+		Description("Periodic on-disk snapshots of window state and baselines, restored on startup. A lighter-weight alternative to Redis backed state for single-node deployments.")
+}
+
+// windowSnapshot is the gob encoded content of a snapshot file.
+type windowSnapshot struct {
+	Version int
+	TakenAt time.Time
+	Windows map[string]*WindowData
+	// Baselines are the published moving baselines by window key
+	Baselines map[string]*sourceBaseline
+	// ScoreHistories are the recent scores of the adaptive thresholds by
+	// window key, oldest first
+	ScoreHistories map[string][]float64
+}
+
+type snapshotter struct {
+	path     string
+	interval time.Duration
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newSnapshotterFromConfig(conf *service.ParsedConfig) (*snapshotter, error) {
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	interval, err := conf.FieldDuration("interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, errors.New("snapshot interval must be positive")
+	}
+
+	return &snapshotter{path: path, interval: interval}, nil
+}
+
+// write atomically replaces the snapshot file with the given state.
+func (s *snapshotter) write(snapshot windowSnapshot) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	snapshot.Version = stateVersion
+	snapshot.TakenAt = time.Now()
+	if err := gob.NewEncoder(tmp).Encode(&snapshot); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// read loads the snapshot file, returning nil when it does not exist.
+func (s *snapshotter) read() (*windowSnapshot, error) {
+	file, err := os.Open(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var snapshot windowSnapshot
+	if err := gob.NewDecoder(file).Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// migrateSnapshot upgrades a decoded snapshot to the current state version.
+// Gob tolerates added and removed fields, so only semantic changes need to
+// be applied here.
+func migrateSnapshot(snapshot *windowSnapshot) error {
+	if snapshot.Version > stateVersion {
+		return fmt.Errorf("snapshot version %d is newer than the supported version %d", snapshot.Version, stateVersion)
+	}
+	if snapshot.Version < 2 {
+		for key, window := range snapshot.Windows {
+			if window.Source == "" {
+				window.Source = key
+			}
+		}
+	}
+	snapshot.Version = stateVersion
+	return nil
+}
+
+// MigrateSnapshotFile rewrites a window snapshot file in the current state
+// version, reporting whether it needed migrating.
+func MigrateSnapshotFile(path string) (bool, error) {
+	s := &snapshotter{path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	var snapshot windowSnapshot
+	err = gob.NewDecoder(file).Decode(&snapshot)
+	file.Close()
+	if err != nil {
+		return false, err
+	}
+
+	if snapshot.Version == stateVersion {
+		return false, nil
+	}
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return false, err
+	}
+	return true, s.write(snapshot)
+}
+
+// copyWindows returns a deep copy of the current windows so that they can be
+// encoded without holding the windows lock.
+func (f *FirewallAnomalyDetector) copyWindows() map[string]*WindowData {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	windows := make(map[string]*WindowData, len(f.windows))
+	for key, window := range f.windows {
+		windows[key] = cloneWindow(window)
+	}
+	return windows
+}
+
+// copyWindow returns a deep copy of a single window, or nil when it does not
+// exist.
+func (f *FirewallAnomalyDetector) copyWindow(windowKey string) *WindowData {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	window, exists := f.windows[windowKey]
+	if !exists {
+		return nil
+	}
+	return cloneWindow(window)
+}
+
+func cloneWindow(window *WindowData) *WindowData {
+	c := *window
+	c.Values = append([]float64(nil), window.Values...)
+	c.Pending = append([]string(nil), window.Pending...)
+	if window.MetricHeads != nil {
+		c.MetricHeads = make(map[string]int, len(window.MetricHeads))
+		for prefix, head := range window.MetricHeads {
+			c.MetricHeads[prefix] = head
+		}
+	}
+	if window.IPFilter != nil {
+		c.IPFilter = window.IPFilter.clone()
+	} else {
+		c.IPs = make(map[string]bool, len(window.IPs))
+		for ip := range window.IPs {
+			c.IPs[ip] = true
+		}
+	}
+	c.IPCounts = make(map[string]int, len(window.IPCounts))
+	for ip, count := range window.IPCounts {
+		c.IPCounts[ip] = count
+	}
+	if window.Metrics != nil {
+		c.Metrics = make(map[string][]float64, len(window.Metrics))
+		for prefix, values := range window.Metrics {
+			c.Metrics[prefix] = append([]float64(nil), values...)
+		}
+	}
+	if window.Enrichment != nil {
+		c.Enrichment = make(map[string]interface{}, len(window.Enrichment))
+		for k, v := range window.Enrichment {
+			c.Enrichment[k] = v
+		}
+	}
+	if window.Severities != nil {
+		c.Severities = make(map[string]int, len(window.Severities))
+		for severity, count := range window.Severities {
+			c.Severities[severity] = count
+		}
+	}
+	return &c
+}
+
+func (f *FirewallAnomalyDetector) writeSnapshot() {
+	snapshot := windowSnapshot{
+		Windows:        f.copyWindows(),
+		Baselines:      f.baselines.snapshot(),
+		ScoreHistories: f.adaptive.snapshot(),
+	}
+	if err := f.snapshots.write(snapshot); err != nil {
+		f.logger.Errorf("Failed to write window snapshot: %v", err)
+	}
+}
+
+// restoreSnapshot loads windows, baselines and adaptive threshold score
+// histories from the snapshot file into the detector.
+func (f *FirewallAnomalyDetector) restoreSnapshot() error {
+	if f.snapshots == nil {
+		return nil
+	}
+
+	snapshot, err := f.snapshots.read()
+	if err != nil || snapshot == nil {
+		return err
+	}
+	f.baselines.restore(snapshot.Baselines)
+	f.adaptive.restore(snapshot.ScoreHistories)
+
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+	for key, window := range snapshot.Windows {
+		f.windows[key] = window
+	}
+	f.logger.Infof("Restored %d windows from snapshot %s", len(snapshot.Windows), f.snapshots.path)
+	return nil
+}
+
+func (f *FirewallAnomalyDetector) startSnapshots() {
+	if f.snapshots == nil {
+		return
+	}
+
+	s := f.snapshots
+	s.shutdown = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.writeSnapshot()
+			case <-s.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+// stopSnapshots stops periodic snapshots and writes a last one so that no
+// state is lost between the final tick and shutdown.
+func (f *FirewallAnomalyDetector) stopSnapshots() {
+	if f.snapshots == nil || f.snapshots.shutdown == nil {
+		return
+	}
+	close(f.snapshots.shutdown)
+	<-f.snapshots.done
+	f.writeSnapshot()
+}
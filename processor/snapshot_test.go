@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	spec := service.NewConfigSpec().Field(snapshotField())
+	conf, err := spec.ParseYAML(`
+snapshot:
+  path: `+filepath.Join(t.TempDir(), "windows.snapshot")+`
+`, nil)
+	require.NoError(t, err)
+
+	snapshots, err := newSnapshotterFromConfig(conf.Namespace("snapshot"))
+	require.NoError(t, err)
+	require.NotNil(t, snapshots)
+
+	detector := &FirewallAnomalyDetector{
+		logger:        service.MockResources().Logger(),
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		snapshots:     snapshots,
+	}
+
+	// Nothing to restore before the first snapshot.
+	require.NoError(t, detector.restoreSnapshot())
+	assert.Empty(t, detector.windows)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 100, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 120, "192.168.1.1", now.Add(time.Second))
+	detector.mergeWindowEnrichment("fortinet.firewall", map[string]interface{}{"asn": map[string]interface{}{"number": 64512.0}})
+	detector.writeSnapshot()
+
+	restored := &FirewallAnomalyDetector{
+		logger:    service.MockResources().Logger(),
+		windows:   make(map[string]*WindowData),
+		snapshots: snapshots,
+	}
+	require.NoError(t, restored.restoreSnapshot())
+
+	window := restored.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Equal(t, []float64{100, 120}, window.Values)
+	assert.Equal(t, 2, window.IPCounts["192.168.1.1"])
+	assert.True(t, window.StartTime.Equal(now))
+	assert.Equal(t, map[string]interface{}{"number": 64512.0}, window.Enrichment["asn"])
+}
+
+func TestSnapshotRestoresBaselinesAndScoreHistories(t *testing.T) {
+	snapshots := &snapshotter{path: filepath.Join(t.TempDir(), "windows.snapshot")}
+	newDetector := func() *FirewallAnomalyDetector {
+		return &FirewallAnomalyDetector{
+			logger:    service.MockResources().Logger(),
+			windows:   make(map[string]*WindowData),
+			snapshots: snapshots,
+			baselines: &baselineStore{name: "edge", smoothing: 0.5, baselines: make(map[string]*sourceBaseline)},
+			adaptive:  &adaptiveThresholds{quantile: 0.5, history: 3, minSamples: 3, scores: make(map[string]*scoreHistory)},
+		}
+	}
+
+	detector := newDetector()
+	end := time.Date(2024, 1, 15, 10, 1, 0, 0, time.UTC)
+	detector.baselines.observe("fortinet.firewall", "fortinet.firewall", "", &WindowData{Values: []float64{10, 30}, EndTime: end}, map[string]float64{"mean_value": 20}, 0.2, 0.7)
+	detector.baselines.observe("fortinet.firewall", "fortinet.firewall", "", &WindowData{Values: []float64{40}, EndTime: end.Add(time.Minute)}, map[string]float64{"mean_value": 40}, 0.4, 0.7)
+	for _, score := range []float64{0.1, 0.2, 0.3, 0.9} {
+		detector.adaptive.observe("fortinet.firewall", score)
+	}
+	detector.writeSnapshot()
+
+	restored := newDetector()
+	require.NoError(t, restored.restoreSnapshot())
+
+	// The moving statistics carry on where they left off
+	baseline := restored.baselines.baselines["fortinet.firewall"]
+	require.NotNil(t, baseline)
+	assert.Equal(t, 2, baseline.Windows)
+	assert.InDelta(t, 30, baseline.Mean, 1e-9)
+	assert.InDelta(t, 10, baseline.StdDev, 1e-9)
+	assert.Equal(t, end.Add(time.Minute), baseline.WindowEnd)
+	restored.baselines.observe("fortinet.firewall", "fortinet.firewall", "", &WindowData{Values: []float64{30}}, nil, 0.1, 0.7)
+	detector.baselines.observe("fortinet.firewall", "fortinet.firewall", "", &WindowData{Values: []float64{30}}, nil, 0.1, 0.7)
+	assert.InDelta(t, detector.baselines.baselines["fortinet.firewall"].StdDev, restored.baselines.baselines["fortinet.firewall"].StdDev, 1e-9)
+
+	// Score histories are restored oldest first, so the next score replaces
+	// the oldest one
+	threshold, ok := restored.adaptive.threshold("fortinet.firewall", nil)
+	require.True(t, ok)
+	assert.Equal(t, 0.3, threshold)
+	restored.adaptive.observe("fortinet.firewall", 1.0)
+	detector.adaptive.observe("fortinet.firewall", 1.0)
+	assert.ElementsMatch(t, detector.adaptive.scores["fortinet.firewall"].scores, restored.adaptive.scores["fortinet.firewall"].scores)
+}
+
+func TestSnapshotDisabledByDefault(t *testing.T) {
+	spec := service.NewConfigSpec().Field(snapshotField())
+	conf, err := spec.ParseYAML(`snapshot: {}`, nil)
+	require.NoError(t, err)
+
+	snapshots, err := newSnapshotterFromConfig(conf.Namespace("snapshot"))
+	require.NoError(t, err)
+	assert.Nil(t, snapshots)
+}
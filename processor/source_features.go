@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func sourceFeaturesField() *service.ConfigField {
+	return service.NewStringListField("features").
+		Description("Features of this source to compute and score, matching the feature vector its model was trained on, one of `" + strings.Join(featureNames, "`, `") + "`. Multi-metric sources select the features of every metric. All features but `high_severity_ratio` are used when omitted.").
+		Optional()
+}
+
+// parseSourceFeatures returns the features selected for a source, the
+// default features when omitted.
+func parseSourceFeatures(source string, conf *service.ParsedConfig) (featureSet, error) {
+	if !conf.Contains("features") {
+		return defaultFeatures, nil
+	}
+	names, err := conf.FieldStringList("features")
+	if err != nil {
+		return 0, err
+	}
+	if len(names) == 0 {
+		return defaultFeatures, nil
+	}
+
+	var selected featureSet
+	for _, name := range names {
+		i, ok := featureIndexOf(name)
+		if !ok {
+			return 0, fmt.Errorf("source %s: unknown feature %s", source, name)
+		}
+		selected |= 1 << i
+	}
+	return selected, nil
+}
+
+// selectedFeatures returns the features computed for a source.
+func (f *FirewallAnomalyDetector) selectedFeatures(source string) featureSet {
+	if selected, exists := f.sourceFeatures[f.sourceKey(source)]; exists {
+		return selected
+	}
+	return defaultFeatures
+}
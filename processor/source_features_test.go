@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourceFeatures(t *testing.T) {
+	spec := service.NewConfigSpec().Field(sourceFeaturesField())
+	parse := func(yaml string) (featureSet, error) {
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return parseSourceFeatures("fortinet.firewall", conf)
+	}
+
+	features, err := parse(`features: [ mean_value, std_dev ]`)
+	require.NoError(t, err)
+	assert.True(t, features.has(featureMeanValue))
+	assert.True(t, features.has(featureStdDev))
+	assert.False(t, features.has(featureMaxValue))
+
+	features, err = parse(`{}`)
+	require.NoError(t, err)
+	assert.Equal(t, defaultFeatures, features)
+
+	_, err = parse(`features: [ mean_value, packets ]`)
+	assert.ErrorContains(t, err, "unknown feature packets")
+}
+
+func TestSelectedFeatures(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		sources: map[string]string{
+			"fortinet.firewall": "connection_count",
+			"paloalto.firewall": "bytes_sent",
+		},
+		sourceMetrics: map[string][]sourceMetric{
+			"paloalto.firewall": {
+				{field: "bytes_sent", prefix: "bytes_sent"},
+				{field: "connection_count", prefix: "conn_count"},
+			},
+		},
+		sourceFeatures: map[string]featureSet{
+			"fortinet.firewall": 1<<featureMeanValue | 1<<featureMaxValue,
+			"paloalto.firewall": 1 << featureMeanValue,
+		},
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for _, source := range []string{"fortinet.firewall", "paloalto.firewall"} {
+		detector.updateScopedWindow(source, "", source, 10, "10.0.0.1", now)
+		detector.updateScopedWindow(source, "", source, 30, "10.0.0.2", now.Add(time.Second))
+	}
+
+	features := detector.extractFeatures(detector.getWindow("fortinet.firewall"))
+	assert.Equal(t, map[string]float64{"mean_value": 20, "max_value": 30}, features.asMap())
+
+	// NATed sources drop unique_ips, which then never adds to the score
+	detector.addMetricValues("paloalto.firewall", map[string]float64{"conn_count": 1})
+	features = detector.extractFeatures(detector.getWindow("paloalto.firewall"))
+	assert.Equal(t, map[string]float64{"bytes_sent_mean_value": 20, "conn_count_mean_value": 1}, features.asMap())
+	assert.Equal(t, 0.0, detector.scoreFeatures(features))
+}
@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func sourceMetricsField() *service.ConfigField {
+	return service.NewObjectListField("metrics",
+		service.NewStringField("field").
+			Description("Metric field to extract from logs, one of `connection_count`, `bytes_sent` or `bytes_recv`"),
+		service.NewStringField("prefix").
+			Description("Prefix of the features of this metric. Defaults to the field name.").
+			Default(""),
+	).
+		Description("Several metrics of this source, each producing its own prefixed feature set in one combined vector, e.g. `bytes_sent_mean_value`. The first metric replaces `metric`.").
+		Optional()
+}
+
+// sourceMetric is one of the metrics windowed for a multi-metric source.
+type sourceMetric struct {
+	field  string
+	prefix string
+}
+
+// parseSourceMetrics returns the metrics of a source configured with a
+// `metrics` list, or nil when the source uses a single `metric`.
+func parseSourceMetrics(source string, conf *service.ParsedConfig) ([]sourceMetric, error) {
+	if !conf.Contains("metrics") {
+		return nil, nil
+	}
+	metricConfs, err := conf.FieldObjectList("metrics")
+	if err != nil {
+		return nil, err
+	}
+	if len(metricConfs) == 0 {
+		return nil, nil
+	}
+
+	metrics := make([]sourceMetric, 0, len(metricConfs))
+	prefixes := make(map[string]bool, len(metricConfs))
+	for _, metricConf := range metricConfs {
+		var m sourceMetric
+		if m.field, err = metricConf.FieldString("field"); err != nil {
+			return nil, err
+		}
+		if _, ok := metricValueOf(FirewallLog{}, m.field); !ok {
+			return nil, fmt.Errorf("source %s: unknown metric field %s", source, m.field)
+		}
+		if m.prefix, err = metricConf.FieldString("prefix"); err != nil {
+			return nil, err
+		}
+		if m.prefix == "" {
+			m.prefix = m.field
+		}
+		if prefixes[m.prefix] {
+			return nil, fmt.Errorf("source %s: duplicate metric prefix %s", source, m.prefix)
+		}
+		prefixes[m.prefix] = true
+		metrics = append(metrics, m)
+	}
+	return metrics, nil
+}
+
+// metricValueOf extracts a metric field from a log.
+func metricValueOf(log FirewallLog, field string) (float64, bool) {
+	switch field {
+	case "connection_count":
+		return float64(log.ConnectionCount), true
+	case "bytes_sent":
+		return float64(log.BytesSent), true
+	case "bytes_recv":
+		return float64(log.BytesRecv), true
+	default:
+		return 0, false
+	}
+}
+
+// secondaryMetricValues extracts the metrics of a multi-metric source other
+// than its primary metric, keyed by feature prefix.
+func (f *FirewallAnomalyDetector) secondaryMetricValues(log FirewallLog) (map[string]float64, error) {
+	metrics := f.sourceMetrics[f.sourceKey(log.LogSource)]
+	if len(metrics) < 2 {
+		return nil, nil
+	}
+
+	values := make(map[string]float64, len(metrics)-1)
+	for _, m := range metrics[1:] {
+		v, ok := metricValueOf(log, m.field)
+		if !ok {
+			return nil, errors.New("unknown metric field: " + m.field)
+		}
+		values[m.prefix] = v
+	}
+	return values, nil
+}
+
+// addMetricValues appends the secondary metric values of a log to its window.
+func (f *FirewallAnomalyDetector) addMetricValues(windowKey string, values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+
+	f.windowsMutex.Lock()
+	defer f.windowsMutex.Unlock()
+
+	if window, exists := f.windows[windowKey]; exists {
+		f.addMetricValuesLocked(window, values)
+	}
+}
+
+func (f *FirewallAnomalyDetector) addMetricValuesLocked(window *WindowData, values map[string]float64) {
+	if len(values) == 0 {
+		return
+	}
+	if window.Metrics == nil {
+		window.Metrics = make(map[string][]float64, len(values))
+	}
+	for prefix, v := range values {
+		buffer, exists := window.Metrics[prefix]
+		if !exists {
+			buffer = f.buffers.newBuffer(f.windowLength(window.Source))
+		}
+		head := window.MetricHeads[prefix]
+		buffer, head, _ = f.buffers.push(buffer, head, v)
+		window.Metrics[prefix] = buffer
+		if head > 0 {
+			if window.MetricHeads == nil {
+				window.MetricHeads = make(map[string]int, len(values))
+			}
+			window.MetricHeads[prefix] = head
+		} else {
+			delete(window.MetricHeads, prefix)
+		}
+	}
+}
+
+// extractFeatures computes the feature vector of a window, limited to the
+// features selected for its source. Multi-metric sources combine the
+// features of every metric, while the window features are shared. Non-finite
+// features are sanitised.
+func (f *FirewallAnomalyDetector) extractFeatures(window *WindowData) featureVector {
+	selected := f.selectedFeatures(window.Source)
+	metrics := f.sourceMetrics[f.sourceKey(window.Source)]
+	var severityRatio float64
+	if selected.has(featureHighSeverityRatio) {
+		severityRatio = f.severities.highSeverityRatio(window)
+	}
+	if len(metrics) == 0 {
+		stats := metricFeatures(window.Values, window.LastMean, window.uniqueIPs())
+		stats[featureHighSeverityRatio] = severityRatio
+		vector := featureVector{metrics: []metricStats{stats.only(selected)}, selected: selected}
+		vector.sanitise()
+		return vector
+	}
+
+	vector := featureVector{
+		metrics:  make([]metricStats, len(metrics)),
+		sources:  metrics,
+		selected: selected,
+	}
+	for i, m := range metrics {
+		values := window.Values
+		if i > 0 {
+			values = window.Metrics[m.prefix]
+		}
+		stats := metricFeatures(values, 0, window.uniqueIPs())
+		stats[featureHighSeverityRatio] = severityRatio
+		vector.metrics[i] = stats.only(selected)
+	}
+	vector.sanitise()
+	return vector
+}
+
+// scoreFeatures scores a feature vector. The features of every metric of a
+// multi-metric source are scored on their own and the highest score wins.
+func (f *FirewallAnomalyDetector) scoreFeatures(features featureVector) float64 {
+	score := 0.0
+	for _, stats := range features.metrics {
+		if s := f.scoreAnomaly(stats); s > score {
+			score = s
+		}
+	}
+	return score
+}
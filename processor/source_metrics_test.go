@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestSourceMetrics(t *testing.T, yaml string) ([]sourceMetric, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(sourceMetricsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return parseSourceMetrics("fortinet.firewall", conf)
+}
+
+func TestParseSourceMetrics(t *testing.T) {
+	metrics, err := parseTestSourceMetrics(t, `
+metrics:
+  - field: bytes_sent
+  - field: connection_count
+    prefix: conn_count
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []sourceMetric{
+		{field: "bytes_sent", prefix: "bytes_sent"},
+		{field: "connection_count", prefix: "conn_count"},
+	}, metrics)
+
+	metrics, err = parseTestSourceMetrics(t, `{}`)
+	require.NoError(t, err)
+	assert.Nil(t, metrics)
+
+	_, err = parseTestSourceMetrics(t, `metrics: [ { field: packets } ]`)
+	assert.Error(t, err)
+
+	_, err = parseTestSourceMetrics(t, `metrics: [ { field: bytes_sent }, { field: bytes_recv, prefix: bytes_sent } ]`)
+	assert.Error(t, err)
+}
+
+func TestMultiMetricFeatures(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		sources:       map[string]string{"fortinet.firewall": "bytes_sent"},
+		sourceMetrics: map[string][]sourceMetric{
+			"fortinet.firewall": {
+				{field: "bytes_sent", prefix: "bytes_sent"},
+				{field: "connection_count", prefix: "conn_count"},
+			},
+		},
+	}
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i, log := range []FirewallLog{
+		{LogSource: "fortinet.firewall", SourceIP: "192.168.1.1", BytesSent: 100, ConnectionCount: 1},
+		{LogSource: "fortinet.firewall", SourceIP: "192.168.1.2", BytesSent: 300, ConnectionCount: 1},
+		{LogSource: "fortinet.firewall", SourceIP: "192.168.1.3", BytesSent: 200, ConnectionCount: 40},
+	} {
+		secondary, err := detector.secondaryMetricValues(log)
+		require.NoError(t, err)
+		detector.updateScopedWindow("fortinet.firewall", "", "fortinet.firewall", float64(log.BytesSent), log.SourceIP, now.Add(time.Duration(i)*time.Second))
+		detector.addMetricValues("fortinet.firewall", secondary)
+	}
+
+	window := detector.getWindow("fortinet.firewall")
+	assert.Equal(t, []float64{1, 1, 40}, window.Metrics["conn_count"])
+
+	vector := detector.extractFeatures(window)
+	features := vector.asMap()
+	assert.Equal(t, 200.0, features["bytes_sent_mean_value"])
+	assert.Equal(t, 300.0, features["bytes_sent_max_value"])
+	assert.Equal(t, 14.0, features["conn_count_mean_value"])
+	assert.Equal(t, 40.0, features["conn_count_max_value"])
+	assert.Equal(t, 3.0, features["unique_ips"])
+	assert.NotContains(t, features, "mean_value")
+
+	// The spiking connection count drives the score on its own
+	assert.Equal(t, 0.2, detector.scoreFeatures(featureVector{
+		metrics: []metricStats{
+			{featureMeanValue: 200, featureStdDev: 100, featurePeakToMeanRatio: 1.5},
+			{featureMeanValue: 14, featureStdDev: 22, featurePeakToMeanRatio: 2.8},
+		},
+		sources:  vector.sources,
+		selected: allFeatures,
+	}))
+	assert.Equal(t, 0.2, detector.scoreFeatures(vector))
+}
@@ -0,0 +1,136 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// sourcePattern matches log sources against a glob or regular expression
+// key of `sources`.
+type sourcePattern struct {
+	key      string
+	priority int
+	match    func(source string) bool
+}
+
+// isSourcePattern reports whether a `sources` key is a pattern rather than an
+// exact log_source. Keys enclosed in slashes are regular expressions and
+// keys containing `*`, `?` or `[` are globs.
+func isSourcePattern(key string) bool {
+	return isRegexpSourceKey(key) || strings.ContainsAny(key, "*?[")
+}
+
+func isRegexpSourceKey(key string) bool {
+	return len(key) > 2 && strings.HasPrefix(key, "/") && strings.HasSuffix(key, "/")
+}
+
+// compileSourcePatterns compiles the pattern keys of `sources`, ordered by
+// descending priority and then by key so that matching is deterministic.
+func compileSourcePatterns(sources map[string]string, priorities map[string]int) ([]sourcePattern, error) {
+	var patterns []sourcePattern
+	for key := range sources {
+		if !isSourcePattern(key) {
+			continue
+		}
+
+		p := sourcePattern{key: key, priority: priorities[key]}
+		if isRegexpSourceKey(key) {
+			re, err := regexp.Compile("^(?:" + key[1:len(key)-1] + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("source %s: %w", key, err)
+			}
+			p.match = re.MatchString
+		} else {
+			if _, err := path.Match(key, ""); err != nil {
+				return nil, fmt.Errorf("source %s: %w", key, err)
+			}
+			glob := key
+			p.match = func(source string) bool {
+				matched, _ := path.Match(glob, source)
+				return matched
+			}
+		}
+		patterns = append(patterns, p)
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].priority != patterns[j].priority {
+			return patterns[i].priority > patterns[j].priority
+		}
+		return patterns[i].key < patterns[j].key
+	})
+	return patterns, nil
+}
+
+// sourceKey returns the `sources` key configuring a log source: the source
+// itself when configured exactly, otherwise the highest priority pattern
+// matching it, otherwise the default source when configured. Unmatched
+// sources are returned as is.
+func (f *FirewallAnomalyDetector) sourceKey(source string) string {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	return f.sourceKeyLocked(source)
+}
+
+// sourceKeyLocked is sourceKey for callers holding settingsMut.
+func (f *FirewallAnomalyDetector) sourceKeyLocked(source string) string {
+	if _, exists := f.sources[source]; exists {
+		return source
+	}
+	if len(f.sourcePatterns) > 0 {
+		if cached, exists := f.sourceMatches.Load(source); exists {
+			m := cached.(*sourceMatch)
+			if !m.used.Load() {
+				m.used.Store(true)
+			}
+			return m.key
+		}
+		for _, p := range f.sourcePatterns {
+			if p.match(source) {
+				f.sourceMatches.Store(source, &sourceMatch{key: p.key, idleSince: time.Now()})
+				return p.key
+			}
+		}
+	}
+	if _, exists := f.sources[defaultSourceKey]; exists {
+		return defaultSourceKey
+	}
+	return source
+}
+
+// sourceMatch is the cached pattern key matching a log source. Uses only
+// raise a flag, which the state collector turns into the time the match has
+// been idle since.
+type sourceMatch struct {
+	key       string
+	used      atomic.Bool
+	idleSince time.Time
+}
+
+// expireSourceMatches removes the cached pattern matches unused since cutoff,
+// as of the previous collection.
+func (f *FirewallAnomalyDetector) expireSourceMatches(now, cutoff time.Time) int {
+	f.settingsMut.RLock()
+	matches := f.sourceMatches
+	f.settingsMut.RUnlock()
+
+	n := 0
+	matches.Range(func(source, cached interface{}) bool {
+		m := cached.(*sourceMatch)
+		switch {
+		case m.used.Swap(false):
+			m.idleSince = now
+		case m.idleSince.Before(cutoff):
+			matches.Delete(source)
+			n++
+		}
+		return true
+	})
+	return n
+}
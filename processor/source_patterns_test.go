@@ -0,0 +1,59 @@
+package processor
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceKey(t *testing.T) {
+	sources := map[string]string{
+		"fortinet.firewall":       "connection_count",
+		"fortinet.*":              "bytes_sent",
+		"fortinet.fw-?":           "bytes_recv",
+		`/fortinet\.fw-[0-9]+/`:   "bytes_recv",
+		`/paloalto\.(east|west)/`: "bytes_sent",
+	}
+	patterns, err := compileSourcePatterns(sources, map[string]int{`/fortinet\.fw-[0-9]+/`: 10})
+	require.NoError(t, err)
+
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:    60,
+		scoreThreshold:   0.7,
+		sources:          sources,
+		sourceThresholds: map[string]float64{"fortinet.*": 0.9},
+		sourceWindows:    map[string]time.Duration{"fortinet.*": 5 * time.Minute},
+		sourcePatterns:   patterns,
+		sourceMatches:    &sync.Map{},
+	}
+
+	for source, key := range map[string]string{
+		"fortinet.firewall": "fortinet.firewall",
+		"fortinet.fw-12":    `/fortinet\.fw-[0-9]+/`,
+		"fortinet.fw-a":     "fortinet.*",
+		"fortinet.edge":     "fortinet.*",
+		"paloalto.east":     `/paloalto\.(east|west)/`,
+		"paloalto.eastern":  "paloalto.eastern",
+	} {
+		assert.Equal(t, key, detector.sourceKey(source), source)
+	}
+
+	field, exists := detector.metricFieldFor("fortinet.edge")
+	assert.True(t, exists)
+	assert.Equal(t, "bytes_sent", field)
+	assert.Equal(t, 0.9, detector.thresholdFor("", "fortinet.edge"))
+	assert.Equal(t, 5*time.Minute, detector.windowLength("fortinet.edge"))
+	assert.Equal(t, []string{"fortinet.*"}, detector.metricLabels("", "fortinet.edge"))
+	assert.Equal(t, []string{unknownSourceLabel}, detector.metricLabels("", "cisco.asa"))
+}
+
+func TestCompileSourcePatternsErrors(t *testing.T) {
+	_, err := compileSourcePatterns(map[string]string{"/fw-[0-9/": "bytes_sent"}, nil)
+	assert.Error(t, err)
+
+	_, err = compileSourcePatterns(map[string]string{"fw-[0-9": "bytes_sent"}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func memoryBudgetField() *service.ConfigField {
+	return service.NewObjectField("memory_budget",
+		service.NewIntField("max_windows").
+			Description("Maximum number of windows held in memory. When exceeded, the least recently updated windows are spilled to Redis and rehydrated on their next update, or once they are due to close. Zero disables the budget. Defaults to zero, or the value of `profile`.").
+			Optional(),
+		service.NewStringField("spill_key").
+			Description("Redis hash spilled windows are stored in").
+			Default("firewall_anomaly_detector:spilled"),
+	).
+		Description("Bounds the memory used by the windows map during traffic storms").
+		Advanced()
+}
+
+// windowSpill moves windows between memory and a Redis hash.
+type windowSpill struct {
+	client     *redis.Client
+	key        string
+	maxWindows int
+
+	// mut serialises spilling and rehydration so that a window is never
+	// read back while it is being written. Spilled windows are tracked with
+	// their end, so that they are closed once due like those in memory.
+	mut     sync.Mutex
+	spilled map[string]time.Time
+}
+
+func newWindowSpillFromConfig(conf *service.ParsedConfig, client *redis.Client, p profile) (*windowSpill, error) {
+	maxWindows, err := fieldIntOr(conf, p.maxWindows, "max_windows")
+	if err != nil {
+		return nil, err
+	}
+	if maxWindows <= 0 {
+		return nil, nil
+	}
+
+	key, err := conf.FieldString("spill_key")
+	if err != nil {
+		return nil, err
+	}
+
+	return &windowSpill{
+		client:     client,
+		key:        key,
+		maxWindows: maxWindows,
+		spilled:    make(map[string]time.Time),
+	}, nil
+}
+
+// evictionCandidates returns the keys of the least recently updated windows
+// that need to leave memory to get back within max.
+func evictionCandidates(windows map[string]*WindowData, max int) []string {
+	if len(windows) <= max {
+		return nil
+	}
+
+	keys := make([]string, 0, len(windows))
+	for key := range windows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := windows[keys[i]].UpdatedAt, windows[keys[j]].UpdatedAt
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return keys[i] < keys[j]
+	})
+	return keys[:len(windows)-max]
+}
+
+// enforceMemoryBudget spills the least recently updated windows to Redis
+// while more windows than the budget allows are held in memory.
+func (f *FirewallAnomalyDetector) enforceMemoryBudget(ctx context.Context) {
+	if f.spill == nil {
+		return
+	}
+
+	f.spill.mut.Lock()
+	defer f.spill.mut.Unlock()
+
+	f.windowsMutex.Lock()
+	evicted := make(map[string]*WindowData)
+	for _, key := range evictionCandidates(f.windows, f.spill.maxWindows) {
+		evicted[key] = f.windows[key]
+		delete(f.windows, key)
+	}
+	f.windowsMutex.Unlock()
+	if len(evicted) == 0 {
+		return
+	}
+
+	values := make(map[string]interface{}, len(evicted))
+	ends := make(map[string]time.Time, len(evicted))
+	for key, window := range evicted {
+		raw, err := encodeWindowState(window)
+		if err != nil {
+			f.logger.Errorf("Failed to encode window %s for spilling: %v", key, err)
+			continue
+		}
+		values[key] = raw
+		ends[key] = window.EndTime
+	}
+
+	if err := f.spill.client.HSet(ctx, f.spill.key, values).Err(); err != nil {
+		// Keep the windows rather than lose them, the budget is retried on
+		// the next update.
+		f.logger.Errorf("Failed to spill %d windows to Redis: %v", len(evicted), err)
+		f.windowsMutex.Lock()
+		for key, window := range evicted {
+			if _, exists := f.windows[key]; !exists {
+				f.windows[key] = window
+			}
+		}
+		f.windowsMutex.Unlock()
+		return
+	}
+
+	for key, end := range ends {
+		f.spill.spilled[key] = end
+	}
+	f.windowsSpilled.Incr(int64(len(values)))
+}
+
+// rehydrateWindow brings a spilled window back into memory before it is
+// updated.
+func (f *FirewallAnomalyDetector) rehydrateWindow(ctx context.Context, windowKey string) {
+	if f.spill == nil {
+		return
+	}
+
+	f.spill.mut.Lock()
+	defer f.spill.mut.Unlock()
+
+	if _, spilled := f.spill.spilled[windowKey]; !spilled {
+		return
+	}
+
+	raw, err := f.spill.client.HGet(ctx, f.spill.key, windowKey).Result()
+	if err != nil && err != redis.Nil {
+		f.logger.Errorf("Failed to rehydrate window %s: %v", windowKey, err)
+		return
+	}
+	delete(f.spill.spilled, windowKey)
+	if err == redis.Nil {
+		return
+	}
+
+	window, _, err := decodeWindowState(windowKey, raw)
+	if err != nil {
+		f.logger.Errorf("Discarding unreadable spilled window %s: %v", windowKey, err)
+	} else {
+		f.windowsMutex.Lock()
+		f.windows[windowKey] = window
+		f.windowsMutex.Unlock()
+	}
+
+	if err := f.spill.client.HDel(ctx, f.spill.key, windowKey).Err(); err != nil {
+		f.logger.Warnf("Failed to remove rehydrated window %s from Redis: %v", windowKey, err)
+	}
+}
+
+// rehydrateAll brings every spilled window back into memory, e.g. before the
+// windows are flushed on shutdown.
+func (f *FirewallAnomalyDetector) rehydrateAll(ctx context.Context) {
+	if f.spill == nil {
+		return
+	}
+
+	f.spill.mut.Lock()
+	keys := make([]string, 0, len(f.spill.spilled))
+	for key := range f.spill.spilled {
+		keys = append(keys, key)
+	}
+	f.spill.mut.Unlock()
+
+	for _, key := range keys {
+		f.rehydrateWindow(ctx, key)
+	}
+}
+
+// rehydrateDue brings the spilled windows whose end the clock passed by
+// window_grace back into memory, so that the windows of quiet sources are
+// closed even though no log rehydrates them.
+func (f *FirewallAnomalyDetector) rehydrateDue(ctx context.Context, now time.Time) {
+	if f.spill == nil {
+		return
+	}
+
+	f.spill.mut.Lock()
+	var keys []string
+	for key, end := range f.spill.spilled {
+		if !now.Before(end.Add(f.windowGrace)) {
+			keys = append(keys, key)
+		}
+	}
+	f.spill.mut.Unlock()
+
+	for _, key := range keys {
+		f.rehydrateWindow(ctx, key)
+	}
+}
+
+// loadSpilled registers windows spilled by a previous run so that they are
+// rehydrated when next updated, returning their pending logs.
+func (f *FirewallAnomalyDetector) loadSpilled(ctx context.Context) ([]string, error) {
+	if f.spill == nil {
+		return nil, nil
+	}
+
+	stored, err := f.spill.client.HGetAll(ctx, f.spill.key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	f.spill.mut.Lock()
+	defer f.spill.mut.Unlock()
+
+	var pending []string
+	for key, raw := range stored {
+		if !f.partitioner.owns(key) {
+			continue
+		}
+		// Unreadable windows are due at once, so that they are discarded
+		window, _, err := decodeWindowState(key, raw)
+		if err != nil {
+			f.spill.spilled[key] = time.Time{}
+			continue
+		}
+		f.spill.spilled[key] = window.EndTime
+		pending = append(pending, window.Pending...)
+	}
+	return pending, nil
+}
@@ -0,0 +1,197 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionCandidatesLeastRecentlyUpdated(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	windows := map[string]*WindowData{
+		"fortinet.firewall": {UpdatedAt: now.Add(3 * time.Second)},
+		"paloalto.firewall": {UpdatedAt: now},
+		"cisco.asa":         {UpdatedAt: now.Add(time.Second)},
+		"checkpoint":        {UpdatedAt: now},
+	}
+
+	assert.Equal(t, []string{"checkpoint", "paloalto.firewall"}, evictionCandidates(windows, 2))
+	assert.Empty(t, evictionCandidates(windows, 4))
+}
+
+func TestMemoryBudgetDisabledByDefault(t *testing.T) {
+	spec := service.NewConfigSpec().Field(memoryBudgetField())
+	conf, err := spec.ParseYAML(`memory_budget: {}`, nil)
+	require.NoError(t, err)
+
+	spill, err := newWindowSpillFromConfig(conf.Namespace("memory_budget"), nil, profiles[profileNone])
+	require.NoError(t, err)
+	assert.Nil(t, spill)
+}
+
+// hashServer is a minimal Redis server holding a single hash, enough for
+// windows to be spilled and rehydrated in tests.
+type hashServer struct {
+	mut  sync.Mutex
+	hash map[string]string
+}
+
+func newHashServer(t *testing.T) (*hashServer, *redis.Client) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	h := &hashServer{hash: make(map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go h.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String()})
+	t.Cleanup(func() { client.Close() })
+	return h, client
+}
+
+func (h *hashServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		h.mut.Lock()
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "HSET":
+			for i := 2; i+1 < len(args); i += 2 {
+				h.hash[args[i]] = args[i+1]
+			}
+			reply = fmt.Sprintf(":%d\r\n", (len(args)-2)/2)
+		case "HGET":
+			if v, ok := h.hash[args[2]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "HDEL":
+			for _, field := range args[2:] {
+				delete(h.hash, field)
+			}
+			reply = fmt.Sprintf(":%d\r\n", len(args)-2)
+		case "HGETALL":
+			reply = fmt.Sprintf("*%d\r\n", 2*len(h.hash))
+			for k, v := range h.hash {
+				reply += fmt.Sprintf("$%d\r\n%s\r\n$%d\r\n%s\r\n", len(k), k, len(v), v)
+			}
+		default:
+			reply = "+OK\r\n"
+		}
+		h.mut.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func TestCloseDueSpilledWindows(t *testing.T) {
+	server, client := newHashServer(t)
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	now := start
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		windowSeconds:  60,
+		windowGrace:    10 * time.Second,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		sources:        map[string]string{"fortinet.firewall": "connection_count", "paloalto.firewall": "connection_count"},
+		windows:        make(map[string]*WindowData),
+		clock:          func() time.Time { return now },
+		spill:          &windowSpill{client: client, key: "spilled", maxWindows: 1, spilled: make(map[string]time.Time)},
+	}
+	ctx := context.Background()
+
+	// The quiet source is spilled once the busy one is updated
+	detector.updateWindow("fortinet.firewall", 100, "192.168.1.1", start)
+	detector.updateWindow("paloalto.firewall", 100, "192.168.1.1", start.Add(time.Second))
+	detector.enforceMemoryBudget(ctx)
+	assert.Nil(t, detector.getWindow("fortinet.firewall"))
+	assert.Contains(t, detector.spill.spilled, "fortinet.firewall")
+
+	now = start.Add(65 * time.Second)
+	results, err := detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	assert.Contains(t, detector.spill.spilled, "fortinet.firewall", "not due before the grace period")
+
+	// Both windows are closed, and the spilled one leaves Redis
+	now = start.Add(70 * time.Second)
+	results, err = detector.closeDueWindows(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	sources := make([]interface{}, 0, len(results))
+	for _, msg := range results {
+		result, err := alertResult(msg)
+		require.NoError(t, err)
+		sources = append(sources, result["log_source"])
+	}
+	assert.ElementsMatch(t, []interface{}{"fortinet.firewall", "paloalto.firewall"}, sources)
+	assert.Empty(t, detector.spill.spilled)
+	server.mut.Lock()
+	assert.Empty(t, server.hash)
+	server.mut.Unlock()
+	assert.Empty(t, detector.windows)
+}
@@ -0,0 +1,148 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// stateVersion is the version of persisted window state written by this
+// build. It is bumped, with a migration registered in stateMigrations,
+// whenever the layout of WindowData changes incompatibly.
+//
+// Version 1 is the bare WindowData document written before state was
+// versioned. Version 2 wraps it in a windowStateEnvelope and records the
+// window's source and tenant.
+const stateVersion = 2
+
+// stateMigrations upgrade a decoded window document from the version they
+// are keyed by to the next one.
+var stateMigrations = map[int]func(key string, window map[string]interface{}) error{
+	1: func(key string, window map[string]interface{}) error {
+		// Unversioned windows were keyed by log source alone.
+		if source, _ := window["Source"].(string); source == "" {
+			window["Source"] = key
+		}
+		return nil
+	},
+}
+
+// windowStateEnvelope is the persisted form of a window.
+type windowStateEnvelope struct {
+	Version int             `json:"version"`
+	Window  json.RawMessage `json:"window"`
+}
+
+// encodeWindowState serialises a window in the current state version.
+func encodeWindowState(window *WindowData) (string, error) {
+	b, err := json.Marshal(window)
+	if err != nil {
+		return "", err
+	}
+	env, err := json.Marshal(windowStateEnvelope{Version: stateVersion, Window: b})
+	if err != nil {
+		return "", err
+	}
+	return string(env), nil
+}
+
+// decodeWindowState parses persisted window state of any supported version,
+// migrating it to the current one. It also reports the version it was
+// stored in.
+func decodeWindowState(key, raw string) (*WindowData, int, error) {
+	version, doc, err := splitWindowState(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	if version > stateVersion {
+		return nil, version, fmt.Errorf("window state version %d is newer than the supported version %d", version, stateVersion)
+	}
+
+	if version < stateVersion {
+		var window map[string]interface{}
+		if err := json.Unmarshal(doc, &window); err != nil {
+			return nil, version, err
+		}
+		for v := version; v < stateVersion; v++ {
+			migrate, exists := stateMigrations[v]
+			if !exists {
+				return nil, version, fmt.Errorf("no migration from window state version %d", v)
+			}
+			if err := migrate(key, window); err != nil {
+				return nil, version, fmt.Errorf("migrating window state from version %d: %w", v, err)
+			}
+		}
+		if doc, err = json.Marshal(window); err != nil {
+			return nil, version, err
+		}
+	}
+
+	var window WindowData
+	if err := json.Unmarshal(doc, &window); err != nil {
+		return nil, version, err
+	}
+	return &window, version, nil
+}
+
+// splitWindowState separates the version of persisted state from the window
+// document. Documents without an envelope are version 1.
+func splitWindowState(raw string) (int, json.RawMessage, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &probe); err != nil {
+		return 0, nil, err
+	}
+	if _, versioned := probe["version"]; !versioned {
+		return 1, json.RawMessage(raw), nil
+	}
+
+	var env windowStateEnvelope
+	if err := json.Unmarshal([]byte(raw), &env); err != nil {
+		return 0, nil, err
+	}
+	return env.Version, env.Window, nil
+}
+
+// StateMigrationResult summarises a state migration.
+type StateMigrationResult struct {
+	Migrated   int
+	Current    int
+	Unreadable int
+}
+
+// MigrateWindowStateHash rewrites every window stored in a Redis hash, such
+// as the shutdown, spill or handoff hashes, in the current state version.
+// Windows that can not be decoded are left untouched.
+func MigrateWindowStateHash(ctx context.Context, client *redis.Client, key string) (StateMigrationResult, error) {
+	var res StateMigrationResult
+
+	stored, err := client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return res, err
+	}
+
+	updated := make(map[string]interface{})
+	for field, raw := range stored {
+		window, version, err := decodeWindowState(field, raw)
+		if err != nil {
+			res.Unreadable++
+			continue
+		}
+		if version == stateVersion {
+			res.Current++
+			continue
+		}
+		if updated[field], err = encodeWindowState(window); err != nil {
+			return res, err
+		}
+		res.Migrated++
+	}
+
+	if len(updated) > 0 {
+		if err := client.HSet(ctx, key, updated).Err(); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
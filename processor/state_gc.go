@@ -0,0 +1,159 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func stateGCField() *service.ConfigField {
+	return service.NewObjectField("state_gc",
+		service.NewDurationField("max_idle").
+			Description("How long state may go untouched before it is reclaimed. Zero disables the collector.").
+			Default("0s"),
+		service.NewDurationField("interval").
+			Description("How often untouched state is collected").
+			Default("1m"),
+	).
+		Description("Periodic collection of the windows, adaptive threshold score histories, cumulative counter baselines, published baselines, source pattern matches, alert cooldowns and clock skews of sources that went quiet, which otherwise stay in memory for good").
+		Advanced()
+}
+
+// State reclaimed by the collector, used as the state label of
+// state_entries_reclaimed.
+const (
+	stateWindows          = "windows"
+	stateScoreHistories   = "score_histories"
+	stateCounterBaselines = "counter_baselines"
+	stateSourceMatches    = "source_matches"
+	stateAlertCooldowns   = "alert_cooldowns"
+	stateClockSkews       = "clock_skews"
+	stateBaselines        = "baselines"
+)
+
+// stateCollector periodically reclaims state untouched for maxIdle.
+type stateCollector struct {
+	maxIdle   time.Duration
+	interval  time.Duration
+	reclaimed *service.MetricCounter
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+func newStateCollectorFromConfig(conf *service.ParsedConfig, metrics *service.Metrics) (*stateCollector, error) {
+	maxIdle, err := conf.FieldDuration("max_idle")
+	if err != nil {
+		return nil, err
+	}
+	if maxIdle < 0 {
+		return nil, fmt.Errorf("state_gc.max_idle must not be negative, got %v", maxIdle)
+	}
+	if maxIdle == 0 {
+		return nil, nil
+	}
+	interval, err := conf.FieldDuration("interval")
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("state_gc.interval must be positive, got %v", interval)
+	}
+	return &stateCollector{
+		maxIdle:   maxIdle,
+		interval:  interval,
+		reclaimed: metrics.NewCounter("state_entries_reclaimed", "state"),
+	}, nil
+}
+
+func (f *FirewallAnomalyDetector) startStateGC() {
+	c := f.stateGC
+	if c == nil {
+		return
+	}
+
+	c.shutdown = make(chan struct{})
+	c.done = make(chan struct{})
+
+	go func() {
+		defer close(c.done)
+
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				f.collectState(context.Background(), time.Now())
+			case <-c.shutdown:
+				return
+			}
+		}
+	}()
+}
+
+func (f *FirewallAnomalyDetector) stopStateGC() {
+	c := f.stateGC
+	if c == nil || c.shutdown == nil {
+		return
+	}
+	close(c.shutdown)
+	<-c.done
+}
+
+// collectState reclaims the state untouched since maxIdle before now and
+// returns the number of entries reclaimed per kind of state.
+func (f *FirewallAnomalyDetector) collectState(ctx context.Context, now time.Time) map[string]int {
+	cutoff := now.Add(-f.stateGC.maxIdle)
+	reclaimed := map[string]int{
+		stateWindows:          f.expireWindows(ctx, cutoff),
+		stateScoreHistories:   f.adaptive.expire(cutoff),
+		stateCounterBaselines: f.units.expire(cutoff),
+		stateSourceMatches:    f.expireSourceMatches(now, cutoff),
+		stateAlertCooldowns:   f.alerts.expireCooldowns(cutoff),
+		stateClockSkews:       f.clockSkews.expire(cutoff),
+		stateBaselines:        f.baselines.expire(cutoff),
+	}
+
+	total := 0
+	for state, n := range reclaimed {
+		if n > 0 {
+			f.stateGC.reclaimed.Incr(int64(n), state)
+			total += n
+		}
+	}
+	if total > 0 {
+		f.logger.Debugf("Reclaimed %d idle state entries", total)
+	}
+	return reclaimed
+}
+
+// expireWindows removes the windows that received no log since cutoff.
+// Their logs are acknowledged, since they are never scored.
+func (f *FirewallAnomalyDetector) expireWindows(ctx context.Context, cutoff time.Time) int {
+	f.windowsMutex.Lock()
+	expired := make(map[string]*WindowData)
+	for key, window := range f.windows {
+		if window.UpdatedAt.Before(cutoff) {
+			expired[key] = window
+			delete(f.windows, key)
+		}
+	}
+	// Closed windows are forgotten along with them, after which late logs
+	// of the key are only bounded by max_lateness and no more empty windows
+	// are emitted for it
+	for key, closed := range f.closed {
+		if _, open := f.windows[key]; !open && closed.lastLog.Before(cutoff) {
+			delete(f.closed, key)
+		}
+	}
+	f.windowsMutex.Unlock()
+
+	for key, window := range expired {
+		f.replication.markDirty(key)
+		f.ackLogs(ctx, window.Pending...)
+	}
+	return len(expired)
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateCollectorConfig(t *testing.T) {
+	parse := func(yaml string) (*stateCollector, error) {
+		spec := service.NewConfigSpec().Field(stateGCField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newStateCollectorFromConfig(conf.Namespace("state_gc"), service.MockResources().Metrics())
+	}
+
+	c, err := parse(`state_gc: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = parse(`state_gc: { max_idle: 1h, interval: 5m }`)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, time.Hour, c.maxIdle)
+	assert.Equal(t, 5*time.Minute, c.interval)
+
+	_, err = parse(`state_gc: { max_idle: 1h, interval: 0s }`)
+	assert.Error(t, err)
+}
+
+func TestCollectState(t *testing.T) {
+	mgr := service.MockResources()
+	patterns, err := compileSourcePatterns(map[string]string{"fortinet.*": "connection_count"}, nil)
+	require.NoError(t, err)
+	detector := &FirewallAnomalyDetector{
+		logger:         mgr.Logger(),
+		windowSeconds:  60,
+		sources:        map[string]string{"fortinet.*": "connection_count"},
+		sourcePatterns: patterns,
+		sourceMatches:  &sync.Map{},
+		windows:        make(map[string]*WindowData),
+		consumer:       &logConsumer{},
+		units:          newUnitNormaliser(map[string]*sourceUnits{"fortinet.*": {scale: 1, cumulative: true}}),
+		adaptive:       &adaptiveThresholds{history: 10, scores: make(map[string]*scoreHistory)},
+		alerts:         &alertDispatcher{cooldown: newAlertCooldown(time.Minute)},
+		clockSkews:     &clockSkews{threshold: time.Minute, smoothing: 0.05, sources: make(map[string]*sourceSkew)},
+		baselines:      &baselineStore{smoothing: 0.1, baselines: make(map[string]*sourceBaseline)},
+		stateGC: &stateCollector{
+			maxIdle:   time.Hour,
+			reclaimed: mgr.Metrics().NewCounter("state_entries_reclaimed", "state"),
+		},
+		windowsCreated: mgr.Metrics().NewCounter("windows_created"),
+	}
+
+	now := time.Now()
+	for _, source := range []string{"fortinet.fw1", "fortinet.fw2"} {
+		assert.Equal(t, "fortinet.*", detector.sourceKey(source))
+		detector.updateWindow(source, 1, "10.0.0.1", now)
+		detector.units.normalise("fortinet.*", source, "connection_count", 1)
+		detector.adaptive.observe(source, 0.5)
+		detector.alerts.cooldown.admit(map[string]interface{}{"log_source": source, "reason": "hike_rate_detected"})
+		detector.clockSkews.observe(source, now, now)
+		detector.baselines.observe(source, source, "", detector.getWindow(source), nil, 0.5, 0.7)
+	}
+
+	// Nothing is idle for long enough yet
+	reclaimed := detector.collectState(context.Background(), now.Add(time.Minute))
+	for state, n := range reclaimed {
+		assert.Zero(t, n, state)
+	}
+
+	// fortinet.fw2 keeps logging, fortinet.fw1 went quiet
+	detector.windows["fortinet.fw1"].UpdatedAt = now.Add(-2 * time.Hour)
+	detector.units.last["fortinet.fw1"] = counterBaseline{value: 1, updated: now.Add(-2 * time.Hour)}
+	detector.adaptive.scores["fortinet.fw1"].updated = now.Add(-2 * time.Hour)
+	detector.alerts.cooldown.states[alertCooldownKey{logSource: "fortinet.fw1", detectionType: "hike_rate_detected"}].lastSent = now.Add(-2 * time.Hour)
+	detector.clockSkews.sources["fortinet.fw1"].updated = now.Add(-2 * time.Hour)
+	detector.baselines.baselines["fortinet.fw1"].updated = now.Add(-2 * time.Hour)
+
+	reclaimed = detector.collectState(context.Background(), now.Add(30*time.Minute))
+	assert.Equal(t, map[string]int{
+		stateWindows:          1,
+		stateScoreHistories:   1,
+		stateCounterBaselines: 1,
+		stateSourceMatches:    0,
+		stateAlertCooldowns:   1,
+		stateClockSkews:       1,
+		stateBaselines:        1,
+	}, reclaimed)
+	assert.Nil(t, detector.getWindow("fortinet.fw1"))
+	assert.NotNil(t, detector.getWindow("fortinet.fw2"))
+	assert.Contains(t, detector.units.last, "fortinet.fw2")
+	assert.Contains(t, detector.adaptive.scores, "fortinet.fw2")
+	assert.Contains(t, detector.clockSkews.sources, "fortinet.fw2")
+	assert.Contains(t, detector.baselines.baselines, "fortinet.fw2")
+
+	// Pattern matches are idle from the collection that finds them unused
+	detector.sourceKey("fortinet.fw2")
+	reclaimed = detector.collectState(context.Background(), now.Add(2*time.Hour))
+	assert.Equal(t, 1, reclaimed[stateSourceMatches])
+	_, cached := detector.sourceMatches.Load("fortinet.fw2")
+	assert.True(t, cached)
+	_, cached = detector.sourceMatches.Load("fortinet.fw1")
+	assert.False(t, cached)
+}
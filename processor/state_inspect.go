@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"context"
+	"encoding/gob"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+// WindowSummary describes a persisted window and the baseline it carries.
+type WindowSummary struct {
+	// Location is the Redis hash or snapshot file the window is stored in.
+	Location  string
+	Key       string
+	Source    string
+	Tenant    string
+	Samples   int
+	UniqueIPs int
+	Mean      float64
+	Max       float64
+	// Baseline and BaselineStdDev are the moving mean and standard
+	// deviation of the metric of the window key, set when HasBaseline is,
+	// i.e. for snapshots of a detector publishing its baselines.
+	Baseline       float64
+	BaselineStdDev float64
+	HasBaseline    bool
+	// Scores is the number of recent scores the adaptive threshold of the
+	// window key is derived from.
+	Scores    int
+	StartTime time.Time
+	EndTime   time.Time
+	UpdatedAt time.Time
+	Pending   int
+	// Unreadable is set for windows that could not be decoded, of which
+	// only the location and key are known.
+	Unreadable bool
+}
+
+func summariseWindow(location, key string, window *WindowData) WindowSummary {
+	source, tenant := windowScope(key, window)
+	summary := WindowSummary{
+		Location:  location,
+		Key:       key,
+		Source:    source,
+		Tenant:    tenant,
+		Samples:   len(window.Values),
+		UniqueIPs: window.uniqueIPs(),
+		StartTime: window.StartTime,
+		EndTime:   window.EndTime,
+		UpdatedAt: window.UpdatedAt,
+		Pending:   len(window.Pending),
+	}
+	if len(window.Values) > 0 {
+		summary.Mean = stat.Mean(window.Values, nil)
+		summary.Max = floats.Max(window.Values)
+	}
+	return summary
+}
+
+// isSourceKey reports whether a window key belongs to a source, for any
+// tenant.
+func isSourceKey(key, source string) bool {
+	escaped := windowKeyFor("", source)
+	return key == escaped || strings.HasSuffix(key, "/"+escaped)
+}
+
+func sortSummaries(summaries []WindowSummary) {
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Source != summaries[j].Source {
+			return summaries[i].Source < summaries[j].Source
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+}
+
+// InspectWindowStateHash summarises the windows stored in a Redis hash, such
+// as the shutdown, spill or handoff hashes, ordered by source.
+func InspectWindowStateHash(ctx context.Context, client *redis.Client, key string) ([]WindowSummary, error) {
+	stored, err := client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]WindowSummary, 0, len(stored))
+	for field, raw := range stored {
+		window, _, err := decodeWindowState(field, raw)
+		if err != nil {
+			summaries = append(summaries, WindowSummary{Location: key, Key: field, Unreadable: true})
+			continue
+		}
+		summaries = append(summaries, summariseWindow(key, field, window))
+	}
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+// InspectSnapshotFile summarises the windows of a window snapshot file,
+// ordered by source.
+func InspectSnapshotFile(path string) ([]WindowSummary, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	snapshot, err := (&snapshotter{path: path}).read()
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]WindowSummary, 0, len(snapshot.Windows))
+	for key, window := range snapshot.Windows {
+		summary := summariseWindow(path, key, window)
+		if baseline, exists := snapshot.Baselines[key]; exists {
+			summary.Baseline, summary.BaselineStdDev, summary.HasBaseline = baseline.Mean, baseline.StdDev, true
+		}
+		summary.Scores = len(snapshot.ScoreHistories[key])
+		summaries = append(summaries, summary)
+	}
+	sortSummaries(summaries)
+	return summaries, nil
+}
+
+// ResetWindowStateHash deletes the windows of a source, across all of its
+// tenants, from a Redis hash. Baselines are only persisted in snapshot
+// files. In ack consumption mode, logs still pending in the deleted windows
+// are re-delivered on the next start. It returns the number of windows
+// deleted.
+func ResetWindowStateHash(ctx context.Context, client *redis.Client, key, source string) (int, error) {
+	stored, err := client.HGetAll(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var fields []string
+	for field, raw := range stored {
+		window, _, err := decodeWindowState(field, raw)
+		if err != nil {
+			continue
+		}
+		if windowSource, _ := windowScope(field, window); windowSource == source {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	return len(fields), client.HDel(ctx, key, fields...).Err()
+}
+
+// ResetSnapshotFile deletes the windows, baselines and adaptive threshold
+// score histories of a source, across all of its tenants, from a window
+// snapshot file, so that its baseline is rebuilt from new logs. It returns
+// the number of windows deleted.
+func ResetSnapshotFile(path, source string) (int, error) {
+	s := &snapshotter{path: path}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	var snapshot windowSnapshot
+	err = gob.NewDecoder(file).Decode(&snapshot)
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+	if err := migrateSnapshot(&snapshot); err != nil {
+		return 0, err
+	}
+
+	deleted, changed := 0, false
+	for key, window := range snapshot.Windows {
+		if windowSource, _ := windowScope(key, window); windowSource == source {
+			delete(snapshot.Windows, key)
+			deleted++
+		}
+	}
+	for key, baseline := range snapshot.Baselines {
+		if baseline.LogSource == source {
+			delete(snapshot.Baselines, key)
+			changed = true
+		}
+	}
+	for key := range snapshot.ScoreHistories {
+		if isSourceKey(key, source) {
+			delete(snapshot.ScoreHistories, key)
+			changed = true
+		}
+	}
+	if deleted == 0 && !changed {
+		return 0, nil
+	}
+	return deleted, s.write(snapshot)
+}
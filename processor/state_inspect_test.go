@@ -0,0 +1,85 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectAndResetSnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "windows.snapshot")
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	s := &snapshotter{path: path}
+	require.NoError(t, s.write(windowSnapshot{Windows: map[string]*WindowData{
+		"acme/fortinet.firewall": {
+			Source:    "fortinet.firewall",
+			Tenant:    "acme",
+			Values:    []float64{100, 300},
+			IPs:       map[string]bool{"192.168.1.1": true, "192.168.1.2": true},
+			StartTime: start,
+			Pending:   []string{`{"connection_count":100}`, `{"connection_count":300}`},
+		},
+		"globex/fortinet.firewall": {Source: "fortinet.firewall", Tenant: "globex", Values: []float64{10}},
+		"paloalto.firewall":        {Source: "paloalto.firewall", Values: []float64{50}},
+	}, Baselines: map[string]*sourceBaseline{
+		"acme/fortinet.firewall": {LogSource: "fortinet.firewall", Tenant: "acme", Mean: 5000, StdDev: 250},
+		"paloalto.firewall":      {LogSource: "paloalto.firewall", Mean: 40},
+	}, ScoreHistories: map[string][]float64{
+		"acme/fortinet.firewall":   {0.1, 0.2},
+		"globex/fortinet.firewall": {0.3},
+		"paloalto.firewall":        {0.4},
+	}}))
+
+	summaries, err := InspectSnapshotFile(path)
+	require.NoError(t, err)
+	require.Len(t, summaries, 3)
+
+	acme := summaries[0]
+	assert.Equal(t, "acme/fortinet.firewall", acme.Key)
+	assert.Equal(t, "fortinet.firewall", acme.Source)
+	assert.Equal(t, "acme", acme.Tenant)
+	assert.Equal(t, 2, acme.Samples)
+	assert.Equal(t, 2, acme.UniqueIPs)
+	assert.Equal(t, 200.0, acme.Mean)
+	assert.Equal(t, 300.0, acme.Max)
+	assert.True(t, acme.HasBaseline)
+	assert.Equal(t, 5000.0, acme.Baseline)
+	assert.Equal(t, 250.0, acme.BaselineStdDev)
+	assert.Equal(t, 2, acme.Scores)
+	assert.False(t, summaries[1].HasBaseline)
+	assert.Equal(t, 2, acme.Pending)
+	assert.Equal(t, "paloalto.firewall", summaries[2].Source)
+
+	// Resetting a source drops its windows for every tenant
+	deleted, err := ResetSnapshotFile(path, "fortinet.firewall")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	summaries, err = InspectSnapshotFile(path)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, "paloalto.firewall", summaries[0].Source)
+	assert.True(t, summaries[0].HasBaseline)
+	assert.Equal(t, 1, summaries[0].Scores)
+
+	// Baselines and score histories of the source are reset along with its
+	// windows
+	snapshot, err := s.read()
+	require.NoError(t, err)
+	assert.Len(t, snapshot.Baselines, 1)
+	assert.Contains(t, snapshot.Baselines, "paloalto.firewall")
+	assert.Equal(t, map[string][]float64{"paloalto.firewall": {0.4}}, snapshot.ScoreHistories)
+
+	deleted, err = ResetSnapshotFile(path, "unknown.firewall")
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestInspectSnapshotFileMissing(t *testing.T) {
+	_, err := InspectSnapshotFile(filepath.Join(t.TempDir(), "missing.snapshot"))
+	assert.Error(t, err)
+}
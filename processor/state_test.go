@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowStateRoundTrip(t *testing.T) {
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	window := &WindowData{
+		Source:    "fortinet.firewall",
+		Tenant:    "acme",
+		Values:    []float64{100, 120},
+		IPs:       map[string]bool{"192.168.1.1": true},
+		IPCounts:  map[string]int{"192.168.1.1": 2},
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+	}
+
+	raw, err := encodeWindowState(window)
+	require.NoError(t, err)
+
+	decoded, version, err := decodeWindowState("acme/fortinet.firewall", raw)
+	require.NoError(t, err)
+	assert.Equal(t, stateVersion, version)
+	assert.Equal(t, window.Values, decoded.Values)
+	assert.Equal(t, "acme", decoded.Tenant)
+}
+
+func TestWindowStateMigratesUnversioned(t *testing.T) {
+	raw := `{"Values":[100,120],"IPs":{"192.168.1.1":true},"IPCounts":{"192.168.1.1":2},"LastMean":0,"StartTime":"2024-01-15T10:00:00Z","EndTime":"2024-01-15T10:01:00Z"}`
+
+	window, version, err := decodeWindowState("fortinet.firewall", raw)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+	assert.Equal(t, "fortinet.firewall", window.Source)
+	assert.Equal(t, []float64{100, 120}, window.Values)
+}
+
+func TestWindowStateRejectsNewerVersion(t *testing.T) {
+	_, _, err := decodeWindowState("fortinet.firewall", `{"version":99,"window":{}}`)
+	assert.Error(t, err)
+}
+
+func TestMigrateSnapshotFile(t *testing.T) {
+	s := &snapshotter{path: filepath.Join(t.TempDir(), "windows.snapshot")}
+	require.NoError(t, s.write(windowSnapshot{Windows: map[string]*WindowData{
+		"fortinet.firewall": {Values: []float64{1}},
+	}}))
+
+	migrated, err := MigrateSnapshotFile(s.path)
+	require.NoError(t, err)
+	assert.False(t, migrated)
+
+	snapshot, err := s.read()
+	require.NoError(t, err)
+	assert.Equal(t, []float64{1}, snapshot.Windows["fortinet.firewall"].Values)
+}
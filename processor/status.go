@@ -0,0 +1,220 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func statusEndpointField() *service.ConfigField {
+	return service.NewObjectField("status_endpoint",
+		service.NewBoolField("enabled").
+			Description("Serve the status of this instance on the Redpanda Connect HTTP server").
+			Default(false),
+		service.NewStringField("path").
+			Description("Path the status is served at").
+			Default("/firewall_anomaly_detector/status"),
+	).
+		Description("Status endpoint reporting the loaded model, the configured sources, their active windows and last emissions, for checking why detections look stale").
+		Advanced()
+}
+
+// StatusReport is the status of a running detector instance.
+type StatusReport struct {
+	GeneratedAt    time.Time      `json:"generated_at"`
+	StartedAt      time.Time      `json:"started_at"`
+	Active         bool           `json:"active"`
+	DryRun         bool           `json:"dry_run"`
+	Model          ModelStatus    `json:"model"`
+	ScoreThreshold float64        `json:"score_threshold"`
+	AnomalyTopic   string         `json:"anomaly_topic"`
+	NormalTopic    string         `json:"normal_topic"`
+	ActiveWindows  int            `json:"active_windows"`
+	Sources        []SourceStatus `json:"sources"`
+}
+
+// ModelStatus describes the model loaded by a detector.
+type ModelStatus struct {
+	Path     string     `json:"path"`
+	Loaded   bool       `json:"loaded"`
+	Version  string     `json:"version,omitempty"`
+	LoadedAt *time.Time `json:"loaded_at,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// SourceStatus describes a source key of a detector: its settings, the
+// windows it has open and the windows it emitted.
+type SourceStatus struct {
+	Source         string     `json:"source"`
+	Configured     bool       `json:"configured"`
+	Metric         string     `json:"metric,omitempty"`
+	ScoreThreshold float64    `json:"score_threshold"`
+	WindowSeconds  int        `json:"window_seconds"`
+	ActiveWindows  int        `json:"active_windows"`
+	EmittedWindows int        `json:"emitted_windows"`
+	LastEmission   *time.Time `json:"last_emission,omitempty"`
+	LastAnomaly    *time.Time `json:"last_anomaly,omitempty"`
+}
+
+// sourceEmissions tracks the windows emitted for a source key.
+type sourceEmissions struct {
+	windows      int
+	lastEmission time.Time
+	lastAnomaly  time.Time
+}
+
+// statusTracker keeps the state reported by the status endpoint that the
+// detector does not otherwise hold.
+type statusTracker struct {
+	mut           sync.Mutex
+	startedAt     time.Time
+	modelLoaded   bool
+	modelVersion  string
+	modelLoadedAt time.Time
+	modelErr      error
+	emissions     map[string]*sourceEmissions
+}
+
+func newStatusTrackerFromConfig(conf *service.ParsedConfig) (*statusTracker, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return &statusTracker{
+		startedAt: time.Now(),
+		emissions: make(map[string]*sourceEmissions),
+	}, nil
+}
+
+// registerStatusEndpoint serves the status when the endpoint is enabled.
+func (f *FirewallAnomalyDetector) registerStatusEndpoint(conf *service.ParsedConfig) error {
+	if f.status == nil {
+		return nil
+	}
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return err
+	}
+	return registerEndpoint(f.resources, path, "Status of the firewall anomaly detector.", f.handleStatus)
+}
+
+func (s *statusTracker) recordModel(version string, at time.Time, err error) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.modelErr = err
+	if err == nil {
+		s.modelLoaded = true
+		s.modelVersion = version
+		s.modelLoadedAt = at
+	}
+}
+
+// recordEmission records a window emitted for a source key.
+func (s *statusTracker) recordEmission(sourceKey string, anomaly bool, at time.Time) {
+	if s == nil {
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	e, exists := s.emissions[sourceKey]
+	if !exists {
+		e = &sourceEmissions{}
+		s.emissions[sourceKey] = e
+	}
+	e.windows++
+	e.lastEmission = at
+	if anomaly {
+		e.lastAnomaly = at
+	}
+}
+
+// statusReport describes the detector. Sources that are no longer
+// configured, e.g. removed through the control channel, are listed while
+// they have open windows or emissions.
+func (f *FirewallAnomalyDetector) statusReport() StatusReport {
+	report := StatusReport{
+		GeneratedAt: f.now(),
+		Active:      f.replication.isActive(),
+		DryRun:      f.dryRun,
+		Model:       ModelStatus{Path: f.modelPath},
+	}
+
+	bySource := map[string]*SourceStatus{}
+	f.settingsMut.RLock()
+	report.ScoreThreshold = f.scoreThreshold
+	report.AnomalyTopic = f.anomalyTopic
+	report.NormalTopic = f.normalTopic
+	for key, metric := range f.sources {
+		threshold, exists := f.sourceThresholds[key]
+		if !exists {
+			threshold = f.scoreThreshold
+		}
+		length, exists := f.sourceWindows[key]
+		if !exists {
+			length = time.Duration(f.windowSeconds) * time.Second
+		}
+		bySource[key] = &SourceStatus{
+			Source:         key,
+			Configured:     true,
+			Metric:         metric,
+			ScoreThreshold: threshold,
+			WindowSeconds:  int(length / time.Second),
+		}
+	}
+	f.settingsMut.RUnlock()
+
+	unconfigured := func(key string) *SourceStatus {
+		s, exists := bySource[key]
+		if !exists {
+			s = &SourceStatus{Source: key}
+			bySource[key] = s
+		}
+		return s
+	}
+
+	for _, view := range f.windowViews("") {
+		unconfigured(f.sourceKey(view.Source)).ActiveWindows++
+		report.ActiveWindows++
+	}
+
+	s := f.status
+	s.mut.Lock()
+	report.StartedAt = s.startedAt
+	report.Model.Loaded = s.modelLoaded
+	report.Model.Version = s.modelVersion
+	report.Model.LoadedAt = optionalTime(s.modelLoadedAt)
+	if s.modelErr != nil {
+		report.Model.Error = s.modelErr.Error()
+	}
+	for key, e := range s.emissions {
+		source := unconfigured(key)
+		source.EmittedWindows = e.windows
+		source.LastEmission = optionalTime(e.lastEmission)
+		source.LastAnomaly = optionalTime(e.lastAnomaly)
+	}
+	s.mut.Unlock()
+
+	report.Sources = make([]SourceStatus, 0, len(bySource))
+	for _, source := range bySource {
+		report.Sources = append(report.Sources, *source)
+	}
+	sort.Slice(report.Sources, func(i, j int) bool { return report.Sources[i].Source < report.Sources[j].Source })
+	return report
+}
+
+func (f *FirewallAnomalyDetector) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(f.statusReport()); err != nil {
+		f.logger.Warnf("Failed to write status response: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusTrackerDisabledByDefault(t *testing.T) {
+	spec := service.NewConfigSpec().Field(statusEndpointField())
+	conf, err := spec.ParseYAML(`status_endpoint: {}`, nil)
+	require.NoError(t, err)
+
+	s, err := newStatusTrackerFromConfig(conf.Namespace("status_endpoint"))
+	require.NoError(t, err)
+	assert.Nil(t, s)
+	s.recordModel("abc", time.Now(), nil)
+	s.recordEmission("fortinet.firewall", true, time.Now())
+}
+
+func TestStatusReport(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector := &FirewallAnomalyDetector{
+		logger:         service.MockResources().Logger(),
+		clock:          func() time.Time { return now },
+		windowSeconds:  60,
+		modelPath:      "/etc/plugin/model.pkl",
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		sources: map[string]string{
+			"fortinet.firewall": "connection_count",
+			"paloalto.firewall": "bytes_sent",
+		},
+		sourceThresholds: map[string]float64{"paloalto.firewall": 0.9},
+		sourceWindows:    map[string]time.Duration{"paloalto.firewall": 5 * time.Minute},
+		windows:          make(map[string]*WindowData),
+		status:           &statusTracker{startedAt: now.Add(-time.Hour), emissions: make(map[string]*sourceEmissions)},
+	}
+
+	detector.status.recordModel("", now, errors.New("corrupt model"))
+	detector.status.recordModel("abc123", now.Add(-30*time.Minute), nil)
+	detector.status.recordEmission("fortinet.firewall", false, now.Add(-2*time.Minute))
+	detector.status.recordEmission("fortinet.firewall", true, now.Add(-time.Minute))
+	detector.status.recordEmission("cisco.asa", false, now.Add(-10*time.Minute))
+	detector.updateWindow("paloalto.firewall", 5, "192.168.1.1", now)
+
+	rec := httptest.NewRecorder()
+	detector.handleStatus(rec, httptest.NewRequest("GET", "/firewall_anomaly_detector/status", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var report StatusReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.True(t, report.Active)
+	assert.Equal(t, now.Add(-time.Hour), report.StartedAt)
+	assert.Equal(t, 1, report.ActiveWindows)
+
+	// A successful reload clears the error of a failed one
+	assert.True(t, report.Model.Loaded)
+	assert.Equal(t, "/etc/plugin/model.pkl", report.Model.Path)
+	assert.Equal(t, "abc123", report.Model.Version)
+	assert.Empty(t, report.Model.Error)
+
+	require.Len(t, report.Sources, 3)
+
+	// Sources that emitted without being configured are listed too
+	assert.Equal(t, "cisco.asa", report.Sources[0].Source)
+	assert.False(t, report.Sources[0].Configured)
+	assert.Equal(t, 1, report.Sources[0].EmittedWindows)
+	assert.Nil(t, report.Sources[0].LastAnomaly)
+
+	fortinet := report.Sources[1]
+	assert.True(t, fortinet.Configured)
+	assert.Equal(t, "connection_count", fortinet.Metric)
+	assert.Equal(t, 0.7, fortinet.ScoreThreshold)
+	assert.Equal(t, 60, fortinet.WindowSeconds)
+	assert.Equal(t, 0, fortinet.ActiveWindows)
+	assert.Equal(t, 2, fortinet.EmittedWindows)
+	require.NotNil(t, fortinet.LastEmission)
+	assert.Equal(t, now.Add(-time.Minute), *fortinet.LastEmission)
+	require.NotNil(t, fortinet.LastAnomaly)
+	assert.Equal(t, now.Add(-time.Minute), *fortinet.LastAnomaly)
+
+	paloalto := report.Sources[2]
+	assert.Equal(t, 0.9, paloalto.ScoreThreshold)
+	assert.Equal(t, 300, paloalto.WindowSeconds)
+	assert.Equal(t, 1, paloalto.ActiveWindows)
+	assert.Equal(t, 0, paloalto.EmittedWindows)
+	assert.Nil(t, paloalto.LastEmission)
+}
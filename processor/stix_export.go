@@ -0,0 +1,272 @@
+package processor
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// stixNamespace is the UUIDv5 namespace STIX 2.1 recommends for
+// deterministic identifiers.
+var stixNamespace = uuid.MustParse("00abedb4-aa42-466c-9c01-fed23315a9b7")
+
+// taxiiMediaType is the media type of TAXII 2.1 requests and responses.
+const taxiiMediaType = "application/taxii+json;version=2.1"
+
+func stixExportField() *service.ConfigField {
+	return service.NewObjectField("stix_export",
+		service.NewStringField("collection_url").
+			Description("URL of the TAXII 2.1 collection indicators are added to, e.g. `https://taxii.example.com/api1/collections/<id>/`. Leave empty to export nothing.").
+			Default(""),
+		service.NewStringField("username").
+			Description("Username of HTTP basic authentication with the TAXII server").
+			Default(""),
+		service.NewStringField("password").
+			Description("Password of HTTP basic authentication, either a literal, a `${ENV_VAR}` reference or a `vault:<path>#<key>` reference").
+			Secret().
+			Optional(),
+		service.NewStringField("token").
+			Description("Bearer token sent instead of basic authentication, either a literal, a `${ENV_VAR}` reference or a `vault:<path>#<key>` reference").
+			Secret().
+			Optional(),
+		service.NewStringListField("indicator_types").
+			Description("STIX indicator types of exported indicators").
+			Default([]string{"anomalous-activity"}),
+		service.NewIntField("confidence").
+			Description("STIX confidence of exported indicators, from 0 to 100").
+			Default(75),
+		service.NewDurationField("valid_for").
+			Description("How long exported indicators are valid from the window they were seen in").
+			Default("720h"),
+		service.NewDurationField("retention").
+			Description("How long an alerted window can still be confirmed and exported").
+			Default("168h"),
+		service.NewDurationField("timeout").
+			Description("Timeout of a single TAXII request").
+			Default("10s"),
+	).
+		Description("Exporter adding the external IPs of anomalies confirmed as true positives through `feedback` to a TAXII 2.1 collection as STIX 2.1 indicators. Requires `feedback`.").
+		Advanced()
+}
+
+// stixCandidate is an alerted window that is exported once confirmed.
+type stixCandidate struct {
+	source      string
+	tenant      string
+	score       float64
+	windowStart time.Time
+	windowEnd   time.Time
+	ips         []string
+}
+
+// stixExporter pushes the external IPs of confirmed anomalies to a TAXII
+// collection.
+type stixExporter struct {
+	client         *http.Client
+	collectionURL  string
+	username       string
+	password       string
+	token          string
+	indicatorTypes []string
+	confidence     int
+	validFor       time.Duration
+	retention      time.Duration
+
+	pushed *service.MetricCounter
+	failed *service.MetricCounter
+
+	mut        sync.Mutex
+	candidates map[string]stixCandidate // window ID -> window
+}
+
+func newSTIXExporterFromConfig(conf *service.ParsedConfig, metrics *service.Metrics, secrets *secretResolver) (*stixExporter, error) {
+	collectionURL, err := conf.FieldString("stix_export", "collection_url")
+	if err != nil || collectionURL == "" {
+		return nil, err
+	}
+
+	e := &stixExporter{
+		collectionURL: strings.TrimSuffix(collectionURL, "/"),
+		pushed:        metrics.NewCounter("stix_indicators_exported"),
+		failed:        metrics.NewCounter("stix_exports_failed"),
+		candidates:    make(map[string]stixCandidate),
+	}
+	if e.username, err = conf.FieldString("stix_export", "username"); err != nil {
+		return nil, err
+	}
+	if e.password, err = secrets.resolveCredential(conf, "stix_export", "password"); err != nil {
+		return nil, err
+	}
+	if e.token, err = secrets.resolveCredential(conf, "stix_export", "token"); err != nil {
+		return nil, err
+	}
+	if e.indicatorTypes, err = conf.FieldStringList("stix_export", "indicator_types"); err != nil {
+		return nil, err
+	}
+	if e.confidence, err = conf.FieldInt("stix_export", "confidence"); err != nil {
+		return nil, err
+	}
+	if e.confidence < 0 || e.confidence > 100 {
+		return nil, fmt.Errorf("stix_export.confidence must be between 0 and 100, got %d", e.confidence)
+	}
+	if e.validFor, err = conf.FieldDuration("stix_export", "valid_for"); err != nil {
+		return nil, err
+	}
+	if e.retention, err = conf.FieldDuration("stix_export", "retention"); err != nil {
+		return nil, err
+	}
+	if e.validFor <= 0 || e.retention <= 0 {
+		return nil, errors.New("stix_export.valid_for and stix_export.retention must be positive")
+	}
+	timeout, err := conf.FieldDuration("stix_export", "timeout")
+	if err != nil {
+		return nil, err
+	}
+	e.client = &http.Client{Timeout: timeout}
+	return e, nil
+}
+
+// externalIP reports whether an address, or aggregated IPv6 prefix, is
+// routable on the internet.
+func externalIP(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		prefix, err := netip.ParsePrefix(ip)
+		if err != nil {
+			return false
+		}
+		addr = prefix.Addr()
+	}
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// observeAlert keeps an alerted window with external IPs until it is
+// confirmed or expires.
+func (e *stixExporter) observeAlert(windowID, source, tenant string, score float64, window *WindowData, now time.Time) {
+	if e == nil {
+		return
+	}
+	var ips []string
+	for _, ip := range topIPs(window.IPCounts, topIPsLimit) {
+		if externalIP(ip.IP) {
+			ips = append(ips, ip.IP)
+		}
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	for id, candidate := range e.candidates {
+		if now.Sub(candidate.windowEnd) > e.retention {
+			delete(e.candidates, id)
+		}
+	}
+	if len(ips) > 0 {
+		e.candidates[windowID] = stixCandidate{
+			source:      source,
+			tenant:      tenant,
+			score:       score,
+			windowStart: window.StartTime,
+			windowEnd:   window.EndTime,
+			ips:         ips,
+		}
+	}
+}
+
+// confirm exports the indicators of a window labelled a true positive,
+// returning how many were exported. Windows this instance did not alert, or
+// that have no external IPs, export nothing.
+func (e *stixExporter) confirm(ctx context.Context, rec feedbackRecord) (int, error) {
+	if e == nil || rec.Label != feedbackTruePositive {
+		return 0, nil
+	}
+	e.mut.Lock()
+	candidate, exists := e.candidates[rec.WindowID]
+	delete(e.candidates, rec.WindowID)
+	e.mut.Unlock()
+	if !exists {
+		return 0, nil
+	}
+
+	objects := make([]map[string]interface{}, 0, len(candidate.ips))
+	for _, ip := range candidate.ips {
+		objects = append(objects, e.indicator(rec, candidate, ip))
+	}
+	if err := e.post(ctx, map[string]interface{}{"objects": objects}); err != nil {
+		e.failed.Incr(1)
+		// Keep the window so that relabelling it retries the export
+		e.mut.Lock()
+		e.candidates[rec.WindowID] = candidate
+		e.mut.Unlock()
+		return 0, err
+	}
+	e.pushed.Incr(int64(len(objects)))
+	return len(objects), nil
+}
+
+// indicator returns the STIX indicator of an IP of a confirmed window. IDs
+// derive from the window ID and IP, so that re-exports update rather than
+// duplicate indicators.
+func (e *stixExporter) indicator(rec feedbackRecord, candidate stixCandidate, ip string) map[string]interface{} {
+	pattern := fmt.Sprintf("[ipv4-addr:value = '%s']", ip)
+	if strings.Contains(ip, "/") {
+		pattern = fmt.Sprintf("[ipv6-addr:value ISSUBSET '%s']", ip)
+	} else if strings.Contains(ip, ":") {
+		pattern = fmt.Sprintf("[ipv6-addr:value = '%s']", ip)
+	}
+
+	labels := []string{"log_source:" + candidate.source}
+	if candidate.tenant != "" {
+		labels = append(labels, "tenant:"+candidate.tenant)
+	}
+	description := fmt.Sprintf("Offending IP of a firewall anomaly on %s scored %.2f, confirmed as a true positive", candidate.source, candidate.score)
+	if rec.Analyst != "" {
+		description += " by " + rec.Analyst
+	}
+
+	timestamp := rec.LabelledAt.UTC().Format(time.RFC3339Nano)
+	return map[string]interface{}{
+		"type":            "indicator",
+		"spec_version":    "2.1",
+		"id":              "indicator--" + uuid.NewSHA1(stixNamespace, []byte(rec.WindowID+"/"+ip)).String(),
+		"created":         timestamp,
+		"modified":        timestamp,
+		"name":            "Firewall anomaly IP " + ip,
+		"description":     description,
+		"indicator_types": e.indicatorTypes,
+		"pattern":         pattern,
+		"pattern_type":    "stix",
+		"valid_from":      candidate.windowStart.UTC().Format(time.RFC3339Nano),
+		"valid_until":     candidate.windowEnd.Add(e.validFor).UTC().Format(time.RFC3339Nano),
+		"confidence":      e.confidence,
+		"labels":          labels,
+		"external_references": []map[string]string{{
+			"source_name": "firewall_anomaly_detector",
+			"external_id": rec.WindowID,
+		}},
+	}
+}
+
+// post adds an envelope of objects to the collection.
+func (e *stixExporter) post(ctx context.Context, envelope map[string]interface{}) error {
+	headers := map[string]string{
+		"Accept":       taxiiMediaType,
+		"Content-Type": taxiiMediaType,
+	}
+	switch {
+	case e.token != "":
+		headers["Authorization"] = "Bearer " + e.token
+	case e.username != "":
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(e.username+":"+e.password))
+	}
+	return postAlertJSON(ctx, e.client, e.collectionURL+"/objects/", headers, envelope)
+}
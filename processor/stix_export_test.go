@@ -0,0 +1,151 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSTIXExporter(t *testing.T, yaml string) (*stixExporter, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(stixExportField()).Field(secretsField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	secrets, err := newSecretResolverFromConfig(conf.Namespace("secrets"))
+	require.NoError(t, err)
+	return newSTIXExporterFromConfig(conf, service.MockResources().Metrics(), secrets)
+}
+
+func TestSTIXExportConfig(t *testing.T) {
+	e, err := parseSTIXExporter(t, `stix_export: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	t.Setenv("TAXII_PASSWORD", "s3cret")
+	e, err = parseSTIXExporter(t, `stix_export: { collection_url: "http://taxii/api1/collections/abc/", username: soc, password: "${TAXII_PASSWORD}" }`)
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, "http://taxii/api1/collections/abc", e.collectionURL)
+	assert.Equal(t, "s3cret", e.password)
+	assert.Equal(t, 720*time.Hour, e.validFor)
+
+	_, err = parseSTIXExporter(t, `stix_export: { collection_url: "http://taxii", confidence: 101 }`)
+	assert.Error(t, err)
+	_, err = parseSTIXExporter(t, `stix_export: { collection_url: "http://taxii", retention: 0s }`)
+	assert.Error(t, err)
+}
+
+func TestExternalIP(t *testing.T) {
+	for ip, external := range map[string]bool{
+		"203.0.113.5":     true,
+		"2001:db8::/64":   true,
+		"10.0.0.1":        false,
+		"192.168.0.0/16":  false,
+		"127.0.0.1":       false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+		"224.0.0.1":       false,
+		"not an ip":       false,
+	} {
+		assert.Equal(t, external, externalIP(ip), ip)
+	}
+}
+
+func TestSTIXExportConfirm(t *testing.T) {
+	var envelope map[string]interface{}
+	status := http.StatusAccepted
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/api1/collections/abc/objects/", r.URL.Path)
+		assert.Equal(t, taxiiMediaType, r.Header.Get("Content-Type"))
+		assert.Equal(t, taxiiMediaType, r.Header.Get("Accept"))
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "soc", user)
+		assert.Equal(t, "s3cret", password)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&envelope))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	e, err := parseSTIXExporter(t, `
+stix_export:
+  collection_url: `+server.URL+`/api1/collections/abc/
+  username: soc
+  password: s3cret
+`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	window := &WindowData{
+		StartTime: start,
+		EndTime:   start.Add(time.Minute),
+		IPCounts:  map[string]int{"203.0.113.5": 10, "10.0.0.1": 20, "2001:db8::/64": 2},
+	}
+	e.observeAlert("w1", "fortinet.firewall", "acme", 0.9, window, start.Add(time.Minute))
+	e.observeAlert("w2", "fortinet.firewall", "", 0.9, &WindowData{IPCounts: map[string]int{"10.0.0.1": 5}}, start.Add(time.Minute))
+	assert.Len(t, e.candidates, 1, "windows without external IPs are not kept")
+
+	// Only true positives of alerted windows are exported
+	labelledAt := start.Add(time.Hour)
+	n, err := e.confirm(ctx, feedbackRecord{WindowID: "w1", Label: feedbackFalsePositive, LabelledAt: labelledAt})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	n, err = e.confirm(ctx, feedbackRecord{WindowID: "unknown", Label: feedbackTruePositive, LabelledAt: labelledAt})
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.Zero(t, requests)
+
+	// A failed export is retried when the window is labelled again
+	status = http.StatusInternalServerError
+	_, err = e.confirm(ctx, feedbackRecord{WindowID: "w1", Label: feedbackTruePositive, LabelledAt: labelledAt})
+	assert.Error(t, err)
+
+	status = http.StatusAccepted
+	n, err = e.confirm(ctx, feedbackRecord{WindowID: "w1", Label: feedbackTruePositive, Analyst: "soc-1", LabelledAt: labelledAt})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Empty(t, e.candidates)
+
+	objects := envelope["objects"].([]interface{})
+	require.Len(t, objects, 2)
+	patterns := map[string]map[string]interface{}{}
+	for _, object := range objects {
+		indicator := object.(map[string]interface{})
+		patterns[indicator["pattern"].(string)] = indicator
+	}
+	indicator := patterns["[ipv4-addr:value = '203.0.113.5']"]
+	require.NotNil(t, indicator)
+	assert.Contains(t, patterns, "[ipv6-addr:value ISSUBSET '2001:db8::/64']")
+	assert.Equal(t, "indicator", indicator["type"])
+	assert.Equal(t, "2.1", indicator["spec_version"])
+	assert.Equal(t, "stix", indicator["pattern_type"])
+	assert.Equal(t, "2024-01-15T10:00:00Z", indicator["valid_from"])
+	assert.Equal(t, "2024-02-14T10:01:00Z", indicator["valid_until"])
+	assert.Equal(t, []interface{}{"log_source:fortinet.firewall", "tenant:acme"}, indicator["labels"])
+	assert.Contains(t, indicator["description"], "by soc-1")
+
+	// IDs are deterministic so that re-exports do not duplicate indicators
+	assert.Equal(t, indicator["id"], e.indicator(feedbackRecord{WindowID: "w1"}, stixCandidate{}, "203.0.113.5")["id"])
+}
+
+func TestSTIXExportExpiry(t *testing.T) {
+	e := &stixExporter{retention: time.Hour, candidates: make(map[string]stixCandidate)}
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	e.observeAlert("w1", "fortinet.firewall", "", 0.9, &WindowData{EndTime: start, IPCounts: map[string]int{"203.0.113.5": 1}}, start)
+	e.observeAlert("w2", "fortinet.firewall", "", 0.9, &WindowData{EndTime: start.Add(2 * time.Hour), IPCounts: map[string]int{"203.0.113.6": 1}}, start.Add(2*time.Hour))
+	assert.NotContains(t, e.candidates, "w1")
+	assert.Contains(t, e.candidates, "w2")
+}
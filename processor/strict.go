@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func strictFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewBoolField("strict").
+			Description("Emit logs with an unknown source or metric field instead of only dropping them, so that misconfigurations surface immediately. Rejected logs are routed to `reject_topic`, or flagged as errors for the error handling of the pipeline when it is empty.").
+			Default(false),
+		service.NewStringField("reject_topic").
+			Description("Topic logs rejected in strict mode or by `validate_logs` are routed to. Empty flags them as errors instead.").
+			Default(""),
+	}
+}
+
+// strictMode routes logs that are rejected for a misconfiguration rather
+// than dropping them silently.
+type strictMode struct {
+	rejectTopic string
+}
+
+func newStrictModeFromConfig(conf *service.ParsedConfig) (*strictMode, error) {
+	strict, err := conf.FieldBool("strict")
+	if err != nil {
+		return nil, err
+	}
+	if !strict {
+		return nil, nil
+	}
+
+	rejectTopic, err := conf.FieldString("reject_topic")
+	if err != nil {
+		return nil, err
+	}
+	return &strictMode{rejectTopic: rejectTopic}, nil
+}
+
+// reject returns a message carrying a rejected log, or nil outside strict
+// mode.
+func (s *strictMode) reject(log FirewallLog, reason string, cause error) *service.Message {
+	if s == nil {
+		return nil
+	}
+	return rejectedLog(log, reason, s.rejectTopic, cause)
+}
+
+// rejectedLog returns a message carrying a log as it was read from Redis,
+// routed to rejectTopic or flagged as an error when it is empty.
+func rejectedLog(log FirewallLog, reason, rejectTopic string, cause error) *service.Message {
+	msg := service.NewMessage([]byte(log.original))
+	msg.MetaSet("reject_reason", reason)
+	msg.MetaSet("log_source", log.LogSource)
+	if log.tenant != "" {
+		msg.MetaSet("tenant", log.tenant)
+	}
+	if rejectTopic != "" {
+		msg.MetaSet("topic", rejectTopic)
+		msg.MetaSet("reject_error", cause.Error())
+	} else {
+		msg.SetError(fmt.Errorf("log rejected: %w", cause))
+	}
+	return msg
+}
@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStrictTestDetector(t *testing.T, yaml string) *FirewallAnomalyDetector {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Fields(strictFields()...)
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	strict, err := newStrictModeFromConfig(conf)
+	require.NoError(t, err)
+
+	return &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		sources: map[string]string{
+			"fortinet.firewall": "connection_count",
+			"paloalto.firewall": "packets",
+		},
+		strict: strict,
+	}
+}
+
+func TestStrictModeRejectTopic(t *testing.T) {
+	f := newStrictTestDetector(t, `{ strict: true, reject_topic: firewall-rejected }`)
+
+	log := FirewallLog{LogSource: "cisco.asa", original: `{"log_source":"cisco.asa"}`}
+	msg, err := f.processLog(context.Background(), log)
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	require.NoError(t, msg.GetError())
+
+	body, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, `{"log_source":"cisco.asa"}`, string(body))
+	topic, _ := msg.MetaGet("topic")
+	assert.Equal(t, "firewall-rejected", topic)
+	reason, _ := msg.MetaGet("reject_reason")
+	assert.Equal(t, dropReasonUnknownSource, reason)
+}
+
+func TestStrictModeErrorOutput(t *testing.T) {
+	f := newStrictTestDetector(t, `{ strict: true }`)
+
+	msg, err := f.processLog(context.Background(), FirewallLog{LogSource: "paloalto.firewall"})
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.ErrorContains(t, msg.GetError(), "unknown metric field packets")
+	reason, _ := msg.MetaGet("reject_reason")
+	assert.Equal(t, dropReasonUnknownMetric, reason)
+}
+
+func TestStrictModeDisabled(t *testing.T) {
+	f := newStrictTestDetector(t, `{}`)
+	assert.Nil(t, f.strict)
+
+	msg, err := f.processLog(context.Background(), FirewallLog{LogSource: "cisco.asa"})
+	require.NoError(t, err)
+	assert.Nil(t, msg)
+}
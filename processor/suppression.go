@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/robfig/cron/v3"
+)
+
+func suppressionSchedulesField() *service.ConfigField {
+	return service.NewObjectListField("suppression_schedules",
+		service.NewStringField("name").
+			Description("Name of the maintenance window, attached to suppressed results"),
+		service.NewStringListField("sources").
+			Description("Log sources the schedule applies to. Applies to every source when empty.").
+			Default([]string{}),
+		service.NewStringField("start").
+			Description("RFC 3339 start of a one-off maintenance window").
+			Default(""),
+		service.NewStringField("end").
+			Description("RFC 3339 end of a one-off maintenance window").
+			Default(""),
+		service.NewStringField("cron").
+			Description("Standard five field cron expression at which a recurring maintenance window opens").
+			Example("0 2 * * 6").
+			Default(""),
+		service.NewDurationField("duration").
+			Description("How long a recurring maintenance window stays open").
+			Default("1h"),
+		service.NewStringField("timezone").
+			Description("IANA timezone the cron expression is evaluated in").
+			Default("UTC"),
+	).
+		Description("Maintenance windows during which detections are tagged `suppressed: true`, routed to the normal topic and not alerted on").
+		Default([]interface{}{}).
+		Advanced()
+}
+
+type suppressionSchedule struct {
+	name    string
+	sources map[string]struct{}
+
+	start, end time.Time
+
+	cron     cron.Schedule
+	duration time.Duration
+	location *time.Location
+}
+
+func newSuppressionSchedulesFromConfig(confs []*service.ParsedConfig) ([]*suppressionSchedule, error) {
+	schedules := make([]*suppressionSchedule, 0, len(confs))
+	for i, conf := range confs {
+		schedule, err := newSuppressionScheduleFromConfig(conf)
+		if err != nil {
+			return nil, fmt.Errorf("suppression schedule %d: %w", i, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func newSuppressionScheduleFromConfig(conf *service.ParsedConfig) (*suppressionSchedule, error) {
+	name, err := conf.FieldString("name")
+	if err != nil {
+		return nil, err
+	}
+
+	sourceList, err := conf.FieldStringList("sources")
+	if err != nil {
+		return nil, err
+	}
+	sources := make(map[string]struct{}, len(sourceList))
+	for _, source := range sourceList {
+		sources[source] = struct{}{}
+	}
+
+	startStr, err := conf.FieldString("start")
+	if err != nil {
+		return nil, err
+	}
+
+	endStr, err := conf.FieldString("end")
+	if err != nil {
+		return nil, err
+	}
+
+	cronStr, err := conf.FieldString("cron")
+	if err != nil {
+		return nil, err
+	}
+
+	duration, err := conf.FieldDuration("duration")
+	if err != nil {
+		return nil, err
+	}
+
+	timezone, err := conf.FieldString("timezone")
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule := &suppressionSchedule{
+		name:     name,
+		sources:  sources,
+		duration: duration,
+		location: location,
+	}
+
+	switch {
+	case cronStr != "" && (startStr != "" || endStr != ""):
+		return nil, fmt.Errorf("%s: cron and start/end are mutually exclusive", name)
+	case cronStr != "":
+		if schedule.cron, err = cron.ParseStandard(cronStr); err != nil {
+			return nil, fmt.Errorf("%s: invalid cron expression: %w", name, err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("%s: duration must be positive, got %v", name, duration)
+		}
+	case startStr != "" && endStr != "":
+		if schedule.start, err = time.Parse(time.RFC3339, startStr); err != nil {
+			return nil, fmt.Errorf("%s: invalid start: %w", name, err)
+		}
+		if schedule.end, err = time.Parse(time.RFC3339, endStr); err != nil {
+			return nil, fmt.Errorf("%s: invalid end: %w", name, err)
+		}
+		if !schedule.end.After(schedule.start) {
+			return nil, fmt.Errorf("%s: end must be after start", name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: either cron or both start and end must be set", name)
+	}
+	return schedule, nil
+}
+
+// active reports whether the schedule suppresses detections for logSource at t.
+func (s *suppressionSchedule) active(logSource string, t time.Time) bool {
+	if len(s.sources) > 0 {
+		if _, exists := s.sources[logSource]; !exists {
+			return false
+		}
+	}
+
+	if s.cron == nil {
+		return !t.Before(s.start) && t.Before(s.end)
+	}
+
+	// The window is open when the schedule fired within the last duration.
+	t = t.In(s.location)
+	next := s.cron.Next(t.Add(-s.duration))
+	return !next.After(t)
+}
+
+// activeSuppression returns the name of the first schedule suppressing
+// detections for logSource at t, or an empty string.
+func (f *FirewallAnomalyDetector) activeSuppression(logSource string, t time.Time) string {
+	for _, schedule := range f.suppressions {
+		if schedule.active(logSource, t) {
+			return schedule.name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,95 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseSuppressionSchedules(t *testing.T, yaml string) ([]*suppressionSchedule, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(suppressionSchedulesField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	confs, err := conf.FieldObjectList("suppression_schedules")
+	require.NoError(t, err)
+	return newSuppressionSchedulesFromConfig(confs)
+}
+
+func TestSuppressionSchedules(t *testing.T) {
+	schedules, err := parseSuppressionSchedules(t, `
+suppression_schedules:
+  - name: pentest
+    sources: [ fortinet.firewall ]
+    start: 2024-01-15T10:00:00Z
+    end: 2024-01-15T12:00:00Z
+  - name: weekly-patching
+    cron: "0 2 * * 6"
+    duration: 2h
+    timezone: Europe/Amsterdam
+`)
+	require.NoError(t, err)
+
+	detector := &FirewallAnomalyDetector{suppressions: schedules}
+
+	tests := []struct {
+		source   string
+		at       string
+		expected string
+	}{
+		{"fortinet.firewall", "2024-01-15T11:00:00Z", "pentest"},
+		{"paloalto.firewall", "2024-01-15T11:00:00Z", ""},
+		{"fortinet.firewall", "2024-01-15T12:00:00Z", ""},
+		// Saturday 02:00-04:00 Amsterdam time is 01:00-03:00 UTC in winter.
+		{"paloalto.firewall", "2024-01-20T01:00:00Z", "weekly-patching"},
+		{"paloalto.firewall", "2024-01-20T02:59:59Z", "weekly-patching"},
+		{"paloalto.firewall", "2024-01-20T03:00:00Z", ""},
+		{"paloalto.firewall", "2024-01-20T00:59:59Z", ""},
+		{"paloalto.firewall", "2024-01-21T01:30:00Z", ""},
+	}
+
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, detector.activeSuppression(test.source, at), "%s at %s", test.source, test.at)
+	}
+}
+
+func TestSuppressionScheduleValidation(t *testing.T) {
+	_, err := parseSuppressionSchedules(t, `
+suppression_schedules:
+  - name: incomplete
+    start: 2024-01-15T10:00:00Z
+`)
+	assert.Error(t, err)
+
+	_, err = parseSuppressionSchedules(t, `
+suppression_schedules:
+  - name: bad-cron
+    cron: "not a cron"
+`)
+	assert.Error(t, err)
+
+	_, err = parseSuppressionSchedules(t, `
+suppression_schedules:
+  - name: backwards
+    start: 2024-01-15T12:00:00Z
+    end: 2024-01-15T10:00:00Z
+`)
+	assert.Error(t, err)
+
+	for _, duration := range []string{"0s", "-1h"} {
+		_, err = parseSuppressionSchedules(t, `
+suppression_schedules:
+  - name: empty
+    cron: "0 2 * * *"
+    duration: `+duration+`
+`)
+		assert.ErrorContains(t, err, "empty: duration must be positive", duration)
+	}
+}
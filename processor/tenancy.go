@@ -0,0 +1,122 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func tenantFields() []*service.ConfigField {
+	return []*service.ConfigField{
+		service.NewStringField("tenant_field").
+			Description("Dot separated path of a field in each log identifying its tenant, e.g. `raw.customer_id`. When set, windows, baselines, thresholds, metric labels and output metadata are scoped per tenant.").
+			Default(""),
+		service.NewObjectMapField("tenants",
+			service.NewFloatField("score_threshold").
+				Description("Anomaly threshold for this tenant, overriding `score_threshold`").
+				Optional(),
+		).
+			Description("Per-tenant overrides keyed by tenant").
+			Default(map[string]interface{}{}).
+			Advanced(),
+	}
+}
+
+// tenantConfig holds the optional per-tenant scoping of the detector.
+type tenantConfig struct {
+	path       []string
+	thresholds map[string]float64
+}
+
+func newTenantConfigFromParsed(conf *service.ParsedConfig) (*tenantConfig, error) {
+	field, err := conf.FieldString("tenant_field")
+	if err != nil {
+		return nil, err
+	}
+	if field == "" {
+		return nil, nil
+	}
+
+	t := &tenantConfig{
+		path:       strings.Split(field, "."),
+		thresholds: make(map[string]float64),
+	}
+
+	tenants, err := conf.FieldObjectMap("tenants")
+	if err != nil {
+		return nil, err
+	}
+	for tenant, tenantConf := range tenants {
+		if !tenantConf.Contains("score_threshold") {
+			continue
+		}
+		if t.thresholds[tenant], err = tenantConf.FieldFloat("score_threshold"); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// tenantOf resolves the tenant of a decoded log document, returning an empty
+// string when the field is missing.
+func (t *tenantConfig) tenantOf(doc map[string]interface{}) string {
+	if t == nil {
+		return ""
+	}
+
+	var v interface{} = doc
+	for _, segment := range t.path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if v, ok = obj[segment]; !ok {
+			return ""
+		}
+	}
+
+	switch tenant := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tenant
+	default:
+		return fmt.Sprintf("%v", tenant)
+	}
+}
+
+// windowKeyEscaper escapes the tenant and source of a window key, so that
+// the separator only ever appears between them.
+var windowKeyEscaper = strings.NewReplacer("%", "%25", "/", "%2F")
+
+// windowKeyFor returns the key of the window a log contributes to. Windows of
+// different tenants never share a key, whatever their tenant and source
+// contain.
+func windowKeyFor(tenant, source string) string {
+	if tenant == "" {
+		return windowKeyEscaper.Replace(source)
+	}
+	return windowKeyEscaper.Replace(tenant) + "/" + windowKeyEscaper.Replace(source)
+}
+
+// thresholdFor returns the anomaly threshold that applies to a window of a
+// tenant's log source. Tenant overrides take precedence over source
+// overrides, which take precedence over the global threshold. Adjustments
+// of the threshold controller apply on top of them.
+func (f *FirewallAnomalyDetector) thresholdFor(tenant, source string) float64 {
+	f.settingsMut.RLock()
+	sourceKey := f.sourceKeyLocked(source)
+	threshold, exists := f.sourceThresholds[sourceKey]
+	if !exists {
+		threshold = f.scoreThreshold
+	}
+	f.settingsMut.RUnlock()
+
+	if f.tenants != nil {
+		if tenantThreshold, exists := f.tenants.thresholds[tenant]; exists {
+			threshold = tenantThreshold
+		}
+	}
+	return f.thresholdControl.adjust(sourceKey, threshold)
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTenantConfig(t *testing.T, yaml string) *tenantConfig {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Fields(tenantFields()...)
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	tenants, err := newTenantConfigFromParsed(conf)
+	require.NoError(t, err)
+	return tenants
+}
+
+func TestTenantOf(t *testing.T) {
+	tenants := parseTenantConfig(t, `tenant_field: raw.customer.id`)
+
+	assert.Equal(t, "acme", tenants.tenantOf(map[string]interface{}{
+		"raw": map[string]interface{}{"customer": map[string]interface{}{"id": "acme"}},
+	}))
+	assert.Equal(t, "42", tenants.tenantOf(map[string]interface{}{
+		"raw": map[string]interface{}{"customer": map[string]interface{}{"id": 42.0}},
+	}))
+	assert.Equal(t, "", tenants.tenantOf(map[string]interface{}{"raw": "acme"}))
+	assert.Equal(t, "", tenants.tenantOf(map[string]interface{}{}))
+
+	assert.Nil(t, parseTenantConfig(t, `{}`))
+}
+
+func TestTenantScopedWindows(t *testing.T) {
+	tenants := parseTenantConfig(t, `
+tenant_field: tenant
+tenants:
+  acme:
+    score_threshold: 0.1
+`)
+
+	detector := &FirewallAnomalyDetector{
+		windowSeconds:  60,
+		scoreThreshold: 0.7,
+		anomalyTopic:   "firewall-anomalies",
+		normalTopic:    "firewall-normal",
+		windows:        make(map[string]*WindowData),
+		tenants:        tenants,
+	}
+	assert.Equal(t, 0.1, detector.thresholdFor("acme", "fortinet.firewall"))
+	assert.Equal(t, 0.7, detector.thresholdFor("globex", "fortinet.firewall"))
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for _, tenant := range []string{"acme", "globex"} {
+		key := windowKeyFor(tenant, "fortinet.firewall")
+		detector.updateScopedWindow(key, tenant, "fortinet.firewall", 10, "192.168.1.1", now)
+		detector.updateScopedWindow(key, tenant, "fortinet.firewall", 1000, "192.168.1.2", now.Add(time.Second))
+	}
+	require.Len(t, detector.windows, 2)
+
+	key := windowKeyFor("acme", "fortinet.firewall")
+	msg, err := detector.scoreWindow(context.Background(), key, detector.getWindow(key), "connection_count", 1000, false)
+	require.NoError(t, err)
+
+	result, err := alertResult(msg)
+	require.NoError(t, err)
+	assert.Equal(t, "fortinet.firewall", result["log_source"])
+	assert.Equal(t, "acme", result["tenant"])
+	assert.Equal(t, true, result["is_anomaly"])
+
+	tenant, _ := msg.MetaGet("tenant")
+	assert.Equal(t, "acme", tenant)
+}
+
+func TestWindowKeysDoNotCollide(t *testing.T) {
+	scopes := [][2]string{
+		{"acme", "fw"},
+		{"acme/", "fw"},
+		{"acme", "/fw"},
+		{"", "acme/fw"},
+		{"acme%2F", "fw"},
+		{"", "acme%2Ffw"},
+		{"", "fw"},
+	}
+
+	keys := make(map[string][2]string, len(scopes))
+	for _, scope := range scopes {
+		key := windowKeyFor(scope[0], scope[1])
+		if other, exists := keys[key]; exists {
+			t.Errorf("tenant %q source %q shares key %q with tenant %q source %q", scope[0], scope[1], key, other[0], other[1])
+		}
+		keys[key] = scope
+	}
+
+	// Plain names keep readable keys
+	assert.Equal(t, "fortinet.firewall", windowKeyFor("", "fortinet.firewall"))
+	assert.Equal(t, "acme/fortinet.firewall", windowKeyFor("acme", "fortinet.firewall"))
+	assert.True(t, isSourceKey(windowKeyFor("acme", "a/b"), "a/b"))
+	assert.False(t, isSourceKey(windowKeyFor("acme", "a/b"), "b"))
+}
@@ -0,0 +1,370 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// Reasons for threshold adjustments.
+const (
+	adjustAlertBudget      = "alert_budget_exceeded"
+	adjustPrecisionLow     = "precision_below_target"
+	adjustPrecisionHigh    = "precision_above_target"
+	adjustUnderAlertBudget = "under_alert_budget"
+)
+
+// alertedWindowsRetention is how long after an alert its label still counts.
+const alertedWindowsRetention = 7 * 24 * time.Hour
+
+func thresholdControlField() *service.ConfigField {
+	return service.NewObjectField("threshold_control",
+		service.NewFloatField("target_precision").
+			Description("Share of the labelled alerts of a source that should be true positives, e.g. `0.8`. Thresholds are raised below it and lowered above it. Requires `feedback`. Zero disables the precision target.").
+			Default(0.0),
+		service.NewIntField("max_alerts_per_day").
+			Description("Alert budget of a source over the last 24 hours. Thresholds are raised while it is exceeded and relaxed back towards their configured value once alerts fall below half of it. Zero disables the budget.").
+			Default(0),
+		service.NewIntField("min_labels").
+			Description("Labels a source needs before its precision moves its threshold").
+			Default(10),
+		service.NewIntField("label_history").
+			Description("Number of recent labels per source its precision is computed over").
+			Default(50),
+		service.NewFloatField("step").
+			Description("How far a threshold moves in one adjustment").
+			Default(0.01),
+		service.NewFloatField("min_threshold").
+			Description("Lowest threshold the controller sets").
+			Default(0.5),
+		service.NewFloatField("max_threshold").
+			Description("Highest threshold the controller sets").
+			Default(0.99),
+		service.NewDurationField("interval").
+			Description("How often thresholds are evaluated").
+			Default("1h"),
+		service.NewStringField("audit_path").
+			Description("File every threshold adjustment is appended to as a JSON line").
+			Default(""),
+	).
+		Description("Controller nudging the thresholds of sources to hold a target precision of analyst labels and an alert budget. Disabled unless `target_precision` or `max_alerts_per_day` is set. Adjustments apply on top of configured and runtime thresholds, and are kept in memory.").
+		Advanced()
+}
+
+// thresholdAdjustment is the audit trail entry of one threshold change.
+type thresholdAdjustment struct {
+	AdjustedAt        time.Time `json:"adjusted_at"`
+	Source            string    `json:"log_source"`
+	Reason            string    `json:"reason"`
+	PreviousThreshold float64   `json:"previous_threshold"`
+	Threshold         float64   `json:"threshold"`
+	AlertsPerDay      int       `json:"alerts_per_day"`
+	Labels            int       `json:"labels"`
+	Precision         *float64  `json:"precision,omitempty"`
+}
+
+// thresholdController adjusts the thresholds of source keys from their
+// alert rate and the labels analysts give their alerts.
+type thresholdController struct {
+	targetPrecision float64
+	maxAlertsPerDay int
+	minLabels       int
+	labelHistory    int
+	step            float64
+	minThreshold    float64
+	maxThreshold    float64
+	interval        time.Duration
+
+	adjustments *service.MetricCounter
+	auditFile   *os.File
+
+	mut           sync.Mutex
+	sources       map[string]*controlledSource // source key -> state
+	alerted       map[string]alertedWindow     // window ID -> alert
+	nextEvaluated time.Time
+}
+
+// controlledSource is the controller state of a source key.
+type controlledSource struct {
+	// offset is added to the configured threshold of the source
+	offset float64
+	alerts []time.Time
+	labels []bool // true positives, oldest first
+}
+
+// alertedWindow is an alert that may still be labelled.
+type alertedWindow struct {
+	sourceKey string
+	at        time.Time
+}
+
+func newThresholdControllerFromConfig(conf *service.ParsedConfig, metrics *service.Metrics) (*thresholdController, error) {
+	c := &thresholdController{sources: make(map[string]*controlledSource), alerted: make(map[string]alertedWindow)}
+	var err error
+	if c.targetPrecision, err = conf.FieldFloat("target_precision"); err != nil {
+		return nil, err
+	}
+	if c.maxAlertsPerDay, err = conf.FieldInt("max_alerts_per_day"); err != nil {
+		return nil, err
+	}
+	if c.targetPrecision == 0 && c.maxAlertsPerDay == 0 {
+		return nil, nil
+	}
+	if c.targetPrecision < 0 || c.targetPrecision >= 1 {
+		return nil, fmt.Errorf("threshold_control.target_precision must be between 0 and 1, got %v", c.targetPrecision)
+	}
+	if c.maxAlertsPerDay < 0 {
+		return nil, fmt.Errorf("threshold_control.max_alerts_per_day must not be negative, got %d", c.maxAlertsPerDay)
+	}
+	if c.minLabels, err = conf.FieldInt("min_labels"); err != nil {
+		return nil, err
+	}
+	if c.labelHistory, err = conf.FieldInt("label_history"); err != nil {
+		return nil, err
+	}
+	if c.minLabels <= 0 || c.minLabels > c.labelHistory {
+		return nil, errors.New("threshold_control.min_labels must be positive and no more than label_history")
+	}
+	if c.step, err = conf.FieldFloat("step"); err != nil {
+		return nil, err
+	}
+	if c.step <= 0 {
+		return nil, fmt.Errorf("threshold_control.step must be positive, got %v", c.step)
+	}
+	if c.minThreshold, err = conf.FieldFloat("min_threshold"); err != nil {
+		return nil, err
+	}
+	if c.maxThreshold, err = conf.FieldFloat("max_threshold"); err != nil {
+		return nil, err
+	}
+	if c.minThreshold < 0 || c.minThreshold > c.maxThreshold || c.maxThreshold > 1 {
+		return nil, errors.New("threshold_control thresholds must satisfy 0 <= min_threshold <= max_threshold <= 1")
+	}
+	if c.interval, err = conf.FieldDuration("interval"); err != nil {
+		return nil, err
+	}
+	if c.interval <= 0 {
+		return nil, fmt.Errorf("threshold_control.interval must be positive, got %v", c.interval)
+	}
+
+	auditPath, err := conf.FieldString("audit_path")
+	if err != nil {
+		return nil, err
+	}
+	if auditPath != "" {
+		if c.auditFile, err = os.OpenFile(auditPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640); err != nil {
+			return nil, err
+		}
+	}
+	c.adjustments = metrics.NewCounter("threshold_adjustments", "log_source", "reason")
+	return c, nil
+}
+
+// adjust returns the threshold of a source key with its adjustment applied.
+func (c *thresholdController) adjust(sourceKey string, threshold float64) float64 {
+	if c == nil {
+		return threshold
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	s, exists := c.sources[sourceKey]
+	if !exists || s.offset == 0 {
+		return threshold
+	}
+	return c.clamp(threshold + s.offset)
+}
+
+func (c *thresholdController) clamp(threshold float64) float64 {
+	return math.Min(math.Max(threshold, c.minThreshold), c.maxThreshold)
+}
+
+func (c *thresholdController) source(sourceKey string) *controlledSource {
+	s, exists := c.sources[sourceKey]
+	if !exists {
+		s = &controlledSource{}
+		c.sources[sourceKey] = s
+	}
+	return s
+}
+
+// observeAlert records an alert of a source key, which analysts may label
+// by its window ID.
+func (c *thresholdController) observeAlert(sourceKey, windowID string, at time.Time) {
+	if c == nil {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	s := c.source(sourceKey)
+	s.alerts = append(s.alerts, at)
+	if c.targetPrecision > 0 {
+		c.alerted[windowID] = alertedWindow{sourceKey: sourceKey, at: at}
+	}
+}
+
+// observeLabel records the label of an alert. Labels of windows this
+// instance did not alert, or alerted too long ago, are ignored.
+func (c *thresholdController) observeLabel(rec feedbackRecord) bool {
+	if c == nil || c.targetPrecision == 0 {
+		return false
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	alert, exists := c.alerted[rec.WindowID]
+	if !exists {
+		return false
+	}
+	// Each alert counts once, however often it is relabelled
+	delete(c.alerted, rec.WindowID)
+
+	s := c.source(alert.sourceKey)
+	s.labels = append(s.labels, rec.Label == feedbackTruePositive)
+	if len(s.labels) > c.labelHistory {
+		s.labels = s.labels[len(s.labels)-c.labelHistory:]
+	}
+	return true
+}
+
+// evaluate adjusts the threshold of every source key once per interval and
+// returns the adjustments made. base returns the threshold configured for a
+// source key.
+func (c *thresholdController) evaluate(now time.Time, base func(sourceKey string) float64) []thresholdAdjustment {
+	if c == nil {
+		return nil
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if now.Before(c.nextEvaluated) {
+		return nil
+	}
+	c.nextEvaluated = now.Add(c.interval)
+
+	for id, alert := range c.alerted {
+		if now.Sub(alert.at) > alertedWindowsRetention {
+			delete(c.alerted, id)
+		}
+	}
+
+	keys := make([]string, 0, len(c.sources))
+	for key := range c.sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var adjustments []thresholdAdjustment
+	for _, key := range keys {
+		s := c.sources[key]
+		cutoff := now.Add(-24 * time.Hour)
+		for len(s.alerts) > 0 && !s.alerts[0].After(cutoff) {
+			s.alerts = s.alerts[1:]
+		}
+
+		adj := thresholdAdjustment{AdjustedAt: now, Source: key, AlertsPerDay: len(s.alerts), Labels: len(s.labels)}
+		labelled := c.targetPrecision > 0 && len(s.labels) >= c.minLabels
+		if labelled {
+			precision := truePositiveShare(s.labels)
+			adj.Precision = &precision
+		}
+
+		direction := 0.0
+		switch {
+		case c.maxAlertsPerDay > 0 && len(s.alerts) > c.maxAlertsPerDay:
+			direction, adj.Reason = 1, adjustAlertBudget
+		case labelled && *adj.Precision < c.targetPrecision:
+			direction, adj.Reason = 1, adjustPrecisionLow
+		case labelled && *adj.Precision > c.targetPrecision:
+			direction, adj.Reason = -1, adjustPrecisionHigh
+		case !labelled && s.offset > 0 && c.maxAlertsPerDay > 0 && len(s.alerts) <= c.maxAlertsPerDay/2:
+			// Without labels, a raised threshold relaxes back towards the
+			// configured one once the alert rate is well within budget
+			direction, adj.Reason = -1, adjustUnderAlertBudget
+		}
+		if direction == 0 {
+			if len(s.alerts) == 0 && len(s.labels) == 0 && s.offset == 0 {
+				delete(c.sources, key)
+			}
+			continue
+		}
+
+		threshold := base(key)
+		adj.PreviousThreshold = c.clamp(threshold + s.offset)
+		offset := s.offset + direction*c.step
+		if adj.Reason == adjustUnderAlertBudget {
+			offset = math.Max(offset, 0)
+		}
+		adj.Threshold = c.clamp(threshold + offset)
+		if adj.Threshold == adj.PreviousThreshold {
+			continue
+		}
+		s.offset = adj.Threshold - threshold
+		adjustments = append(adjustments, adj)
+	}
+	return adjustments
+}
+
+func truePositiveShare(labels []bool) float64 {
+	n := 0
+	for _, tp := range labels {
+		if tp {
+			n++
+		}
+	}
+	return float64(n) / float64(len(labels))
+}
+
+// record appends an adjustment to the audit file and meters it.
+func (c *thresholdController) record(adj thresholdAdjustment) error {
+	c.adjustments.Incr(1, adj.Source, adj.Reason)
+	if c.auditFile == nil {
+		return nil
+	}
+	line, err := json.Marshal(adj)
+	if err != nil {
+		return err
+	}
+	_, err = c.auditFile.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the audit file.
+func (c *thresholdController) Close() error {
+	if c == nil || c.auditFile == nil {
+		return nil
+	}
+	return c.auditFile.Close()
+}
+
+// controlThresholds runs the threshold controller once its interval has
+// passed, logging and auditing every adjustment.
+func (f *FirewallAnomalyDetector) controlThresholds(now time.Time) {
+	adjustments := f.thresholdControl.evaluate(now, f.sourceThreshold)
+	for _, adj := range adjustments {
+		f.logger.Infof("Threshold of %s moved from %.3f to %.3f: %s", adj.Source, adj.PreviousThreshold, adj.Threshold, adj.Reason)
+		if err := f.thresholdControl.record(adj); err != nil {
+			f.logger.Errorf("Failed to audit threshold adjustment of %s: %v", adj.Source, err)
+		}
+	}
+}
+
+// sourceThreshold returns the threshold configured for a source key,
+// including runtime overrides.
+func (f *FirewallAnomalyDetector) sourceThreshold(sourceKey string) float64 {
+	f.settingsMut.RLock()
+	defer f.settingsMut.RUnlock()
+
+	if threshold, exists := f.sourceThresholds[sourceKey]; exists {
+		return threshold
+	}
+	return f.scoreThreshold
+}
@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholdControlConfig(t *testing.T) {
+	parse := func(yaml string) (*thresholdController, error) {
+		spec := service.NewConfigSpec().Field(thresholdControlField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newThresholdControllerFromConfig(conf.Namespace("threshold_control"), service.MockResources().Metrics())
+	}
+
+	c, err := parse(`threshold_control: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	c, err = parse(`threshold_control: { target_precision: 0.8, max_alerts_per_day: 20 }`)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, 10, c.minLabels)
+	assert.Equal(t, 0.01, c.step)
+	assert.Equal(t, time.Hour, c.interval)
+
+	for _, yaml := range []string{
+		`threshold_control: { target_precision: 1 }`,
+		`threshold_control: { max_alerts_per_day: -1 }`,
+		`threshold_control: { max_alerts_per_day: 5, min_labels: 60 }`,
+		`threshold_control: { max_alerts_per_day: 5, step: 0 }`,
+		`threshold_control: { max_alerts_per_day: 5, min_threshold: 0.9, max_threshold: 0.8 }`,
+		`threshold_control: { max_alerts_per_day: 5, interval: 0s }`,
+	} {
+		_, err = parse(yaml)
+		assert.Error(t, err, yaml)
+	}
+}
+
+func testThresholdController(targetPrecision float64, maxAlertsPerDay int) *thresholdController {
+	return &thresholdController{
+		targetPrecision: targetPrecision,
+		maxAlertsPerDay: maxAlertsPerDay,
+		minLabels:       4,
+		labelHistory:    4,
+		step:            0.05,
+		minThreshold:    0.5,
+		maxThreshold:    0.8,
+		interval:        time.Hour,
+		adjustments:     service.MockResources().Metrics().NewCounter("threshold_adjustments", "log_source", "reason"),
+		sources:         make(map[string]*controlledSource),
+		alerted:         make(map[string]alertedWindow),
+	}
+}
+
+func TestThresholdControlAlertBudget(t *testing.T) {
+	c := testThresholdController(0, 2)
+	base := func(string) float64 { return 0.7 }
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		c.observeAlert("fortinet.firewall", "", now.Add(time.Duration(i)*time.Minute))
+	}
+	now = now.Add(time.Hour)
+	adjustments := c.evaluate(now, base)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, adjustAlertBudget, adjustments[0].Reason)
+	assert.Equal(t, 3, adjustments[0].AlertsPerDay)
+	assert.InDelta(t, 0.75, adjustments[0].Threshold, 1e-9)
+	assert.InDelta(t, 0.75, c.adjust("fortinet.firewall", 0.7), 1e-9)
+	assert.Equal(t, 0.7, c.adjust("paloalto.firewall", 0.7))
+
+	// Nothing is evaluated before the interval has passed
+	assert.Empty(t, c.evaluate(now.Add(time.Minute), base))
+
+	// Raised thresholds are clamped to max_threshold
+	now = now.Add(time.Hour)
+	adjustments = c.evaluate(now, base)
+	require.Len(t, adjustments, 1)
+	assert.InDelta(t, 0.8, adjustments[0].Threshold, 1e-9)
+	now = now.Add(time.Hour)
+	assert.Empty(t, c.evaluate(now, base))
+
+	// Once the alerts age out, the threshold relaxes back to the configured one
+	now = now.Add(24 * time.Hour)
+	adjustments = c.evaluate(now, base)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, adjustUnderAlertBudget, adjustments[0].Reason)
+	assert.InDelta(t, 0.75, adjustments[0].Threshold, 1e-9)
+	now = now.Add(time.Hour)
+	require.Len(t, c.evaluate(now, base), 1)
+	assert.Equal(t, 0.7, c.adjust("fortinet.firewall", 0.7))
+	now = now.Add(time.Hour)
+	assert.Empty(t, c.evaluate(now, base))
+	assert.Empty(t, c.sources)
+}
+
+func TestThresholdControlPrecision(t *testing.T) {
+	c := testThresholdController(0.5, 0)
+	base := func(string) float64 { return 0.6 }
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	label := func(windowID, label string) bool {
+		return c.observeLabel(feedbackRecord{WindowID: windowID, Label: label})
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		c.observeAlert("fortinet.firewall", id, now)
+	}
+	assert.True(t, label("a", feedbackFalsePositive))
+	assert.True(t, label("b", feedbackFalsePositive))
+	assert.True(t, label("c", feedbackTruePositive))
+
+	// Windows not alerted, or already labelled, do not count
+	assert.False(t, label("unknown", feedbackFalsePositive))
+	assert.False(t, label("a", feedbackTruePositive))
+
+	// Too few labels to move the threshold
+	now = now.Add(time.Hour)
+	assert.Empty(t, c.evaluate(now, base))
+
+	assert.True(t, label("d", feedbackFalsePositive))
+	now = now.Add(time.Hour)
+	adjustments := c.evaluate(now, base)
+	require.Len(t, adjustments, 1)
+	assert.Equal(t, adjustPrecisionLow, adjustments[0].Reason)
+	assert.Equal(t, 4, adjustments[0].Labels)
+	require.NotNil(t, adjustments[0].Precision)
+	assert.Equal(t, 0.25, *adjustments[0].Precision)
+	assert.InDelta(t, 0.65, c.adjust("fortinet.firewall", 0.6), 1e-9)
+
+	// Precision above target lowers the threshold, down to min_threshold
+	for _, id := range []string{"e", "f", "g", "h"} {
+		c.observeAlert("fortinet.firewall", id, now)
+		require.True(t, label(id, feedbackTruePositive))
+	}
+	for i := 0; i < 4; i++ {
+		now = now.Add(time.Hour)
+		c.evaluate(now, base)
+	}
+	assert.InDelta(t, 0.5, c.adjust("fortinet.firewall", 0.6), 1e-9)
+	now = now.Add(time.Hour)
+	assert.Empty(t, c.evaluate(now, base))
+}
+
+func TestThresholdControlDisabled(t *testing.T) {
+	var c *thresholdController
+	assert.Equal(t, 0.7, c.adjust("fortinet.firewall", 0.7))
+	c.observeAlert("fortinet.firewall", "a", time.Now())
+	assert.False(t, c.observeLabel(feedbackRecord{WindowID: "a", Label: feedbackTruePositive}))
+	assert.Empty(t, c.evaluate(time.Now(), nil))
+	assert.NoError(t, c.Close())
+}
@@ -0,0 +1,281 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+// LabeledWindow is a window of logs labeled as anomalous or normal, read as
+// a JSON line of a tuning dataset.
+type LabeledWindow struct {
+	LogSource string            `json:"log_source"`
+	Anomaly   bool              `json:"anomaly"`
+	Logs      []json.RawMessage `json:"logs"`
+}
+
+// ThresholdStats are the detections of a candidate threshold.
+type ThresholdStats struct {
+	Threshold      float64
+	TruePositives  int
+	FalsePositives int
+	TrueNegatives  int
+	FalseNegatives int
+
+	Precision         float64
+	Recall            float64
+	FalsePositiveRate float64
+	F1                float64
+}
+
+// SourceTuning is the tuning report of a `sources` key, or of every window
+// for the overall report.
+type SourceTuning struct {
+	Source    string
+	Windows   int
+	Anomalies int
+	// AUC is the area under the ROC curve of the scores.
+	AUC        float64
+	Thresholds []ThresholdStats
+	// Tunable reports whether there are windows of both labels, without
+	// which no threshold is recommended.
+	Tunable     bool
+	Recommended float64
+}
+
+// TuningReport is the result of a threshold tuning.
+type TuningReport struct {
+	Overall SourceTuning
+	Sources []SourceTuning
+	// Skipped counts the labeled windows none of whose logs were windowed.
+	Skipped int
+}
+
+// Tune scores labeled windows with the first firewall_anomaly_detector
+// processor of a config, and reports the precision, recall and false positive
+// rate of every candidate threshold per `sources` key, recommending the
+// threshold with the highest F1 score. The logs of a labeled window are
+// parsed, normalised and windowed like in a replay, and always form a single
+// window however long they span. Logs without a log_source take the one of
+// their window.
+func Tune(ctx context.Context, env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool), dataset io.Reader, thresholds []float64) (TuningReport, error) {
+	var report TuningReport
+
+	detector, err := newOfflineDetector(env, confYAML, lookupEnv)
+	if err != nil {
+		return report, err
+	}
+	defer detector.Close(ctx)
+
+	// Windows are held open and the clock never reaches their end, so they
+	// are only scored once all logs of a labeled window are in
+	detector.holdWindows = true
+	detector.clock = func() time.Time { return time.Time{} }
+
+	var all []labeledScore
+	bySource := make(map[string][]labeledScore)
+
+	scanner := bufio.NewScanner(dataset)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		item := strings.TrimSpace(scanner.Text())
+		if item == "" {
+			continue
+		}
+		var labeled LabeledWindow
+		if err := json.Unmarshal([]byte(item), &labeled); err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		scored, err := detector.scoreLabeled(ctx, labeled)
+		if err != nil {
+			return report, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(scored) == 0 {
+			report.Skipped++
+			continue
+		}
+		for _, s := range scored {
+			all = append(all, s)
+			bySource[s.sourceKey] = append(bySource[s.sourceKey], s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+
+	report.Overall = tuneScores("", all, thresholds)
+	keys := make([]string, 0, len(bySource))
+	for key := range bySource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		report.Sources = append(report.Sources, tuneScores(key, bySource[key], thresholds))
+	}
+	return report, nil
+}
+
+// labeledScore is the score of a labeled window.
+type labeledScore struct {
+	sourceKey string
+	score     float64
+	anomaly   bool
+}
+
+// scoreLabeled windows the logs of a labeled window and scores the resulting
+// windows, one per tenant when windows are scoped per tenant.
+func (f *FirewallAnomalyDetector) scoreLabeled(ctx context.Context, labeled LabeledWindow) ([]labeledScore, error) {
+	for _, raw := range labeled.Logs {
+		log, ok := f.parseLog(ctx, string(raw))
+		if !ok {
+			continue
+		}
+		if log.LogSource == "" {
+			log.LogSource = labeled.LogSource
+		}
+		if _, err := f.processLog(ctx, log); err != nil {
+			return nil, err
+		}
+	}
+
+	windows := f.drainWindows()
+	keys := make([]string, 0, len(windows))
+	for key := range windows {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	scores := make([]labeledScore, 0, len(windows))
+	for _, key := range keys {
+		window := windows[key]
+		source, _ := windowScope(key, window)
+		scores = append(scores, labeledScore{
+			sourceKey: f.sourceKey(source),
+			score:     f.scoreFeatures(f.extractFeatures(window)),
+			anomaly:   labeled.Anomaly,
+		})
+	}
+	return scores, nil
+}
+
+// tuneScores evaluates candidate thresholds over labeled scores. A window is
+// detected when its score reaches the threshold, as in scoreWindow.
+func tuneScores(source string, scores []labeledScore, thresholds []float64) SourceTuning {
+	tuning := SourceTuning{Source: source, Windows: len(scores)}
+	for _, s := range scores {
+		if s.anomaly {
+			tuning.Anomalies++
+		}
+	}
+	tuning.Tunable = tuning.Anomalies > 0 && tuning.Anomalies < tuning.Windows
+	tuning.AUC = rocAUC(scores)
+
+	bestF1 := -1.0
+	for _, threshold := range thresholds {
+		stats := ThresholdStats{Threshold: threshold}
+		for _, s := range scores {
+			detected := s.score >= threshold
+			switch {
+			case detected && s.anomaly:
+				stats.TruePositives++
+			case detected:
+				stats.FalsePositives++
+			case s.anomaly:
+				stats.FalseNegatives++
+			default:
+				stats.TrueNegatives++
+			}
+		}
+		stats.Precision = ratio(stats.TruePositives, stats.TruePositives+stats.FalsePositives)
+		stats.Recall = ratio(stats.TruePositives, stats.TruePositives+stats.FalseNegatives)
+		stats.FalsePositiveRate = ratio(stats.FalsePositives, stats.FalsePositives+stats.TrueNegatives)
+		if stats.Precision+stats.Recall > 0 {
+			stats.F1 = 2 * stats.Precision * stats.Recall / (stats.Precision + stats.Recall)
+		}
+		tuning.Thresholds = append(tuning.Thresholds, stats)
+
+		// Ties go to the higher threshold, which alerts less
+		if tuning.Tunable && stats.F1 >= bestF1 {
+			bestF1 = stats.F1
+			tuning.Recommended = threshold
+		}
+	}
+	return tuning
+}
+
+// rocAUC returns the area under the ROC curve of labeled scores, i.e. the
+// probability that an anomalous window scores higher than a normal one, with
+// ties counting half.
+func rocAUC(scores []labeledScore) float64 {
+	sorted := append([]labeledScore(nil), scores...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].score < sorted[j].score })
+
+	var positives, negatives int
+	var positiveRanks float64
+	for i := 0; i < len(sorted); {
+		// Tied scores share their average rank
+		j := i
+		for j < len(sorted) && sorted[j].score == sorted[i].score {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if sorted[k].anomaly {
+				positives++
+				positiveRanks += rank
+			} else {
+				negatives++
+			}
+		}
+		i = j
+	}
+	if positives == 0 || negatives == 0 {
+		return 0
+	}
+	return (positiveRanks - float64(positives*(positives+1))/2) / float64(positives*negatives)
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+// ThresholdConfig returns the recommended thresholds as a config block, to
+// be merged into the detector config.
+func (r TuningReport) ThresholdConfig() string {
+	var b strings.Builder
+	if r.Overall.Tunable {
+		fmt.Fprintf(&b, "score_threshold: %g\n", r.Overall.Recommended)
+	}
+
+	var defaultSource *SourceTuning
+	wroteSources := false
+	for i, s := range r.Sources {
+		if !s.Tunable {
+			continue
+		}
+		if s.Source == defaultSourceKey {
+			defaultSource = &r.Sources[i]
+			continue
+		}
+		if !wroteSources {
+			b.WriteString("sources:\n")
+			wroteSources = true
+		}
+		fmt.Fprintf(&b, "  %q:\n    score_threshold: %g\n", s.Source, s.Recommended)
+	}
+	if defaultSource != nil {
+		fmt.Fprintf(&b, "default_source:\n  score_threshold: %g\n", defaultSource.Recommended)
+	}
+	return b.String()
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tuningTestConfig = `
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        sources:
+          fortinet.firewall:
+            metric: connection_count
+          paloalto.firewall:
+            metric: bytes_sent
+`
+
+func labeledWindowLine(t *testing.T, source string, anomaly, spike bool) string {
+	t.Helper()
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	labeled := LabeledWindow{LogSource: source, Anomaly: anomaly}
+	for i := 0; i < 20; i++ {
+		count := 10
+		if spike && i == 10 {
+			count = 5000
+		}
+		// Logs may leave out the log_source of their window
+		labeled.Logs = append(labeled.Logs, json.RawMessage(fmt.Sprintf(
+			`{"timestamp":%q,"source_ip":"10.0.0.%d","connection_count":%d,"bytes_sent":%d}`,
+			start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), i%4, count, count)))
+	}
+	line, err := json.Marshal(labeled)
+	require.NoError(t, err)
+	return string(line)
+}
+
+func TestTune(t *testing.T) {
+	var dataset []string
+	for i := 0; i < 3; i++ {
+		dataset = append(dataset, labeledWindowLine(t, "fortinet.firewall", true, true))
+	}
+	// An anomaly without a spike is missed, and a normal spike is a false
+	// positive
+	dataset = append(dataset, labeledWindowLine(t, "fortinet.firewall", true, false))
+	dataset = append(dataset, labeledWindowLine(t, "fortinet.firewall", false, true))
+	for i := 0; i < 5; i++ {
+		dataset = append(dataset, labeledWindowLine(t, "fortinet.firewall", false, false))
+		dataset = append(dataset, labeledWindowLine(t, "paloalto.firewall", false, false))
+	}
+	dataset = append(dataset, `{"log_source":"unknown.firewall","anomaly":true,"logs":[{"connection_count":1}]}`)
+
+	thresholds := []float64{0, 0.2, 0.4, 0.6, 0.8, 1}
+	report, err := Tune(context.Background(), service.NewEnvironment(), []byte(tuningTestConfig), os.LookupEnv, strings.NewReader(strings.Join(dataset, "\n")), thresholds)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 15, report.Overall.Windows)
+	require.Len(t, report.Sources, 2)
+
+	fortinet := report.Sources[0]
+	assert.Equal(t, "fortinet.firewall", fortinet.Source)
+	assert.Equal(t, 10, fortinet.Windows)
+	assert.Equal(t, 4, fortinet.Anomalies)
+	assert.True(t, fortinet.Tunable)
+	assert.Equal(t, 0.4, fortinet.Recommended)
+
+	require.Len(t, fortinet.Thresholds, len(thresholds))
+	atRecommended := fortinet.Thresholds[2]
+	assert.Equal(t, 3, atRecommended.TruePositives)
+	assert.Equal(t, 1, atRecommended.FalsePositives)
+	assert.Equal(t, 1, atRecommended.FalseNegatives)
+	assert.Equal(t, 5, atRecommended.TrueNegatives)
+	assert.Equal(t, 0.75, atRecommended.Precision)
+	assert.Equal(t, 0.75, atRecommended.Recall)
+	assert.InDelta(t, 1.0/6, atRecommended.FalsePositiveRate, 1e-9)
+
+	// Of the 24 pairs of anomalous and normal windows, 15 are ordered right
+	// and 8 tie
+	assert.InDelta(t, (15+0.5*8)/24, fortinet.AUC, 1e-9)
+
+	paloalto := report.Sources[1]
+	assert.False(t, paloalto.Tunable)
+
+	// The recommendations are valid detector config
+	conf, err := detectorConfigSpec().ParseYAML(report.ThresholdConfig(), nil)
+	require.NoError(t, err)
+	threshold, err := conf.FieldFloat("score_threshold")
+	require.NoError(t, err)
+	assert.Equal(t, 0.4, threshold)
+	sources, err := conf.FieldObjectMap("sources")
+	require.NoError(t, err)
+	require.Len(t, sources, 1)
+	threshold, err = sources["fortinet.firewall"].FieldFloat("score_threshold")
+	require.NoError(t, err)
+	assert.Equal(t, 0.4, threshold)
+}
+
+func TestROCAUC(t *testing.T) {
+	assert.Equal(t, 1.0, rocAUC([]labeledScore{{score: 0.9, anomaly: true}, {score: 0.1}}))
+	assert.Equal(t, 0.0, rocAUC([]labeledScore{{score: 0.1, anomaly: true}, {score: 0.9}}))
+	assert.Equal(t, 0.5, rocAUC([]labeledScore{{score: 0.5, anomaly: true}, {score: 0.5}}))
+	assert.Equal(t, 0.0, rocAUC([]labeledScore{{score: 0.5}}))
+}
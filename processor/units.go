@@ -0,0 +1,219 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	counterDelta      = "delta"
+	counterCumulative = "cumulative"
+)
+
+// byteUnits are the units byte metrics can be logged in, with their size in
+// bytes.
+var byteUnits = map[string]float64{
+	"bytes": 1,
+	"kb":    1e3,
+	"kib":   1 << 10,
+	"mb":    1e6,
+	"mib":   1 << 20,
+	"gb":    1e9,
+	"gib":   1 << 30,
+}
+
+func sourceUnitField() *service.ConfigField {
+	return service.NewStringEnumField("unit", "bytes", "kb", "kib", "mb", "mib", "gb", "gib").
+		Description("Unit this source logs `bytes_sent` and `bytes_recv` in, normalised to bytes").
+		Default("bytes")
+}
+
+func sourceCounterField() *service.ConfigField {
+	return service.NewStringEnumField("counter", counterDelta, counterCumulative).
+		Description("Whether the metrics of this source are per-log deltas or cumulative counters, such as SNMP interface octets, which are converted to the delta since the previous log with counter resets detected").
+		Default(counterDelta)
+}
+
+func sourceCounterBitsField() *service.ConfigField {
+	return service.NewIntField("counter_bits").
+		Description("Width of the cumulative counters of this source, 32 or 64, so that a counter wrapping around is told apart from a reset. Zero takes every counter going backwards as a reset.").
+		Default(0)
+}
+
+// sourceUnits is the unit configuration of a source.
+type sourceUnits struct {
+	scale      float64
+	cumulative bool
+	// wrapAt is the value counters wrap around at, zero when unknown
+	wrapAt float64
+}
+
+// counterStep is how a cumulative counter moved since its previous value.
+type counterStep int
+
+const (
+	// counterBaselined is the first value of a counter
+	counterBaselined counterStep = iota
+	counterIncreased
+	// counterReset is a counter that went backwards, e.g. after a reboot
+	counterReset
+	// counterWrapped is a counter that overflowed its width
+	counterWrapped
+)
+
+// parseSourceUnits returns the unit configuration of a source, or nil when
+// its metrics are deltas in bytes. Field defaults are not applied to the
+// default `sources`, hence the fallbacks.
+func parseSourceUnits(conf *service.ParsedConfig) (*sourceUnits, error) {
+	unit, err := fieldStringOr(conf, "bytes", "unit")
+	if err != nil {
+		return nil, err
+	}
+	counter, err := fieldStringOr(conf, counterDelta, "counter")
+	if err != nil {
+		return nil, err
+	}
+	bits, err := fieldIntOr(conf, 0, "counter_bits")
+	if err != nil {
+		return nil, err
+	}
+	if bits != 0 && bits != 32 && bits != 64 {
+		return nil, fmt.Errorf("counter_bits must be 0, 32 or 64, got %d", bits)
+	}
+	if bits != 0 && counter != counterCumulative {
+		return nil, fmt.Errorf("counter_bits requires counter: %s", counterCumulative)
+	}
+	if unit == "bytes" && counter == counterDelta {
+		return nil, nil
+	}
+	units := &sourceUnits{scale: byteUnits[unit], cumulative: counter == counterCumulative}
+	if bits != 0 {
+		units.wrapAt = math.Exp2(float64(bits))
+	}
+	return units, nil
+}
+
+// unitNormaliser converts metric values to bytes and cumulative counters to
+// deltas. Counter values are only held in memory, so counters take a new
+// baseline after a restart.
+type unitNormaliser struct {
+	sources map[string]*sourceUnits // `sources` key -> units
+
+	mut  sync.Mutex
+	last map[string]counterBaseline // counter key -> last cumulative value
+}
+
+type counterBaseline struct {
+	value   float64
+	updated time.Time
+}
+
+func newUnitNormaliser(sources map[string]*sourceUnits) *unitNormaliser {
+	if len(sources) == 0 {
+		return nil
+	}
+	return &unitNormaliser{sources: sources, last: make(map[string]counterBaseline)}
+}
+
+// normalise returns a metric value in bytes, or the delta since the previous
+// value of the same counter of a window key for cumulative sources, along
+// with how the counter moved. The first value of a counter only sets its
+// baseline. A counter going backwards wrapped around when it was in the upper
+// half of its width and the wrapped delta is below half of it; otherwise it
+// was reset, and the value itself is the delta since the reset. Deltas are
+// computed on the logged values, before they are scaled.
+func (u *unitNormaliser) normalise(sourceKey, counterKey, field string, value float64) (float64, counterStep) {
+	if u == nil {
+		return value, counterIncreased
+	}
+	units, exists := u.sources[sourceKey]
+	if !exists {
+		return value, counterIncreased
+	}
+
+	scale := 1.0
+	if field == "bytes_sent" || field == "bytes_recv" {
+		scale = units.scale
+	}
+	if !units.cumulative {
+		return value * scale, counterIncreased
+	}
+
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	last, seen := u.last[counterKey]
+	u.last[counterKey] = counterBaseline{value: value, updated: time.Now()}
+	switch {
+	case !seen:
+		return 0, counterBaselined
+	case value >= last.value:
+		return (value - last.value) * scale, counterIncreased
+	case units.wrapAt > 0 && last.value >= units.wrapAt/2 && units.wrapAt-last.value+value < units.wrapAt/2:
+		return (units.wrapAt - last.value + value) * scale, counterWrapped
+	default:
+		return value * scale, counterReset
+	}
+}
+
+// expire removes the counter baselines not updated since cutoff. Their
+// counters take a new baseline should they report again.
+func (u *unitNormaliser) expire(cutoff time.Time) int {
+	if u == nil {
+		return 0
+	}
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	n := 0
+	for key, baseline := range u.last {
+		if baseline.updated.Before(cutoff) {
+			delete(u.last, key)
+			n++
+		}
+	}
+	return n
+}
+
+// normaliseMetrics normalises the primary and secondary metric values of a
+// log of a window. ok is false while any of its counters takes a baseline.
+func (f *FirewallAnomalyDetector) normaliseMetrics(windowKey, tenant, source, field string, value float64, secondary map[string]float64) (float64, map[string]float64, bool) {
+	if f.units == nil {
+		return value, secondary, true
+	}
+	sourceKey := f.sourceKey(source)
+	labels := f.metricLabels(tenant, source)
+
+	// Counters of a window are keyed by feature prefix, the primary metric
+	// by the window key alone
+	value, step := f.units.normalise(sourceKey, windowKey, field, value)
+	f.countCounterStep(step, labels)
+	ok := step != counterBaselined
+
+	metrics := f.sourceMetrics[sourceKey]
+	for _, m := range metrics[min(1, len(metrics)):] {
+		v, exists := secondary[m.prefix]
+		if !exists {
+			continue
+		}
+		v, step := f.units.normalise(sourceKey, windowKey+"\x00"+m.prefix, m.field, v)
+		f.countCounterStep(step, labels)
+		secondary[m.prefix] = v
+		ok = ok && step != counterBaselined
+	}
+	return value, secondary, ok
+}
+
+// countCounterStep counts the resets and wraps of cumulative counters.
+func (f *FirewallAnomalyDetector) countCounterStep(step counterStep, labels []string) {
+	switch step {
+	case counterReset:
+		f.counterResets.Incr(1, labels...)
+	case counterWrapped:
+		f.counterWraps.Incr(1, labels...)
+	}
+}
@@ -0,0 +1,121 @@
+package processor
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestUnits(t *testing.T, yaml string) *sourceUnits {
+	t.Helper()
+
+	units, err := parseTestUnitsErr(t, yaml)
+	require.NoError(t, err)
+	return units
+}
+
+func parseTestUnitsErr(t *testing.T, yaml string) (*sourceUnits, error) {
+	t.Helper()
+
+	conf, err := service.NewConfigSpec().Field(sourceUnitField()).Field(sourceCounterField()).Field(sourceCounterBitsField()).ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return parseSourceUnits(conf)
+}
+
+func TestParseSourceUnits(t *testing.T) {
+	assert.Nil(t, parseTestUnits(t, `{}`))
+	assert.Equal(t, &sourceUnits{scale: 1024}, parseTestUnits(t, `unit: kib`))
+	assert.Equal(t, &sourceUnits{scale: 1, cumulative: true}, parseTestUnits(t, `counter: cumulative`))
+	assert.Equal(t, &sourceUnits{scale: 1, cumulative: true, wrapAt: 1 << 32}, parseTestUnits(t, `{ counter: cumulative, counter_bits: 32 }`))
+
+	_, err := parseTestUnitsErr(t, `{ counter: cumulative, counter_bits: 16 }`)
+	assert.Error(t, err)
+	_, err = parseTestUnitsErr(t, `counter_bits: 32`)
+	assert.Error(t, err)
+}
+
+func TestUnitScaling(t *testing.T) {
+	u := newUnitNormaliser(map[string]*sourceUnits{"cisco.asa": {scale: 1e3}})
+
+	v, step := u.normalise("cisco.asa", "cisco.asa", "bytes_sent", 12)
+	assert.Equal(t, counterIncreased, step)
+	assert.Equal(t, 12000.0, v)
+
+	// Only byte metrics have a unit
+	v, _ = u.normalise("cisco.asa", "cisco.asa", "connection_count", 12)
+	assert.Equal(t, 12.0, v)
+	v, _ = u.normalise("fortinet.firewall", "fortinet.firewall", "bytes_sent", 12)
+	assert.Equal(t, 12.0, v)
+
+	assert.Nil(t, newUnitNormaliser(map[string]*sourceUnits{}))
+}
+
+func TestCumulativeCounters(t *testing.T) {
+	u := newUnitNormaliser(map[string]*sourceUnits{"snmp.*": {scale: 1, cumulative: true}})
+
+	// The first value only sets the baseline
+	_, step := u.normalise("snmp.*", "snmp.core1", "bytes_recv", 1000)
+	assert.Equal(t, counterBaselined, step)
+
+	v, step := u.normalise("snmp.*", "snmp.core1", "bytes_recv", 1500)
+	assert.Equal(t, counterIncreased, step)
+	assert.Equal(t, 500.0, v)
+
+	// Counters are kept per window key
+	_, step = u.normalise("snmp.*", "snmp.core2", "bytes_recv", 900000)
+	assert.Equal(t, counterBaselined, step)
+
+	// A counter going backwards was reset, e.g. by a device reboot
+	v, step = u.normalise("snmp.*", "snmp.core1", "bytes_recv", 200)
+	assert.Equal(t, counterReset, step)
+	assert.Equal(t, 200.0, v)
+
+	v, _ = u.normalise("snmp.*", "snmp.core1", "bytes_recv", 260)
+	assert.Equal(t, 60.0, v)
+}
+
+func TestCounterWraparound(t *testing.T) {
+	u := newUnitNormaliser(map[string]*sourceUnits{"snmp.*": {scale: 1e3, cumulative: true, wrapAt: 1 << 32}})
+
+	_, step := u.normalise("snmp.*", "snmp.core1", "bytes_recv", 1<<32-100)
+	assert.Equal(t, counterBaselined, step)
+
+	// A 32-bit counter near its top wrapping around contributes the bytes
+	// counted on both sides of the wrap, scaled after the delta is taken
+	v, step := u.normalise("snmp.*", "snmp.core1", "bytes_recv", 50)
+	assert.Equal(t, counterWrapped, step)
+	assert.Equal(t, 150000.0, v)
+
+	// Going backwards from the lower half of the range is a reset
+	_, step = u.normalise("snmp.*", "snmp.core1", "bytes_recv", 10)
+	assert.Equal(t, counterReset, step)
+
+	// As is a wrap that would imply an implausibly large delta
+	u.normalise("snmp.*", "snmp.core2", "bytes_recv", 1<<31+10)
+	v, step = u.normalise("snmp.*", "snmp.core2", "bytes_recv", 1<<31)
+	assert.Equal(t, counterReset, step)
+	assert.Equal(t, float64(1<<31)*1e3, v)
+}
+
+func TestNormaliseMultiMetricCounters(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		sources: map[string]string{"snmp.core1": "bytes_sent"},
+		sourceMetrics: map[string][]sourceMetric{"snmp.core1": {
+			{field: "bytes_sent", prefix: "sent"},
+			{field: "bytes_recv", prefix: "recv"},
+		}},
+		sourceMatches: &sync.Map{},
+		units:         newUnitNormaliser(map[string]*sourceUnits{"snmp.core1": {scale: 1e3, cumulative: true}}),
+	}
+
+	_, _, ok := detector.normaliseMetrics("snmp.core1", "", "snmp.core1", "bytes_sent", 10, map[string]float64{"recv": 20})
+	assert.False(t, ok)
+
+	sent, secondary, ok := detector.normaliseMetrics("snmp.core1", "", "snmp.core1", "bytes_sent", 15, map[string]float64{"recv": 50})
+	assert.True(t, ok)
+	assert.Equal(t, 5000.0, sent)
+	assert.Equal(t, map[string]float64{"recv": 30000}, secondary)
+}
@@ -0,0 +1,329 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+const (
+	IssueError   = "error"
+	IssueWarning = "warning"
+)
+
+// ConfigIssue is a problem found by ValidateConfig.
+type ConfigIssue struct {
+	// Line of the config the issue was found at, zero when unknown.
+	Line int
+	// Path is the dot path of the offending field.
+	Path     string
+	Severity string
+	Message  string
+}
+
+func (i ConfigIssue) String() string {
+	if i.Path == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Path, i.Message)
+}
+
+// ValidateConfig checks a stream config offline. The config is linted
+// against the components of the environment, and every
+// firewall_anomaly_detector processor is checked for settings that parse but
+// can not work: unknown metrics, overlapping sources, a missing model file,
+// thresholds that are never or always reached and overrides that never
+// apply. Issues are sorted by line.
+func ValidateConfig(env *service.Environment, confYAML []byte, lookupEnv func(name string) (string, bool)) ([]ConfigIssue, error) {
+	expanded, missing := expandEnv(string(confYAML), lookupEnv)
+
+	var issues []ConfigIssue
+	for _, name := range missing {
+		issues = append(issues, ConfigIssue{
+			Severity: IssueError,
+			Message:  fmt.Sprintf("environment variable %s is not set", name),
+		})
+	}
+
+	schema := env.FullConfigSchema("", "")
+	lints, err := schema.NewStreamConfigLinter().
+		SetSkipEnvVarCheck(true).
+		LintYAML([]byte(expanded))
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lints {
+		issues = append(issues, ConfigIssue{
+			Line:     l.Line,
+			Severity: IssueError,
+			Message:  l.What,
+		})
+	}
+
+	err = schema.NewStreamConfigWalker().WalkComponentsYAML([]byte(expanded), func(w *service.WalkedComponent) error {
+		if w.ComponentType != "processor" || w.Name != "firewall_anomaly_detector" {
+			return nil
+		}
+		for _, issue := range validateDetector(env, w) {
+			issue.Path = strings.TrimSuffix(w.Path+".firewall_anomaly_detector."+issue.Path, ".")
+			if issue.Line == 0 {
+				issue.Line = w.LineStart
+			}
+			issues = append(issues, issue)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Line < issues[j].Line
+	})
+	return issues, nil
+}
+
+// validateDetector checks the config of a single detector, with paths
+// relative to the processor.
+func validateDetector(env *service.Environment, w *service.WalkedComponent) []ConfigIssue {
+	v := &detectorValidator{}
+
+	explicit, err := walkedDetectorFields(w)
+	if err != nil {
+		v.errorf("", "%v", err)
+		return v.issues
+	}
+	conf, err := parseDetectorFields(env, explicit)
+	if err != nil {
+		v.errorf("", "%v", err)
+		return v.issues
+	}
+
+	v.checkWindow(conf)
+	v.checkModel(conf)
+	v.checkThresholds(conf)
+	v.checkEventTime(conf)
+	v.checkSources(conf, explicit)
+	v.checkTenants(conf, explicit)
+	v.checkFaultInjection(conf)
+	return v.issues
+}
+
+// walkedDetectorFields returns the fields set on a walked
+// firewall_anomaly_detector processor.
+func walkedDetectorFields(w *service.WalkedComponent) (map[string]interface{}, error) {
+	raw, err := w.ConfigAny()
+	if err != nil {
+		return nil, err
+	}
+	component, _ := raw.(map[string]interface{})
+	explicit, _ := component["firewall_anomaly_detector"].(map[string]interface{})
+	return explicit, nil
+}
+
+// parseDetectorFields parses the fields of a detector against its spec.
+func parseDetectorFields(env *service.Environment, fields map[string]interface{}) (*service.ParsedConfig, error) {
+	// JSON is valid YAML, and marshals the processor fields without the
+	// component wrapper
+	confJSON, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return detectorConfigSpec().ParseYAML(string(confJSON), env)
+}
+
+type detectorValidator struct {
+	issues []ConfigIssue
+}
+
+func (v *detectorValidator) errorf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, ConfigIssue{Path: path, Severity: IssueError, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *detectorValidator) warnf(path, format string, args ...interface{}) {
+	v.issues = append(v.issues, ConfigIssue{Path: path, Severity: IssueWarning, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *detectorValidator) checkWindow(conf *service.ParsedConfig) {
+	preset, err := profileFromConfig(conf)
+	if err != nil {
+		v.errorf("profile", "%v", err)
+		return
+	}
+	windowSeconds, err := fieldIntOr(conf, preset.windowSeconds, "window_seconds")
+	if err != nil {
+		v.errorf("window_seconds", "%v", err)
+		return
+	}
+	if windowSeconds <= 0 {
+		v.errorf("window_seconds", "must be positive, got %d", windowSeconds)
+	}
+}
+
+func (v *detectorValidator) checkModel(conf *service.ParsedConfig) {
+	modelPath, err := conf.FieldString("model_path")
+	if err != nil {
+		v.errorf("model_path", "%v", err)
+		return
+	}
+	info, err := os.Stat(modelPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		v.errorf("model_path", "model file %s does not exist on this host; make sure it is mounted where the detector runs", modelPath)
+	case err != nil:
+		v.errorf("model_path", "model file %s can not be read: %v", modelPath, err)
+	case info.IsDir():
+		v.errorf("model_path", "%s is a directory, not a model file", modelPath)
+	}
+}
+
+// checkThreshold reports thresholds that are never reached, since scores
+// are capped at 1, or that flag every window.
+func (v *detectorValidator) checkThreshold(path string, threshold float64) {
+	switch {
+	case threshold > 1:
+		v.errorf(path, "%v is never reached, anomaly scores range from 0 to 1", threshold)
+	case threshold < 0:
+		v.errorf(path, "%v is negative, anomaly scores range from 0 to 1", threshold)
+	case threshold == 0:
+		v.warnf(path, "0 flags every window as anomalous")
+	}
+}
+
+func (v *detectorValidator) checkThresholds(conf *service.ParsedConfig) {
+	threshold, err := conf.FieldFloat("score_threshold")
+	if err != nil {
+		v.errorf("score_threshold", "%v", err)
+		return
+	}
+	v.checkThreshold("score_threshold", threshold)
+
+	rate, err := conf.FieldFloat("debug_sample", "rate")
+	if err == nil && (rate < 0 || rate > 1) {
+		v.errorf("debug_sample.rate", "%v is not a fraction between 0 and 1", rate)
+	}
+}
+
+func (v *detectorValidator) checkFaultInjection(conf *service.ParsedConfig) {
+	enabled := false
+	for _, field := range []string{"malformed_rate", "duplicate_rate", "out_of_order_rate"} {
+		rate, err := conf.FieldFloat("fault_injection", field)
+		if err != nil {
+			continue
+		}
+		if rate < 0 || rate > 1 {
+			v.errorf("fault_injection."+field, "%v is not a fraction between 0 and 1", rate)
+		}
+		enabled = enabled || rate > 0
+	}
+	if latency, err := conf.FieldDuration("fault_injection", "redis_latency"); err == nil && latency > 0 {
+		enabled = true
+	}
+	if enabled {
+		v.warnf("fault_injection", "faults are injected into the logs read from Redis, which is meant for test configs only")
+	}
+}
+
+func (v *detectorValidator) checkEventTime(conf *service.ParsedConfig) {
+	if _, err := newEventTimeParserFromConfig(conf.Namespace("event_time")); err != nil {
+		v.errorf("event_time", "%v", err)
+	}
+}
+
+func (v *detectorValidator) checkSources(conf *service.ParsedConfig, explicit map[string]interface{}) {
+	sources, err := conf.FieldObjectMap("sources")
+	if err != nil {
+		v.errorf("sources", "%v", err)
+		return
+	}
+	explicitSources, _ := explicit["sources"].(map[string]interface{})
+
+	if _, err := parseDefaultSource(conf, 60); err != nil {
+		v.errorf("default_source", "%v", err)
+	}
+
+	names := make([]string, 0, len(sources))
+	metricFields := make(map[string]string, len(sources))
+	priorities := make(map[string]int, len(sources))
+	for source, sourceConf := range sources {
+		names = append(names, source)
+		metricFields[source], _ = sourceConf.FieldString("metric")
+		priorities[source], _ = fieldIntOr(sourceConf, 0, "priority")
+	}
+	sort.Strings(names)
+	if _, err := compileSourcePatterns(metricFields, priorities); err != nil {
+		v.errorf("sources", "%v", err)
+	}
+
+	normalised := make(map[string]string, len(sources))
+	for _, source := range names {
+		sourceConf := sources[source]
+		path := "sources." + source
+
+		key := strings.ToLower(strings.TrimSpace(source))
+		if other, exists := normalised[key]; exists {
+			v.errorf(path, "overlaps with source %q, differing only in case or whitespace; log_source values are matched exactly, so one of them likely never receives logs", other)
+		}
+		normalised[key] = source
+		if key != strings.ToLower(source) {
+			v.warnf(path, "has leading or trailing whitespace that log_source values rarely carry")
+		}
+
+		metrics, err := parseSourceMetrics(source, sourceConf)
+		if err != nil {
+			v.errorf(path+".metrics", "%v", err)
+		}
+		explicitConf, _ := explicitSources[source].(map[string]interface{})
+		if _, set := explicitConf["metric"]; set && len(metrics) > 0 {
+			v.warnf(path+".metric", "is never used, the first entry of metrics replaces it")
+		} else if metric, err := sourceConf.FieldString("metric"); err != nil {
+			v.errorf(path+".metric", "%v", err)
+		} else if _, ok := metricValueOf(FirewallLog{}, metric); !ok && len(metrics) == 0 {
+			v.errorf(path+".metric", "unknown metric field %s, every log of the source would be dropped; use connection_count, bytes_sent or bytes_recv", metric)
+		}
+
+		if _, err := parseSourceFeatures(source, sourceConf); err != nil {
+			v.errorf(path+".features", "%v", err)
+		}
+		if _, err := parseSourceTimezone(source, sourceConf); err != nil {
+			v.errorf(path+".timezone", "%v", err)
+		}
+
+		if sourceConf.Contains("score_threshold") {
+			if threshold, err := sourceConf.FieldFloat("score_threshold"); err == nil {
+				v.checkThreshold(path+".score_threshold", threshold)
+			}
+		}
+		if sourceConf.Contains("window_seconds") {
+			if seconds, err := sourceConf.FieldInt("window_seconds"); err == nil && seconds <= 0 {
+				v.errorf(path+".window_seconds", "must be positive, got %d", seconds)
+			}
+		}
+	}
+}
+
+func (v *detectorValidator) checkTenants(conf *service.ParsedConfig, explicit map[string]interface{}) {
+	tenants, err := conf.FieldObjectMap("tenants")
+	if err != nil {
+		v.errorf("tenants", "%v", err)
+		return
+	}
+	field, _ := conf.FieldString("tenant_field")
+	if _, set := explicit["tenants"]; set && field == "" && len(tenants) > 0 {
+		v.warnf("tenants", "overrides are never used without tenant_field")
+	}
+
+	for tenant, tenantConf := range tenants {
+		if !tenantConf.Contains("score_threshold") {
+			continue
+		}
+		if threshold, err := tenantConf.FieldFloat("score_threshold"); err == nil {
+			v.checkThreshold("tenants."+tenant+".score_threshold", threshold)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package processor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func issueStrings(issues []ConfigIssue) []string {
+	out := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		out = append(out, issue.Severity+" "+issue.Path)
+	}
+	return out
+}
+
+func TestValidateConfigValid(t *testing.T) {
+	modelPath := filepath.Join(t.TempDir(), "model.pkl")
+	require.NoError(t, os.WriteFile(modelPath, []byte("model"), 0o644))
+
+	issues, err := ValidateConfig(service.NewEnvironment(), []byte(`
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        model_path: `+modelPath+`
+        window_seconds: ${WINDOW_SECONDS:60}
+        sources:
+          fortinet.firewall: {metric: connection_count}
+          paloalto.firewall:
+            metrics: [{field: bytes_sent}, {field: bytes_recv}]
+`), func(string) (string, bool) { return "", false })
+	require.NoError(t, err)
+	assert.Empty(t, issues)
+}
+
+func TestValidateConfigIssues(t *testing.T) {
+	issues, err := ValidateConfig(service.NewEnvironment(), []byte(`
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        model_path: /nonexistent/model.pkl
+        score_threshold: 1.5
+        unknown_field: true
+        redis_config:
+          password: ${REDIS_PASSWORD}
+        sources:
+          Fortinet.Firewall: {metric: connection_count}
+          fortinet.firewall: {metric: packets}
+          paloalto.firewall:
+            metric: bytes_sent
+            metrics: [{field: bytes_sent}, {field: bytes_recv}]
+        tenants:
+          acme: {score_threshold: 0}
+`), func(string) (string, bool) { return "", false })
+	require.NoError(t, err)
+
+	const prefix = "pipeline.processors.0.firewall_anomaly_detector."
+	assert.Equal(t, []string{
+		"error ",
+		"error " + prefix + "model_path",
+		"error " + prefix + "score_threshold",
+		"error " + prefix + "sources.fortinet.firewall",
+		"error " + prefix + "sources.fortinet.firewall.metric",
+		"warning " + prefix + "sources.paloalto.firewall.metric",
+		"warning " + prefix + "tenants",
+		"warning " + prefix + "tenants.acme.score_threshold",
+		"error ",
+	}, issueStrings(issues))
+	assert.Contains(t, issues[0].Message, "REDIS_PASSWORD")
+	assert.Equal(t, 7, issues[len(issues)-1].Line)
+	assert.Contains(t, issues[len(issues)-1].Message, "unknown_field")
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"reflect"
+	"strings"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func validationField() *service.ConfigField {
+	return service.NewBoolField("validate_logs").
+		Description("Validate logs before windowing them: `log_source`, `source_ip` and `dest_ip` are required, IP addresses must be valid and counters must not be negative. Invalid logs are dropped and routed like strict mode rejections, to `reject_topic` or as errors, with the list of their violations in `violations` metadata.").
+		Default(false).
+		Advanced()
+}
+
+// logValidator checks logs against the rules in the validate tags of the
+// fields of FirewallLog, rather than windowing whatever parses.
+type logValidator struct {
+	rejectTopic string
+}
+
+func newLogValidatorFromConfig(conf *service.ParsedConfig) (*logValidator, error) {
+	enabled, err := conf.FieldBool("validate_logs")
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	rejectTopic, err := conf.FieldString("reject_topic")
+	if err != nil {
+		return nil, err
+	}
+	return &logValidator{rejectTopic: rejectTopic}, nil
+}
+
+// violation is a rule a log breaks, emitted with rejected logs.
+type violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// fieldRules are the validation rules of a field of FirewallLog.
+type fieldRules struct {
+	index int
+	name  string
+	rules []string
+}
+
+// logRules are the rules of the validate tags of FirewallLog, named after
+// the JSON field they apply to.
+var logRules = parseFieldRules(reflect.TypeOf(FirewallLog{}))
+
+func parseFieldRules(t reflect.Type) []fieldRules {
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		fields = append(fields, fieldRules{index: i, name: name, rules: strings.Split(tag, ",")})
+	}
+	return fields
+}
+
+// validate returns the violations of a log, none when validation is
+// disabled.
+func (v *logValidator) validate(log FirewallLog) []violation {
+	if v == nil {
+		return nil
+	}
+
+	var violations []violation
+	value := reflect.ValueOf(log)
+	for _, field := range logRules {
+		fieldValue := value.Field(field.index)
+		for _, rule := range field.rules {
+			if message := checkRule(rule, fieldValue); message != "" {
+				violations = append(violations, violation{Field: field.name, Rule: rule, Message: message})
+			}
+		}
+	}
+	return violations
+}
+
+// checkRule returns why a value breaks a rule, or an empty string.
+func checkRule(rule string, value reflect.Value) string {
+	switch rule {
+	case "required":
+		if value.IsZero() {
+			return "is missing"
+		}
+	case "ip":
+		if s := value.String(); s != "" {
+			if _, err := netip.ParseAddr(s); err != nil {
+				return fmt.Sprintf("%q is not an IP address", s)
+			}
+		}
+	case "min=0":
+		if value.Int() < 0 {
+			return fmt.Sprintf("%d is negative", value.Int())
+		}
+	}
+	return ""
+}
+
+// reject returns a message carrying an invalid log along with its
+// violations.
+func (v *logValidator) reject(log FirewallLog, violations []violation) *service.Message {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Field + " " + violation.Message
+	}
+	msg := rejectedLog(log, dropReasonInvalidSchema, v.rejectTopic, errors.New("invalid log: "+strings.Join(messages, "; ")))
+	if b, err := json.Marshal(violations); err == nil {
+		msg.MetaSet("violations", string(b))
+	}
+	return msg
+}
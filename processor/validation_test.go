@@ -0,0 +1,90 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogValidatorConfig(t *testing.T) {
+	parse := func(yaml string) *logValidator {
+		spec := service.NewConfigSpec().Fields(strictFields()...).Field(validationField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		v, err := newLogValidatorFromConfig(conf)
+		require.NoError(t, err)
+		return v
+	}
+
+	assert.Nil(t, parse(`{}`))
+	v := parse(`{ validate_logs: true, reject_topic: firewall-rejected }`)
+	require.NotNil(t, v)
+	assert.Equal(t, "firewall-rejected", v.rejectTopic)
+}
+
+func TestLogValidatorRules(t *testing.T) {
+	v := &logValidator{}
+	valid := FirewallLog{LogSource: "fortinet.firewall", SourceIP: "192.168.1.1", DestIP: "2001:db8::1", ConnectionCount: 3}
+	assert.Empty(t, v.validate(valid))
+
+	invalid := FirewallLog{SourceIP: "192.168.1.300", ConnectionCount: -1, BytesSent: -5}
+	assert.Equal(t, []violation{
+		{Field: "log_source", Rule: "required", Message: "is missing"},
+		{Field: "source_ip", Rule: "ip", Message: `"192.168.1.300" is not an IP address`},
+		{Field: "dest_ip", Rule: "required", Message: "is missing"},
+		{Field: "connection_count", Rule: "min=0", Message: "-1 is negative"},
+		{Field: "bytes_sent", Rule: "min=0", Message: "-5 is negative"},
+	}, v.validate(invalid))
+
+	var disabled *logValidator
+	assert.Empty(t, disabled.validate(invalid))
+}
+
+func TestInvalidLogsAreRejected(t *testing.T) {
+	mgr := service.MockResources()
+	f := &FirewallAnomalyDetector{
+		logger:        mgr.Logger(),
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		sources:       map[string]string{"fortinet.firewall": "connection_count"},
+		validator:     &logValidator{rejectTopic: "firewall-rejected"},
+		processedLogs: mgr.Metrics().NewCounter("processed_logs"),
+		logsDropped:   mgr.Metrics().NewCounter("logs_dropped", "reason"),
+	}
+
+	original := `{"log_source":"fortinet.firewall","source_ip":"not-an-ip","dest_ip":"10.0.0.1"}`
+	msg, err := f.processLog(context.Background(), FirewallLog{
+		LogSource: "fortinet.firewall",
+		SourceIP:  "not-an-ip",
+		DestIP:    "10.0.0.1",
+		original:  original,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.Nil(t, f.getWindow("fortinet.firewall"))
+
+	body, err := msg.AsBytes()
+	require.NoError(t, err)
+	assert.Equal(t, original, string(body))
+	topic, _ := msg.MetaGet("topic")
+	assert.Equal(t, "firewall-rejected", topic)
+	reason, _ := msg.MetaGet("reject_reason")
+	assert.Equal(t, dropReasonInvalidSchema, reason)
+
+	raw, _ := msg.MetaGet("violations")
+	var violations []violation
+	require.NoError(t, json.Unmarshal([]byte(raw), &violations))
+	assert.Equal(t, []violation{{Field: "source_ip", Rule: "ip", Message: `"not-an-ip" is not an IP address`}}, violations)
+
+	// Without a reject topic the log goes to the error handling of the
+	// pipeline
+	f.validator.rejectTopic = ""
+	msg, err = f.processLog(context.Background(), FirewallLog{LogSource: "fortinet.firewall", DestIP: "10.0.0.1"})
+	require.NoError(t, err)
+	require.NotNil(t, msg)
+	assert.ErrorContains(t, msg.GetError(), "source_ip is missing")
+}
@@ -0,0 +1,243 @@
+package processor
+
+import (
+	_ "embed"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+//go:embed webui/index.html
+var webUIPage []byte
+
+func webUIField() *service.ConfigField {
+	return service.NewObjectField("web_ui",
+		service.NewBoolField("enabled").
+			Description("Serve a page reviewing live detections on the Redpanda Connect HTTP server").
+			Default(false),
+		service.NewStringField("path").
+			Description("Path the page is served at. Its data is served as JSON at the path suffixed with `/data`.").
+			Default("/firewall_anomaly_detector/ui"),
+		service.NewIntField("feed_size").
+			Description("Number of recent anomalies listed in the feed").
+			Default(200),
+		service.NewIntField("sparkline_points").
+			Description("Number of recent window scores drawn per source").
+			Default(60),
+		service.NewDurationField("refresh_interval").
+			Description("How often the page reloads its data").
+			Default("5s"),
+	).
+		Description("Lightweight web page listing live anomalies, recent scores per source and the current windows, for teams without a SIEM").
+		Advanced()
+}
+
+// uiAnomaly is an anomaly listed in the feed of the web UI.
+type uiAnomaly struct {
+	WindowKey    string                 `json:"window_key"`
+	Source       string                 `json:"log_source"`
+	Tenant       string                 `json:"tenant,omitempty"`
+	WindowStart  time.Time              `json:"window_start"`
+	WindowEnd    time.Time              `json:"window_end"`
+	Samples      int                    `json:"samples"`
+	AnomalyScore float64                `json:"anomaly_score"`
+	Threshold    float64                `json:"threshold"`
+	Alerted      bool                   `json:"alerted"`
+	SuppressedBy string                 `json:"suppressed_by,omitempty"`
+	Features     map[string]interface{} `json:"features"`
+}
+
+// uiScore is a point of the score sparkline of a window key.
+type uiScore struct {
+	WindowEnd time.Time `json:"window_end"`
+	Score     float64   `json:"score"`
+	Anomaly   bool      `json:"anomaly"`
+}
+
+// uiSource is the score history of a window key.
+type uiSource struct {
+	WindowKey string    `json:"window_key"`
+	Source    string    `json:"log_source"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Threshold float64   `json:"threshold"`
+	Scores    []uiScore `json:"scores"`
+}
+
+// webUI keeps the recent detections shown by the web UI.
+type webUI struct {
+	feedSize        int
+	points          int
+	refreshInterval time.Duration
+
+	mut     sync.Mutex
+	feed    []uiAnomaly
+	sources map[string]*uiSource
+}
+
+func newWebUIFromConfig(conf *service.ParsedConfig) (*webUI, string, error) {
+	enabled, err := conf.FieldBool("enabled")
+	if err != nil || !enabled {
+		return nil, "", err
+	}
+	path, err := conf.FieldString("path")
+	if err != nil {
+		return nil, "", err
+	}
+	feedSize, err := conf.FieldInt("feed_size")
+	if err != nil {
+		return nil, "", err
+	}
+	points, err := conf.FieldInt("sparkline_points")
+	if err != nil {
+		return nil, "", err
+	}
+	refreshInterval, err := conf.FieldDuration("refresh_interval")
+	if err != nil {
+		return nil, "", err
+	}
+	return &webUI{
+		feedSize:        feedSize,
+		points:          points,
+		refreshInterval: refreshInterval,
+		sources:         make(map[string]*uiSource),
+	}, strings.TrimSuffix(path, "/"), nil
+}
+
+// registerWebUI serves the web UI when it is enabled.
+func (f *FirewallAnomalyDetector) registerWebUI(conf *service.ParsedConfig) error {
+	ui, path, err := newWebUIFromConfig(conf)
+	if err != nil || ui == nil {
+		return err
+	}
+	f.ui = ui
+	if err := registerEndpoint(f.resources, path, "Reviews the live detections of the firewall anomaly detector.", f.handleWebUIPage); err != nil {
+		return err
+	}
+	return registerEndpoint(f.resources, path+"/data", "Live detections of the firewall anomaly detector.", f.handleWebUIData)
+}
+
+// observe records the decision of a scored window.
+func (u *webUI) observe(decision auditRecord) {
+	if u == nil {
+		return
+	}
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	source, exists := u.sources[decision.WindowKey]
+	if !exists {
+		source = &uiSource{WindowKey: decision.WindowKey, Source: decision.Source, Tenant: decision.Tenant}
+		u.sources[decision.WindowKey] = source
+	}
+	source.Threshold = decision.Threshold
+	source.Scores = append(source.Scores, uiScore{
+		WindowEnd: decision.WindowEnd,
+		Score:     decision.AnomalyScore,
+		Anomaly:   decision.IsAnomaly && !decision.Suppressed,
+	})
+	if len(source.Scores) > u.points {
+		source.Scores = source.Scores[len(source.Scores)-u.points:]
+	}
+
+	if !decision.IsAnomaly {
+		return
+	}
+	u.feed = append(u.feed, uiAnomaly{
+		WindowKey:    decision.WindowKey,
+		Source:       decision.Source,
+		Tenant:       decision.Tenant,
+		WindowStart:  decision.WindowStart,
+		WindowEnd:    decision.WindowEnd,
+		Samples:      decision.Samples,
+		AnomalyScore: decision.AnomalyScore,
+		Threshold:    decision.Threshold,
+		Alerted:      decision.Alerted,
+		SuppressedBy: decision.SuppressedBy,
+		Features:     finiteFeatures(decision.Features),
+	})
+	if len(u.feed) > u.feedSize {
+		u.feed = u.feed[len(u.feed)-u.feedSize:]
+	}
+}
+
+// snapshot returns the anomalies of the feed, newest first, and the score
+// histories ordered by window key.
+func (u *webUI) snapshot() ([]uiAnomaly, []uiSource) {
+	u.mut.Lock()
+	defer u.mut.Unlock()
+
+	feed := make([]uiAnomaly, len(u.feed))
+	for i, anomaly := range u.feed {
+		feed[len(u.feed)-1-i] = anomaly
+	}
+	sources := make([]uiSource, 0, len(u.sources))
+	for _, source := range u.sources {
+		s := *source
+		s.Scores = append([]uiScore(nil), source.Scores...)
+		sources = append(sources, s)
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].WindowKey < sources[j].WindowKey })
+	return feed, sources
+}
+
+func (f *FirewallAnomalyDetector) handleWebUIPage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+	if _, err := w.Write(webUIPage); err != nil {
+		f.logger.Warnf("Failed to write web UI page: %v", err)
+	}
+}
+
+func (f *FirewallAnomalyDetector) handleWebUIData(w http.ResponseWriter, r *http.Request) {
+	feed, sources := f.ui.snapshot()
+
+	views := f.windowViews("")
+	windows := make([]map[string]interface{}, len(views))
+	for i, view := range views {
+		windows[i] = map[string]interface{}{
+			"key":          view.Key,
+			"log_source":   view.Source,
+			"tenant":       view.Tenant,
+			"samples":      view.Samples,
+			"unique_ips":   view.UniqueIPs,
+			"start_time":   view.StartTime,
+			"end_time":     view.EndTime,
+			"updated_at":   view.UpdatedAt,
+			"stats":        finiteFeatures(view.Stats),
+			"pending_logs": view.Pending,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err := json.NewEncoder(w).Encode(map[string]interface{}{
+		"generated_at":        f.now(),
+		"refresh_interval_ms": f.ui.refreshInterval.Milliseconds(),
+		"anomalies":           feed,
+		"sources":             sources,
+		"windows":             windows,
+	})
+	if err != nil {
+		f.logger.Warnf("Failed to write web UI data: %v", err)
+	}
+}
+
+// finiteFeatures converts features for JSON encoding, writing those that are
+// not finite, such as the standard deviation of a single sample, as null.
+func finiteFeatures(features map[string]float64) map[string]interface{} {
+	converted := make(map[string]interface{}, len(features))
+	for name, v := range features {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			converted[name] = nil
+			continue
+		}
+		converted[name] = v
+	}
+	return converted
+}
@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseWebUI(t *testing.T, yaml string) *webUI {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(webUIField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+
+	ui, _, err := newWebUIFromConfig(conf.Namespace("web_ui"))
+	require.NoError(t, err)
+	return ui
+}
+
+func TestWebUIDisabledByDefault(t *testing.T) {
+	ui := parseWebUI(t, `web_ui: {}`)
+	assert.Nil(t, ui)
+	ui.observe(auditRecord{})
+}
+
+func TestWebUI(t *testing.T) {
+	ui := parseWebUI(t, `
+web_ui:
+  enabled: true
+  feed_size: 2
+  sparkline_points: 3
+`)
+	require.NotNil(t, ui)
+
+	detector := &FirewallAnomalyDetector{
+		logger:        service.MockResources().Logger(),
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+		ui:            ui,
+	}
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		ui.observe(auditRecord{
+			WindowKey:    "fortinet.firewall",
+			Source:       "fortinet.firewall",
+			WindowStart:  start.Add(time.Duration(i) * time.Minute),
+			WindowEnd:    start.Add(time.Duration(i+1) * time.Minute),
+			AnomalyScore: float64(i) / 4,
+			Threshold:    0.7,
+			IsAnomaly:    i >= 3,
+			Alerted:      i >= 3,
+			Features:     map[string]float64{"mean_value": float64(i), "std_dev": 0},
+		})
+	}
+	ui.observe(auditRecord{WindowKey: "paloalto.firewall", Source: "paloalto.firewall", Threshold: 0.5})
+	detector.updateWindow("paloalto.firewall", 5, "192.168.1.1", start)
+
+	rec := httptest.NewRecorder()
+	detector.handleWebUIData(rec, httptest.NewRequest("GET", "/firewall_anomaly_detector/ui/data", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var resp struct {
+		RefreshIntervalMS int64                    `json:"refresh_interval_ms"`
+		Anomalies         []uiAnomaly              `json:"anomalies"`
+		Sources           []uiSource               `json:"sources"`
+		Windows           []map[string]interface{} `json:"windows"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, int64(5000), resp.RefreshIntervalMS)
+
+	// The feed lists the newest anomalies first
+	require.Len(t, resp.Anomalies, 2)
+	assert.Equal(t, 1.0, resp.Anomalies[0].AnomalyScore)
+	assert.Equal(t, 0.75, resp.Anomalies[1].AnomalyScore)
+	assert.Equal(t, 4.0, resp.Anomalies[0].Features["mean_value"])
+
+	require.Len(t, resp.Sources, 2)
+	fortinet := resp.Sources[0]
+	assert.Equal(t, "fortinet.firewall", fortinet.WindowKey)
+	require.Len(t, fortinet.Scores, 3)
+	assert.Equal(t, 0.5, fortinet.Scores[0].Score)
+	assert.True(t, fortinet.Scores[2].Anomaly)
+	assert.Equal(t, 0.5, resp.Sources[1].Threshold)
+
+	// The undefined standard deviation of a single sample is sanitised
+	require.Len(t, resp.Windows, 1)
+	assert.Equal(t, "paloalto.firewall", resp.Windows[0]["log_source"])
+	assert.Equal(t, 0.0, resp.Windows[0]["stats"].(map[string]interface{})["std_dev"])
+
+	rec = httptest.NewRecorder()
+	detector.handleWebUIPage(rec, httptest.NewRequest("GET", "/firewall_anomaly_detector/ui", nil))
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, rec.Body.String(), "Firewall Anomaly Detector")
+}
@@ -0,0 +1,93 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func windowBufferField() *service.ConfigField {
+	return service.NewObjectField("window_buffer",
+		service.NewFloatField("expected_eps").
+			Description("Expected logs per second of a window. Sample buffers are preallocated for that rate over the window duration, so that they do not grow as logs arrive. Zero starts windows with empty buffers.").
+			Default(0.0),
+		service.NewIntField("max_samples").
+			Description("Maximum samples kept per window and metric. A full buffer wraps around, every new sample replacing the oldest one, so that features are computed over the latest samples only. Zero keeps every sample.").
+			Default(0),
+	).
+		Description("Sizing of the buffers holding the samples of a window, avoiding repeated slice growth and bounding their memory at high throughput").
+		Advanced()
+}
+
+// windowBuffers sizes the sample buffers of windows. The zero value grows
+// buffers as samples arrive and never drops any.
+type windowBuffers struct {
+	expectedEPS float64
+	maxSamples  int
+}
+
+func newWindowBuffersFromConfig(conf *service.ParsedConfig) (windowBuffers, error) {
+	var b windowBuffers
+	var err error
+	if b.expectedEPS, err = conf.FieldFloat("expected_eps"); err != nil {
+		return b, err
+	}
+	if b.expectedEPS < 0 {
+		return b, fmt.Errorf("window_buffer.expected_eps must not be negative, got %v", b.expectedEPS)
+	}
+	if b.maxSamples, err = conf.FieldInt("max_samples"); err != nil {
+		return b, err
+	}
+	if b.maxSamples < 0 {
+		return b, fmt.Errorf("window_buffer.max_samples must not be negative, got %d", b.maxSamples)
+	}
+	return b, nil
+}
+
+// capacity returns the number of samples preallocated for a window of the
+// given duration.
+func (b windowBuffers) capacity(length time.Duration) int {
+	n := int(math.Ceil(b.expectedEPS * length.Seconds()))
+	if b.maxSamples > 0 && n > b.maxSamples {
+		n = b.maxSamples
+	}
+	return n
+}
+
+// newBuffer returns an empty sample buffer for a window of the given
+// duration.
+func (b windowBuffers) newBuffer(length time.Duration) []float64 {
+	return make([]float64, 0, b.capacity(length))
+}
+
+// push adds a sample to a buffer whose oldest sample is at head once it has
+// wrapped around. It returns the buffer, its new head, and whether the
+// oldest sample was replaced.
+func (b windowBuffers) push(values []float64, head int, v float64) ([]float64, int, bool) {
+	if b.maxSamples <= 0 || len(values) < b.maxSamples {
+		return append(values, v), head, false
+	}
+	values[head] = v
+	return values, (head + 1) % len(values), true
+}
+
+// orderedSamples returns the samples of a buffer from oldest to newest,
+// copying them when the buffer has wrapped around.
+func orderedSamples(values []float64, head int) []float64 {
+	if head <= 0 || head >= len(values) {
+		return values
+	}
+	ordered := make([]float64, 0, len(values))
+	ordered = append(ordered, values[head:]...)
+	return append(ordered, values[:head]...)
+}
+
+// lastValue returns the newest primary metric value of a window.
+func (w *WindowData) lastValue() float64 {
+	if w.ValuesHead > 0 && w.ValuesHead <= len(w.Values) {
+		return w.Values[w.ValuesHead-1]
+	}
+	return w.Values[len(w.Values)-1]
+}
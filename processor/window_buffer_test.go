@@ -0,0 +1,112 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseWindowBuffers(t *testing.T, yaml string) (windowBuffers, error) {
+	t.Helper()
+
+	spec := service.NewConfigSpec().Field(windowBufferField())
+	conf, err := spec.ParseYAML(yaml, nil)
+	require.NoError(t, err)
+	return newWindowBuffersFromConfig(conf.Namespace("window_buffer"))
+}
+
+func TestWindowBuffersConfig(t *testing.T) {
+	b, err := parseWindowBuffers(t, `window_buffer: {}`)
+	require.NoError(t, err)
+	assert.Equal(t, windowBuffers{}, b)
+	assert.Equal(t, 0, cap(b.newBuffer(time.Minute)))
+
+	b, err = parseWindowBuffers(t, `
+window_buffer:
+  expected_eps: 2.5
+  max_samples: 100
+`)
+	require.NoError(t, err)
+	assert.Equal(t, 25, b.capacity(10*time.Second))
+	assert.Equal(t, 100, b.capacity(time.Minute))
+	assert.Equal(t, 100, cap(b.newBuffer(time.Minute)))
+
+	_, err = parseWindowBuffers(t, `window_buffer: {expected_eps: -1}`)
+	assert.Error(t, err)
+	_, err = parseWindowBuffers(t, `window_buffer: {max_samples: -1}`)
+	assert.Error(t, err)
+}
+
+func TestWindowBufferPush(t *testing.T) {
+	b := windowBuffers{maxSamples: 3}
+
+	var values []float64
+	var head int
+	var overwritten bool
+	for i := 1; i <= 3; i++ {
+		values, head, overwritten = b.push(values, head, float64(i))
+		assert.False(t, overwritten)
+	}
+	assert.Equal(t, []float64{1, 2, 3}, values)
+	assert.Equal(t, 0, head)
+
+	// A full buffer replaces its oldest samples
+	values, head, overwritten = b.push(values, head, 4)
+	assert.True(t, overwritten)
+	values, head, _ = b.push(values, head, 5)
+	assert.Equal(t, []float64{4, 5, 3}, values)
+	assert.Equal(t, 2, head)
+	assert.Equal(t, []float64{3, 4, 5}, orderedSamples(values, head))
+
+	window := &WindowData{Values: values, ValuesHead: head}
+	assert.Equal(t, 5.0, window.lastValue())
+
+	values, head, _ = b.push(values, head, 6)
+	assert.Equal(t, 0, head)
+	window = &WindowData{Values: values, ValuesHead: head}
+	assert.Equal(t, 6.0, window.lastValue())
+	assert.Equal(t, []float64{4, 5, 6}, orderedSamples(values, head))
+}
+
+func TestWindowBufferBoundsWindows(t *testing.T) {
+	mgr := service.MockResources()
+	detector := &FirewallAnomalyDetector{
+		logger:             mgr.Logger(),
+		windowSeconds:      60,
+		sources:            map[string]string{"fortinet.firewall": "connection_count"},
+		sourceMetrics:      map[string][]sourceMetric{},
+		windows:            make(map[string]*WindowData),
+		buffers:            windowBuffers{expectedEPS: 0.05, maxSamples: 4},
+		windowsCreated:     mgr.Metrics().NewCounter("windows_created"),
+		samplesOverwritten: mgr.Metrics().NewCounter("window_samples_overwritten", "log_source"),
+	}
+
+	start := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 6; i++ {
+		detector.updateWindow("fortinet.firewall", float64(i), "192.168.1.1", start.Add(time.Duration(i)*time.Second))
+		if i == 1 {
+			// Preallocated for 0.05 logs per second over a minute
+			assert.Equal(t, 3, cap(detector.getWindow("fortinet.firewall").Values))
+		}
+	}
+
+	window := detector.getWindow("fortinet.firewall")
+	require.NotNil(t, window)
+	assert.Len(t, window.Values, 4)
+	assert.Equal(t, 6.0, window.lastValue())
+
+	// Features cover the latest samples only
+	features := detector.extractFeatures(window).asMap()
+	assert.Equal(t, 4.5, features["mean_value"])
+	assert.Equal(t, 3.0, features["min_value"])
+	assert.Equal(t, 6.0, features["max_value"])
+
+	// Merging unwraps both buffers into arrival order
+	other := &WindowData{Values: []float64{9, 7, 8}, ValuesHead: 1}
+	mergeWindows(window, other)
+	assert.Equal(t, []float64{3, 4, 5, 6, 7, 8, 9}, window.Values)
+	assert.Equal(t, 0, window.ValuesHead)
+}
@@ -0,0 +1,59 @@
+package processor
+
+import "unsafe"
+
+// Rough per-entry costs used to estimate the memory held by window state.
+// The estimate ignores allocator slack and is meant for trends, e.g. leak
+// detection and capacity planning, rather than exact accounting.
+const (
+	windowOverheadBytes = int64(unsafe.Sizeof(WindowData{})) + 64
+	mapEntryBytes       = 48
+	stringHeaderBytes   = int64(unsafe.Sizeof(""))
+	valueBytes          = int64(unsafe.Sizeof(float64(0)))
+)
+
+// windowStateBytes estimates the memory held by a window and its key.
+func windowStateBytes(key string, window *WindowData) int64 {
+	n := windowOverheadBytes + mapEntryBytes + int64(len(key))
+	n += int64(cap(window.Values)) * valueBytes
+	for prefix, values := range window.Metrics {
+		n += mapEntryBytes + int64(len(prefix)) + int64(cap(values))*valueBytes
+	}
+	for ip := range window.IPs {
+		n += mapEntryBytes + int64(len(ip))
+	}
+	if window.IPFilter != nil {
+		n += int64(cap(window.IPFilter.Bits))
+	}
+	for ip := range window.IPCounts {
+		n += mapEntryBytes + int64(len(ip))
+	}
+	for k := range window.Enrichment {
+		n += mapEntryBytes + int64(len(k))
+	}
+	for _, raw := range window.Pending {
+		n += stringHeaderBytes + int64(len(raw))
+	}
+	n += int64(len(window.Source) + len(window.Tenant))
+	return n
+}
+
+// windowStats summarises the windows held in memory.
+func (f *FirewallAnomalyDetector) windowStats() (windows, values int, bytes int64) {
+	f.windowsMutex.RLock()
+	defer f.windowsMutex.RUnlock()
+
+	for key, window := range f.windows {
+		values += len(window.Values)
+		bytes += windowStateBytes(key, window)
+	}
+	return len(f.windows), values, bytes
+}
+
+// updateWindowGauges refreshes the window capacity gauges.
+func (f *FirewallAnomalyDetector) updateWindowGauges() {
+	windows, values, bytes := f.windowStats()
+	f.activeWindows.Set(int64(windows))
+	f.bufferedValues.Set(int64(values))
+	f.windowStateBytes.Set(bytes)
+}
@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindowStats(t *testing.T) {
+	detector := &FirewallAnomalyDetector{
+		windowSeconds: 60,
+		windows:       make(map[string]*WindowData),
+	}
+
+	windows, values, bytes := detector.windowStats()
+	assert.Equal(t, 0, windows)
+	assert.Equal(t, 0, values)
+	assert.Equal(t, int64(0), bytes)
+
+	now := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	detector.updateWindow("fortinet.firewall", 10, "192.168.1.1", now)
+	detector.updateWindow("fortinet.firewall", 20, "192.168.1.2", now)
+	detector.updateWindow("paloalto.firewall", 5, "192.168.1.1", now)
+
+	windows, values, bytes = detector.windowStats()
+	assert.Equal(t, 2, windows)
+	assert.Equal(t, 3, values)
+	assert.Greater(t, bytes, 2*windowOverheadBytes)
+
+	// More logs mean more state
+	small := windowStateBytes("fortinet.firewall", detector.getWindow("fortinet.firewall"))
+	detector.getWindow("fortinet.firewall").Pending = []string{`{"log_source":"fortinet.firewall"}`}
+	assert.Greater(t, windowStateBytes("fortinet.firewall", detector.getWindow("fortinet.firewall")), small)
+
+	// Gauges are nil safe for detectors built without metrics
+	detector.updateWindowGauges()
+}
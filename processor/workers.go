@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+)
+
+func workerPoolField() *service.ConfigField {
+	return service.NewObjectField("worker_pool",
+		service.NewIntField("workers").
+			Description("Number of workers processing the logs of a read in parallel. Logs are sharded by window key, so that the logs of a window are processed in order by one worker while independent windows are scored in parallel. Zero uses one worker per CPU.").
+			Default(1),
+	).
+		Description("Parallel processing of the logs read from Redis on multi-core hosts").
+		Advanced()
+}
+
+// workerPool shards the logs of a read across workers by window key.
+type workerPool struct {
+	workers int
+}
+
+func newWorkerPoolFromConfig(conf *service.ParsedConfig) (*workerPool, error) {
+	workers, err := conf.FieldInt("workers")
+	if err != nil {
+		return nil, err
+	}
+	if workers < 0 {
+		return nil, fmt.Errorf("worker_pool.workers must not be negative, got %d", workers)
+	}
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers == 1 {
+		return nil, nil
+	}
+	return &workerPool{workers: workers}, nil
+}
+
+// shard returns the worker processing the logs of a window key.
+func (p *workerPool) shard(windowKey string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(windowKey))
+	return int(h.Sum32() % uint32(p.workers))
+}
+
+// processLogs processes the logs of a read, in parallel across window keys
+// when the worker pool is enabled. Results keep the order of the logs that
+// produced them.
+func (f *FirewallAnomalyDetector) processLogs(ctx context.Context, logs []FirewallLog) []*service.Message {
+	defer f.startEnrichment(ctx, logs)()
+
+	results := make([]*service.Message, len(logs))
+	process := func(i int) {
+		result, err := f.processLog(ctx, logs[i])
+		if err != nil {
+			f.logger.Errorf("Failed to process log: %v", err)
+			return
+		}
+		results[i] = result
+	}
+
+	if f.workers == nil || len(logs) < 2 {
+		for i := range logs {
+			process(i)
+		}
+	} else {
+		shards := make([][]int, f.workers.workers)
+		for i, log := range logs {
+			shard := f.workers.shard(windowKeyFor(log.tenant, log.LogSource))
+			shards[shard] = append(shards[shard], i)
+		}
+
+		var wg sync.WaitGroup
+		for _, indexes := range shards {
+			if len(indexes) == 0 {
+				continue
+			}
+			wg.Add(1)
+			go func(indexes []int) {
+				defer wg.Done()
+				for _, i := range indexes {
+					process(i)
+				}
+			}(indexes)
+		}
+		wg.Wait()
+	}
+
+	emitted := results[:0]
+	for _, result := range results {
+		if result != nil {
+			emitted = append(emitted, result)
+		}
+	}
+	return emitted
+}
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redpanda-data/benthos/v4/public/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPoolConfig(t *testing.T) {
+	parse := func(yaml string) (*workerPool, error) {
+		spec := service.NewConfigSpec().Field(workerPoolField())
+		conf, err := spec.ParseYAML(yaml, nil)
+		require.NoError(t, err)
+		return newWorkerPoolFromConfig(conf.Namespace("worker_pool"))
+	}
+
+	pool, err := parse(`worker_pool: {}`)
+	require.NoError(t, err)
+	assert.Nil(t, pool)
+
+	pool, err = parse(`worker_pool: {workers: 0}`)
+	require.NoError(t, err)
+	if runtime.NumCPU() > 1 {
+		require.NotNil(t, pool)
+		assert.Equal(t, runtime.NumCPU(), pool.workers)
+	}
+
+	pool, err = parse(`worker_pool: {workers: 4}`)
+	require.NoError(t, err)
+	require.NotNil(t, pool)
+	assert.Equal(t, pool.shard("fortinet.firewall"), pool.shard("fortinet.firewall"))
+
+	_, err = parse(`worker_pool: {workers: -1}`)
+	assert.Error(t, err)
+}
+
+func TestWorkerPoolMatchesSerialProcessing(t *testing.T) {
+	const parallelConfig = `
+pipeline:
+  processors:
+    - firewall_anomaly_detector:
+        default_source:
+          metric: connection_count
+        worker_pool:
+          workers: 4
+`
+	sources := []string{"bench.source.0", "bench.source.1", "bench.source.2", "bench.source.3", "bench.source.4", "bench.source.5"}
+	opts := DefaultBenchOptions
+	opts.Logs = 3000
+	opts.EventsPerSecond = 20
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	items := generateBenchLogs(opts, sources, start)
+
+	run := func(confYAML string) ([]string, map[string][]auditRecord) {
+		detector, err := newOfflineDetector(service.NewEnvironment(), []byte(confYAML), os.LookupEnv)
+		require.NoError(t, err)
+		defer detector.Close(context.Background())
+
+		var mut sync.Mutex
+		decisions := map[string][]auditRecord{}
+		detector.onDecided = func(decision auditRecord) {
+			mut.Lock()
+			decisions[decision.WindowKey] = append(decisions[decision.WindowKey], decision)
+			mut.Unlock()
+		}
+
+		// Windows complete once the clock is a window length past their end,
+		// here on every log
+		ctx := context.Background()
+		now := start.Add(24 * time.Hour)
+		detector.clock = func() time.Time { return now }
+		var results []string
+		for i := 0; i < len(items); i += 100 {
+			var logs []FirewallLog
+			for _, item := range items[i : i+100] {
+				log, ok := detector.parseLog(ctx, item)
+				require.True(t, ok)
+				logs = append(logs, log)
+			}
+			for _, msg := range detector.processLogs(ctx, logs) {
+				b, err := msg.AsBytes()
+				require.NoError(t, err)
+				results = append(results, string(b))
+			}
+		}
+		return results, decisions
+	}
+
+	serialResults, serialDecisions := run(benchConfig)
+	parallelResults, parallelDecisions := run(parallelConfig)
+
+	require.NotEmpty(t, serialResults)
+	assert.Equal(t, len(serialResults), len(parallelResults))
+	require.Len(t, parallelDecisions, len(sources))
+	for key, decisions := range serialDecisions {
+		got := parallelDecisions[key]
+		require.Len(t, got, len(decisions), key)
+		for i := range decisions {
+			assert.Equal(t, decisions[i].WindowStart, got[i].WindowStart, key)
+			assert.Equal(t, decisions[i].Samples, got[i].Samples, key)
+			assert.Equal(t, decisions[i].AnomalyScore, got[i].AnomalyScore, key)
+		}
+	}
+}